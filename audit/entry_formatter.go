@@ -25,8 +25,9 @@ import (
 )
 
 var (
-	_ Formatter        = (*EntryFormatter)(nil)
-	_ eventlogger.Node = (*EntryFormatter)(nil)
+	_ Formatter          = (*EntryFormatter)(nil)
+	_ eventlogger.Node   = (*EntryFormatter)(nil)
+	_ eventlogger.Closer = (*EntryFormatter)(nil)
 )
 
 // timeProvider offers a way to supply a pre-configured time.
@@ -66,7 +67,8 @@ type FormatterConfig struct {
 	// This should only ever be used in a testing context
 	OmitTime bool
 
-	// The required/target format for the event (supported: JSONFormat and JSONxFormat).
+	// The required/target format for the event (supported: JSONFormat, JSONxFormat,
+	// CEFFormat, LEEFFormat and OTLPFormat).
 	RequiredFormat format
 
 	// headerFormatter specifies the formatter used for headers that existing in any incoming audit request.
@@ -74,6 +76,11 @@ type FormatterConfig struct {
 
 	// Prefix specifies a Prefix that should be prepended to any formatted request or response before serialization.
 	Prefix string
+
+	// HashChain specifies whether each entry should include a sequence number
+	// and the hash of the previous entry, so that any entries which are removed,
+	// modified or reordered can be detected (supported: JSONFormat).
+	HashChain bool
 }
 
 // EntryFormatter should be used to format audit requests and responses.
@@ -83,10 +90,11 @@ type EntryFormatter struct {
 	salter Salter
 	logger hclog.Logger
 	name   string
+	chain  *hashChain
 }
 
 // NewFormatterConfig should be used to create a FormatterConfig.
-// Accepted options: WithElision, WithFormat, WithHMACAccessor, WithOmitTime, WithPrefix, WithRaw.
+// Accepted options: WithElision, WithFormat, WithHashChain, WithHMACAccessor, WithOmitTime, WithPrefix, WithRaw.
 func NewFormatterConfig(headerFormatter HeaderFormatter, opt ...Option) (FormatterConfig, error) {
 	if headerFormatter == nil || reflect.ValueOf(headerFormatter).IsNil() {
 		return FormatterConfig{}, fmt.Errorf("header formatter is required: %w", ErrInvalidParameter)
@@ -97,9 +105,14 @@ func NewFormatterConfig(headerFormatter HeaderFormatter, opt ...Option) (Formatt
 		return FormatterConfig{}, err
 	}
 
+	if opts.withHashChain && opts.withFormat != JSONFormat {
+		return FormatterConfig{}, fmt.Errorf("hash chaining is only supported with the %q format: %w", JSONFormat, ErrInvalidParameter)
+	}
+
 	return FormatterConfig{
 		headerFormatter:    headerFormatter,
 		ElideListResponses: opts.withElision,
+		HashChain:          opts.withHashChain,
 		HMACAccessor:       opts.withHMACAccessor,
 		OmitTime:           opts.withOmitTime,
 		Prefix:             opts.withPrefix,
@@ -109,7 +122,8 @@ func NewFormatterConfig(headerFormatter HeaderFormatter, opt ...Option) (Formatt
 }
 
 // NewEntryFormatter should be used to create an EntryFormatter.
-func NewEntryFormatter(name string, config FormatterConfig, salter Salter, logger hclog.Logger) (*EntryFormatter, error) {
+// Accepted options: WithStorage.
+func NewEntryFormatter(name string, config FormatterConfig, salter Salter, logger hclog.Logger, opt ...Option) (*EntryFormatter, error) {
 	name = strings.TrimSpace(name)
 	if name == "" {
 		return nil, fmt.Errorf("name is required: %w", ErrInvalidParameter)
@@ -123,12 +137,36 @@ func NewEntryFormatter(name string, config FormatterConfig, salter Salter, logge
 		return nil, fmt.Errorf("cannot create a new audit formatter with nil logger: %w", ErrInvalidParameter)
 	}
 
-	return &EntryFormatter{
+	opts, err := getOpts(opt...)
+	if err != nil {
+		return nil, err
+	}
+
+	f := &EntryFormatter{
 		config: config,
 		salter: salter,
 		logger: logger,
 		name:   name,
-	}, nil
+	}
+
+	if config.HashChain {
+		f.chain = &hashChain{
+			salter:  salter,
+			logger:  logger,
+			storage: opts.withStorage,
+		}
+	}
+
+	return f, nil
+}
+
+// Close checkpoints the head of the hash chain, if enabled.
+func (f *EntryFormatter) Close(ctx context.Context) error {
+	if f.chain == nil {
+		return nil
+	}
+
+	return f.chain.close(ctx)
 }
 
 // Reopen is a no-op for the formatter node.
@@ -226,28 +264,26 @@ func (f *EntryFormatter) Process(ctx context.Context, e *eventlogger.Event) (_ *
 		return nil, fmt.Errorf("unable to parse %s from audit event: %w", a.Subtype, err)
 	}
 
-	result, err := jsonutil.EncodeJSON(entry)
-	if err != nil {
-		return nil, fmt.Errorf("unable to format %s: %w", a.Subtype, err)
-	}
+	var result []byte
+	switch {
+	case f.chain != nil:
+		// Link the entry to the previous one, this must happen as the entry is
+		// encoded so that the hash covers exactly what will be written.
+		result, err = f.chain.link(ctx, func(sequence uint64, prevHash string) ([]byte, error) {
+			switch e := entry.(type) {
+			case *RequestEntry:
+				e.Sequence, e.PrevHash = sequence, prevHash
+			case *ResponseEntry:
+				e.Sequence, e.PrevHash = sequence, prevHash
+			}
 
-	if f.config.RequiredFormat == JSONxFormat {
-		var err error
-		result, err = jsonx.EncodeJSONBytes(result)
-		if err != nil {
-			return nil, fmt.Errorf("unable to encode JSONx using JSON data: %w", err)
-		}
-		if result == nil {
-			return nil, fmt.Errorf("encoded JSONx was nil: %w", err)
-		}
+			return f.encode(entry)
+		})
+	default:
+		result, err = f.encode(entry)
 	}
-
-	// This makes a bit of a mess of the 'format' since both JSON and XML (JSONx)
-	// don't support a prefix just sitting there.
-	// However, this would be a breaking change to how Vault currently works to
-	// include the prefix as part of the JSON object or XML document.
-	if f.config.Prefix != "" {
-		result = append([]byte(f.config.Prefix), result...)
+	if err != nil {
+		return nil, fmt.Errorf("unable to format %s: %w", a.Subtype, err)
 	}
 
 	// Copy some properties from the event (and audit event) and store the
@@ -272,6 +308,46 @@ func (f *EntryFormatter) Process(ctx context.Context, e *eventlogger.Event) (_ *
 	return e2, nil
 }
 
+// encode serializes an entry to the required format, prepending any configured prefix.
+func (f *EntryFormatter) encode(entry any) ([]byte, error) {
+	var result []byte
+	var err error
+
+	switch f.config.RequiredFormat {
+	case CEFFormat:
+		result, err = encodeCEF(entry)
+	case LEEFFormat:
+		result, err = encodeLEEF(entry)
+	case OTLPFormat:
+		result, err = encodeOTLP(entry)
+	default:
+		result, err = jsonutil.EncodeJSON(entry)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if f.config.RequiredFormat == JSONxFormat {
+		result, err = jsonx.EncodeJSONBytes(result)
+		if err != nil {
+			return nil, fmt.Errorf("unable to encode JSONx using JSON data: %w", err)
+		}
+		if result == nil {
+			return nil, fmt.Errorf("encoded JSONx was nil: %w", err)
+		}
+	}
+
+	// This makes a bit of a mess of the 'format' since JSON, XML (JSONx) and
+	// OTLP don't support a prefix just sitting there.
+	// However, this would be a breaking change to how Vault currently works to
+	// include the prefix as part of the JSON object or XML document.
+	if f.config.Prefix != "" {
+		result = append([]byte(f.config.Prefix), result...)
+	}
+
+	return result, nil
+}
+
 // FormatRequest attempts to format the specified logical.LogInput into a RequestEntry.
 func (f *EntryFormatter) FormatRequest(ctx context.Context, in *logical.LogInput, provider timeProvider) (*RequestEntry, error) {
 	switch {
@@ -690,6 +766,7 @@ func doElideListResponseData(data map[string]interface{}) {
 }
 
 // newTemporaryEntryFormatter creates a cloned EntryFormatter instance with a non-persistent Salter.
+// Entries it formats are not hash chained, as they can't be verified without the salt.
 func newTemporaryEntryFormatter(n *EntryFormatter) *EntryFormatter {
 	return &EntryFormatter{
 		salter: &nonPersistentSalt{},
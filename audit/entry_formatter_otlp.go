@@ -0,0 +1,197 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/hashicorp/vault/sdk/helper/jsonutil"
+)
+
+const (
+	// otlpScopeName is the instrumentation scope used for audit log records.
+	otlpScopeName = "vault.audit"
+
+	// Severity numbers, as defined by the OpenTelemetry log data model.
+	otlpSeverityInfo  = 9
+	otlpSeverityError = 17
+)
+
+// otlpAttributes maps fields of an entry to the log record attributes they
+// are copied to, in addition to being part of the body.
+var otlpAttributes = []struct {
+	key  string
+	path []string
+}{
+	{key: "vault.audit.type", path: []string{"type"}},
+	{key: "vault.request.id", path: []string{"request", "id"}},
+	{key: "vault.request.operation", path: []string{"request", "operation"}},
+	{key: "vault.request.path", path: []string{"request", "path"}},
+	{key: "vault.request.mount_type", path: []string{"request", "mount_type"}},
+	{key: "vault.namespace.path", path: []string{"request", "namespace", "path"}},
+	{key: "vault.auth.entity_id", path: []string{"auth", "entity_id"}},
+	{key: "vault.auth.display_name", path: []string{"auth", "display_name"}},
+	{key: "client.address", path: []string{"request", "remote_address"}},
+	{key: "client.port", path: []string{"request", "remote_port"}},
+}
+
+// The following types represent the OTLP/JSON encoding of an ExportLogsServiceRequest,
+// containing a single log record.
+// See: https://opentelemetry.io/docs/specs/otlp/#json-protobuf-encoding
+type otlpLogsData struct {
+	ResourceLogs []otlpResourceLogs `json:"resourceLogs"`
+}
+
+type otlpResourceLogs struct {
+	Resource  otlpResource    `json:"resource"`
+	ScopeLogs []otlpScopeLogs `json:"scopeLogs"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpScopeLogs struct {
+	Scope      otlpScope       `json:"scope"`
+	LogRecords []otlpLogRecord `json:"logRecords"`
+}
+
+type otlpScope struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+}
+
+type otlpLogRecord struct {
+	TimeUnixNano   string         `json:"timeUnixNano,omitempty"`
+	SeverityNumber int            `json:"severityNumber"`
+	SeverityText   string         `json:"severityText"`
+	Body           otlpAnyValue   `json:"body"`
+	Attributes     []otlpKeyValue `json:"attributes,omitempty"`
+}
+
+type otlpKeyValue struct {
+	Key   string       `json:"key"`
+	Value otlpAnyValue `json:"value"`
+}
+
+type otlpAnyValue struct {
+	StringValue *string           `json:"stringValue,omitempty"`
+	BoolValue   *bool             `json:"boolValue,omitempty"`
+	IntValue    *string           `json:"intValue,omitempty"`
+	DoubleValue *float64          `json:"doubleValue,omitempty"`
+	ArrayValue  *otlpArrayValue   `json:"arrayValue,omitempty"`
+	KvlistValue *otlpKeyValueList `json:"kvlistValue,omitempty"`
+}
+
+type otlpArrayValue struct {
+	Values []otlpAnyValue `json:"values"`
+}
+
+type otlpKeyValueList struct {
+	Values []otlpKeyValue `json:"values"`
+}
+
+// otlpString creates an AnyValue holding a string.
+func otlpString(s string) otlpAnyValue {
+	return otlpAnyValue{StringValue: &s}
+}
+
+// otlpValue converts a value decoded from JSON into an AnyValue.
+func otlpValue(v any) otlpAnyValue {
+	switch v := v.(type) {
+	case string:
+		return otlpString(v)
+	case bool:
+		return otlpAnyValue{BoolValue: &v}
+	case json.Number:
+		// OTLP/JSON encodes 64-bit integers as strings.
+		if _, err := v.Int64(); err == nil {
+			s := v.String()
+			return otlpAnyValue{IntValue: &s}
+		}
+		f, err := v.Float64()
+		if err != nil {
+			return otlpString(v.String())
+		}
+		return otlpAnyValue{DoubleValue: &f}
+	case []any:
+		values := make([]otlpAnyValue, 0, len(v))
+		for _, e := range v {
+			values = append(values, otlpValue(e))
+		}
+		return otlpAnyValue{ArrayValue: &otlpArrayValue{Values: values}}
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		values := make([]otlpKeyValue, 0, len(v))
+		for _, k := range keys {
+			values = append(values, otlpKeyValue{Key: k, Value: otlpValue(v[k])})
+		}
+		return otlpAnyValue{KvlistValue: &otlpKeyValueList{Values: values}}
+	case nil:
+		return otlpAnyValue{}
+	default:
+		return otlpString(fmt.Sprint(v))
+	}
+}
+
+// encodeOTLP renders an entry as an OpenTelemetry log record, using the OTLP/JSON
+// encoding of an ExportLogsServiceRequest so that each line can be sent directly
+// to a collector. The entry is the body of the record, and commonly used fields
+// are also copied to attributes.
+func encodeOTLP(entry any) ([]byte, error) {
+	fields, err := entryFields(entry)
+	if err != nil {
+		return nil, err
+	}
+
+	record := otlpLogRecord{
+		SeverityNumber: otlpSeverityInfo,
+		SeverityText:   "INFO",
+		Body:           otlpValue(fields),
+	}
+
+	if stringField(fields, "error") != "" {
+		record.SeverityNumber = otlpSeverityError
+		record.SeverityText = "ERROR"
+	}
+
+	if t, ok := entryTime(fields); ok {
+		record.TimeUnixNano = strconv.FormatInt(t.UnixNano(), 10)
+	}
+
+	for _, a := range otlpAttributes {
+		if v := stringField(fields, a.path...); v != "" {
+			record.Attributes = append(record.Attributes, otlpKeyValue{Key: a.key, Value: otlpString(v)})
+		}
+	}
+
+	ver := productVersion()
+
+	return jsonutil.EncodeJSON(&otlpLogsData{
+		ResourceLogs: []otlpResourceLogs{
+			{
+				Resource: otlpResource{
+					Attributes: []otlpKeyValue{
+						{Key: "service.name", Value: otlpString("vault")},
+						{Key: "service.version", Value: otlpString(ver)},
+					},
+				},
+				ScopeLogs: []otlpScopeLogs{
+					{
+						Scope:      otlpScope{Name: otlpScopeName, Version: ver},
+						LogRecords: []otlpLogRecord{record},
+					},
+				},
+			},
+		},
+	})
+}
@@ -0,0 +1,111 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package audit
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestEncodeOTLP ensures that entries are rendered as OTLP/JSON export requests
+// containing a single log record.
+func TestEncodeOTLP(t *testing.T) {
+	t.Parallel()
+
+	got, err := encodeOTLP(testSIEMEntry())
+	require.NoError(t, err)
+	require.Equal(t, byte('\n'), got[len(got)-1])
+
+	var data otlpLogsData
+	require.NoError(t, json.Unmarshal(got, &data))
+	require.Len(t, data.ResourceLogs, 1)
+	require.Len(t, data.ResourceLogs[0].ScopeLogs, 1)
+	require.Equal(t, otlpScopeName, data.ResourceLogs[0].ScopeLogs[0].Scope.Name)
+	require.Equal(t, "vault", *data.ResourceLogs[0].Resource.Attributes[0].Value.StringValue)
+
+	records := data.ResourceLogs[0].ScopeLogs[0].LogRecords
+	require.Len(t, records, 1)
+
+	record := records[0]
+	require.Equal(t, "1704164645678000000", record.TimeUnixNano)
+	require.Equal(t, otlpSeverityError, record.SeverityNumber)
+	require.Equal(t, "ERROR", record.SeverityText)
+
+	attrs := make(map[string]string)
+	for _, a := range record.Attributes {
+		attrs[a.Key] = *a.Value.StringValue
+	}
+	require.Equal(t, map[string]string{
+		"vault.audit.type":        "request",
+		"vault.request.id":        "req-1",
+		"vault.request.operation": "update",
+		"vault.request.path":      "secret/data/foo",
+		"vault.auth.display_name": "token",
+		"client.address":          "127.0.0.1",
+		"client.port":             "8200",
+	}, attrs)
+
+	body := make(map[string]otlpAnyValue)
+	for _, kv := range record.Body.KvlistValue.Values {
+		body[kv.Key] = kv.Value
+	}
+	require.Equal(t, "permission denied", *body["error"].StringValue)
+	require.Len(t, body["auth"].KvlistValue.Values, 2)
+	require.Equal(t, "policies", body["auth"].KvlistValue.Values[1].Key)
+	require.Len(t, body["auth"].KvlistValue.Values[1].Value.ArrayValue.Values, 2)
+}
+
+// TestOTLPValue ensures that values decoded from JSON are converted to the
+// correct kind of AnyValue.
+func TestOTLPValue(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		value    any
+		expected string
+	}{
+		"string": {
+			value:    "foo",
+			expected: `{"stringValue":"foo"}`,
+		},
+		"bool": {
+			value:    true,
+			expected: `{"boolValue":true}`,
+		},
+		"int": {
+			value:    json.Number("42"),
+			expected: `{"intValue":"42"}`,
+		},
+		"double": {
+			value:    json.Number("4.2"),
+			expected: `{"doubleValue":4.2}`,
+		},
+		"nil": {
+			value:    nil,
+			expected: `{}`,
+		},
+		"array": {
+			value:    []any{"a", json.Number("1")},
+			expected: `{"arrayValue":{"values":[{"stringValue":"a"},{"intValue":"1"}]}}`,
+		},
+		"map": {
+			value:    map[string]any{"b": "2", "a": "1"},
+			expected: `{"kvlistValue":{"values":[{"key":"a","value":{"stringValue":"1"}},{"key":"b","value":{"stringValue":"2"}}]}}`,
+		},
+	}
+
+	for name, tc := range tests {
+		name := name
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := json.Marshal(otlpValue(tc.value))
+			require.NoError(t, err)
+			require.JSONEq(t, tc.expected, string(got))
+		})
+	}
+}
@@ -0,0 +1,286 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package audit
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/helper/jsonutil"
+	vaultVersion "github.com/hashicorp/vault/version"
+)
+
+const (
+	// siemVendor and siemProduct identify Vault in CEF and LEEF headers.
+	siemVendor  = "HashiCorp"
+	siemProduct = "Vault"
+
+	// leefTimeLayout is the layout used for the LEEF devTime attribute, it must
+	// match leefTimeFormat which tells the consumer how to parse it.
+	leefTimeLayout = "2006-01-02T15:04:05.000Z07:00"
+	leefTimeFormat = "yyyy-MM-dd'T'HH:mm:ss.SSSXXX"
+)
+
+var (
+	cefHeaderEscaper  = strings.NewReplacer(`\`, `\\`, `|`, `\|`, "\n", " ", "\r", " ")
+	cefValueEscaper   = strings.NewReplacer(`\`, `\\`, `=`, `\=`, "\n", `\n`, "\r", `\r`)
+	leefHeaderEscaper = strings.NewReplacer(`|`, `\|`, "\n", " ", "\r", " ")
+	leefValueEscaper  = strings.NewReplacer("\t", `\t`, "\n", `\n`, "\r", `\r`)
+
+	// cefKeys maps fields of an entry to keys from the CEF extension dictionary.
+	cefKeys = map[string]string{
+		"auth.display_name":      "suser",
+		"error":                  "reason",
+		"request.id":             "externalId",
+		"request.operation":      "act",
+		"request.path":           "request",
+		"request.remote_address": "src",
+		"request.remote_port":    "spt",
+		"type":                   "cat",
+	}
+
+	// leefKeys maps fields of an entry to predefined LEEF attributes.
+	leefKeys = map[string]string{
+		"auth.display_name":      "usrName",
+		"request.remote_address": "src",
+		"request.remote_port":    "srcPort",
+		"type":                   "cat",
+	}
+)
+
+// siemField is a single key/value pair within a CEF extension or LEEF event.
+type siemField struct {
+	key   string
+	value string
+}
+
+// productVersion returns the version of Vault which is included in each event.
+func productVersion() string {
+	return vaultVersion.GetVersion().VersionNumber()
+}
+
+// entryFields converts a formatted request or response entry into a generic map,
+// so that it can be rendered in formats which aren't JSON.
+func entryFields(entry any) (map[string]any, error) {
+	b, err := jsonutil.EncodeJSON(entry)
+	if err != nil {
+		return nil, err
+	}
+
+	var fields map[string]any
+	if err := jsonutil.DecodeJSONFromReader(bytes.NewReader(b), &fields); err != nil {
+		return nil, err
+	}
+
+	return fields, nil
+}
+
+// stringField returns the value of a (nested) field as a string, or an empty
+// string when the field isn't present.
+func stringField(fields map[string]any, path ...string) string {
+	var v any = fields
+	for _, p := range path {
+		m, ok := v.(map[string]any)
+		if !ok {
+			return ""
+		}
+		v = m[p]
+	}
+
+	switch v := v.(type) {
+	case nil, map[string]any, []any:
+		return ""
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// entryTime parses the time of an entry, which will be missing if the formatter
+// has been configured to omit it.
+func entryTime(fields map[string]any) (time.Time, bool) {
+	t, err := time.Parse(time.RFC3339Nano, stringField(fields, "time"))
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	return t, true
+}
+
+// entrySeverity returns a severity for the entry on a scale of 0 to 10, entries
+// which record an error are treated as more severe.
+func entrySeverity(fields map[string]any) int {
+	if stringField(fields, "error") != "" {
+		return 7
+	}
+
+	return 3
+}
+
+// flattenFields calls fn for each scalar value within v, with a key made up of
+// the dot separated path to the value. Arrays of scalar values are joined using
+// commas, other arrays use the index of each element as part of the key.
+func flattenFields(key string, v any, fn func(key, value string)) {
+	join := func(k string) string {
+		if key == "" {
+			return k
+		}
+		return key + "." + k
+	}
+
+	switch v := v.(type) {
+	case nil:
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			flattenFields(join(k), v[k], fn)
+		}
+	case []any:
+		values := make([]string, 0, len(v))
+		for _, e := range v {
+			switch e.(type) {
+			case nil, map[string]any, []any:
+				for i, e := range v {
+					flattenFields(join(strconv.Itoa(i)), e, fn)
+				}
+				return
+			}
+			values = append(values, fmt.Sprint(e))
+		}
+
+		if len(values) > 0 {
+			fn(key, strings.Join(values, ","))
+		}
+	default:
+		fn(key, fmt.Sprint(v))
+	}
+}
+
+// siemKey replaces any characters which aren't safe to use within a CEF or LEEF key.
+func siemKey(key string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '_', r == '-':
+			return r
+		default:
+			return '_'
+		}
+	}, key)
+}
+
+// siemFields flattens the entry, replacing the keys of fields which appear in
+// mapped, and prefixing the keys of all other fields with prefix. The time of
+// the entry is omitted as each format has its own representation.
+func siemFields(fields map[string]any, mapped map[string]string, prefix string) []siemField {
+	var result []siemField
+	flattenFields("", fields, func(key, value string) {
+		switch k, ok := mapped[key]; {
+		case key == "time":
+		case ok:
+			result = append(result, siemField{key: k, value: value})
+		default:
+			result = append(result, siemField{key: prefix + siemKey(key), value: value})
+		}
+	})
+
+	return result
+}
+
+// encodeCEF renders an entry as an ArcSight Common Event Format (CEF) event.
+// Fields which appear in the CEF extension dictionary use the dictionary key,
+// all other fields are included as additional data (ad.*).
+func encodeCEF(entry any) ([]byte, error) {
+	fields, err := entryFields(entry)
+	if err != nil {
+		return nil, err
+	}
+
+	signature := stringField(fields, "type")
+	name := strings.TrimSpace(stringField(fields, "request", "operation") + " " + stringField(fields, "request", "path"))
+	if name == "" {
+		name = signature
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "CEF:0|%s|%s|%s|%s|%s|%d|",
+		siemVendor,
+		siemProduct,
+		cefHeaderEscaper.Replace(productVersion()),
+		cefHeaderEscaper.Replace(signature),
+		cefHeaderEscaper.Replace(name),
+		entrySeverity(fields),
+	)
+
+	ext := make([]siemField, 0, len(fields)+2)
+	if t, ok := entryTime(fields); ok {
+		ext = append(ext, siemField{key: "rt", value: strconv.FormatInt(t.UnixMilli(), 10)})
+	}
+
+	outcome := "success"
+	if stringField(fields, "error") != "" {
+		outcome = "failure"
+	}
+	ext = append(ext, siemField{key: "outcome", value: outcome})
+	ext = append(ext, siemFields(fields, cefKeys, "ad.")...)
+
+	for i, f := range ext {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(f.key)
+		b.WriteByte('=')
+		b.WriteString(cefValueEscaper.Replace(f.value))
+	}
+	b.WriteByte('\n')
+
+	return []byte(b.String()), nil
+}
+
+// encodeLEEF renders an entry as an IBM QRadar Log Event Extended Format (LEEF)
+// 2.0 event, using a tab to separate attributes. Fields which have a predefined
+// LEEF attribute use that key, all other fields use their dot separated path.
+func encodeLEEF(entry any) ([]byte, error) {
+	fields, err := entryFields(entry)
+	if err != nil {
+		return nil, err
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "LEEF:2.0|%s|%s|%s|%s|x09|",
+		siemVendor,
+		siemProduct,
+		leefHeaderEscaper.Replace(productVersion()),
+		leefHeaderEscaper.Replace(stringField(fields, "type")),
+	)
+
+	attrs := make([]siemField, 0, len(fields)+3)
+	if t, ok := entryTime(fields); ok {
+		attrs = append(attrs,
+			siemField{key: "devTime", value: t.Format(leefTimeLayout)},
+			siemField{key: "devTimeFormat", value: leefTimeFormat},
+		)
+	}
+	attrs = append(attrs, siemField{key: "sev", value: strconv.Itoa(entrySeverity(fields))})
+	attrs = append(attrs, siemFields(fields, leefKeys, "")...)
+
+	for i, a := range attrs {
+		if i > 0 {
+			b.WriteByte('\t')
+		}
+		b.WriteString(a.key)
+		b.WriteByte('=')
+		b.WriteString(leefValueEscaper.Replace(a.value))
+	}
+	b.WriteByte('\n')
+
+	return []byte(b.String()), nil
+}
@@ -0,0 +1,114 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package audit
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// testSIEMEntry returns a request entry containing fields which are mapped to
+// well-known keys, fields which must be escaped and nested data.
+func testSIEMEntry() *RequestEntry {
+	return &RequestEntry{
+		Type:  "request",
+		Time:  "2024-01-02T03:04:05.678Z",
+		Error: "permission denied",
+		Auth: &Auth{
+			DisplayName: "token",
+			Policies:    []string{"default", "ops"},
+		},
+		Request: &Request{
+			ID:         "req-1",
+			Operation:  "update",
+			Path:       "secret/data/foo",
+			RemoteAddr: "127.0.0.1",
+			RemotePort: 8200,
+			Data: map[string]interface{}{
+				"equation": "a=b|c\\d",
+				"note":     "line one\nline\ttwo",
+			},
+		},
+	}
+}
+
+// TestEncodeCEF ensures that entries are rendered as CEF events, using the
+// extension dictionary where possible and escaping values.
+func TestEncodeCEF(t *testing.T) {
+	t.Parallel()
+
+	got, err := encodeCEF(testSIEMEntry())
+	require.NoError(t, err)
+
+	expected := "CEF:0|HashiCorp|Vault|" + productVersion() + "|request|update secret/data/foo|7|" +
+		"rt=1704164645678 outcome=failure " +
+		"suser=token " +
+		"ad.auth.policies=default,ops " +
+		"reason=permission denied " +
+		"ad.request.data.equation=a\\=b|c\\\\d " +
+		"ad.request.data.note=line one\\nline\ttwo " +
+		"externalId=req-1 " +
+		"act=update " +
+		"request=secret/data/foo " +
+		"src=127.0.0.1 " +
+		"spt=8200 " +
+		"cat=request\n"
+	require.Equal(t, expected, string(got))
+}
+
+// TestEncodeLEEF ensures that entries are rendered as tab delimited LEEF 2.0
+// events, using predefined attributes where possible and escaping values.
+func TestEncodeLEEF(t *testing.T) {
+	t.Parallel()
+
+	got, err := encodeLEEF(testSIEMEntry())
+	require.NoError(t, err)
+
+	expected := "LEEF:2.0|HashiCorp|Vault|" + productVersion() + "|request|x09|" +
+		"devTime=2024-01-02T03:04:05.678Z\t" +
+		"devTimeFormat=yyyy-MM-dd'T'HH:mm:ss.SSSXXX\t" +
+		"sev=7\t" +
+		"usrName=token\t" +
+		"auth.policies=default,ops\t" +
+		"error=permission denied\t" +
+		"request.data.equation=a=b|c\\d\t" +
+		"request.data.note=line one\\nline\\ttwo\t" +
+		"request.id=req-1\t" +
+		"request.operation=update\t" +
+		"request.path=secret/data/foo\t" +
+		"src=127.0.0.1\t" +
+		"srcPort=8200\t" +
+		"cat=request\n"
+	require.Equal(t, expected, string(got))
+}
+
+// TestFlattenFields ensures that nested values are flattened into dot separated
+// keys, and that arrays are handled according to their contents.
+func TestFlattenFields(t *testing.T) {
+	t.Parallel()
+
+	fields := map[string]any{
+		"b": map[string]any{
+			"scalars": []any{"x", "y"},
+			"objects": []any{map[string]any{"k": "v"}},
+			"empty":   []any{},
+			"nil":     nil,
+		},
+		"a":       true,
+		"odd key": "value",
+	}
+
+	var got []siemField
+	flattenFields("", fields, func(key, value string) {
+		got = append(got, siemField{key: siemKey(key), value: value})
+	})
+
+	require.Equal(t, []siemField{
+		{key: "a", value: "true"},
+		{key: "b.objects.0.k", value: "v"},
+		{key: "b.scalars", value: "x,y"},
+		{key: "odd_key", value: "value"},
+	}, got)
+}
@@ -0,0 +1,407 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/hashicorp/eventlogger"
+	"github.com/hashicorp/go-bexpr"
+	"github.com/hashicorp/vault/helper/namespace"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+var _ eventlogger.Node = (*EntryRedactor)(nil)
+
+const (
+	// redactionRequestData and redactionResponseData are the fields of an audit
+	// entry which redaction rules can refer to.
+	redactionRequestData  = "request.data"
+	redactionResponseData = "response.data"
+
+	// redactionWildcard matches any key when used as part of a field path.
+	redactionWildcard = "*"
+)
+
+// RedactionRule describes fields which should be removed from audit entries.
+type RedactionRule struct {
+	// Filter is an optional expression, in the same format as the audit device
+	// 'filter' option, that selects the entries the rule applies to. When empty,
+	// the rule applies to every entry.
+	Filter string `json:"filter"`
+
+	// Drop lists fields which should be removed.
+	Drop []string `json:"drop"`
+
+	// Keep lists the only fields which should be retained, any other fields
+	// within request.data or response.data (as referenced by Keep) are removed.
+	Keep []string `json:"keep"`
+}
+
+// redactionRule is a parsed RedactionRule.
+type redactionRule struct {
+	evaluator *bexpr.Evaluator
+	drop      map[string]*fieldTree
+	keep      map[string]*fieldTree
+}
+
+// fieldTree holds the paths of fields within a map, one segment per level.
+type fieldTree struct {
+	// leaf indicates that a field path ends here.
+	leaf     bool
+	children map[string]*fieldTree
+}
+
+// EntryRedactor should be used to remove fields from audit requests and responses
+// before they are formatted, according to a set of rules.
+type EntryRedactor struct {
+	rules []*redactionRule
+}
+
+// NewEntryRedactor should be used to create an EntryRedactor node.
+// The rules should be a JSON array of RedactionRule objects.
+func NewEntryRedactor(rules string) (*EntryRedactor, error) {
+	rules = strings.TrimSpace(rules)
+	if rules == "" {
+		return nil, fmt.Errorf("cannot create new audit redactor with no rules: %w", ErrExternalOptions)
+	}
+
+	var raw []RedactionRule
+	if err := json.Unmarshal([]byte(rules), &raw); err != nil {
+		return nil, fmt.Errorf("unable to parse redaction rules: %w: %w", ErrExternalOptions, err)
+	}
+
+	if len(raw) == 0 {
+		return nil, fmt.Errorf("cannot create new audit redactor with no rules: %w", ErrExternalOptions)
+	}
+
+	r := &EntryRedactor{rules: make([]*redactionRule, 0, len(raw))}
+	for i, rr := range raw {
+		rule, err := newRedactionRule(rr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redaction rule %d: %w", i, err)
+		}
+		r.rules = append(r.rules, rule)
+	}
+
+	return r, nil
+}
+
+// newRedactionRule validates a RedactionRule and parses it ready for use.
+func newRedactionRule(rr RedactionRule) (*redactionRule, error) {
+	if len(rr.Drop) == 0 && len(rr.Keep) == 0 {
+		return nil, fmt.Errorf("at least one field to drop or keep is required: %w", ErrExternalOptions)
+	}
+
+	rule := &redactionRule{
+		drop: make(map[string]*fieldTree),
+		keep: make(map[string]*fieldTree),
+	}
+
+	if filter := strings.TrimSpace(rr.Filter); filter != "" {
+		eval, err := bexpr.CreateEvaluator(filter)
+		if err != nil {
+			return nil, fmt.Errorf("cannot create filter: %w: %w", ErrExternalOptions, err)
+		}
+
+		// Validate the filter in the same way as EntryFilter, so that we don't
+		// fail to audit requests due to a filter that can never be evaluated.
+		if _, err = eval.Evaluate(logical.LogInputBexpr{}); err != nil {
+			return nil, fmt.Errorf("filter references an unsupported field: %s: %w", filter, ErrExternalOptions)
+		}
+
+		rule.evaluator = eval
+	}
+
+	for _, field := range rr.Drop {
+		if err := addRedactionField(rule.drop, field, true); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, field := range rr.Keep {
+		if err := addRedactionField(rule.keep, field, false); err != nil {
+			return nil, err
+		}
+	}
+
+	return rule, nil
+}
+
+// addRedactionField adds the path of a field to the tree for the part of the
+// entry it refers to. When whole is true the field may refer to all of
+// request.data or response.data.
+func addRedactionField(trees map[string]*fieldTree, field string, whole bool) error {
+	field = strings.TrimSpace(field)
+
+	var root, rest string
+	for _, r := range []string{redactionRequestData, redactionResponseData} {
+		if field == r || strings.HasPrefix(field, r+".") {
+			root, rest = r, strings.TrimPrefix(strings.TrimPrefix(field, r), ".")
+			break
+		}
+	}
+
+	switch {
+	case root == "":
+		return fmt.Errorf("field %q must be within %q or %q: %w", field, redactionRequestData, redactionResponseData, ErrExternalOptions)
+	case rest == "" && !whole:
+		return fmt.Errorf("field %q must refer to a field within %q: %w", field, root, ErrExternalOptions)
+	}
+
+	tree, ok := trees[root]
+	if !ok {
+		tree = &fieldTree{}
+		trees[root] = tree
+	}
+
+	if rest == "" {
+		tree.leaf = true
+		return nil
+	}
+
+	for _, segment := range strings.Split(rest, ".") {
+		if segment == "" {
+			return fmt.Errorf("field %q contains an empty segment: %w", field, ErrExternalOptions)
+		}
+		if tree.children == nil {
+			tree.children = make(map[string]*fieldTree)
+		}
+		child, ok := tree.children[segment]
+		if !ok {
+			child = &fieldTree{}
+			tree.children[segment] = child
+		}
+		tree = child
+	}
+	tree.leaf = true
+
+	return nil
+}
+
+// Reopen is a no-op for the redactor node.
+func (*EntryRedactor) Reopen() error {
+	return nil
+}
+
+// Type describes the type of this node. The redactor transforms events rather
+// than deciding which events remain in the pipeline, so it is not a filter.
+func (*EntryRedactor) Type() eventlogger.NodeType {
+	return eventlogger.NodeTypeFormatter
+}
+
+// Process applies any rules which match the event to a copy of the event data,
+// the original event is left unmodified as it may be shared by other pipelines.
+func (r *EntryRedactor) Process(ctx context.Context, e *eventlogger.Event) (*eventlogger.Event, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
+	}
+
+	if e == nil {
+		return nil, fmt.Errorf("event is nil: %w", ErrInvalidParameter)
+	}
+
+	a, ok := e.Payload.(*AuditEvent)
+	if !ok {
+		return nil, fmt.Errorf("cannot parse event payload: %w", ErrInvalidParameter)
+	}
+
+	// If we don't have data to process, then the formatter will deal with it.
+	if a.Data == nil {
+		return e, nil
+	}
+
+	var matched []*redactionRule
+	var datum *logical.LogInputBexpr
+	for _, rule := range r.rules {
+		if rule.evaluator != nil {
+			if datum == nil {
+				ns, err := namespace.FromContext(ctx)
+				if err != nil {
+					return nil, fmt.Errorf("cannot obtain namespace: %w", err)
+				}
+				datum = a.Data.BexprDatum(ns.Path)
+			}
+
+			result, err := rule.evaluator.Evaluate(datum)
+			if err != nil {
+				return nil, fmt.Errorf("unable to evaluate redaction rule filter: %w", err)
+			}
+			if !result {
+				continue
+			}
+		}
+		matched = append(matched, rule)
+	}
+
+	if len(matched) == 0 {
+		return e, nil
+	}
+
+	data, err := a.Data.Clone()
+	if err != nil {
+		return nil, fmt.Errorf("unable to clone audit event data: %w", err)
+	}
+
+	for _, rule := range matched {
+		if data.Request != nil {
+			data.Request.Data = rule.apply(redactionRequestData, data.Request.Data)
+		}
+		if data.Response != nil {
+			data.Response.Data = rule.apply(redactionResponseData, data.Response.Data)
+		}
+	}
+
+	a2 := &AuditEvent{
+		ID:        a.ID,
+		Version:   a.Version,
+		Subtype:   a.Subtype,
+		Timestamp: a.Timestamp,
+		Data:      data,
+	}
+
+	return &eventlogger.Event{
+		Type:      e.Type,
+		CreatedAt: e.CreatedAt,
+		Formatted: make(map[string][]byte),
+		Payload:   a2,
+	}, nil
+}
+
+// apply removes fields from the data for the specified part of the entry.
+func (r *redactionRule) apply(root string, data map[string]any) map[string]any {
+	if data == nil {
+		return nil
+	}
+
+	if keep, ok := r.keep[root]; ok {
+		keepFields(data, keep)
+	}
+
+	if drop, ok := r.drop[root]; ok {
+		if drop.leaf {
+			return nil
+		}
+		dropFields(data, drop)
+	}
+
+	return data
+}
+
+// matching returns the trees within the children of t which match key.
+func (t *fieldTree) matching(key string) []*fieldTree {
+	var result []*fieldTree
+	if child, ok := t.children[key]; ok {
+		result = append(result, child)
+	}
+	if child, ok := t.children[redactionWildcard]; ok && key != redactionWildcard {
+		result = append(result, child)
+	}
+
+	return result
+}
+
+// dropFields removes the fields in t from data.
+func dropFields(data map[string]any, t *fieldTree) {
+	for k, v := range data {
+		for _, child := range t.matching(k) {
+			if child.leaf {
+				delete(data, k)
+				break
+			}
+			if nested, ok := redactableMap(v); ok {
+				dropFields(nested, child)
+				data[k] = nested
+			}
+		}
+	}
+}
+
+// keepFields removes any fields from data which are not in t, or on the path to
+// a field in t.
+func keepFields(data map[string]any, t *fieldTree) {
+	for k, v := range data {
+		matches := t.matching(k)
+		if len(matches) == 0 {
+			delete(data, k)
+			continue
+		}
+
+		// When more than one path matches, nested fields are kept if any path keeps them.
+		var merged *fieldTree
+		for _, child := range matches {
+			merged = mergeFieldTrees(merged, child)
+		}
+		if merged.leaf {
+			continue
+		}
+
+		nested, ok := redactableMap(v)
+		if !ok {
+			// A field within this value should be kept, but it doesn't have fields.
+			delete(data, k)
+			continue
+		}
+
+		keepFields(nested, merged)
+		data[k] = nested
+	}
+}
+
+// redactableMap returns v as a map which fields can be removed from. Typed
+// values, such as structs (e.g. logical.Auth) or maps with other value types,
+// are converted using their JSON representation, which is how their fields are
+// named in the audit entry.
+func redactableMap(v any) (map[string]any, bool) {
+	switch v := v.(type) {
+	case nil:
+		return nil, false
+	case map[string]any:
+		return v, true
+	}
+
+	switch reflect.Indirect(reflect.ValueOf(v)).Kind() {
+	case reflect.Struct, reflect.Map:
+	default:
+		return nil, false
+	}
+
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, false
+	}
+
+	var m map[string]any
+	if err := json.Unmarshal(b, &m); err != nil || m == nil {
+		return nil, false
+	}
+
+	return m, true
+}
+
+// mergeFieldTrees combines two trees, a nil tree is treated as empty.
+func mergeFieldTrees(a, b *fieldTree) *fieldTree {
+	if a == nil {
+		return b
+	}
+	if b == nil {
+		return a
+	}
+
+	merged := &fieldTree{leaf: a.leaf || b.leaf, children: make(map[string]*fieldTree)}
+	for k, v := range a.children {
+		merged.children[k] = v
+	}
+	for k, v := range b.children {
+		merged.children[k] = mergeFieldTrees(merged.children[k], v)
+	}
+
+	return merged
+}
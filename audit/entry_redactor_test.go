@@ -0,0 +1,281 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package audit
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/eventlogger"
+	"github.com/hashicorp/vault/helper/namespace"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/require"
+)
+
+// TestEntryRedactor_NewEntryRedactor tests that we can create EntryRedactor types
+// correctly, and that invalid rules are rejected.
+func TestEntryRedactor_NewEntryRedactor(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		Rules                string
+		IsErrorExpected      bool
+		ExpectedErrorMessage string
+	}{
+		"empty": {
+			Rules:                "  ",
+			IsErrorExpected:      true,
+			ExpectedErrorMessage: "cannot create new audit redactor with no rules: invalid configuration",
+		},
+		"empty-array": {
+			Rules:                "[]",
+			IsErrorExpected:      true,
+			ExpectedErrorMessage: "cannot create new audit redactor with no rules: invalid configuration",
+		},
+		"not-json": {
+			Rules:                "drop everything",
+			IsErrorExpected:      true,
+			ExpectedErrorMessage: "unable to parse redaction rules: invalid configuration",
+		},
+		"no-fields": {
+			Rules:                `[{"filter": "mount_type == pki"}]`,
+			IsErrorExpected:      true,
+			ExpectedErrorMessage: "invalid redaction rule 0: at least one field to drop or keep is required: invalid configuration",
+		},
+		"bad-filter": {
+			Rules:                `[{"filter": "foo == bar", "drop": ["response.data.foo"]}]`,
+			IsErrorExpected:      true,
+			ExpectedErrorMessage: "invalid redaction rule 0: filter references an unsupported field: foo == bar: invalid configuration",
+		},
+		"bad-field": {
+			Rules:                `[{"drop": ["response.data.foo"]}, {"drop": ["auth.policies"]}]`,
+			IsErrorExpected:      true,
+			ExpectedErrorMessage: "invalid redaction rule 1: field \"auth.policies\" must be within \"request.data\" or \"response.data\": invalid configuration",
+		},
+		"empty-segment": {
+			Rules:                `[{"drop": ["response.data..foo"]}]`,
+			IsErrorExpected:      true,
+			ExpectedErrorMessage: "invalid redaction rule 0: field \"response.data..foo\" contains an empty segment: invalid configuration",
+		},
+		"keep-whole": {
+			Rules:                `[{"keep": ["response.data"]}]`,
+			IsErrorExpected:      true,
+			ExpectedErrorMessage: "invalid redaction rule 0: field \"response.data\" must refer to a field within \"response.data\": invalid configuration",
+		},
+		"drop-whole": {
+			Rules: `[{"drop": ["request.data"]}]`,
+		},
+		"good": {
+			Rules: `[{"filter": "mount_type == pki", "drop": ["response.data.ca_chain"], "keep": ["request.data.common_name"]}]`,
+		},
+	}
+
+	for name, tc := range tests {
+		name := name
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			r, err := NewEntryRedactor(tc.Rules)
+			switch {
+			case tc.IsErrorExpected:
+				require.Error(t, err)
+				require.ErrorContains(t, err, tc.ExpectedErrorMessage)
+				require.Nil(t, r)
+			default:
+				require.NoError(t, err)
+				require.NotNil(t, r)
+			}
+		})
+	}
+}
+
+// TestEntryRedactor_Type ensures we always return the right type for this node.
+func TestEntryRedactor_Type(t *testing.T) {
+	t.Parallel()
+
+	r := &EntryRedactor{}
+	require.Equal(t, eventlogger.NodeTypeFormatter, r.Type())
+}
+
+// TestEntryRedactor_Process ensures that matching rules drop, or keep, the
+// configured fields and leave the original event untouched.
+func TestEntryRedactor_Process(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		Rules            string
+		ExpectedRequest  map[string]any
+		ExpectedResponse map[string]any
+	}{
+		"no-match": {
+			Rules: `[{"filter": "mount_type == transit", "drop": ["request.data.common_name"]}]`,
+			ExpectedRequest: map[string]any{
+				"common_name": "example.com",
+				"ttl":         "1h",
+			},
+			ExpectedResponse: map[string]any{
+				"certificate": "cert",
+				"ca_chain":    []string{"a", "b"},
+				"nested":      map[string]any{"a": "1", "b": "2"},
+			},
+		},
+		"drop": {
+			Rules: `[{"filter": "mount_type == pki", "drop": ["response.data.ca_chain", "response.data.nested.a", "request.data.missing"]}]`,
+			ExpectedRequest: map[string]any{
+				"common_name": "example.com",
+				"ttl":         "1h",
+			},
+			ExpectedResponse: map[string]any{
+				"certificate": "cert",
+				"nested":      map[string]any{"b": "2"},
+			},
+		},
+		"drop-whole": {
+			Rules: `[{"drop": ["response.data"]}]`,
+			ExpectedRequest: map[string]any{
+				"common_name": "example.com",
+				"ttl":         "1h",
+			},
+		},
+		"drop-wildcard": {
+			Rules: `[{"drop": ["response.data.*.a"]}]`,
+			ExpectedRequest: map[string]any{
+				"common_name": "example.com",
+				"ttl":         "1h",
+			},
+			ExpectedResponse: map[string]any{
+				"certificate": "cert",
+				"ca_chain":    []string{"a", "b"},
+				"nested":      map[string]any{"b": "2"},
+			},
+		},
+		"keep": {
+			Rules: `[{"keep": ["response.data.certificate", "response.data.nested.b", "response.data.ca_chain.0"]}]`,
+			ExpectedRequest: map[string]any{
+				"common_name": "example.com",
+				"ttl":         "1h",
+			},
+			ExpectedResponse: map[string]any{
+				"certificate": "cert",
+				"nested":      map[string]any{"b": "2"},
+			},
+		},
+		"keep-and-drop": {
+			Rules: `[{"keep": ["request.data.common_name"]}, {"filter": "path matches \"pki/.*\"", "drop": ["response.data.certificate"]}]`,
+			ExpectedRequest: map[string]any{
+				"common_name": "example.com",
+			},
+			ExpectedResponse: map[string]any{
+				"ca_chain": []string{"a", "b"},
+				"nested":   map[string]any{"a": "1", "b": "2"},
+			},
+		},
+	}
+
+	for name, tc := range tests {
+		name := name
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			r, err := NewEntryRedactor(tc.Rules)
+			require.NoError(t, err)
+
+			in := &logical.LogInput{
+				Request: &logical.Request{
+					MountType: "pki",
+					Path:      "pki/issue/example",
+					Data: map[string]any{
+						"common_name": "example.com",
+						"ttl":         "1h",
+					},
+				},
+				Response: &logical.Response{
+					Data: map[string]any{
+						"certificate": "cert",
+						"ca_chain":    []string{"a", "b"},
+						"nested":      map[string]any{"a": "1", "b": "2"},
+					},
+				},
+			}
+			e := fakeEvent(t, ResponseType, in)
+
+			processed, err := r.Process(namespace.RootContext(context.Background()), e)
+			require.NoError(t, err)
+			require.NotNil(t, processed)
+
+			a, ok := processed.Payload.(*AuditEvent)
+			require.True(t, ok)
+			require.Equal(t, tc.ExpectedRequest, a.Data.Request.Data)
+			require.Equal(t, tc.ExpectedResponse, a.Data.Response.Data)
+
+			// The original event data must not be modified.
+			require.Len(t, in.Request.Data, 2)
+			require.Len(t, in.Response.Data, 3)
+			require.Len(t, in.Response.Data["nested"], 2)
+		})
+	}
+}
+
+// TestEntryRedactor_Process_Typed ensures that fields are removed from typed
+// values within the data, such as structs and maps which aren't map[string]any,
+// using the names they have in the audit entry.
+func TestEntryRedactor_Process_Typed(t *testing.T) {
+	t.Parallel()
+
+	r, err := NewEntryRedactor(`[{"drop": ["response.data.auth.client_token", "response.data.labels.secret"]}, {"keep": ["response.data.auth.policies", "response.data.auth.metadata.role", "response.data.labels"]}]`)
+	require.NoError(t, err)
+
+	auth := &logical.Auth{
+		ClientToken: "hvs.secret",
+		Accessor:    "accessor",
+		Policies:    []string{"default"},
+		Metadata:    map[string]string{"role": "admin", "user": "bob"},
+	}
+	labels := map[string]string{"secret": "s3cr3t", "team": "a"}
+	in := &logical.LogInput{
+		Request: &logical.Request{},
+		Response: &logical.Response{
+			Data: map[string]any{
+				"auth":   auth,
+				"labels": labels,
+				"ttl":    60,
+			},
+		},
+	}
+	e := fakeEvent(t, ResponseType, in)
+
+	processed, err := r.Process(namespace.RootContext(context.Background()), e)
+	require.NoError(t, err)
+
+	a, ok := processed.Payload.(*AuditEvent)
+	require.True(t, ok)
+	require.Equal(t, map[string]any{
+		"auth": map[string]any{
+			"policies": []any{"default"},
+			"metadata": map[string]any{"role": "admin"},
+		},
+		"labels": map[string]any{"team": "a"},
+	}, a.Data.Response.Data)
+
+	// The original typed values must not be modified.
+	require.Equal(t, "hvs.secret", auth.ClientToken)
+	require.Len(t, auth.Metadata, 2)
+	require.Len(t, labels, 2)
+}
+
+// TestEntryRedactor_Process_NoNamespace ensures that we return an error when
+// a rule needs the namespace to evaluate its filter, and there isn't one.
+func TestEntryRedactor_Process_NoNamespace(t *testing.T) {
+	t.Parallel()
+
+	r, err := NewEntryRedactor(`[{"filter": "namespace == foo", "drop": ["request.data.foo"]}]`)
+	require.NoError(t, err)
+
+	e := fakeEvent(t, RequestType, &logical.LogInput{Request: &logical.Request{}})
+	processed, err := r.Process(context.Background(), e)
+	require.EqualError(t, err, "cannot obtain namespace: no namespace")
+	require.Nil(t, processed)
+}
@@ -25,6 +25,9 @@ const (
 const (
 	JSONFormat  format = "json"
 	JSONxFormat format = "jsonx"
+	CEFFormat   format = "cef"
+	LEEFFormat  format = "leef"
+	OTLPFormat  format = "otlp"
 )
 
 // Check AuditEvent implements the timeProvider at compile time.
@@ -116,7 +119,7 @@ func (t subtype) validate() error {
 // validate ensures that format is one of the set of allowed event formats.
 func (f format) validate() error {
 	switch f {
-	case JSONFormat, JSONxFormat:
+	case JSONFormat, JSONxFormat, CEFFormat, LEEFFormat, OTLPFormat:
 		return nil
 	default:
 		return fmt.Errorf("invalid format %q: %w", f, ErrInvalidParameter)
@@ -0,0 +1,507 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+const (
+	// hashChainStoragePath is the path, within the storage of the audit device,
+	// where the head of the chain is checkpointed so that it resumes after Vault
+	// is restarted or the device is reloaded.
+	hashChainStoragePath = "hash_chain_head"
+
+	// hashChainCheckpointEntries and hashChainCheckpointInterval bound the
+	// number of entries, and the time, between checkpoints of the head of
+	// the chain.
+	hashChainCheckpointEntries  = 1000
+	hashChainCheckpointInterval = 30 * time.Second
+
+	// hashChainResumeWindow is the number of entries, before the most recent
+	// one, from which a chain may resume when verifying it. Entries written
+	// after the last checkpoint are superseded when Vault stops unexpectedly.
+	hashChainResumeWindow = 64 * hashChainCheckpointEntries
+
+	// hashChainTestMessagePath is the request path of the test message written
+	// when an audit device is enabled, which is not part of the chain.
+	hashChainTestMessagePath = "sys/audit/test"
+
+	// hashChainWindow is the maximum number of entries held while waiting for
+	// an entry which may have been written out of order.
+	hashChainWindow = 4096
+)
+
+// Kinds of issue reported when verifying a hash chain.
+const (
+	// HashChainIssueGap indicates that one or more entries are missing.
+	HashChainIssueGap HashChainIssueKind = "gap"
+
+	// HashChainIssueModified indicates that an entry no longer matches the
+	// hash recorded by the entry which follows it.
+	HashChainIssueModified HashChainIssueKind = "modified"
+
+	// HashChainIssueDuplicate indicates that a sequence number appears more than once.
+	HashChainIssueDuplicate HashChainIssueKind = "duplicate"
+
+	// HashChainIssueReordered indicates that an entry appears after an entry
+	// with a higher sequence number. Entries for concurrent requests may be
+	// written slightly out of order, so this is informational.
+	HashChainIssueReordered HashChainIssueKind = "reordered"
+
+	// HashChainIssueInvalid indicates that a line could not be parsed, or
+	// is not part of a hash chain.
+	HashChainIssueInvalid HashChainIssueKind = "invalid"
+
+	// HashChainIssueResumed indicates that the chain resumed from an earlier
+	// entry, superseding the entries which followed it, as happens when Vault
+	// stops before checkpointing the head of the chain. This is informational.
+	HashChainIssueResumed HashChainIssueKind = "resumed"
+)
+
+// HashChainMAC computes the keyed hash of an audit entry, as recorded by the
+// entry which follows it. It must produce the same result as the audit device's
+// salt, e.g. by using the sys/audit-hash endpoint.
+type HashChainMAC func(entry []byte) (string, error)
+
+// hashChain links the entries formatted by an EntryFormatter together by giving
+// each entry a sequence number and the HMAC of the previous entry, keyed using
+// the audit device's salt so that the chain can't be recomputed by anyone who is
+// able to modify the log.
+type hashChain struct {
+	lock   sync.Mutex
+	salter Salter
+	logger hclog.Logger
+
+	// storage is used to checkpoint the head of the chain, when it is nil the
+	// chain only exists in memory.
+	storage logical.Storage
+	loaded  bool
+
+	sequence uint64
+	lastHash string
+
+	// unpersisted is the number of entries linked since the last checkpoint,
+	// which was taken at checkpointed.
+	unpersisted  int
+	checkpointed time.Time
+}
+
+// hashChainHead is the persisted state of a hash chain.
+type hashChainHead struct {
+	Sequence uint64 `json:"sequence"`
+	LastHash string `json:"last_hash"`
+}
+
+// chainLink is the part of an audit entry used to link it to the previous entry.
+type chainLink struct {
+	Sequence uint64 `json:"sequence"`
+	PrevHash string `json:"prev_hash"`
+	Request  *struct {
+		Path string `json:"path"`
+	} `json:"request"`
+}
+
+// link obtains the next sequence number and the HMAC of the previous entry,
+// supplies them to encode and records the HMAC of the result as the new head of
+// the chain. The lock is held while encoding so that sequence numbers and hashes
+// are assigned in the same order. The head is kept in memory and checkpointed
+// periodically, a failed checkpoint is logged rather than failing the entry.
+func (c *hashChain) link(ctx context.Context, encode func(sequence uint64, prevHash string) ([]byte, error)) ([]byte, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if !c.loaded {
+		if err := c.load(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	result, err := encode(c.sequence+1, c.lastHash)
+	if err != nil {
+		return nil, err
+	}
+
+	s, err := c.salter.Salt(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get salt to link entry: %w", err)
+	}
+
+	c.sequence++
+	c.lastHash = hashChainEntry(s.GetIdentifiedHMAC, result)
+
+	if c.storage != nil {
+		c.unpersisted++
+		if c.unpersisted >= hashChainCheckpointEntries || time.Since(c.checkpointed) >= hashChainCheckpointInterval {
+			if err := c.checkpoint(ctx); err != nil {
+				// Try again once another interval has passed.
+				c.logger.Warn("unable to checkpoint hash chain head", "sequence", c.sequence, "error", err)
+				c.unpersisted = 0
+				c.checkpointed = time.Now()
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// close checkpoints the head of the chain, if any entries were linked since
+// the last checkpoint.
+func (c *hashChain) close(ctx context.Context) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.storage == nil || c.unpersisted == 0 {
+		return nil
+	}
+
+	return c.checkpoint(ctx)
+}
+
+// checkpoint persists the head of the chain.
+// It relies on the caller holding the lock.
+func (c *hashChain) checkpoint(ctx context.Context) error {
+	if err := c.persist(ctx, hashChainHead{Sequence: c.sequence, LastHash: c.lastHash}); err != nil {
+		return err
+	}
+
+	c.unpersisted = 0
+	c.checkpointed = time.Now()
+
+	return nil
+}
+
+// load resumes the chain from the head persisted in storage. When the head
+// can't be written back (e.g. on a node which can't write to storage), a new
+// chain is started which only exists in memory.
+// It relies on the caller holding the lock.
+func (c *hashChain) load(ctx context.Context) error {
+	if c.storage == nil {
+		c.loaded = true
+		return nil
+	}
+
+	entry, err := c.storage.Get(ctx, hashChainStoragePath)
+	if err != nil {
+		return fmt.Errorf("unable to read hash chain head: %w", err)
+	}
+
+	var head hashChainHead
+	if entry != nil {
+		if err := entry.DecodeJSON(&head); err != nil {
+			return fmt.Errorf("unable to decode hash chain head: %w", err)
+		}
+	}
+
+	err = c.persist(ctx, head)
+	switch {
+	case err != nil && strings.Contains(err.Error(), logical.ErrReadOnly.Error()):
+		c.storage = nil
+		head = hashChainHead{}
+	case err != nil:
+		return err
+	}
+
+	c.sequence = head.Sequence
+	c.lastHash = head.LastHash
+	c.checkpointed = time.Now()
+	c.loaded = true
+
+	return nil
+}
+
+// persist writes the head of the chain to storage.
+func (c *hashChain) persist(ctx context.Context, head hashChainHead) error {
+	entry, err := logical.StorageEntryJSON(hashChainStoragePath, head)
+	if err != nil {
+		return fmt.Errorf("unable to encode hash chain head: %w", err)
+	}
+
+	if err := c.storage.Put(ctx, entry); err != nil {
+		return fmt.Errorf("unable to persist hash chain head: %w", err)
+	}
+
+	return nil
+}
+
+// hashChainEntry returns the keyed hash of a formatted entry, ignoring any
+// trailing new line so the hash matches the line read back from a log.
+func hashChainEntry(mac func(string) string, entry []byte) string {
+	return mac(string(bytes.TrimRight(entry, "\r\n")))
+}
+
+// HashChainIssueKind describes the kind of problem found when verifying a hash chain.
+type HashChainIssueKind string
+
+// HashChainIssue describes a problem found when verifying a hash chain.
+type HashChainIssue struct {
+	Kind     HashChainIssueKind `json:"kind"`
+	Line     int                `json:"line,omitempty"`
+	Sequence uint64             `json:"sequence,omitempty"`
+	Detail   string             `json:"detail"`
+}
+
+// HashChainReport is the result of verifying the hash chain(s) in an audit log.
+type HashChainReport struct {
+	// Entries is the number of entries which were read.
+	Entries int `json:"entries"`
+
+	// Chains is the number of chains found, a new chain starts each time an
+	// audit device is enabled. Nodes which can't persist the head of the chain
+	// also start a new chain each time they are unsealed.
+	Chains int `json:"chains"`
+
+	// Issues contains the problems which were found, in the order they were found.
+	Issues []HashChainIssue `json:"issues,omitempty"`
+}
+
+// Valid returns true when no issues (other than reordering or resuming) were found.
+func (r *HashChainReport) Valid() bool {
+	for _, i := range r.Issues {
+		if i.Kind != HashChainIssueReordered && i.Kind != HashChainIssueResumed {
+			return false
+		}
+	}
+
+	return true
+}
+
+// pendingLink is an entry which has been read but not yet verified against
+// the entry which precedes it.
+type pendingLink struct {
+	line     int
+	hash     string
+	prevHash string
+}
+
+// hashChainVerifier verifies entries, in the order they were read from a log.
+type hashChainVerifier struct {
+	report *HashChainReport
+	mac    HashChainMAC
+
+	// expected is the sequence number of the next entry to verify.
+	expected uint64
+
+	// lastHash and lastLine describe the most recently verified entry.
+	lastHash string
+	lastLine int
+
+	// highest is the highest sequence number seen in the current chain.
+	highest uint64
+
+	// pending holds entries which were read before the entry preceding them.
+	pending map[uint64]pendingLink
+
+	// hashes holds the hashes of the most recently verified entries, by
+	// sequence number, from which the chain may resume.
+	hashes map[uint64]string
+}
+
+// VerifyHashChain reads audit entries, one per line, and verifies that they form
+// unbroken hash chains, using mac to compute the keyed hash of each entry. Any
+// prefix configured on the audit device should be supplied so that it can be
+// removed before each entry is parsed.
+func VerifyHashChain(r io.Reader, prefix string, mac HashChainMAC) (*HashChainReport, error) {
+	if mac == nil {
+		return nil, fmt.Errorf("mac is required: %w", ErrInvalidParameter)
+	}
+
+	v := &hashChainVerifier{
+		report:  &HashChainReport{},
+		mac:     mac,
+		pending: make(map[uint64]pendingLink),
+		hashes:  make(map[uint64]string),
+	}
+
+	reader := bufio.NewReader(r)
+	for lineNum := 1; ; lineNum++ {
+		line, err := reader.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			if addErr := v.add(lineNum, line, prefix); addErr != nil {
+				return nil, fmt.Errorf("unable to verify line %d: %w", lineNum, addErr)
+			}
+		}
+
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("unable to read line %d: %w", lineNum, err)
+		}
+	}
+
+	v.finish()
+
+	return v.report, nil
+}
+
+// add parses a single line and verifies it against the chain.
+func (v *hashChainVerifier) add(lineNum int, line []byte, prefix string) error {
+	v.report.Entries++
+
+	entry := bytes.TrimRight(line, "\r\n")
+	if !bytes.HasPrefix(entry, []byte(prefix)) {
+		v.issue(HashChainIssueInvalid, lineNum, 0, fmt.Sprintf("line does not start with the prefix %q", prefix))
+		return nil
+	}
+
+	var link chainLink
+	if err := json.Unmarshal(entry[len(prefix):], &link); err != nil {
+		v.issue(HashChainIssueInvalid, lineNum, 0, fmt.Sprintf("unable to parse entry: %s", err))
+		return nil
+	}
+
+	if link.Sequence == 0 {
+		// The test message written when the device is enabled is hashed
+		// using a temporary salt, so it isn't part of the chain.
+		if link.Request != nil && link.Request.Path == hashChainTestMessagePath {
+			return nil
+		}
+
+		v.issue(HashChainIssueInvalid, lineNum, 0, "entry has no sequence number")
+		return nil
+	}
+
+	hash, err := v.mac(entry)
+	if err != nil {
+		return err
+	}
+
+	// The first entry of a chain has no previous hash; anything we haven't
+	// yet verified belongs to the chain which came before it.
+	if link.Sequence == 1 && link.PrevHash == "" {
+		v.finish()
+		v.report.Chains++
+		v.expected = 1
+		v.highest = 0
+		v.lastHash = ""
+		v.lastLine = 0
+		v.hashes = make(map[uint64]string)
+	}
+
+	if v.report.Chains == 0 {
+		// The log starts part way through a chain, e.g. after rotation.
+		v.report.Chains++
+		v.expected = link.Sequence
+		v.lastHash = link.PrevHash
+	}
+
+	// An earlier entry which follows on from the entry before it, but differs
+	// from the entry already verified with its sequence number, is where the
+	// chain resumed from its last checkpoint.
+	if prev, ok := v.hashes[link.Sequence-1]; ok && link.Sequence < v.expected && link.PrevHash == prev && v.hashes[link.Sequence] != hash {
+		v.finish()
+		v.issue(HashChainIssueResumed, lineNum, link.Sequence, fmt.Sprintf("chain resumed from sequence %d, superseding sequences %d to %d", link.Sequence-1, link.Sequence, v.expected-1))
+		for seq := link.Sequence; seq < v.expected; seq++ {
+			delete(v.hashes, seq)
+		}
+		v.expected = link.Sequence
+		v.highest = link.Sequence - 1
+		v.lastHash = prev
+		v.lastLine = 0
+	}
+
+	switch _, exists := v.pending[link.Sequence]; {
+	case link.Sequence < v.expected, exists:
+		v.issue(HashChainIssueDuplicate, lineNum, link.Sequence, "sequence number has already been seen")
+		return nil
+	case link.Sequence < v.highest:
+		v.issue(HashChainIssueReordered, lineNum, link.Sequence, fmt.Sprintf("entry appears after sequence %d", v.highest))
+	default:
+		v.highest = link.Sequence
+	}
+
+	v.pending[link.Sequence] = pendingLink{
+		line:     lineNum,
+		hash:     hash,
+		prevHash: link.PrevHash,
+	}
+
+	v.resolve()
+
+	// Entries are only written slightly out of order, so once we're holding
+	// too many entries waiting for an earlier one, it isn't coming.
+	for len(v.pending) > hashChainWindow {
+		v.skipGap()
+	}
+
+	return nil
+}
+
+// resolve verifies pending entries for as long as the next expected entry is available.
+func (v *hashChainVerifier) resolve() {
+	for {
+		p, ok := v.pending[v.expected]
+		if !ok {
+			return
+		}
+
+		if p.prevHash != v.lastHash {
+			detail := fmt.Sprintf("entry at line %d does not match the hash recorded by sequence %d at line %d", v.lastLine, v.expected, p.line)
+			if v.lastLine == 0 {
+				detail = fmt.Sprintf("hash of the previous entry recorded at line %d does not match", p.line)
+			}
+			v.issue(HashChainIssueModified, v.lastLine, v.expected-1, detail)
+		}
+
+		delete(v.pending, v.expected)
+		v.hashes[v.expected] = p.hash
+		delete(v.hashes, v.expected-hashChainResumeWindow)
+		v.lastHash = p.hash
+		v.lastLine = p.line
+		v.expected++
+	}
+}
+
+// finish reports any gaps in the current chain and verifies what remains.
+func (v *hashChainVerifier) finish() {
+	for len(v.pending) > 0 {
+		v.skipGap()
+	}
+}
+
+// skipGap reports the gap between the next expected entry and the lowest
+// pending entry, then carries on verifying from that entry.
+func (v *hashChainVerifier) skipGap() {
+	var next uint64
+	for s := range v.pending {
+		if next == 0 || s < next {
+			next = s
+		}
+	}
+
+	detail := fmt.Sprintf("sequence %d is missing", v.expected)
+	if next-v.expected > 1 {
+		detail = fmt.Sprintf("sequences %d to %d are missing", v.expected, next-1)
+	}
+	v.issue(HashChainIssueGap, v.pending[next].line, v.expected, detail)
+
+	// We can't verify the first entry after a gap against its predecessor,
+	// so carry on from that entry.
+	v.expected = next
+	v.lastHash = v.pending[next].prevHash
+	v.lastLine = 0
+	v.resolve()
+}
+
+func (v *hashChainVerifier) issue(kind HashChainIssueKind, line int, seq uint64, detail string) {
+	v.report.Issues = append(v.report.Issues, HashChainIssue{
+		Kind:     kind,
+		Line:     line,
+		Sequence: seq,
+		Detail:   detail,
+	})
+}
@@ -0,0 +1,370 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/vault/helper/namespace"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/require"
+)
+
+// testHashChainMAC returns a HashChainMAC which uses the supplied salt, as
+// the sys/audit-hash endpoint would for an audit device.
+func testHashChainMAC(s *staticSalt) HashChainMAC {
+	return func(entry []byte) (string, error) {
+		return s.salt.GetIdentifiedHMAC(string(entry)), nil
+	}
+}
+
+// testHashChainLog returns the lines of a log containing a hash chain with
+// the specified number of entries.
+func testHashChainLog(t *testing.T, s *staticSalt, prefix string, n int) []string {
+	t.Helper()
+
+	return testHashChainLink(t, &hashChain{salter: s}, prefix, n)
+}
+
+// testHashChainLink links the specified number of entries using the chain,
+// returning the lines which would be written to a log.
+func testHashChainLink(t *testing.T, c *hashChain, prefix string, n int) []string {
+	t.Helper()
+
+	lines := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		line, err := c.link(context.Background(), func(sequence uint64, prevHash string) ([]byte, error) {
+			b, err := json.Marshal(&RequestEntry{
+				Type:     "request",
+				Error:    fmt.Sprintf("entry %d", i),
+				Sequence: sequence,
+				PrevHash: prevHash,
+			})
+			if err != nil {
+				return nil, err
+			}
+			return append([]byte(prefix), append(b, '\n')...), nil
+		})
+		require.NoError(t, err)
+		lines = append(lines, string(line))
+	}
+
+	return lines
+}
+
+// TestHashChain_link ensures that each link records the sequence number and
+// the HMAC of the previous entry.
+func TestHashChain_link(t *testing.T) {
+	t.Parallel()
+
+	s := newStaticSalt(t)
+	lines := testHashChainLog(t, s, "", 3)
+
+	var prev string
+	for i, line := range lines {
+		var link chainLink
+		require.NoError(t, json.Unmarshal([]byte(line), &link))
+		require.Equal(t, uint64(i+1), link.Sequence)
+		require.Equal(t, prev, link.PrevHash)
+		prev = hashChainEntry(s.salt.GetIdentifiedHMAC, []byte(line))
+		require.True(t, strings.HasPrefix(prev, "hmac-sha256:"))
+	}
+}
+
+// TestHashChain_resume ensures that a chain resumes from the head checkpointed
+// when the device was closed, so that entries removed from the end of a log
+// before the device is recreated are detected.
+func TestHashChain_resume(t *testing.T) {
+	t.Parallel()
+
+	s := newStaticSalt(t)
+	storage := &logical.InmemStorage{}
+
+	c := &hashChain{salter: s, logger: hclog.NewNullLogger(), storage: storage}
+	lines := testHashChainLink(t, c, "", 3)
+	require.NoError(t, c.close(context.Background()))
+	resumed := testHashChainLink(t, &hashChain{salter: s, logger: hclog.NewNullLogger(), storage: storage}, "", 2)
+
+	var link chainLink
+	require.NoError(t, json.Unmarshal([]byte(resumed[0]), &link))
+	require.Equal(t, uint64(4), link.Sequence)
+
+	report, err := VerifyHashChain(strings.NewReader(strings.Join(append(lines, resumed...), "")), "", testHashChainMAC(s))
+	require.NoError(t, err)
+	require.Equal(t, 1, report.Chains)
+	require.Empty(t, report.Issues)
+
+	// Truncating the log before the device is recreated leaves a gap.
+	report, err = VerifyHashChain(strings.NewReader(strings.Join(append(lines[:2], resumed...), "")), "", testHashChainMAC(s))
+	require.NoError(t, err)
+	require.Equal(t, []HashChainIssue{
+		{Kind: HashChainIssueGap, Line: 3, Sequence: 3, Detail: "sequence 3 is missing"},
+	}, report.Issues)
+}
+
+// TestHashChain_checkpoint ensures that the head of the chain is only written
+// to storage periodically, and that a chain which resumes from an earlier
+// checkpoint, as it does when Vault stops unexpectedly, can be verified.
+func TestHashChain_checkpoint(t *testing.T) {
+	t.Parallel()
+
+	s := newStaticSalt(t)
+	storage := &logical.InmemStorage{}
+
+	c := &hashChain{salter: s, logger: hclog.NewNullLogger(), storage: storage}
+	lines := testHashChainLink(t, c, "", hashChainCheckpointEntries+2)
+
+	var head hashChainHead
+	entry, err := storage.Get(context.Background(), hashChainStoragePath)
+	require.NoError(t, err)
+	require.NoError(t, entry.DecodeJSON(&head))
+	require.Equal(t, uint64(hashChainCheckpointEntries), head.Sequence)
+
+	// The device isn't closed, so the chain resumes from the checkpoint.
+	resumed := testHashChainLink(t, &hashChain{salter: s, logger: hclog.NewNullLogger(), storage: storage}, "", 2)
+
+	var link chainLink
+	require.NoError(t, json.Unmarshal([]byte(resumed[0]), &link))
+	require.Equal(t, uint64(hashChainCheckpointEntries+1), link.Sequence)
+
+	report, err := VerifyHashChain(strings.NewReader(strings.Join(append(lines, resumed...), "")), "", testHashChainMAC(s))
+	require.NoError(t, err)
+	require.Equal(t, 1, report.Chains)
+	require.Equal(t, []HashChainIssue{
+		{
+			Kind:     HashChainIssueResumed,
+			Line:     hashChainCheckpointEntries + 3,
+			Sequence: hashChainCheckpointEntries + 1,
+			Detail:   fmt.Sprintf("chain resumed from sequence %d, superseding sequences %d to %d", hashChainCheckpointEntries, hashChainCheckpointEntries+1, hashChainCheckpointEntries+2),
+		},
+	}, report.Issues)
+	require.True(t, report.Valid())
+
+	// Modifying an entry written after resuming is still detected.
+	resumed[0] = strings.Replace(resumed[0], "entry 0", "entry 9", 1)
+	report, err = VerifyHashChain(strings.NewReader(strings.Join(append(lines, resumed...), "")), "", testHashChainMAC(s))
+	require.NoError(t, err)
+	require.False(t, report.Valid())
+}
+
+// readOnlyStorage is storage which can't be written to, e.g. on a performance standby.
+type readOnlyStorage struct {
+	logical.InmemStorage
+}
+
+func (s *readOnlyStorage) Put(_ context.Context, _ *logical.StorageEntry) error {
+	return logical.ErrReadOnly
+}
+
+// TestHashChain_readOnlyStorage ensures that a new, in memory, chain is started
+// when the head of the chain can't be persisted.
+func TestHashChain_readOnlyStorage(t *testing.T) {
+	t.Parallel()
+
+	s := newStaticSalt(t)
+	storage := &readOnlyStorage{}
+	entry, err := logical.StorageEntryJSON(hashChainStoragePath, hashChainHead{Sequence: 10, LastHash: "hmac-sha256:abc"})
+	require.NoError(t, err)
+	require.NoError(t, storage.InmemStorage.Put(context.Background(), entry))
+
+	c := &hashChain{salter: s, logger: hclog.NewNullLogger(), storage: storage}
+	lines := testHashChainLink(t, c, "", 2)
+	require.Nil(t, c.storage)
+
+	var link chainLink
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &link))
+	require.Equal(t, uint64(1), link.Sequence)
+	require.Empty(t, link.PrevHash)
+}
+
+// TestVerifyHashChain ensures that we can detect removed, modified, duplicated
+// and reordered entries in a log.
+func TestVerifyHashChain(t *testing.T) {
+	t.Parallel()
+
+	s := newStaticSalt(t)
+
+	tests := map[string]struct {
+		prefix         string
+		modify         func(lines []string) []string
+		expectedIssues []HashChainIssue
+		expectedChains int
+		valid          bool
+	}{
+		"intact": {
+			modify:         func(lines []string) []string { return lines },
+			expectedChains: 1,
+			valid:          true,
+		},
+		"intact-with-prefix": {
+			prefix:         "vault: ",
+			modify:         func(lines []string) []string { return lines },
+			expectedChains: 1,
+			valid:          true,
+		},
+		"rotated": {
+			modify:         func(lines []string) []string { return lines[2:] },
+			expectedChains: 1,
+			valid:          true,
+		},
+		"reenabled": {
+			modify: func(lines []string) []string {
+				return append(lines, testHashChainLog(t, s, "", 2)...)
+			},
+			expectedChains: 2,
+			valid:          true,
+		},
+		"removed": {
+			modify: func(lines []string) []string {
+				return append(lines[:1], lines[3:]...)
+			},
+			expectedIssues: []HashChainIssue{
+				{Kind: HashChainIssueGap, Line: 2, Sequence: 2, Detail: "sequences 2 to 3 are missing"},
+			},
+			expectedChains: 1,
+		},
+		"modified": {
+			modify: func(lines []string) []string {
+				lines[1] = strings.Replace(lines[1], "entry 1", "entry 9", 1)
+				return lines
+			},
+			expectedIssues: []HashChainIssue{
+				{Kind: HashChainIssueModified, Line: 2, Sequence: 2, Detail: "entry at line 2 does not match the hash recorded by sequence 3 at line 3"},
+			},
+			expectedChains: 1,
+		},
+		"duplicated": {
+			modify: func(lines []string) []string {
+				return append(lines[:3], lines[2:]...)
+			},
+			expectedIssues: []HashChainIssue{
+				{Kind: HashChainIssueDuplicate, Line: 4, Sequence: 3, Detail: "sequence number has already been seen"},
+			},
+			expectedChains: 1,
+		},
+		"reordered": {
+			modify: func(lines []string) []string {
+				lines[1], lines[2] = lines[2], lines[1]
+				return lines
+			},
+			expectedIssues: []HashChainIssue{
+				{Kind: HashChainIssueReordered, Line: 3, Sequence: 2, Detail: "entry appears after sequence 3"},
+			},
+			expectedChains: 1,
+			valid:          true,
+		},
+		"recomputed": {
+			modify: func(lines []string) []string {
+				// Modifying an entry and recomputing the rest of the chain
+				// without the salt doesn't produce matching hashes.
+				forged := testHashChainLog(t, newStaticSalt(t), "", 5)
+				forged[1] = strings.Replace(forged[1], "entry 1", "entry 9", 1)
+				return append(lines[:1], forged[1:]...)
+			},
+			expectedIssues: []HashChainIssue{
+				{Kind: HashChainIssueModified, Line: 1, Sequence: 1, Detail: "entry at line 1 does not match the hash recorded by sequence 2 at line 2"},
+				{Kind: HashChainIssueModified, Line: 2, Sequence: 2, Detail: "entry at line 2 does not match the hash recorded by sequence 3 at line 3"},
+				{Kind: HashChainIssueModified, Line: 3, Sequence: 3, Detail: "entry at line 3 does not match the hash recorded by sequence 4 at line 4"},
+				{Kind: HashChainIssueModified, Line: 4, Sequence: 4, Detail: "entry at line 4 does not match the hash recorded by sequence 5 at line 5"},
+			},
+			expectedChains: 1,
+		},
+		"test-message": {
+			modify: func(lines []string) []string {
+				return append([]string{"{\"type\":\"request\",\"request\":{\"path\":\"sys/audit/test\"}}\n"}, lines...)
+			},
+			expectedChains: 1,
+			valid:          true,
+		},
+		"not-chained": {
+			modify: func(lines []string) []string {
+				return append(lines, "{\"type\":\"request\"}\n")
+			},
+			expectedIssues: []HashChainIssue{
+				{Kind: HashChainIssueInvalid, Line: 6, Detail: "entry has no sequence number"},
+			},
+			expectedChains: 1,
+		},
+		"garbage": {
+			modify: func(lines []string) []string {
+				return append(lines, "garbage\n")
+			},
+			expectedIssues: []HashChainIssue{
+				{Kind: HashChainIssueInvalid, Line: 6, Detail: "unable to parse entry: invalid character 'g' looking for beginning of value"},
+			},
+			expectedChains: 1,
+		},
+	}
+
+	for name, tc := range tests {
+		name := name
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			lines := tc.modify(testHashChainLog(t, s, tc.prefix, 5))
+
+			report, err := VerifyHashChain(strings.NewReader(strings.Join(lines, "")), tc.prefix, testHashChainMAC(s))
+			require.NoError(t, err)
+			require.Equal(t, len(lines), report.Entries)
+			require.Equal(t, tc.expectedChains, report.Chains)
+			require.Equal(t, tc.expectedIssues, report.Issues)
+			require.Equal(t, tc.valid, report.Valid())
+		})
+	}
+}
+
+// TestEntryFormatter_Process_HashChain ensures that entries formatted
+// concurrently by an EntryFormatter form a hash chain which can be verified.
+func TestEntryFormatter_Process_HashChain(t *testing.T) {
+	t.Parallel()
+
+	cfg, err := NewFormatterConfig(&testHeaderFormatter{}, WithHashChain(true), WithPrefix("vault: "))
+	require.NoError(t, err)
+	require.True(t, cfg.HashChain)
+
+	s := newStaticSalt(t)
+	f, err := NewEntryFormatter("juan", cfg, s, hclog.NewNullLogger(), WithStorage(&logical.InmemStorage{}))
+	require.NoError(t, err)
+
+	process := func() []byte {
+		e := fakeEvent(t, RequestType, &logical.LogInput{Request: &logical.Request{ID: "123"}})
+		processed, err := f.Process(namespace.RootContext(context.Background()), e)
+		require.NoError(t, err)
+		result, found := processed.Format(JSONFormat.String())
+		require.True(t, found)
+		return result
+	}
+
+	// The first entry starts the chain, the rest may be written in any order.
+	results := make([][]byte, 10)
+	results[0] = process()
+
+	var wg sync.WaitGroup
+	for i := 1; i < len(results); i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i] = process()
+		}(i)
+	}
+	wg.Wait()
+
+	report, err := VerifyHashChain(bytes.NewReader(bytes.Join(results, nil)), "vault: ", testHashChainMAC(s))
+	require.NoError(t, err)
+	require.Equal(t, 10, report.Entries)
+	require.Equal(t, 1, report.Chains)
+	require.True(t, report.Valid())
+
+	// Test messages are formatted using a temporary salt, so they mustn't
+	// consume sequence numbers from the chain.
+	require.Nil(t, newTemporaryEntryFormatter(f).chain)
+}
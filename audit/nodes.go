@@ -55,8 +55,9 @@ func ProcessManual(ctx context.Context, data *logical.LogInput, ids []eventlogge
 	// Process nodes in order, updating the event with the result.
 	// This means we *should* do:
 	// 1. filter (optional if configured)
-	// 2. formatter (temporary)
-	// 3. sink
+	// 2. redactor (optional if configured)
+	// 3. formatter (temporary)
+	// 4. sink
 	for _, id := range ids {
 		// If the event is nil, we've completed processing the pipeline (hopefully
 		// by either a filter node or a sink node).
@@ -70,9 +71,14 @@ func ProcessManual(ctx context.Context, data *logical.LogInput, ids []eventlogge
 
 		switch node.Type() {
 		case eventlogger.NodeTypeFormatter:
-			// Use a temporary formatter node  which doesn't persist its salt anywhere.
-			if formatNode, ok := node.(*EntryFormatter); ok && formatNode != nil {
-				e, err = newTemporaryEntryFormatter(formatNode).Process(ctx, e)
+			switch formatNode := node.(type) {
+			case *EntryFormatter:
+				// Use a temporary formatter node which doesn't persist its salt anywhere.
+				if formatNode != nil {
+					e, err = newTemporaryEntryFormatter(formatNode).Process(ctx, e)
+				}
+			case *EntryRedactor:
+				e, err = formatNode.Process(ctx, e)
 			}
 		default:
 			e, err = node.Process(ctx, e)
@@ -7,6 +7,8 @@ import (
 	"errors"
 	"strings"
 	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
 )
 
 // Option is how options are passed as arguments.
@@ -23,6 +25,8 @@ type options struct {
 	withElision      bool
 	withOmitTime     bool
 	withHMACAccessor bool
+	withHashChain    bool
+	withStorage      logical.Storage
 }
 
 // getDefaultOptions returns options with their default values.
@@ -161,3 +165,20 @@ func WithHMACAccessor(h bool) Option {
 		return nil
 	}
 }
+
+// WithHashChain provides an Option to represent whether entries should be hash chained.
+func WithHashChain(h bool) Option {
+	return func(o *options) error {
+		o.withHashChain = h
+		return nil
+	}
+}
+
+// WithStorage provides an Option to represent the storage of the audit device,
+// which is used to persist the head of a hash chain.
+func WithStorage(s logical.Storage) Option {
+	return func(o *options) error {
+		o.withStorage = s
+		return nil
+	}
+}
@@ -507,3 +507,35 @@ func TestOptions_Opts(t *testing.T) {
 		})
 	}
 }
+
+// TestOptions_WithHashChain exercises WithHashChain Option to ensure it performs as expected.
+func TestOptions_WithHashChain(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		Value         bool
+		ExpectedValue bool
+	}{
+		"true": {
+			Value:         true,
+			ExpectedValue: true,
+		},
+		"false": {
+			Value:         false,
+			ExpectedValue: false,
+		},
+	}
+
+	for name, tc := range tests {
+		name := name
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			opts := &options{}
+			applyOption := WithHashChain(tc.Value)
+			err := applyOption(opts)
+			require.NoError(t, err)
+			require.Equal(t, tc.ExpectedValue, opts.withHashChain)
+		})
+	}
+}
@@ -13,7 +13,10 @@ import (
 	"github.com/hashicorp/eventlogger"
 )
 
-var _ eventlogger.Node = (*SinkMetricTimer)(nil)
+var (
+	_ eventlogger.Node          = (*SinkMetricTimer)(nil)
+	_ eventlogger.NodeUnwrapper = (*SinkMetricTimer)(nil)
+)
 
 // SinkMetricTimer is a wrapper for any kind of eventlogger.NodeTypeSink node that
 // processes events containing an AuditEvent payload.
@@ -70,6 +73,12 @@ func (s *SinkMetricTimer) Reopen() error {
 	return s.Sink.Reopen()
 }
 
+// Unwrap returns the underlying sink (eventlogger.Node), this allows the broker
+// to close the sink when it is removed.
+func (s *SinkMetricTimer) Unwrap() eventlogger.Node {
+	return s.Sink
+}
+
 // Type wraps the Type method of this underlying sink (eventlogger.Node).
 func (s *SinkMetricTimer) Type() eventlogger.NodeType {
 	return s.Sink.Type()
@@ -74,6 +74,11 @@ type RequestEntry struct {
 	Request       *Request `json:"request,omitempty"`
 	Time          string   `json:"time,omitempty"`
 	Type          string   `json:"type,omitempty"`
+
+	// Sequence and PrevHash link the entry to the previous entry written by
+	// the same audit device, they are only populated when hash chaining is enabled.
+	Sequence uint64 `json:"sequence,omitempty"`
+	PrevHash string `json:"prev_hash,omitempty"`
 }
 
 // ResponseEntry is the structure of a response audit log entry.
@@ -85,6 +90,11 @@ type ResponseEntry struct {
 	Type      string    `json:"type,omitempty"`
 	Request   *Request  `json:"request,omitempty"`
 	Response  *Response `json:"response,omitempty"`
+
+	// Sequence and PrevHash link the entry to the previous entry written by
+	// the same audit device, they are only populated when hash chaining is enabled.
+	Sequence uint64 `json:"sequence,omitempty"`
+	PrevHash string `json:"prev_hash,omitempty"`
 }
 
 type Request struct {
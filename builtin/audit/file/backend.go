@@ -30,9 +30,10 @@ var _ audit.Backend = (*Backend)(nil)
 
 // Backend is the audit backend for the file-based audit store.
 //
-// NOTE: This audit backend is currently very simple: it appends to a file.
-// It doesn't do anything more at the moment to assist with rotation
-// or reset the write cursor, this should be done in the future.
+// NOTE: This audit backend appends to a file. The file can optionally be rotated
+// based on its size or age (see the 'max_bytes', 'max_age', 'max_files' and
+// 'gzip' options), otherwise rotation is left to external tools which should
+// send a SIGHUP so that the file is reopened.
 type Backend struct {
 	fallback   bool
 	name       string
@@ -100,6 +101,18 @@ func Factory(_ context.Context, conf *audit.BackendConfig, headersConfig audit.H
 		return nil, err
 	}
 
+	rotateOpts := []event.Option{
+		event.WithMaxBytes(conf.Config["max_bytes"]),
+		event.WithMaxAge(conf.Config["max_age"]),
+		event.WithMaxFiles(conf.Config["max_files"]),
+		event.WithGzip(conf.Config["gzip"]),
+	}
+
+	err = event.ValidateOptions(rotateOpts...)
+	if err != nil {
+		return nil, err
+	}
+
 	b := &Backend{
 		fallback:   fallback,
 		name:       conf.MountPath,
@@ -119,12 +132,17 @@ func Factory(_ context.Context, conf *audit.BackendConfig, headersConfig audit.H
 		return nil, err
 	}
 
+	err = b.configureRedactionNode(conf.Config["redaction_rules"])
+	if err != nil {
+		return nil, err
+	}
+
 	err = b.configureFormatterNode(conf.MountPath, cfg, conf.Logger)
 	if err != nil {
 		return nil, err
 	}
 
-	err = b.configureSinkNode(conf.MountPath, filePath, conf.Config["mode"], cfg.RequiredFormat.String())
+	err = b.configureSinkNode(conf.MountPath, filePath, conf.Config["mode"], cfg.RequiredFormat.String(), rotateOpts...)
 	if err != nil {
 		return nil, fmt.Errorf("error configuring sink node: %w", err)
 	}
@@ -223,9 +241,40 @@ func newFormatterConfig(headerFormatter audit.HeaderFormatter, config map[string
 		opts = append(opts, audit.WithPrefix(prefix))
 	}
 
+	if hashChainRaw, ok := config["hash_chain"]; ok {
+		v, err := strconv.ParseBool(hashChainRaw)
+		if err != nil {
+			return audit.FormatterConfig{}, fmt.Errorf("unable to parse 'hash_chain': %w", audit.ErrExternalOptions)
+		}
+		opts = append(opts, audit.WithHashChain(v))
+	}
+
 	return audit.NewFormatterConfig(headerFormatter, opts...)
 }
 
+// configureRedactionNode is used to configure a redaction node and associated ID
+// on the Backend, when any redaction rules have been supplied.
+func (b *Backend) configureRedactionNode(rules string) error {
+	if strings.TrimSpace(rules) == "" {
+		return nil
+	}
+
+	redactionNodeID, err := event.GenerateNodeID()
+	if err != nil {
+		return fmt.Errorf("error generating random NodeID for redaction node: %w: %w", audit.ErrInternal, err)
+	}
+
+	redactionNode, err := audit.NewEntryRedactor(rules)
+	if err != nil {
+		return fmt.Errorf("error creating redaction node: %w", err)
+	}
+
+	b.nodeIDList = append(b.nodeIDList, redactionNodeID)
+	b.nodeMap[redactionNodeID] = redactionNode
+
+	return nil
+}
+
 // configureFormatterNode is used to configure a formatter node and associated ID on the Backend.
 func (b *Backend) configureFormatterNode(name string, formatConfig audit.FormatterConfig, logger hclog.Logger) error {
 	formatterNodeID, err := event.GenerateNodeID()
@@ -233,7 +282,7 @@ func (b *Backend) configureFormatterNode(name string, formatConfig audit.Formatt
 		return fmt.Errorf("error generating random NodeID for formatter node: %w: %w", audit.ErrInternal, err)
 	}
 
-	formatterNode, err := audit.NewEntryFormatter(name, formatConfig, b, logger)
+	formatterNode, err := audit.NewEntryFormatter(name, formatConfig, b, logger, audit.WithStorage(b.saltView))
 	if err != nil {
 		return fmt.Errorf("error creating formatter: %w", err)
 	}
@@ -245,7 +294,9 @@ func (b *Backend) configureFormatterNode(name string, formatConfig audit.Formatt
 }
 
 // configureSinkNode is used to configure a sink node and associated ID on the Backend.
-func (b *Backend) configureSinkNode(name string, filePath string, mode string, format string) error {
+// Any supplied options are passed to the file sink, they are ignored when writing
+// to stdout or discarding entries.
+func (b *Backend) configureSinkNode(name string, filePath string, mode string, format string, opt ...event.Option) error {
 	name = strings.TrimSpace(name)
 	if name == "" {
 		return fmt.Errorf("name is required: %w", audit.ErrExternalOptions)
@@ -286,7 +337,7 @@ func (b *Backend) configureSinkNode(name string, filePath string, mode string, f
 	default:
 		// The NewFileSink function attempts to open the file and will return an error if it can't.
 		sinkName = name
-		sinkNode, err = event.NewFileSink(filePath, format, []event.Option{event.WithFileMode(mode)}...)
+		sinkNode, err = event.NewFileSink(filePath, format, append([]event.Option{event.WithFileMode(mode)}, opt...)...)
 	}
 
 	if err != nil {
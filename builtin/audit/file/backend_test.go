@@ -184,6 +184,24 @@ func TestBackend_newFormatterConfig(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		"happy-path-cef": {
+			config: map[string]string{
+				"format":        audit.CEFFormat.String(),
+				"hmac_accessor": "false",
+			},
+			want: audit.FormatterConfig{
+				RequiredFormat: audit.CEFFormat,
+			},
+		},
+		"happy-path-otlp": {
+			config: map[string]string{
+				"format": "OTLP",
+			},
+			want: audit.FormatterConfig{
+				RequiredFormat: audit.OTLPFormat,
+				HMACAccessor:   true,
+			},
+		},
 		"invalid-format": {
 			config: map[string]string{
 				"format":               " squiggly ",
@@ -236,6 +254,35 @@ func TestBackend_newFormatterConfig(t *testing.T) {
 				HMACAccessor:   true,
 			},
 		},
+		"hash-chain": {
+			config: map[string]string{
+				"format":     audit.JSONFormat.String(),
+				"hash_chain": "true",
+			},
+			want: audit.FormatterConfig{
+				RequiredFormat: audit.JSONFormat,
+				HMACAccessor:   true,
+				HashChain:      true,
+			},
+		},
+		"invalid-hash-chain": {
+			config: map[string]string{
+				"format":     audit.JSONFormat.String(),
+				"hash_chain": "maybe",
+			},
+			want:            audit.FormatterConfig{},
+			wantErr:         true,
+			expectedMessage: "unable to parse 'hash_chain': invalid configuration",
+		},
+		"hash-chain-jsonx": {
+			config: map[string]string{
+				"format":     audit.JSONxFormat.String(),
+				"hash_chain": "true",
+			},
+			want:            audit.FormatterConfig{},
+			wantErr:         true,
+			expectedMessage: "hash chaining is only supported with the \"json\" format: invalid internal parameter",
+		},
 	}
 	for name, tc := range tests {
 		name := name
@@ -256,6 +303,7 @@ func TestBackend_newFormatterConfig(t *testing.T) {
 			require.Equal(t, tc.want.HMACAccessor, got.HMACAccessor)
 			require.Equal(t, tc.want.OmitTime, got.OmitTime)
 			require.Equal(t, tc.want.Prefix, got.Prefix)
+			require.Equal(t, tc.want.HashChain, got.HashChain)
 		})
 	}
 }
@@ -471,6 +519,91 @@ func TestBackend_Factory_Conf(t *testing.T) {
 			isErrorExpected:      true,
 			expectedErrorMessage: "cannot configure a fallback device with a filter: invalid configuration",
 		},
+		"redaction-rules-valid": {
+			backendConfig: &audit.BackendConfig{
+				MountPath:  "discard",
+				SaltConfig: &salt.Config{},
+				SaltView:   &logical.InmemStorage{},
+				Logger:     hclog.NewNullLogger(),
+				Config: map[string]string{
+					"file_path":       discard,
+					"redaction_rules": `[{"filter": "mount_type == pki", "drop": ["response.data.ca_chain"]}]`,
+				},
+			},
+			isErrorExpected: false,
+		},
+		"redaction-rules-invalid": {
+			backendConfig: &audit.BackendConfig{
+				MountPath:  "discard",
+				SaltConfig: &salt.Config{},
+				SaltView:   &logical.InmemStorage{},
+				Logger:     hclog.NewNullLogger(),
+				Config: map[string]string{
+					"file_path":       discard,
+					"redaction_rules": `[{"drop": ["auth.policies"]}]`,
+				},
+			},
+			isErrorExpected:      true,
+			expectedErrorMessage: "error creating redaction node: invalid redaction rule 0: field \"auth.policies\" must be within \"request.data\" or \"response.data\": invalid configuration",
+		},
+		"rotation-valid": {
+			backendConfig: &audit.BackendConfig{
+				MountPath:  "discard",
+				SaltConfig: &salt.Config{},
+				SaltView:   &logical.InmemStorage{},
+				Logger:     hclog.NewNullLogger(),
+				Config: map[string]string{
+					"file_path": discard,
+					"max_bytes": "100MiB",
+					"max_age":   "24h",
+					"max_files": "7",
+					"gzip":      "true",
+				},
+			},
+			isErrorExpected: false,
+		},
+		"max-bytes-not-valid": {
+			backendConfig: &audit.BackendConfig{
+				MountPath:  "discard",
+				SaltConfig: &salt.Config{},
+				SaltView:   &logical.InmemStorage{},
+				Logger:     hclog.NewNullLogger(),
+				Config: map[string]string{
+					"file_path": discard,
+					"max_bytes": "lots",
+				},
+			},
+			isErrorExpected:      true,
+			expectedErrorMessage: "unable to parse max bytes: invalid parameter: could not parse capacity from input",
+		},
+		"max-age-not-valid": {
+			backendConfig: &audit.BackendConfig{
+				MountPath:  "discard",
+				SaltConfig: &salt.Config{},
+				SaltView:   &logical.InmemStorage{},
+				Logger:     hclog.NewNullLogger(),
+				Config: map[string]string{
+					"file_path": discard,
+					"max_age":   "qwerty",
+				},
+			},
+			isErrorExpected:      true,
+			expectedErrorMessage: "unable to parse max age: invalid parameter: time: invalid duration \"qwerty\"",
+		},
+		"max-files-not-valid": {
+			backendConfig: &audit.BackendConfig{
+				MountPath:  "discard",
+				SaltConfig: &salt.Config{},
+				SaltView:   &logical.InmemStorage{},
+				Logger:     hclog.NewNullLogger(),
+				Config: map[string]string{
+					"file_path": discard,
+					"max_files": "-1",
+				},
+			},
+			isErrorExpected:      true,
+			expectedErrorMessage: "max files cannot be negative: invalid parameter",
+		},
 		"non-fallback-device-with-filter": {
 			backendConfig: &audit.BackendConfig{
 				MountPath:  "discard",
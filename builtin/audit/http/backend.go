@@ -0,0 +1,467 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package http
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/hashicorp/eventlogger"
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/go-secure-stdlib/parseutil"
+	"github.com/hashicorp/vault/audit"
+	"github.com/hashicorp/vault/internal/observability/event"
+	"github.com/hashicorp/vault/sdk/helper/salt"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+var _ audit.Backend = (*Backend)(nil)
+
+// Backend is the audit backend for the HTTP audit transport, it sends batches
+// of audit entries to an HTTP endpoint.
+type Backend struct {
+	fallback   bool
+	name       string
+	nodeIDList []eventlogger.NodeID
+	nodeMap    map[eventlogger.NodeID]eventlogger.Node
+	salt       *salt.Salt
+	saltConfig *salt.Config
+	saltMutex  sync.RWMutex
+	saltView   logical.Storage
+
+	// sink is the underlying HTTP sink (also present, wrapped, in the nodeMap)
+	// which is used to flush the test message when the device is enabled.
+	sink *event.HTTPSink
+}
+
+func Factory(_ context.Context, conf *audit.BackendConfig, headersConfig audit.HeaderFormatter) (audit.Backend, error) {
+	if conf.SaltConfig == nil {
+		return nil, fmt.Errorf("nil salt config: %w", audit.ErrInvalidParameter)
+	}
+
+	if conf.SaltView == nil {
+		return nil, fmt.Errorf("nil salt view: %w", audit.ErrInvalidParameter)
+	}
+
+	if conf.Logger == nil || reflect.ValueOf(conf.Logger).IsNil() {
+		return nil, fmt.Errorf("nil logger: %w", audit.ErrInvalidParameter)
+	}
+
+	if conf.MountPath == "" {
+		return nil, fmt.Errorf("mount path cannot be empty: %w", audit.ErrInvalidParameter)
+	}
+
+	address, ok := conf.Config["address"]
+	if !ok {
+		return nil, fmt.Errorf("address is required: %w", audit.ErrExternalOptions)
+	}
+
+	writeDeadline, ok := conf.Config["write_timeout"]
+	if !ok {
+		writeDeadline = "5s"
+	}
+
+	headers, err := parseHeaders(conf.Config["headers"])
+	if err != nil {
+		return nil, err
+	}
+
+	tlsConfig, err := newTLSConfig(conf.Config)
+	if err != nil {
+		return nil, err
+	}
+
+	sinkOpts := []event.Option{
+		event.WithMaxDuration(writeDeadline),
+		event.WithHeaders(headers),
+		event.WithTLSConfig(tlsConfig),
+		event.WithBatchSize(conf.Config["batch_size"]),
+		event.WithBatchInterval(conf.Config["batch_interval"]),
+		event.WithMaxBufferedEvents(conf.Config["max_buffered_entries"]),
+		event.WithMaxRetries(conf.Config["max_retries"]),
+		event.WithRetryWait(conf.Config["retry_wait"]),
+		event.WithSpoolDir(conf.Config["spool_dir"]),
+		event.WithSpoolSize(conf.Config["spool_max_size"]),
+		event.WithLogger(conf.Logger),
+	}
+
+	err = event.ValidateOptions(sinkOpts...)
+	if err != nil {
+		return nil, err
+	}
+
+	// The config options 'fallback' and 'filter' are mutually exclusive, a fallback
+	// device catches everything, so it cannot be allowed to filter.
+	var fallback bool
+	if fallbackRaw, ok := conf.Config["fallback"]; ok {
+		fallback, err = parseutil.ParseBool(fallbackRaw)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse 'fallback': %w", audit.ErrExternalOptions)
+		}
+	}
+
+	if _, ok := conf.Config["filter"]; ok && fallback {
+		return nil, fmt.Errorf("cannot configure a fallback device with a filter: %w", audit.ErrExternalOptions)
+	}
+
+	cfg, err := newFormatterConfig(headersConfig, conf.Config)
+	if err != nil {
+		return nil, err
+	}
+
+	// OTLP events are combined into a single export request for each batch,
+	// which isn't possible if a prefix has been added to each event.
+	if cfg.RequiredFormat == audit.OTLPFormat && cfg.Prefix != "" {
+		return nil, fmt.Errorf("'prefix' cannot be used with the %q format: %w", audit.OTLPFormat, audit.ErrExternalOptions)
+	}
+
+	b := &Backend{
+		fallback:   fallback,
+		name:       conf.MountPath,
+		saltConfig: conf.SaltConfig,
+		saltView:   conf.SaltView,
+		nodeIDList: []eventlogger.NodeID{},
+		nodeMap:    make(map[eventlogger.NodeID]eventlogger.Node),
+	}
+
+	err = b.configureFilterNode(conf.Config["filter"])
+	if err != nil {
+		return nil, err
+	}
+
+	err = b.configureRedactionNode(conf.Config["redaction_rules"])
+	if err != nil {
+		return nil, err
+	}
+
+	err = b.configureFormatterNode(conf.MountPath, cfg, conf.Logger)
+	if err != nil {
+		return nil, err
+	}
+
+	err = b.configureSinkNode(conf.MountPath, address, cfg.RequiredFormat.String(), sinkOpts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return b, nil
+}
+
+// LogTestMessage processes the test message and then flushes the sink, so that
+// the message is delivered (or spooled) rather than waiting in the current batch.
+func (b *Backend) LogTestMessage(ctx context.Context, in *logical.LogInput) error {
+	if len(b.nodeIDList) > 0 {
+		err := audit.ProcessManual(ctx, in, b.nodeIDList, b.nodeMap)
+		if err != nil {
+			return err
+		}
+	}
+
+	if b.sink != nil {
+		return b.sink.Flush(ctx)
+	}
+
+	return nil
+}
+
+// Reload attempts to send any buffered and spooled audit entries.
+func (b *Backend) Reload(ctx context.Context) error {
+	for _, n := range b.nodeMap {
+		if n.Type() == eventlogger.NodeTypeSink {
+			return n.Reopen()
+		}
+	}
+
+	return nil
+}
+
+func (b *Backend) Salt(ctx context.Context) (*salt.Salt, error) {
+	b.saltMutex.RLock()
+	if b.salt != nil {
+		defer b.saltMutex.RUnlock()
+		return b.salt, nil
+	}
+	b.saltMutex.RUnlock()
+	b.saltMutex.Lock()
+	defer b.saltMutex.Unlock()
+	if b.salt != nil {
+		return b.salt, nil
+	}
+	s, err := salt.NewSalt(ctx, b.saltView, b.saltConfig)
+	if err != nil {
+		return nil, err
+	}
+	b.salt = s
+	return s, nil
+}
+
+func (b *Backend) Invalidate(_ context.Context) {
+	b.saltMutex.Lock()
+	defer b.saltMutex.Unlock()
+	b.salt = nil
+}
+
+// parseHeaders parses the 'headers' config option, which is expected to be a
+// JSON object of header names to values.
+func parseHeaders(raw string) (map[string]string, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return nil, nil
+	}
+
+	var headers map[string]string
+	if err := json.Unmarshal([]byte(raw), &headers); err != nil {
+		return nil, fmt.Errorf("unable to parse 'headers' as a JSON object of strings: %w", audit.ErrExternalOptions)
+	}
+
+	return headers, nil
+}
+
+// newTLSConfig creates the TLS configuration used to connect to the endpoint
+// using the tls_* options from the config map supplied to the factory.
+// A nil config is returned when no TLS options have been supplied.
+func newTLSConfig(config map[string]string) (*tls.Config, error) {
+	caCert := strings.TrimSpace(config["tls_ca_cert"])
+	clientCert := strings.TrimSpace(config["tls_client_cert"])
+	clientKey := strings.TrimSpace(config["tls_client_key"])
+	serverName := strings.TrimSpace(config["tls_server_name"])
+	skipVerifyRaw, hasSkipVerify := config["tls_skip_verify"]
+
+	if caCert == "" && clientCert == "" && clientKey == "" && serverName == "" && !hasSkipVerify {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ServerName: serverName,
+	}
+
+	if hasSkipVerify {
+		v, err := strconv.ParseBool(skipVerifyRaw)
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse 'tls_skip_verify': %w", audit.ErrExternalOptions)
+		}
+		tlsConfig.InsecureSkipVerify = v
+	}
+
+	if caCert != "" {
+		pem, err := os.ReadFile(caCert)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read 'tls_ca_cert': %w: %w", audit.ErrExternalOptions, err)
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in 'tls_ca_cert': %w", audit.ErrExternalOptions)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	switch {
+	case clientCert == "" && clientKey == "":
+	case clientCert == "" || clientKey == "":
+		return nil, fmt.Errorf("'tls_client_cert' and 'tls_client_key' must be configured together: %w", audit.ErrExternalOptions)
+	default:
+		cert, err := tls.LoadX509KeyPair(clientCert, clientKey)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load client certificate: %w: %w", audit.ErrExternalOptions, err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
+
+// newFormatterConfig creates the configuration required by a formatter node using
+// the config map supplied to the factory.
+func newFormatterConfig(headerFormatter audit.HeaderFormatter, config map[string]string) (audit.FormatterConfig, error) {
+	var opts []audit.Option
+
+	if format, ok := config["format"]; ok {
+		if !audit.IsValidFormat(format) {
+			return audit.FormatterConfig{}, fmt.Errorf("unsupported 'format': %w", audit.ErrExternalOptions)
+		}
+
+		opts = append(opts, audit.WithFormat(format))
+	}
+
+	// Check if hashing of accessor is disabled
+	if hmacAccessorRaw, ok := config["hmac_accessor"]; ok {
+		v, err := strconv.ParseBool(hmacAccessorRaw)
+		if err != nil {
+			return audit.FormatterConfig{}, fmt.Errorf("unable to parse 'hmac_accessor': %w", audit.ErrExternalOptions)
+		}
+		opts = append(opts, audit.WithHMACAccessor(v))
+	}
+
+	// Check if raw logging is enabled
+	if raw, ok := config["log_raw"]; ok {
+		v, err := strconv.ParseBool(raw)
+		if err != nil {
+			return audit.FormatterConfig{}, fmt.Errorf("unable to parse 'log_raw: %w", audit.ErrExternalOptions)
+		}
+		opts = append(opts, audit.WithRaw(v))
+	}
+
+	if elideListResponsesRaw, ok := config["elide_list_responses"]; ok {
+		v, err := strconv.ParseBool(elideListResponsesRaw)
+		if err != nil {
+			return audit.FormatterConfig{}, fmt.Errorf("unable to parse 'elide_list_responses': %w", audit.ErrExternalOptions)
+		}
+		opts = append(opts, audit.WithElision(v))
+	}
+
+	if prefix, ok := config["prefix"]; ok {
+		opts = append(opts, audit.WithPrefix(prefix))
+	}
+
+	if hashChainRaw, ok := config["hash_chain"]; ok {
+		v, err := strconv.ParseBool(hashChainRaw)
+		if err != nil {
+			return audit.FormatterConfig{}, fmt.Errorf("unable to parse 'hash_chain': %w", audit.ErrExternalOptions)
+		}
+		opts = append(opts, audit.WithHashChain(v))
+	}
+
+	return audit.NewFormatterConfig(headerFormatter, opts...)
+}
+
+// configureRedactionNode is used to configure a redaction node and associated ID
+// on the Backend, when any redaction rules have been supplied.
+func (b *Backend) configureRedactionNode(rules string) error {
+	if strings.TrimSpace(rules) == "" {
+		return nil
+	}
+
+	redactionNodeID, err := event.GenerateNodeID()
+	if err != nil {
+		return fmt.Errorf("error generating random NodeID for redaction node: %w: %w", audit.ErrInternal, err)
+	}
+
+	redactionNode, err := audit.NewEntryRedactor(rules)
+	if err != nil {
+		return fmt.Errorf("error creating redaction node: %w", err)
+	}
+
+	b.nodeIDList = append(b.nodeIDList, redactionNodeID)
+	b.nodeMap[redactionNodeID] = redactionNode
+
+	return nil
+}
+
+// configureFormatterNode is used to configure a formatter node and associated ID on the Backend.
+func (b *Backend) configureFormatterNode(name string, formatConfig audit.FormatterConfig, logger hclog.Logger) error {
+	formatterNodeID, err := event.GenerateNodeID()
+	if err != nil {
+		return fmt.Errorf("error generating random NodeID for formatter node: %w: %w", audit.ErrInternal, err)
+	}
+
+	formatterNode, err := audit.NewEntryFormatter(name, formatConfig, b, logger, audit.WithStorage(b.saltView))
+	if err != nil {
+		return fmt.Errorf("error creating formatter: %w", err)
+	}
+
+	b.nodeIDList = append(b.nodeIDList, formatterNodeID)
+	b.nodeMap[formatterNodeID] = formatterNode
+
+	return nil
+}
+
+// configureSinkNode is used to configure a sink node and associated ID on the Backend.
+func (b *Backend) configureSinkNode(name string, address string, format string, opts ...event.Option) error {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return fmt.Errorf("name is required: %w", audit.ErrInvalidParameter)
+	}
+
+	address = strings.TrimSpace(address)
+	if address == "" {
+		return fmt.Errorf("address is required: %w", audit.ErrInvalidParameter)
+	}
+
+	format = strings.TrimSpace(format)
+	if format == "" {
+		return fmt.Errorf("format is required: %w", audit.ErrInvalidParameter)
+	}
+
+	sinkNodeID, err := event.GenerateNodeID()
+	if err != nil {
+		return fmt.Errorf("error generating random NodeID for sink node: %w", err)
+	}
+
+	n, err := event.NewHTTPSink(address, format, opts...)
+	if err != nil {
+		return err
+	}
+
+	// Wrap the sink node with metrics middleware
+	sinkMetricTimer, err := audit.NewSinkMetricTimer(name, n)
+	if err != nil {
+		return fmt.Errorf("unable to add timing metrics to sink for path %q: %w", name, err)
+	}
+
+	// Decide what kind of labels we want and wrap the sink node inside a metrics counter.
+	var metricLabeler event.Labeler
+	switch {
+	case b.fallback:
+		metricLabeler = &audit.MetricLabelerAuditFallback{}
+	default:
+		metricLabeler = &audit.MetricLabelerAuditSink{}
+	}
+
+	sinkMetricCounter, err := event.NewMetricsCounter(name, sinkMetricTimer, metricLabeler)
+	if err != nil {
+		return fmt.Errorf("unable to add counting metrics to sink for path %q: %w", name, err)
+	}
+
+	b.sink = n
+	b.nodeIDList = append(b.nodeIDList, sinkNodeID)
+	b.nodeMap[sinkNodeID] = sinkMetricCounter
+
+	return nil
+}
+
+// Name for this backend, this would ideally correspond to the mount path for the audit device.
+func (b *Backend) Name() string {
+	return b.name
+}
+
+// Nodes returns the nodes which should be used by the event framework to process audit entries.
+func (b *Backend) Nodes() map[eventlogger.NodeID]eventlogger.Node {
+	return b.nodeMap
+}
+
+// NodeIDs returns the IDs of the nodes, in the order they are required.
+func (b *Backend) NodeIDs() []eventlogger.NodeID {
+	return b.nodeIDList
+}
+
+// EventType returns the event type for the backend.
+func (b *Backend) EventType() eventlogger.EventType {
+	return event.AuditType.AsEventType()
+}
+
+// HasFiltering determines if the first node for the pipeline is an eventlogger.NodeTypeFilter.
+func (b *Backend) HasFiltering() bool {
+	if b.nodeMap == nil {
+		return false
+	}
+
+	return len(b.nodeIDList) > 0 && b.nodeMap[b.nodeIDList[0]].Type() == eventlogger.NodeTypeFilter
+}
+
+// IsFallback can be used to determine if this audit backend device is intended to
+// be used as a fallback to catch all events that are not written when only using
+// filtered pipelines.
+func (b *Backend) IsFallback() bool {
+	return b.fallback
+}
@@ -108,6 +108,11 @@ func Factory(_ context.Context, conf *audit.BackendConfig, headersConfig audit.H
 		return nil, err
 	}
 
+	err = b.configureRedactionNode(conf.Config["redaction_rules"])
+	if err != nil {
+		return nil, err
+	}
+
 	err = b.configureFormatterNode(conf.MountPath, cfg, conf.Logger)
 	if err != nil {
 		return nil, err
@@ -208,9 +213,40 @@ func newFormatterConfig(headerFormatter audit.HeaderFormatter, config map[string
 		opts = append(opts, audit.WithPrefix(prefix))
 	}
 
+	if hashChainRaw, ok := config["hash_chain"]; ok {
+		v, err := strconv.ParseBool(hashChainRaw)
+		if err != nil {
+			return audit.FormatterConfig{}, fmt.Errorf("unable to parse 'hash_chain': %w", audit.ErrExternalOptions)
+		}
+		opts = append(opts, audit.WithHashChain(v))
+	}
+
 	return audit.NewFormatterConfig(headerFormatter, opts...)
 }
 
+// configureRedactionNode is used to configure a redaction node and associated ID
+// on the Backend, when any redaction rules have been supplied.
+func (b *Backend) configureRedactionNode(rules string) error {
+	if strings.TrimSpace(rules) == "" {
+		return nil
+	}
+
+	redactionNodeID, err := event.GenerateNodeID()
+	if err != nil {
+		return fmt.Errorf("error generating random NodeID for redaction node: %w: %w", audit.ErrInternal, err)
+	}
+
+	redactionNode, err := audit.NewEntryRedactor(rules)
+	if err != nil {
+		return fmt.Errorf("error creating redaction node: %w", err)
+	}
+
+	b.nodeIDList = append(b.nodeIDList, redactionNodeID)
+	b.nodeMap[redactionNodeID] = redactionNode
+
+	return nil
+}
+
 // configureFormatterNode is used to configure a formatter node and associated ID on the Backend.
 func (b *Backend) configureFormatterNode(name string, formatConfig audit.FormatterConfig, logger hclog.Logger) error {
 	formatterNodeID, err := event.GenerateNodeID()
@@ -218,7 +254,7 @@ func (b *Backend) configureFormatterNode(name string, formatConfig audit.Formatt
 		return fmt.Errorf("error generating random NodeID for formatter node: %w: %w", audit.ErrInternal, err)
 	}
 
-	formatterNode, err := audit.NewEntryFormatter(name, formatConfig, b, logger)
+	formatterNode, err := audit.NewEntryFormatter(name, formatConfig, b, logger, audit.WithStorage(b.saltView))
 	if err != nil {
 		return fmt.Errorf("error creating formatter: %w", err)
 	}
@@ -25,6 +25,7 @@ var ErrAccountDoesNotExist = errors.New("The request specified an account that d
 var ErrAcmeDisabled = errors.New("ACME feature is disabled")
 
 var (
+	ErrAlreadyReplaced         = errors.New("The request specified a predecessor certificate which has already been replaced")
 	ErrAlreadyRevoked          = errors.New("The request specified a certificate to be revoked that has already been revoked")
 	ErrBadCSR                  = errors.New("The CSR is unacceptable")
 	ErrBadNonce                = errors.New("The client sent an unacceptable anti-replay nonce")
@@ -53,6 +54,7 @@ var (
 // Mapping of err->name; see table in RFC 8555 Section 6.7. Errors.
 var errIdMappings = map[error]string{
 	ErrAccountDoesNotExist:     "accountDoesNotExist",
+	ErrAlreadyReplaced:         "alreadyReplaced",
 	ErrAlreadyRevoked:          "alreadyRevoked",
 	ErrBadCSR:                  "badCSR",
 	ErrBadNonce:                "badNonce",
@@ -81,6 +83,7 @@ var errIdMappings = map[error]string{
 // Mapping of err->status codes; see table in RFC 8555 Section 6.7. Errors.
 var errCodeMappings = map[error]int{
 	ErrAccountDoesNotExist:     http.StatusBadRequest, // See RFC 8555 Section 7.3.1. Finding an Account URL Given a Key.
+	ErrAlreadyReplaced:         http.StatusConflict,   // See draft-ietf-acme-ari, Section 5. Extensions to the Order Object.
 	ErrAlreadyRevoked:          http.StatusBadRequest,
 	ErrBadCSR:                  http.StatusBadRequest,
 	ErrBadNonce:                http.StatusBadRequest,
@@ -220,6 +220,8 @@ type acmeOrder struct {
 	AuthorizationIds        []string            `json:"authorization-ids"`
 	CertificateSerialNumber string              `json:"cert-serial-number"`
 	CertificateExpiry       time.Time           `json:"cert-expiry"`
+	// The ACME Renewal Information identifier of the certificate this order replaces, if any.
+	Replaces string `json:"replaces,omitempty"`
 	// The actual issuer UUID that issued the certificate, blank if an order exists but no certificate was issued.
 	IssuerId issuing.IssuerID `json:"issuer-id"`
 }
@@ -580,6 +582,8 @@ type acmeCertEntry struct {
 	Serial  string `json:"-"`
 	Account string `json:"-"`
 	Order   string `json:"order"`
+	// The order which issued the certificate replacing this one, if any.
+	ReplacedBy string `json:"replaced-by,omitempty"`
 }
 
 func (a *acmeState) TrackIssuedCert(ac *acmeContext, accountId string, serial string, orderId string) error {
@@ -600,6 +604,29 @@ func (a *acmeState) TrackIssuedCert(ac *acmeContext, accountId string, serial st
 	return nil
 }
 
+// MarkIssuedCertReplaced records that the certificate with the given serial
+// was replaced by the one issued by the given order, per the ACME Renewal
+// Information extension.
+func (a *acmeState) MarkIssuedCertReplaced(ac *acmeContext, accountId string, serial string, orderId string) error {
+	entry, err := a.GetIssuedCert(ac, accountId, serial)
+	if err != nil {
+		return err
+	}
+
+	entry.ReplacedBy = orderId
+
+	json, err := logical.StorageEntryJSON(getAcmeSerialToAccountTrackerPath(accountId, serial), entry)
+	if err != nil {
+		return fmt.Errorf("error serializing acme cert entry: %w", err)
+	}
+
+	if err = ac.sc.Storage.Put(ac.sc.Context, json); err != nil {
+		return fmt.Errorf("error writing acme cert entry: %w", err)
+	}
+
+	return nil
+}
+
 func (a *acmeState) GetIssuedCert(ac *acmeContext, accountId string, serial string) (*acmeCertEntry, error) {
 	path := acmeAccountPrefix + accountId + "/certs/" + normalizeSerial(serial)
 
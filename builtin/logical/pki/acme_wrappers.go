@@ -59,6 +59,7 @@ func setupAcmeDirectory(b *backend, acmePrefix string, unauthPrefix string, opts
 	b.Backend.Paths = append(b.Backend.Paths, pathAcmeChallenge(b, acmePrefix, opts))
 	b.Backend.Paths = append(b.Backend.Paths, pathAcmeAuthorization(b, acmePrefix, opts))
 	b.Backend.Paths = append(b.Backend.Paths, pathAcmeRevoke(b, acmePrefix, opts))
+	b.Backend.Paths = append(b.Backend.Paths, pathAcmeRenewalInfo(b, acmePrefix, opts))
 	b.Backend.Paths = append(b.Backend.Paths, pathAcmeNewEab(b, acmePrefix)) // auth'd API that lives underneath the various /acme paths
 
 	// Add specific un-auth'd paths for ACME APIs
@@ -75,6 +76,7 @@ func setupAcmeDirectory(b *backend, acmePrefix string, unauthPrefix string, opts
 	b.PathsSpecial.Unauthenticated = append(b.PathsSpecial.Unauthenticated, unauthPrefix+"/order/+")
 	b.PathsSpecial.Unauthenticated = append(b.PathsSpecial.Unauthenticated, unauthPrefix+"/order/+/finalize")
 	b.PathsSpecial.Unauthenticated = append(b.PathsSpecial.Unauthenticated, unauthPrefix+"/order/+/cert")
+	b.PathsSpecial.Unauthenticated = append(b.PathsSpecial.Unauthenticated, unauthPrefix+"/renewal-info/+")
 	// We specifically do NOT add acme/new-eab to this as it should be auth'd
 }
 
@@ -232,6 +232,12 @@ func Backend(conf *logical.BackendConfig) *backend {
 			pathAcmeConfig(&b),
 			pathAcmeEabList(&b),
 			pathAcmeEabDelete(&b),
+
+			// EST
+			pathConfigEst(&b),
+
+			// SCEP
+			pathConfigScep(&b),
 		},
 
 		Secrets: []*framework.Secret{
@@ -275,6 +281,12 @@ func Backend(conf *logical.BackendConfig) *backend {
 		setupAcmeDirectory(&b, prefix.acmePrefix, prefix.unauthPrefix, prefix.opts)
 	}
 
+	// Add EST paths to backend
+	setupEstPaths(&b)
+
+	// Add SCEP paths to backend
+	setupScepPaths(&b)
+
 	b.tidyCASGuard = new(uint32)
 	b.tidyCancelCAS = new(uint32)
 	b.tidyStatus = &tidyStatus{state: tidyStatusInactive}
@@ -328,6 +340,15 @@ type backend struct {
 	// Write lock around issuers and keys.
 	issuersLock sync.RWMutex
 
+	// Lock around the EST configuration and the .well-known/est redirects
+	// registered for it.
+	estLock      sync.Mutex
+	estRedirects map[string]string
+
+	// Lock around consuming SCEP challenge passwords, so that each can only
+	// be used once.
+	scepLock sync.Mutex
+
 	// Context around ACME operations
 	acmeState       *acmeState
 	acmeAccountLock sync.RWMutex // (Write) Locked on Tidy, (Read) Locked on Account Creation
@@ -434,6 +455,8 @@ func (b *backend) initialize(ctx context.Context, ir *logical.InitializationRequ
 		return err
 	}
 
+	b.reloadEstRedirects(sc)
+
 	// Initialize also needs to populate our certificate and revoked certificate count
 	err = b.initializeStoredCertificateCounts(ctx)
 	if err != nil {
@@ -450,6 +473,8 @@ func (b *backend) cleanup(ctx context.Context) {
 
 	b.GetAcmeState().Shutdown(b)
 
+	b.removeEstRedirects(ctx)
+
 	b.cleanupEnt(sc)
 }
 
@@ -587,6 +612,10 @@ func (b *backend) invalidate(ctx context.Context, key string) {
 		b.CrlBuilder().markConfigDirty()
 	case key == storageAcmeConfig:
 		b.GetAcmeState().markConfigDirty()
+	case key == storageEstConfig:
+		// Invalidations may hold storage locks, so don't load the
+		// configuration inline.
+		go b.reloadEstRedirects(b.makeStorageContext(context.Background(), b.storage))
 	case key == storageIssuerConfig:
 		b.CrlBuilder().invalidateCRLBuildTime()
 	case strings.HasPrefix(key, crossRevocationPrefix):
@@ -6694,18 +6694,48 @@ func pathShouldBeUnauthedWriteOnly(t *testing.T, client *api.Client, path string
 	}
 }
 
+func pathShouldBeUnauthedReadWrite(t *testing.T, client *api.Client, path string, token string) {
+	for _, withToken := range []string{"", token} {
+		client.SetToken(withToken)
+		authed := withToken != ""
+
+		// Reading and writing should be allowed, though with no parameters
+		// the request itself will generally fail.
+		resp, err := client.Logical().ReadWithContext(ctx, path)
+		if err != nil && isPermDenied(err) {
+			t.Fatalf("unexpected failure to read %v (authed: %v): %v / %v", path, authed, err, resp)
+		}
+		resp, err = client.Logical().WriteWithContext(ctx, path, map[string]interface{}{})
+		if err != nil && isPermDenied(err) {
+			t.Fatalf("unexpected failure to write %v (authed: %v): %v / %v", path, authed, err, resp)
+		}
+
+		// These should all be denied.
+		resp, err = client.Logical().DeleteWithContext(ctx, path)
+		if (err == nil && resp != nil) || (err != nil && !isDeniedOp(err)) {
+			t.Fatalf("unexpected failure during delete on read-write path %v (authed: %v): %v / %v", path, authed, err, resp)
+		}
+		resp, err = client.Logical().JSONMergePatch(ctx, path, map[string]interface{}{})
+		if (err == nil && resp != nil) || (err != nil && !isDeniedOp(err)) {
+			t.Fatalf("unexpected failure during patch on read-write path %v (authed: %v): %v / %v", path, authed, err, resp)
+		}
+	}
+}
+
 type pathAuthChecker int
 
 const (
 	shouldBeAuthed pathAuthChecker = iota
 	shouldBeUnauthedReadList
 	shouldBeUnauthedWriteOnly
+	shouldBeUnauthedReadWrite
 )
 
 var pathAuthChckerMap = map[pathAuthChecker]pathAuthCheckerFunc{
 	shouldBeAuthed:            pathShouldBeAuthed,
 	shouldBeUnauthedReadList:  pathShouldBeUnauthedReadList,
 	shouldBeUnauthedWriteOnly: pathShouldBeUnauthedWriteOnly,
+	shouldBeUnauthedReadWrite: pathShouldBeUnauthedReadWrite,
 }
 
 func TestProperAuthing(t *testing.T) {
@@ -6785,6 +6815,8 @@ func TestProperAuthing(t *testing.T) {
 		"certs/revocation-queue/":                shouldBeAuthed,
 		"certs/unified-revoked/":                 shouldBeAuthed,
 		"config/acme":                            shouldBeAuthed,
+		"config/est":                             shouldBeAuthed,
+		"config/scep":                            shouldBeAuthed,
 		"config/auto-tidy":                       shouldBeAuthed,
 		"config/ca":                              shouldBeAuthed,
 		"config/cluster":                         shouldBeAuthed,
@@ -6900,11 +6932,25 @@ func TestProperAuthing(t *testing.T) {
 		paths[acmePrefix+"order/13b80844-e60d-42d2-b7e9-152a8e834b90"] = shouldBeUnauthedWriteOnly
 		paths[acmePrefix+"order/13b80844-e60d-42d2-b7e9-152a8e834b90/finalize"] = shouldBeUnauthedWriteOnly
 		paths[acmePrefix+"order/13b80844-e60d-42d2-b7e9-152a8e834b90/cert"] = shouldBeUnauthedWriteOnly
+		paths[acmePrefix+"renewal-info/aYhba4dGQEHhs3uEe6CuLN4ByNQ.AIdlQyE"] = shouldBeUnauthedReadList
 
 		// Make sure this new-eab path is auth'd
 		paths[acmePrefix+"new-eab"] = shouldBeAuthed
 	}
 
+	// Add EST based paths to the test suite
+	for _, estPrefix := range []string{"est/", "est/test-label/", "roles/test/est/"} {
+		paths[estPrefix+"cacerts"] = shouldBeUnauthedReadList
+		paths[estPrefix+"csrattrs"] = shouldBeUnauthedReadList
+		paths[estPrefix+"simpleenroll"] = shouldBeUnauthedWriteOnly
+		paths[estPrefix+"simplereenroll"] = shouldBeUnauthedWriteOnly
+	}
+
+	// Add SCEP based paths to the test suite
+	paths["scep"] = shouldBeUnauthedReadWrite
+	paths["roles/test/scep"] = shouldBeUnauthedReadWrite
+	paths["roles/test/scep/challenge"] = shouldBeAuthed
+
 	for path, checkerType := range paths {
 		checker := pathAuthChckerMap[checkerType]
 		checker(t, client, "pki/"+path, token)
@@ -6960,12 +7006,18 @@ func TestProperAuthing(t *testing.T) {
 		if strings.Contains(raw_path, "acme/") && strings.Contains(raw_path, "{order_id}") {
 			raw_path = strings.ReplaceAll(raw_path, "{order_id}", "13b80844-e60d-42d2-b7e9-152a8e834b90")
 		}
+		if strings.Contains(raw_path, "acme/") && strings.Contains(raw_path, "{cert_id}") {
+			raw_path = strings.ReplaceAll(raw_path, "{cert_id}", "aYhba4dGQEHhs3uEe6CuLN4ByNQ.AIdlQyE")
+		}
 		if strings.Contains(raw_path, "eab") && strings.Contains(raw_path, "{key_id}") {
 			raw_path = strings.ReplaceAll(raw_path, "{key_id}", eabKid)
 		}
 		if strings.Contains(raw_path, "external-policy/") && strings.Contains(raw_path, "{policy}") {
 			raw_path = strings.ReplaceAll(raw_path, "{policy}", "a-policy")
 		}
+		if strings.Contains(raw_path, "est/") && strings.Contains(raw_path, "{label}") {
+			raw_path = strings.ReplaceAll(raw_path, "{label}", "test-label")
+		}
 
 		raw_path = entProperAuthingPathReplacer(raw_path)
 
@@ -7151,6 +7203,108 @@ func TestGenerateRootCAWithAIA(t *testing.T) {
 	requireSuccessNonNilResponse(t, resp, err, "expected root generation to succeed")
 }
 
+func TestBackend_NameConstraints(t *testing.T) {
+	t.Parallel()
+	b, s := CreateBackendWithStorage(t)
+
+	// Invalid IP ranges are rejected.
+	_, err := CBWrite(b, s, "root/generate/internal", map[string]interface{}{
+		"common_name":         "root example.com",
+		"permitted_ip_ranges": "10.0.0.1",
+	})
+	require.ErrorContains(t, err, "is not a valid IP range")
+
+	resp, err := CBWrite(b, s, "root/generate/internal", map[string]interface{}{
+		"common_name":               "root example.com",
+		"ttl":                       "40h",
+		"permitted_dns_domains":     "example.com",
+		"excluded_dns_domains":      "bad.example.com",
+		"permitted_ip_ranges":       "10.0.0.0/8",
+		"excluded_ip_ranges":        "10.1.0.0/16",
+		"permitted_email_addresses": "example.com",
+		"excluded_email_addresses":  "root@example.com",
+		"permitted_uri_domains":     ".example.com",
+		"excluded_uri_domains":      "bad.example.com",
+	})
+	requireSuccessNonNilResponse(t, resp, err, "expected root generation to succeed")
+
+	rootCert := parseCert(t, resp.Data["certificate"].(string))
+	require.True(t, rootCert.PermittedDNSDomainsCritical)
+	require.Equal(t, []string{"example.com"}, rootCert.PermittedDNSDomains)
+	require.Equal(t, []string{"bad.example.com"}, rootCert.ExcludedDNSDomains)
+	require.Equal(t, "10.0.0.0/8", certutil.MakeIpRangeCommaSeparatedString(rootCert.PermittedIPRanges))
+	require.Equal(t, "10.1.0.0/16", certutil.MakeIpRangeCommaSeparatedString(rootCert.ExcludedIPRanges))
+	require.Equal(t, []string{"example.com"}, rootCert.PermittedEmailAddresses)
+	require.Equal(t, []string{"root@example.com"}, rootCert.ExcludedEmailAddresses)
+	require.Equal(t, []string{".example.com"}, rootCert.PermittedURIDomains)
+	require.Equal(t, []string{"bad.example.com"}, rootCert.ExcludedURIDomains)
+
+	_, err = CBWrite(b, s, "roles/test", map[string]interface{}{
+		"allow_any_name":   true,
+		"allowed_uri_sans": "*",
+		"ttl":              "1h",
+	})
+	require.NoError(t, err)
+
+	resp, err = CBWrite(b, s, "issue/test", map[string]interface{}{
+		"common_name": "www.example.com",
+		"alt_names":   "user@example.com",
+		"ip_sans":     "10.0.0.1",
+		"uri_sans":    "https://www.example.com/path",
+	})
+	requireSuccessNonNilResponse(t, resp, err, "expected issuance within the name constraints to succeed")
+
+	for name, data := range map[string]map[string]interface{}{
+		"dns not permitted":   {"common_name": "www.example.org"},
+		"dns excluded":        {"common_name": "host.bad.example.com"},
+		"ip not permitted":    {"common_name": "www.example.com", "ip_sans": "192.168.0.1"},
+		"ip excluded":         {"common_name": "www.example.com", "ip_sans": "10.1.2.3"},
+		"email not permitted": {"common_name": "www.example.com", "alt_names": "user@example.org"},
+		"email excluded":      {"common_name": "www.example.com", "alt_names": "root@example.com"},
+		"uri not permitted":   {"common_name": "www.example.com", "uri_sans": "https://example.org"},
+		"uri excluded":        {"common_name": "www.example.com", "uri_sans": "https://bad.example.com"},
+		"uri without host":    {"common_name": "www.example.com", "uri_sans": "urn:uuid:6e8bc430-9c3a-11d9-9669-0800200c9a66"},
+	} {
+		_, err = CBWrite(b, s, "issue/test", data)
+		require.ErrorContains(t, err, "name constraints of the issuer", name)
+	}
+
+	// Constraints can also be set when signing intermediates, and apply to
+	// the intermediate's own names.
+	bInt, sInt := CreateBackendWithStorage(t)
+	resp, err = CBWrite(bInt, sInt, "intermediate/generate/internal", map[string]interface{}{
+		"common_name": "int.example.com",
+	})
+	requireSuccessNonNilResponse(t, resp, err, "expected intermediate CSR generation to succeed")
+	csr := resp.Data["csr"].(string)
+
+	_, err = CBWrite(b, s, "root/sign-intermediate", map[string]interface{}{
+		"common_name": "int.example.org",
+		"csr":         csr,
+	})
+	require.ErrorContains(t, err, "name constraints of the issuer")
+
+	resp, err = CBWrite(b, s, "root/sign-intermediate", map[string]interface{}{
+		"common_name":              "int.example.com",
+		"csr":                      csr,
+		"ttl":                      "20h",
+		"permitted_dns_domains":    "int.example.com",
+		"excluded_ip_ranges":       "10.2.0.0/16,fd00::/8",
+		"excluded_email_addresses": ".example.com",
+		"excluded_uri_domains":     ".int.example.com",
+	})
+	requireSuccessNonNilResponse(t, resp, err, "expected intermediate signing to succeed")
+
+	intCert := parseCert(t, resp.Data["certificate"].(string))
+	require.True(t, intCert.PermittedDNSDomainsCritical)
+	require.Equal(t, []string{"int.example.com"}, intCert.PermittedDNSDomains)
+	require.Empty(t, intCert.ExcludedDNSDomains)
+	require.Empty(t, intCert.PermittedIPRanges)
+	require.Equal(t, "10.2.0.0/16,fd00::/8", certutil.MakeIpRangeCommaSeparatedString(intCert.ExcludedIPRanges))
+	require.Equal(t, []string{".example.com"}, intCert.ExcludedEmailAddresses)
+	require.Equal(t, []string{".int.example.com"}, intCert.ExcludedURIDomains)
+}
+
 var (
 	initTest  sync.Once
 	rsaCAKey  string
@@ -4,6 +4,7 @@
 package pki
 
 import (
+	"context"
 	"crypto"
 	"crypto/x509"
 	"crypto/x509/pkix"
@@ -347,7 +348,9 @@ func generateCert(sc *storageContext,
 
 	if isCA {
 		data.Params.IsCA = isCA
-		data.Params.PermittedDNSDomains = input.apiData.Get("permitted_dns_domains").([]string)
+		if err := issuing.SetNameConstraints(data.Params, NewNameConstraintsInputFromFieldData(input.apiData)); err != nil {
+			return nil, nil, err
+		}
 
 		if data.SigningBundle == nil {
 			// Generating a self-signed root certificate. Since we have no
@@ -391,11 +394,23 @@ func generateCert(sc *storageContext,
 		}
 	}
 
+	var ctSigner *issuing.CTSigner
+	if !isCA {
+		ctSigner = issuing.NewCTSigner(sc.Context, caSign)
+	}
+	if ctSigner != nil {
+		data.CertificateSigner = ctSigner.SignCertificate
+	}
+
 	parsedBundle, err := generateCABundle(sc, input, data, randomSource)
 	if err != nil {
 		return nil, nil, err
 	}
 
+	if ctSigner != nil {
+		warnings = append(warnings, ctSigner.Warnings...)
+	}
+
 	return parsedBundle, warnings, nil
 }
 
@@ -422,15 +437,17 @@ func generateIntermediateCSR(sc *storageContext, input *inputBundle, randomSourc
 func NewSignCertInputFromDataFields(data *framework.FieldData, isCA bool, useCSRValues bool) SignCertInputFromDataFields {
 	certBundle := NewCreationBundleInputFromFieldData(data)
 	return SignCertInputFromDataFields{
-		CreationBundleInputFromFieldData: certBundle,
-		data:                             data,
-		isCA:                             isCA,
-		useCSRValues:                     useCSRValues,
+		CreationBundleInputFromFieldData:  certBundle,
+		NameConstraintsInputFromFieldData: NewNameConstraintsInputFromFieldData(data),
+		data:                              data,
+		isCA:                              isCA,
+		useCSRValues:                      useCSRValues,
 	}
 }
 
 type SignCertInputFromDataFields struct {
 	CreationBundleInputFromFieldData
+	NameConstraintsInputFromFieldData
 	data         *framework.FieldData
 	isCA         bool
 	useCSRValues bool
@@ -464,11 +481,49 @@ func (i SignCertInputFromDataFields) UseCSRValues() bool {
 	return i.useCSRValues
 }
 
-func (i SignCertInputFromDataFields) GetPermittedDomains() []string {
+func NewNameConstraintsInputFromFieldData(data *framework.FieldData) NameConstraintsInputFromFieldData {
+	return NameConstraintsInputFromFieldData{data: data}
+}
+
+var _ issuing.NameConstraintsInput = NameConstraintsInputFromFieldData{}
+
+type NameConstraintsInputFromFieldData struct {
+	data *framework.FieldData
+}
+
+func (i NameConstraintsInputFromFieldData) GetPermittedDomains() []string {
 	return i.data.Get("permitted_dns_domains").([]string)
 }
 
-func signCert(b *backend, data *inputBundle, caSign *certutil.CAInfoBundle, isCA bool, useCSRValues bool) (*certutil.ParsedCertBundle, []string, error) {
+func (i NameConstraintsInputFromFieldData) GetExcludedDomains() []string {
+	return i.data.Get("excluded_dns_domains").([]string)
+}
+
+func (i NameConstraintsInputFromFieldData) GetPermittedIpRanges() []string {
+	return i.data.Get("permitted_ip_ranges").([]string)
+}
+
+func (i NameConstraintsInputFromFieldData) GetExcludedIpRanges() []string {
+	return i.data.Get("excluded_ip_ranges").([]string)
+}
+
+func (i NameConstraintsInputFromFieldData) GetPermittedEmailAddresses() []string {
+	return i.data.Get("permitted_email_addresses").([]string)
+}
+
+func (i NameConstraintsInputFromFieldData) GetExcludedEmailAddresses() []string {
+	return i.data.Get("excluded_email_addresses").([]string)
+}
+
+func (i NameConstraintsInputFromFieldData) GetPermittedUriDomains() []string {
+	return i.data.Get("permitted_uri_domains").([]string)
+}
+
+func (i NameConstraintsInputFromFieldData) GetExcludedUriDomains() []string {
+	return i.data.Get("excluded_uri_domains").([]string)
+}
+
+func signCert(ctx context.Context, b *backend, data *inputBundle, caSign *certutil.CAInfoBundle, isCA bool, useCSRValues bool) (*certutil.ParsedCertBundle, []string, error) {
 	if data.role == nil {
 		return nil, nil, errutil.InternalError{Err: "no role found in data bundle"}
 	}
@@ -476,7 +531,7 @@ func signCert(b *backend, data *inputBundle, caSign *certutil.CAInfoBundle, isCA
 	entityInfo := issuing.NewEntityInfoFromReq(data.req)
 	signCertInput := NewSignCertInputFromDataFields(data.apiData, isCA, useCSRValues)
 
-	return issuing.SignCert(b.System(), data.role, entityInfo, caSign, signCertInput)
+	return issuing.SignCert(ctx, b.System(), data.role, entityInfo, caSign, signCertInput)
 }
 
 func getOtherSANsFromX509Extensions(exts []pkix.Extension) ([]certutil.OtherNameUtf8, error) {
@@ -327,56 +327,69 @@ func TestParseCertificate(t *testing.T) {
 				SKID:                          []byte("We'll assert that it is not nil as an special case"),
 			},
 			wantFields: map[string]interface{}{
-				"common_name":           "the common name",
-				"alt_names":             "",
-				"ip_sans":               "",
-				"uri_sans":              "",
-				"other_sans":            "",
-				"signature_bits":        384,
-				"exclude_cn_from_sans":  true,
-				"ou":                    "",
-				"organization":          "",
-				"country":               "",
-				"locality":              "",
-				"province":              "",
-				"street_address":        "",
-				"postal_code":           "",
-				"serial_number":         "",
-				"ttl":                   "1h0m30s",
-				"max_path_length":       -1,
-				"permitted_dns_domains": "",
-				"use_pss":               false,
-				"key_type":              "ec",
-				"key_bits":              384,
-				"skid":                  "We'll assert that it is not nil as an special case",
+				"common_name":               "the common name",
+				"alt_names":                 "",
+				"ip_sans":                   "",
+				"uri_sans":                  "",
+				"other_sans":                "",
+				"signature_bits":            384,
+				"exclude_cn_from_sans":      true,
+				"ou":                        "",
+				"organization":              "",
+				"country":                   "",
+				"locality":                  "",
+				"province":                  "",
+				"street_address":            "",
+				"postal_code":               "",
+				"serial_number":             "",
+				"ttl":                       "1h0m30s",
+				"max_path_length":           -1,
+				"permitted_dns_domains":     "",
+				"excluded_dns_domains":      "",
+				"permitted_ip_ranges":       "",
+				"excluded_ip_ranges":        "",
+				"permitted_email_addresses": "",
+				"excluded_email_addresses":  "",
+				"permitted_uri_domains":     "",
+				"excluded_uri_domains":      "",
+				"use_pss":                   false,
+				"key_type":                  "ec",
+				"key_bits":                  384,
+				"skid":                      "We'll assert that it is not nil as an special case",
 			},
 			wantErr: false,
 		},
 		{
-			// Note that this test's data is used to create the internal CA used by test "full non CA cert"
 			name: "full CA",
 			data: map[string]interface{}{
 				// using the same order as in https://developer.hashicorp.com/vault/api-docs/secret/pki#sign-certificate
-				"common_name":           "the common name",
-				"alt_names":             "user@example.com,admin@example.com,example.com,www.example.com",
-				"ip_sans":               "1.2.3.4,1.2.3.5",
-				"uri_sans":              "https://example.com,https://www.example.com",
-				"other_sans":            "1.3.6.1.4.1.311.20.2.3;utf8:caadmin@example.com",
-				"ttl":                   "2h",
-				"max_path_length":       2,
-				"permitted_dns_domains": ".example.com,.www.example.com",
-				"ou":                    "unit1, unit2",
-				"organization":          "org1, org2",
-				"country":               "US, CA",
-				"locality":              "locality1, locality2",
-				"province":              "province1, province2",
-				"street_address":        "street_address1, street_address2",
-				"postal_code":           "postal_code1, postal_code2",
-				"not_before_duration":   "45s",
-				"key_type":              "rsa",
-				"use_pss":               true,
-				"key_bits":              2048,
-				"signature_bits":        384,
+				"common_name":               "the common name",
+				"alt_names":                 "user@example.com,admin@example.com,example.com,www.example.com",
+				"ip_sans":                   "1.2.3.4,1.2.3.5",
+				"uri_sans":                  "https://example.com,https://www.example.com",
+				"other_sans":                "1.3.6.1.4.1.311.20.2.3;utf8:caadmin@example.com",
+				"ttl":                       "2h",
+				"max_path_length":           2,
+				"permitted_dns_domains":     ".example.com,.www.example.com",
+				"excluded_dns_domains":      ".internal.example.com",
+				"permitted_ip_ranges":       "1.2.3.0/24",
+				"excluded_ip_ranges":        "1.2.3.128/25",
+				"permitted_email_addresses": "example.com",
+				"excluded_email_addresses":  "root@example.com",
+				"permitted_uri_domains":     "example.com,.example.com",
+				"excluded_uri_domains":      "internal.example.com",
+				"ou":                        "unit1, unit2",
+				"organization":              "org1, org2",
+				"country":                   "US, CA",
+				"locality":                  "locality1, locality2",
+				"province":                  "province1, province2",
+				"street_address":            "street_address1, street_address2",
+				"postal_code":               "postal_code1, postal_code2",
+				"not_before_duration":       "45s",
+				"key_type":                  "rsa",
+				"use_pss":                   true,
+				"key_bits":                  2048,
+				"signature_bits":            384,
 				// TODO(kitography): Specify key usage
 			},
 			ttl: 2 * time.Hour,
@@ -410,39 +423,128 @@ func TestParseCertificate(t *testing.T) {
 				ForceAppendCaChain:            false,
 				UseCSRValues:                  false,
 				PermittedDNSDomains:           []string{".example.com", ".www.example.com"},
+				ExcludedDNSDomains:            []string{".internal.example.com"},
+				PermittedIPRanges:             []*net.IPNet{{IP: net.IP{1, 2, 3, 0}, Mask: net.CIDRMask(24, 32)}},
+				ExcludedIPRanges:              []*net.IPNet{{IP: net.IP{1, 2, 3, 128}, Mask: net.CIDRMask(25, 32)}},
+				PermittedEmailAddresses:       []string{"example.com"},
+				ExcludedEmailAddresses:        []string{"root@example.com"},
+				PermittedURIDomains:           []string{"example.com", ".example.com"},
+				ExcludedURIDomains:            []string{"internal.example.com"},
 				URLs:                          nil,
 				MaxPathLength:                 2,
 				NotBeforeDuration:             45 * time.Second,
 				SKID:                          []byte("We'll assert that it is not nil as an special case"),
 			},
 			wantFields: map[string]interface{}{
-				"common_name":           "the common name",
-				"alt_names":             "example.com,www.example.com,admin@example.com,user@example.com",
-				"ip_sans":               "1.2.3.4,1.2.3.5",
-				"uri_sans":              "https://example.com,https://www.example.com",
-				"other_sans":            "1.3.6.1.4.1.311.20.2.3;UTF-8:caadmin@example.com",
-				"signature_bits":        384,
-				"exclude_cn_from_sans":  true,
-				"ou":                    "unit1,unit2",
-				"organization":          "org1,org2",
-				"country":               "CA,US",
-				"locality":              "locality1,locality2",
-				"province":              "province1,province2",
-				"street_address":        "street_address1,street_address2",
-				"postal_code":           "postal_code1,postal_code2",
-				"serial_number":         "",
-				"ttl":                   "2h0m45s",
-				"max_path_length":       2,
-				"permitted_dns_domains": ".example.com,.www.example.com",
-				"use_pss":               true,
+				"common_name":               "the common name",
+				"alt_names":                 "example.com,www.example.com,admin@example.com,user@example.com",
+				"ip_sans":                   "1.2.3.4,1.2.3.5",
+				"uri_sans":                  "https://example.com,https://www.example.com",
+				"other_sans":                "1.3.6.1.4.1.311.20.2.3;UTF-8:caadmin@example.com",
+				"signature_bits":            384,
+				"exclude_cn_from_sans":      true,
+				"ou":                        "unit1,unit2",
+				"organization":              "org1,org2",
+				"country":                   "CA,US",
+				"locality":                  "locality1,locality2",
+				"province":                  "province1,province2",
+				"street_address":            "street_address1,street_address2",
+				"postal_code":               "postal_code1,postal_code2",
+				"serial_number":             "",
+				"ttl":                       "2h0m45s",
+				"max_path_length":           2,
+				"permitted_dns_domains":     ".example.com,.www.example.com",
+				"excluded_dns_domains":      ".internal.example.com",
+				"permitted_ip_ranges":       "1.2.3.0/24",
+				"excluded_ip_ranges":        "1.2.3.128/25",
+				"permitted_email_addresses": "example.com",
+				"excluded_email_addresses":  "root@example.com",
+				"permitted_uri_domains":     "example.com,.example.com",
+				"excluded_uri_domains":      "internal.example.com",
+				"use_pss":                   true,
+				"key_type":                  "rsa",
+				"key_bits":                  2048,
+				"skid":                      "We'll assert that it is not nil as an special case",
+			},
+			wantErr: false,
+		},
+		{
+			// Note that this test's data is used to create the internal CA used by test "full non CA cert"
+			name: "CA with bare permitted DNS domain",
+			data: map[string]interface{}{
+				"common_name":           "the bare domain CA",
 				"key_type":              "rsa",
 				"key_bits":              2048,
-				"skid":                  "We'll assert that it is not nil as an special case",
+				"ttl":                   "2h",
+				"permitted_dns_domains": "example.com",
+			},
+			ttl: 2 * time.Hour,
+			wantParams: certutil.CreationParameters{
+				Subject: pkix.Name{
+					CommonName: "the bare domain CA",
+				},
+				DNSNames:                      nil,
+				EmailAddresses:                nil,
+				IPAddresses:                   nil,
+				URIs:                          nil,
+				OtherSANs:                     make(map[string][]string),
+				IsCA:                          true,
+				KeyType:                       "rsa",
+				KeyBits:                       2048,
+				NotAfter:                      time.Time{},
+				KeyUsage:                      x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+				ExtKeyUsage:                   0,
+				ExtKeyUsageOIDs:               nil,
+				PolicyIdentifiers:             nil,
+				BasicConstraintsValidForNonCA: false,
+				SignatureBits:                 256,
+				UsePSS:                        false,
+				ForceAppendCaChain:            false,
+				UseCSRValues:                  false,
+				PermittedDNSDomains:           []string{"example.com"},
+				URLs:                          nil,
+				MaxPathLength:                 -1,
+				NotBeforeDuration:             30,
+				SKID:                          []byte("We'll assert that it is not nil as an special case"),
+			},
+			wantFields: map[string]interface{}{
+				"common_name":               "the bare domain CA",
+				"alt_names":                 "",
+				"ip_sans":                   "",
+				"uri_sans":                  "",
+				"other_sans":                "",
+				"signature_bits":            256,
+				"exclude_cn_from_sans":      true,
+				"ou":                        "",
+				"organization":              "",
+				"country":                   "",
+				"locality":                  "",
+				"province":                  "",
+				"street_address":            "",
+				"postal_code":               "",
+				"serial_number":             "",
+				"ttl":                       "2h0m30s",
+				"max_path_length":           -1,
+				"permitted_dns_domains":     "example.com",
+				"excluded_dns_domains":      "",
+				"permitted_ip_ranges":       "",
+				"excluded_ip_ranges":        "",
+				"permitted_email_addresses": "",
+				"excluded_email_addresses":  "",
+				"permitted_uri_domains":     "",
+				"excluded_uri_domains":      "",
+				"use_pss":                   false,
+				"key_type":                  "rsa",
+				"key_bits":                  2048,
+				"skid":                      "We'll assert that it is not nil as an special case",
 			},
 			wantErr: false,
 		},
 		{
-			// Note that we use the data of test "full CA" to create the internal CA needed for this test
+			// Note that we use the data of test "CA with bare permitted DNS domain" to create the internal CA
+			// needed for this test. The "full CA" only permits subdomains of .example.com, and name
+			// constraints are now enforced when issuing, so it can't issue this certificate for the bare
+			// example.com name.
 			name: "full non CA cert",
 			data: map[string]interface{}{
 				// using the same order as in https://developer.hashicorp.com/vault/api-docs/secret/pki#generate-certificate-and-key
@@ -505,28 +607,35 @@ func TestParseCertificate(t *testing.T) {
 				SKID:                          []byte("We'll assert that it is not nil as an special case"),
 			},
 			wantFields: map[string]interface{}{
-				"common_name":           "the common name non ca",
-				"alt_names":             "example.com,www.example.com,admin@example.com,user@example.com",
-				"ip_sans":               "1.2.3.4,1.2.3.5",
-				"uri_sans":              "https://example.com,https://www.example.com",
-				"other_sans":            "1.3.6.1.4.1.311.20.2.3;UTF-8:caadmin@example.com",
-				"signature_bits":        384,
-				"exclude_cn_from_sans":  true,
-				"ou":                    "",
-				"organization":          "",
-				"country":               "",
-				"locality":              "",
-				"province":              "",
-				"street_address":        "",
-				"postal_code":           "",
-				"serial_number":         "",
-				"ttl":                   "2h0m45s",
-				"max_path_length":       0,
-				"permitted_dns_domains": "",
-				"use_pss":               false,
-				"key_type":              "rsa",
-				"key_bits":              2048,
-				"skid":                  "We'll assert that it is not nil as an special case",
+				"common_name":               "the common name non ca",
+				"alt_names":                 "example.com,www.example.com,admin@example.com,user@example.com",
+				"ip_sans":                   "1.2.3.4,1.2.3.5",
+				"uri_sans":                  "https://example.com,https://www.example.com",
+				"other_sans":                "1.3.6.1.4.1.311.20.2.3;UTF-8:caadmin@example.com",
+				"signature_bits":            384,
+				"exclude_cn_from_sans":      true,
+				"ou":                        "",
+				"organization":              "",
+				"country":                   "",
+				"locality":                  "",
+				"province":                  "",
+				"street_address":            "",
+				"postal_code":               "",
+				"serial_number":             "",
+				"ttl":                       "2h0m45s",
+				"max_path_length":           0,
+				"permitted_dns_domains":     "",
+				"excluded_dns_domains":      "",
+				"permitted_ip_ranges":       "",
+				"excluded_ip_ranges":        "",
+				"permitted_email_addresses": "",
+				"excluded_email_addresses":  "",
+				"permitted_uri_domains":     "",
+				"excluded_uri_domains":      "",
+				"use_pss":                   false,
+				"key_type":                  "rsa",
+				"key_bits":                  2048,
+				"skid":                      "We'll assert that it is not nil as an special case",
 			},
 			wantErr: false,
 		},
@@ -547,8 +656,9 @@ func TestParseCertificate(t *testing.T) {
 			require.NoError(t, err)
 			require.NotNil(t, cert)
 		} else {
-			// use the "simple CA" data to create the internal CA
-			caData := tests[1].data
+			// use the "CA with bare permitted DNS domain" data to create the internal CA, as the name
+			// constraints of the "full CA" exclude the bare example.com name of the certificate
+			caData := tests[2].data
 			caData["ttl"] = "3h"
 			resp, err := CBWrite(b, s, "root/generate/internal", caData)
 			require.NoError(t, err)
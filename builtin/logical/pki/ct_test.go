@@ -0,0 +1,554 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package pki
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/builtin/logical/pki/issuing"
+	"github.com/hashicorp/vault/sdk/helper/certutil"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/cryptobyte"
+)
+
+// testCTLog is a minimal RFC 6962 log accepting pre-certificates, used as a
+// local stand-in for real Certificate Transparency logs.
+type testCTLog struct {
+	server      *httptest.Server
+	key         *ecdsa.PrivateKey
+	submissions atomic.Int32
+	fail        bool
+}
+
+func newTestCTLog(t *testing.T, fail bool) *testCTLog {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	log := &testCTLog{key: key, fail: fail}
+	log.server = httptest.NewServer(http.HandlerFunc(log.handleAddPreChain))
+	t.Cleanup(log.server.Close)
+
+	return log
+}
+
+func (l *testCTLog) publicKeyPEM(t *testing.T) string {
+	keyBytes, err := x509.MarshalPKIXPublicKey(&l.key.PublicKey)
+	require.NoError(t, err)
+	return strings.TrimSpace(string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: keyBytes})))
+}
+
+func (l *testCTLog) handleAddPreChain(w http.ResponseWriter, r *http.Request) {
+	l.submissions.Add(1)
+	if l.fail {
+		http.Error(w, "log unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	if r.Method != http.MethodPost || r.URL.Path != "/ct/v1/add-pre-chain" {
+		http.NotFound(w, r)
+		return
+	}
+
+	var req struct {
+		Chain []string `json:"chain"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.Chain) < 2 {
+		http.Error(w, "invalid chain", http.StatusBadRequest)
+		return
+	}
+
+	var chain []*x509.Certificate
+	for _, encoded := range req.Chain {
+		certBytes, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			http.Error(w, "invalid chain", http.StatusBadRequest)
+			return
+		}
+		cert, err := x509.ParseCertificate(certBytes)
+		if err != nil {
+			http.Error(w, "invalid chain", http.StatusBadRequest)
+			return
+		}
+		chain = append(chain, cert)
+	}
+
+	precert, issuer := chain[0], chain[1]
+	if precert.CheckSignatureFrom(issuer) != nil {
+		http.Error(w, "pre-certificate not signed by issuer", http.StatusBadRequest)
+		return
+	}
+
+	poisoned := false
+	for _, ext := range precert.Extensions {
+		if ext.Id.Equal(issuing.ExtensionCTPoisonOID) && ext.Critical {
+			poisoned = true
+		}
+	}
+	if !poisoned {
+		http.Error(w, "missing poison extension", http.StatusBadRequest)
+		return
+	}
+
+	tbs, err := issuing.RemoveTBSExtension(precert.RawTBSCertificate, issuing.ExtensionCTPoisonOID)
+	if err != nil {
+		http.Error(w, "invalid pre-certificate", http.StatusBadRequest)
+		return
+	}
+
+	keyBytes, _ := x509.MarshalPKIXPublicKey(&l.key.PublicKey)
+	logID := sha256.Sum256(keyBytes)
+	issuerKeyHash := sha256.Sum256(issuer.RawSubjectPublicKeyInfo)
+	timestamp := uint64(time.Now().UnixMilli())
+
+	var signed cryptobyte.Builder
+	signed.AddUint8(0) // v1
+	signed.AddUint8(0) // certificate_timestamp
+	signed.AddUint64(timestamp)
+	signed.AddUint16(1) // precert_entry
+	signed.AddBytes(issuerKeyHash[:])
+	signed.AddUint24LengthPrefixed(func(child *cryptobyte.Builder) {
+		child.AddBytes(tbs)
+	})
+	signed.AddUint16(0) // no extensions
+	digest := sha256.Sum256(signed.BytesOrPanic())
+
+	signature, err := l.key.Sign(rand.Reader, digest[:], crypto.SHA256)
+	if err != nil {
+		http.Error(w, "failed signing", http.StatusInternalServerError)
+		return
+	}
+
+	var digitallySigned cryptobyte.Builder
+	digitallySigned.AddUint8(4) // sha256
+	digitallySigned.AddUint8(3) // ecdsa
+	digitallySigned.AddUint16LengthPrefixed(func(child *cryptobyte.Builder) {
+		child.AddBytes(signature)
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"sct_version": 0,
+		"id":          base64.StdEncoding.EncodeToString(logID[:]),
+		"timestamp":   timestamp,
+		"extensions":  "",
+		"signature":   base64.StdEncoding.EncodeToString(digitallySigned.BytesOrPanic()),
+	})
+}
+
+// requireValidEmbeddedSCTs verifies the SCTs embedded in the certificate
+// against the given logs, as a relying party would.
+func requireValidEmbeddedSCTs(t *testing.T, cert *x509.Certificate, issuer *x509.Certificate, logs ...*testCTLog) {
+	t.Helper()
+
+	scts, err := issuing.ParseSCTList(cert)
+	require.NoError(t, err)
+	require.Len(t, scts, len(logs))
+
+	tbs, err := issuing.RemoveTBSExtension(cert.RawTBSCertificate, issuing.ExtensionCTSCTListOID)
+	require.NoError(t, err)
+	issuerKeyHash := sha256.Sum256(issuer.RawSubjectPublicKeyInfo)
+
+	for index, sct := range scts {
+		require.NoError(t, issuing.VerifySCT(sct, &logs[index].key.PublicKey, issuerKeyHash[:], tbs))
+	}
+}
+
+func TestCT_IssuerConfig(t *testing.T) {
+	t.Parallel()
+	b, s := CreateBackendWithStorage(t)
+
+	resp, err := CBWrite(b, s, "root/generate/internal", map[string]interface{}{
+		"common_name": "root example.com",
+		"issuer_name": "root",
+	})
+	requireSuccessNonNilResponse(t, resp, err)
+
+	log := newTestCTLog(t, false)
+
+	resp, err = CBRead(b, s, "issuer/root")
+	requireSuccessNonNilResponse(t, resp, err)
+	require.Empty(t, resp.Data["ct_logs"])
+	require.Equal(t, 0, resp.Data["ct_required_scts"])
+	require.Equal(t, int64(10), resp.Data["ct_log_timeout"])
+	require.Equal(t, issuing.CTFailurePolicyFailClosed, resp.Data["ct_failure_policy"])
+
+	// Invalid configurations are rejected.
+	_, err = CBPatch(b, s, "issuer/root", map[string]interface{}{
+		"ct_logs": "not a url",
+	})
+	require.ErrorContains(t, err, "invalid URL")
+
+	_, err = CBPatch(b, s, "issuer/root", map[string]interface{}{
+		"ct_logs":            log.server.URL,
+		"ct_log_public_keys": []string{log.publicKeyPEM(t), log.publicKeyPEM(t)},
+	})
+	require.ErrorContains(t, err, "one public key per log")
+
+	_, err = CBPatch(b, s, "issuer/root", map[string]interface{}{
+		"ct_logs":            log.server.URL,
+		"ct_log_public_keys": "bm90IGEga2V5",
+	})
+	require.ErrorContains(t, err, "invalid public key")
+
+	_, err = CBPatch(b, s, "issuer/root", map[string]interface{}{
+		"ct_logs":          log.server.URL,
+		"ct_required_scts": 2,
+	})
+	require.ErrorContains(t, err, "exceeds the number of logs")
+
+	_, err = CBPatch(b, s, "issuer/root", map[string]interface{}{
+		"ct_failure_policy": "fail_open",
+	})
+	require.ErrorContains(t, err, "ct_failure_policy must be")
+
+	resp, err = CBPatch(b, s, "issuer/root", map[string]interface{}{
+		"ct_logs":            log.server.URL,
+		"ct_log_public_keys": log.publicKeyPEM(t),
+		"ct_required_scts":   1,
+	})
+	requireSuccessNonNilResponse(t, resp, err)
+	require.Equal(t, []string{log.server.URL}, resp.Data["ct_logs"])
+	require.Equal(t, []string{log.publicKeyPEM(t)}, resp.Data["ct_log_public_keys"])
+	require.Equal(t, 1, resp.Data["ct_required_scts"])
+
+	// Patching only the policy keeps the logs.
+	resp, err = CBPatch(b, s, "issuer/root", map[string]interface{}{
+		"ct_required_scts": 0,
+	})
+	requireSuccessNonNilResponse(t, resp, err)
+	require.Equal(t, []string{log.server.URL}, resp.Data["ct_logs"])
+	require.Equal(t, 0, resp.Data["ct_required_scts"])
+
+	resp, err = CBPatch(b, s, "issuer/root", map[string]interface{}{
+		"ct_log_timeout":    "3s",
+		"ct_failure_policy": issuing.CTFailurePolicyIssueWithoutSCTs,
+	})
+	requireSuccessNonNilResponse(t, resp, err)
+	require.Equal(t, []string{log.server.URL}, resp.Data["ct_logs"])
+	require.Equal(t, int64(3), resp.Data["ct_log_timeout"])
+	require.Equal(t, issuing.CTFailurePolicyIssueWithoutSCTs, resp.Data["ct_failure_policy"])
+
+	// Updating the issuer without the CT parameters removes them.
+	resp, err = CBWrite(b, s, "issuer/root", map[string]interface{}{})
+	requireSuccessNonNilResponse(t, resp, err)
+	require.Empty(t, resp.Data["ct_logs"])
+	require.Empty(t, resp.Data["ct_log_public_keys"])
+	require.Equal(t, int64(10), resp.Data["ct_log_timeout"])
+	require.Equal(t, issuing.CTFailurePolicyFailClosed, resp.Data["ct_failure_policy"])
+}
+
+func TestCT_EmbedSCTs(t *testing.T) {
+	t.Parallel()
+	b, s := CreateBackendWithStorage(t)
+
+	resp, err := CBWrite(b, s, "root/generate/internal", map[string]interface{}{
+		"common_name": "root example.com",
+		"issuer_name": "root",
+		"key_type":    "ec",
+	})
+	requireSuccessNonNilResponse(t, resp, err)
+	rootCert := parseCert(t, resp.Data["certificate"].(string))
+
+	_, err = CBWrite(b, s, "roles/test", map[string]interface{}{
+		"allowed_domains":  "example.com",
+		"allow_subdomains": true,
+		"key_type":         "ec",
+		"ttl":              "1h",
+	})
+	require.NoError(t, err)
+
+	// Without logs, certificates don't carry SCTs.
+	resp, err = CBWrite(b, s, "issue/test", map[string]interface{}{
+		"common_name": "www.example.com",
+	})
+	requireSuccessNonNilResponse(t, resp, err)
+	scts, err := issuing.ParseSCTList(parseCert(t, resp.Data["certificate"].(string)))
+	require.NoError(t, err)
+	require.Empty(t, scts)
+
+	logA := newTestCTLog(t, false)
+	logB := newTestCTLog(t, false)
+	_, err = CBPatch(b, s, "issuer/root", map[string]interface{}{
+		"ct_logs":            []string{logA.server.URL, logB.server.URL},
+		"ct_log_public_keys": []string{logA.publicKeyPEM(t), logB.publicKeyPEM(t)},
+	})
+	require.NoError(t, err)
+
+	// Issuance embeds an SCT from each log.
+	resp, err = CBWrite(b, s, "issue/test", map[string]interface{}{
+		"common_name": "www.example.com",
+	})
+	requireSuccessNonNilResponse(t, resp, err)
+	require.Empty(t, resp.Warnings)
+	cert := parseCert(t, resp.Data["certificate"].(string))
+	require.NoError(t, cert.CheckSignatureFrom(rootCert))
+	require.Equal(t, []string{"www.example.com"}, cert.DNSNames)
+	requireValidEmbeddedSCTs(t, cert, rootCert, logA, logB)
+
+	// The stored certificate is the one with the SCTs.
+	resp, err = CBRead(b, s, "cert/"+resp.Data["serial_number"].(string))
+	requireSuccessNonNilResponse(t, resp, err)
+	require.True(t, bytes.Equal(cert.Raw, parseCert(t, resp.Data["certificate"].(string)).Raw))
+
+	// So does signing a CSR.
+	csrKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	csrBytes, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject: pkix.Name{CommonName: "api.example.com"},
+	}, csrKey)
+	require.NoError(t, err)
+	csr := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: csrBytes}))
+	resp, err = CBWrite(b, s, "sign/test", map[string]interface{}{
+		"csr": csr,
+	})
+	requireSuccessNonNilResponse(t, resp, err)
+	requireValidEmbeddedSCTs(t, parseCert(t, resp.Data["certificate"].(string)), rootCert, logA, logB)
+
+	// CA certificates aren't submitted to logs.
+	submissions := logA.submissions.Load()
+	resp, err = CBWrite(b, s, "root/sign-intermediate", map[string]interface{}{
+		"common_name": "intermediate example.com",
+		"csr":         csr,
+	})
+	requireSuccessNonNilResponse(t, resp, err)
+	require.Equal(t, submissions, logA.submissions.Load())
+
+	// A failing log fails issuance when its SCT is required...
+	failing := newTestCTLog(t, true)
+	_, err = CBPatch(b, s, "issuer/root", map[string]interface{}{
+		"ct_logs":            []string{logA.server.URL, failing.server.URL},
+		"ct_log_public_keys": []string{logA.publicKeyPEM(t), failing.publicKeyPEM(t)},
+	})
+	require.NoError(t, err)
+
+	_, err = CBWrite(b, s, "issue/test", map[string]interface{}{
+		"common_name": "www.example.com",
+	})
+	require.ErrorContains(t, err, "unable to obtain the 2 SCTs required")
+	require.ErrorContains(t, err, failing.server.URL)
+
+	// ... unless the failure policy allows issuing without SCTs...
+	_, err = CBPatch(b, s, "issuer/root", map[string]interface{}{
+		"ct_failure_policy": issuing.CTFailurePolicyIssueWithoutSCTs,
+	})
+	require.NoError(t, err)
+
+	resp, err = CBWrite(b, s, "issue/test", map[string]interface{}{
+		"common_name": "www.example.com",
+	})
+	requireSuccessNonNilResponse(t, resp, err)
+	require.NotEmpty(t, resp.Warnings)
+	require.Contains(t, resp.Warnings[0], "issued without SCTs")
+	cert = parseCert(t, resp.Data["certificate"].(string))
+	require.NoError(t, cert.CheckSignatureFrom(rootCert))
+	scts, err = issuing.ParseSCTList(cert)
+	require.NoError(t, err)
+	require.Empty(t, scts)
+
+	_, err = CBPatch(b, s, "issuer/root", map[string]interface{}{
+		"ct_failure_policy": issuing.CTFailurePolicyFailClosed,
+	})
+	require.NoError(t, err)
+
+	// ... but only warns when the policy is otherwise satisfied.
+	_, err = CBPatch(b, s, "issuer/root", map[string]interface{}{
+		"ct_required_scts": 1,
+	})
+	require.NoError(t, err)
+
+	resp, err = CBWrite(b, s, "issue/test", map[string]interface{}{
+		"common_name": "www.example.com",
+	})
+	requireSuccessNonNilResponse(t, resp, err)
+	require.NotEmpty(t, resp.Warnings)
+	require.Contains(t, resp.Warnings[0], failing.server.URL)
+	requireValidEmbeddedSCTs(t, parseCert(t, resp.Data["certificate"].(string)), rootCert, logA)
+
+	// SCTs that don't verify against the configured key are rejected.
+	_, err = CBPatch(b, s, "issuer/root", map[string]interface{}{
+		"ct_logs":            []string{logA.server.URL},
+		"ct_log_public_keys": []string{logB.publicKeyPEM(t)},
+		"ct_required_scts":   0,
+	})
+	require.NoError(t, err)
+
+	_, err = CBWrite(b, s, "issue/test", map[string]interface{}{
+		"common_name": "www.example.com",
+	})
+	require.ErrorContains(t, err, "log ID does not match")
+
+	// Logs which don't answer within the timeout count as failed.
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(30 * time.Second):
+		}
+	}))
+	t.Cleanup(slow.Close)
+	_, err = CBPatch(b, s, "issuer/root", map[string]interface{}{
+		"ct_logs":            []string{slow.URL},
+		"ct_log_public_keys": []string{},
+		"ct_log_timeout":     1,
+	})
+	require.NoError(t, err)
+
+	start := time.Now()
+	_, err = CBWrite(b, s, "issue/test", map[string]interface{}{
+		"common_name": "www.example.com",
+	})
+	require.ErrorContains(t, err, "context deadline exceeded")
+	require.Less(t, time.Since(start), 10*time.Second)
+}
+
+// countingSigner counts the signatures made with the issuer's key.
+type countingSigner struct {
+	crypto.Signer
+	signatures atomic.Int32
+}
+
+func (s *countingSigner) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	s.signatures.Add(1)
+	return s.Signer.Sign(rand, digest, opts)
+}
+
+// TestCT_SignsOnlyPrecertificateAndFinal verifies that the issuer's key
+// signs the pre-certificate and the final certificate, and never a plain
+// certificate without either CT extension.
+func TestCT_SignsOnlyPrecertificateAndFinal(t *testing.T) {
+	t.Parallel()
+
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "root example.com"},
+		NotBefore:             time.Now().Add(-time.Minute),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	caBytes, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, caKey.Public(), caKey)
+	require.NoError(t, err)
+	caCert, err := x509.ParseCertificate(caBytes)
+	require.NoError(t, err)
+
+	leafKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	leafTemplate := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "www.example.com"},
+		DNSNames:     []string{"www.example.com"},
+		NotBefore:    time.Now().Add(-time.Minute),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+
+	log := newTestCTLog(t, false)
+	failing := newTestCTLog(t, true)
+
+	for name, tc := range map[string]struct {
+		logs          []*testCTLog
+		failurePolicy string
+		wantSCTs      int
+	}{
+		"with SCTs":    {logs: []*testCTLog{log}, wantSCTs: 1},
+		"without SCTs": {logs: []*testCTLog{failing}, failurePolicy: issuing.CTFailurePolicyIssueWithoutSCTs},
+	} {
+		t.Run(name, func(t *testing.T) {
+			signer := &countingSigner{Signer: caKey}
+			caSign := &certutil.CAInfoBundle{
+				ParsedCertBundle: certutil.ParsedCertBundle{
+					Certificate:      caCert,
+					CertificateBytes: caBytes,
+					PrivateKey:       signer,
+					PrivateKeyType:   certutil.ECPrivateKey,
+				},
+				CTFailurePolicy: tc.failurePolicy,
+			}
+			for _, log := range tc.logs {
+				caSign.CTLogs = append(caSign.CTLogs, certutil.CTLog{URL: log.server.URL})
+			}
+
+			ctSigner := issuing.NewCTSigner(context.Background(), caSign)
+			certBytes, err := ctSigner.SignCertificate(rand.Reader, leafTemplate, leafKey.Public())
+			require.NoError(t, err)
+			require.Equal(t, int32(2), signer.signatures.Load())
+
+			cert, err := x509.ParseCertificate(certBytes)
+			require.NoError(t, err)
+			require.NoError(t, cert.CheckSignatureFrom(caCert))
+			for _, ext := range cert.Extensions {
+				require.False(t, ext.Id.Equal(issuing.ExtensionCTPoisonOID))
+			}
+			scts, err := issuing.ParseSCTList(cert)
+			require.NoError(t, err)
+			require.Len(t, scts, tc.wantSCTs)
+			if tc.wantSCTs > 0 {
+				// None of the logs carry a public key, so their SCTs are
+				// embedded unverified.
+				require.Len(t, ctSigner.Warnings, tc.wantSCTs)
+				require.Contains(t, ctSigner.Warnings[0], "without verifying it")
+			}
+		})
+	}
+}
+
+func TestCT_EmbedSCTsRSA(t *testing.T) {
+	t.Parallel()
+	b, s := CreateBackendWithStorage(t)
+
+	resp, err := CBWrite(b, s, "root/generate/internal", map[string]interface{}{
+		"common_name": "root example.com",
+		"issuer_name": "root",
+		"key_type":    "rsa",
+		"use_pss":     true,
+	})
+	requireSuccessNonNilResponse(t, resp, err)
+	rootCert := parseCert(t, resp.Data["certificate"].(string))
+
+	_, err = CBWrite(b, s, "roles/test", map[string]interface{}{
+		"allow_any_name": true,
+		"use_pss":        true,
+		"ttl":            "1h",
+	})
+	require.NoError(t, err)
+
+	log := newTestCTLog(t, false)
+	_, err = CBPatch(b, s, "issuer/root", map[string]interface{}{
+		"ct_logs":            log.server.URL,
+		"ct_log_public_keys": log.publicKeyPEM(t),
+	})
+	require.NoError(t, err)
+
+	resp, err = CBWrite(b, s, "issue/test", map[string]interface{}{
+		"common_name": "www.example.com",
+		"alt_names":   "user@example.com",
+		"ip_sans":     "10.0.0.1",
+	})
+	requireSuccessNonNilResponse(t, resp, err)
+	cert := parseCert(t, resp.Data["certificate"].(string))
+	require.Equal(t, x509.SHA256WithRSAPSS, cert.SignatureAlgorithm)
+	require.NoError(t, cert.CheckSignatureFrom(rootCert))
+	require.Equal(t, []string{"user@example.com"}, cert.EmailAddresses)
+	require.Len(t, cert.IPAddresses, 1)
+	requireValidEmbeddedSCTs(t, cert, rootCert, log)
+}
@@ -0,0 +1,540 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package issuing
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-cleanhttp"
+	"github.com/hashicorp/vault/sdk/helper/certutil"
+	"github.com/hashicorp/vault/sdk/helper/errutil"
+	"golang.org/x/crypto/cryptobyte"
+)
+
+var (
+	// ExtensionCTPoisonOID marks a certificate as an RFC 6962 pre-certificate,
+	// which relying parties must not accept.
+	ExtensionCTPoisonOID = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 11129, 2, 4, 3}
+
+	// ExtensionCTSCTListOID holds the SCTs embedded in a certificate.
+	ExtensionCTSCTListOID = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 11129, 2, 4, 2}
+)
+
+const (
+	// DefaultCTLogTimeout bounds how long issuance waits on each CT log
+	// when the issuer doesn't configure a timeout.
+	DefaultCTLogTimeout = 10 * time.Second
+
+	// CTFailurePolicyFailClosed fails issuance when fewer SCTs than
+	// required were obtained; this is the default.
+	CTFailurePolicyFailClosed = "fail_closed"
+
+	// CTFailurePolicyIssueWithoutSCTs issues the certificate without SCTs
+	// when fewer SCTs than required were obtained.
+	CTFailurePolicyIssueWithoutSCTs = "issue_without_scts"
+
+	// ctMaxResponseSize bounds the size of the responses read from CT logs.
+	ctMaxResponseSize = 64 * 1024
+
+	ctSCTVersionV1             uint8  = 0
+	ctSignatureTypeCertificate uint8  = 0
+	ctEntryTypePrecertificate  uint16 = 1
+	ctHashAlgorithmSHA256      uint8  = 4
+	ctSignatureAlgorithmRSA    uint8  = 1
+	ctSignatureAlgorithmECDSA  uint8  = 3
+	ctAddPreChainPath                 = "/ct/v1/add-pre-chain"
+	ctLogIDLength                     = sha256.Size
+)
+
+// SignedCertificateTimestamp is an RFC 6962 v1 Signed Certificate Timestamp.
+type SignedCertificateTimestamp struct {
+	Version            uint8
+	LogID              []byte
+	Timestamp          uint64
+	Extensions         []byte
+	HashAlgorithm      uint8
+	SignatureAlgorithm uint8
+	Signature          []byte
+}
+
+// addChainResponse is the response of a log to add-pre-chain, see
+// https://datatracker.ietf.org/doc/html/rfc6962#section-4.1.
+type addChainResponse struct {
+	SCTVersion uint8  `json:"sct_version"`
+	ID         string `json:"id"`
+	Timestamp  uint64 `json:"timestamp"`
+	Extensions string `json:"extensions"`
+	Signature  string `json:"signature"`
+}
+
+// CTSigner signs leaf certificates of an issuer with Certificate
+// Transparency logs. A poisoned pre-certificate is signed and submitted to
+// the logs, then the final certificate is signed with the returned SCTs
+// embedded; the plain certificate is never signed.
+type CTSigner struct {
+	// ctx is the context of the request, which bounds the submissions to
+	// the logs along with the timeout of each log.
+	ctx    context.Context
+	caSign *certutil.CAInfoBundle
+
+	// Warnings lists the logs which failed while issuance still succeeded,
+	// either because the issuer's policy was otherwise satisfied or because
+	// its failure policy allows issuing without SCTs, and the logs whose
+	// SCTs were embedded without being verified.
+	Warnings []string
+}
+
+// NewCTSigner returns a CTSigner for the issuer, submitting to its logs
+// within the context of the request, or nil when the issuer has no
+// Certificate Transparency logs.
+func NewCTSigner(ctx context.Context, caSign *certutil.CAInfoBundle) *CTSigner {
+	if caSign == nil || len(caSign.CTLogs) == 0 {
+		return nil
+	}
+
+	return &CTSigner{ctx: ctx, caSign: caSign}
+}
+
+// SignCertificate implements certutil.CertificateSigner.
+func (s *CTSigner) SignCertificate(randReader io.Reader, template *x509.Certificate, pub crypto.PublicKey) ([]byte, error) {
+	caSign := s.caSign
+
+	for _, ext := range template.ExtraExtensions {
+		if ext.Id.Equal(ExtensionCTPoisonOID) || ext.Id.Equal(ExtensionCTSCTListOID) {
+			return nil, errutil.UserError{Err: "the certificate request must not contain Certificate Transparency extensions"}
+		}
+	}
+
+	required := caSign.CTRequiredSCTs
+	if required <= 0 {
+		required = len(caSign.CTLogs)
+	}
+
+	timeout := caSign.CTLogTimeout
+	if timeout <= 0 {
+		timeout = DefaultCTLogTimeout
+	}
+
+	precert, err := signWithExtension(randReader, caSign, template, pub, pkix.Extension{
+		Id:       ExtensionCTPoisonOID,
+		Critical: true,
+		Value:    asn1.NullBytes,
+	})
+	if err != nil {
+		return nil, errutil.InternalError{Err: fmt.Sprintf("unable to create pre-certificate for Certificate Transparency: %v", err)}
+	}
+
+	tbs, err := RemoveTBSExtension(precert.RawTBSCertificate, ExtensionCTPoisonOID)
+	if err != nil {
+		return nil, errutil.InternalError{Err: fmt.Sprintf("unable to parse pre-certificate: %v", err)}
+	}
+	issuerKeyHash := sha256.Sum256(caSign.Certificate.RawSubjectPublicKeyInfo)
+
+	chain := [][]byte{precert.Raw}
+	for _, block := range caSign.GetFullChain() {
+		chain = append(chain, block.Bytes)
+	}
+
+	client := cleanhttp.DefaultClient()
+	scts := make([]*SignedCertificateTimestamp, len(caSign.CTLogs))
+	errs := make([]error, len(caSign.CTLogs))
+
+	var wg sync.WaitGroup
+	for index, log := range caSign.CTLogs {
+		wg.Add(1)
+		go func(index int, log certutil.CTLog) {
+			defer wg.Done()
+
+			ctx, cancel := context.WithTimeout(s.ctx, timeout)
+			defer cancel()
+
+			scts[index], errs[index] = submitPrecertificate(ctx, client, log, chain, issuerKeyHash[:], tbs)
+		}(index, log)
+	}
+	wg.Wait()
+
+	var obtained []*SignedCertificateTimestamp
+	var failures, unverified []string
+	for index, log := range caSign.CTLogs {
+		if errs[index] != nil {
+			failures = append(failures, fmt.Sprintf("%v: %v", log.URL, errs[index]))
+			continue
+		}
+		obtained = append(obtained, scts[index])
+		if log.PublicKey == "" {
+			unverified = append(unverified, log.URL)
+		}
+	}
+
+	if len(obtained) < required {
+		msg := fmt.Sprintf("unable to obtain the %d SCTs required by the issuer's Certificate Transparency policy, got %d; failed logs: %v", required, len(obtained), strings.Join(failures, "; "))
+		if caSign.CTFailurePolicy != CTFailurePolicyIssueWithoutSCTs {
+			return nil, errutil.InternalError{Err: msg}
+		}
+
+		// The final certificate matches the logged pre-certificate, it only
+		// lacks the SCT list extension.
+		s.Warnings = append(s.Warnings, msg+"; the certificate was issued without SCTs as allowed by the issuer's failure policy")
+		final, err := signWithExtension(randReader, caSign, template, pub)
+		if err != nil {
+			return nil, errutil.InternalError{Err: fmt.Sprintf("unable to create certificate: %v", err)}
+		}
+		return final.Raw, nil
+	}
+
+	for _, failure := range failures {
+		s.Warnings = append(s.Warnings, fmt.Sprintf("failed to submit pre-certificate to Certificate Transparency log %v", failure))
+	}
+	for _, url := range unverified {
+		s.Warnings = append(s.Warnings, fmt.Sprintf("embedded the SCT of Certificate Transparency log %v without verifying it, as the issuer has no public key configured for the log", url))
+	}
+
+	sctList, err := MarshalSCTList(obtained)
+	if err != nil {
+		return nil, errutil.InternalError{Err: fmt.Sprintf("unable to encode SCT list: %v", err)}
+	}
+	extValue, err := asn1.Marshal(sctList)
+	if err != nil {
+		return nil, errutil.InternalError{Err: fmt.Sprintf("unable to encode SCT list: %v", err)}
+	}
+
+	final, err := signWithExtension(randReader, caSign, template, pub, pkix.Extension{
+		Id:    ExtensionCTSCTListOID,
+		Value: extValue,
+	})
+	if err != nil {
+		return nil, errutil.InternalError{Err: fmt.Sprintf("unable to create certificate with embedded SCTs: %v", err)}
+	}
+
+	// The SCTs only verify when the final certificate is the logged
+	// pre-certificate with the poison replaced by the SCT list.
+	finalTBS, err := RemoveTBSExtension(final.RawTBSCertificate, ExtensionCTSCTListOID)
+	if err != nil || !bytes.Equal(finalTBS, tbs) {
+		return nil, errutil.InternalError{Err: "certificate with embedded SCTs does not match the logged pre-certificate"}
+	}
+
+	return final.Raw, nil
+}
+
+// signWithExtension signs a copy of the template with the additional
+// extensions appended after all others, so that removing them from the
+// TBSCertificate yields the same bytes for the pre-certificate and the
+// final certificate.
+func signWithExtension(randReader io.Reader, caSign *certutil.CAInfoBundle, template *x509.Certificate, pub crypto.PublicKey, exts ...pkix.Extension) (*x509.Certificate, error) {
+	withExt := *template
+	withExt.ExtraExtensions = append(slices.Clone(template.ExtraExtensions), exts...)
+
+	certBytes, err := x509.CreateCertificate(randReader, &withExt, caSign.Certificate, pub, caSign.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return x509.ParseCertificate(certBytes)
+}
+
+func submitPrecertificate(ctx context.Context, client *http.Client, log certutil.CTLog, chain [][]byte, issuerKeyHash []byte, tbs []byte) (*SignedCertificateTimestamp, error) {
+	var logKey crypto.PublicKey
+	if log.PublicKey != "" {
+		var err error
+		logKey, err = ParseCTLogPublicKey(log.PublicKey)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	encodedChain := make([]string, len(chain))
+	for index, certBytes := range chain {
+		encodedChain[index] = base64.StdEncoding.EncodeToString(certBytes)
+	}
+	body, err := json.Marshal(map[string]interface{}{
+		"chain": encodedChain,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(log.URL, "/")+ctAddPreChainPath, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, ctMaxResponseSize))
+	if err != nil {
+		return nil, fmt.Errorf("failed reading response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code %d: %s", resp.StatusCode, strings.TrimSpace(string(respBody)))
+	}
+
+	var addChainResp addChainResponse
+	if err := json.Unmarshal(respBody, &addChainResp); err != nil {
+		return nil, fmt.Errorf("failed parsing response: %w", err)
+	}
+
+	sct, err := parseAddChainResponse(addChainResp)
+	if err != nil {
+		return nil, err
+	}
+
+	if logKey != nil {
+		if err := VerifySCT(sct, logKey, issuerKeyHash, tbs); err != nil {
+			return nil, err
+		}
+	}
+
+	return sct, nil
+}
+
+func parseAddChainResponse(resp addChainResponse) (*SignedCertificateTimestamp, error) {
+	if resp.SCTVersion != ctSCTVersionV1 {
+		return nil, fmt.Errorf("unsupported SCT version: %d", resp.SCTVersion)
+	}
+
+	logID, err := base64.StdEncoding.DecodeString(resp.ID)
+	if err != nil || len(logID) != ctLogIDLength {
+		return nil, fmt.Errorf("invalid log ID in SCT: %q", resp.ID)
+	}
+
+	extensions, err := base64.StdEncoding.DecodeString(resp.Extensions)
+	if err != nil {
+		return nil, fmt.Errorf("invalid extensions in SCT: %w", err)
+	}
+
+	rawSignature, err := base64.StdEncoding.DecodeString(resp.Signature)
+	if err != nil {
+		return nil, fmt.Errorf("invalid signature in SCT: %w", err)
+	}
+
+	sct := &SignedCertificateTimestamp{
+		Version:    resp.SCTVersion,
+		LogID:      logID,
+		Timestamp:  resp.Timestamp,
+		Extensions: extensions,
+	}
+
+	input := cryptobyte.String(rawSignature)
+	var signature cryptobyte.String
+	if !input.ReadUint8(&sct.HashAlgorithm) ||
+		!input.ReadUint8(&sct.SignatureAlgorithm) ||
+		!input.ReadUint16LengthPrefixed(&signature) ||
+		!input.Empty() {
+		return nil, fmt.Errorf("invalid signature in SCT")
+	}
+	sct.Signature = signature
+
+	return sct, nil
+}
+
+// VerifySCT verifies the signature of a log over a pre-certificate entry,
+// given the hash of the issuer's SubjectPublicKeyInfo and the pre-certificate's
+// TBSCertificate without the poison extension.
+func VerifySCT(sct *SignedCertificateTimestamp, logKey crypto.PublicKey, issuerKeyHash []byte, tbs []byte) error {
+	logKeyBytes, err := x509.MarshalPKIXPublicKey(logKey)
+	if err != nil {
+		return fmt.Errorf("invalid log public key: %w", err)
+	}
+	logID := sha256.Sum256(logKeyBytes)
+	if !bytes.Equal(logID[:], sct.LogID) {
+		return fmt.Errorf("SCT log ID does not match the public key of the log")
+	}
+
+	if sct.HashAlgorithm != ctHashAlgorithmSHA256 {
+		return fmt.Errorf("unsupported SCT hash algorithm: %d", sct.HashAlgorithm)
+	}
+
+	var b cryptobyte.Builder
+	b.AddUint8(sct.Version)
+	b.AddUint8(ctSignatureTypeCertificate)
+	b.AddUint64(sct.Timestamp)
+	b.AddUint16(ctEntryTypePrecertificate)
+	b.AddBytes(issuerKeyHash)
+	b.AddUint24LengthPrefixed(func(child *cryptobyte.Builder) {
+		child.AddBytes(tbs)
+	})
+	b.AddUint16LengthPrefixed(func(child *cryptobyte.Builder) {
+		child.AddBytes(sct.Extensions)
+	})
+	signed, err := b.Bytes()
+	if err != nil {
+		return fmt.Errorf("unable to encode signed SCT data: %w", err)
+	}
+	digest := sha256.Sum256(signed)
+
+	switch key := logKey.(type) {
+	case *ecdsa.PublicKey:
+		if sct.SignatureAlgorithm != ctSignatureAlgorithmECDSA || !ecdsa.VerifyASN1(key, digest[:], sct.Signature) {
+			return fmt.Errorf("invalid SCT signature")
+		}
+	case *rsa.PublicKey:
+		if sct.SignatureAlgorithm != ctSignatureAlgorithmRSA || rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], sct.Signature) != nil {
+			return fmt.Errorf("invalid SCT signature")
+		}
+	default:
+		return fmt.Errorf("unsupported log public key type: %T", logKey)
+	}
+
+	return nil
+}
+
+// MarshalSCTList encodes SCTs into the TLS encoded SignedCertificateTimestampList
+// of RFC 6962 Section 3.3, the contents of the SCT list extension.
+func MarshalSCTList(scts []*SignedCertificateTimestamp) ([]byte, error) {
+	var b cryptobyte.Builder
+	b.AddUint16LengthPrefixed(func(list *cryptobyte.Builder) {
+		for _, sct := range scts {
+			list.AddUint16LengthPrefixed(func(serialized *cryptobyte.Builder) {
+				serialized.AddUint8(sct.Version)
+				serialized.AddBytes(sct.LogID)
+				serialized.AddUint64(sct.Timestamp)
+				serialized.AddUint16LengthPrefixed(func(child *cryptobyte.Builder) {
+					child.AddBytes(sct.Extensions)
+				})
+				serialized.AddUint8(sct.HashAlgorithm)
+				serialized.AddUint8(sct.SignatureAlgorithm)
+				serialized.AddUint16LengthPrefixed(func(child *cryptobyte.Builder) {
+					child.AddBytes(sct.Signature)
+				})
+			})
+		}
+	})
+
+	return b.Bytes()
+}
+
+// ParseSCTList decodes the SCTs embedded in a certificate, returning an
+// empty list when the certificate has no SCT list extension.
+func ParseSCTList(cert *x509.Certificate) ([]*SignedCertificateTimestamp, error) {
+	var extValue []byte
+	for _, ext := range cert.Extensions {
+		if ext.Id.Equal(ExtensionCTSCTListOID) {
+			extValue = ext.Value
+		}
+	}
+	if extValue == nil {
+		return nil, nil
+	}
+
+	var sctList []byte
+	if rest, err := asn1.Unmarshal(extValue, &sctList); err != nil || len(rest) > 0 {
+		return nil, fmt.Errorf("invalid SCT list extension")
+	}
+
+	input := cryptobyte.String(sctList)
+	var list cryptobyte.String
+	if !input.ReadUint16LengthPrefixed(&list) || !input.Empty() {
+		return nil, fmt.Errorf("invalid SCT list")
+	}
+
+	var scts []*SignedCertificateTimestamp
+	for !list.Empty() {
+		var serialized, logID, extensions, signature cryptobyte.String
+		sct := &SignedCertificateTimestamp{}
+		if !list.ReadUint16LengthPrefixed(&serialized) ||
+			!serialized.ReadUint8(&sct.Version) ||
+			!serialized.ReadBytes((*[]byte)(&logID), ctLogIDLength) ||
+			!serialized.ReadUint64(&sct.Timestamp) ||
+			!serialized.ReadUint16LengthPrefixed(&extensions) ||
+			!serialized.ReadUint8(&sct.HashAlgorithm) ||
+			!serialized.ReadUint8(&sct.SignatureAlgorithm) ||
+			!serialized.ReadUint16LengthPrefixed(&signature) ||
+			!serialized.Empty() {
+			return nil, fmt.Errorf("invalid SCT in SCT list")
+		}
+		sct.LogID = logID
+		sct.Extensions = extensions
+		sct.Signature = signature
+		scts = append(scts, sct)
+	}
+
+	return scts, nil
+}
+
+// tbsCertificate mirrors the TBSCertificate of RFC 5280, keeping all but
+// the extensions as raw values so they are re-encoded verbatim.
+type tbsCertificate struct {
+	Raw                asn1.RawContent
+	Version            int `asn1:"optional,explicit,default:0,tag:0"`
+	SerialNumber       *big.Int
+	SignatureAlgorithm asn1.RawValue
+	Issuer             asn1.RawValue
+	Validity           asn1.RawValue
+	Subject            asn1.RawValue
+	PublicKey          asn1.RawValue
+	UniqueId           asn1.BitString   `asn1:"optional,tag:1"`
+	SubjectUniqueId    asn1.BitString   `asn1:"optional,tag:2"`
+	Extensions         []pkix.Extension `asn1:"omitempty,optional,explicit,tag:3"`
+}
+
+// RemoveTBSExtension returns the TBSCertificate with the given extension
+// removed, as needed to compute the data signed by CT logs.
+func RemoveTBSExtension(rawTBS []byte, oid asn1.ObjectIdentifier) ([]byte, error) {
+	var tbs tbsCertificate
+	rest, err := asn1.Unmarshal(rawTBS, &tbs)
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) > 0 {
+		return nil, fmt.Errorf("trailing data after TBSCertificate")
+	}
+
+	tbs.Raw = nil
+	tbs.Extensions = slices.DeleteFunc(tbs.Extensions, func(ext pkix.Extension) bool {
+		return ext.Id.Equal(oid)
+	})
+
+	return asn1.Marshal(tbs)
+}
+
+// ParseCTLogPublicKey parses the public key of a CT log, either PEM encoded
+// or as the base64 encoded DER SubjectPublicKeyInfo published in log lists.
+func ParseCTLogPublicKey(rawKey string) (crypto.PublicKey, error) {
+	var keyBytes []byte
+	if block, _ := pem.Decode([]byte(rawKey)); block != nil {
+		keyBytes = block.Bytes
+	} else {
+		var err error
+		keyBytes, err = base64.StdEncoding.DecodeString(strings.TrimSpace(rawKey))
+		if err != nil {
+			return nil, fmt.Errorf("log public key is neither PEM nor base64 encoded")
+		}
+	}
+
+	key, err := x509.ParsePKIXPublicKey(keyBytes)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse log public key: %w", err)
+	}
+
+	switch key.(type) {
+	case *ecdsa.PublicKey, *rsa.PublicKey:
+		return key, nil
+	default:
+		return nil, fmt.Errorf("unsupported log public key type: %T", key)
+	}
+}
@@ -428,6 +428,10 @@ func GenerateCreationBundle(b logical.SystemView, role *RoleEntry, entityInfo En
 		return creation, warnings, nil
 	}
 
+	if err := ValidateNameConstraints(caSign.Certificate, creation.Params); err != nil {
+		return nil, nil, err
+	}
+
 	// This will have been read in from the getGlobalAIAURLs function
 	creation.Params.URLs = caSign.URLs
 
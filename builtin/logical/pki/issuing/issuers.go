@@ -127,6 +127,10 @@ type IssuerEntry struct {
 	RevocationTime       int64                     `json:"revocation_time"`
 	RevocationTimeUTC    time.Time                 `json:"revocation_time_utc"`
 	AIAURIs              *AiaConfigEntry           `json:"aia_uris,omitempty"`
+	CTLogs               []certutil.CTLog          `json:"ct_logs,omitempty"`
+	CTRequiredSCTs       int                       `json:"ct_required_scts,omitempty"`
+	CTLogTimeout         time.Duration             `json:"ct_log_timeout,omitempty"`
+	CTFailurePolicy      string                    `json:"ct_failure_policy,omitempty"`
 	LastModified         time.Time                 `json:"last_modified"`
 	Version              uint                      `json:"version"`
 }
@@ -402,6 +406,10 @@ func FetchCAInfoByIssuerId(ctx context.Context, s logical.Storage, mkv managed_k
 		URLs:                 nil,
 		LeafNotAfterBehavior: entry.LeafNotAfterBehavior,
 		RevocationSigAlg:     entry.RevocationSigAlg,
+		CTLogs:               entry.CTLogs,
+		CTRequiredSCTs:       entry.CTRequiredSCTs,
+		CTLogTimeout:         entry.CTLogTimeout,
+		CTFailurePolicy:      entry.CTFailurePolicy,
 	}
 
 	entries, err := GetAIAURLs(ctx, s, entry)
@@ -0,0 +1,197 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package issuing
+
+import (
+	"crypto/x509"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/hashicorp/vault/sdk/helper/certutil"
+	"github.com/hashicorp/vault/sdk/helper/errutil"
+)
+
+// NameConstraintsInput provides the name constraints to encode into a CA
+// certificate, see https://tools.ietf.org/html/rfc5280#section-4.2.1.10.
+type NameConstraintsInput interface {
+	GetPermittedDomains() []string
+	GetExcludedDomains() []string
+	GetPermittedIpRanges() []string
+	GetExcludedIpRanges() []string
+	GetPermittedEmailAddresses() []string
+	GetExcludedEmailAddresses() []string
+	GetPermittedUriDomains() []string
+	GetExcludedUriDomains() []string
+}
+
+// SetNameConstraints copies the name constraints of the input onto the
+// creation parameters of a CA certificate.
+func SetNameConstraints(params *certutil.CreationParameters, input NameConstraintsInput) error {
+	permittedIpRanges, err := ParseIpRanges(input.GetPermittedIpRanges())
+	if err != nil {
+		return fmt.Errorf("invalid permitted_ip_ranges: %w", err)
+	}
+	excludedIpRanges, err := ParseIpRanges(input.GetExcludedIpRanges())
+	if err != nil {
+		return fmt.Errorf("invalid excluded_ip_ranges: %w", err)
+	}
+
+	params.PermittedDNSDomains = input.GetPermittedDomains()
+	params.ExcludedDNSDomains = input.GetExcludedDomains()
+	params.PermittedIPRanges = permittedIpRanges
+	params.ExcludedIPRanges = excludedIpRanges
+	params.PermittedEmailAddresses = input.GetPermittedEmailAddresses()
+	params.ExcludedEmailAddresses = input.GetExcludedEmailAddresses()
+	params.PermittedURIDomains = input.GetPermittedUriDomains()
+	params.ExcludedURIDomains = input.GetExcludedUriDomains()
+
+	return nil
+}
+
+// ParseIpRanges parses a list of IP ranges in CIDR notation.
+func ParseIpRanges(ranges []string) ([]*net.IPNet, error) {
+	var ret []*net.IPNet
+	for _, ipRange := range ranges {
+		_, ipNet, err := net.ParseCIDR(strings.TrimSpace(ipRange))
+		if err != nil {
+			return nil, errutil.UserError{Err: fmt.Sprintf("%q is not a valid IP range in CIDR notation", ipRange)}
+		}
+		ret = append(ret, ipNet)
+	}
+	return ret, nil
+}
+
+// ValidateNameConstraints verifies that the names of the certificate to be
+// issued are allowed by the name constraints of the issuing CA, so that we
+// don't issue certificates that relying parties would reject.
+func ValidateNameConstraints(caCert *x509.Certificate, params *certutil.CreationParameters) error {
+	if caCert == nil || params == nil {
+		return nil
+	}
+
+	for _, name := range params.DNSNames {
+		if !nameIsConstrained(name, caCert.PermittedDNSDomains, caCert.ExcludedDNSDomains, matchDomainConstraint) {
+			return errutil.UserError{Err: fmt.Sprintf("DNS name %q is not allowed by the name constraints of the issuer", name)}
+		}
+	}
+
+	for _, email := range params.EmailAddresses {
+		if !nameIsConstrained(email, caCert.PermittedEmailAddresses, caCert.ExcludedEmailAddresses, matchEmailConstraint) {
+			return errutil.UserError{Err: fmt.Sprintf("email address %q is not allowed by the name constraints of the issuer", email)}
+		}
+	}
+
+	for _, uri := range params.URIs {
+		if len(caCert.PermittedURIDomains) == 0 && len(caCert.ExcludedURIDomains) == 0 {
+			break
+		}
+
+		host := uri.Hostname()
+		if host == "" || net.ParseIP(host) != nil {
+			return errutil.UserError{Err: fmt.Sprintf("URI %q has no domain name to validate against the name constraints of the issuer", uri.String())}
+		}
+		if !nameIsConstrained(host, caCert.PermittedURIDomains, caCert.ExcludedURIDomains, matchUriDomainConstraint) {
+			return errutil.UserError{Err: fmt.Sprintf("URI %q is not allowed by the name constraints of the issuer", uri.String())}
+		}
+	}
+
+	for _, ip := range params.IPAddresses {
+		if !ipIsConstrained(ip, caCert.PermittedIPRanges, caCert.ExcludedIPRanges) {
+			return errutil.UserError{Err: fmt.Sprintf("IP address %q is not allowed by the name constraints of the issuer", ip.String())}
+		}
+	}
+
+	return nil
+}
+
+// nameIsConstrained returns true when the name matches none of the excluded
+// constraints and, if any are present, one of the permitted constraints.
+func nameIsConstrained(name string, permitted []string, excluded []string, match func(string, string) bool) bool {
+	for _, constraint := range excluded {
+		if match(name, constraint) {
+			return false
+		}
+	}
+
+	if len(permitted) == 0 {
+		return true
+	}
+
+	for _, constraint := range permitted {
+		if match(name, constraint) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func ipIsConstrained(ip net.IP, permitted []*net.IPNet, excluded []*net.IPNet) bool {
+	for _, ipRange := range excluded {
+		if ipRange.Contains(ip) {
+			return false
+		}
+	}
+
+	if len(permitted) == 0 {
+		return true
+	}
+
+	for _, ipRange := range permitted {
+		if ipRange.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// matchDomainConstraint matches a domain name against a DNS name constraint:
+// a constraint matches the domain itself and its subdomains, while a
+// constraint with a leading period only matches subdomains.
+func matchDomainConstraint(domain string, constraint string) bool {
+	domain = strings.ToLower(strings.TrimSuffix(domain, "."))
+	constraint = strings.ToLower(constraint)
+
+	if constraint == "" {
+		return true
+	}
+	if strings.HasPrefix(constraint, ".") {
+		return strings.HasSuffix(domain, constraint)
+	}
+
+	return domain == constraint || strings.HasSuffix(domain, "."+constraint)
+}
+
+// matchUriDomainConstraint matches the host of a URI against a URI name
+// constraint: a constraint with a leading period matches subdomains,
+// otherwise it must match the host exactly.
+func matchUriDomainConstraint(host string, constraint string) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	constraint = strings.ToLower(constraint)
+
+	if strings.HasPrefix(constraint, ".") {
+		return strings.HasSuffix(host, constraint)
+	}
+
+	return host == constraint
+}
+
+// matchEmailConstraint matches an email address against an email name
+// constraint: a constraint containing an @ is a full mailbox, one with a
+// leading period matches all hosts of a domain, otherwise it must match
+// the host of the address exactly.
+func matchEmailConstraint(email string, constraint string) bool {
+	if strings.Contains(constraint, "@") {
+		return strings.EqualFold(email, constraint)
+	}
+
+	at := strings.LastIndex(email, "@")
+	if at == -1 {
+		return false
+	}
+
+	return matchUriDomainConstraint(email[at+1:], constraint)
+}
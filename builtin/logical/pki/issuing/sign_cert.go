@@ -4,6 +4,7 @@
 package issuing
 
 import (
+	"context"
 	"crypto/ecdsa"
 	"crypto/ed25519"
 	"crypto/rsa"
@@ -20,7 +21,7 @@ type SignCertInput interface {
 	GetCSR() (*x509.CertificateRequest, error)
 	IsCA() bool
 	UseCSRValues() bool
-	GetPermittedDomains() []string
+	NameConstraintsInput
 }
 
 func NewBasicSignCertInput(csr *x509.CertificateRequest, isCA bool, useCSRValues bool) BasicSignCertInput {
@@ -103,7 +104,35 @@ func (b BasicSignCertInput) GetPermittedDomains() []string {
 	return []string{}
 }
 
-func SignCert(b logical.SystemView, role *RoleEntry, entityInfo EntityInfo, caSign *certutil.CAInfoBundle, signInput SignCertInput) (*certutil.ParsedCertBundle, []string, error) {
+func (b BasicSignCertInput) GetExcludedDomains() []string {
+	return []string{}
+}
+
+func (b BasicSignCertInput) GetPermittedIpRanges() []string {
+	return []string{}
+}
+
+func (b BasicSignCertInput) GetExcludedIpRanges() []string {
+	return []string{}
+}
+
+func (b BasicSignCertInput) GetPermittedEmailAddresses() []string {
+	return []string{}
+}
+
+func (b BasicSignCertInput) GetExcludedEmailAddresses() []string {
+	return []string{}
+}
+
+func (b BasicSignCertInput) GetPermittedUriDomains() []string {
+	return []string{}
+}
+
+func (b BasicSignCertInput) GetExcludedUriDomains() []string {
+	return []string{}
+}
+
+func SignCert(ctx context.Context, b logical.SystemView, role *RoleEntry, entityInfo EntityInfo, caSign *certutil.CAInfoBundle, signInput SignCertInput) (*certutil.ParsedCertBundle, []string, error) {
 	if role == nil {
 		return nil, nil, errutil.InternalError{Err: "no role found in data bundle"}
 	}
@@ -273,7 +302,9 @@ func SignCert(b logical.SystemView, role *RoleEntry, entityInfo EntityInfo, caSi
 	creation.Params.UseCSRValues = signInput.UseCSRValues()
 
 	if signInput.IsCA() {
-		creation.Params.PermittedDNSDomains = signInput.GetPermittedDomains()
+		if err := SetNameConstraints(creation.Params, signInput); err != nil {
+			return nil, nil, err
+		}
 	} else {
 		for _, ext := range csr.Extensions {
 			if ext.Id.Equal(certutil.ExtensionBasicConstraintsOID) {
@@ -282,10 +313,24 @@ func SignCert(b logical.SystemView, role *RoleEntry, entityInfo EntityInfo, caSi
 		}
 	}
 
+	// Leaf certificates of issuers with Certificate Transparency logs get
+	// their SCTs embedded.
+	var ctSigner *CTSigner
+	if !signInput.IsCA() {
+		ctSigner = NewCTSigner(ctx, caSign)
+	}
+	if ctSigner != nil {
+		creation.CertificateSigner = ctSigner.SignCertificate
+	}
+
 	parsedBundle, err := certutil.SignCertificate(creation)
 	if err != nil {
 		return nil, nil, err
 	}
 
+	if ctSigner != nil {
+		warnings = append(warnings, ctSigner.Warnings...)
+	}
+
 	return parsedBundle, warnings, nil
 }
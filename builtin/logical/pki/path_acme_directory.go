@@ -51,6 +51,8 @@ func (b *backend) acmeDirectoryHandler(acmeCtx *acmeContext, r *logical.Request,
 		"newOrder":   acmeCtx.baseUrl.JoinPath("new-order").String(),
 		"revokeCert": acmeCtx.baseUrl.JoinPath("revoke-cert").String(),
 		"keyChange":  acmeCtx.baseUrl.JoinPath("key-change").String(),
+		// See draft-ietf-acme-ari, ACME Renewal Information (ARI) Extension.
+		"renewalInfo": acmeCtx.baseUrl.JoinPath("renewal-info").String(),
 		// This is purposefully missing newAuthz as we don't support pre-authorization
 		"meta": map[string]interface{}{
 			"externalAccountRequired": acmeCtx.eabPolicy.IsExternalAccountRequired(),
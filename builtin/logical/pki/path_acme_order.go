@@ -258,6 +258,14 @@ func (b *backend) acmeFinalizeOrderHandler(ac *acmeContext, r *logical.Request,
 		return nil, err
 	}
 
+	// Another order might have replaced the same certificate since this
+	// order was created.
+	if len(order.Replaces) > 0 {
+		if err = b.validateAcmeOrderReplaces(ac, order.AccountId, order.Replaces, order.Identifiers); err != nil {
+			return nil, err
+		}
+	}
+
 	var signedCertBundle *certutil.ParsedCertBundle
 	var issuerId issuing.IssuerID
 	if ac.runtimeOpts.isCiepsEnabled {
@@ -296,6 +304,12 @@ func (b *backend) acmeFinalizeOrderHandler(ac *acmeContext, r *logical.Request,
 		return nil, fmt.Errorf("failed saving updated order: %w", err)
 	}
 
+	if len(order.Replaces) > 0 {
+		if err := b.markAcmeOrderReplaces(ac, order); err != nil {
+			b.Logger().Warn("failed marking certificate as replaced by ACME order", "order", orderId, "replaces", order.Replaces, "error", err)
+		}
+	}
+
 	if err := b.doTrackBilling(ac.sc.Context, order.Identifiers); err != nil {
 		b.Logger().Error("failed to track billing for order", "order", orderId, "error", err)
 		err = nil
@@ -568,7 +582,7 @@ func issueCertFromCsr(ac *acmeContext, csr *x509.CertificateRequest) (*certutil.
 	// unit, we have no way of validating this (via ACME here, without perhaps
 	// an external policy engine), and thus should not be setting it on our
 	// final issued certificate.
-	parsedBundle, _, err := signCert(ac.sc.Backend, input, signingBundle, false /* is_ca=false */, false /* use_csr_values */)
+	parsedBundle, _, err := signCert(ac.sc.Context, ac.sc.Backend, input, signingBundle, false /* is_ca=false */, false /* use_csr_values */)
 	if err != nil {
 		return nil, "", fmt.Errorf("%w: refusing to sign CSR: %s", ErrBadCSR, err.Error())
 	}
@@ -737,6 +751,18 @@ func (b *backend) acmeNewOrderHandler(ac *acmeContext, _ *logical.Request, _ *fr
 		return nil, err
 	}
 
+	replaces, err := parseOptStringField(data, "replaces")
+	if err != nil {
+		return nil, err
+	}
+
+	if len(replaces) > 0 {
+		err = b.validateAcmeOrderReplaces(ac, account.KeyId, replaces, identifiers)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	// Per RFC 8555 -> 7.1.3. Order Objects
 	// For pending orders, the authorizations that the client needs to complete before the
 	// requested certificate can be issued (see Section 7.5), including
@@ -770,6 +796,7 @@ func (b *backend) acmeNewOrderHandler(ac *acmeContext, _ *logical.Request, _ *fr
 		Expires:          time.Now().Add(24 * time.Hour), // TODO: Readjust this based on authz and/or config
 		Identifiers:      identifiers,
 		AuthorizationIds: authorizationIds,
+		Replaces:         replaces,
 	}
 
 	err = b.GetAcmeState().SaveOrder(ac, order)
@@ -787,6 +814,65 @@ func (b *backend) acmeNewOrderHandler(ac *acmeContext, _ *logical.Request, _ *fr
 	return resp, nil
 }
 
+// validateAcmeOrderReplaces validates the certificate an order replaces, per
+// draft-ietf-acme-ari, Section 5: it must have been issued to the same
+// account, share an identifier with the order and not have been replaced
+// by another order yet.
+func (b *backend) validateAcmeOrderReplaces(ac *acmeContext, accountId string, certId string, identifiers []*ACMEIdentifier) error {
+	authorityKeyId, serial, err := parseAcmeCertId(certId)
+	if err != nil {
+		return err
+	}
+
+	cert, err := fetchAcmeCertByCertId(ac.sc, authorityKeyId, serial)
+	if err != nil {
+		return err
+	}
+	if cert == nil {
+		return fmt.Errorf("%w: replaced certificate %s does not exist", ErrMalformed, certId)
+	}
+
+	certEntry, err := b.GetAcmeState().GetIssuedCert(ac, accountId, serial)
+	if err != nil {
+		return fmt.Errorf("%w: replaced certificate %s: %s", ErrMalformed, certId, err.Error())
+	}
+
+	if len(certEntry.ReplacedBy) > 0 {
+		return fmt.Errorf("%w: certificate %s was replaced by order %s", ErrAlreadyReplaced, certId, certEntry.ReplacedBy)
+	}
+
+	for _, identifier := range identifiers {
+		switch identifier.Type {
+		case ACMEDNSIdentifier:
+			for _, dnsName := range cert.DNSNames {
+				if strings.EqualFold(dnsName, identifier.OriginalValue) {
+					return nil
+				}
+			}
+		case ACMEIPIdentifier:
+			ip := net.ParseIP(identifier.Value)
+			for _, ipAddress := range cert.IPAddresses {
+				if ipAddress.Equal(ip) {
+					return nil
+				}
+			}
+		}
+	}
+
+	return fmt.Errorf("%w: order does not share any identifier with replaced certificate %s", ErrMalformed, certId)
+}
+
+// markAcmeOrderReplaces records the certificate replaced by a finalized
+// order, preventing other orders from replacing it again.
+func (b *backend) markAcmeOrderReplaces(ac *acmeContext, order *acmeOrder) error {
+	_, serial, err := parseAcmeCertId(order.Replaces)
+	if err != nil {
+		return err
+	}
+
+	return b.GetAcmeState().MarkIssuedCertReplaced(ac, order.AccountId, serial, order.OrderId)
+}
+
 func validateAcmeProvidedOrderDates(notBefore time.Time, notAfter time.Time) error {
 	if !notBefore.IsZero() && !notAfter.IsZero() {
 		if notBefore.Equal(notAfter) {
@@ -833,6 +919,10 @@ func formatOrderResponse(acmeCtx *acmeContext, order *acmeOrder) *logical.Respon
 		},
 	}
 
+	if len(order.Replaces) > 0 {
+		resp.Data["replaces"] = order.Replaces
+	}
+
 	// Only reply with the certificate URL if we are in a valid order state.
 	if order.Status == ACMEOrderValid {
 		resp.Data["certificate"] = baseOrderUrl + "/cert"
@@ -914,6 +1004,20 @@ func parseOptRFC3339Field(data map[string]interface{}, keyName string) (time.Tim
 	return timeVal, nil
 }
 
+func parseOptStringField(data map[string]interface{}, keyName string) (string, error) {
+	rawValue, present := data[keyName]
+	if !present {
+		return "", nil
+	}
+
+	value, ok := rawValue.(string)
+	if !ok {
+		return "", fmt.Errorf("invalid type (%T) for field '%s': %w", rawValue, keyName, ErrMalformed)
+	}
+
+	return value, nil
+}
+
 func parseOrderIdentifiers(data map[string]interface{}) ([]*ACMEIdentifier, error) {
 	rawIdentifiers, present := data["identifiers"]
 	if !present {
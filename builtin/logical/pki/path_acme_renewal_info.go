@@ -0,0 +1,188 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package pki
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+const (
+	// acmeRenewalInfoRetryAfter is how long clients are told to wait before
+	// polling the renewal information of a certificate again.
+	acmeRenewalInfoRetryAfter = 6 * time.Hour
+
+	// acmeIssuerRotationRenewalSpread is the longest window over which we
+	// spread the renewals of certificates issued by an issuer that is no
+	// longer the one of the directory, to avoid all clients renewing at once.
+	acmeIssuerRotationRenewalSpread = 24 * time.Hour
+)
+
+func pathAcmeRenewalInfo(b *backend, baseUrl string, opts acmeWrapperOpts) *framework.Path {
+	return patternAcmeRenewalInfo(b, baseUrl+"/renewal-info/"+framework.MatchAllRegex("cert_id"), opts)
+}
+
+func patternAcmeRenewalInfo(b *backend, pattern string, opts acmeWrapperOpts) *framework.Path {
+	fields := map[string]*framework.FieldSchema{}
+	addFieldsForACMEPath(fields, pattern)
+	fields["cert_id"] = &framework.FieldSchema{
+		Type:        framework.TypeString,
+		Description: `The ACME renewal information identifier of the certificate`,
+		Required:    true,
+	}
+
+	return &framework.Path{
+		Pattern: pattern,
+		Fields:  fields,
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.ReadOperation: &framework.PathOperation{
+				Callback:                    b.acmeWrapper(opts, b.acmeRenewalInfoHandler),
+				ForwardPerformanceSecondary: false,
+				ForwardPerformanceStandby:   true,
+			},
+		},
+
+		HelpSynopsis:    pathAcmeHelpSync,
+		HelpDescription: pathAcmeHelpDesc,
+	}
+}
+
+func (b *backend) acmeRenewalInfoHandler(acmeCtx *acmeContext, _ *logical.Request, fields *framework.FieldData) (*logical.Response, error) {
+	authorityKeyId, serial, err := parseAcmeCertId(fields.Get("cert_id").(string))
+	if err != nil {
+		return nil, err
+	}
+
+	cert, err := fetchAcmeCertByCertId(acmeCtx.sc, authorityKeyId, serial)
+	if err != nil {
+		return nil, err
+	}
+	if cert == nil {
+		return logical.RespondWithStatusCode(nil, nil, http.StatusNotFound)
+	}
+
+	revEntry, err := fetchCertBySerial(acmeCtx.sc, "revoked/", serial)
+	if err != nil {
+		return nil, fmt.Errorf("failed reading revocation entry for certificate %s: %w", serial, err)
+	}
+
+	issuerCert, err := acmeCtx.issuer.GetCertificate()
+	if err != nil {
+		return nil, fmt.Errorf("failed parsing issuer certificate: %w", err)
+	}
+	rotated := !bytes.Equal(cert.AuthorityKeyId, issuerCert.SubjectKeyId)
+
+	start, end := computeAcmeRenewalWindow(cert, time.Now(), revEntry != nil, rotated)
+
+	rawBody, err := json.Marshal(map[string]interface{}{
+		"suggestedWindow": map[string]interface{}{
+			"start": start.UTC().Format(time.RFC3339),
+			"end":   end.UTC().Format(time.RFC3339),
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed encoding response: %w", err)
+	}
+
+	return &logical.Response{
+		Headers: map[string][]string{
+			"Retry-After": {strconv.Itoa(int(acmeRenewalInfoRetryAfter.Seconds()))},
+		},
+		Data: map[string]interface{}{
+			logical.HTTPContentType: "application/json",
+			logical.HTTPStatusCode:  http.StatusOK,
+			logical.HTTPRawBody:     rawBody,
+		},
+	}, nil
+}
+
+// computeAcmeRenewalWindow returns the window within which a certificate
+// should be renewed. By default, this spans from two thirds to five sixths
+// of its validity period. Revoked certificates should be renewed right away,
+// while certificates issued by an issuer other than the one currently
+// used by the directory should be renewed soon, spread over a short window.
+func computeAcmeRenewalWindow(cert *x509.Certificate, now time.Time, revoked bool, rotated bool) (time.Time, time.Time) {
+	if revoked {
+		// A window in the past tells clients to renew immediately.
+		return now.Add(-time.Hour), now
+	}
+
+	lifetime := cert.NotAfter.Sub(cert.NotBefore)
+	start := cert.NotAfter.Add(-lifetime / 3)
+	end := cert.NotAfter.Add(-lifetime / 6)
+
+	if rotated {
+		spread := cert.NotAfter.Sub(now) / 2
+		if spread > acmeIssuerRotationRenewalSpread {
+			spread = acmeIssuerRotationRenewalSpread
+		}
+
+		if now.Before(start) {
+			start = now
+		}
+		if now.Add(spread).Before(end) {
+			end = now.Add(spread)
+		}
+	}
+
+	return start, end
+}
+
+// parseAcmeCertId parses the certificate identifier used by ACME Renewal
+// Information, made of the base64url encoded key identifier of the
+// certificate's Authority Key Identifier extension and serial number,
+// separated by a period.
+func parseAcmeCertId(certId string) ([]byte, string, error) {
+	rawAuthorityKeyId, rawSerial, found := strings.Cut(certId, ".")
+	if !found || len(rawAuthorityKeyId) == 0 || len(rawSerial) == 0 {
+		return nil, "", fmt.Errorf("%w: invalid certificate identifier: %s", ErrMalformed, certId)
+	}
+
+	authorityKeyId, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(rawAuthorityKeyId, "="))
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: failed decoding authority key identifier of certificate identifier: %s", ErrMalformed, err.Error())
+	}
+
+	serialBytes, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(rawSerial, "="))
+	if err != nil {
+		return nil, "", fmt.Errorf("%w: failed decoding serial number of certificate identifier: %s", ErrMalformed, err.Error())
+	}
+
+	return authorityKeyId, serialFromBigInt(new(big.Int).SetBytes(serialBytes)), nil
+}
+
+// fetchAcmeCertByCertId loads the certificate referenced by an ACME Renewal
+// Information certificate identifier, returning nil if no such certificate
+// was issued by this mount.
+func fetchAcmeCertByCertId(sc *storageContext, authorityKeyId []byte, serial string) (*x509.Certificate, error) {
+	certEntry, err := fetchCertBySerial(sc, "certs/", serial)
+	if err != nil {
+		return nil, fmt.Errorf("failed reading certificate %s from storage: %w", serial, err)
+	}
+	if certEntry == nil || len(certEntry.Value) == 0 {
+		return nil, nil
+	}
+
+	cert, err := x509.ParseCertificate(certEntry.Value)
+	if err != nil {
+		return nil, fmt.Errorf("failed parsing certificate %s: %w", serial, err)
+	}
+
+	if !bytes.Equal(cert.AuthorityKeyId, authorityKeyId) {
+		return nil, nil
+	}
+
+	return cert, nil
+}
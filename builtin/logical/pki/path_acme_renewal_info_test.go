@@ -0,0 +1,280 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package pki
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-jose/go-jose/v3"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/acme"
+)
+
+func TestAcmeRenewalInfo_ComputeWindow(t *testing.T) {
+	t.Parallel()
+
+	notBefore := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	cert := &x509.Certificate{
+		NotBefore: notBefore,
+		NotAfter:  notBefore.Add(90 * 24 * time.Hour),
+	}
+
+	// By default, renew between two thirds and five sixths of the lifetime.
+	now := notBefore.Add(24 * time.Hour)
+	start, end := computeAcmeRenewalWindow(cert, now, false, false)
+	require.Equal(t, notBefore.Add(60*24*time.Hour), start)
+	require.Equal(t, notBefore.Add(75*24*time.Hour), end)
+
+	// Revoked certificates get a window in the past.
+	start, end = computeAcmeRenewalWindow(cert, now, true, false)
+	require.True(t, start.Before(end))
+	require.False(t, end.After(now))
+
+	// Certificates of a rotated issuer renew over the next day.
+	start, end = computeAcmeRenewalWindow(cert, now, false, true)
+	require.Equal(t, now, start)
+	require.Equal(t, now.Add(acmeIssuerRotationRenewalSpread), end)
+
+	// Unless their regular window is sooner than that.
+	now = notBefore.Add(70 * 24 * time.Hour)
+	start, end = computeAcmeRenewalWindow(cert, now, false, true)
+	require.Equal(t, notBefore.Add(60*24*time.Hour), start)
+	require.Equal(t, now.Add(acmeIssuerRotationRenewalSpread), end)
+
+	now = notBefore.Add(89 * 24 * time.Hour)
+	start, end = computeAcmeRenewalWindow(cert, now, false, true)
+	require.Equal(t, notBefore.Add(60*24*time.Hour), start)
+	require.Equal(t, notBefore.Add(75*24*time.Hour), end)
+}
+
+func TestAcmeRenewalInfo_ParseCertId(t *testing.T) {
+	t.Parallel()
+
+	// Example from draft-ietf-acme-ari, Section 4.1.
+	authorityKeyId, serial, err := parseAcmeCertId("aYhba4dGQEHhs3uEe6CuLN4ByNQ.AIdlQyE")
+	require.NoError(t, err)
+	require.Equal(t, []byte{
+		0x69, 0x88, 0x5b, 0x6b, 0x87, 0x46, 0x40, 0x41, 0xe1, 0xb3,
+		0x7b, 0x84, 0x7b, 0xa0, 0xae, 0x2c, 0xde, 0x01, 0xc8, 0xd4,
+	}, authorityKeyId)
+	require.Equal(t, "87:65:43:21", serial)
+
+	for _, certId := range []string{"", "aYhba4dGQEHhs3uEe6CuLN4ByNQ", ".AIdlQyE", "aYhba4dGQEHhs3uEe6CuLN4ByNQ.", "a+b.AIdlQyE"} {
+		_, _, err = parseAcmeCertId(certId)
+		require.ErrorIs(t, err, ErrMalformed, "expected error parsing %q", certId)
+	}
+}
+
+func TestAcmeRenewalInfo(t *testing.T) {
+	t.Parallel()
+
+	cluster, client, _ := setupAcmeBackend(t)
+	defer cluster.Cleanup()
+	testCtx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	_, err := client.Logical().WriteWithContext(testCtx, "sys/mounts/pki/tune", map[string]interface{}{
+		"allowed_response_headers": []string{"Last-Modified", "Replay-Nonce", "Link", "Location", "Retry-After"},
+	})
+	require.NoError(t, err, "failed tuning mount response headers")
+
+	baseAcmeURL := "/v1/pki/acme/"
+	accountKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err, "failed creating ec key")
+	acmeClient := getAcmeClientForCluster(t, cluster, baseAcmeURL, accountKey)
+
+	_, certs := doACMEWorkflow(t, client, acmeClient)
+	leaf, err := x509.ParseCertificate(certs[0])
+	require.NoError(t, err, "failed parsing leaf certificate")
+	certId := acmeCertIdForTest(leaf)
+
+	// The directory advertises the renewal information endpoint.
+	dir, err := acmeClient.Discover(testCtx)
+	require.NoError(t, err, "failed fetching directory")
+	var directory map[string]interface{}
+	status, _, body := acmeTestGet(t, acmeClient.HTTPClient, acmeClient.DirectoryURL)
+	require.Equal(t, http.StatusOK, status)
+	require.NoError(t, json.Unmarshal(body, &directory))
+	renewalInfoURL, ok := directory["renewalInfo"].(string)
+	require.True(t, ok, "directory missing renewalInfo: %v", directory)
+
+	// The default window is towards the end of the certificate's lifetime.
+	start, end, retryAfter := acmeTestRenewalInfo(t, acmeClient.HTTPClient, renewalInfoURL+"/"+certId)
+	require.Equal(t, "21600", retryAfter)
+	lifetime := leaf.NotAfter.Sub(leaf.NotBefore)
+	require.WithinDuration(t, leaf.NotAfter.Add(-lifetime/3), start, time.Second)
+	require.WithinDuration(t, leaf.NotAfter.Add(-lifetime/6), end, time.Second)
+
+	// Unknown or malformed certificate identifiers are rejected.
+	status, _, _ = acmeTestGet(t, acmeClient.HTTPClient, renewalInfoURL+"/"+base64.RawURLEncoding.EncodeToString(leaf.AuthorityKeyId)+".AIdlQyE")
+	require.Equal(t, http.StatusNotFound, status)
+	status, _, _ = acmeTestGet(t, acmeClient.HTTPClient, renewalInfoURL+"/"+base64.RawURLEncoding.EncodeToString([]byte("other"))+"."+strings.Split(certId, ".")[1])
+	require.Equal(t, http.StatusNotFound, status)
+	status, _, _ = acmeTestGet(t, acmeClient.HTTPClient, renewalInfoURL+"/not-a-cert-id")
+	require.Equal(t, http.StatusBadRequest, status)
+
+	// Replace the certificate through a new order.
+	acct, err := acmeClient.GetReg(testCtx, "")
+	require.NoError(t, err, "failed looking up account")
+	orderURL, orderResp := acmeTestNewOrder(t, acmeClient, accountKey, acct.URI, dir.OrderURL, certId, http.StatusCreated)
+	require.Equal(t, certId, orderResp["replaces"])
+
+	order, err := acmeClient.GetOrder(testCtx, orderURL)
+	require.NoError(t, err, "failed fetching order")
+	markAuthorizationSuccess(t, client, acmeClient, acct, order)
+
+	csrKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err, "failed generated key for CSR")
+	csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{DNSNames: []string{"*.localdomain"}}, csrKey)
+	require.NoError(t, err, "failed generating csr")
+	replacementCerts, _, err := acmeClient.CreateOrderCert(testCtx, order.FinalizeURL, csr, true)
+	require.NoError(t, err, "failed finalizing order")
+	replacement, err := x509.ParseCertificate(replacementCerts[0])
+	require.NoError(t, err, "failed parsing replacement certificate")
+
+	// Once replaced, the certificate can't be replaced again.
+	_, orderResp = acmeTestNewOrder(t, acmeClient, accountKey, acct.URI, dir.OrderURL, certId, http.StatusConflict)
+	require.Equal(t, "urn:ietf:params:acme:error:alreadyReplaced", orderResp["type"])
+
+	// Nor can certificates of other accounts, or without shared identifiers.
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err, "failed creating ec key")
+	otherClient := getAcmeClientForCluster(t, cluster, baseAcmeURL, otherKey)
+	otherAcct, err := otherClient.Register(testCtx, &acme.Account{}, func(tosURL string) bool { return true })
+	require.NoError(t, err, "failed registering account")
+	_, orderResp = acmeTestNewOrder(t, otherClient, otherKey, otherAcct.URI, dir.OrderURL, acmeCertIdForTest(replacement), http.StatusBadRequest)
+	require.Equal(t, "urn:ietf:params:acme:error:malformed", orderResp["type"])
+
+	_, orderResp = acmeTestNewOrderForIdentifier(t, acmeClient, accountKey, acct.URI, dir.OrderURL, "other.localdomain", acmeCertIdForTest(replacement), http.StatusBadRequest)
+	require.Equal(t, "urn:ietf:params:acme:error:malformed", orderResp["type"])
+
+	// Rotating the directory's issuer makes its certificates renew soon.
+	resp, err := client.Logical().WriteWithContext(testCtx, "pki/issuers/generate/root/internal", map[string]interface{}{
+		"issuer_name": "new-root",
+		"key_type":    "ec",
+		"common_name": "Test Root R2",
+		"ttl":         "7200h",
+	})
+	require.NoError(t, err, "failed creating new root")
+	require.NotNil(t, resp)
+	_, err = client.Logical().WriteWithContext(testCtx, "pki/config/issuers", map[string]interface{}{
+		"default": "new-root",
+	})
+	require.NoError(t, err, "failed updating default issuer")
+
+	now := time.Now()
+	start, end, _ = acmeTestRenewalInfo(t, acmeClient.HTTPClient, renewalInfoURL+"/"+acmeCertIdForTest(replacement))
+	require.False(t, start.After(now.Add(time.Minute)))
+	require.False(t, end.After(now.Add(acmeIssuerRotationRenewalSpread+time.Minute)))
+
+	// Revoked certificates should be renewed right away.
+	_, err = client.Logical().WriteWithContext(testCtx, "pki/revoke", map[string]interface{}{
+		"serial_number": serialFromCert(leaf),
+	})
+	require.NoError(t, err, "failed revoking certificate")
+	_, end, _ = acmeTestRenewalInfo(t, acmeClient.HTTPClient, renewalInfoURL+"/"+certId)
+	require.False(t, end.After(time.Now()))
+}
+
+// acmeCertIdForTest builds the ACME Renewal Information identifier of a
+// certificate, per draft-ietf-acme-ari, Section 4.1.
+func acmeCertIdForTest(cert *x509.Certificate) string {
+	serial := cert.SerialNumber.Bytes()
+	if len(serial) == 0 || serial[0]&0x80 != 0 {
+		serial = append([]byte{0}, serial...)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(cert.AuthorityKeyId) + "." + base64.RawURLEncoding.EncodeToString(serial)
+}
+
+func acmeTestGet(t *testing.T, httpClient *http.Client, url string) (int, http.Header, []byte) {
+	t.Helper()
+
+	resp, err := httpClient.Get(url)
+	require.NoError(t, err, "failed fetching %s", url)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err, "failed reading response body")
+
+	return resp.StatusCode, resp.Header, body
+}
+
+func acmeTestRenewalInfo(t *testing.T, httpClient *http.Client, url string) (time.Time, time.Time, string) {
+	t.Helper()
+
+	status, headers, body := acmeTestGet(t, httpClient, url)
+	require.Equal(t, http.StatusOK, status, "unexpected response: %s", body)
+	require.Equal(t, "application/json", headers.Get("Content-Type"))
+
+	var renewalInfo struct {
+		SuggestedWindow struct {
+			Start time.Time `json:"start"`
+			End   time.Time `json:"end"`
+		} `json:"suggestedWindow"`
+	}
+	require.NoError(t, json.Unmarshal(body, &renewalInfo), "failed decoding renewal info: %s", body)
+	require.True(t, renewalInfo.SuggestedWindow.Start.Before(renewalInfo.SuggestedWindow.End), "invalid window: %s", body)
+
+	return renewalInfo.SuggestedWindow.Start, renewalInfo.SuggestedWindow.End, headers.Get("Retry-After")
+}
+
+func acmeTestNewOrder(t *testing.T, acmeClient *acme.Client, key crypto.Signer, kid string, url string, replaces string, expectedStatus int) (string, map[string]interface{}) {
+	return acmeTestNewOrderForIdentifier(t, acmeClient, key, kid, url, "*.localdomain", replaces, expectedStatus)
+}
+
+// acmeTestNewOrderForIdentifier creates an order replacing a certificate,
+// which the Go ACME client doesn't support.
+func acmeTestNewOrderForIdentifier(t *testing.T, acmeClient *acme.Client, key crypto.Signer, kid string, url string, identifier string, replaces string, expectedStatus int) (string, map[string]interface{}) {
+	t.Helper()
+
+	dir, err := acmeClient.Discover(context.Background())
+	require.NoError(t, err, "failed fetching directory")
+	nonceResp, err := acmeClient.HTTPClient.Head(dir.NonceURL)
+	require.NoError(t, err, "failed fetching nonce")
+	nonceResp.Body.Close()
+
+	signer, err := jose.NewSigner(jose.SigningKey{
+		Algorithm: jose.ES256,
+		Key:       jose.JSONWebKey{Key: key, KeyID: kid},
+	}, &jose.SignerOptions{
+		ExtraHeaders: map[jose.HeaderKey]interface{}{
+			"nonce": nonceResp.Header.Get("Replay-Nonce"),
+			"url":   url,
+		},
+	})
+	require.NoError(t, err, "failed creating signer")
+
+	payload, err := json.Marshal(map[string]interface{}{
+		"identifiers": []map[string]interface{}{{"type": "dns", "value": identifier}},
+		"replaces":    replaces,
+	})
+	require.NoError(t, err, "failed encoding payload")
+	jws, err := signer.Sign(payload)
+	require.NoError(t, err, "failed signing payload")
+
+	resp, err := acmeClient.HTTPClient.Post(url, "application/jose+json", bytes.NewBufferString(jws.FullSerialize()))
+	require.NoError(t, err, "failed creating order")
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err, "failed reading response body")
+	require.Equal(t, expectedStatus, resp.StatusCode, "unexpected response: %s", body)
+
+	var result map[string]interface{}
+	require.NoError(t, json.Unmarshal(body, &result), "failed decoding response: %s", body)
+
+	return resp.Header.Get("Location"), result
+}
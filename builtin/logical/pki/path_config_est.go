@@ -0,0 +1,437 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package pki
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/hashicorp/vault/builtin/logical/pki/issuing"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/helper/errutil"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+const (
+	storageEstConfig      = "config/est"
+	pathConfigEstHelpSyn  = "Configuration of EST Endpoints"
+	pathConfigEstHelpDesc = "Here we configure:\n\nenabled=false, whether EST is enabled, defaults to false,\ndefault_mount=false, whether this mount registers the default .well-known/est URL path,\ndefault_path_policy=\"\", either \"sign-verbatim\" or \"role:<role_name>\", the policy used for requests without an EST label,\nlabel_to_path_policy={}, a map of EST labels, registered as .well-known/est/<label> URL paths, to the policy used for their requests,\nauthenticators={}, the auth mounts, keyed by \"userpass\" and \"cert\", which EST delegates the authentication of enrollment requests to."
+
+	// estWellKnownPrefix is the .well-known path space the EST protocol is
+	// served under, per RFC 7030 Section 3.2.2.
+	estWellKnownPrefix = "est"
+
+	estAuthenticatorUserpass = "userpass"
+	estAuthenticatorCert     = "cert"
+)
+
+// estLabelRegex matches the labels EST clients may use as an additional path
+// segment; the operation names are not valid labels.
+var estLabelRegex = regexp.MustCompile(`^[a-zA-Z0-9._-]+$`)
+
+type estConfigEntry struct {
+	Enabled           bool              `json:"enabled"`
+	DefaultMount      bool              `json:"default_mount"`
+	DefaultPathPolicy string            `json:"default_path_policy"`
+	LabelToPathPolicy map[string]string `json:"label_to_path_policy"`
+	Authenticators    estAuthenticators `json:"authenticators"`
+	LastUpdated       time.Time         `json:"last_updated"`
+}
+
+type estAuthenticators struct {
+	Userpass *estUserpassAuthenticator `json:"userpass,omitempty"`
+	Cert     *estCertAuthenticator     `json:"cert,omitempty"`
+}
+
+type estUserpassAuthenticator struct {
+	Accessor string `json:"accessor"`
+}
+
+type estCertAuthenticator struct {
+	Accessor string `json:"accessor"`
+	CertRole string `json:"cert_role"`
+}
+
+// wellKnownRedirects returns the .well-known redirects the configuration
+// registers, keyed by their source. Redirects can't be nested within each
+// other, so the default mount serves its labels through its redirect of
+// .well-known/est as a whole.
+func (c *estConfigEntry) wellKnownRedirects() map[string]string {
+	redirects := map[string]string{}
+	if !c.Enabled {
+		return redirects
+	}
+
+	if c.DefaultMount {
+		redirects[estWellKnownPrefix] = "est"
+		return redirects
+	}
+	for label := range c.LabelToPathPolicy {
+		redirects[estWellKnownPrefix+"/"+label] = "est/" + label
+	}
+
+	return redirects
+}
+
+func (sc *storageContext) getEstConfig() (*estConfigEntry, error) {
+	entry, err := sc.Storage.Get(sc.Context, storageEstConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	var mapping estConfigEntry
+	if entry == nil {
+		mapping.LabelToPathPolicy = map[string]string{}
+		return &mapping, nil
+	}
+
+	if err := entry.DecodeJSON(&mapping); err != nil {
+		return nil, errutil.InternalError{Err: fmt.Sprintf("unable to decode EST configuration: %v", err)}
+	}
+	if mapping.LabelToPathPolicy == nil {
+		mapping.LabelToPathPolicy = map[string]string{}
+	}
+
+	return &mapping, nil
+}
+
+func (sc *storageContext) setEstConfig(entry *estConfigEntry) error {
+	json, err := logical.StorageEntryJSON(storageEstConfig, entry)
+	if err != nil {
+		return fmt.Errorf("failed creating storage entry: %w", err)
+	}
+
+	if err := sc.Storage.Put(sc.Context, json); err != nil {
+		return fmt.Errorf("failed writing storage entry: %w", err)
+	}
+
+	return nil
+}
+
+func pathConfigEst(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "config/est",
+
+		DisplayAttrs: &framework.DisplayAttributes{
+			OperationPrefix: operationPrefixPKI,
+		},
+
+		Fields: map[string]*framework.FieldSchema{
+			"enabled": {
+				Type:        framework.TypeBool,
+				Description: `whether EST is enabled, defaults to false meaning that clusters will by default not get EST support`,
+				Default:     false,
+			},
+			"default_mount": {
+				Type:        framework.TypeBool,
+				Description: `whether this mount registers the default .well-known/est URL path; only a single mount can enable this across a Vault cluster`,
+				Default:     false,
+			},
+			"default_path_policy": {
+				Type:        framework.TypeString,
+				Description: `the policy used for requests without an EST label, either "sign-verbatim" or a role given as "role:<role_name>"; required when default_mount is enabled`,
+			},
+			"label_to_path_policy": {
+				Type:        framework.TypeKVPairs,
+				Description: `a map of EST labels to the policy used for their requests, either "sign-verbatim" or a role given as "role:<role_name>"; labels must be unique across a Vault cluster, and register .well-known/est/<label> URL paths`,
+			},
+			"authenticators": {
+				Type:        framework.TypeMap,
+				Description: `the auth mounts EST delegates authentication to, keyed by "userpass" for HTTP Basic authentication or "cert" for TLS client certificate authentication; each takes the "accessor" of the auth mount, and "cert" an optional "cert_role" passed as the name of the certificate role to log in with`,
+			},
+		},
+
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.ReadOperation: &framework.PathOperation{
+				DisplayAttrs: &framework.DisplayAttributes{
+					OperationSuffix: "est-configuration",
+				},
+				Callback: b.pathEstConfigRead,
+			},
+			logical.UpdateOperation: &framework.PathOperation{
+				Callback: b.pathEstConfigWrite,
+				DisplayAttrs: &framework.DisplayAttributes{
+					OperationVerb:   "configure",
+					OperationSuffix: "est",
+				},
+				// Read more about why these flags are set in backend.go.
+				ForwardPerformanceStandby:   true,
+				ForwardPerformanceSecondary: true,
+			},
+		},
+
+		HelpSynopsis:    pathConfigEstHelpSyn,
+		HelpDescription: pathConfigEstHelpDesc,
+	}
+}
+
+func (b *backend) pathEstConfigRead(ctx context.Context, req *logical.Request, _ *framework.FieldData) (*logical.Response, error) {
+	sc := b.makeStorageContext(ctx, req.Storage)
+	config, err := sc.getEstConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	return genResponseFromEstConfig(config, nil), nil
+}
+
+func genResponseFromEstConfig(config *estConfigEntry, warnings []string) *logical.Response {
+	authenticators := map[string]interface{}{}
+	if config.Authenticators.Userpass != nil {
+		authenticators[estAuthenticatorUserpass] = map[string]interface{}{
+			"accessor": config.Authenticators.Userpass.Accessor,
+		}
+	}
+	if config.Authenticators.Cert != nil {
+		authenticators[estAuthenticatorCert] = map[string]interface{}{
+			"accessor":  config.Authenticators.Cert.Accessor,
+			"cert_role": config.Authenticators.Cert.CertRole,
+		}
+	}
+
+	var lastUpdated string
+	if !config.LastUpdated.IsZero() {
+		lastUpdated = config.LastUpdated.Format(time.RFC3339)
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"enabled":              config.Enabled,
+			"default_mount":        config.DefaultMount,
+			"default_path_policy":  config.DefaultPathPolicy,
+			"label_to_path_policy": config.LabelToPathPolicy,
+			"authenticators":       authenticators,
+			"last_updated":         lastUpdated,
+		},
+		Warnings: warnings,
+	}
+}
+
+func (b *backend) pathEstConfigWrite(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	sc := b.makeStorageContext(ctx, req.Storage)
+
+	b.estLock.Lock()
+	defer b.estLock.Unlock()
+
+	config, err := sc.getEstConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	if enabledRaw, ok := d.GetOk("enabled"); ok {
+		config.Enabled = enabledRaw.(bool)
+	}
+
+	if defaultMountRaw, ok := d.GetOk("default_mount"); ok {
+		config.DefaultMount = defaultMountRaw.(bool)
+	}
+
+	if defaultPathPolicyRaw, ok := d.GetOk("default_path_policy"); ok {
+		config.DefaultPathPolicy = defaultPathPolicyRaw.(string)
+	}
+
+	if labelToPathPolicyRaw, ok := d.GetOk("label_to_path_policy"); ok {
+		config.LabelToPathPolicy = labelToPathPolicyRaw.(map[string]string)
+	}
+
+	if authenticatorsRaw, ok := d.GetOk("authenticators"); ok {
+		authenticators, err := parseEstAuthenticators(authenticatorsRaw.(map[string]interface{}))
+		if err != nil {
+			return logical.ErrorResponse(err.Error()), nil
+		}
+		config.Authenticators = *authenticators
+	}
+
+	if config.DefaultMount && config.DefaultPathPolicy == "" {
+		return logical.ErrorResponse("default_path_policy is required when default_mount is enabled"), nil
+	}
+	if config.DefaultPathPolicy != "" {
+		if _, err := getEstPathPolicy(sc, config.DefaultPathPolicy); err != nil {
+			return logical.ErrorResponse(fmt.Sprintf("invalid default_path_policy: %v", err)), nil
+		}
+	}
+	for label, policy := range config.LabelToPathPolicy {
+		if !estLabelRegex.MatchString(label) || isEstOperation(label) {
+			return logical.ErrorResponse(fmt.Sprintf("invalid EST label %q", label)), nil
+		}
+		if _, err := getEstPathPolicy(sc, policy); err != nil {
+			return logical.ErrorResponse(fmt.Sprintf("invalid path policy for EST label %q: %v", label, err)), nil
+		}
+	}
+
+	var warnings []string
+	if config.Enabled && config.Authenticators.Userpass == nil && config.Authenticators.Cert == nil {
+		warnings = append(warnings, "no authenticators are configured, so EST clients can't enroll")
+	}
+
+	// Register the .well-known redirects ahead of persisting the
+	// configuration, so that conflicts with other mounts are rejected.
+	if err := b.updateEstRedirects(ctx, config); err != nil {
+		if err == errEstRedirectsUnsupported {
+			warnings = append(warnings, err.Error())
+		} else {
+			return logical.ErrorResponse(fmt.Sprintf("unable to register the .well-known/est paths: %v", err)), nil
+		}
+	}
+
+	config.LastUpdated = time.Now()
+	if err := sc.setEstConfig(config); err != nil {
+		return nil, err
+	}
+
+	return genResponseFromEstConfig(config, warnings), nil
+}
+
+func parseEstAuthenticators(raw map[string]interface{}) (*estAuthenticators, error) {
+	authenticators := &estAuthenticators{}
+	for name, settingsRaw := range raw {
+		settings, ok := settingsRaw.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("the settings of authenticator %q must be a map", name)
+		}
+
+		accessor, _ := settings["accessor"].(string)
+		if accessor == "" {
+			return nil, fmt.Errorf("authenticator %q is missing the accessor of its auth mount", name)
+		}
+
+		switch name {
+		case estAuthenticatorUserpass:
+			authenticators.Userpass = &estUserpassAuthenticator{Accessor: accessor}
+		case estAuthenticatorCert:
+			certRole, _ := settings["cert_role"].(string)
+			authenticators.Cert = &estCertAuthenticator{Accessor: accessor, CertRole: certRole}
+		default:
+			return nil, fmt.Errorf("unknown authenticator %q; valid authenticators are %q and %q", name, estAuthenticatorUserpass, estAuthenticatorCert)
+		}
+	}
+
+	return authenticators, nil
+}
+
+var errEstRedirectsUnsupported = fmt.Errorf("this mount does not support registering .well-known paths, so EST clients need to be pointed at the mount directly")
+
+// updateEstRedirects registers the .well-known redirects of the given EST
+// configuration, replacing those registered previously. When registering
+// fails, the previous redirects are restored. The caller must hold estLock.
+func (b *backend) updateEstRedirects(ctx context.Context, config *estConfigEntry) error {
+	wellKnown, ok := b.System().(logical.WellKnownSystemView)
+	if !ok {
+		return errEstRedirectsUnsupported
+	}
+
+	previous := b.estRedirects
+	register := func(redirects map[string]string) (map[string]string, error) {
+		registered := map[string]string{}
+		var errs error
+		for _, src := range sortedKeys(redirects) {
+			if err := wellKnown.RequestWellKnownRedirect(ctx, src, redirects[src]); err != nil {
+				errs = multierror.Append(errs, err)
+				continue
+			}
+			registered[src] = redirects[src]
+		}
+		return registered, errs
+	}
+	deregister := func(redirects map[string]string) {
+		for src := range redirects {
+			wellKnown.DeregisterWellKnownRedirect(ctx, src)
+		}
+	}
+
+	deregister(previous)
+	registered, err := register(config.wellKnownRedirects())
+	if err != nil {
+		deregister(registered)
+		b.estRedirects, _ = register(previous)
+		return err
+	}
+
+	b.estRedirects = registered
+	return nil
+}
+
+// reloadEstRedirects registers the .well-known redirects of the stored EST
+// configuration; conflicts with other mounts are only logged, as there is no
+// request to reject.
+func (b *backend) reloadEstRedirects(sc *storageContext) {
+	b.estLock.Lock()
+	defer b.estLock.Unlock()
+
+	config, err := sc.getEstConfig()
+	if err != nil {
+		b.Logger().Error("failed to load EST configuration", "error", err)
+		return
+	}
+
+	err = b.updateEstRedirects(sc.Context, config)
+	switch {
+	case err == errEstRedirectsUnsupported:
+	case err != nil:
+		b.Logger().Error("failed to register the .well-known/est paths", "error", err)
+	}
+}
+
+// removeEstRedirects deregisters all .well-known redirects of the mount.
+func (b *backend) removeEstRedirects(ctx context.Context) {
+	b.estLock.Lock()
+	defer b.estLock.Unlock()
+
+	wellKnown, ok := b.System().(logical.WellKnownSystemView)
+	if !ok {
+		return
+	}
+	for src := range b.estRedirects {
+		wellKnown.DeregisterWellKnownRedirect(ctx, src)
+	}
+	b.estRedirects = nil
+}
+
+// getEstPathPolicy resolves an EST path policy: "role:<role_name>" issues
+// certificates with the named role and its issuer, while "sign-verbatim"
+// signs CSRs verbatim with the default issuer.
+func getEstPathPolicy(sc *storageContext, policy string) (*estPolicy, error) {
+	switch {
+	case policy == "sign-verbatim":
+		return &estPolicy{
+			role: issuing.SignVerbatimRoleWithOpts(
+				issuing.WithIssuer(defaultRef),
+				issuing.WithNoStore(false)),
+			verbatim: true,
+		}, nil
+	case strings.HasPrefix(policy, rolePrefix):
+		return getEstRolePolicy(sc, policy[rolePrefixLength:])
+	default:
+		return nil, fmt.Errorf(`path policy %q must be "sign-verbatim" or "role:<role_name>"`, policy)
+	}
+}
+
+func getEstRolePolicy(sc *storageContext, roleName string) (*estPolicy, error) {
+	role, err := sc.Backend.GetRole(sc.Context, sc.Storage, roleName)
+	if err != nil {
+		return nil, err
+	}
+	if role == nil {
+		return nil, fmt.Errorf("role %q does not exist", roleName)
+	}
+	if role.Issuer == "" {
+		role.Issuer = defaultRef
+	}
+
+	return &estPolicy{role: role}, nil
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,178 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package pki
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/helper/errutil"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+const (
+	storageScepConfig      = "config/scep"
+	pathConfigScepHelpSyn  = "Configuration of SCEP Endpoints"
+	pathConfigScepHelpDesc = "Here we configure:\n\nenabled=false, whether SCEP is enabled, defaults to false,\ndefault_role=\"\", the role used for requests to the scep path of the mount, rather than that of a role,\nchallenge_ttl=\"1h\", the default lifetime of the challenge passwords minted for SCEP clients."
+
+	defaultScepChallengeTTL = 1 * time.Hour
+)
+
+type scepConfigEntry struct {
+	Enabled      bool          `json:"enabled"`
+	DefaultRole  string        `json:"default_role"`
+	ChallengeTTL time.Duration `json:"challenge_ttl"`
+	LastUpdated  time.Time     `json:"last_updated"`
+}
+
+func (sc *storageContext) getScepConfig() (*scepConfigEntry, error) {
+	entry, err := sc.Storage.Get(sc.Context, storageScepConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	var mapping scepConfigEntry
+	if entry == nil {
+		mapping.ChallengeTTL = defaultScepChallengeTTL
+		return &mapping, nil
+	}
+
+	if err := entry.DecodeJSON(&mapping); err != nil {
+		return nil, errutil.InternalError{Err: fmt.Sprintf("unable to decode SCEP configuration: %v", err)}
+	}
+
+	return &mapping, nil
+}
+
+func (sc *storageContext) setScepConfig(entry *scepConfigEntry) error {
+	json, err := logical.StorageEntryJSON(storageScepConfig, entry)
+	if err != nil {
+		return fmt.Errorf("failed creating storage entry: %w", err)
+	}
+
+	if err := sc.Storage.Put(sc.Context, json); err != nil {
+		return fmt.Errorf("failed writing storage entry: %w", err)
+	}
+
+	return nil
+}
+
+func pathConfigScep(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "config/scep",
+
+		DisplayAttrs: &framework.DisplayAttributes{
+			OperationPrefix: operationPrefixPKI,
+		},
+
+		Fields: map[string]*framework.FieldSchema{
+			"enabled": {
+				Type:        framework.TypeBool,
+				Description: `whether SCEP is enabled, defaults to false meaning that clusters will by default not get SCEP support`,
+				Default:     false,
+			},
+			"default_role": {
+				Type:        framework.TypeString,
+				Description: `the role used for requests to the scep path of the mount; when empty, SCEP clients need to use the scep path of a role`,
+			},
+			"challenge_ttl": {
+				Type:        framework.TypeDurationSecond,
+				Description: `the default lifetime of the challenge passwords minted for SCEP clients, defaults to 1 hour`,
+				Default:     int(defaultScepChallengeTTL.Seconds()),
+			},
+		},
+
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.ReadOperation: &framework.PathOperation{
+				DisplayAttrs: &framework.DisplayAttributes{
+					OperationSuffix: "scep-configuration",
+				},
+				Callback: b.pathScepConfigRead,
+			},
+			logical.UpdateOperation: &framework.PathOperation{
+				Callback: b.pathScepConfigWrite,
+				DisplayAttrs: &framework.DisplayAttributes{
+					OperationVerb:   "configure",
+					OperationSuffix: "scep",
+				},
+				// Read more about why these flags are set in backend.go.
+				ForwardPerformanceStandby:   true,
+				ForwardPerformanceSecondary: true,
+			},
+		},
+
+		HelpSynopsis:    pathConfigScepHelpSyn,
+		HelpDescription: pathConfigScepHelpDesc,
+	}
+}
+
+func (b *backend) pathScepConfigRead(ctx context.Context, req *logical.Request, _ *framework.FieldData) (*logical.Response, error) {
+	sc := b.makeStorageContext(ctx, req.Storage)
+	config, err := sc.getScepConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	return genResponseFromScepConfig(config), nil
+}
+
+func genResponseFromScepConfig(config *scepConfigEntry) *logical.Response {
+	var lastUpdated string
+	if !config.LastUpdated.IsZero() {
+		lastUpdated = config.LastUpdated.Format(time.RFC3339)
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"enabled":       config.Enabled,
+			"default_role":  config.DefaultRole,
+			"challenge_ttl": int64(config.ChallengeTTL.Seconds()),
+			"last_updated":  lastUpdated,
+		},
+	}
+}
+
+func (b *backend) pathScepConfigWrite(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	sc := b.makeStorageContext(ctx, req.Storage)
+
+	config, err := sc.getScepConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	if enabledRaw, ok := d.GetOk("enabled"); ok {
+		config.Enabled = enabledRaw.(bool)
+	}
+
+	if defaultRoleRaw, ok := d.GetOk("default_role"); ok {
+		config.DefaultRole = defaultRoleRaw.(string)
+	}
+
+	if challengeTTLRaw, ok := d.GetOk("challenge_ttl"); ok {
+		config.ChallengeTTL = time.Duration(challengeTTLRaw.(int)) * time.Second
+	}
+
+	if config.ChallengeTTL <= 0 {
+		return logical.ErrorResponse("challenge_ttl must be greater than zero"), nil
+	}
+
+	if config.DefaultRole != "" {
+		role, err := sc.Backend.GetRole(sc.Context, sc.Storage, config.DefaultRole)
+		if err != nil {
+			return nil, err
+		}
+		if role == nil {
+			return logical.ErrorResponse(fmt.Sprintf("default_role %q does not exist", config.DefaultRole)), nil
+		}
+	}
+
+	config.LastUpdated = time.Now()
+	if err := sc.setScepConfig(config); err != nil {
+		return nil, err
+	}
+
+	return genResponseFromScepConfig(config), nil
+}
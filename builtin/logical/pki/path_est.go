@@ -0,0 +1,640 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package pki
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/vault/builtin/logical/pki/issuing"
+	"github.com/hashicorp/vault/helper/pkcs7"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/helper/certutil"
+	"github.com/hashicorp/vault/sdk/helper/consts"
+	"github.com/hashicorp/vault/sdk/helper/errutil"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+const (
+	estOperationCACerts        = "cacerts"
+	estOperationSimpleEnroll   = "simpleenroll"
+	estOperationSimpleReenroll = "simplereenroll"
+	estOperationCSRAttrs       = "csrattrs"
+
+	estCertsOnlyContentType = "application/pkcs7-mime; smime-type=certs-only"
+	estCSRAttrsContentType  = "application/csrattrs"
+
+	// estMaximumRequestSize bounds the size of the base64 encoded CSRs read
+	// from enrollment requests.
+	estMaximumRequestSize = 64 * 1024
+)
+
+var (
+	oidRSAEncryption = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 1}
+	oidECPublicKey   = asn1.ObjectIdentifier{1, 2, 840, 10045, 2, 1}
+	oidEd25519       = asn1.ObjectIdentifier{1, 3, 101, 112}
+
+	oidNamedCurves = map[int]asn1.ObjectIdentifier{
+		224: {1, 3, 132, 0, 33},
+		256: {1, 2, 840, 10045, 3, 1, 7},
+		384: {1, 3, 132, 0, 34},
+		521: {1, 3, 132, 0, 35},
+	}
+
+	oidExtensionSubjectAltName = asn1.ObjectIdentifier{2, 5, 29, 17}
+)
+
+// estPolicy is the role and issuer certificates are issued with on behalf of
+// EST clients.
+type estPolicy struct {
+	role *issuing.RoleEntry
+	// verbatim is set for the sign-verbatim path policy, which copies the
+	// values of CSRs into the certificates.
+	verbatim bool
+}
+
+func isEstOperation(name string) bool {
+	switch name {
+	case estOperationCACerts, estOperationSimpleEnroll, estOperationSimpleReenroll, estOperationCSRAttrs:
+		return true
+	}
+	return false
+}
+
+// buildEstPaths returns the EST protocol paths (RFC 7030 Section 3.2.2)
+// served under the given prefix.
+func buildEstPaths(b *backend, prefix string, fields map[string]*framework.FieldSchema) []*framework.Path {
+	return []*framework.Path{
+		{
+			Pattern: prefix + "/" + estOperationCACerts,
+			DisplayAttrs: &framework.DisplayAttributes{
+				OperationPrefix: operationPrefixPKI,
+				OperationVerb:   "read",
+				OperationSuffix: "est-ca-certificates",
+			},
+			Fields: fields,
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.ReadOperation: &framework.PathOperation{
+					Callback: b.pathEstCACerts,
+				},
+			},
+			HelpSynopsis:    pathEstCACertsHelpSyn,
+			HelpDescription: pathEstHelpDesc,
+		},
+		{
+			Pattern: prefix + "/" + estOperationCSRAttrs,
+			DisplayAttrs: &framework.DisplayAttributes{
+				OperationPrefix: operationPrefixPKI,
+				OperationVerb:   "read",
+				OperationSuffix: "est-csr-attributes",
+			},
+			Fields: fields,
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.ReadOperation: &framework.PathOperation{
+					Callback: b.pathEstCSRAttrs,
+				},
+			},
+			HelpSynopsis:    pathEstCSRAttrsHelpSyn,
+			HelpDescription: pathEstHelpDesc,
+		},
+		{
+			Pattern: prefix + "/" + estOperationSimpleEnroll,
+			DisplayAttrs: &framework.DisplayAttributes{
+				OperationPrefix: operationPrefixPKI,
+				OperationVerb:   "est-enroll",
+			},
+			Fields: fields,
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.UpdateOperation: &framework.PathOperation{
+					Callback: b.pathEstSimpleEnroll,
+				},
+			},
+			HelpSynopsis:    pathEstSimpleEnrollHelpSyn,
+			HelpDescription: pathEstHelpDesc,
+		},
+		{
+			Pattern: prefix + "/" + estOperationSimpleReenroll,
+			DisplayAttrs: &framework.DisplayAttributes{
+				OperationPrefix: operationPrefixPKI,
+				OperationVerb:   "est-reenroll",
+			},
+			Fields: fields,
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.UpdateOperation: &framework.PathOperation{
+					Callback: b.pathEstSimpleReenroll,
+				},
+			},
+			HelpSynopsis:    pathEstSimpleReenrollHelpSyn,
+			HelpDescription: pathEstHelpDesc,
+		},
+	}
+}
+
+// setupEstPaths adds the EST protocol paths of the default path policy, of
+// the EST labels and of the roles to the backend.
+func setupEstPaths(b *backend) {
+	for _, prefix := range []struct {
+		pattern      string
+		unauthPrefix string
+		fields       map[string]*framework.FieldSchema
+	}{
+		{
+			"est",
+			"est",
+			map[string]*framework.FieldSchema{},
+		},
+		{
+			"est/" + framework.GenericNameRegex("label"),
+			"est/+",
+			map[string]*framework.FieldSchema{
+				"label": {
+					Type:        framework.TypeString,
+					Description: `The EST label, selecting the path policy configured for it.`,
+					Required:    true,
+				},
+			},
+		},
+		{
+			"roles/" + framework.GenericNameRegex("role") + "/est",
+			"roles/+/est",
+			map[string]*framework.FieldSchema{
+				"role": {
+					Type:        framework.TypeString,
+					Description: `The role to issue certificates with; it must be used by one of the EST path policies.`,
+					Required:    true,
+				},
+			},
+		},
+	} {
+		b.Backend.Paths = append(b.Backend.Paths, buildEstPaths(b, prefix.pattern, prefix.fields)...)
+
+		for _, operation := range []string{estOperationCACerts, estOperationCSRAttrs, estOperationSimpleEnroll, estOperationSimpleReenroll} {
+			b.PathsSpecial.Unauthenticated = append(b.PathsSpecial.Unauthenticated, prefix.unauthPrefix+"/"+operation)
+		}
+
+		// The enrollment requests carry DER encoded CSRs rather than JSON.
+		b.PathsSpecial.Binary = append(b.PathsSpecial.Binary,
+			prefix.unauthPrefix+"/"+estOperationSimpleEnroll,
+			prefix.unauthPrefix+"/"+estOperationSimpleReenroll)
+	}
+}
+
+func (b *backend) pathEstCACerts(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	sc := b.makeStorageContext(ctx, req.Storage)
+	_, policy, resp, err := b.getEstConfigAndPolicy(sc, data)
+	if resp != nil || err != nil {
+		return resp, err
+	}
+
+	issuerId, err := sc.resolveIssuerReference(policy.role.Issuer)
+	if err != nil {
+		return logical.ErrorResponse(fmt.Sprintf("failed to resolve issuer %q: %v", policy.role.Issuer, err)), nil
+	}
+	issuer, err := sc.fetchIssuerById(issuerId)
+	if err != nil {
+		return nil, err
+	}
+
+	chain := issuer.CAChain
+	if len(chain) == 0 {
+		chain = []string{issuer.Certificate}
+	}
+
+	var certs []byte
+	for _, certPem := range chain {
+		block, _ := pem.Decode([]byte(certPem))
+		if block == nil {
+			return nil, fmt.Errorf("failed to decode the certificate chain of issuer %v", issuerId)
+		}
+		certs = append(certs, block.Bytes...)
+	}
+
+	return estCertsOnlyResponse(certs)
+}
+
+func (b *backend) pathEstCSRAttrs(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	sc := b.makeStorageContext(ctx, req.Storage)
+	_, policy, resp, err := b.getEstConfigAndPolicy(sc, data)
+	if resp != nil || err != nil {
+		return resp, err
+	}
+
+	attrs, err := estCSRAttributes(policy)
+	if err != nil {
+		return nil, err
+	}
+	if attrs == nil {
+		return &logical.Response{
+			Data: map[string]interface{}{
+				logical.HTTPStatusCode: http.StatusNoContent,
+			},
+		}, nil
+	}
+
+	return estBase64Response(estCSRAttrsContentType, attrs), nil
+}
+
+func (b *backend) pathEstSimpleEnroll(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	return b.estEnroll(ctx, req, data, false)
+}
+
+func (b *backend) pathEstSimpleReenroll(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	return b.estEnroll(ctx, req, data, true)
+}
+
+func (b *backend) estEnroll(ctx context.Context, req *logical.Request, data *framework.FieldData, reenroll bool) (*logical.Response, error) {
+	sc := b.makeStorageContext(ctx, req.Storage)
+	config, policy, resp, err := b.getEstConfigAndPolicy(sc, data)
+	if resp != nil || err != nil {
+		return resp, err
+	}
+
+	// EST clients authenticate against an auth mount rather than with a
+	// Vault token; once the delegated login succeeds, Vault reissues the
+	// request with the resulting token, checked against the ACL policies.
+	if req.ClientTokenSource != logical.ClientTokenFromInternalAuth {
+		return estDelegateAuth(req, config)
+	}
+
+	// Issued certificates are stored unless the role says otherwise, so
+	// forward the request on to the primary in that case.
+	if !policy.role.NoStore && b.System().ReplicationState().HasState(consts.ReplicationPerformanceStandby) {
+		return nil, logical.ErrReadOnly
+	}
+
+	csr, err := readEstCSR(req)
+	if err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+
+	if reenroll {
+		if err := checkEstReenrollment(sc, req, csr); err != nil {
+			return logical.ErrorResponse(err.Error()), nil
+		}
+	}
+
+	parsedBundle, err := b.issueEstCertificate(sc, req, policy, csr)
+	if err != nil {
+		switch err.(type) {
+		case errutil.UserError:
+			return logical.ErrorResponse(err.Error()), nil
+		default:
+			return nil, err
+		}
+	}
+
+	return estCertsOnlyResponse(parsedBundle.Certificate.Raw)
+}
+
+// getEstConfigAndPolicy loads the EST configuration, and resolves the path
+// policy of the request from its label or role.
+func (b *backend) getEstConfigAndPolicy(sc *storageContext, data *framework.FieldData) (*estConfigEntry, *estPolicy, *logical.Response, error) {
+	config, err := sc.getEstConfig()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if !config.Enabled {
+		return nil, nil, logical.ErrorResponse("EST is not enabled on this mount"), nil
+	}
+
+	var policy *estPolicy
+	if roleRaw, ok := data.GetOk("role"); ok {
+		roleName := roleRaw.(string)
+		if !config.usesRole(roleName) {
+			return nil, nil, logical.ErrorResponse(fmt.Sprintf("role %q is not used by any EST path policy", roleName)), nil
+		}
+		policy, err = getEstRolePolicy(sc, roleName)
+	} else if labelRaw, ok := data.GetOk("label"); ok {
+		label := labelRaw.(string)
+		pathPolicy, ok := config.LabelToPathPolicy[label]
+		if !ok {
+			return nil, nil, logical.ErrorResponse(fmt.Sprintf("unknown EST label %q", label)), nil
+		}
+		policy, err = getEstPathPolicy(sc, pathPolicy)
+	} else {
+		if config.DefaultPathPolicy == "" {
+			return nil, nil, logical.ErrorResponse("no default_path_policy is configured for EST requests without a label"), nil
+		}
+		policy, err = getEstPathPolicy(sc, config.DefaultPathPolicy)
+	}
+	if err != nil {
+		return nil, nil, logical.ErrorResponse(fmt.Sprintf("failed to load EST path policy: %v", err)), nil
+	}
+
+	return config, policy, nil, nil
+}
+
+// usesRole returns whether any of the path policies uses the named role.
+func (c *estConfigEntry) usesRole(roleName string) bool {
+	if c.DefaultPathPolicy == rolePrefix+roleName {
+		return true
+	}
+	for _, policy := range c.LabelToPathPolicy {
+		if policy == rolePrefix+roleName {
+			return true
+		}
+	}
+	return false
+}
+
+// estDelegateAuth asks Vault to authenticate the request against the auth
+// mount matching the credentials the client provided; HTTP Basic credentials
+// are preferred over TLS client certificates.
+func estDelegateAuth(req *logical.Request, config *estConfigEntry) (*logical.Response, error) {
+	authenticators := config.Authenticators
+	username, password, hasBasicAuth := estBasicAuth(req)
+
+	switch {
+	case hasBasicAuth && authenticators.Userpass != nil:
+		return nil, logical.NewDelegatedAuthenticationRequest(authenticators.Userpass.Accessor,
+			"login/"+username, map[string]interface{}{"password": password}, estAuthErrorHandler(config))
+	case hasEstClientCertificate(req) && authenticators.Cert != nil:
+		loginData := map[string]interface{}{}
+		if authenticators.Cert.CertRole != "" {
+			loginData["name"] = authenticators.Cert.CertRole
+		}
+		return nil, logical.NewDelegatedAuthenticationRequest(authenticators.Cert.Accessor,
+			"login", loginData, estAuthErrorHandler(config))
+	}
+
+	return estUnauthorizedResponse(config), nil
+}
+
+// estAuthErrorHandler turns failed delegated logins into the 401 responses
+// RFC 7030 Section 3.2.3 expects.
+func estAuthErrorHandler(config *estConfigEntry) logical.DelegatedAuthErrorHandler {
+	return func(_ context.Context, _, _ *logical.Request, authResponse *logical.Response, err error) (*logical.Response, error) {
+		if err != nil && !errors.Is(err, logical.ErrInvalidCredentials) && !errors.Is(err, logical.ErrPermissionDenied) {
+			return nil, err
+		}
+		return estUnauthorizedResponse(config), nil
+	}
+}
+
+func estUnauthorizedResponse(config *estConfigEntry) *logical.Response {
+	data := map[string]interface{}{
+		logical.HTTPContentType: "text/plain",
+		logical.HTTPStatusCode:  http.StatusUnauthorized,
+		logical.HTTPRawBody:     []byte("authentication required"),
+	}
+	if config.Authenticators.Userpass != nil {
+		data[logical.HTTPWWWAuthenticateHeader] = `Basic realm="estrealm"`
+	}
+
+	return &logical.Response{Data: data}
+}
+
+func estBasicAuth(req *logical.Request) (string, string, bool) {
+	if req.HTTPRequest == nil {
+		return "", "", false
+	}
+	return req.HTTPRequest.BasicAuth()
+}
+
+func hasEstClientCertificate(req *logical.Request) bool {
+	return estClientCertificate(req) != nil
+}
+
+func estClientCertificate(req *logical.Request) *x509.Certificate {
+	if req.Connection == nil || req.Connection.ConnState == nil || len(req.Connection.ConnState.PeerCertificates) == 0 {
+		return nil
+	}
+	return req.Connection.ConnState.PeerCertificates[0]
+}
+
+// readEstCSR reads the base64 encoded PKCS#10 CSR making up the body of
+// enrollment requests (RFC 7030 Section 4.2.1).
+func readEstCSR(req *logical.Request) (*x509.CertificateRequest, error) {
+	if req.HTTPRequest == nil || req.HTTPRequest.Body == nil {
+		return nil, errors.New("no certificate signing request in request body")
+	}
+	defer req.HTTPRequest.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(req.HTTPRequest.Body, estMaximumRequestSize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read request body: %w", err)
+	}
+	if len(body) >= estMaximumRequestSize {
+		return nil, errors.New("request is too large")
+	}
+
+	// Clients may wrap the base64 encoding across lines.
+	der, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(string(body)), ""))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode base64 certificate signing request: %w", err)
+	}
+
+	csr, err := x509.ParseCertificateRequest(der)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse certificate signing request: %w", err)
+	}
+	if err := csr.CheckSignature(); err != nil {
+		return nil, fmt.Errorf("invalid certificate signing request signature: %w", err)
+	}
+
+	return csr, nil
+}
+
+// checkEstReenrollment verifies that the client authenticated the TLS
+// connection with a valid certificate issued by this mount, and that the
+// CSR keeps its Subject and SubjectAltName, per RFC 7030 Section 4.2.2.
+func checkEstReenrollment(sc *storageContext, req *logical.Request, csr *x509.CertificateRequest) error {
+	cert := estClientCertificate(req)
+	if cert == nil {
+		return errors.New("re-enrollment requires the client to present the certificate being renewed")
+	}
+
+	now := time.Now()
+	if now.Before(cert.NotBefore) || now.After(cert.NotAfter) {
+		return errors.New("the certificate being renewed is not valid at this time")
+	}
+
+	issued, err := isIssuedByMount(sc, cert)
+	if err != nil {
+		return err
+	}
+	if !issued {
+		return errors.New("the certificate being renewed was not issued by this mount")
+	}
+
+	revInfo, err := sc.fetchRevocationInfo(serialFromCert(cert))
+	if err != nil {
+		return err
+	}
+	if revInfo != nil {
+		return errors.New("the certificate being renewed has been revoked")
+	}
+
+	if !bytes.Equal(csr.RawSubject, cert.RawSubject) {
+		return errors.New("the subject of the certificate signing request differs from the certificate being renewed")
+	}
+	if !bytes.Equal(subjectAltNameExtension(csr.Extensions), subjectAltNameExtension(cert.Extensions)) {
+		return errors.New("the subject alternative names of the certificate signing request differ from the certificate being renewed")
+	}
+
+	return nil
+}
+
+// isIssuedByMount returns whether the certificate was signed by one of the
+// issuers of the mount.
+func isIssuedByMount(sc *storageContext, cert *x509.Certificate) (bool, error) {
+	issuerIds, err := sc.listIssuers()
+	if err != nil {
+		return false, err
+	}
+
+	for _, issuerId := range issuerIds {
+		issuer, err := sc.fetchIssuerById(issuerId)
+		if err != nil {
+			return false, err
+		}
+
+		issuerCert, err := issuer.GetCertificate()
+		if err != nil {
+			return false, err
+		}
+
+		if cert.CheckSignatureFrom(issuerCert) == nil {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func subjectAltNameExtension(extensions []pkix.Extension) []byte {
+	for _, ext := range extensions {
+		if ext.Id.Equal(oidExtensionSubjectAltName) {
+			return ext.Value
+		}
+	}
+	return nil
+}
+
+func (b *backend) issueEstCertificate(sc *storageContext, req *logical.Request, policy *estPolicy, csr *x509.CertificateRequest) (*certutil.ParsedCertBundle, error) {
+	pemCsr := string(pem.EncodeToMemory(&pem.Block{
+		Type:  "CERTIFICATE REQUEST",
+		Bytes: csr.Raw,
+	}))
+
+	data := &framework.FieldData{
+		Raw: map[string]interface{}{
+			"csr": pemCsr,
+		},
+		Schema: getCsrSignVerbatimSchemaFields(),
+	}
+
+	signingBundle, _, err := sc.fetchCAInfoWithIssuer(policy.role.Issuer, issuing.IssuanceUsage)
+	if err != nil {
+		return nil, fmt.Errorf("failed loading CA %s: %w", policy.role.Issuer, err)
+	}
+
+	input := &inputBundle{
+		req:     req,
+		apiData: data,
+		role:    policy.role,
+	}
+	parsedBundle, _, err := signCert(sc.Context, b, input, signingBundle, false /* is_ca=false */, policy.verbatim)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := parsedBundle.Verify(); err != nil {
+		return nil, fmt.Errorf("verification of parsed bundle failed: %w", err)
+	}
+
+	if !policy.role.NoStore {
+		if err := issuing.StoreCertificate(sc.Context, sc.Storage, b.GetCertificateCounter(), parsedBundle); err != nil {
+			return nil, err
+		}
+	}
+
+	return parsedBundle, nil
+}
+
+// estCSRAttributes returns the DER encoded CsrAttrs (RFC 7030 Section 4.5.2)
+// telling clients which key type the role requires, or nil when the path
+// policy has no requirements.
+func estCSRAttributes(policy *estPolicy) ([]byte, error) {
+	var attrs []interface{}
+	switch policy.role.KeyType {
+	case "rsa":
+		attrs = append(attrs, oidRSAEncryption)
+	case "ec":
+		curve, ok := oidNamedCurves[policy.role.KeyBits]
+		if !ok {
+			return nil, fmt.Errorf("unsupported EC key size %d", policy.role.KeyBits)
+		}
+		attrs = append(attrs, struct {
+			Type   asn1.ObjectIdentifier
+			Values []asn1.ObjectIdentifier `asn1:"set"`
+		}{oidECPublicKey, []asn1.ObjectIdentifier{curve}})
+	case "ed25519":
+		attrs = append(attrs, oidEd25519)
+	}
+	if len(attrs) == 0 {
+		return nil, nil
+	}
+
+	var encoded []asn1.RawValue
+	for _, attr := range attrs {
+		der, err := asn1.Marshal(attr)
+		if err != nil {
+			return nil, err
+		}
+		encoded = append(encoded, asn1.RawValue{FullBytes: der})
+	}
+
+	return asn1.Marshal(encoded)
+}
+
+func estCertsOnlyResponse(certs []byte) (*logical.Response, error) {
+	p7, err := pkcs7.DegenerateCertificate(certs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode certificates: %w", err)
+	}
+
+	return estBase64Response(estCertsOnlyContentType, p7), nil
+}
+
+// estBase64Response returns a raw response with a base64 encoded body, as
+// EST responses use a Content-Transfer-Encoding of base64.
+func estBase64Response(contentType string, body []byte) *logical.Response {
+	return &logical.Response{
+		Data: map[string]interface{}{
+			logical.HTTPContentType: contentType,
+			logical.HTTPStatusCode:  http.StatusOK,
+			logical.HTTPRawBody:     []byte(base64.StdEncoding.EncodeToString(body)),
+		},
+		Headers: map[string][]string{
+			"Content-Transfer-Encoding": {"base64"},
+		},
+	}
+}
+
+const (
+	pathEstCACertsHelpSyn        = `Fetch the CA certificates for EST clients.`
+	pathEstCSRAttrsHelpSyn       = `Fetch the CSR attributes EST clients should use.`
+	pathEstSimpleEnrollHelpSyn   = `Enroll an EST client, issuing a certificate for its CSR.`
+	pathEstSimpleReenrollHelpSyn = `Re-enroll an EST client, renewing the certificate it authenticated with.`
+	pathEstHelpDesc              = `
+These paths implement the Enrollment over Secure Transport protocol (RFC 7030),
+usually reached through the .well-known/est paths registered by the EST
+configuration. The cacerts and csrattrs operations are unauthenticated; the
+simpleenroll and simplereenroll operations delegate authentication to the auth
+mounts configured as EST authenticators, using HTTP Basic credentials or TLS
+client certificates.
+`
+)
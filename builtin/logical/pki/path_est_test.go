@@ -0,0 +1,490 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package pki
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/vault/api"
+	"github.com/hashicorp/vault/builtin/credential/userpass"
+	"github.com/hashicorp/vault/helper/pkcs7"
+	vaulthttp "github.com/hashicorp/vault/http"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/hashicorp/vault/vault"
+	"github.com/stretchr/testify/require"
+)
+
+// setupEstBackend creates a backend with a root issuer and an "est" role,
+// and enables EST with the given configuration.
+func setupEstBackend(t *testing.T, config map[string]interface{}) (*backend, logical.Storage) {
+	t.Helper()
+
+	b, s := CreateBackendWithStorage(t)
+
+	resp, err := CBWrite(b, s, "root/generate/internal", map[string]interface{}{
+		"common_name": "root-ca.com",
+		"key_type":    "ec",
+	})
+	requireSuccessNonNilResponse(t, resp, err, "failed generating root issuer")
+
+	resp, err = CBWrite(b, s, "roles/est", map[string]interface{}{
+		"allowed_domains":  "example.com",
+		"allow_subdomains": true,
+		"key_type":         "ec",
+		"key_bits":         384,
+		"ttl":              "1h",
+	})
+	requireSuccessNonNilResponse(t, resp, err, "failed creating role")
+
+	resp, err = CBWrite(b, s, "config/est", config)
+	requireSuccessNonNilResponse(t, resp, err, "failed configuring EST")
+
+	return b, s
+}
+
+func estEnrollRequest(s logical.Storage, path string, csr []byte) *logical.Request {
+	body := base64.StdEncoding.EncodeToString(csr)
+	httpReq, _ := http.NewRequest(http.MethodPost, "/v1/pki/"+path, strings.NewReader(body))
+
+	return &logical.Request{
+		Operation:         logical.UpdateOperation,
+		Path:              path,
+		Storage:           s,
+		HTTPRequest:       httpReq,
+		ClientTokenSource: logical.ClientTokenFromInternalAuth,
+		Connection:        &logical.Connection{},
+	}
+}
+
+func requireEstCertificates(t *testing.T, resp *logical.Response) []*x509.Certificate {
+	t.Helper()
+
+	require.NotNil(t, resp)
+	require.Equal(t, http.StatusOK, resp.Data[logical.HTTPStatusCode], "unexpected response: %v", resp.Data)
+	require.Equal(t, estCertsOnlyContentType, resp.Data[logical.HTTPContentType])
+
+	der, err := base64.StdEncoding.DecodeString(string(resp.Data[logical.HTTPRawBody].([]byte)))
+	require.NoError(t, err, "response body was not base64 encoded")
+
+	certs := parseEstCertsOnly(t, der)
+	require.NotEmpty(t, certs)
+	return certs
+}
+
+// parseEstCertsOnly returns the certificates of a degenerate PKCS#7
+// SignedData structure; pkcs7.Parse can't handle its empty content.
+func parseEstCertsOnly(t *testing.T, der []byte) []*x509.Certificate {
+	t.Helper()
+
+	var contentInfo struct {
+		ContentType asn1.ObjectIdentifier
+		Content     asn1.RawValue `asn1:"explicit,tag:0"`
+	}
+	_, err := asn1.Unmarshal(der, &contentInfo)
+	require.NoError(t, err, "response body was not a PKCS#7 structure")
+	require.True(t, contentInfo.ContentType.Equal(pkcs7.OIDSignedData))
+
+	var signedData struct {
+		Version          int
+		DigestAlgorithms asn1.RawValue
+		ContentInfo      asn1.RawValue
+		Certificates     asn1.RawValue `asn1:"optional,tag:0"`
+	}
+	_, err = asn1.Unmarshal(contentInfo.Content.Bytes, &signedData)
+	require.NoError(t, err, "response body was not a PKCS#7 SignedData structure")
+
+	certs, err := x509.ParseCertificates(signedData.Certificates.Bytes)
+	require.NoError(t, err)
+	return certs
+}
+
+func TestEst_Config(t *testing.T) {
+	t.Parallel()
+
+	b, s := CreateBackendWithStorage(t)
+
+	resp, err := CBRead(b, s, "config/est")
+	requireSuccessNonNilResponse(t, resp, err)
+	require.Equal(t, false, resp.Data["enabled"])
+	require.Equal(t, "", resp.Data["last_updated"])
+
+	resp, err = CBWrite(b, s, "roles/est", map[string]interface{}{
+		"allow_any_name": true,
+	})
+	requireSuccessNonNilResponse(t, resp, err)
+
+	// A default mount needs a default path policy.
+	resp, err = CBWrite(b, s, "config/est", map[string]interface{}{
+		"enabled":       true,
+		"default_mount": true,
+	})
+	require.Error(t, err, "expected error without default_path_policy")
+
+	// Path policies must be valid.
+	resp, err = CBWrite(b, s, "config/est", map[string]interface{}{
+		"enabled":             true,
+		"default_path_policy": "role:unknown",
+	})
+	require.Error(t, err, "expected error with unknown role")
+
+	// Labels can't shadow the EST operations.
+	resp, err = CBWrite(b, s, "config/est", map[string]interface{}{
+		"enabled":              true,
+		"label_to_path_policy": map[string]interface{}{"cacerts": "sign-verbatim"},
+	})
+	require.Error(t, err, "expected error with reserved label")
+
+	// Authenticators must be known.
+	resp, err = CBWrite(b, s, "config/est", map[string]interface{}{
+		"enabled":        true,
+		"authenticators": map[string]interface{}{"ldap": map[string]interface{}{"accessor": "auth_ldap_1234"}},
+	})
+	require.Error(t, err, "expected error with unknown authenticator")
+
+	resp, err = CBWrite(b, s, "config/est", map[string]interface{}{
+		"enabled":              true,
+		"default_mount":        true,
+		"default_path_policy":  "role:est",
+		"label_to_path_policy": map[string]interface{}{"test-label": "sign-verbatim"},
+		"authenticators": map[string]interface{}{
+			"userpass": map[string]interface{}{"accessor": "auth_userpass_1234"},
+			"cert":     map[string]interface{}{"accessor": "auth_cert_1234", "cert_role": "est-ca"},
+		},
+	})
+	requireSuccessNonNilResponse(t, resp, err)
+
+	resp, err = CBRead(b, s, "config/est")
+	requireSuccessNonNilResponse(t, resp, err)
+	require.Equal(t, true, resp.Data["enabled"])
+	require.Equal(t, true, resp.Data["default_mount"])
+	require.Equal(t, "role:est", resp.Data["default_path_policy"])
+	require.Equal(t, map[string]string{"test-label": "sign-verbatim"}, resp.Data["label_to_path_policy"])
+	require.NotEmpty(t, resp.Data["last_updated"])
+
+	authenticators := resp.Data["authenticators"].(map[string]interface{})
+	require.Equal(t, map[string]interface{}{"accessor": "auth_userpass_1234"}, authenticators["userpass"])
+	require.Equal(t, map[string]interface{}{"accessor": "auth_cert_1234", "cert_role": "est-ca"}, authenticators["cert"])
+}
+
+func TestEst_CACertsAndCSRAttrs(t *testing.T) {
+	t.Parallel()
+
+	b, s := setupEstBackend(t, map[string]interface{}{
+		"enabled":              true,
+		"default_path_policy":  "role:est",
+		"label_to_path_policy": map[string]interface{}{"verbatim": "sign-verbatim"},
+	})
+
+	resp, err := CBRead(b, s, "issuer/default/json")
+	requireSuccessNonNilResponse(t, resp, err)
+	issuerCert := parseCert(t, resp.Data["certificate"].(string))
+
+	for _, path := range []string{"est/cacerts", "est/verbatim/cacerts", "roles/est/est/cacerts"} {
+		resp, err = CBRead(b, s, path)
+		require.NoError(t, err, "failed reading %s", path)
+		certs := requireEstCertificates(t, resp)
+		require.Equal(t, issuerCert.Raw, certs[0].Raw, "unexpected CA from %s", path)
+	}
+
+	// Roles not used by any path policy aren't reachable over EST.
+	resp, err = CBWrite(b, s, "roles/other", map[string]interface{}{"allow_any_name": true})
+	requireSuccessNonNilResponse(t, resp, err)
+	resp, err = CBRead(b, s, "roles/other/est/cacerts")
+	require.Error(t, err, "expected error for role without EST path policy")
+
+	resp, err = CBRead(b, s, "est/unknown-label/cacerts")
+	require.Error(t, err, "expected error for unknown label")
+
+	// The role requires P-384 keys, which the CSR attributes advertise.
+	resp, err = CBRead(b, s, "est/csrattrs")
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.Data[logical.HTTPStatusCode])
+	require.Equal(t, estCSRAttrsContentType, resp.Data[logical.HTTPContentType])
+	der, err := base64.StdEncoding.DecodeString(string(resp.Data[logical.HTTPRawBody].([]byte)))
+	require.NoError(t, err)
+
+	var attrs []asn1.RawValue
+	rest, err := asn1.Unmarshal(der, &attrs)
+	require.NoError(t, err)
+	require.Empty(t, rest)
+	require.Len(t, attrs, 1)
+
+	var attr struct {
+		Type   asn1.ObjectIdentifier
+		Values []asn1.ObjectIdentifier `asn1:"set"`
+	}
+	_, err = asn1.Unmarshal(attrs[0].FullBytes, &attr)
+	require.NoError(t, err)
+	require.True(t, attr.Type.Equal(oidECPublicKey))
+	require.Len(t, attr.Values, 1)
+	require.True(t, attr.Values[0].Equal(oidNamedCurves[384]))
+
+	// sign-verbatim has no requirements, so there is nothing to return.
+	resp, err = CBRead(b, s, "est/verbatim/csrattrs")
+	require.NoError(t, err)
+	require.Equal(t, http.StatusNoContent, resp.Data[logical.HTTPStatusCode])
+
+	// Disabling EST disables every endpoint.
+	resp, err = CBWrite(b, s, "config/est", map[string]interface{}{"enabled": false})
+	requireSuccessNonNilResponse(t, resp, err)
+	resp, err = CBRead(b, s, "est/cacerts")
+	require.Error(t, err, "expected error with EST disabled")
+}
+
+func TestEst_Enroll(t *testing.T) {
+	t.Parallel()
+
+	b, s := setupEstBackend(t, map[string]interface{}{
+		"enabled":             true,
+		"default_path_policy": "role:est",
+		"authenticators": map[string]interface{}{
+			"userpass": map[string]interface{}{"accessor": "auth_userpass_1234"},
+		},
+	})
+	ctx := context.Background()
+
+	_, csr, _ := generateCSR(t, &x509.CertificateRequest{
+		Subject:  pkix.Name{CommonName: "device.example.com"},
+		DNSNames: []string{"device.example.com"},
+	}, "ec", 384)
+
+	// Requests that were not authenticated by Vault need to be delegated to
+	// the configured auth mount.
+	req := estEnrollRequest(s, "est/simpleenroll", csr)
+	req.ClientTokenSource = logical.ClientTokenFromVaultHeader
+	req.HTTPRequest.SetBasicAuth("device", "secret")
+	_, err := b.HandleRequest(ctx, req)
+	var daErr *logical.RequestDelegatedAuthError
+	require.True(t, errors.As(err, &daErr), "expected delegated auth request, got: %v", err)
+	require.Equal(t, "auth_userpass_1234", daErr.MountAccessor())
+	require.Equal(t, "login/device", daErr.Path())
+	require.Equal(t, map[string]interface{}{"password": "secret"}, daErr.Data())
+
+	// Failed logins turn into a challenge for the client.
+	resp, err := daErr.AuthErrorHandler()(ctx, req, nil, nil, logical.ErrInvalidCredentials)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusUnauthorized, resp.Data[logical.HTTPStatusCode])
+	require.Equal(t, `Basic realm="estrealm"`, resp.Data[logical.HTTPWWWAuthenticateHeader])
+
+	// As do requests without any credentials.
+	req = estEnrollRequest(s, "est/simpleenroll", csr)
+	req.ClientTokenSource = logical.ClientTokenFromVaultHeader
+	resp, err = b.HandleRequest(ctx, req)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusUnauthorized, resp.Data[logical.HTTPStatusCode])
+
+	// Authenticated requests get their certificate.
+	resp, err = b.HandleRequest(ctx, estEnrollRequest(s, "est/simpleenroll", csr))
+	require.NoError(t, err)
+	certs := requireEstCertificates(t, resp)
+	require.Len(t, certs, 1)
+	leaf := certs[0]
+	require.Equal(t, "device.example.com", leaf.Subject.CommonName)
+	require.Equal(t, []string{"device.example.com"}, leaf.DNSNames)
+
+	// The role's key requirements still apply.
+	_, badCsr, _ := generateCSR(t, &x509.CertificateRequest{
+		Subject: pkix.Name{CommonName: "device.example.com"},
+	}, "ec", 256)
+	resp, err = b.HandleRequest(ctx, estEnrollRequest(s, "est/simpleenroll", badCsr))
+	require.NoError(t, err)
+	require.True(t, resp.IsError(), "expected error with P-256 key: %v", resp)
+
+	// Garbage isn't a CSR.
+	resp, err = b.HandleRequest(ctx, estEnrollRequest(s, "est/simpleenroll", []byte("not a csr")))
+	require.NoError(t, err)
+	require.True(t, resp.IsError(), "expected error with invalid CSR: %v", resp)
+
+	// The certificate was stored.
+	resp, err = CBRead(b, s, "cert/"+serialFromCert(leaf))
+	requireSuccessNonNilResponse(t, resp, err)
+
+	// Re-enrollment requires the client certificate on the TLS connection.
+	_, renewCsr, _ := generateCSR(t, &x509.CertificateRequest{
+		Subject:  pkix.Name{CommonName: "device.example.com"},
+		DNSNames: []string{"device.example.com"},
+	}, "ec", 384)
+	resp, err = b.HandleRequest(ctx, estEnrollRequest(s, "est/simplereenroll", renewCsr))
+	require.NoError(t, err)
+	require.True(t, resp.IsError(), "expected error without client certificate: %v", resp)
+
+	reenroll := func(csr []byte) (*logical.Response, error) {
+		req := estEnrollRequest(s, "est/simplereenroll", csr)
+		req.Connection.ConnState = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{leaf}}
+		return b.HandleRequest(ctx, req)
+	}
+
+	resp, err = reenroll(renewCsr)
+	require.NoError(t, err)
+	renewed := requireEstCertificates(t, resp)[0]
+	require.Equal(t, leaf.Subject.String(), renewed.Subject.String())
+	require.NotEqual(t, serialFromCert(leaf), serialFromCert(renewed))
+
+	// The Subject can't change when re-enrolling.
+	_, otherCsr, _ := generateCSR(t, &x509.CertificateRequest{
+		Subject:  pkix.Name{CommonName: "other.example.com"},
+		DNSNames: []string{"device.example.com"},
+	}, "ec", 384)
+	resp, err = reenroll(otherCsr)
+	require.NoError(t, err)
+	require.True(t, resp.IsError(), "expected error with different subject: %v", resp)
+
+	// Nor can revoked certificates be re-enrolled.
+	resp, err = CBWrite(b, s, "revoke", map[string]interface{}{"serial_number": serialFromCert(leaf)})
+	requireSuccessNonNilResponse(t, resp, err)
+	resp, err = reenroll(renewCsr)
+	require.NoError(t, err)
+	require.True(t, resp.IsError(), "expected error with revoked certificate: %v", resp)
+}
+
+// TestEst_Integration enrolls through the .well-known paths, authenticating
+// with HTTP Basic credentials against a userpass mount.
+func TestEst_Integration(t *testing.T) {
+	t.Parallel()
+
+	coreConfig := &vault.CoreConfig{
+		CredentialBackends: map[string]logical.Factory{
+			"userpass": userpass.Factory,
+		},
+		LogicalBackends: map[string]logical.Factory{
+			"pki": Factory,
+		},
+	}
+	cluster := vault.NewTestCluster(t, coreConfig, &vault.TestClusterOptions{
+		HandlerFunc: vaulthttp.Handler,
+	})
+	cluster.Start()
+	defer cluster.Cleanup()
+	client := cluster.Cores[0].Client
+
+	err := client.Sys().EnableAuthWithOptions("userpass", &api.EnableAuthOptions{Type: "userpass"})
+	require.NoError(t, err)
+	err = client.Sys().PutPolicy("est-enroll", `path "pki/est/*" { capabilities = ["update"] }`)
+	require.NoError(t, err)
+	_, err = client.Logical().Write("auth/userpass/users/device", map[string]interface{}{
+		"password":   "secret",
+		"policies":   "est-enroll",
+		"token_type": "batch",
+	})
+	require.NoError(t, err)
+	_, err = client.Logical().Write("auth/userpass/users/no-policy", map[string]interface{}{
+		"password":   "secret",
+		"token_type": "batch",
+	})
+	require.NoError(t, err)
+
+	resp, err := client.Logical().Read("sys/mounts/auth/userpass")
+	require.NoError(t, err)
+	upAccessor := resp.Data["accessor"].(string)
+
+	err = client.Sys().Mount("pki", &api.MountInput{
+		Type: "pki",
+		Config: api.MountConfigInput{
+			DelegatedAuthAccessors: []string{upAccessor},
+			AllowedResponseHeaders: []string{"Content-Transfer-Encoding"},
+		},
+	})
+	require.NoError(t, err)
+
+	_, err = client.Logical().Write("pki/root/generate/internal", map[string]interface{}{
+		"common_name": "root-ca.com",
+		"key_type":    "ec",
+	})
+	require.NoError(t, err)
+	_, err = client.Logical().Write("pki/roles/est", map[string]interface{}{
+		"allowed_domains":  "example.com",
+		"allow_subdomains": true,
+		"key_type":         "any",
+		"ttl":              "1h",
+	})
+	require.NoError(t, err)
+	_, err = client.Logical().Write("pki/config/est", map[string]interface{}{
+		"enabled":              true,
+		"default_mount":        true,
+		"default_path_policy":  "role:est",
+		"label_to_path_policy": map[string]interface{}{"verbatim": "sign-verbatim"},
+		"authenticators": map[string]interface{}{
+			"userpass": map[string]interface{}{"accessor": upAccessor},
+		},
+	})
+	require.NoError(t, err)
+
+	httpClient := client.CloneConfig().HttpClient
+	estURL := client.Address() + "/.well-known/est/"
+
+	// cacerts doesn't need any authentication, and labels of the default
+	// mount are served through its redirect.
+	for _, path := range []string{"cacerts", "verbatim/cacerts"} {
+		httpResp, err := httpClient.Get(estURL + path)
+		require.NoError(t, err)
+		body, err := io.ReadAll(httpResp.Body)
+		httpResp.Body.Close()
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, httpResp.StatusCode, "unexpected response for %s: %s", path, body)
+		require.Equal(t, estCertsOnlyContentType, httpResp.Header.Get("Content-Type"))
+	}
+
+	_, csr, _ := generateCSR(t, &x509.CertificateRequest{
+		Subject:  pkix.Name{CommonName: "device.example.com"},
+		DNSNames: []string{"device.example.com"},
+	}, "ec", 256)
+
+	enroll := func(username, password string) (*http.Response, []byte) {
+		req, err := http.NewRequest(http.MethodPost, estURL+"simpleenroll",
+			bytes.NewBufferString(base64.StdEncoding.EncodeToString(csr)))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/pkcs10")
+		if username != "" {
+			req.SetBasicAuth(username, password)
+		}
+
+		resp, err := httpClient.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return resp, body
+	}
+
+	httpResp, _ := enroll("", "")
+	require.Equal(t, http.StatusUnauthorized, httpResp.StatusCode)
+	require.Equal(t, `Basic realm="estrealm"`, httpResp.Header.Get("WWW-Authenticate"))
+
+	httpResp, _ = enroll("device", "wrong")
+	require.Equal(t, http.StatusUnauthorized, httpResp.StatusCode)
+
+	// Authenticated clients still need a policy allowing them to enroll.
+	httpResp, _ = enroll("no-policy", "secret")
+	require.Equal(t, http.StatusForbidden, httpResp.StatusCode)
+
+	httpResp, body := enroll("device", "secret")
+	require.Equal(t, http.StatusOK, httpResp.StatusCode, "unexpected response: %s", body)
+	require.Equal(t, estCertsOnlyContentType, httpResp.Header.Get("Content-Type"))
+	require.Equal(t, "base64", httpResp.Header.Get("Content-Transfer-Encoding"))
+
+	der, err := base64.StdEncoding.DecodeString(string(body))
+	require.NoError(t, err)
+	certs := parseEstCertsOnly(t, der)
+	require.Len(t, certs, 1)
+	require.Equal(t, "device.example.com", certs[0].Subject.CommonName)
+
+	// Disabling EST removes the .well-known paths again.
+	_, err = client.Logical().Write("pki/config/est", map[string]interface{}{"enabled": false})
+	require.NoError(t, err)
+	httpResp, err = httpClient.Get(estURL + "cacerts")
+	require.NoError(t, err)
+	httpResp.Body.Close()
+	require.Equal(t, http.StatusNotFound, httpResp.StatusCode)
+}
@@ -9,6 +9,7 @@ import (
 	"encoding/pem"
 	"fmt"
 	"net/http"
+	"slices"
 	"strings"
 	"time"
 
@@ -209,6 +210,42 @@ and using '{{cluster_aia_path}}' requires /config/cluster's 'aia_path' member
 to be set on all PR secondary clusters.`,
 		Default: false,
 	}
+	fields["ct_logs"] = &framework.FieldSchema{
+		Type: framework.TypeCommaStringSlice,
+		Description: `Comma-separated list of base URLs of RFC 6962
+Certificate Transparency logs. When set, pre-certificates of leaf
+certificates issued by this issuer are submitted to these logs and the
+returned SCTs are embedded in the issued certificates.`,
+	}
+	fields["ct_log_public_keys"] = &framework.FieldSchema{
+		Type: framework.TypeCommaStringSlice,
+		Description: `Comma-separated list of PEM or base64 DER encoded
+public keys of the logs in ct_logs, in the same order. When set, the SCTs
+returned by the logs are verified before being embedded; SCTs of logs
+without a key are embedded unverified, with a warning.`,
+	}
+	fields["ct_required_scts"] = &framework.FieldSchema{
+		Type: framework.TypeInt,
+		Description: `Minimum number of SCTs which must be obtained from
+the logs in ct_logs for issuance to succeed. Defaults to 0, requiring an
+SCT from every log.`,
+		Default: 0,
+	}
+	fields["ct_log_timeout"] = &framework.FieldSchema{
+		Type: framework.TypeDurationSecond,
+		Description: `How long to wait on each log in ct_logs for an SCT
+before treating the log as failed. Defaults to 10 seconds.`,
+		Default: int(issuing.DefaultCTLogTimeout / time.Second), // TypeDurationSecond currently requires defaults to be int
+	}
+	fields["ct_failure_policy"] = &framework.FieldSchema{
+		Type: framework.TypeString,
+		Description: `What to do when fewer SCTs than required by
+ct_required_scts were obtained: "fail_closed" (the default) fails
+issuance, while "issue_without_scts" issues the certificate without
+embedded SCTs and returns a warning.`,
+		Default:       issuing.CTFailurePolicyFailClosed,
+		AllowedValues: []interface{}{issuing.CTFailurePolicyFailClosed, issuing.CTFailurePolicyIssueWithoutSCTs},
+	}
 
 	updateIssuerSchema := map[int][]framework.Response{
 		http.StatusOK: {{
@@ -292,6 +329,31 @@ to be set on all PR secondary clusters.`,
 					Description: `Whether or not templating is enabled for AIA fields`,
 					Required:    false,
 				},
+				"ct_logs": {
+					Type:        framework.TypeStringSlice,
+					Description: `Certificate Transparency logs`,
+					Required:    false,
+				},
+				"ct_log_public_keys": {
+					Type:        framework.TypeStringSlice,
+					Description: `Certificate Transparency log public keys`,
+					Required:    false,
+				},
+				"ct_required_scts": {
+					Type:        framework.TypeInt,
+					Description: `Certificate Transparency required SCTs`,
+					Required:    false,
+				},
+				"ct_log_timeout": {
+					Type:        framework.TypeDurationSecond,
+					Description: `Certificate Transparency per-log timeout`,
+					Required:    false,
+				},
+				"ct_failure_policy": {
+					Type:        framework.TypeString,
+					Description: `Certificate Transparency failure policy`,
+					Required:    false,
+				},
 			},
 		}},
 	}
@@ -453,6 +515,25 @@ func respondReadIssuer(issuer *issuing.IssuerEntry) (*logical.Response, error) {
 		"issuing_certificates":           []string{},
 		"crl_distribution_points":        []string{},
 		"ocsp_servers":                   []string{},
+		"ct_logs":                        []string{},
+		"ct_log_public_keys":             []string{},
+		"ct_required_scts":               issuer.CTRequiredSCTs,
+		"ct_log_timeout":                 int64(issuing.DefaultCTLogTimeout.Seconds()),
+		"ct_failure_policy":              issuing.CTFailurePolicyFailClosed,
+	}
+
+	if issuer.CTLogTimeout > 0 {
+		data["ct_log_timeout"] = int64(issuer.CTLogTimeout.Seconds())
+	}
+	if issuer.CTFailurePolicy != "" {
+		data["ct_failure_policy"] = issuer.CTFailurePolicy
+	}
+
+	for _, log := range issuer.CTLogs {
+		data["ct_logs"] = append(data["ct_logs"].([]string), log.URL)
+		if log.PublicKey != "" {
+			data["ct_log_public_keys"] = append(data["ct_log_public_keys"].([]string), log.PublicKey)
+		}
 	}
 
 	if issuer.Revoked {
@@ -575,6 +656,21 @@ func (b *backend) pathUpdateIssuer(ctx context.Context, req *logical.Request, da
 		return logical.ErrorResponse(fmt.Sprintf("invalid URL found in Authority Information Access (AIA) parameter ocsp_servers: %s", badURL)), nil
 	}
 
+	// Certificate Transparency changes
+	newCTLogs, err := buildCTLogs(data.Get("ct_logs").([]string), data.Get("ct_log_public_keys").([]string))
+	if err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+	newCTRequiredSCTs := data.Get("ct_required_scts").(int)
+	if err := validateCTRequiredSCTs(newCTLogs, newCTRequiredSCTs); err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+	newCTLogTimeout := time.Duration(data.Get("ct_log_timeout").(int)) * time.Second
+	newCTFailurePolicy := data.Get("ct_failure_policy").(string)
+	if err := validateCTSubmission(newCTLogTimeout, newCTFailurePolicy); err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+
 	modified := false
 
 	var oldName string
@@ -618,6 +714,18 @@ func (b *backend) pathUpdateIssuer(ctx context.Context, req *logical.Request, da
 		modified = true
 	}
 
+	if !slices.Equal(newCTLogs, issuer.CTLogs) || newCTRequiredSCTs != issuer.CTRequiredSCTs {
+		issuer.CTLogs = newCTLogs
+		issuer.CTRequiredSCTs = newCTRequiredSCTs
+		modified = true
+	}
+
+	if newCTLogTimeout != issuer.CTLogTimeout || newCTFailurePolicy != issuer.CTFailurePolicy {
+		issuer.CTLogTimeout = newCTLogTimeout
+		issuer.CTFailurePolicy = newCTFailurePolicy
+		modified = true
+	}
+
 	if issuer.AIAURIs == nil && (len(issuerCertificates) > 0 || len(crlDistributionPoints) > 0 || len(ocspServers) > 0) {
 		issuer.AIAURIs = &issuing.AiaConfigEntry{}
 	}
@@ -863,6 +971,67 @@ func (b *backend) pathPatchIssuer(ctx context.Context, req *logical.Request, dat
 		}
 	}
 
+	// Certificate Transparency changes
+	rawCTLogs, logsOk := data.GetOk("ct_logs")
+	rawCTLogKeys, keysOk := data.GetOk("ct_log_public_keys")
+	rawCTRequiredSCTs, requiredOk := data.GetOk("ct_required_scts")
+	if logsOk || keysOk || requiredOk {
+		var ctLogURLs, ctLogKeys []string
+		for _, log := range issuer.CTLogs {
+			ctLogURLs = append(ctLogURLs, log.URL)
+			if log.PublicKey != "" {
+				ctLogKeys = append(ctLogKeys, log.PublicKey)
+			}
+		}
+		if logsOk {
+			ctLogURLs = rawCTLogs.([]string)
+		}
+		if keysOk {
+			ctLogKeys = rawCTLogKeys.([]string)
+		}
+		newCTRequiredSCTs := issuer.CTRequiredSCTs
+		if requiredOk {
+			newCTRequiredSCTs = rawCTRequiredSCTs.(int)
+		}
+
+		newCTLogs, err := buildCTLogs(ctLogURLs, ctLogKeys)
+		if err != nil {
+			return logical.ErrorResponse(err.Error()), nil
+		}
+		if err := validateCTRequiredSCTs(newCTLogs, newCTRequiredSCTs); err != nil {
+			return logical.ErrorResponse(err.Error()), nil
+		}
+
+		if !slices.Equal(newCTLogs, issuer.CTLogs) || newCTRequiredSCTs != issuer.CTRequiredSCTs {
+			issuer.CTLogs = newCTLogs
+			issuer.CTRequiredSCTs = newCTRequiredSCTs
+			modified = true
+		}
+	}
+
+	rawCTLogTimeout, timeoutOk := data.GetOk("ct_log_timeout")
+	rawCTFailurePolicy, policyOk := data.GetOk("ct_failure_policy")
+	if timeoutOk || policyOk {
+		newCTLogTimeout := issuer.CTLogTimeout
+		if timeoutOk {
+			newCTLogTimeout = time.Duration(rawCTLogTimeout.(int)) * time.Second
+		}
+		newCTFailurePolicy := issuer.CTFailurePolicy
+		if policyOk {
+			newCTFailurePolicy = rawCTFailurePolicy.(string)
+		}
+
+		if err := validateCTSubmission(newCTLogTimeout, newCTFailurePolicy); err != nil {
+			return logical.ErrorResponse(err.Error()), nil
+		}
+
+		if newCTLogTimeout != issuer.CTLogTimeout || newCTFailurePolicy != issuer.CTFailurePolicy {
+			issuer.CTLogTimeout = newCTLogTimeout
+			issuer.CTFailurePolicy = newCTFailurePolicy
+			modified = true
+		}
+	}
+
 	// AIA access changes.
 	if issuer.AIAURIs == nil {
 		issuer.AIAURIs = &issuing.AiaConfigEntry{}
@@ -986,6 +1155,54 @@ func (b *backend) pathPatchIssuer(ctx context.Context, req *logical.Request, dat
 	return response, err
 }
 
+// buildCTLogs validates the Certificate Transparency logs of an issuer and
+// their optional public keys, which must be given for all logs or none.
+func buildCTLogs(urls []string, publicKeys []string) ([]certutil.CTLog, error) {
+	if badURL := issuing.ValidateURLs(urls); badURL != "" {
+		return nil, fmt.Errorf("invalid URL found in Certificate Transparency parameter ct_logs: %s", badURL)
+	}
+
+	if len(publicKeys) > 0 && len(publicKeys) != len(urls) {
+		return nil, fmt.Errorf("ct_log_public_keys must contain one public key per log in ct_logs: got %d keys for %d logs", len(publicKeys), len(urls))
+	}
+
+	var logs []certutil.CTLog
+	for index, url := range urls {
+		log := certutil.CTLog{URL: url}
+		if len(publicKeys) > 0 {
+			if _, err := issuing.ParseCTLogPublicKey(publicKeys[index]); err != nil {
+				return nil, fmt.Errorf("invalid public key for Certificate Transparency log %s: %w", url, err)
+			}
+			log.PublicKey = publicKeys[index]
+		}
+		logs = append(logs, log)
+	}
+
+	return logs, nil
+}
+
+func validateCTRequiredSCTs(logs []certutil.CTLog, required int) error {
+	if required < 0 {
+		return fmt.Errorf("ct_required_scts must not be negative")
+	}
+	if required > len(logs) {
+		return fmt.Errorf("ct_required_scts (%d) exceeds the number of logs in ct_logs (%d)", required, len(logs))
+	}
+	return nil
+}
+
+func validateCTSubmission(timeout time.Duration, failurePolicy string) error {
+	if timeout < 0 {
+		return fmt.Errorf("ct_log_timeout must not be negative")
+	}
+	switch failurePolicy {
+	case "", issuing.CTFailurePolicyFailClosed, issuing.CTFailurePolicyIssueWithoutSCTs:
+		return nil
+	default:
+		return fmt.Errorf("ct_failure_policy must be %q or %q, got %q", issuing.CTFailurePolicyFailClosed, issuing.CTFailurePolicyIssueWithoutSCTs, failurePolicy)
+	}
+}
+
 func (b *backend) pathGetRawIssuer(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
 	if b.UseLegacyBundleCaStorage() {
 		return logical.ErrorResponse("Can not get issuer until migration has completed"), nil
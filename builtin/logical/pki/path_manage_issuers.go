@@ -597,6 +597,31 @@ func pathRevokeIssuer(b *backend) *framework.Path {
 								Description: `Specifies the URL values for the OCSP Servers field`,
 								Required:    true,
 							},
+							"ct_logs": {
+								Type:        framework.TypeStringSlice,
+								Description: `Certificate Transparency logs leaf certificates are submitted to`,
+								Required:    false,
+							},
+							"ct_log_public_keys": {
+								Type:        framework.TypeStringSlice,
+								Description: `Public keys of the Certificate Transparency logs`,
+								Required:    false,
+							},
+							"ct_required_scts": {
+								Type:        framework.TypeInt,
+								Description: `Minimum number of SCTs required for issuance`,
+								Required:    false,
+							},
+							"ct_log_timeout": {
+								Type:        framework.TypeDurationSecond,
+								Description: `How long to wait on each Certificate Transparency log`,
+								Required:    false,
+							},
+							"ct_failure_policy": {
+								Type:        framework.TypeString,
+								Description: `What to do when not enough SCTs were obtained`,
+								Required:    false,
+							},
 							"revocation_time": {
 								Type:        framework.TypeInt64,
 								Description: `Time of revocation`,
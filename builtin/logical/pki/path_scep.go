@@ -0,0 +1,791 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package pki
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/vault/builtin/logical/pki/issuing"
+	"github.com/hashicorp/vault/helper/pkcs7"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/helper/certutil"
+	"github.com/hashicorp/vault/sdk/helper/consts"
+	"github.com/hashicorp/vault/sdk/helper/errutil"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+const (
+	scepOperationGetCACaps    = "GetCACaps"
+	scepOperationGetCACert    = "GetCACert"
+	scepOperationPKIOperation = "PKIOperation"
+
+	scepCACertContentType   = "application/x-x509-ca-cert"
+	scepCARACertContentType = "application/x-x509-ca-ra-cert"
+	scepPKIMessageType      = "application/x-pki-message"
+
+	// scepMaximumRequestSize bounds the size of the PKI messages read from
+	// PKIOperation requests.
+	scepMaximumRequestSize = 256 * 1024
+
+	scepChallengePrefix = "scep/challenges/"
+)
+
+// The capabilities returned by GetCACaps (RFC 8894 Section 3.5.2).
+var scepCACaps = []string{
+	"POSTPKIOperation",
+	"SHA-1",
+	"SHA-256",
+	"SHA-512",
+	"AES",
+	"DES3",
+	"SCEPStandard",
+}
+
+// The SCEP message types (RFC 8894 Section 3.2.1.2), pkiStatus values
+// (Section 3.2.1.3) and failInfo values (Section 3.2.1.4).
+const (
+	scepMessageTypeCertRep = "3"
+	scepMessageTypePKCSReq = "19"
+
+	scepStatusSuccess = "0"
+	scepStatusFailure = "2"
+
+	scepFailBadAlg          = "0"
+	scepFailBadMessageCheck = "1"
+	scepFailBadRequest      = "2"
+)
+
+var (
+	oidScepMessageType    = asn1.ObjectIdentifier{2, 16, 840, 1, 113733, 1, 9, 2}
+	oidScepPKIStatus      = asn1.ObjectIdentifier{2, 16, 840, 1, 113733, 1, 9, 3}
+	oidScepFailInfo       = asn1.ObjectIdentifier{2, 16, 840, 1, 113733, 1, 9, 4}
+	oidScepSenderNonce    = asn1.ObjectIdentifier{2, 16, 840, 1, 113733, 1, 9, 5}
+	oidScepRecipientNonce = asn1.ObjectIdentifier{2, 16, 840, 1, 113733, 1, 9, 6}
+	oidScepTransactionID  = asn1.ObjectIdentifier{2, 16, 840, 1, 113733, 1, 9, 7}
+
+	oidChallengePassword = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 7}
+)
+
+// scepChallenge is a challenge password minted for a SCEP client, stored
+// under the hash of the password.
+type scepChallenge struct {
+	Role       string    `json:"role"`
+	Expiration time.Time `json:"expiration"`
+}
+
+// scepRequest is a PKIOperation request whose signature has been verified.
+type scepRequest struct {
+	messageType   string
+	transactionID string
+	senderNonce   []byte
+	signer        *x509.Certificate
+	digestOid     asn1.ObjectIdentifier
+	envelope      []byte
+}
+
+// scepFailure is a PKIOperation request failure reported to the client in a
+// CertRep message, rather than as an HTTP error.
+type scepFailure struct {
+	failInfo string
+	err      error
+}
+
+func (e *scepFailure) Error() string {
+	return e.err.Error()
+}
+
+func newScepFailure(failInfo string, format string, args ...interface{}) *scepFailure {
+	return &scepFailure{failInfo: failInfo, err: fmt.Errorf(format, args...)}
+}
+
+func buildScepPath(b *backend, pattern string, fields map[string]*framework.FieldSchema) *framework.Path {
+	fields["operation"] = &framework.FieldSchema{
+		Type:        framework.TypeString,
+		Description: `The SCEP operation: GetCACaps, GetCACert or PKIOperation.`,
+		Query:       true,
+	}
+	fields["message"] = &framework.FieldSchema{
+		Type:        framework.TypeString,
+		Description: `The base64 encoded PKI message of PKIOperation requests sent with GET.`,
+		Query:       true,
+	}
+
+	return &framework.Path{
+		Pattern: pattern,
+		DisplayAttrs: &framework.DisplayAttributes{
+			OperationPrefix: operationPrefixPKI,
+		},
+		Fields: fields,
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.ReadOperation: &framework.PathOperation{
+				Callback: b.pathScepRead,
+				DisplayAttrs: &framework.DisplayAttributes{
+					OperationVerb:   "read",
+					OperationSuffix: "scep",
+				},
+			},
+			logical.UpdateOperation: &framework.PathOperation{
+				Callback: b.pathScepWrite,
+				DisplayAttrs: &framework.DisplayAttributes{
+					OperationVerb: "scep-pki-operation",
+				},
+			},
+		},
+		HelpSynopsis:    pathScepHelpSyn,
+		HelpDescription: pathScepHelpDesc,
+	}
+}
+
+func pathScepChallenge(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "roles/" + framework.GenericNameRegex("role") + "/scep/challenge",
+		DisplayAttrs: &framework.DisplayAttributes{
+			OperationPrefix: operationPrefixPKI,
+			OperationVerb:   "generate",
+			OperationSuffix: "scep-challenge",
+		},
+		Fields: map[string]*framework.FieldSchema{
+			"role": {
+				Type:        framework.TypeString,
+				Description: `The role the SCEP client will be issued a certificate with.`,
+				Required:    true,
+			},
+			"ttl": {
+				Type:        framework.TypeDurationSecond,
+				Description: `The lifetime of the challenge password; defaults to the challenge_ttl of the SCEP configuration.`,
+			},
+		},
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.UpdateOperation: &framework.PathOperation{
+				Callback: b.pathScepChallengeWrite,
+				Responses: map[int][]framework.Response{
+					http.StatusOK: {{
+						Description: "OK",
+						Fields: map[string]*framework.FieldSchema{
+							"challenge": {
+								Type:        framework.TypeString,
+								Description: `The challenge password to give to the SCEP client`,
+								Required:    true,
+							},
+							"role": {
+								Type:        framework.TypeString,
+								Description: `The role the challenge password is tied to`,
+								Required:    true,
+							},
+							"expiration": {
+								Type:        framework.TypeString,
+								Description: `The time the challenge password expires at`,
+								Required:    true,
+							},
+						},
+					}},
+				},
+				// Challenges are written to storage.
+				ForwardPerformanceStandby:   true,
+				ForwardPerformanceSecondary: true,
+			},
+		},
+		HelpSynopsis:    pathScepChallengeHelpSyn,
+		HelpDescription: pathScepChallengeHelpDesc,
+	}
+}
+
+// setupScepPaths adds the SCEP protocol paths of the mount and of the roles
+// to the backend.
+func setupScepPaths(b *backend) {
+	b.Backend.Paths = append(b.Backend.Paths,
+		buildScepPath(b, "scep", map[string]*framework.FieldSchema{}),
+		buildScepPath(b, "roles/"+framework.GenericNameRegex("role")+"/scep", map[string]*framework.FieldSchema{
+			"role": {
+				Type:        framework.TypeString,
+				Description: `The role to issue certificates with.`,
+				Required:    true,
+			},
+		}),
+		pathScepChallenge(b),
+	)
+
+	// SCEP clients authenticate with challenge passwords, and POST their
+	// PKI messages as DER rather than JSON.
+	b.PathsSpecial.Unauthenticated = append(b.PathsSpecial.Unauthenticated, "scep", "roles/+/scep")
+	b.PathsSpecial.Binary = append(b.PathsSpecial.Binary, "scep", "roles/+/scep")
+}
+
+func (b *backend) pathScepChallengeWrite(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	sc := b.makeStorageContext(ctx, req.Storage)
+	config, err := sc.getScepConfig()
+	if err != nil {
+		return nil, err
+	}
+	if !config.Enabled {
+		return logical.ErrorResponse("SCEP is not enabled on this mount"), nil
+	}
+
+	roleName := data.Get("role").(string)
+	role, err := b.GetRole(ctx, req.Storage, roleName)
+	if err != nil {
+		return nil, err
+	}
+	if role == nil {
+		return logical.ErrorResponse(fmt.Sprintf("role %q does not exist", roleName)), nil
+	}
+
+	ttl := config.ChallengeTTL
+	if ttlRaw, ok := data.GetOk("ttl"); ok {
+		ttl = time.Duration(ttlRaw.(int)) * time.Second
+	}
+	if ttl <= 0 {
+		return logical.ErrorResponse("ttl must be greater than zero"), nil
+	}
+
+	// Challenge passwords are carried as a PrintableString in CSRs, so stick
+	// to hex characters.
+	raw := make([]byte, 16)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, fmt.Errorf("failed to generate challenge password: %w", err)
+	}
+	password := hex.EncodeToString(raw)
+
+	challenge := &scepChallenge{
+		Role:       roleName,
+		Expiration: time.Now().Add(ttl),
+	}
+	entry, err := logical.StorageEntryJSON(scepChallengePrefix+hashScepChallenge(password), challenge)
+	if err != nil {
+		return nil, err
+	}
+	if err := req.Storage.Put(ctx, entry); err != nil {
+		return nil, err
+	}
+
+	// Unused challenges are removed once expired here, rather than requiring
+	// a tidy operation.
+	if err := b.removeExpiredScepChallenges(sc); err != nil {
+		b.Logger().Warn("failed to remove expired SCEP challenges", "error", err)
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"challenge":  password,
+			"role":       roleName,
+			"expiration": challenge.Expiration.Format(time.RFC3339),
+		},
+	}, nil
+}
+
+func hashScepChallenge(password string) string {
+	sum := sha256.Sum256([]byte(password))
+	return hex.EncodeToString(sum[:])
+}
+
+func (b *backend) removeExpiredScepChallenges(sc *storageContext) error {
+	keys, err := sc.Storage.List(sc.Context, scepChallengePrefix)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, key := range keys {
+		challenge, err := getScepChallenge(sc, key)
+		if err != nil {
+			return err
+		}
+		if challenge != nil && now.After(challenge.Expiration) {
+			if err := sc.Storage.Delete(sc.Context, scepChallengePrefix+key); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func getScepChallenge(sc *storageContext, key string) (*scepChallenge, error) {
+	entry, err := sc.Storage.Get(sc.Context, scepChallengePrefix+key)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var challenge scepChallenge
+	if err := entry.DecodeJSON(&challenge); err != nil {
+		return nil, fmt.Errorf("failed to decode SCEP challenge: %w", err)
+	}
+	return &challenge, nil
+}
+
+// redeemScepChallenge checks that the password is a valid challenge for the
+// role and runs issue, removing the challenge so that it can't be used again
+// only once issue succeeds: a CSR rejected by the role leaves the client free
+// to retry with the same challenge. The lock is held throughout, so that a
+// challenge is never redeemed twice.
+func (b *backend) redeemScepChallenge(sc *storageContext, password string, roleName string, issue func() error) error {
+	b.scepLock.Lock()
+	defer b.scepLock.Unlock()
+
+	key := hashScepChallenge(password)
+	challenge, err := getScepChallenge(sc, key)
+	if err != nil {
+		return err
+	}
+	if challenge == nil || time.Now().After(challenge.Expiration) || challenge.Role != roleName {
+		return newScepFailure(scepFailBadRequest, "invalid challenge password")
+	}
+
+	if err := issue(); err != nil {
+		return err
+	}
+
+	return sc.Storage.Delete(sc.Context, scepChallengePrefix+key)
+}
+
+func (b *backend) pathScepRead(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	operation := data.Get("operation").(string)
+	if operation != scepOperationPKIOperation {
+		return b.scepGetOperation(ctx, req, data, operation)
+	}
+
+	// The message is base64 encoded in the query string; clients that don't
+	// URL encode it leave plus signs that decode to spaces.
+	message := strings.ReplaceAll(data.Get("message").(string), " ", "+")
+	raw, err := base64.StdEncoding.DecodeString(message)
+	if err != nil {
+		return logical.ErrorResponse(fmt.Sprintf("failed to decode base64 message: %v", err)), nil
+	}
+
+	return b.scepPKIOperation(ctx, req, data, raw)
+}
+
+func (b *backend) pathScepWrite(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	// The body of binary paths isn't parsed, nor is the query string of
+	// POST requests, so the operation is read from the HTTP request.
+	operation := data.Get("operation").(string)
+	if operation == "" && req.HTTPRequest != nil && req.HTTPRequest.URL != nil {
+		operation = req.HTTPRequest.URL.Query().Get("operation")
+	}
+	if operation != scepOperationPKIOperation {
+		return logical.ErrorResponse(fmt.Sprintf("unsupported SCEP operation %q for POST requests", operation)), nil
+	}
+
+	if req.HTTPRequest == nil || req.HTTPRequest.Body == nil {
+		return logical.ErrorResponse("no PKI message in request body"), nil
+	}
+	defer req.HTTPRequest.Body.Close()
+
+	raw, err := io.ReadAll(io.LimitReader(req.HTTPRequest.Body, scepMaximumRequestSize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read request body: %w", err)
+	}
+	if len(raw) >= scepMaximumRequestSize {
+		return logical.ErrorResponse("request is too large"), nil
+	}
+
+	return b.scepPKIOperation(ctx, req, data, raw)
+}
+
+func (b *backend) scepGetOperation(ctx context.Context, req *logical.Request, data *framework.FieldData, operation string) (*logical.Response, error) {
+	sc := b.makeStorageContext(ctx, req.Storage)
+	_, _, resp, err := b.getScepConfigAndRole(sc, data)
+	if resp != nil || err != nil {
+		return resp, err
+	}
+
+	switch operation {
+	case scepOperationGetCACaps:
+		return scepRawResponse("text/plain", []byte(strings.Join(scepCACaps, "\n"))), nil
+	case scepOperationGetCACert:
+		return b.scepGetCACert(sc, data)
+	default:
+		return logical.ErrorResponse(fmt.Sprintf("unsupported SCEP operation %q", operation)), nil
+	}
+}
+
+// getScepConfigAndRole loads the SCEP configuration, and the role of the
+// request from its path or the default_role.
+func (b *backend) getScepConfigAndRole(sc *storageContext, data *framework.FieldData) (*scepConfigEntry, *issuing.RoleEntry, *logical.Response, error) {
+	config, err := sc.getScepConfig()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if !config.Enabled {
+		return nil, nil, logical.ErrorResponse("SCEP is not enabled on this mount"), nil
+	}
+
+	roleName := config.DefaultRole
+	if roleRaw, ok := data.GetOk("role"); ok {
+		roleName = roleRaw.(string)
+	}
+	if roleName == "" {
+		return nil, nil, logical.ErrorResponse("no default_role is configured for SCEP requests without a role"), nil
+	}
+
+	role, err := b.GetRole(sc.Context, sc.Storage, roleName)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if role == nil {
+		return nil, nil, logical.ErrorResponse(fmt.Sprintf("role %q does not exist", roleName)), nil
+	}
+	if role.Issuer == "" {
+		role.Issuer = defaultRef
+	}
+
+	return config, role, nil, nil
+}
+
+// fetchScepCA loads the issuer of the role, which SCEP clients encrypt their
+// requests to, and which signs the responses.
+func (b *backend) fetchScepCA(sc *storageContext, role *issuing.RoleEntry) (*certutil.CAInfoBundle, *rsa.PrivateKey, error) {
+	signingBundle, _, err := sc.fetchCAInfoWithIssuer(role.Issuer, issuing.IssuanceUsage)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed loading CA %s: %w", role.Issuer, err)
+	}
+
+	// Clients encrypt their requests with RSA key transport.
+	key, ok := signingBundle.PrivateKey.(*rsa.PrivateKey)
+	if !ok {
+		return nil, nil, errutil.UserError{Err: fmt.Sprintf("SCEP requires issuer %s to have an RSA key held by Vault", role.Issuer)}
+	}
+
+	return signingBundle, key, nil
+}
+
+// scepGetCACert returns the issuer of the role, along with the rest of its
+// chain when it isn't a root (RFC 8894 Section 4.2.1).
+func (b *backend) scepGetCACert(sc *storageContext, data *framework.FieldData) (*logical.Response, error) {
+	_, role, resp, err := b.getScepConfigAndRole(sc, data)
+	if resp != nil || err != nil {
+		return resp, err
+	}
+
+	signingBundle, _, err := b.fetchScepCA(sc, role)
+	if err != nil {
+		var userErr errutil.UserError
+		if errors.As(err, &userErr) {
+			return logical.ErrorResponse(err.Error()), nil
+		}
+		return nil, err
+	}
+
+	chain := signingBundle.GetFullChain()
+	if len(chain) <= 1 {
+		return scepRawResponse(scepCACertContentType, signingBundle.Certificate.Raw), nil
+	}
+
+	var certs []byte
+	for _, cert := range chain {
+		certs = append(certs, cert.Bytes...)
+	}
+	p7, err := pkcs7.DegenerateCertificate(certs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode certificates: %w", err)
+	}
+	return scepRawResponse(scepCARACertContentType, p7), nil
+}
+
+func (b *backend) scepPKIOperation(ctx context.Context, req *logical.Request, data *framework.FieldData, raw []byte) (*logical.Response, error) {
+	sc := b.makeStorageContext(ctx, req.Storage)
+	_, role, resp, err := b.getScepConfigAndRole(sc, data)
+	if resp != nil || err != nil {
+		return resp, err
+	}
+
+	// Challenge passwords are removed and certificates stored, so forward
+	// the request on to the primary.
+	if b.System().ReplicationState().HasState(consts.ReplicationPerformanceStandby) {
+		return nil, logical.ErrReadOnly
+	}
+
+	signingBundle, caKey, err := b.fetchScepCA(sc, role)
+	if err != nil {
+		var userErr errutil.UserError
+		if errors.As(err, &userErr) {
+			return logical.ErrorResponse(err.Error()), nil
+		}
+		return nil, err
+	}
+
+	scepReq, err := parseScepRequest(raw)
+	if err != nil {
+		// Without a verified request, there is no one to send a CertRep to.
+		return logical.ErrorResponse(err.Error()), nil
+	}
+
+	cert, encryptionAlgorithm, err := b.scepEnroll(sc, req, role, signingBundle, caKey, scepReq)
+	if err != nil {
+		var failure *scepFailure
+		if !errors.As(err, &failure) {
+			return nil, err
+		}
+		b.Logger().Debug("rejected SCEP request", "transaction_id", scepReq.transactionID, "error", failure.err)
+		return b.scepCertRep(signingBundle.Certificate, caKey, scepReq, nil, 0, failure.failInfo)
+	}
+
+	return b.scepCertRep(signingBundle.Certificate, caKey, scepReq, cert, encryptionAlgorithm, "")
+}
+
+// parseScepRequest parses and verifies the signature of a pkiMessage (RFC
+// 8894 Section 3.2).
+func parseScepRequest(raw []byte) (*scepRequest, error) {
+	p7, err := pkcs7.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse PKI message: %w", err)
+	}
+
+	signer := p7.GetOnlySigner()
+	if signer == nil {
+		return nil, errors.New("PKI message must have a single signer, whose certificate is included")
+	}
+	if err := p7.Verify(); err != nil {
+		return nil, fmt.Errorf("failed to verify PKI message signature: %w", err)
+	}
+
+	scepReq := &scepRequest{
+		signer:    signer,
+		digestOid: p7.Signers[0].DigestAlgorithm.Algorithm,
+		envelope:  p7.Content,
+	}
+	if err := p7.UnmarshalSignedAttribute(oidScepMessageType, &scepReq.messageType); err != nil {
+		return nil, fmt.Errorf("failed to read messageType attribute: %w", err)
+	}
+	if err := p7.UnmarshalSignedAttribute(oidScepTransactionID, &scepReq.transactionID); err != nil {
+		return nil, fmt.Errorf("failed to read transactionID attribute: %w", err)
+	}
+	if err := p7.UnmarshalSignedAttribute(oidScepSenderNonce, &scepReq.senderNonce); err != nil {
+		return nil, fmt.Errorf("failed to read senderNonce attribute: %w", err)
+	}
+
+	return scepReq, nil
+}
+
+// scepEnroll decrypts the CSR of a PKCSReq message, and issues it after
+// checking its challenge password, returning the algorithm the response is to
+// be encrypted with.
+func (b *backend) scepEnroll(sc *storageContext, req *logical.Request, role *issuing.RoleEntry, signingBundle *certutil.CAInfoBundle, caKey *rsa.PrivateKey, scepReq *scepRequest) (*x509.Certificate, int, error) {
+	if scepReq.messageType != scepMessageTypePKCSReq {
+		return nil, 0, newScepFailure(scepFailBadRequest, "unsupported message type %q", scepReq.messageType)
+	}
+	if _, ok := scepReq.signer.PublicKey.(*rsa.PublicKey); !ok {
+		return nil, 0, newScepFailure(scepFailBadAlg, "the response can only be encrypted to a signer with an RSA key")
+	}
+
+	envelope, err := pkcs7.Parse(scepReq.envelope)
+	if err != nil {
+		return nil, 0, newScepFailure(scepFailBadMessageCheck, "failed to parse enveloped data: %v", err)
+	}
+	encryptionAlgorithm, err := envelope.EncryptionAlgorithm()
+	if err != nil {
+		return nil, 0, newScepFailure(scepFailBadAlg, "unsupported content encryption: %v", err)
+	}
+	der, err := envelope.Decrypt(signingBundle.Certificate, caKey)
+	if err != nil {
+		return nil, 0, newScepFailure(scepFailBadMessageCheck, "failed to decrypt enveloped data: %v", err)
+	}
+
+	csr, err := x509.ParseCertificateRequest(der)
+	if err != nil {
+		return nil, 0, newScepFailure(scepFailBadRequest, "failed to parse certificate signing request: %v", err)
+	}
+	if err := csr.CheckSignature(); err != nil {
+		return nil, 0, newScepFailure(scepFailBadMessageCheck, "invalid certificate signing request signature: %v", err)
+	}
+
+	password, err := scepChallengePassword(csr)
+	if err != nil {
+		return nil, 0, newScepFailure(scepFailBadRequest, "%v", err)
+	}
+
+	var parsedBundle *certutil.ParsedCertBundle
+	err = b.redeemScepChallenge(sc, password, role.Name, func() error {
+		var err error
+		parsedBundle, err = b.issueScepCertificate(sc, req, role, signingBundle, csr)
+		return err
+	})
+	if err != nil {
+		var userErr errutil.UserError
+		if errors.As(err, &userErr) {
+			return nil, 0, newScepFailure(scepFailBadRequest, "%v", err)
+		}
+		return nil, 0, err
+	}
+
+	return parsedBundle.Certificate, encryptionAlgorithm, nil
+}
+
+// scepChallengePassword returns the challengePassword attribute of the CSR
+// (RFC 2985 Section 5.4.1), which crypto/x509 doesn't parse.
+func scepChallengePassword(csr *x509.CertificateRequest) (string, error) {
+	var tbs struct {
+		Version       int
+		Subject       asn1.RawValue
+		PublicKey     asn1.RawValue
+		RawAttributes []asn1.RawValue `asn1:"tag:0"`
+	}
+	if _, err := asn1.Unmarshal(csr.RawTBSCertificateRequest, &tbs); err != nil {
+		return "", fmt.Errorf("failed to parse certificate signing request attributes: %w", err)
+	}
+
+	for _, rawAttr := range tbs.RawAttributes {
+		var attr struct {
+			Type   asn1.ObjectIdentifier
+			Values []asn1.RawValue `asn1:"set"`
+		}
+		if _, err := asn1.Unmarshal(rawAttr.FullBytes, &attr); err != nil {
+			return "", fmt.Errorf("failed to parse certificate signing request attribute: %w", err)
+		}
+		if !attr.Type.Equal(oidChallengePassword) || len(attr.Values) != 1 {
+			continue
+		}
+
+		var password string
+		if _, err := asn1.Unmarshal(attr.Values[0].FullBytes, &password); err != nil {
+			return "", fmt.Errorf("failed to parse challenge password: %w", err)
+		}
+		return password, nil
+	}
+
+	return "", errors.New("certificate signing request has no challenge password")
+}
+
+// issueScepCertificate issues the CSR with the same validation as the
+// sign/:role endpoint.
+func (b *backend) issueScepCertificate(sc *storageContext, req *logical.Request, role *issuing.RoleEntry, signingBundle *certutil.CAInfoBundle, csr *x509.CertificateRequest) (*certutil.ParsedCertBundle, error) {
+	pemCsr := string(pem.EncodeToMemory(&pem.Block{
+		Type:  "CERTIFICATE REQUEST",
+		Bytes: csr.Raw,
+	}))
+
+	data := &framework.FieldData{
+		Raw: map[string]interface{}{
+			"csr": pemCsr,
+		},
+		Schema: getCsrSignVerbatimSchemaFields(),
+	}
+
+	input := &inputBundle{
+		req:     req,
+		apiData: data,
+		role:    role,
+	}
+	parsedBundle, _, err := signCert(sc.Context, b, input, signingBundle, false /* is_ca=false */, false /* use_csr_values */)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := parsedBundle.Verify(); err != nil {
+		return nil, fmt.Errorf("verification of parsed bundle failed: %w", err)
+	}
+
+	if !role.NoStore {
+		if err := issuing.StoreCertificate(sc.Context, sc.Storage, b.GetCertificateCounter(), parsedBundle); err != nil {
+			return nil, err
+		}
+	}
+
+	return parsedBundle, nil
+}
+
+// scepCertRep builds the CertRep message (RFC 8894 Section 3.3.2) answering
+// the request; the issued certificate is encrypted to the signer of the
+// request, while failures carry no content.
+func (b *backend) scepCertRep(caCert *x509.Certificate, caKey *rsa.PrivateKey, scepReq *scepRequest, cert *x509.Certificate, encryptionAlgorithm int, failInfo string) (*logical.Response, error) {
+	var content []byte
+	status := scepStatusFailure
+	if cert != nil {
+		status = scepStatusSuccess
+
+		degenerate, err := pkcs7.DegenerateCertificate(cert.Raw)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode certificate: %w", err)
+		}
+		content, err = pkcs7.EncryptWithAlgorithm(degenerate, []*x509.Certificate{scepReq.signer}, encryptionAlgorithm)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encrypt certificate: %w", err)
+		}
+	}
+
+	senderNonce := make([]byte, 16)
+	if _, err := rand.Read(senderNonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	attributes := []pkcs7.Attribute{
+		{Type: oidScepMessageType, Value: scepMessageTypeCertRep},
+		{Type: oidScepPKIStatus, Value: status},
+		{Type: oidScepTransactionID, Value: scepReq.transactionID},
+		{Type: oidScepSenderNonce, Value: senderNonce},
+		{Type: oidScepRecipientNonce, Value: scepReq.senderNonce},
+	}
+	if failInfo != "" {
+		attributes = append(attributes, pkcs7.Attribute{Type: oidScepFailInfo, Value: failInfo})
+	}
+
+	sd, err := pkcs7.NewSignedData(content)
+	if err != nil {
+		return nil, err
+	}
+	// Reply with the digest algorithm of the request, so that clients
+	// limited to SHA-1 can verify the response.
+	for _, digestOid := range []asn1.ObjectIdentifier{pkcs7.OIDDigestAlgorithmSHA1, pkcs7.OIDDigestAlgorithmSHA256, pkcs7.OIDDigestAlgorithmSHA512} {
+		if scepReq.digestOid.Equal(digestOid) {
+			sd.SetDigestAlgorithm(digestOid)
+		}
+	}
+	if err := sd.AddSigner(caCert, caKey, pkcs7.SignerInfoConfig{ExtraSignedAttributes: attributes}); err != nil {
+		return nil, fmt.Errorf("failed to sign response: %w", err)
+	}
+	certRep, err := sd.Finish()
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode response: %w", err)
+	}
+
+	return scepRawResponse(scepPKIMessageType, certRep), nil
+}
+
+func scepRawResponse(contentType string, body []byte) *logical.Response {
+	return &logical.Response{
+		Data: map[string]interface{}{
+			logical.HTTPContentType: contentType,
+			logical.HTTPStatusCode:  http.StatusOK,
+			logical.HTTPRawBody:     body,
+		},
+	}
+}
+
+const (
+	pathScepHelpSyn  = `Serve SCEP clients enrolling for certificates.`
+	pathScepHelpDesc = `
+This implements the Simple Certificate Enrollment Protocol (RFC 8894),
+answering the GetCACaps, GetCACert and PKIOperation operations given by
+the "operation" query parameter.
+
+PKIOperation requests must carry a PKCSReq message whose CSR holds a
+challenge password minted through the roles/:role/scep/challenge endpoint
+for the role of the request; the CSR is then issued with the same
+validation as the sign/:role endpoint.
+`
+
+	pathScepChallengeHelpSyn  = `Generate a challenge password for a SCEP client.`
+	pathScepChallengeHelpDesc = `
+This endpoint generates a single use challenge password, which allows a
+SCEP client to be issued a single certificate by this role until it
+expires.
+`
+)
@@ -0,0 +1,465 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package pki
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"io"
+	"math/big"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/api"
+	"github.com/hashicorp/vault/helper/pkcs7"
+	vaulthttp "github.com/hashicorp/vault/http"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/hashicorp/vault/vault"
+	"github.com/stretchr/testify/require"
+)
+
+// scepTestClient holds the key and self-signed certificate a SCEP client
+// signs its requests with.
+type scepTestClient struct {
+	key  *rsa.PrivateKey
+	cert *x509.Certificate
+}
+
+func newScepTestClient(t *testing.T, commonName string) *scepTestClient {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Minute),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	return &scepTestClient{key: key, cert: cert}
+}
+
+// csr builds a CSR holding the challenge password, which crypto/x509 can't
+// encode.
+func (c *scepTestClient) csr(t *testing.T, commonName string, challenge string) []byte {
+	t.Helper()
+
+	template, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject: pkix.Name{CommonName: commonName},
+	}, c.key)
+	require.NoError(t, err)
+	parsed, err := x509.ParseCertificateRequest(template)
+	require.NoError(t, err)
+
+	password, err := asn1.MarshalWithParams(challenge, "printable")
+	require.NoError(t, err)
+	type attribute struct {
+		Type   asn1.ObjectIdentifier
+		Values []asn1.RawValue `asn1:"set"`
+	}
+	tbs, err := asn1.Marshal(struct {
+		Version    int
+		Subject    asn1.RawValue
+		PublicKey  asn1.RawValue
+		Attributes []attribute `asn1:"tag:0"`
+	}{
+		Subject:    asn1.RawValue{FullBytes: parsed.RawSubject},
+		PublicKey:  asn1.RawValue{FullBytes: parsed.RawSubjectPublicKeyInfo},
+		Attributes: []attribute{{Type: oidChallengePassword, Values: []asn1.RawValue{{FullBytes: password}}}},
+	})
+	require.NoError(t, err)
+
+	digest := sha256.Sum256(tbs)
+	signature, err := rsa.SignPKCS1v15(rand.Reader, c.key, crypto.SHA256, digest[:])
+	require.NoError(t, err)
+
+	csr, err := asn1.Marshal(struct {
+		TBS                asn1.RawValue
+		SignatureAlgorithm pkix.AlgorithmIdentifier
+		Signature          asn1.BitString
+	}{
+		TBS:                asn1.RawValue{FullBytes: tbs},
+		SignatureAlgorithm: pkix.AlgorithmIdentifier{Algorithm: pkcs7.OIDEncryptionAlgorithmRSASHA256},
+		Signature:          asn1.BitString{Bytes: signature, BitLength: len(signature) * 8},
+	})
+	require.NoError(t, err)
+	return csr
+}
+
+// pkcsReq builds a PKCSReq pkiMessage for the CSR, encrypted to the CA.
+func (c *scepTestClient) pkcsReq(t *testing.T, ca *x509.Certificate, csr []byte, algorithm int) ([]byte, []byte) {
+	t.Helper()
+
+	envelope, err := pkcs7.EncryptWithAlgorithm(csr, []*x509.Certificate{ca}, algorithm)
+	require.NoError(t, err)
+
+	nonce := make([]byte, 16)
+	_, err = rand.Read(nonce)
+	require.NoError(t, err)
+
+	sd, err := pkcs7.NewSignedData(envelope)
+	require.NoError(t, err)
+	err = sd.AddSigner(c.cert, c.key, pkcs7.SignerInfoConfig{
+		ExtraSignedAttributes: []pkcs7.Attribute{
+			{Type: oidScepMessageType, Value: scepMessageTypePKCSReq},
+			{Type: oidScepTransactionID, Value: "test-transaction"},
+			{Type: oidScepSenderNonce, Value: nonce},
+		},
+	})
+	require.NoError(t, err)
+	msg, err := sd.Finish()
+	require.NoError(t, err)
+	return msg, nonce
+}
+
+// certRep verifies the CertRep message answering a request, returning its
+// status and the issued certificate, if any.
+func (c *scepTestClient) certRep(t *testing.T, ca *x509.Certificate, raw []byte, nonce []byte) (string, string, *x509.Certificate) {
+	t.Helper()
+
+	p7, err := pkcs7.Parse(raw)
+	require.NoError(t, err)
+	require.NoError(t, p7.Verify())
+	require.Equal(t, ca.Raw, p7.GetOnlySigner().Raw)
+
+	var messageType, status, failInfo, transactionID string
+	var recipientNonce []byte
+	require.NoError(t, p7.UnmarshalSignedAttribute(oidScepMessageType, &messageType))
+	require.NoError(t, p7.UnmarshalSignedAttribute(oidScepPKIStatus, &status))
+	require.NoError(t, p7.UnmarshalSignedAttribute(oidScepTransactionID, &transactionID))
+	require.NoError(t, p7.UnmarshalSignedAttribute(oidScepRecipientNonce, &recipientNonce))
+	require.Equal(t, scepMessageTypeCertRep, messageType)
+	require.Equal(t, "test-transaction", transactionID)
+	require.Equal(t, nonce, recipientNonce)
+
+	if status != scepStatusSuccess {
+		require.NoError(t, p7.UnmarshalSignedAttribute(oidScepFailInfo, &failInfo))
+		return status, failInfo, nil
+	}
+
+	envelope, err := pkcs7.Parse(p7.Content)
+	require.NoError(t, err)
+	degenerate, err := envelope.Decrypt(c.cert, c.key)
+	require.NoError(t, err)
+	certs := parseEstCertsOnly(t, degenerate)
+	require.Len(t, certs, 1)
+	return status, "", certs[0]
+}
+
+func setupScepBackend(t *testing.T) (*backend, logical.Storage, *x509.Certificate) {
+	t.Helper()
+
+	b, s := CreateBackendWithStorage(t)
+
+	resp, err := CBWrite(b, s, "root/generate/internal", map[string]interface{}{
+		"common_name": "root-ca.com",
+		"key_type":    "rsa",
+	})
+	requireSuccessNonNilResponse(t, resp, err, "failed generating root issuer")
+	ca := parseCert(t, resp.Data["certificate"].(string))
+
+	resp, err = CBWrite(b, s, "roles/scep", map[string]interface{}{
+		"allowed_domains":  "example.com",
+		"allow_subdomains": true,
+		"ttl":              "1h",
+	})
+	requireSuccessNonNilResponse(t, resp, err, "failed creating role")
+
+	resp, err = CBWrite(b, s, "config/scep", map[string]interface{}{
+		"enabled":      true,
+		"default_role": "scep",
+	})
+	requireSuccessNonNilResponse(t, resp, err, "failed configuring SCEP")
+
+	return b, s, ca
+}
+
+func scepPostRequest(s logical.Storage, path string, msg []byte) *logical.Request {
+	httpReq, _ := http.NewRequest(http.MethodPost, "/v1/pki/"+path+"?operation=PKIOperation", bytes.NewReader(msg))
+
+	return &logical.Request{
+		Operation:   logical.UpdateOperation,
+		Path:        path,
+		Storage:     s,
+		Data:        map[string]interface{}{"operation": scepOperationPKIOperation},
+		HTTPRequest: httpReq,
+	}
+}
+
+func requireScepResponse(t *testing.T, resp *logical.Response, err error, contentType string) []byte {
+	t.Helper()
+
+	require.NoError(t, err)
+	require.NotNil(t, resp)
+	require.Equal(t, http.StatusOK, resp.Data[logical.HTTPStatusCode], "unexpected response: %v", resp.Data)
+	require.Equal(t, contentType, resp.Data[logical.HTTPContentType])
+	return resp.Data[logical.HTTPRawBody].([]byte)
+}
+
+func TestScep_Config(t *testing.T) {
+	t.Parallel()
+
+	b, s := CreateBackendWithStorage(t)
+
+	resp, err := CBRead(b, s, "config/scep")
+	requireSuccessNonNilResponse(t, resp, err)
+	require.Equal(t, false, resp.Data["enabled"])
+	require.Equal(t, int64(3600), resp.Data["challenge_ttl"])
+
+	_, err = CBWrite(b, s, "config/scep", map[string]interface{}{
+		"enabled":      true,
+		"default_role": "unknown",
+	})
+	require.Error(t, err, "expected error with unknown default_role")
+
+	// Challenges can only be minted once SCEP is enabled.
+	resp, err = CBWrite(b, s, "roles/scep", map[string]interface{}{"allow_any_name": true})
+	requireSuccessNonNilResponse(t, resp, err)
+	_, err = CBWrite(b, s, "roles/scep/scep/challenge", map[string]interface{}{})
+	require.Error(t, err, "expected error with SCEP disabled")
+
+	resp, err = CBWrite(b, s, "config/scep", map[string]interface{}{
+		"enabled":       true,
+		"default_role":  "scep",
+		"challenge_ttl": "10m",
+	})
+	requireSuccessNonNilResponse(t, resp, err)
+
+	resp, err = CBRead(b, s, "config/scep")
+	requireSuccessNonNilResponse(t, resp, err)
+	require.Equal(t, true, resp.Data["enabled"])
+	require.Equal(t, "scep", resp.Data["default_role"])
+	require.Equal(t, int64(600), resp.Data["challenge_ttl"])
+	require.NotEmpty(t, resp.Data["last_updated"])
+
+	resp, err = CBWrite(b, s, "roles/scep/scep/challenge", map[string]interface{}{})
+	requireSuccessNonNilResponse(t, resp, err)
+	require.Len(t, resp.Data["challenge"], 32)
+	require.Equal(t, "scep", resp.Data["role"])
+	expiration, err := time.Parse(time.RFC3339, resp.Data["expiration"].(string))
+	require.NoError(t, err)
+	require.WithinDuration(t, time.Now().Add(10*time.Minute), expiration, time.Minute)
+
+	_, err = CBWrite(b, s, "roles/unknown/scep/challenge", map[string]interface{}{})
+	require.Error(t, err, "expected error with unknown role")
+}
+
+func TestScep_GetCACapsAndCACert(t *testing.T) {
+	t.Parallel()
+
+	b, s, ca := setupScepBackend(t)
+
+	for _, path := range []string{"scep", "roles/scep/scep"} {
+		resp, err := CBReq(b, s, logical.ReadOperation, path, map[string]interface{}{"operation": scepOperationGetCACaps})
+		caps := requireScepResponse(t, resp, err, "text/plain")
+		require.Contains(t, strings.Split(string(caps), "\n"), "POSTPKIOperation")
+		require.Contains(t, strings.Split(string(caps), "\n"), "SHA-256")
+		require.Contains(t, strings.Split(string(caps), "\n"), "AES")
+
+		// A root is returned on its own.
+		resp, err = CBReq(b, s, logical.ReadOperation, path, map[string]interface{}{"operation": scepOperationGetCACert})
+		der := requireScepResponse(t, resp, err, scepCACertContentType)
+		require.Equal(t, ca.Raw, der)
+	}
+
+	_, err := CBReq(b, s, logical.ReadOperation, "scep", map[string]interface{}{"operation": "GetNextCACert"})
+	require.Error(t, err, "expected error with unsupported operation")
+
+	_, err = CBReq(b, s, logical.ReadOperation, "roles/unknown/scep", map[string]interface{}{"operation": scepOperationGetCACaps})
+	require.Error(t, err, "expected error with unknown role")
+
+	// Issuers with EC keys can't decrypt SCEP requests.
+	resp, err := CBWrite(b, s, "root/generate/internal", map[string]interface{}{
+		"common_name": "ec-root-ca.com",
+		"key_type":    "ec",
+		"issuer_name": "ec-root",
+	})
+	requireSuccessNonNilResponse(t, resp, err)
+	resp, err = CBWrite(b, s, "roles/ec", map[string]interface{}{
+		"allow_any_name": true,
+		"issuer_ref":     "ec-root",
+	})
+	requireSuccessNonNilResponse(t, resp, err)
+	_, err = CBReq(b, s, logical.ReadOperation, "roles/ec/scep", map[string]interface{}{"operation": scepOperationGetCACert})
+	require.ErrorContains(t, err, "RSA key")
+}
+
+func TestScep_Enroll(t *testing.T) {
+	t.Parallel()
+
+	b, s, ca := setupScepBackend(t)
+	ctx := context.Background()
+	client := newScepTestClient(t, "device.example.com")
+
+	resp, err := CBWrite(b, s, "roles/scep/scep/challenge", map[string]interface{}{})
+	requireSuccessNonNilResponse(t, resp, err)
+	challenge := resp.Data["challenge"].(string)
+
+	csr := client.csr(t, "device.example.com", challenge)
+	msg, nonce := client.pkcsReq(t, ca, csr, pkcs7.EncryptionAlgorithmAES128CBC)
+
+	resp, err = b.HandleRequest(ctx, scepPostRequest(s, "scep", msg))
+	body := requireScepResponse(t, resp, err, scepPKIMessageType)
+	status, _, cert := client.certRep(t, ca, body, nonce)
+	require.Equal(t, scepStatusSuccess, status)
+	require.Equal(t, "device.example.com", cert.Subject.CommonName)
+	require.NoError(t, cert.CheckSignatureFrom(ca))
+
+	// The certificate was stored.
+	resp, err = CBRead(b, s, "cert/"+serialFromCert(cert))
+	requireSuccessNonNilResponse(t, resp, err)
+
+	// Challenges can only be used once.
+	resp, err = b.HandleRequest(ctx, scepPostRequest(s, "scep", msg))
+	body = requireScepResponse(t, resp, err, scepPKIMessageType)
+	status, failInfo, _ := client.certRep(t, ca, body, nonce)
+	require.Equal(t, scepStatusFailure, status)
+	require.Equal(t, scepFailBadRequest, failInfo)
+
+	// Challenges are tied to the role they were minted for.
+	resp, err = CBWrite(b, s, "roles/other", map[string]interface{}{"allow_any_name": true, "ttl": "1h"})
+	requireSuccessNonNilResponse(t, resp, err)
+	resp, err = CBWrite(b, s, "roles/other/scep/challenge", map[string]interface{}{})
+	requireSuccessNonNilResponse(t, resp, err)
+	otherChallenge := resp.Data["challenge"].(string)
+
+	msg, nonce = client.pkcsReq(t, ca, client.csr(t, "device.example.com", otherChallenge), pkcs7.EncryptionAlgorithmAES128CBC)
+	resp, err = b.HandleRequest(ctx, scepPostRequest(s, "scep", msg))
+	body = requireScepResponse(t, resp, err, scepPKIMessageType)
+	status, failInfo, _ = client.certRep(t, ca, body, nonce)
+	require.Equal(t, scepStatusFailure, status)
+	require.Equal(t, scepFailBadRequest, failInfo)
+
+	// The role still validates the CSR.
+	resp, err = CBWrite(b, s, "roles/scep/scep/challenge", map[string]interface{}{})
+	requireSuccessNonNilResponse(t, resp, err)
+	retryChallenge := resp.Data["challenge"].(string)
+	msg, nonce = client.pkcsReq(t, ca, client.csr(t, "device.hashicorp.com", retryChallenge), pkcs7.EncryptionAlgorithmAES128CBC)
+	resp, err = b.HandleRequest(ctx, scepPostRequest(s, "scep", msg))
+	body = requireScepResponse(t, resp, err, scepPKIMessageType)
+	status, failInfo, _ = client.certRep(t, ca, body, nonce)
+	require.Equal(t, scepStatusFailure, status)
+	require.Equal(t, scepFailBadRequest, failInfo)
+
+	// A rejected CSR doesn't use up the challenge.
+	msg, nonce = client.pkcsReq(t, ca, client.csr(t, "device.example.com", retryChallenge), pkcs7.EncryptionAlgorithmAES128CBC)
+	resp, err = b.HandleRequest(ctx, scepPostRequest(s, "scep", msg))
+	body = requireScepResponse(t, resp, err, scepPKIMessageType)
+	status, _, cert = client.certRep(t, ca, body, nonce)
+	require.Equal(t, scepStatusSuccess, status)
+	require.Equal(t, "device.example.com", cert.Subject.CommonName)
+
+	// Responses are encrypted with the algorithm of the request, and
+	// PKIOperation also works through GET.
+	resp, err = CBWrite(b, s, "roles/other/scep/challenge", map[string]interface{}{})
+	requireSuccessNonNilResponse(t, resp, err)
+	msg, nonce = client.pkcsReq(t, ca, client.csr(t, "device.example.com", resp.Data["challenge"].(string)), pkcs7.EncryptionAlgorithmDESEDE3CBC)
+	resp, err = b.HandleRequest(ctx, &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "roles/other/scep",
+		Storage:   s,
+		Data: map[string]interface{}{
+			"operation": scepOperationPKIOperation,
+			"message":   base64.StdEncoding.EncodeToString(msg),
+		},
+	})
+	body = requireScepResponse(t, resp, err, scepPKIMessageType)
+	status, _, cert = client.certRep(t, ca, body, nonce)
+	require.Equal(t, scepStatusSuccess, status)
+	require.Equal(t, "device.example.com", cert.Subject.CommonName)
+
+	p7, err := pkcs7.Parse(body)
+	require.NoError(t, err)
+	envelope, err := pkcs7.Parse(p7.Content)
+	require.NoError(t, err)
+	algorithm, err := envelope.EncryptionAlgorithm()
+	require.NoError(t, err)
+	require.Equal(t, pkcs7.EncryptionAlgorithmDESEDE3CBC, algorithm)
+
+	// Messages which can't be verified are rejected outright.
+	resp, err = b.HandleRequest(ctx, scepPostRequest(s, "scep", []byte("not a pki message")))
+	require.NoError(t, err)
+	require.True(t, resp.IsError(), "expected error with invalid message: %v", resp)
+}
+
+// TestScep_Integration enrolls through the HTTP API, as SCEP clients do.
+func TestScep_Integration(t *testing.T) {
+	t.Parallel()
+
+	coreConfig := &vault.CoreConfig{
+		LogicalBackends: map[string]logical.Factory{
+			"pki": Factory,
+		},
+	}
+	cluster := vault.NewTestCluster(t, coreConfig, &vault.TestClusterOptions{
+		HandlerFunc: vaulthttp.Handler,
+	})
+	cluster.Start()
+	defer cluster.Cleanup()
+	client := cluster.Cores[0].Client
+
+	err := client.Sys().Mount("pki", &api.MountInput{Type: "pki"})
+	require.NoError(t, err)
+	resp, err := client.Logical().Write("pki/root/generate/internal", map[string]interface{}{
+		"common_name": "root-ca.com",
+		"key_type":    "rsa",
+	})
+	require.NoError(t, err)
+	ca := parseCert(t, resp.Data["certificate"].(string))
+
+	_, err = client.Logical().Write("pki/roles/scep", map[string]interface{}{
+		"allowed_domains":  "example.com",
+		"allow_subdomains": true,
+		"ttl":              "1h",
+	})
+	require.NoError(t, err)
+	_, err = client.Logical().Write("pki/config/scep", map[string]interface{}{"enabled": true})
+	require.NoError(t, err)
+	resp, err = client.Logical().Write("pki/roles/scep/scep/challenge", nil)
+	require.NoError(t, err)
+	challenge := resp.Data["challenge"].(string)
+
+	httpClient := client.CloneConfig().HttpClient
+	scepURL := client.Address() + "/v1/pki/roles/scep/scep"
+
+	httpResp, err := httpClient.Get(scepURL + "?operation=GetCACert")
+	require.NoError(t, err)
+	body, err := io.ReadAll(httpResp.Body)
+	httpResp.Body.Close()
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, httpResp.StatusCode, "unexpected response: %s", body)
+	require.Equal(t, scepCACertContentType, httpResp.Header.Get("Content-Type"))
+	require.Equal(t, ca.Raw, body)
+
+	scepClient := newScepTestClient(t, "device.example.com")
+	msg, nonce := scepClient.pkcsReq(t, ca, scepClient.csr(t, "device.example.com", challenge), pkcs7.EncryptionAlgorithmAES128CBC)
+
+	httpResp, err = httpClient.Post(scepURL+"?operation=PKIOperation", scepPKIMessageType, bytes.NewReader(msg))
+	require.NoError(t, err)
+	body, err = io.ReadAll(httpResp.Body)
+	httpResp.Body.Close()
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, httpResp.StatusCode, "unexpected response: %s", body)
+	require.Equal(t, scepPKIMessageType, httpResp.Header.Get("Content-Type"))
+
+	status, _, cert := scepClient.certRep(t, ca, body, nonce)
+	require.Equal(t, scepStatusSuccess, status)
+	require.Equal(t, "device.example.com", cert.Subject.CommonName)
+}
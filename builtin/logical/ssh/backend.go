@@ -7,6 +7,7 @@ import (
 	"context"
 	"strings"
 	"sync"
+	"sync/atomic"
 
 	"github.com/hashicorp/vault/sdk/framework"
 	"github.com/hashicorp/vault/sdk/helper/salt"
@@ -20,6 +21,17 @@ type backend struct {
 	view      logical.Storage
 	salt      *salt.Salt
 	saltMutex sync.RWMutex
+
+	// issuersLock serializes changes to the issuers and their configuration
+	issuersLock      sync.Mutex
+	legacyCAMigrated atomic.Bool
+
+	// revokeLock serializes revocations and rebuilding the KRL
+	revokeLock sync.Mutex
+
+	tidyCASGuard   atomic.Bool
+	tidyStatusLock sync.RWMutex
+	tidyStatus     *tidyStatus
 }
 
 func Factory(ctx context.Context, conf *logical.BackendConfig) (logical.Backend, error) {
@@ -43,16 +55,23 @@ func Backend(conf *logical.BackendConfig) (*backend, error) {
 			Unauthenticated: []string{
 				"verify",
 				"public_key",
+				"issuer/+/public_key",
+				"krl",
+				"renew",
 			},
 
 			LocalStorage: []string{
 				"otp/",
+				certsStoragePrefix,
+				revokedStoragePrefix,
+				krlStoragePath,
 			},
 
 			SealWrapStorage: []string{
 				caPrivateKey,
 				caPrivateKeyStoragePath,
 				keysStoragePrefix,
+				issuerPrefix,
 			},
 		},
 
@@ -64,18 +83,30 @@ func Backend(conf *logical.BackendConfig) (*backend, error) {
 			pathLookup(&b),
 			pathVerify(&b),
 			pathConfigCA(&b),
+			pathConfigIssuers(&b),
+			pathListIssuers(&b),
+			pathGenerateIssuer(&b),
+			pathImportIssuer(&b),
+			pathIssuer(&b),
+			pathFetchIssuerPublicKey(&b),
 			pathSign(&b),
 			pathIssue(&b),
 			pathFetchPublicKey(&b),
 			pathCleanupKeys(&b),
+			pathRenew(&b),
+			pathRevoke(&b),
+			pathFetchKRL(&b),
+			pathTidy(&b),
+			pathTidyStatus(&b),
 		},
 
 		Secrets: []*framework.Secret{
 			secretOTP(&b),
 		},
 
-		Invalidate:  b.invalidate,
-		BackendType: logical.TypeLogical,
+		InitializeFunc: b.initialize,
+		Invalidate:     b.invalidate,
+		BackendType:    logical.TypeLogical,
 	}
 	return &b, nil
 }
@@ -103,6 +134,10 @@ func (b *backend) Salt(ctx context.Context) (*salt.Salt, error) {
 	return salt, nil
 }
 
+func (b *backend) initialize(ctx context.Context, ir *logical.InitializationRequest) error {
+	return b.migrateLegacyCA(ctx, ir.Storage)
+}
+
 func (b *backend) invalidate(_ context.Context, key string) {
 	switch key {
 	case salt.DefaultLocation:
@@ -2757,18 +2757,29 @@ func TestProperAuthing(t *testing.T) {
 	// key := resp.Data["key"].(string)
 
 	paths := map[string]pathAuthChecker{
-		"config/ca":          shouldBeAuthed,
-		"config/zeroaddress": shouldBeAuthed,
-		"creds/test-otp":     shouldBeAuthed,
-		"issue/test-ca":      shouldBeAuthed,
-		"lookup":             shouldBeAuthed,
-		"public_key":         shouldBeUnauthedReadList,
-		"roles/test-ca":      shouldBeAuthed,
-		"roles/test-otp":     shouldBeAuthed,
-		"roles/":             shouldBeAuthed,
-		"sign/test-ca":       shouldBeAuthed,
-		"tidy/dynamic-keys":  shouldBeAuthed,
-		"verify":             shouldBeUnauthedWriteOnly,
+		"config/ca":                 shouldBeAuthed,
+		"config/issuers":            shouldBeAuthed,
+		"config/zeroaddress":        shouldBeAuthed,
+		"creds/test-otp":            shouldBeAuthed,
+		"issue/test-ca":             shouldBeAuthed,
+		"issuer/default":            shouldBeAuthed,
+		"issuer/default/public_key": shouldBeUnauthedReadList,
+		"issuers/":                  shouldBeAuthed,
+		"issuers/generate":          shouldBeAuthed,
+		"issuers/import":            shouldBeAuthed,
+		"krl":                       shouldBeUnauthedReadList,
+		"lookup":                    shouldBeAuthed,
+		"public_key":                shouldBeUnauthedReadList,
+		"renew":                     shouldBeUnauthedWriteOnly,
+		"revoke":                    shouldBeAuthed,
+		"roles/test-ca":             shouldBeAuthed,
+		"roles/test-otp":            shouldBeAuthed,
+		"roles/":                    shouldBeAuthed,
+		"sign/test-ca":              shouldBeAuthed,
+		"tidy":                      shouldBeAuthed,
+		"tidy-status":               shouldBeAuthed,
+		"tidy/dynamic-keys":         shouldBeAuthed,
+		"verify":                    shouldBeUnauthedWriteOnly,
 	}
 	for path, checkerType := range paths {
 		checker := pathAuthChckerMap[checkerType]
@@ -2806,6 +2817,9 @@ func TestProperAuthing(t *testing.T) {
 		if strings.Contains(raw_path, "{role}") && strings.Contains(raw_path, "creds") {
 			raw_path = strings.ReplaceAll(raw_path, "{role}", "test-otp")
 		}
+		if strings.Contains(raw_path, "{issuer_ref}") {
+			raw_path = strings.ReplaceAll(raw_path, "{issuer_ref}", "default")
+		}
 
 		handler, present := paths[raw_path]
 		if !present {
@@ -0,0 +1,126 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package ssh
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	certsStoragePrefix   = "certs/"
+	revokedStoragePrefix = "revoked/"
+)
+
+// issuedCertificate records a certificate signed by the backend, so that it
+// can be revoked by its serial number, key ID, or public key.
+type issuedCertificate struct {
+	SerialNumber    string    `json:"serial_number"`
+	KeyID           string    `json:"key_id"`
+	IssuerID        string    `json:"issuer_id"`
+	Role            string    `json:"role"`
+	PublicKey       string    `json:"public_key"`
+	CertType        string    `json:"cert_type"`
+	ValidPrincipals []string  `json:"valid_principals"`
+	Expiration      time.Time `json:"expiration"`
+	RevocationTime  time.Time `json:"revocation_time"`
+}
+
+func (c *issuedCertificate) Serial() (uint64, error) {
+	return parseSerialNumber(c.SerialNumber)
+}
+
+func (c *issuedCertificate) Revoked() bool {
+	return !c.RevocationTime.IsZero()
+}
+
+func formatSerialNumber(serial uint64) string {
+	return strconv.FormatUint(serial, 16)
+}
+
+// parseSerialNumber parses a serial number in the hexadecimal format returned
+// by the sign and issue endpoints.
+func parseSerialNumber(serialNumber string) (uint64, error) {
+	normalized := strings.TrimPrefix(strings.ToLower(strings.ReplaceAll(serialNumber, ":", "")), "0x")
+	serial, err := strconv.ParseUint(normalized, 16, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid serial number %q", serialNumber)
+	}
+	return serial, nil
+}
+
+// storeCertificate records a newly signed certificate along with the issuer
+// and role it was signed with.
+func storeCertificate(ctx context.Context, s logical.Storage, issuerID, roleName string, certificate *ssh.Certificate) error {
+	certType := "user"
+	if certificate.CertType == ssh.HostCert {
+		certType = "host"
+	}
+
+	entry := &issuedCertificate{
+		SerialNumber:    formatSerialNumber(certificate.Serial),
+		KeyID:           certificate.KeyId,
+		IssuerID:        issuerID,
+		Role:            roleName,
+		PublicKey:       strings.TrimSpace(string(ssh.MarshalAuthorizedKey(certificate.Key))),
+		CertType:        certType,
+		ValidPrincipals: certificate.ValidPrincipals,
+		Expiration:      time.Unix(int64(certificate.ValidBefore), 0).UTC(),
+	}
+
+	return writeCertificate(ctx, s, certsStoragePrefix, entry)
+}
+
+func writeCertificate(ctx context.Context, s logical.Storage, prefix string, cert *issuedCertificate) error {
+	entry, err := logical.StorageEntryJSON(prefix+cert.SerialNumber, cert)
+	if err != nil {
+		return err
+	}
+
+	return s.Put(ctx, entry)
+}
+
+func fetchCertificate(ctx context.Context, s logical.Storage, prefix, serialNumber string) (*issuedCertificate, error) {
+	entry, err := s.Get(ctx, prefix+serialNumber)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read certificate %s: %w", serialNumber, err)
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var cert issuedCertificate
+	if err := entry.DecodeJSON(&cert); err != nil {
+		return nil, fmt.Errorf("failed to decode certificate %s: %w", serialNumber, err)
+	}
+
+	return &cert, nil
+}
+
+// listCertificates returns all certificates stored below the prefix.
+func listCertificates(ctx context.Context, s logical.Storage, prefix string) ([]*issuedCertificate, error) {
+	serials, err := s.List(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	certs := make([]*issuedCertificate, 0, len(serials))
+	for _, serial := range serials {
+		cert, err := fetchCertificate(ctx, s, prefix, serial)
+		if err != nil {
+			return nil, err
+		}
+		if cert != nil {
+			certs = append(certs, cert)
+		}
+	}
+
+	return certs, nil
+}
@@ -0,0 +1,336 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package ssh
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/hashicorp/go-uuid"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/helper/consts"
+	"github.com/hashicorp/vault/sdk/logical"
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	issuerPrefix      = "issuers/"
+	issuersConfigPath = "config/issuers"
+
+	// legacyCAMigrationLogPath records the migration of the CA keypair stored
+	// before multiple issuers were supported. The keypair itself is kept so
+	// that older versions of Vault can still read it.
+	legacyCAMigrationLogPath = "config/legacy_ca_migration_log"
+
+	// defaultRef refers to the default issuer of the mount
+	defaultRef     = "default"
+	issuerRefParam = "issuer_ref"
+)
+
+var (
+	issuerNameMatcher = regexp.MustCompile("^" + framework.GenericNameRegex(issuerRefParam) + "$")
+
+	errNoDefaultIssuer = errors.New("no default issuer currently configured")
+)
+
+// sshIssuer is a CA keypair used to sign certificates.
+type sshIssuer struct {
+	ID           string    `json:"id"`
+	Name         string    `json:"name"`
+	PublicKey    string    `json:"public_key"`
+	PrivateKey   string    `json:"private_key"`
+	Disabled     bool      `json:"disabled"`
+	CreationTime time.Time `json:"creation_time"`
+}
+
+// Signer returns the signer of the issuer's private key.
+func (i *sshIssuer) Signer() (ssh.Signer, error) {
+	signer, err := ssh.ParsePrivateKey([]byte(i.PrivateKey))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse stored CA private key: %w", err)
+	}
+	return signer, nil
+}
+
+type issuersConfig struct {
+	DefaultIssuerID string `json:"default"`
+}
+
+// legacyCAMigrationLog records that the legacy CA keypair was migrated.
+type legacyCAMigrationLog struct {
+	Created       time.Time `json:"created"`
+	CreatedIssuer string    `json:"issuer_id"`
+}
+
+// migrateLegacyCA copies a CA keypair configured at config/ca before multiple
+// issuers were supported to an issuer, which becomes the default issuer. The
+// legacy keypair is left in place, and is no longer read once the migration
+// has been logged.
+func (b *backend) migrateLegacyCA(ctx context.Context, s logical.Storage) error {
+	if b.legacyCAMigrated.Load() {
+		return nil
+	}
+
+	b.issuersLock.Lock()
+	defer b.issuersLock.Unlock()
+
+	if b.legacyCAMigrated.Load() {
+		return nil
+	}
+
+	// Only migrate on a primary cluster or performance secondary with a local
+	// mount; other nodes will see the result through replication.
+	if b.System().ReplicationState().HasState(consts.ReplicationDRSecondary|consts.ReplicationPerformanceStandby) ||
+		(!b.System().LocalMount() && b.System().ReplicationState().HasState(consts.ReplicationPerformanceSecondary)) {
+		return nil
+	}
+
+	logEntry, err := s.Get(ctx, legacyCAMigrationLogPath)
+	if err != nil {
+		return fmt.Errorf("failed to read CA migration log: %w", err)
+	}
+	if logEntry != nil {
+		b.legacyCAMigrated.Store(true)
+		return nil
+	}
+
+	publicKeyEntry, err := caKey(ctx, s, caPublicKey)
+	if err != nil {
+		return fmt.Errorf("failed to read CA public key: %w", err)
+	}
+	privateKeyEntry, err := caKey(ctx, s, caPrivateKey)
+	if err != nil {
+		return fmt.Errorf("failed to read CA private key: %w", err)
+	}
+
+	hasPublicKey := publicKeyEntry != nil && publicKeyEntry.Key != ""
+	hasPrivateKey := privateKeyEntry != nil && privateKeyEntry.Key != ""
+	switch {
+	case hasPublicKey && hasPrivateKey:
+		issuer, err := b.writeNewIssuer(ctx, s, "", publicKeyEntry.Key, privateKeyEntry.Key)
+		if err != nil {
+			return err
+		}
+
+		config, err := getIssuersConfig(ctx, s)
+		if err != nil {
+			return err
+		}
+		if config.DefaultIssuerID == "" {
+			config.DefaultIssuerID = issuer.ID
+			if err := setIssuersConfig(ctx, s, config); err != nil {
+				return err
+			}
+		}
+
+		logEntry, err := logical.StorageEntryJSON(legacyCAMigrationLogPath, legacyCAMigrationLog{
+			Created:       time.Now(),
+			CreatedIssuer: issuer.ID,
+		})
+		if err != nil {
+			return err
+		}
+		if err := s.Put(ctx, logEntry); err != nil {
+			return err
+		}
+
+		b.Logger().Info("migrated CA keypair to issuer", "issuer_id", issuer.ID)
+	case hasPublicKey || hasPrivateKey:
+		b.Logger().Warn("skipping migration of incomplete CA keypair to issuer")
+	}
+
+	b.legacyCAMigrated.Store(true)
+	return nil
+}
+
+// writeNewIssuer stores a new issuer with the given keypair. The issuers lock
+// must be held.
+func (b *backend) writeNewIssuer(ctx context.Context, s logical.Storage, name, publicKey, privateKey string) (*sshIssuer, error) {
+	id, err := uuid.GenerateUUID()
+	if err != nil {
+		return nil, err
+	}
+
+	issuer := &sshIssuer{
+		ID:           id,
+		Name:         name,
+		PublicKey:    publicKey,
+		PrivateKey:   privateKey,
+		CreationTime: time.Now(),
+	}
+	if err := writeIssuer(ctx, s, issuer); err != nil {
+		return nil, err
+	}
+
+	return issuer, nil
+}
+
+// validateCAKeyPair checks that the private key can be parsed and, if the
+// public key is given, that it belongs to the private key. It returns the
+// public key in authorized key format.
+func validateCAKeyPair(publicKey, privateKey string) (string, error) {
+	signer, err := ssh.ParsePrivateKey([]byte(privateKey))
+	if err != nil {
+		return "", fmt.Errorf("Unable to parse private_key as an SSH private key: %v", err)
+	}
+
+	if publicKey == "" {
+		return string(ssh.MarshalAuthorizedKey(signer.PublicKey())), nil
+	}
+
+	parsed, err := parsePublicSSHKey(publicKey)
+	if err != nil {
+		return "", fmt.Errorf("Unable to parse public_key as an SSH public key: %v", err)
+	}
+	if !bytes.Equal(parsed.Marshal(), signer.PublicKey().Marshal()) {
+		return "", errors.New("public_key does not match private_key")
+	}
+
+	return publicKey, nil
+}
+
+// validateIssuerName checks that the name is valid and not used by another
+// issuer.
+func validateIssuerName(ctx context.Context, s logical.Storage, name, id string) error {
+	if name == "" {
+		return nil
+	}
+	if name == defaultRef {
+		return fmt.Errorf("reserved issuer name %q", defaultRef)
+	}
+	if !issuerNameMatcher.MatchString(name) {
+		return errors.New("issuer name contained invalid characters")
+	}
+
+	issuers, err := listIssuers(ctx, s)
+	if err != nil {
+		return err
+	}
+	for _, issuer := range issuers {
+		if issuer.Name == name && issuer.ID != id {
+			return fmt.Errorf("issuer name %q is already in use by issuer %s", name, issuer.ID)
+		}
+	}
+
+	return nil
+}
+
+func listIssuerIDs(ctx context.Context, s logical.Storage) ([]string, error) {
+	return s.List(ctx, issuerPrefix)
+}
+
+// listIssuers returns all issuers, ordered by creation time.
+func listIssuers(ctx context.Context, s logical.Storage) ([]*sshIssuer, error) {
+	ids, err := listIssuerIDs(ctx, s)
+	if err != nil {
+		return nil, err
+	}
+
+	issuers := make([]*sshIssuer, 0, len(ids))
+	for _, id := range ids {
+		issuer, err := fetchIssuerByID(ctx, s, id)
+		if err != nil {
+			return nil, err
+		}
+		if issuer != nil {
+			issuers = append(issuers, issuer)
+		}
+	}
+
+	sort.SliceStable(issuers, func(i, j int) bool {
+		return issuers[i].CreationTime.Before(issuers[j].CreationTime)
+	})
+
+	return issuers, nil
+}
+
+func fetchIssuerByID(ctx context.Context, s logical.Storage, id string) (*sshIssuer, error) {
+	entry, err := s.Get(ctx, issuerPrefix+id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read issuer %s: %w", id, err)
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var issuer sshIssuer
+	if err := entry.DecodeJSON(&issuer); err != nil {
+		return nil, fmt.Errorf("failed to decode issuer %s: %w", id, err)
+	}
+
+	return &issuer, nil
+}
+
+// fetchIssuerByRef returns the issuer with the given ID or name, or the
+// default issuer. It returns nil if there is no such issuer.
+func fetchIssuerByRef(ctx context.Context, s logical.Storage, ref string) (*sshIssuer, error) {
+	if ref == "" || ref == defaultRef {
+		config, err := getIssuersConfig(ctx, s)
+		if err != nil {
+			return nil, err
+		}
+		if config.DefaultIssuerID == "" {
+			return nil, nil
+		}
+		return fetchIssuerByID(ctx, s, config.DefaultIssuerID)
+	}
+
+	// Look the reference up as an ID first, which is quicker than fetching
+	// all issuers
+	issuer, err := fetchIssuerByID(ctx, s, ref)
+	if err != nil || issuer != nil {
+		return issuer, err
+	}
+
+	issuers, err := listIssuers(ctx, s)
+	if err != nil {
+		return nil, err
+	}
+	for _, issuer := range issuers {
+		if issuer.Name == ref {
+			return issuer, nil
+		}
+	}
+
+	return nil, nil
+}
+
+func writeIssuer(ctx context.Context, s logical.Storage, issuer *sshIssuer) error {
+	entry, err := logical.StorageEntryJSON(issuerPrefix+issuer.ID, issuer)
+	if err != nil {
+		return err
+	}
+
+	return s.Put(ctx, entry)
+}
+
+func getIssuersConfig(ctx context.Context, s logical.Storage) (*issuersConfig, error) {
+	entry, err := s.Get(ctx, issuersConfigPath)
+	if err != nil {
+		return nil, err
+	}
+
+	config := &issuersConfig{}
+	if entry != nil {
+		if err := entry.DecodeJSON(config); err != nil {
+			return nil, fmt.Errorf("failed to decode issuers config: %w", err)
+		}
+	}
+
+	return config, nil
+}
+
+func setIssuersConfig(ctx context.Context, s logical.Storage, config *issuersConfig) error {
+	entry, err := logical.StorageEntryJSON(issuersConfigPath, config)
+	if err != nil {
+		return err
+	}
+
+	return s.Put(ctx, entry)
+}
@@ -0,0 +1,178 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package ssh
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"golang.org/x/crypto/ssh"
+)
+
+const krlStoragePath = "krl"
+
+// Constants of the OpenSSH key revocation list format, as described in
+// PROTOCOL.krl of the OpenSSH sources.
+const (
+	krlMagic         uint64 = 0x5353484b524c0a00
+	krlFormatVersion uint32 = 1
+
+	krlSectionCertificates byte = 1
+	krlSectionCertSerials  byte = 0x20
+)
+
+// krlEntry is the KRL most recently built from the revoked certificates.
+type krlEntry struct {
+	Version uint64 `json:"version"`
+	KRL     []byte `json:"krl"`
+}
+
+// krlCASection lists the revoked serial numbers of certificates signed by a
+// single CA key.
+type krlCASection struct {
+	CAKey   ssh.PublicKey
+	Serials []uint64
+}
+
+// encodeKRL encodes a key revocation list in the OpenSSH format, which sshd
+// accepts in its RevokedKeys file.
+func encodeKRL(version uint64, generated time.Time, sections []krlCASection) []byte {
+	var buf bytes.Buffer
+
+	buf.Write(binary.BigEndian.AppendUint64(nil, krlMagic))
+	buf.Write(binary.BigEndian.AppendUint32(nil, krlFormatVersion))
+	buf.Write(binary.BigEndian.AppendUint64(nil, version))
+	buf.Write(binary.BigEndian.AppendUint64(nil, uint64(generated.Unix())))
+	// flags
+	buf.Write(binary.BigEndian.AppendUint64(nil, 0))
+	// reserved
+	writeKRLString(&buf, nil)
+	// comment
+	writeKRLString(&buf, nil)
+
+	for _, section := range sections {
+		if len(section.Serials) == 0 {
+			continue
+		}
+
+		var serials bytes.Buffer
+		for _, serial := range section.Serials {
+			serials.Write(binary.BigEndian.AppendUint64(nil, serial))
+		}
+
+		var certs bytes.Buffer
+		writeKRLString(&certs, section.CAKey.Marshal())
+		// reserved
+		writeKRLString(&certs, nil)
+		certs.WriteByte(krlSectionCertSerials)
+		writeKRLString(&certs, serials.Bytes())
+
+		buf.WriteByte(krlSectionCertificates)
+		writeKRLString(&buf, certs.Bytes())
+	}
+
+	return buf.Bytes()
+}
+
+func writeKRLString(buf *bytes.Buffer, data []byte) {
+	buf.Write(binary.BigEndian.AppendUint32(nil, uint32(len(data))))
+	buf.Write(data)
+}
+
+// rebuildKRL builds the KRL from the unexpired revoked certificates and
+// stores it. The revocation lock must be held.
+func (b *backend) rebuildKRL(ctx context.Context, s logical.Storage) error {
+	revoked, err := listCertificates(ctx, s, revokedStoragePrefix)
+	if err != nil {
+		return fmt.Errorf("failed to list revoked certificates: %w", err)
+	}
+
+	now := time.Now()
+	serialsByIssuer := make(map[string][]uint64)
+	for _, cert := range revoked {
+		// Expired certificates are refused by hosts anyway
+		if now.After(cert.Expiration) {
+			continue
+		}
+
+		serial, err := cert.Serial()
+		if err != nil {
+			return err
+		}
+		// OpenSSH does not accept a serial number of zero in a KRL
+		if serial == 0 {
+			continue
+		}
+		serialsByIssuer[cert.IssuerID] = append(serialsByIssuer[cert.IssuerID], serial)
+	}
+
+	issuerIDs := make([]string, 0, len(serialsByIssuer))
+	for issuerID := range serialsByIssuer {
+		issuerIDs = append(issuerIDs, issuerID)
+	}
+	sort.Strings(issuerIDs)
+
+	var sections []krlCASection
+	for _, issuerID := range issuerIDs {
+		issuer, err := fetchIssuerByID(ctx, s, issuerID)
+		if err != nil {
+			return err
+		}
+		// Hosts can no longer trust certificates of a deleted issuer
+		if issuer == nil {
+			continue
+		}
+
+		caKey, err := parsePublicSSHKey(issuer.PublicKey)
+		if err != nil {
+			return fmt.Errorf("failed to parse public key of issuer %s: %w", issuerID, err)
+		}
+
+		serials := serialsByIssuer[issuerID]
+		sort.Slice(serials, func(i, j int) bool { return serials[i] < serials[j] })
+		sections = append(sections, krlCASection{CAKey: caKey, Serials: serials})
+	}
+
+	previous, err := fetchKRL(ctx, s)
+	if err != nil {
+		return err
+	}
+
+	var version uint64 = 1
+	if previous != nil {
+		version = previous.Version + 1
+	}
+
+	entry, err := logical.StorageEntryJSON(krlStoragePath, &krlEntry{
+		Version: version,
+		KRL:     encodeKRL(version, now, sections),
+	})
+	if err != nil {
+		return err
+	}
+
+	return s.Put(ctx, entry)
+}
+
+func fetchKRL(ctx context.Context, s logical.Storage) (*krlEntry, error) {
+	entry, err := s.Get(ctx, krlStoragePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read KRL: %w", err)
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var krl krlEntry
+	if err := entry.DecodeJSON(&krl); err != nil {
+		return nil, fmt.Errorf("failed to decode KRL: %w", err)
+	}
+
+	return &krl, nil
+}
@@ -104,18 +104,22 @@ Read operations will return the public key, if already stored/generated.`,
 }
 
 func (b *backend) pathConfigCARead(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
-	publicKeyEntry, err := caKey(ctx, req.Storage, caPublicKey)
+	if err := b.migrateLegacyCA(ctx, req.Storage); err != nil {
+		return nil, err
+	}
+
+	issuer, err := fetchIssuerByRef(ctx, req.Storage, defaultRef)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read CA public key: %w", err)
 	}
 
-	if publicKeyEntry == nil {
+	if issuer == nil {
 		return logical.ErrorResponse("keys haven't been configured yet"), nil
 	}
 
 	response := &logical.Response{
 		Data: map[string]interface{}{
-			"public_key": publicKeyEntry.Key,
+			"public_key": issuer.PublicKey,
 		},
 	}
 
@@ -123,15 +127,29 @@ func (b *backend) pathConfigCARead(ctx context.Context, req *logical.Request, da
 }
 
 func (b *backend) pathConfigCADelete(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
-	if err := req.Storage.Delete(ctx, caPrivateKeyStoragePath); err != nil {
+	if err := b.migrateLegacyCA(ctx, req.Storage); err != nil {
+		return nil, err
+	}
+
+	b.issuersLock.Lock()
+	defer b.issuersLock.Unlock()
+
+	issuer, err := fetchIssuerByRef(ctx, req.Storage, defaultRef)
+	if err != nil {
 		return nil, err
 	}
-	if err := req.Storage.Delete(ctx, caPublicKeyStoragePath); err != nil {
+	if issuer == nil {
+		return nil, nil
+	}
+
+	if _, err := deleteIssuer(ctx, req.Storage, issuer); err != nil {
 		return nil, err
 	}
 	return nil, nil
 }
 
+// caKey reads a CA key stored before multiple issuers were supported; these
+// are copied to an issuer by migrateLegacyCA.
 func caKey(ctx context.Context, storage logical.Storage, keyType string) (*keyStorageEntry, error) {
 	var path, deprecatedPath string
 	switch keyType {
@@ -244,51 +262,36 @@ func (b *backend) pathConfigCAUpdate(ctx context.Context, req *logical.Request,
 		return nil, fmt.Errorf("failed to generate or parse the keys")
 	}
 
-	publicKeyEntry, err := caKey(ctx, req.Storage, caPublicKey)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read CA public key: %w", err)
+	if err := b.migrateLegacyCA(ctx, req.Storage); err != nil {
+		return nil, err
 	}
 
-	privateKeyEntry, err := caKey(ctx, req.Storage, caPrivateKey)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read CA private key: %w", err)
-	}
+	b.issuersLock.Lock()
+	defer b.issuersLock.Unlock()
 
-	if (publicKeyEntry != nil && publicKeyEntry.Key != "") || (privateKeyEntry != nil && privateKeyEntry.Key != "") {
-		return logical.ErrorResponse("keys are already configured; delete them before reconfiguring"), nil
-	}
-
-	entry, err := logical.StorageEntryJSON(caPublicKeyStoragePath, &keyStorageEntry{
-		Key: publicKey,
-	})
+	config, err := getIssuersConfig(ctx, req.Storage)
 	if err != nil {
 		return nil, err
 	}
 
-	// Save the public key
-	err = req.Storage.Put(ctx, entry)
-	if err != nil {
-		return nil, err
+	if config.DefaultIssuerID != "" {
+		return logical.ErrorResponse("keys are already configured; delete them before reconfiguring"), nil
 	}
 
-	entry, err = logical.StorageEntryJSON(caPrivateKeyStoragePath, &keyStorageEntry{
-		Key: privateKey,
-	})
+	issuer, err := b.writeNewIssuer(ctx, req.Storage, "", publicKey, privateKey)
 	if err != nil {
 		return nil, err
 	}
 
-	// Save the private key
-	err = req.Storage.Put(ctx, entry)
-	if err != nil {
+	config.DefaultIssuerID = issuer.ID
+	if err := setIssuersConfig(ctx, req.Storage, config); err != nil {
 		var mErr *multierror.Error
 
-		mErr = multierror.Append(mErr, fmt.Errorf("failed to store CA private key: %w", err))
+		mErr = multierror.Append(mErr, fmt.Errorf("failed to set default issuer: %w", err))
 
-		// If storing private key fails, the corresponding public key should be
-		// removed
-		if delErr := req.Storage.Delete(ctx, caPublicKeyStoragePath); delErr != nil {
-			mErr = multierror.Append(mErr, fmt.Errorf("failed to cleanup CA public key: %w", delErr))
+		// If setting the default issuer fails, the issuer should be removed
+		if delErr := req.Storage.Delete(ctx, issuerPrefix+issuer.ID); delErr != nil {
+			mErr = multierror.Append(mErr, fmt.Errorf("failed to cleanup issuer: %w", delErr))
 			return nil, mErr
 		}
 
@@ -5,6 +5,8 @@ package ssh
 
 import (
 	"context"
+	"strings"
+	"time"
 
 	"github.com/hashicorp/vault/sdk/framework"
 	"github.com/hashicorp/vault/sdk/logical"
@@ -24,23 +26,89 @@ func pathFetchPublicKey(b *backend) *framework.Path {
 		},
 
 		HelpSynopsis:    `Retrieve the public key.`,
-		HelpDescription: `This allows the public key of the SSH CA certificate that this backend has been configured with to be fetched. This is a raw response endpoint without JSON encoding; use -format=raw or an external tool (e.g., curl) to fetch this value.`,
+		HelpDescription: `This allows the public keys of all enabled SSH CA issuers of this backend to be fetched, one per line, starting with the default issuer. This is a raw response endpoint without JSON encoding; use -format=raw or an external tool (e.g., curl) to fetch this value.`,
 	}
 }
 
 func (b *backend) pathFetchPublicKey(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
-	publicKeyEntry, err := caKey(ctx, req.Storage, caPublicKey)
+	if err := b.migrateLegacyCA(ctx, req.Storage); err != nil {
+		return nil, err
+	}
+
+	issuers, err := listIssuers(ctx, req.Storage)
 	if err != nil {
 		return nil, err
 	}
-	if publicKeyEntry == nil || publicKeyEntry.Key == "" {
+	config, err := getIssuersConfig(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	// Publish the public key of the default issuer first, followed by those of
+	// all other enabled issuers, one per line, so the response can be used as
+	// is for TrustedUserCAKeys or @cert-authority entries.
+	var publicKeys []string
+	for _, issuer := range issuers {
+		if issuer.Disabled || issuer.PublicKey == "" {
+			continue
+		}
+		publicKey := strings.TrimSuffix(issuer.PublicKey, "\n")
+		if issuer.ID == config.DefaultIssuerID {
+			publicKeys = append([]string{publicKey}, publicKeys...)
+		} else {
+			publicKeys = append(publicKeys, publicKey)
+		}
+	}
+	if len(publicKeys) == 0 {
 		return nil, nil
 	}
 
 	response := &logical.Response{
 		Data: map[string]interface{}{
 			logical.HTTPContentType: "text/plain",
-			logical.HTTPRawBody:     []byte(publicKeyEntry.Key),
+			logical.HTTPRawBody:     []byte(strings.Join(publicKeys, "\n") + "\n"),
+			logical.HTTPStatusCode:  200,
+		},
+	}
+
+	return response, nil
+}
+
+func pathFetchKRL(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: `krl`,
+
+		DisplayAttrs: &framework.DisplayAttributes{
+			OperationPrefix: operationPrefixSSH,
+			OperationSuffix: "krl",
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.ReadOperation: b.pathFetchKRL,
+		},
+
+		HelpSynopsis:    `Retrieve the key revocation list.`,
+		HelpDescription: `This allows the OpenSSH key revocation list (KRL) of the certificates revoked by this backend to be fetched, for use as the RevokedKeys file of sshd. This is a raw response endpoint without JSON encoding; use -format=raw or an external tool (e.g., curl) to fetch this value.`,
+	}
+}
+
+func (b *backend) pathFetchKRL(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	krl, err := fetchKRL(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	// Nothing has been revoked yet, so serve an empty list
+	if krl == nil {
+		krl = &krlEntry{
+			KRL: encodeKRL(0, time.Now(), nil),
+		}
+	}
+
+	response := &logical.Response{
+		Data: map[string]interface{}{
+			logical.HTTPContentType: "application/octet-stream",
+			logical.HTTPRawBody:     krl.KRL,
 			logical.HTTPStatusCode:  200,
 		},
 	}
@@ -101,17 +101,31 @@ func (b *backend) pathSignIssueCertificateHelper(ctx context.Context, req *logic
 		return logical.ErrorResponse(err.Error()), nil
 	}
 
-	privateKeyEntry, err := caKey(ctx, req.Storage, caPrivateKey)
+	if err := b.migrateLegacyCA(ctx, req.Storage); err != nil {
+		return nil, err
+	}
+
+	issuerRef := role.IssuerRef
+	if issuerRef == "" {
+		issuerRef = defaultRef
+	}
+	issuer, err := fetchIssuerByRef(ctx, req.Storage, issuerRef)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read CA private key: %w", err)
 	}
-	if privateKeyEntry == nil || privateKeyEntry.Key == "" {
-		return nil, errors.New("failed to read CA private key")
+	if issuer == nil {
+		if issuerRef == defaultRef {
+			return nil, errors.New("failed to read CA private key")
+		}
+		return logical.ErrorResponse(fmt.Sprintf("issuer %q of role not found", issuerRef)), nil
+	}
+	if issuer.Disabled {
+		return logical.ErrorResponse(fmt.Sprintf("issuer %q of role is disabled", issuerRef)), nil
 	}
 
-	signer, err := ssh.ParsePrivateKey([]byte(privateKeyEntry.Key))
+	signer, err := issuer.Signer()
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse stored CA private key: %w", err)
+		return nil, err
 	}
 
 	cBundle := creationBundle{
@@ -136,6 +150,10 @@ func (b *backend) pathSignIssueCertificateHelper(ctx context.Context, req *logic
 		return nil, errors.New("error marshaling signed certificate")
 	}
 
+	if err := storeCertificate(ctx, req.Storage, issuer.ID, data.Get("role").(string), certificate); err != nil {
+		return nil, fmt.Errorf("unable to store certificate locally: %w", err)
+	}
+
 	response := &logical.Response{
 		Data: map[string]interface{}{
 			"serial_number": strconv.FormatUint(certificate.Serial, 16),
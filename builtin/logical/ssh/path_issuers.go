@@ -0,0 +1,574 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package ssh
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+func pathListIssuers(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "issuers/?$",
+
+		DisplayAttrs: &framework.DisplayAttributes{
+			OperationPrefix: operationPrefixSSH,
+			OperationSuffix: "issuers",
+		},
+
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.ListOperation: &framework.PathOperation{
+				Callback: b.pathListIssuersHandler,
+			},
+		},
+
+		HelpSynopsis:    pathListIssuersHelpSyn,
+		HelpDescription: pathListIssuersHelpDesc,
+	}
+}
+
+func pathGenerateIssuer(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "issuers/generate",
+
+		DisplayAttrs: &framework.DisplayAttributes{
+			OperationPrefix: operationPrefixSSH,
+			OperationVerb:   "generate",
+			OperationSuffix: "issuer",
+		},
+
+		Fields: map[string]*framework.FieldSchema{
+			"key_type": {
+				Type:        framework.TypeString,
+				Description: `Specifies the desired key type; could be a OpenSSH key type identifier (ssh-rsa, ecdsa-sha2-nistp256, ecdsa-sha2-nistp384, ecdsa-sha2-nistp521, or ssh-ed25519) or an algorithm (rsa, ec, ed25519).`,
+				Default:     "ssh-rsa",
+			},
+			"key_bits": {
+				Type:        framework.TypeInt,
+				Description: `Specifies the desired key bits for variable-length keys (such as when key_type="ssh-rsa") or which NIST P-curve to use when key_type="ec" (256, 384, or 521).`,
+				Default:     0,
+			},
+			"issuer_name": {
+				Type:        framework.TypeString,
+				Description: `Optional name of the issuer, which can be used to refer to it instead of its ID.`,
+			},
+			"set_default": {
+				Type:        framework.TypeBool,
+				Description: `Whether to make the issuer the default issuer. The first issuer of the mount always becomes the default.`,
+			},
+		},
+
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.UpdateOperation: &framework.PathOperation{
+				Callback: b.pathGenerateIssuerHandler,
+			},
+		},
+
+		HelpSynopsis:    pathGenerateIssuerHelpSyn,
+		HelpDescription: pathGenerateIssuerHelpDesc,
+	}
+}
+
+func pathImportIssuer(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "issuers/import",
+
+		DisplayAttrs: &framework.DisplayAttributes{
+			OperationPrefix: operationPrefixSSH,
+			OperationVerb:   "import",
+			OperationSuffix: "issuer",
+		},
+
+		Fields: map[string]*framework.FieldSchema{
+			"private_key": {
+				Type:        framework.TypeString,
+				Description: `Private half of the SSH key that will be used to sign certificates.`,
+				Required:    true,
+			},
+			"public_key": {
+				Type:        framework.TypeString,
+				Description: `Public half of the SSH key that will be used to sign certificates. If omitted, it is derived from the private key.`,
+			},
+			"issuer_name": {
+				Type:        framework.TypeString,
+				Description: `Optional name of the issuer, which can be used to refer to it instead of its ID.`,
+			},
+			"set_default": {
+				Type:        framework.TypeBool,
+				Description: `Whether to make the issuer the default issuer. The first issuer of the mount always becomes the default.`,
+			},
+		},
+
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.UpdateOperation: &framework.PathOperation{
+				Callback: b.pathImportIssuerHandler,
+			},
+		},
+
+		HelpSynopsis:    pathImportIssuerHelpSyn,
+		HelpDescription: pathImportIssuerHelpDesc,
+	}
+}
+
+func pathIssuer(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "issuer/" + framework.GenericNameRegex(issuerRefParam) + "$",
+
+		DisplayAttrs: &framework.DisplayAttributes{
+			OperationPrefix: operationPrefixSSH,
+			OperationSuffix: "issuer",
+		},
+
+		Fields: map[string]*framework.FieldSchema{
+			issuerRefParam: {
+				Type:        framework.TypeString,
+				Description: `Reference to an existing issuer; either "default" for the default issuer, an identifier of an issuer, or the name assigned to an issuer.`,
+			},
+			"issuer_name": {
+				Type:        framework.TypeString,
+				Description: `Name of the issuer, which can be used to refer to it instead of its ID.`,
+			},
+			"disabled": {
+				Type:        framework.TypeBool,
+				Description: `Whether the issuer is disabled. Disabled issuers can not sign certificates and their public key is not published at the public_key endpoint. The default issuer can not be disabled.`,
+			},
+		},
+
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.ReadOperation: &framework.PathOperation{
+				Callback: b.pathReadIssuerHandler,
+			},
+			logical.UpdateOperation: &framework.PathOperation{
+				Callback: b.pathUpdateIssuerHandler,
+				DisplayAttrs: &framework.DisplayAttributes{
+					OperationVerb: "write",
+				},
+			},
+			logical.DeleteOperation: &framework.PathOperation{
+				Callback: b.pathDeleteIssuerHandler,
+			},
+		},
+
+		HelpSynopsis:    pathIssuerHelpSyn,
+		HelpDescription: pathIssuerHelpDesc,
+	}
+}
+
+func pathFetchIssuerPublicKey(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "issuer/" + framework.GenericNameRegex(issuerRefParam) + "/public_key",
+
+		DisplayAttrs: &framework.DisplayAttributes{
+			OperationPrefix: operationPrefixSSH,
+			OperationSuffix: "issuer-public-key",
+		},
+
+		Fields: map[string]*framework.FieldSchema{
+			issuerRefParam: {
+				Type:        framework.TypeString,
+				Description: `Reference to an existing issuer; either "default" for the default issuer, an identifier of an issuer, or the name assigned to an issuer.`,
+			},
+		},
+
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.ReadOperation: &framework.PathOperation{
+				Callback: b.pathFetchIssuerPublicKeyHandler,
+			},
+		},
+
+		HelpSynopsis:    `Retrieve the public key of an issuer.`,
+		HelpDescription: `This allows the public key of a single issuer to be fetched. This is a raw response endpoint without JSON encoding; use -format=raw or an external tool (e.g., curl) to fetch this value.`,
+	}
+}
+
+func pathConfigIssuers(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "config/issuers",
+
+		DisplayAttrs: &framework.DisplayAttributes{
+			OperationPrefix: operationPrefixSSH,
+		},
+
+		Fields: map[string]*framework.FieldSchema{
+			defaultRef: {
+				Type:        framework.TypeString,
+				Description: `Reference (name or identifier) to the default issuer.`,
+			},
+		},
+
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.ReadOperation: &framework.PathOperation{
+				Callback: b.pathReadIssuersConfigHandler,
+				DisplayAttrs: &framework.DisplayAttributes{
+					OperationVerb:   "read",
+					OperationSuffix: "issuers-configuration",
+				},
+			},
+			logical.UpdateOperation: &framework.PathOperation{
+				Callback: b.pathWriteIssuersConfigHandler,
+				DisplayAttrs: &framework.DisplayAttributes{
+					OperationVerb:   "configure",
+					OperationSuffix: "issuers",
+				},
+			},
+		},
+
+		HelpSynopsis:    pathConfigIssuersHelpSyn,
+		HelpDescription: pathConfigIssuersHelpDesc,
+	}
+}
+
+func (b *backend) pathListIssuersHandler(ctx context.Context, req *logical.Request, _ *framework.FieldData) (*logical.Response, error) {
+	if err := b.migrateLegacyCA(ctx, req.Storage); err != nil {
+		return nil, err
+	}
+
+	issuers, err := listIssuers(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+	config, err := getIssuersConfig(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []string
+	keyInfo := make(map[string]interface{}, len(issuers))
+	for _, issuer := range issuers {
+		keys = append(keys, issuer.ID)
+		keyInfo[issuer.ID] = map[string]interface{}{
+			"issuer_name": issuer.Name,
+			"is_default":  issuer.ID == config.DefaultIssuerID,
+			"disabled":    issuer.Disabled,
+		}
+	}
+
+	return logical.ListResponseWithInfo(keys, keyInfo), nil
+}
+
+func (b *backend) pathGenerateIssuerHandler(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	publicKey, privateKey, err := generateSSHKeyPair(b.Backend.GetRandomReader(), data.Get("key_type").(string), data.Get("key_bits").(int))
+	if err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+
+	return b.createIssuer(ctx, req.Storage, data.Get("issuer_name").(string), publicKey, privateKey, data.Get("set_default").(bool))
+}
+
+func (b *backend) pathImportIssuerHandler(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	privateKey := data.Get("private_key").(string)
+	if privateKey == "" {
+		return logical.ErrorResponse("missing private_key"), nil
+	}
+
+	publicKey, err := validateCAKeyPair(data.Get("public_key").(string), privateKey)
+	if err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+
+	return b.createIssuer(ctx, req.Storage, data.Get("issuer_name").(string), publicKey, privateKey, data.Get("set_default").(bool))
+}
+
+// createIssuer stores a new issuer and makes it the default issuer if
+// requested or if there is no default issuer yet.
+func (b *backend) createIssuer(ctx context.Context, s logical.Storage, name, publicKey, privateKey string, setDefault bool) (*logical.Response, error) {
+	if err := b.migrateLegacyCA(ctx, s); err != nil {
+		return nil, err
+	}
+
+	b.issuersLock.Lock()
+	defer b.issuersLock.Unlock()
+
+	if err := validateIssuerName(ctx, s, name, ""); err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+
+	config, err := getIssuersConfig(ctx, s)
+	if err != nil {
+		return nil, err
+	}
+
+	issuer, err := b.writeNewIssuer(ctx, s, name, publicKey, privateKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if setDefault || config.DefaultIssuerID == "" {
+		config.DefaultIssuerID = issuer.ID
+		if err := setIssuersConfig(ctx, s, config); err != nil {
+			return nil, err
+		}
+	}
+
+	return respondReadIssuer(issuer, config.DefaultIssuerID == issuer.ID), nil
+}
+
+func (b *backend) pathReadIssuerHandler(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	if err := b.migrateLegacyCA(ctx, req.Storage); err != nil {
+		return nil, err
+	}
+
+	issuer, resp, err := fetchIssuerForRequest(ctx, req.Storage, data)
+	if issuer == nil {
+		return resp, err
+	}
+
+	config, err := getIssuersConfig(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	return respondReadIssuer(issuer, config.DefaultIssuerID == issuer.ID), nil
+}
+
+func (b *backend) pathUpdateIssuerHandler(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	if err := b.migrateLegacyCA(ctx, req.Storage); err != nil {
+		return nil, err
+	}
+
+	b.issuersLock.Lock()
+	defer b.issuersLock.Unlock()
+
+	issuer, resp, err := fetchIssuerForRequest(ctx, req.Storage, data)
+	if issuer == nil {
+		return resp, err
+	}
+
+	config, err := getIssuersConfig(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+	isDefault := config.DefaultIssuerID == issuer.ID
+
+	modified := false
+
+	if nameRaw, ok := data.GetOk("issuer_name"); ok {
+		name := nameRaw.(string)
+		if name != issuer.Name {
+			if err := validateIssuerName(ctx, req.Storage, name, issuer.ID); err != nil {
+				return logical.ErrorResponse(err.Error()), nil
+			}
+			issuer.Name = name
+			modified = true
+		}
+	}
+
+	if disabledRaw, ok := data.GetOk("disabled"); ok {
+		disabled := disabledRaw.(bool)
+		if disabled && isDefault {
+			return logical.ErrorResponse("the default issuer can not be disabled; set another default issuer first"), nil
+		}
+		if disabled != issuer.Disabled {
+			issuer.Disabled = disabled
+			modified = true
+		}
+	}
+
+	if modified {
+		if err := writeIssuer(ctx, req.Storage, issuer); err != nil {
+			return nil, err
+		}
+	}
+
+	return respondReadIssuer(issuer, isDefault), nil
+}
+
+func (b *backend) pathDeleteIssuerHandler(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	if err := b.migrateLegacyCA(ctx, req.Storage); err != nil {
+		return nil, err
+	}
+
+	b.issuersLock.Lock()
+	defer b.issuersLock.Unlock()
+
+	issuer, err := fetchIssuerByRef(ctx, req.Storage, data.Get(issuerRefParam).(string))
+	if err != nil {
+		return nil, err
+	}
+	if issuer == nil {
+		return nil, nil
+	}
+
+	return deleteIssuer(ctx, req.Storage, issuer)
+}
+
+// deleteIssuer removes the issuer, unsetting the default issuer if it was the
+// default. The issuers lock must be held.
+func deleteIssuer(ctx context.Context, s logical.Storage, issuer *sshIssuer) (*logical.Response, error) {
+	if err := s.Delete(ctx, issuerPrefix+issuer.ID); err != nil {
+		return nil, err
+	}
+
+	config, err := getIssuersConfig(ctx, s)
+	if err != nil {
+		return nil, err
+	}
+	if config.DefaultIssuerID != issuer.ID {
+		return nil, nil
+	}
+
+	config.DefaultIssuerID = ""
+	if err := setIssuersConfig(ctx, s, config); err != nil {
+		return nil, err
+	}
+
+	resp := &logical.Response{}
+	resp.AddWarning("Deleted the default issuer; roles referring to the default issuer can not sign certificates until another default issuer is set.")
+	return resp, nil
+}
+
+func (b *backend) pathFetchIssuerPublicKeyHandler(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	if err := b.migrateLegacyCA(ctx, req.Storage); err != nil {
+		return nil, err
+	}
+
+	issuer, err := fetchIssuerByRef(ctx, req.Storage, data.Get(issuerRefParam).(string))
+	if err != nil {
+		return nil, err
+	}
+	if issuer == nil {
+		return nil, nil
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			logical.HTTPContentType: "text/plain",
+			logical.HTTPRawBody:     []byte(issuer.PublicKey),
+			logical.HTTPStatusCode:  200,
+		},
+	}, nil
+}
+
+func (b *backend) pathReadIssuersConfigHandler(ctx context.Context, req *logical.Request, _ *framework.FieldData) (*logical.Response, error) {
+	if err := b.migrateLegacyCA(ctx, req.Storage); err != nil {
+		return nil, err
+	}
+
+	config, err := getIssuersConfig(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			defaultRef: config.DefaultIssuerID,
+		},
+	}, nil
+}
+
+func (b *backend) pathWriteIssuersConfigHandler(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	if err := b.migrateLegacyCA(ctx, req.Storage); err != nil {
+		return nil, err
+	}
+
+	b.issuersLock.Lock()
+	defer b.issuersLock.Unlock()
+
+	ref := data.Get(defaultRef).(string)
+	if ref == "" || ref == defaultRef {
+		return logical.ErrorResponse("a reference to an existing issuer is required to set the default issuer"), nil
+	}
+
+	issuer, err := fetchIssuerByRef(ctx, req.Storage, ref)
+	if err != nil {
+		return nil, err
+	}
+	if issuer == nil {
+		return logical.ErrorResponse(fmt.Sprintf("issuer %q not found", ref)), nil
+	}
+	if issuer.Disabled {
+		return logical.ErrorResponse("a disabled issuer can not be the default issuer"), nil
+	}
+
+	config, err := getIssuersConfig(ctx, req.Storage)
+	if err != nil {
+		return nil, err
+	}
+	if config.DefaultIssuerID != issuer.ID {
+		config.DefaultIssuerID = issuer.ID
+		if err := setIssuersConfig(ctx, req.Storage, config); err != nil {
+			return nil, err
+		}
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			defaultRef: config.DefaultIssuerID,
+		},
+	}, nil
+}
+
+// fetchIssuerForRequest returns the issuer referenced by the request, or an
+// error response if there is no such issuer.
+func fetchIssuerForRequest(ctx context.Context, s logical.Storage, data *framework.FieldData) (*sshIssuer, *logical.Response, error) {
+	ref := data.Get(issuerRefParam).(string)
+	issuer, err := fetchIssuerByRef(ctx, s, ref)
+	if err != nil {
+		return nil, nil, err
+	}
+	if issuer == nil {
+		if ref == defaultRef {
+			return nil, logical.ErrorResponse(errNoDefaultIssuer.Error()), nil
+		}
+		return nil, logical.ErrorResponse(fmt.Sprintf("issuer %q not found", ref)), nil
+	}
+
+	return issuer, nil, nil
+}
+
+func respondReadIssuer(issuer *sshIssuer, isDefault bool) *logical.Response {
+	keyType := ""
+	if publicKey, err := parsePublicSSHKey(issuer.PublicKey); err == nil {
+		keyType = publicKey.Type()
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"issuer_id":   issuer.ID,
+			"issuer_name": issuer.Name,
+			"public_key":  issuer.PublicKey,
+			"key_type":    keyType,
+			"disabled":    issuer.Disabled,
+			"is_default":  isDefault,
+		},
+	}
+}
+
+const (
+	pathListIssuersHelpSyn  = `Fetch a list of the SSH CA issuers.`
+	pathListIssuersHelpDesc = `
+This endpoint allows listing of the issuers of this mount, by their identifier,
+along with their names, whether they are the default issuer, and whether they
+are disabled.
+`
+
+	pathGenerateIssuerHelpSyn  = `Generate a new SSH CA issuer.`
+	pathGenerateIssuerHelpDesc = `
+This endpoint generates a new SSH CA keypair and stores it as an issuer. The
+private key cannot be retrieved later.
+`
+
+	pathImportIssuerHelpSyn  = `Import an SSH CA keypair as a new issuer.`
+	pathImportIssuerHelpDesc = `
+This endpoint stores the given SSH CA keypair as an issuer. The fields must be
+in the standard private and public SSH format. For security reasons, the
+private key cannot be retrieved later.
+`
+
+	pathIssuerHelpSyn  = `Fetch, update, or delete a single SSH CA issuer.`
+	pathIssuerHelpDesc = `
+This endpoint allows fetching the public key of an issuer, renaming or
+disabling it, or deleting it. Roles referring to a deleted issuer can no longer
+sign certificates.
+`
+
+	pathConfigIssuersHelpSyn  = `Read and set the default SSH CA issuer.`
+	pathConfigIssuersHelpDesc = `
+This endpoint allows setting the issuer used by roles which do not refer to a
+specific issuer, as well as by the config/ca endpoint.
+`
+)
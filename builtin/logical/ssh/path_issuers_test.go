@@ -0,0 +1,323 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package ssh
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"golang.org/x/crypto/ssh"
+)
+
+func TestSSH_Issuers(t *testing.T) {
+	config := logical.TestBackendConfig()
+	config.StorageView = &logical.InmemStorage{}
+
+	b, err := Factory(context.Background(), config)
+	if err != nil {
+		t.Fatalf("Cannot create backend: %s", err)
+	}
+
+	doReq := func(op logical.Operation, path string, data map[string]interface{}) (*logical.Response, error) {
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: op,
+			Path:      path,
+			Data:      data,
+			Storage:   config.StorageView,
+		})
+		if err == nil && resp != nil && resp.IsError() {
+			err = resp.Error()
+		}
+		return resp, err
+	}
+
+	// The first issuer becomes the default issuer
+	resp, err := doReq(logical.UpdateOperation, "issuers/import", map[string]interface{}{
+		"private_key": testCAPrivateKey,
+		"issuer_name": "old",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldID := resp.Data["issuer_id"].(string)
+	if resp.Data["is_default"] != true || resp.Data["key_type"] != ssh.KeyAlgoRSA {
+		t.Fatalf("unexpected issuer: %#v", resp.Data)
+	}
+
+	resp, err = doReq(logical.ReadOperation, "config/ca", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldPublicKey := resp.Data["public_key"].(string)
+
+	if _, err := doReq(logical.UpdateOperation, "issuers/generate", map[string]interface{}{
+		"issuer_name": "old",
+	}); err == nil {
+		t.Fatal("expected issuer with a duplicate name to fail")
+	}
+	if _, err := doReq(logical.UpdateOperation, "issuers/generate", map[string]interface{}{
+		"issuer_name": "default",
+	}); err == nil {
+		t.Fatal("expected issuer with a reserved name to fail")
+	}
+	if _, err := doReq(logical.UpdateOperation, "issuers/import", map[string]interface{}{
+		"private_key": testCAPrivateKey,
+		"public_key":  testCAPublicKeyEd25519,
+	}); err == nil {
+		t.Fatal("expected import of mismatched keys to fail")
+	}
+
+	resp, err = doReq(logical.UpdateOperation, "issuers/generate", map[string]interface{}{
+		"key_type":    "ed25519",
+		"issuer_name": "new",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	newID := resp.Data["issuer_id"].(string)
+	newPublicKey := resp.Data["public_key"].(string)
+	if resp.Data["is_default"] != false {
+		t.Fatalf("expected second issuer not to be the default: %#v", resp.Data)
+	}
+
+	resp, err = doReq(logical.ListOperation, "issuers/", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keys := resp.Data["keys"].([]string); len(keys) != 2 {
+		t.Fatalf("unexpected issuers: %v", keys)
+	}
+	keyInfo := resp.Data["key_info"].(map[string]interface{})
+	if info := keyInfo[newID].(map[string]interface{}); info["issuer_name"] != "new" || info["is_default"] != false {
+		t.Fatalf("unexpected issuer info: %#v", info)
+	}
+
+	// Both keys are published, default first
+	resp, err = doReq(logical.ReadOperation, "public_key", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	published := string(resp.Data[logical.HTTPRawBody].([]byte))
+	if published != oldPublicKey+newPublicKey {
+		t.Fatalf("unexpected published keys:\n%s", published)
+	}
+
+	resp, err = doReq(logical.ReadOperation, "issuer/new/public_key", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(resp.Data[logical.HTTPRawBody].([]byte)) != newPublicKey {
+		t.Fatalf("unexpected issuer public key: %v", resp.Data)
+	}
+
+	// Roles sign with the issuer they refer to
+	if _, err := doReq(logical.UpdateOperation, "roles/missing", map[string]interface{}{
+		"key_type":                "ca",
+		"allow_user_certificates": true,
+		"issuer_ref":              "missing",
+	}); err == nil {
+		t.Fatal("expected role with an unknown issuer to fail")
+	}
+	for role, ref := range map[string]string{"default-issuer": "", "new-issuer": "new"} {
+		data := map[string]interface{}{
+			"key_type":                "ca",
+			"allowed_users":           "*",
+			"allow_user_certificates": true,
+		}
+		if ref != "" {
+			data["issuer_ref"] = ref
+		}
+		if _, err := doReq(logical.UpdateOperation, "roles/"+role, data); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	resp, err = doReq(logical.ReadOperation, "roles/default-issuer", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Data["issuer_ref"] != defaultRef {
+		t.Fatalf("unexpected issuer_ref: %v", resp.Data["issuer_ref"])
+	}
+
+	signedBy := func(role string) string {
+		t.Helper()
+		resp, err := doReq(logical.UpdateOperation, "sign/"+role, map[string]interface{}{
+			"public_key":       testCAPublicKeyEd25519,
+			"valid_principals": "toor",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		parsed, _, _, _, err := ssh.ParseAuthorizedKey([]byte(resp.Data["signed_key"].(string)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(ssh.MarshalAuthorizedKey(parsed.(*ssh.Certificate).SignatureKey))
+	}
+	if signer := signedBy("default-issuer"); !keysEqual(t, signer, oldPublicKey) {
+		t.Fatalf("expected certificate signed by the default issuer, got %v", signer)
+	}
+	if signer := signedBy("new-issuer"); !keysEqual(t, signer, newPublicKey) {
+		t.Fatalf("expected certificate signed by the role's issuer, got %v", signer)
+	}
+
+	// Changing the default issuer changes the issuer of roles using it
+	if _, err := doReq(logical.UpdateOperation, "config/issuers", map[string]interface{}{
+		"default": newID,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if signer := signedBy("default-issuer"); !keysEqual(t, signer, newPublicKey) {
+		t.Fatalf("expected certificate signed by the new default issuer, got %v", signer)
+	}
+
+	// Disabled issuers can not sign and are not published
+	if _, err := doReq(logical.UpdateOperation, "issuer/new", map[string]interface{}{
+		"disabled": true,
+	}); err == nil {
+		t.Fatal("expected disabling the default issuer to fail")
+	}
+	if _, err := doReq(logical.UpdateOperation, "issuer/old", map[string]interface{}{
+		"disabled": true,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	resp, err = doReq(logical.ReadOperation, "public_key", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if published := string(resp.Data[logical.HTTPRawBody].([]byte)); published != newPublicKey {
+		t.Fatalf("unexpected published keys:\n%s", published)
+	}
+	if _, err := doReq(logical.UpdateOperation, "roles/old-issuer", map[string]interface{}{
+		"key_type":                "ca",
+		"allowed_users":           "*",
+		"allow_user_certificates": true,
+		"issuer_ref":              oldID,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := doReq(logical.UpdateOperation, "sign/old-issuer", map[string]interface{}{
+		"public_key":       testCAPublicKeyEd25519,
+		"valid_principals": "toor",
+	}); err == nil {
+		t.Fatal("expected signing with a disabled issuer to fail")
+	}
+
+	// Deleting the CA configuration deletes the default issuer only
+	if _, err := doReq(logical.DeleteOperation, "config/ca", nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := doReq(logical.ReadOperation, "issuer/default", nil); err == nil {
+		t.Fatal("expected no default issuer after deleting the CA configuration")
+	}
+	if _, err := doReq(logical.ReadOperation, "issuer/old", nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSSH_IssuersMigration(t *testing.T) {
+	config := logical.TestBackendConfig()
+	config.StorageView = &logical.InmemStorage{}
+
+	b, err := Factory(context.Background(), config)
+	if err != nil {
+		t.Fatalf("Cannot create backend: %s", err)
+	}
+
+	// Store a CA keypair as done before multiple issuers were supported
+	for path, key := range map[string]string{
+		caPublicKeyStoragePath:  testCAPublicKey,
+		caPrivateKeyStoragePath: testCAPrivateKey,
+	} {
+		entry, err := logical.StorageEntryJSON(path, keyStorageEntry{Key: key})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := config.StorageView.Put(context.Background(), entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	resp, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ReadOperation,
+		Path:      "issuer/default",
+		Storage:   config.StorageView,
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: err: %v, resp: %v", err, resp)
+	}
+	if resp.Data["public_key"] != testCAPublicKey || resp.Data["is_default"] != true {
+		t.Fatalf("unexpected migrated issuer: %#v", resp.Data)
+	}
+
+	// The legacy keypair is kept for older versions of Vault
+	for _, path := range []string{caPublicKeyStoragePath, caPrivateKeyStoragePath} {
+		entry, err := config.StorageView.Get(context.Background(), path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if entry == nil {
+			t.Fatalf("expected %s to be kept after migration", path)
+		}
+	}
+
+	logEntry, err := config.StorageView.Get(context.Background(), legacyCAMigrationLogPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var migrationLog legacyCAMigrationLog
+	if logEntry == nil {
+		t.Fatal("expected the migration to be logged")
+	}
+	if err := logEntry.DecodeJSON(&migrationLog); err != nil {
+		t.Fatal(err)
+	}
+	if migrationLog.CreatedIssuer != resp.Data["issuer_id"] {
+		t.Fatalf("expected the migrated issuer %v to be logged, got %#v", resp.Data["issuer_id"], migrationLog)
+	}
+
+	// Once logged, the legacy keypair isn't migrated again, even after the
+	// issuer is deleted and the backend is recreated
+	if _, err := b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.DeleteOperation,
+		Path:      "config/ca",
+		Storage:   config.StorageView,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err = Factory(context.Background(), config)
+	if err != nil {
+		t.Fatalf("Cannot create backend: %s", err)
+	}
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Operation: logical.ListOperation,
+		Path:      "issuers",
+		Storage:   config.StorageView,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp != nil && len(resp.Data) > 0 && resp.Data["keys"] != nil {
+		t.Fatalf("expected no issuers, got %#v", resp.Data)
+	}
+}
+
+func keysEqual(t *testing.T, a, b string) bool {
+	t.Helper()
+
+	keyA, err := parsePublicSSHKey(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyB, err := parsePublicSSHKey(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return bytes.Equal(keyA.Marshal(), keyB.Marshal())
+}
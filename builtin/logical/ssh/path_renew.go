@@ -0,0 +1,289 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package ssh
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	// renewSignatureNamespace is the namespace hosts sign renewal requests
+	// in, as passed to ssh-keygen -Y sign -n.
+	renewSignatureNamespace = "vault-ssh-renew"
+
+	// renewTimestampSkew is how far the timestamp of a renewal request may
+	// be from the current time.
+	renewTimestampSkew = 5 * time.Minute
+)
+
+func pathRenew(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "renew",
+
+		DisplayAttrs: &framework.DisplayAttributes{
+			OperationPrefix: operationPrefixSSH,
+			OperationVerb:   "renew",
+			OperationSuffix: "host-certificate",
+		},
+
+		Fields: map[string]*framework.FieldSchema{
+			"certificate": {
+				Type:        framework.TypeString,
+				Description: `The currently valid host certificate to renew, in the OpenSSH authorized key format.`,
+			},
+			"timestamp": {
+				Type:        framework.TypeInt64,
+				Description: `The current time in seconds since the Unix epoch; must be within five minutes of the time on the Vault server.`,
+			},
+			"signature": {
+				Type: framework.TypeString,
+				Description: `Armored SSH signature, as produced by "ssh-keygen -Y sign -n vault-ssh-renew"
+with the private host key, of the message "<serial_number>:<timestamp>", where
+serial_number is the hexadecimal serial number of the certificate.`,
+			},
+			"ttl": {
+				Type: framework.TypeDurationSecond,
+				Description: `The requested Time To Live for the renewed certificate;
+sets the expiration date. If not specified
+the role default, backend default, or system
+default TTL is used, in that order. Cannot
+be later than the role max TTL.`,
+			},
+		},
+
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.UpdateOperation: &framework.PathOperation{
+				Callback: b.pathRenewWrite,
+			},
+		},
+
+		HelpSynopsis:    pathRenewHelpSyn,
+		HelpDescription: pathRenewHelpDesc,
+	}
+}
+
+func (b *backend) pathRenewWrite(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	certificate := data.Get("certificate").(string)
+	if certificate == "" {
+		return logical.ErrorResponse("missing certificate"), nil
+	}
+	signature := data.Get("signature").(string)
+	if signature == "" {
+		return logical.ErrorResponse("missing signature"), nil
+	}
+	timestamp := data.Get("timestamp").(int64)
+
+	parsed, err := parsePublicSSHKey(certificate)
+	if err != nil {
+		return logical.ErrorResponse(fmt.Sprintf("failed to parse certificate: %s", err)), nil
+	}
+	cert, ok := parsed.(*ssh.Certificate)
+	if !ok {
+		return logical.ErrorResponse("certificate is not an SSH certificate"), nil
+	}
+	if cert.CertType != ssh.HostCert {
+		return logical.ErrorResponse("only host certificates can be renewed"), nil
+	}
+
+	now := time.Now()
+	signedAt := time.Unix(timestamp, 0)
+	if signedAt.Before(now.Add(-renewTimestampSkew)) || signedAt.After(now.Add(renewTimestampSkew)) {
+		return logical.ErrorResponse("timestamp is not within five minutes of the current time"), nil
+	}
+
+	// The signature proves possession of the private host key
+	message := fmt.Sprintf("%s:%d", formatSerialNumber(cert.Serial), timestamp)
+	if err := verifySSHSignature(cert.Key, renewSignatureNamespace, []byte(message), signature); err != nil {
+		return logical.ErrorResponse(fmt.Sprintf("failed to verify signature: %s", err)), nil
+	}
+
+	// The certificate itself must still be valid
+	supportedOptions := make([]string, 0, len(cert.CriticalOptions))
+	for option := range cert.CriticalOptions {
+		supportedOptions = append(supportedOptions, option)
+	}
+	checker := &ssh.CertChecker{
+		SupportedCriticalOptions: supportedOptions,
+		Clock:                    func() time.Time { return now },
+	}
+	principal := ""
+	if len(cert.ValidPrincipals) > 0 {
+		principal = cert.ValidPrincipals[0]
+	}
+	if err := checker.CheckCert(principal, cert); err != nil {
+		return logical.ErrorResponse(fmt.Sprintf("certificate is not valid: %s", err)), nil
+	}
+
+	if err := b.migrateLegacyCA(ctx, req.Storage); err != nil {
+		return nil, err
+	}
+
+	// Only certificates this backend signed and still knows about can be
+	// renewed
+	record, err := fetchCertificate(ctx, req.Storage, certsStoragePrefix, formatSerialNumber(cert.Serial))
+	if err != nil {
+		return nil, err
+	}
+	if record == nil {
+		return logical.ErrorResponse("certificate was not signed by this backend"), nil
+	}
+	recordedKey, err := parsePublicSSHKey(record.PublicKey)
+	if err != nil || !bytes.Equal(recordedKey.Marshal(), cert.Key.Marshal()) {
+		return logical.ErrorResponse("certificate was not signed by this backend"), nil
+	}
+	issuer, err := fetchIssuerByID(ctx, req.Storage, record.IssuerID)
+	if err != nil {
+		return nil, err
+	}
+	if issuer == nil {
+		return logical.ErrorResponse("issuer of certificate no longer exists"), nil
+	}
+	issuerKey, err := parsePublicSSHKey(issuer.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse public key of issuer %s: %w", issuer.ID, err)
+	}
+	if !bytes.Equal(issuerKey.Marshal(), cert.SignatureKey.Marshal()) {
+		return logical.ErrorResponse("certificate was not signed by this backend"), nil
+	}
+	if record.Revoked() {
+		return logical.ErrorResponse("certificate has been revoked"), nil
+	}
+	if record.Role == "" {
+		return logical.ErrorResponse("certificate was signed before host renewal was supported and cannot be renewed"), nil
+	}
+
+	role, err := b.getRole(ctx, req.Storage, record.Role)
+	if err != nil {
+		return nil, err
+	}
+	if role == nil {
+		return logical.ErrorResponse(fmt.Sprintf("role %q of certificate no longer exists", record.Role)), nil
+	}
+	if role.KeyType != KeyTypeCA || !role.AllowHostRenewal {
+		return logical.ErrorResponse(fmt.Sprintf("role %q does not allow host certificate renewal", record.Role)), nil
+	}
+
+	if err := b.validateSignedKeyRequirements(cert.Key, role); err != nil {
+		return logical.ErrorResponse(fmt.Sprintf("host key failed to meet the key requirements: %s", err)), nil
+	}
+
+	// Sign the host key again with the same details, which the role's
+	// current configuration validates as for any other signing request.
+	raw := map[string]interface{}{
+		"role":             record.Role,
+		"cert_type":        "host",
+		"valid_principals": strings.Join(cert.ValidPrincipals, ","),
+		"critical_options": stringMapToInterface(cert.CriticalOptions),
+		"extensions":       stringMapToInterface(cert.Extensions),
+	}
+	if role.AllowUserKeyIDs {
+		raw["key_id"] = cert.KeyId
+	}
+	if ttl, ok := data.GetOk("ttl"); ok {
+		raw["ttl"] = ttl
+	}
+	signData := &framework.FieldData{
+		Raw:    raw,
+		Schema: pathSign(b).Fields,
+	}
+
+	return b.pathSignIssueCertificateHelper(ctx, req, signData, role, cert.Key)
+}
+
+func stringMapToInterface(m map[string]string) map[string]interface{} {
+	result := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		result[k] = v
+	}
+	return result
+}
+
+// verifySSHSignature verifies an armored signature in the format produced by
+// ssh-keygen -Y sign, as described in PROTOCOL.sshsig of the OpenSSH
+// sources.
+func verifySSHSignature(publicKey ssh.PublicKey, namespace string, message []byte, armored string) error {
+	armored = strings.TrimSpace(armored)
+	if !strings.HasPrefix(armored, "-----BEGIN SSH SIGNATURE-----") || !strings.HasSuffix(armored, "-----END SSH SIGNATURE-----") {
+		return errors.New("signature is not an armored SSH signature")
+	}
+	armored = strings.TrimPrefix(armored, "-----BEGIN SSH SIGNATURE-----")
+	armored = strings.TrimSuffix(armored, "-----END SSH SIGNATURE-----")
+	blob, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(armored), ""))
+	if err != nil {
+		return fmt.Errorf("failed to decode signature: %w", err)
+	}
+
+	if !bytes.HasPrefix(blob, []byte("SSHSIG")) {
+		return errors.New("signature is not an SSH signature")
+	}
+	var sig struct {
+		Version       uint32
+		PublicKey     []byte
+		Namespace     string
+		Reserved      []byte
+		HashAlgorithm string
+		Signature     []byte
+	}
+	if err := ssh.Unmarshal(blob[len("SSHSIG"):], &sig); err != nil {
+		return fmt.Errorf("failed to parse signature: %w", err)
+	}
+	if sig.Version != 1 {
+		return fmt.Errorf("unsupported signature version %d", sig.Version)
+	}
+	if !bytes.Equal(sig.PublicKey, publicKey.Marshal()) {
+		return errors.New("signature was not made with the certified key")
+	}
+	if sig.Namespace != namespace {
+		return fmt.Errorf("signature namespace must be %q", namespace)
+	}
+
+	var hash []byte
+	switch sig.HashAlgorithm {
+	case "sha256":
+		sum := sha256.Sum256(message)
+		hash = sum[:]
+	case "sha512":
+		sum := sha512.Sum512(message)
+		hash = sum[:]
+	default:
+		return fmt.Errorf("unsupported signature hash algorithm %q", sig.HashAlgorithm)
+	}
+
+	var signature ssh.Signature
+	if err := ssh.Unmarshal(sig.Signature, &signature); err != nil {
+		return fmt.Errorf("failed to parse signature: %w", err)
+	}
+
+	signed := append([]byte("SSHSIG"), ssh.Marshal(struct {
+		Namespace     string
+		Reserved      []byte
+		HashAlgorithm string
+		Hash          []byte
+	}{namespace, sig.Reserved, sig.HashAlgorithm, hash})...)
+
+	return publicKey.Verify(signed, &signature)
+}
+
+const pathRenewHelpSyn = `Renew a host certificate, authenticating with the host key.`
+
+const pathRenewHelpDesc = `
+This endpoint allows hosts to renew a currently valid host certificate without
+a Vault token. The request is authenticated by a signature, made with the
+private host key, over the serial number of the certificate and the current
+time. The renewed certificate keeps the key ID, principals, critical options,
+and extensions of the current one, as long as the role which signed it, which
+must have 'allow_host_renewal' set, still permits them.
+`
@@ -0,0 +1,188 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package ssh
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha512"
+	"encoding/base64"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+	"golang.org/x/crypto/ssh"
+)
+
+func TestSSH_RenewHostCertificate(t *testing.T) {
+	config := logical.TestBackendConfig()
+	config.StorageView = &logical.InmemStorage{}
+
+	b, err := Factory(context.Background(), config)
+	if err != nil {
+		t.Fatalf("Cannot create backend: %s", err)
+	}
+
+	doReq := func(op logical.Operation, path string, data map[string]interface{}) (*logical.Response, error) {
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: op,
+			Path:      path,
+			Data:      data,
+			Storage:   config.StorageView,
+		})
+		if err == nil && resp != nil && resp.IsError() {
+			err = resp.Error()
+		}
+		return resp, err
+	}
+
+	if _, err := doReq(logical.UpdateOperation, "config/ca", map[string]interface{}{
+		"key_type": "ed25519",
+	}); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"renewable", "not-renewable"} {
+		if _, err := doReq(logical.UpdateOperation, "roles/"+name, map[string]interface{}{
+			"key_type":                "ca",
+			"allowed_domains":         "example.com",
+			"allow_subdomains":        true,
+			"allow_host_certificates": true,
+			"allow_host_renewal":      name == "renewable",
+			"ttl":                     "1h",
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, hostKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hostSigner, err := ssh.NewSignerFromKey(hostKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, otherKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherSigner, err := ssh.NewSignerFromKey(otherKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sign := func(role string) *ssh.Certificate {
+		t.Helper()
+		resp, err := doReq(logical.UpdateOperation, "sign/"+role, map[string]interface{}{
+			"public_key":       string(ssh.MarshalAuthorizedKey(hostSigner.PublicKey())),
+			"cert_type":        "host",
+			"valid_principals": "host.example.com",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return parseCertificate(t, resp.Data["signed_key"].(string))
+	}
+
+	renew := func(cert *ssh.Certificate, signer ssh.Signer, timestamp time.Time) (*logical.Response, error) {
+		message := fmt.Sprintf("%x:%d", cert.Serial, timestamp.Unix())
+		return doReq(logical.UpdateOperation, "renew", map[string]interface{}{
+			"certificate": string(ssh.MarshalAuthorizedKey(cert)),
+			"timestamp":   timestamp.Unix(),
+			"signature":   testSSHSignature(t, signer, renewSignatureNamespace, []byte(message)),
+		})
+	}
+
+	cert := sign("renewable")
+
+	if _, err := renew(cert, otherSigner, time.Now()); err == nil {
+		t.Fatal("expected renewal signed with another key to fail")
+	}
+	if _, err := renew(cert, hostSigner, time.Now().Add(-time.Hour)); err == nil {
+		t.Fatal("expected renewal with a stale timestamp to fail")
+	}
+	if _, err := renew(sign("not-renewable"), hostSigner, time.Now()); err == nil {
+		t.Fatal("expected renewal of a certificate of a role without allow_host_renewal to fail")
+	}
+
+	resp, err := renew(cert, hostSigner, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	renewed := parseCertificate(t, resp.Data["signed_key"].(string))
+	if renewed.Serial == cert.Serial || resp.Data["serial_number"] != formatSerialNumber(renewed.Serial) {
+		t.Fatalf("expected renewed certificate to have a new serial number, got %v", resp.Data["serial_number"])
+	}
+	if renewed.CertType != ssh.HostCert || !reflect.DeepEqual(renewed.ValidPrincipals, cert.ValidPrincipals) {
+		t.Fatalf("unexpected renewed certificate: %#v", renewed)
+	}
+	if string(renewed.Key.Marshal()) != string(hostSigner.PublicKey().Marshal()) {
+		t.Fatal("expected renewed certificate to certify the host key")
+	}
+
+	// The renewed certificate can be renewed in turn, but a revoked one can't
+	if _, err := doReq(logical.UpdateOperation, "revoke", map[string]interface{}{
+		"serial_number": formatSerialNumber(renewed.Serial),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := renew(renewed, hostSigner, time.Now()); err == nil {
+		t.Fatal("expected renewal of a revoked certificate to fail")
+	}
+}
+
+func parseCertificate(t *testing.T, signedKey string) *ssh.Certificate {
+	t.Helper()
+
+	key, err := parsePublicSSHKey(signedKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, ok := key.(*ssh.Certificate)
+	if !ok {
+		t.Fatalf("expected a certificate, got %T", key)
+	}
+	return cert
+}
+
+// testSSHSignature signs the message the way ssh-keygen -Y sign does.
+func testSSHSignature(t *testing.T, signer ssh.Signer, namespace string, message []byte) string {
+	t.Helper()
+
+	hash := sha512.Sum512(message)
+	signed := append([]byte("SSHSIG"), ssh.Marshal(struct {
+		Namespace     string
+		Reserved      []byte
+		HashAlgorithm string
+		Hash          []byte
+	}{namespace, nil, "sha512", hash[:]})...)
+
+	signature, err := signer.Sign(rand.Reader, signed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	blob := append([]byte("SSHSIG"), ssh.Marshal(struct {
+		Version       uint32
+		PublicKey     []byte
+		Namespace     string
+		Reserved      []byte
+		HashAlgorithm string
+		Signature     []byte
+	}{1, signer.PublicKey().Marshal(), namespace, nil, "sha512", ssh.Marshal(signature)})...)
+
+	encoded := base64.StdEncoding.EncodeToString(blob)
+	var armored strings.Builder
+	armored.WriteString("-----BEGIN SSH SIGNATURE-----\n")
+	for len(encoded) > 70 {
+		armored.WriteString(encoded[:70] + "\n")
+		encoded = encoded[70:]
+	}
+	armored.WriteString(encoded + "\n-----END SSH SIGNATURE-----\n")
+	return armored.String()
+}
@@ -0,0 +1,160 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package ssh
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+func pathRevoke(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "revoke",
+
+		DisplayAttrs: &framework.DisplayAttributes{
+			OperationPrefix: operationPrefixSSH,
+			OperationVerb:   "revoke",
+			OperationSuffix: "certificates",
+		},
+
+		Fields: map[string]*framework.FieldSchema{
+			"serial_number": {
+				Type:        framework.TypeString,
+				Description: `Serial number of the certificate to revoke, in the hexadecimal format returned when it was signed.`,
+			},
+			"key_id": {
+				Type:        framework.TypeString,
+				Description: `Key ID of the certificates to revoke; all unexpired certificates with this key ID are revoked.`,
+			},
+			"public_key": {
+				Type:        framework.TypeString,
+				Description: `SSH public key of the certificates to revoke; all unexpired certificates of this key are revoked.`,
+			},
+		},
+
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.UpdateOperation: &framework.PathOperation{
+				Callback: b.pathRevokeWrite,
+			},
+		},
+
+		HelpSynopsis:    pathRevokeHelpSyn,
+		HelpDescription: pathRevokeHelpDesc,
+	}
+}
+
+func (b *backend) pathRevokeWrite(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	serialNumber := data.Get("serial_number").(string)
+	keyID := data.Get("key_id").(string)
+	publicKey := data.Get("public_key").(string)
+
+	set := 0
+	for _, value := range []string{serialNumber, keyID, publicKey} {
+		if value != "" {
+			set++
+		}
+	}
+	if set != 1 {
+		return logical.ErrorResponse("exactly one of serial_number, key_id, or public_key must be set"), nil
+	}
+
+	b.revokeLock.Lock()
+	defer b.revokeLock.Unlock()
+
+	now := time.Now()
+
+	var certs []*issuedCertificate
+	switch {
+	case serialNumber != "":
+		serial, err := parseSerialNumber(serialNumber)
+		if err != nil {
+			return logical.ErrorResponse(err.Error()), nil
+		}
+		cert, err := fetchCertificate(ctx, req.Storage, certsStoragePrefix, formatSerialNumber(serial))
+		if err != nil {
+			return nil, err
+		}
+		if cert == nil {
+			return logical.ErrorResponse(fmt.Sprintf("certificate with serial number %s not found", serialNumber)), nil
+		}
+		if now.After(cert.Expiration) {
+			resp := &logical.Response{}
+			resp.AddWarning(fmt.Sprintf("certificate with serial number %s has already expired; ignoring revocation request", serialNumber))
+			return resp, nil
+		}
+		certs = append(certs, cert)
+	default:
+		var keyBytes []byte
+		if publicKey != "" {
+			parsed, err := parsePublicSSHKey(publicKey)
+			if err != nil {
+				return logical.ErrorResponse(fmt.Sprintf("failed to parse public_key as SSH key: %s", err)), nil
+			}
+			keyBytes = parsed.Marshal()
+		}
+
+		stored, err := listCertificates(ctx, req.Storage, certsStoragePrefix)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list certificates: %w", err)
+		}
+		for _, cert := range stored {
+			if now.After(cert.Expiration) {
+				continue
+			}
+			if keyID != "" && cert.KeyID != keyID {
+				continue
+			}
+			if keyBytes != nil {
+				parsed, err := parsePublicSSHKey(cert.PublicKey)
+				if err != nil || !bytes.Equal(parsed.Marshal(), keyBytes) {
+					continue
+				}
+			}
+			certs = append(certs, cert)
+		}
+		if len(certs) == 0 {
+			return logical.ErrorResponse("no unexpired certificates found to revoke"), nil
+		}
+	}
+
+	revokedSerials := make([]string, 0, len(certs))
+	for _, cert := range certs {
+		revokedSerials = append(revokedSerials, cert.SerialNumber)
+		if cert.Revoked() {
+			continue
+		}
+
+		cert.RevocationTime = now
+		if err := writeCertificate(ctx, req.Storage, revokedStoragePrefix, cert); err != nil {
+			return nil, fmt.Errorf("failed to store revoked certificate %s: %w", cert.SerialNumber, err)
+		}
+		if err := writeCertificate(ctx, req.Storage, certsStoragePrefix, cert); err != nil {
+			return nil, fmt.Errorf("failed to update certificate %s: %w", cert.SerialNumber, err)
+		}
+	}
+
+	if err := b.rebuildKRL(ctx, req.Storage); err != nil {
+		return nil, fmt.Errorf("failed to rebuild KRL: %w", err)
+	}
+
+	return &logical.Response{
+		Data: map[string]interface{}{
+			"serial_numbers":  revokedSerials,
+			"revocation_time": now.Unix(),
+		},
+	}, nil
+}
+
+const pathRevokeHelpSyn = `Revoke certificates signed by this backend.`
+
+const pathRevokeHelpDesc = `
+This endpoint revokes certificates by their serial number, by their key ID, or
+by the public key they certify. Revoked certificates are listed in the key
+revocation list published at the krl endpoint until they expire.
+`
@@ -0,0 +1,238 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package ssh
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+func TestSSH_RevokeKRLTidy(t *testing.T) {
+	config := logical.TestBackendConfig()
+	config.StorageView = &logical.InmemStorage{}
+
+	b, err := Factory(context.Background(), config)
+	if err != nil {
+		t.Fatalf("Cannot create backend: %s", err)
+	}
+
+	doReq := func(op logical.Operation, path string, data map[string]interface{}) (*logical.Response, error) {
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Operation: op,
+			Path:      path,
+			Data:      data,
+			Storage:   config.StorageView,
+		})
+		if err == nil && resp != nil && resp.IsError() {
+			err = resp.Error()
+		}
+		return resp, err
+	}
+
+	if _, err := doReq(logical.UpdateOperation, "config/ca", map[string]interface{}{
+		"key_type": "ed25519",
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := doReq(logical.UpdateOperation, "roles/test", map[string]interface{}{
+		"key_type":                "ca",
+		"allowed_users":           "*",
+		"allow_user_certificates": true,
+		"allow_user_key_ids":      true,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	sign := func(publicKey, keyID string) uint64 {
+		t.Helper()
+		resp, err := doReq(logical.UpdateOperation, "sign/test", map[string]interface{}{
+			"public_key":       publicKey,
+			"valid_principals": "toor",
+			"key_id":           keyID,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		serial, err := parseSerialNumber(resp.Data["serial_number"].(string))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return serial
+	}
+
+	bySerial := sign(testCAPublicKeyEd25519, "by-serial")
+	byKeyID := sign(testCAPublicKeyEd25519, "by-key-id")
+	byPublicKey := sign(publicKey3072, "by-public-key")
+	notRevoked := sign(testCAPublicKeyEd25519, "not-revoked")
+
+	// Nothing is revoked yet
+	if serials := fetchKRLSerials(t, doReq); len(serials) != 0 {
+		t.Fatalf("expected empty KRL, got %v", serials)
+	}
+
+	if _, err := doReq(logical.UpdateOperation, "revoke", map[string]interface{}{
+		"serial_number": formatSerialNumber(bySerial),
+		"key_id":        "by-key-id",
+	}); err == nil {
+		t.Fatal("expected revocation by several criteria to fail")
+	}
+	if _, err := doReq(logical.UpdateOperation, "revoke", map[string]interface{}{
+		"serial_number": "1234",
+	}); err == nil {
+		t.Fatal("expected revocation of an unknown serial number to fail")
+	}
+
+	for _, data := range []map[string]interface{}{
+		{"serial_number": formatSerialNumber(bySerial)},
+		{"key_id": "by-key-id"},
+		{"public_key": publicKey3072},
+	} {
+		resp, err := doReq(logical.UpdateOperation, "revoke", data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if serials := resp.Data["serial_numbers"].([]string); len(serials) != 1 {
+			t.Fatalf("expected a single certificate to be revoked by %v, got %v", data, serials)
+		}
+	}
+
+	expected := []uint64{bySerial, byKeyID, byPublicKey}
+	sort.Slice(expected, func(i, j int) bool { return expected[i] < expected[j] })
+	serials := fetchKRLSerials(t, doReq)
+	if len(serials) != len(expected) {
+		t.Fatalf("expected KRL serials %v, got %v", expected, serials)
+	}
+	for i := range expected {
+		if serials[i] != expected[i] || serials[i] == notRevoked {
+			t.Fatalf("expected KRL serials %v, got %v", expected, serials)
+		}
+	}
+
+	// Expire one of the revoked certificates, which tidy then removes from
+	// storage and from the KRL
+	cert, err := fetchCertificate(context.Background(), config.StorageView, revokedStoragePrefix, formatSerialNumber(bySerial))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert.Expiration = time.Now().Add(-time.Hour)
+	for _, prefix := range []string{certsStoragePrefix, revokedStoragePrefix} {
+		if err := writeCertificate(context.Background(), config.StorageView, prefix, cert); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	resp, err := doReq(logical.UpdateOperation, "tidy", map[string]interface{}{
+		"tidy_cert_store":    true,
+		"tidy_revoked_certs": true,
+		"safety_buffer":      "1s",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Data[logical.HTTPStatusCode] != 202 {
+		t.Fatalf("expected tidy to be accepted, got %#v", resp)
+	}
+
+	var status *logical.Response
+	for i := 0; i < 50; i++ {
+		status, err = doReq(logical.ReadOperation, "tidy-status", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if status.Data["state"] != "Running" {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	if status.Data["state"] != "Finished" || status.Data["cert_store_deleted_count"] != uint(1) || status.Data["revoked_cert_deleted_count"] != uint(1) {
+		t.Fatalf("unexpected tidy status: %#v", status.Data)
+	}
+
+	if serials := fetchKRLSerials(t, doReq); len(serials) != 2 {
+		t.Fatalf("expected tidied certificate to be removed from the KRL, got %v", serials)
+	}
+	if _, err := doReq(logical.UpdateOperation, "revoke", map[string]interface{}{
+		"serial_number": formatSerialNumber(bySerial),
+	}); err == nil {
+		t.Fatal("expected revocation of a tidied certificate to fail")
+	}
+}
+
+// fetchKRLSerials fetches the KRL and returns the revoked serial numbers it
+// lists.
+func fetchKRLSerials(t *testing.T, doReq func(logical.Operation, string, map[string]interface{}) (*logical.Response, error)) []uint64 {
+	t.Helper()
+
+	resp, err := doReq(logical.ReadOperation, "krl", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	krl := bytes.NewReader(resp.Data[logical.HTTPRawBody].([]byte))
+
+	readUint32 := func(r *bytes.Reader) uint32 {
+		var v uint32
+		if err := binary.Read(r, binary.BigEndian, &v); err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+	readUint64 := func(r *bytes.Reader) uint64 {
+		var v uint64
+		if err := binary.Read(r, binary.BigEndian, &v); err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+	readString := func(r *bytes.Reader) []byte {
+		data := make([]byte, readUint32(r))
+		if _, err := r.Read(data); err != nil && len(data) > 0 {
+			t.Fatal(err)
+		}
+		return data
+	}
+
+	if magic := readUint64(krl); magic != krlMagic {
+		t.Fatalf("unexpected KRL magic %x", magic)
+	}
+	if version := readUint32(krl); version != krlFormatVersion {
+		t.Fatalf("unexpected KRL format version %d", version)
+	}
+	// krl_version, generated_date, flags, reserved, comment
+	readUint64(krl)
+	readUint64(krl)
+	readUint64(krl)
+	readString(krl)
+	readString(krl)
+
+	var serials []uint64
+	for krl.Len() > 0 {
+		sectionType, _ := krl.ReadByte()
+		section := bytes.NewReader(readString(krl))
+		if sectionType != krlSectionCertificates {
+			t.Fatalf("unexpected KRL section type %d", sectionType)
+		}
+
+		// ca_key, reserved
+		readString(section)
+		readString(section)
+		for section.Len() > 0 {
+			certSectionType, _ := section.ReadByte()
+			certSection := bytes.NewReader(readString(section))
+			if certSectionType != krlSectionCertSerials {
+				t.Fatalf("unexpected KRL certificate section type %d", certSectionType)
+			}
+			for certSection.Len() > 0 {
+				serials = append(serials, readUint64(certSection))
+			}
+		}
+	}
+
+	return serials
+}
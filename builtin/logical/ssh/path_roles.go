@@ -31,7 +31,7 @@ const (
 	// Present version of the sshRole struct; when adding a new field or are
 	// needing to perform a migration, increment this struct and read the note
 	// in checkUpgrade(...).
-	roleEntryVersion = 3
+	roleEntryVersion = 4
 )
 
 // Structure that represents a role in SSH backend. This is a common role structure
@@ -66,6 +66,8 @@ type sshRole struct {
 	AlgorithmSigner            string            `mapstructure:"algorithm_signer" json:"algorithm_signer"`
 	Version                    int               `mapstructure:"role_version" json:"role_version"`
 	NotBeforeDuration          time.Duration     `mapstructure:"not_before_duration" json:"not_before_duration"`
+	IssuerRef                  string            `mapstructure:"issuer_ref" json:"issuer_ref"`
+	AllowHostRenewal           bool              `mapstructure:"allow_host_renewal" json:"allow_host_renewal"`
 }
 
 func pathListRoles(b *backend) *framework.Path {
@@ -292,6 +294,16 @@ func pathRoles(b *backend) *framework.Path {
 				`,
 				Default: false,
 			},
+			"allow_host_renewal": {
+				Type: framework.TypeBool,
+				Description: `
+				[Not applicable for OTP type] [Optional for CA type]
+				If set, hosts holding a valid host certificate signed with this role
+				can renew it through the renew endpoint, authenticating with their
+				host key instead of a Vault token.
+				`,
+				Default: false,
+			},
 			"allow_bare_domains": {
 				Type: framework.TypeBool,
 				Description: `
@@ -363,6 +375,19 @@ func pathRoles(b *backend) *framework.Path {
 					Value: 30,
 				},
 			},
+			issuerRefParam: {
+				Type:    framework.TypeString,
+				Default: defaultRef,
+				Description: `
+				[Not applicable for OTP type] [Optional for CA type]
+				Reference to the issuer used to sign certificates; either "default"
+				for the default issuer of the mount, an identifier of an issuer, or
+				the name assigned to an issuer.`,
+				DisplayAttrs: &framework.DisplayAttributes{
+					Name:  "Issuer",
+					Value: defaultRef,
+				},
+			},
 		},
 
 		Callbacks: map[logical.Operation]framework.OperationFunc{
@@ -460,6 +485,16 @@ func (b *backend) pathRoleWrite(ctx context.Context, req *logical.Request, d *fr
 		if errorResponse != nil {
 			return errorResponse, nil
 		}
+
+		if role.IssuerRef != defaultRef {
+			issuer, err := fetchIssuerByRef(ctx, req.Storage, role.IssuerRef)
+			if err != nil {
+				return nil, err
+			}
+			if issuer == nil {
+				return logical.ErrorResponse(fmt.Sprintf("issuer %q not found", role.IssuerRef)), nil
+			}
+		}
 		roleEntry = *role
 	} else {
 		return logical.ErrorResponse("invalid key type"), nil
@@ -484,6 +519,7 @@ func (b *backend) createCARole(allowedUsers, defaultUser, signer string, data *f
 		AllowedExtensions:         data.Get("allowed_extensions").(string),
 		AllowUserCertificates:     data.Get("allow_user_certificates").(bool),
 		AllowHostCertificates:     data.Get("allow_host_certificates").(bool),
+		AllowHostRenewal:          data.Get("allow_host_renewal").(bool),
 		AllowedUsers:              allowedUsers,
 		AllowedUsersTemplate:      data.Get("allowed_users_template").(bool),
 		AllowedDomains:            data.Get("allowed_domains").(string),
@@ -499,12 +535,21 @@ func (b *backend) createCARole(allowedUsers, defaultUser, signer string, data *f
 		AlgorithmSigner:           signer,
 		Version:                   roleEntryVersion,
 		NotBeforeDuration:         time.Duration(data.Get("not_before_duration").(int)) * time.Second,
+		IssuerRef:                 data.Get(issuerRefParam).(string),
+	}
+
+	if role.IssuerRef == "" {
+		role.IssuerRef = defaultRef
 	}
 
 	if !role.AllowUserCertificates && !role.AllowHostCertificates {
 		return nil, logical.ErrorResponse("Either 'allow_user_certificates' or 'allow_host_certificates' must be set to 'true'")
 	}
 
+	if role.AllowHostRenewal && !role.AllowHostCertificates {
+		return nil, logical.ErrorResponse("'allow_host_renewal' requires 'allow_host_certificates' to be set to 'true'")
+	}
+
 	defaultCriticalOptions := convertMapToStringValue(data.Get("default_critical_options").(map[string]interface{}))
 	defaultExtensions := convertMapToStringValue(data.Get("default_extensions").(map[string]interface{}))
 	allowedUserKeyLengths, err := convertMapToIntSlice(data.Get("allowed_user_key_lengths").(map[string]interface{}))
@@ -587,17 +632,21 @@ func (b *backend) checkUpgrade(ctx context.Context, s logical.Storage, n string,
 		// signing key type as we want to make ssh-rsa an explicitly notated
 		// algorithm choice.
 		var publicKey ssh.PublicKey
-		publicKeyEntry, err := caKey(ctx, s, caPublicKey)
+		var issuer *sshIssuer
+		err := b.migrateLegacyCA(ctx, s)
+		if err == nil {
+			issuer, err = fetchIssuerByRef(ctx, s, defaultRef)
+		}
 		if err != nil {
 			b.Logger().Debug(fmt.Sprintf("failed to load public key entry while attempting to migrate: %v", err))
 			goto SKIPVERSION2
 		}
-		if publicKeyEntry == nil || publicKeyEntry.Key == "" {
+		if issuer == nil || issuer.PublicKey == "" {
 			b.Logger().Debug(fmt.Sprintf("got empty public key entry while attempting to migrate"))
 			goto SKIPVERSION2
 		}
 
-		publicKey, err = parsePublicSSHKey(publicKeyEntry.Key)
+		publicKey, err = parsePublicSSHKey(issuer.PublicKey)
 		if err == nil {
 			// Move an empty signing algorithm to an explicit ssh-rsa (SHA-1)
 			// if this key is of type RSA. This isn't a secure default but
@@ -622,6 +671,16 @@ func (b *backend) checkUpgrade(ctx context.Context, s logical.Storage, n string,
 		result.Version = 3
 	}
 
+	// Role version 4 introduced issuers; roles which existed before signed
+	// with the only CA key, which has become the default issuer.
+	if result.Version < 4 {
+		if result.KeyType == KeyTypeCA && result.IssuerRef == "" {
+			result.IssuerRef = defaultRef
+		}
+		result.Version = 4
+		modified = true
+	}
+
 	// Add new migrations just before here.
 	//
 	// Condition copied from PKI builtin.
@@ -680,6 +739,7 @@ func (b *backend) parseRole(role *sshRole) (map[string]interface{}, error) {
 			"allowed_extensions":          role.AllowedExtensions,
 			"allow_user_certificates":     role.AllowUserCertificates,
 			"allow_host_certificates":     role.AllowHostCertificates,
+			"allow_host_renewal":          role.AllowHostRenewal,
 			"allow_bare_domains":          role.AllowBareDomains,
 			"allow_subdomains":            role.AllowSubdomains,
 			"allow_user_key_ids":          role.AllowUserKeyIDs,
@@ -691,6 +751,7 @@ func (b *backend) parseRole(role *sshRole) (map[string]interface{}, error) {
 			"allowed_user_key_lengths":    role.AllowedUserKeyTypesLengths,
 			"algorithm_signer":            role.AlgorithmSigner,
 			"not_before_duration":         int64(role.NotBeforeDuration.Seconds()),
+			"issuer_ref":                  role.IssuerRef,
 		}
 	case KeyTypeDynamic:
 		return nil, fmt.Errorf("dynamic key type roles are no longer supported")
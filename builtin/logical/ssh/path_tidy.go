@@ -0,0 +1,317 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package ssh
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+const defaultTidySafetyBuffer = 72 * time.Hour
+
+type tidyStatusState int
+
+const (
+	tidyStatusInactive tidyStatusState = iota
+	tidyStatusStarted
+	tidyStatusFinished
+	tidyStatusError
+)
+
+func (s tidyStatusState) String() string {
+	switch s {
+	case tidyStatusStarted:
+		return "Running"
+	case tidyStatusFinished:
+		return "Finished"
+	case tidyStatusError:
+		return "Error"
+	default:
+		return "Inactive"
+	}
+}
+
+type tidyStatus struct {
+	// Parameters used to initiate the operation
+	safetyBuffer     int
+	tidyCertStore    bool
+	tidyRevokedCerts bool
+
+	// Status
+	state                   tidyStatusState
+	err                     error
+	timeStarted             time.Time
+	timeFinished            time.Time
+	certStoreDeletedCount   uint
+	revokedCertDeletedCount uint
+}
+
+func pathTidy(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "tidy$",
+
+		DisplayAttrs: &framework.DisplayAttributes{
+			OperationPrefix: operationPrefixSSH,
+			OperationVerb:   "tidy",
+		},
+
+		Fields: map[string]*framework.FieldSchema{
+			"tidy_cert_store": {
+				Type: framework.TypeBool,
+				Description: `Set to true to enable tidying up
+the records of issued certificates`,
+			},
+			"tidy_revoked_certs": {
+				Type: framework.TypeBool,
+				Description: `Set to true to expire all revoked
+certificates, removing them from the key
+revocation list`,
+			},
+			"safety_buffer": {
+				Type: framework.TypeDurationSecond,
+				Description: `The amount of extra time that must have passed
+beyond certificate expiration before it is removed
+from the backend storage and/or key revocation list.
+Defaults to 72 hours.`,
+				Default: int(defaultTidySafetyBuffer / time.Second),
+			},
+		},
+
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.UpdateOperation: &framework.PathOperation{
+				Callback:                  b.pathTidyWrite,
+				ForwardPerformanceStandby: true,
+			},
+		},
+
+		HelpSynopsis:    pathTidyHelpSyn,
+		HelpDescription: pathTidyHelpDesc,
+	}
+}
+
+func pathTidyStatus(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "tidy-status$",
+
+		DisplayAttrs: &framework.DisplayAttributes{
+			OperationPrefix: operationPrefixSSH,
+			OperationVerb:   "tidy",
+			OperationSuffix: "status",
+		},
+
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.ReadOperation: &framework.PathOperation{
+				Callback:                  b.pathTidyStatusRead,
+				ForwardPerformanceStandby: true,
+			},
+		},
+
+		HelpSynopsis:    pathTidyStatusHelpSyn,
+		HelpDescription: pathTidyStatusHelpDesc,
+	}
+}
+
+func (b *backend) pathTidyWrite(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	safetyBuffer := d.Get("safety_buffer").(int)
+	tidyCertStore := d.Get("tidy_cert_store").(bool)
+	tidyRevokedCerts := d.Get("tidy_revoked_certs").(bool)
+
+	if safetyBuffer < 1 {
+		return logical.ErrorResponse("safety_buffer must be greater than zero"), nil
+	}
+
+	if !tidyCertStore && !tidyRevokedCerts {
+		resp := &logical.Response{}
+		resp.AddWarning("Manual tidy requested but no tidy operations were set. Enable at least one tidy operation to be run (tidy_cert_store, tidy_revoked_certs).")
+		return resp, nil
+	}
+
+	if !b.tidyCASGuard.CompareAndSwap(false, true) {
+		resp := &logical.Response{}
+		resp.AddWarning("Tidy operation already in progress.")
+		return resp, nil
+	}
+
+	// Tests using framework will screw up the storage so make a locally
+	// scoped req to hold a reference
+	req = &logical.Request{
+		Storage: req.Storage,
+	}
+
+	status := &tidyStatus{
+		safetyBuffer:     safetyBuffer,
+		tidyCertStore:    tidyCertStore,
+		tidyRevokedCerts: tidyRevokedCerts,
+		state:            tidyStatusStarted,
+		timeStarted:      time.Now(),
+	}
+
+	b.tidyStatusLock.Lock()
+	b.tidyStatus = status
+	b.tidyStatusLock.Unlock()
+
+	go func() {
+		defer b.tidyCASGuard.Store(false)
+
+		// Don't cancel when the original client request goes away.
+		ctx := context.Background()
+		logger := b.Logger().Named("tidy")
+
+		err := b.doTidy(ctx, req.Storage, logger, status, time.Duration(safetyBuffer)*time.Second)
+		if err != nil {
+			logger.Error("error running tidy", "error", err)
+		}
+
+		b.tidyStatusLock.Lock()
+		defer b.tidyStatusLock.Unlock()
+
+		status.timeFinished = time.Now()
+		status.err = err
+		if err != nil {
+			status.state = tidyStatusError
+		} else {
+			status.state = tidyStatusFinished
+		}
+	}()
+
+	resp := &logical.Response{}
+	resp.AddWarning("Tidy operation successfully started. Any information from the operation will be printed to Vault's server logs.")
+	return logical.RespondWithStatusCode(resp, req, http.StatusAccepted)
+}
+
+func (b *backend) doTidy(ctx context.Context, s logical.Storage, logger hclog.Logger, status *tidyStatus, safetyBuffer time.Duration) error {
+	if status.tidyCertStore {
+		deleted, err := tidyCertificates(ctx, s, certsStoragePrefix, safetyBuffer)
+		b.tidyStatusLock.Lock()
+		status.certStoreDeletedCount = deleted
+		b.tidyStatusLock.Unlock()
+		if err != nil {
+			return err
+		}
+	}
+
+	if status.tidyRevokedCerts {
+		b.revokeLock.Lock()
+		defer b.revokeLock.Unlock()
+
+		deleted, err := tidyCertificates(ctx, s, revokedStoragePrefix, safetyBuffer)
+		b.tidyStatusLock.Lock()
+		status.revokedCertDeletedCount = deleted
+		b.tidyStatusLock.Unlock()
+		if err != nil {
+			return err
+		}
+
+		if deleted > 0 {
+			if err := b.rebuildKRL(ctx, s); err != nil {
+				return fmt.Errorf("failed to rebuild KRL: %w", err)
+			}
+		}
+	}
+
+	logger.Info("tidy finished", "cert_store_deleted_count", status.certStoreDeletedCount, "revoked_cert_deleted_count", status.revokedCertDeletedCount)
+	return nil
+}
+
+// tidyCertificates deletes the certificates below the prefix which expired
+// longer than the safety buffer ago, returning how many were deleted.
+func tidyCertificates(ctx context.Context, s logical.Storage, prefix string, safetyBuffer time.Duration) (uint, error) {
+	serials, err := s.List(ctx, prefix)
+	if err != nil {
+		return 0, fmt.Errorf("error fetching list of certificates: %w", err)
+	}
+
+	var deleted uint
+	for _, serial := range serials {
+		cert, err := fetchCertificate(ctx, s, prefix, serial)
+		if err != nil {
+			return deleted, err
+		}
+		if cert != nil && time.Now().Before(cert.Expiration.Add(safetyBuffer)) {
+			continue
+		}
+
+		if err := s.Delete(ctx, prefix+serial); err != nil {
+			return deleted, fmt.Errorf("error deleting certificate %s: %w", serial, err)
+		}
+		deleted++
+	}
+
+	return deleted, nil
+}
+
+func (b *backend) pathTidyStatusRead(_ context.Context, _ *logical.Request, _ *framework.FieldData) (*logical.Response, error) {
+	b.tidyStatusLock.RLock()
+	defer b.tidyStatusLock.RUnlock()
+
+	resp := &logical.Response{
+		Data: map[string]interface{}{
+			"safety_buffer":              nil,
+			"tidy_cert_store":            nil,
+			"tidy_revoked_certs":         nil,
+			"state":                      tidyStatusInactive.String(),
+			"error":                      nil,
+			"time_started":               nil,
+			"time_finished":              nil,
+			"cert_store_deleted_count":   nil,
+			"revoked_cert_deleted_count": nil,
+		},
+	}
+
+	status := b.tidyStatus
+	if status == nil {
+		return resp, nil
+	}
+
+	resp.Data["safety_buffer"] = status.safetyBuffer
+	resp.Data["tidy_cert_store"] = status.tidyCertStore
+	resp.Data["tidy_revoked_certs"] = status.tidyRevokedCerts
+	resp.Data["state"] = status.state.String()
+	resp.Data["time_started"] = status.timeStarted
+	resp.Data["cert_store_deleted_count"] = status.certStoreDeletedCount
+	resp.Data["revoked_cert_deleted_count"] = status.revokedCertDeletedCount
+
+	if status.err != nil {
+		resp.Data["error"] = status.err.Error()
+	}
+	if !status.timeFinished.IsZero() {
+		resp.Data["time_finished"] = status.timeFinished
+	}
+
+	return resp, nil
+}
+
+const pathTidyHelpSyn = `
+Tidy up the backend by removing expired certificates and revocation entries.
+`
+
+const pathTidyHelpDesc = `
+This endpoint allows expired certificate records to be removed from the
+backend, freeing up storage and shortening the key revocation list.
+
+For safety, this function is a noop if called without parameters; cleanup from
+normal certificate storage must be enabled with 'tidy_cert_store' and cleanup
+from revoked certificates must be enabled with 'tidy_revoked_certs'.
+
+The 'safety_buffer' parameter is useful to ensure that clock skew amongst your
+hosts cannot lead to a certificate being removed from the key revocation list
+while it is still considered valid by other hosts (for instance, if their
+clocks are a few minutes behind). The 'safety_buffer' parameter can be an
+integer number of seconds or a string duration like "72h".
+`
+
+const pathTidyStatusHelpSyn = `
+Returns the status of the tidy operation.
+`
+
+const pathTidyStatusHelpDesc = `
+This is a read only endpoint that returns information about the current tidy
+operation, or the most recent if none is currently running.
+`
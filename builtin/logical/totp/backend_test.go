@@ -10,6 +10,8 @@ import (
 	"net/url"
 	"path"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -18,6 +20,7 @@ import (
 	"github.com/hashicorp/vault/sdk/logical"
 	"github.com/mitchellh/mapstructure"
 	otplib "github.com/pquerna/otp"
+	hotplib "github.com/pquerna/otp/hotp"
 	totplib "github.com/pquerna/otp/totp"
 )
 
@@ -1241,3 +1244,276 @@ func testAccStepValidateCode(t *testing.T, name string, code string, valid, expe
 		},
 	}
 }
+
+func TestBackend_hotpKey(t *testing.T) {
+	config := logical.TestBackendConfig()
+	config.StorageView = &logical.InmemStorage{}
+	b, err := Factory(context.Background(), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	doReq := func(op logical.Operation, path string, data map[string]interface{}) *logical.Response {
+		t.Helper()
+		resp, err := b.HandleRequest(namespace.RootContext(nil), &logical.Request{
+			Path:      path,
+			Operation: op,
+			Storage:   config.StorageView,
+			Data:      data,
+		})
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("bad: path: %q\nresp: %#v\nerr: %v", path, resp, err)
+		}
+		return resp
+	}
+
+	key, _ := createKey()
+	code := func(counter uint64) string {
+		t.Helper()
+		c, err := hotplib.GenerateCode(key, counter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+	validate := func(c string, valid bool) {
+		t.Helper()
+		resp := doReq(logical.UpdateOperation, "code/test", map[string]interface{}{
+			"code": c,
+		})
+		if resp.Data["valid"] != valid {
+			t.Fatalf("expected code %s to be valid=%t", c, valid)
+		}
+	}
+
+	doReq(logical.UpdateOperation, "keys/test", map[string]interface{}{
+		"type":       "hotp",
+		"key":        key,
+		"counter":    5,
+		"look_ahead": 3,
+		// This test validates many invalid codes on purpose
+		"lockout_threshold": 100,
+	})
+
+	resp := doReq(logical.ReadOperation, "keys/test", nil)
+	if resp.Data["type"] != "hotp" || resp.Data["counter"] != uint64(5) || resp.Data["look_ahead"] != uint(3) {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+
+	validate(code(4), false)
+	validate(code(5), true)
+	// Codes can't be reused
+	validate(code(5), false)
+	// Codes within the look ahead window are accepted, skipping the ones before
+	validate(code(9), true)
+	validate(code(8), false)
+	validate(code(14), false)
+
+	// Resynchronize after the token got past the look ahead window
+	resp, err = b.HandleRequest(namespace.RootContext(nil), &logical.Request{
+		Path:      "resync/test",
+		Operation: logical.UpdateOperation,
+		Storage:   config.StorageView,
+		Data: map[string]interface{}{
+			"code":      code(20),
+			"next_code": code(22),
+		},
+	})
+	if err != nil || resp == nil || !resp.IsError() {
+		t.Fatalf("expected resync with non-consecutive codes to fail: %#v", resp)
+	}
+	resp = doReq(logical.UpdateOperation, "resync/test", map[string]interface{}{
+		"code":      code(20),
+		"next_code": code(21),
+	})
+	if resp.Data["counter"] != uint64(22) {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+	validate(code(21), false)
+	validate(code(22), true)
+
+	// Concurrent validations of the same code can't both succeed
+	var wg sync.WaitGroup
+	var validCount atomic.Int32
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := b.HandleRequest(namespace.RootContext(nil), &logical.Request{
+				Path:      "code/test",
+				Operation: logical.UpdateOperation,
+				Storage:   config.StorageView,
+				Data: map[string]interface{}{
+					"code": code(23),
+				},
+			})
+			if err == nil && resp.Data["valid"] == true {
+				validCount.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	if validCount.Load() != 1 {
+		t.Fatalf("expected exactly one concurrent validation to succeed, got %d", validCount.Load())
+	}
+
+	// Generating a code uses up a counter value
+	resp = doReq(logical.ReadOperation, "code/test", nil)
+	if resp.Data["code"] != code(24) {
+		t.Fatalf("expected code for counter 24, got %v", resp.Data["code"])
+	}
+	validate(code(24), false)
+	validate(code(25), true)
+}
+
+func TestBackend_hotpKeyURL(t *testing.T) {
+	config := logical.TestBackendConfig()
+	config.StorageView = &logical.InmemStorage{}
+	b, err := Factory(context.Background(), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := b.HandleRequest(namespace.RootContext(nil), &logical.Request{
+		Path:      "keys/generated",
+		Operation: logical.UpdateOperation,
+		Storage:   config.StorageView,
+		Data: map[string]interface{}{
+			"type":         "hotp",
+			"generate":     true,
+			"issuer":       "Vault",
+			"account_name": "Test",
+			"counter":      7,
+			"qr_size":      0,
+		},
+	})
+	if err != nil || resp == nil || resp.IsError() {
+		t.Fatalf("bad: resp: %#v\nerr: %v", resp, err)
+	}
+	keyURL, err := url.Parse(resp.Data["url"].(string))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keyURL.Host != "hotp" || keyURL.Query().Get("counter") != "7" {
+		t.Fatalf("bad url: %s", keyURL)
+	}
+
+	// The type and counter are read from imported urls
+	resp, err = b.HandleRequest(namespace.RootContext(nil), &logical.Request{
+		Path:      "keys/imported",
+		Operation: logical.UpdateOperation,
+		Storage:   config.StorageView,
+		Data: map[string]interface{}{
+			"url": keyURL.String(),
+		},
+	})
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("bad: resp: %#v\nerr: %v", resp, err)
+	}
+	resp, err = b.HandleRequest(namespace.RootContext(nil), &logical.Request{
+		Path:      "keys/imported",
+		Operation: logical.ReadOperation,
+		Storage:   config.StorageView,
+	})
+	if err != nil || resp == nil {
+		t.Fatalf("bad: resp: %#v\nerr: %v", resp, err)
+	}
+	if resp.Data["type"] != "hotp" || resp.Data["counter"] != uint64(7) || resp.Data["issuer"] != "Vault" {
+		t.Fatalf("bad: %#v", resp.Data)
+	}
+}
+
+func TestBackend_codeReplayAndLockout(t *testing.T) {
+	config := logical.TestBackendConfig()
+	config.StorageView = &logical.InmemStorage{}
+	b, err := Factory(context.Background(), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	doReq := func(op logical.Operation, path string, data map[string]interface{}) (*logical.Response, error) {
+		resp, err := b.HandleRequest(namespace.RootContext(nil), &logical.Request{
+			Path:      path,
+			Operation: op,
+			Storage:   config.StorageView,
+			Data:      data,
+		})
+		if err == nil && resp != nil && resp.IsError() {
+			err = resp.Error()
+		}
+		return resp, err
+	}
+	validate := func(code string) (bool, error) {
+		resp, err := doReq(logical.UpdateOperation, "code/test", map[string]interface{}{
+			"code": code,
+		})
+		if err != nil {
+			return false, err
+		}
+		return resp.Data["valid"].(bool), nil
+	}
+
+	key, _ := createKey()
+	if _, err := doReq(logical.UpdateOperation, "keys/test", map[string]interface{}{
+		"key":               key,
+		"lockout_threshold": 3,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// A code of the previous time step is accepted within the skew, but not
+	// once a code of the current time step was used
+	previous, _ := totplib.GenerateCodeCustom(key, time.Now().Add(-30*time.Second), totplib.ValidateOpts{Period: 30, Digits: otplib.DigitsSix, Algorithm: otplib.AlgorithmSHA1})
+	current, _ := generateCode(key, 30, otplib.DigitsSix, otplib.AlgorithmSHA1)
+	if valid, err := validate(current); err != nil || !valid {
+		t.Fatalf("expected current code to be valid: %v", err)
+	}
+	if _, err := validate(current); err == nil {
+		t.Fatal("expected reuse of the current code to fail")
+	}
+	if previous != current {
+		if _, err := validate(previous); err == nil {
+			t.Fatal("expected code of an earlier time step to be rejected")
+		}
+	}
+
+	// Replay protection survives restarts, as the time step is persisted
+	b, err = Factory(context.Background(), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := validate(current); err == nil {
+		t.Fatal("expected reuse of the current code to fail after a restart")
+	}
+
+	for i := 0; i < 3; i++ {
+		if valid, err := validate("000000"); err != nil || valid {
+			t.Fatalf("expected invalid code to be rejected without error: %v", err)
+		}
+	}
+
+	resp, err := doReq(logical.ReadOperation, "lockout/test", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Data["failed_attempts"] != uint(3) || resp.Data["locked"] != true {
+		t.Fatalf("expected key to be locked out: %#v", resp.Data)
+	}
+	if _, err := validate(current); err == nil || !strings.Contains(err.Error(), "locked out") {
+		t.Fatalf("expected validation of a locked out key to fail, got %v", err)
+	}
+
+	if _, err := doReq(logical.DeleteOperation, "lockout/test", nil); err != nil {
+		t.Fatal(err)
+	}
+	resp, err = doReq(logical.ReadOperation, "lockout/test", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Data["failed_attempts"] != 0 || resp.Data["locked"] != false {
+		t.Fatalf("expected lockout to be reset: %#v", resp.Data)
+	}
+	if valid, err := validate("000000"); err != nil || valid {
+		t.Fatalf("expected invalid code to be rejected without error: %v", err)
+	}
+}
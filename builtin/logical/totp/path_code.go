@@ -5,15 +5,20 @@ package totp
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 
 	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/helper/locksutil"
 	"github.com/hashicorp/vault/sdk/logical"
 	otplib "github.com/pquerna/otp"
+	hotplib "github.com/pquerna/otp/hotp"
 	totplib "github.com/pquerna/otp/totp"
 )
 
+var errCodeAlreadyUsed = errors.New("code already used; wait until the next time period")
+
 func pathCode(b *backend) *framework.Path {
 	return &framework.Path{
 		Pattern: "code/" + framework.GenericNameWithAtRegex("name"),
@@ -30,7 +35,7 @@ func pathCode(b *backend) *framework.Path {
 			},
 			"code": {
 				Type:        framework.TypeString,
-				Description: "TOTP code to be validated.",
+				Description: "TOTP or HOTP code to be validated.",
 			},
 		},
 
@@ -57,6 +62,10 @@ func pathCode(b *backend) *framework.Path {
 func (b *backend) pathReadCode(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
 	name := data.Get("name").(string)
 
+	lock := locksutil.LockForKey(b.keyLocks, name)
+	lock.Lock()
+	defer lock.Unlock()
+
 	// Get the key
 	key, err := b.Key(ctx, req.Storage, name)
 	if err != nil {
@@ -66,6 +75,28 @@ func (b *backend) pathReadCode(ctx context.Context, req *logical.Request, data *
 		return logical.ErrorResponse(fmt.Sprintf("unknown key: %s", name)), nil
 	}
 
+	if key.keyType() == keyTypeHOTP {
+		// Generating a code uses up the current counter value
+		hotpToken, err := hotplib.GenerateCodeCustom(key.Key, key.Counter, hotplib.ValidateOpts{
+			Digits:    key.Digits,
+			Algorithm: key.Algorithm,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		key.Counter++
+		if err := b.setKey(ctx, req.Storage, name, key); err != nil {
+			return nil, err
+		}
+
+		return &logical.Response{
+			Data: map[string]interface{}{
+				"code": hotpToken,
+			},
+		}, nil
+	}
+
 	// Generate password using totp library
 	totpToken, err := totplib.GenerateCodeCustom(key.Key, time.Now(), totplib.ValidateOpts{
 		Period:    key.Period,
@@ -93,6 +124,10 @@ func (b *backend) pathValidateCode(ctx context.Context, req *logical.Request, da
 		return logical.ErrorResponse("the code value is required"), nil
 	}
 
+	lock := locksutil.LockForKey(b.keyLocks, name)
+	lock.Lock()
+	defer lock.Unlock()
+
 	// Get the key's stored values
 	key, err := b.Key(ctx, req.Storage, name)
 	if err != nil {
@@ -102,31 +137,31 @@ func (b *backend) pathValidateCode(ctx context.Context, req *logical.Request, da
 		return logical.ErrorResponse(fmt.Sprintf("unknown key: %s", name)), nil
 	}
 
-	usedName := fmt.Sprintf("%s_%s", name, code)
-
-	_, ok := b.usedCodes.Get(usedName)
-	if ok {
-		return logical.ErrorResponse("code already used; wait until the next time period"), nil
+	if resp, err := b.checkLockout(ctx, req.Storage, name, key); resp != nil || err != nil {
+		return resp, err
 	}
 
-	valid, err := totplib.ValidateCustom(code, key.Key, time.Now(), totplib.ValidateOpts{
-		Period:    key.Period,
-		Skew:      key.Skew,
-		Digits:    key.Digits,
-		Algorithm: key.Algorithm,
-	})
-	if err != nil && err != otplib.ErrValidateInputInvalidLength {
-		return logical.ErrorResponse("an error occurred while validating the code"), err
+	var valid bool
+	switch key.keyType() {
+	case keyTypeHOTP:
+		valid, err = b.validateHOTPCode(ctx, req.Storage, name, key, code)
+	default:
+		valid, err = b.validateTOTPCode(ctx, req.Storage, name, key, code)
+	}
+	if err == errCodeAlreadyUsed {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+	if err != nil {
+		return nil, err
 	}
 
-	// Take the key skew, add two for behind and in front, and multiple that by
-	// the period to cover the full possibility of the validity of the key
-	err = b.usedCodes.Add(usedName, nil, time.Duration(
-		int64(time.Second)*
-			int64(key.Period)*
-			int64((2+key.Skew))))
+	if valid {
+		err = b.resetFailedValidations(ctx, req.Storage, name)
+	} else {
+		err = b.recordFailedValidation(ctx, req.Storage, name, key)
+	}
 	if err != nil {
-		return nil, fmt.Errorf("error adding code to used cache: %w", err)
+		return nil, err
 	}
 
 	return &logical.Response{
@@ -136,11 +171,82 @@ func (b *backend) pathValidateCode(ctx context.Context, req *logical.Request, da
 	}, nil
 }
 
+// validateTOTPCode validates the code against the time steps within the skew
+// of the key. A valid code records its time step, so neither it nor any code
+// of an earlier time step can be used again. The key lock must be held.
+func (b *backend) validateTOTPCode(ctx context.Context, s logical.Storage, name string, key *keyEntry, code string) (bool, error) {
+	opts := hotplib.ValidateOpts{
+		Digits:    key.Digits,
+		Algorithm: key.Algorithm,
+	}
+
+	// This matches the counters the totp library validates against
+	step := uint64(time.Now().Unix()) / uint64(key.Period)
+	for i := step - uint64(key.Skew); i <= step+uint64(key.Skew); i++ {
+		ok, err := hotplib.ValidateCustom(code, i, key.Key, opts)
+		if err != nil {
+			if err == otplib.ErrValidateInputInvalidLength {
+				return false, nil
+			}
+			return false, fmt.Errorf("an error occurred while validating the code: %w", err)
+		}
+		if !ok {
+			continue
+		}
+
+		if i <= key.UsedStep {
+			return false, errCodeAlreadyUsed
+		}
+
+		key.UsedStep = i
+		if err := b.setKey(ctx, s, name, key); err != nil {
+			return false, err
+		}
+		return true, nil
+	}
+
+	return false, nil
+}
+
+// validateHOTPCode validates the code against the current counter value of
+// the key and the look ahead window after it. A valid code moves the counter
+// past the value it was generated with, so neither it nor any earlier code
+// can be used again. The key lock must be held.
+func (b *backend) validateHOTPCode(ctx context.Context, s logical.Storage, name string, key *keyEntry, code string) (bool, error) {
+	opts := hotplib.ValidateOpts{
+		Digits:    key.Digits,
+		Algorithm: key.Algorithm,
+	}
+
+	for i := uint64(0); i <= uint64(key.LookAhead); i++ {
+		ok, err := hotplib.ValidateCustom(code, key.Counter+i, key.Key, opts)
+		if err != nil {
+			if err == otplib.ErrValidateInputInvalidLength {
+				return false, nil
+			}
+			return false, fmt.Errorf("an error occurred while validating the code: %w", err)
+		}
+		if !ok {
+			continue
+		}
+
+		key.Counter += i + 1
+		if err := b.setKey(ctx, s, name, key); err != nil {
+			return false, err
+		}
+		return true, nil
+	}
+
+	return false, nil
+}
+
 const pathCodeHelpSyn = `
-Request time-based one-time use password or validate a password for a certain key .
+Request a one-time use password or validate a password for a certain key.
 `
 
 const pathCodeHelpDesc = `
-This path generates and validates time-based one-time use passwords for a certain key. 
+This path generates and validates time-based or counter-based one-time use
+passwords for a certain key. Generating or validating a code of a counter-based
+key advances its counter.
 
 `
@@ -13,13 +13,21 @@ import (
 	"net/url"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/helper/locksutil"
 	"github.com/hashicorp/vault/sdk/logical"
 	otplib "github.com/pquerna/otp"
+	hotplib "github.com/pquerna/otp/hotp"
 	totplib "github.com/pquerna/otp/totp"
 )
 
+const (
+	keyTypeTOTP = "totp"
+	keyTypeHOTP = "hotp"
+)
+
 func pathListKeys(b *backend) *framework.Path {
 	return &framework.Path{
 		Pattern: "keys/?$",
@@ -53,6 +61,12 @@ func pathKeys(b *backend) *framework.Path {
 				Description: "Name of the key.",
 			},
 
+			"type": {
+				Type:        framework.TypeString,
+				Default:     keyTypeTOTP,
+				Description: `The type of the key; either "totp" for time-based keys or "hotp" for counter-based keys. If a url is given, its type is used.`,
+			},
+
 			"generate": {
 				Type:        framework.TypeBool,
 				Default:     false,
@@ -110,6 +124,42 @@ func pathKeys(b *backend) *framework.Path {
 				Description: `The number of delay periods that are allowed when validating a TOTP token. This value can either be 0 or 1. Only used if generate is true.`,
 			},
 
+			"counter": {
+				Type:        framework.TypeInt,
+				Default:     0,
+				Description: `The initial counter value of the HOTP key. Only used if type is hotp.`,
+			},
+
+			"look_ahead": {
+				Type:        framework.TypeInt,
+				Default:     10,
+				Description: `The number of counter values past the current one that are accepted when validating an HOTP code, to tolerate codes generated without being validated. Only used if type is hotp.`,
+			},
+
+			"lockout_threshold": {
+				Type:        framework.TypeInt,
+				Default:     defaultLockoutThreshold,
+				Description: `The number of failed validation attempts after which validating codes of the key is locked out.`,
+			},
+
+			"lockout_duration": {
+				Type:        framework.TypeDurationSecond,
+				Default:     int(defaultLockoutDuration.Seconds()),
+				Description: `The amount of time validating codes of the key is locked out for.`,
+			},
+
+			"lockout_counter_reset": {
+				Type:        framework.TypeDurationSecond,
+				Default:     int(defaultLockoutCounterReset.Seconds()),
+				Description: `The amount of time after the last failed validation attempt after which the failed attempts are forgotten.`,
+			},
+
+			"disable_lockout": {
+				Type:        framework.TypeBool,
+				Default:     false,
+				Description: `Disables the lockout of the key after failed validation attempts.`,
+			},
+
 			"qr_size": {
 				Type:        framework.TypeInt,
 				Default:     200,
@@ -165,12 +215,31 @@ func (b *backend) Key(ctx context.Context, s logical.Storage, n string) (*keyEnt
 	return &result, nil
 }
 
+func (b *backend) setKey(ctx context.Context, s logical.Storage, n string, key *keyEntry) error {
+	entry, err := logical.StorageEntryJSON("key/"+n, key)
+	if err != nil {
+		return err
+	}
+
+	return s.Put(ctx, entry)
+}
+
 func (b *backend) pathKeyDelete(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
-	err := req.Storage.Delete(ctx, "key/"+data.Get("name").(string))
+	name := data.Get("name").(string)
+
+	lock := locksutil.LockForKey(b.keyLocks, name)
+	lock.Lock()
+	defer lock.Unlock()
+
+	err := req.Storage.Delete(ctx, "key/"+name)
 	if err != nil {
 		return nil, err
 	}
 
+	if err := b.resetFailedValidations(ctx, req.Storage, name); err != nil {
+		return nil, err
+	}
+
 	return nil, nil
 }
 
@@ -187,15 +256,29 @@ func (b *backend) pathKeyRead(ctx context.Context, req *logical.Request, data *f
 	algorithm := key.Algorithm.String()
 
 	// Return values of key
-	return &logical.Response{
+	resp := &logical.Response{
 		Data: map[string]interface{}{
+			"type":         key.keyType(),
 			"issuer":       key.Issuer,
 			"account_name": key.AccountName,
 			"period":       key.Period,
 			"algorithm":    algorithm,
 			"digits":       key.Digits,
+
+			"lockout_threshold":     key.lockoutThreshold(),
+			"lockout_duration":      int64(key.lockoutDuration().Seconds()),
+			"lockout_counter_reset": int64(key.lockoutCounterReset().Seconds()),
+			"disable_lockout":       key.DisableLockout,
 		},
-	}, nil
+	}
+
+	if key.keyType() == keyTypeHOTP {
+		delete(resp.Data, "period")
+		resp.Data["counter"] = key.Counter
+		resp.Data["look_ahead"] = key.LookAhead
+	}
+
+	return resp, nil
 }
 
 func (b *backend) pathKeyList(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
@@ -209,6 +292,7 @@ func (b *backend) pathKeyList(ctx context.Context, req *logical.Request, d *fram
 
 func (b *backend) pathKeyCreate(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
 	name := data.Get("name").(string)
+	keyType := data.Get("type").(string)
 	generate := data.Get("generate").(bool)
 	exported := data.Get("exported").(bool)
 	keyString := data.Get("key").(string)
@@ -221,6 +305,12 @@ func (b *backend) pathKeyCreate(ctx context.Context, req *logical.Request, data
 	qrSize := data.Get("qr_size").(int)
 	keySize := data.Get("key_size").(int)
 	inputURL := data.Get("url").(string)
+	counter := data.Get("counter").(int)
+	lookAhead := data.Get("look_ahead").(int)
+	lockoutThreshold := data.Get("lockout_threshold").(int)
+	lockoutDuration := time.Duration(data.Get("lockout_duration").(int)) * time.Second
+	lockoutCounterReset := time.Duration(data.Get("lockout_counter_reset").(int)) * time.Second
+	disableLockout := data.Get("disable_lockout").(bool)
 
 	if generate {
 		if keyString != "" {
@@ -244,6 +334,12 @@ func (b *backend) pathKeyCreate(ctx context.Context, req *logical.Request, data
 		path := strings.TrimPrefix(urlObject.Path, "/")
 		index := strings.Index(path, ":")
 
+		// Read key type
+		switch urlObject.Host {
+		case keyTypeTOTP, keyTypeHOTP:
+			keyType = urlObject.Host
+		}
+
 		// Read issuer
 		urlIssuer := urlQuery.Get("issuer")
 		if urlIssuer != "" {
@@ -289,6 +385,22 @@ func (b *backend) pathKeyCreate(ctx context.Context, req *logical.Request, data
 		if algorithmQuery != "" {
 			algorithm = algorithmQuery
 		}
+
+		// Read counter
+		counterQuery := urlQuery.Get("counter")
+		if counterQuery != "" {
+			counterInt, err := strconv.Atoi(counterQuery)
+			if err != nil {
+				return logical.ErrorResponse("an error occurred while parsing counter value in url"), err
+			}
+			counter = counterInt
+		}
+	}
+
+	switch keyType {
+	case keyTypeTOTP, keyTypeHOTP:
+	default:
+		return logical.ErrorResponse("the type value must be totp or hotp"), nil
 	}
 
 	// Translate digits and algorithm to a format the totp library understands
@@ -335,6 +447,26 @@ func (b *backend) pathKeyCreate(ctx context.Context, req *logical.Request, data
 		return logical.ErrorResponse("the key_size value must be greater than zero"), nil
 	}
 
+	if counter < 0 {
+		return logical.ErrorResponse("the counter value must be greater than or equal to zero"), nil
+	}
+
+	if lookAhead < 0 {
+		return logical.ErrorResponse("the look_ahead value must be greater than or equal to zero"), nil
+	}
+
+	if lockoutThreshold <= 0 {
+		return logical.ErrorResponse("the lockout_threshold value must be greater than zero"), nil
+	}
+
+	if lockoutDuration <= 0 {
+		return logical.ErrorResponse("the lockout_duration value must be greater than zero"), nil
+	}
+
+	if lockoutCounterReset <= 0 {
+		return logical.ErrorResponse("the lockout_counter_reset value must be greater than zero"), nil
+	}
+
 	// Period, Skew and Key Size need to be unsigned ints
 	uintPeriod := uint(period)
 	uintSkew := uint(skew)
@@ -354,15 +486,32 @@ func (b *backend) pathKeyCreate(ctx context.Context, req *logical.Request, data
 		}
 
 		// Generate a new key
-		keyObject, err := totplib.Generate(totplib.GenerateOpts{
-			Issuer:      issuer,
-			AccountName: accountName,
-			Period:      uintPeriod,
-			Digits:      keyDigits,
-			Algorithm:   keyAlgorithm,
-			SecretSize:  uintKeySize,
-			Rand:        b.GetRandomReader(),
-		})
+		var keyObject *otplib.Key
+		var err error
+		switch keyType {
+		case keyTypeHOTP:
+			keyObject, err = hotplib.Generate(hotplib.GenerateOpts{
+				Issuer:      issuer,
+				AccountName: accountName,
+				Digits:      keyDigits,
+				Algorithm:   keyAlgorithm,
+				SecretSize:  uintKeySize,
+				Rand:        b.GetRandomReader(),
+			})
+			if err == nil {
+				keyObject, err = hotpKeyWithCounter(keyObject, counter)
+			}
+		default:
+			keyObject, err = totplib.Generate(totplib.GenerateOpts{
+				Issuer:      issuer,
+				AccountName: accountName,
+				Period:      uintPeriod,
+				Digits:      keyDigits,
+				Algorithm:   keyAlgorithm,
+				SecretSize:  uintKeySize,
+				Rand:        b.GetRandomReader(),
+			})
+		}
 		if err != nil {
 			return logical.ErrorResponse("an error occurred while generating a key"), err
 		}
@@ -415,8 +564,7 @@ func (b *backend) pathKeyCreate(ctx context.Context, req *logical.Request, data
 		}
 	}
 
-	// Store it
-	entry, err := logical.StorageEntryJSON("key/"+name, &keyEntry{
+	key := &keyEntry{
 		Key:         keyString,
 		Issuer:      issuer,
 		AccountName: accountName,
@@ -424,17 +572,45 @@ func (b *backend) pathKeyCreate(ctx context.Context, req *logical.Request, data
 		Algorithm:   keyAlgorithm,
 		Digits:      keyDigits,
 		Skew:        uintSkew,
-	})
-	if err != nil {
-		return nil, err
+		Type:        keyType,
+
+		LockoutThreshold:    uint(lockoutThreshold),
+		LockoutDuration:     lockoutDuration,
+		LockoutCounterReset: lockoutCounterReset,
+		DisableLockout:      disableLockout,
 	}
-	if err := req.Storage.Put(ctx, entry); err != nil {
+	if keyType == keyTypeHOTP {
+		key.Counter = uint64(counter)
+		key.LookAhead = uint(lookAhead)
+	}
+
+	// Store it
+	lock := locksutil.LockForKey(b.keyLocks, name)
+	lock.Lock()
+	defer lock.Unlock()
+
+	if err := b.setKey(ctx, req.Storage, name, key); err != nil {
 		return nil, err
 	}
 
 	return response, nil
 }
 
+// hotpKeyWithCounter adds the initial counter value to the url of a generated
+// HOTP key, which authenticator apps require.
+func hotpKeyWithCounter(key *otplib.Key, counter int) (*otplib.Key, error) {
+	keyURL, err := url.Parse(key.String())
+	if err != nil {
+		return nil, err
+	}
+
+	query := keyURL.Query()
+	query.Set("counter", strconv.Itoa(counter))
+	keyURL.RawQuery = query.Encode()
+
+	return otplib.NewKeyFromURL(keyURL.String())
+}
+
 type keyEntry struct {
 	Key         string           `json:"key" mapstructure:"key" structs:"key"`
 	Issuer      string           `json:"issuer" mapstructure:"issuer" structs:"issuer"`
@@ -443,6 +619,24 @@ type keyEntry struct {
 	Algorithm   otplib.Algorithm `json:"algorithm" mapstructure:"algorithm" structs:"algorithm"`
 	Digits      otplib.Digits    `json:"digits" mapstructure:"digits" structs:"digits"`
 	Skew        uint             `json:"skew" mapstructure:"skew" structs:"skew"`
+	Type        string           `json:"type" mapstructure:"type" structs:"type"`
+	Counter     uint64           `json:"counter" mapstructure:"counter" structs:"counter"`
+	LookAhead   uint             `json:"look_ahead" mapstructure:"look_ahead" structs:"look_ahead"`
+	UsedStep    uint64           `json:"used_step" mapstructure:"used_step" structs:"used_step"`
+
+	LockoutThreshold    uint          `json:"lockout_threshold" mapstructure:"lockout_threshold" structs:"lockout_threshold"`
+	LockoutDuration     time.Duration `json:"lockout_duration" mapstructure:"lockout_duration" structs:"lockout_duration"`
+	LockoutCounterReset time.Duration `json:"lockout_counter_reset" mapstructure:"lockout_counter_reset" structs:"lockout_counter_reset"`
+	DisableLockout      bool          `json:"disable_lockout" mapstructure:"disable_lockout" structs:"disable_lockout"`
+}
+
+// keyType returns the type of the key; keys created before HOTP keys were
+// supported are time-based.
+func (k *keyEntry) keyType() string {
+	if k.Type == "" {
+		return keyTypeTOTP
+	}
+	return k.Type
 }
 
 const pathKeyHelpSyn = `
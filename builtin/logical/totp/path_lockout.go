@@ -0,0 +1,239 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package totp
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/helper/locksutil"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+const lockoutStoragePrefix = "lockout/"
+
+// The lockout defaults match those of the user lockout of auth methods.
+const (
+	defaultLockoutThreshold    = 5
+	defaultLockoutDuration     = 15 * time.Minute
+	defaultLockoutCounterReset = 15 * time.Minute
+)
+
+// lockoutEntry tracks the failed validation attempts of a key.
+type lockoutEntry struct {
+	FailedAttempts    uint      `json:"failed_attempts"`
+	LastFailedAttempt time.Time `json:"last_failed_attempt"`
+}
+
+func pathLockout(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "lockout/" + framework.GenericNameWithAtRegex("name"),
+
+		DisplayAttrs: &framework.DisplayAttributes{
+			OperationPrefix: operationPrefixTOTP,
+			OperationSuffix: "lockout",
+		},
+
+		Fields: map[string]*framework.FieldSchema{
+			"name": {
+				Type:        framework.TypeString,
+				Description: "Name of the key.",
+			},
+		},
+
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.ReadOperation: &framework.PathOperation{
+				Callback: b.pathLockoutRead,
+				DisplayAttrs: &framework.DisplayAttributes{
+					OperationVerb: "read",
+				},
+			},
+			logical.DeleteOperation: &framework.PathOperation{
+				Callback: b.pathLockoutReset,
+				DisplayAttrs: &framework.DisplayAttributes{
+					OperationVerb: "reset",
+				},
+			},
+		},
+
+		HelpSynopsis:    pathLockoutHelpSyn,
+		HelpDescription: pathLockoutHelpDesc,
+	}
+}
+
+func (b *backend) pathLockoutRead(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	name := data.Get("name").(string)
+
+	lock := locksutil.LockForKey(b.keyLocks, name)
+	lock.RLock()
+	defer lock.RUnlock()
+
+	key, err := b.Key(ctx, req.Storage, name)
+	if err != nil {
+		return nil, err
+	}
+	if key == nil {
+		return logical.ErrorResponse(fmt.Sprintf("unknown key: %s", name)), nil
+	}
+
+	entry, err := b.lockout(ctx, req.Storage, name)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &logical.Response{
+		Data: map[string]interface{}{
+			"failed_attempts":     0,
+			"last_failed_attempt": nil,
+			"locked":              false,
+			"locked_until":        nil,
+		},
+	}
+	if entry != nil {
+		resp.Data["failed_attempts"] = key.failedAttempts(entry)
+		resp.Data["last_failed_attempt"] = entry.LastFailedAttempt
+	}
+	if lockedUntil := key.lockedUntil(entry); !lockedUntil.IsZero() {
+		resp.Data["locked"] = true
+		resp.Data["locked_until"] = lockedUntil
+	}
+
+	return resp, nil
+}
+
+func (b *backend) pathLockoutReset(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	name := data.Get("name").(string)
+
+	lock := locksutil.LockForKey(b.keyLocks, name)
+	lock.Lock()
+	defer lock.Unlock()
+
+	return nil, b.resetFailedValidations(ctx, req.Storage, name)
+}
+
+func (b *backend) lockout(ctx context.Context, s logical.Storage, name string) (*lockoutEntry, error) {
+	entry, err := s.Get(ctx, lockoutStoragePrefix+name)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var result lockoutEntry
+	if err := entry.DecodeJSON(&result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// checkLockout returns an error response if the key is locked out. The key
+// lock must be held.
+func (b *backend) checkLockout(ctx context.Context, s logical.Storage, name string, key *keyEntry) (*logical.Response, error) {
+	entry, err := b.lockout(ctx, s, name)
+	if err != nil {
+		return nil, err
+	}
+
+	lockedUntil := key.lockedUntil(entry)
+	if lockedUntil.IsZero() {
+		return nil, nil
+	}
+
+	return logical.ErrorResponse(fmt.Sprintf("key is locked out due to too many failed validation attempts; try again after %s", lockedUntil.Format(time.RFC3339))), nil
+}
+
+// recordFailedValidation counts a failed validation attempt of the key. The
+// key lock must be held.
+func (b *backend) recordFailedValidation(ctx context.Context, s logical.Storage, name string, key *keyEntry) error {
+	if key.DisableLockout {
+		return nil
+	}
+
+	entry, err := b.lockout(ctx, s, name)
+	if err != nil {
+		return err
+	}
+	if entry == nil {
+		entry = &lockoutEntry{}
+	}
+
+	entry.FailedAttempts = key.failedAttempts(entry) + 1
+	entry.LastFailedAttempt = time.Now()
+
+	storageEntry, err := logical.StorageEntryJSON(lockoutStoragePrefix+name, entry)
+	if err != nil {
+		return err
+	}
+
+	return s.Put(ctx, storageEntry)
+}
+
+// resetFailedValidations clears the failed validation attempts of the key.
+// The key lock must be held.
+func (b *backend) resetFailedValidations(ctx context.Context, s logical.Storage, name string) error {
+	return s.Delete(ctx, lockoutStoragePrefix+name)
+}
+
+func (k *keyEntry) lockoutThreshold() uint {
+	if k.LockoutThreshold == 0 {
+		return defaultLockoutThreshold
+	}
+	return k.LockoutThreshold
+}
+
+func (k *keyEntry) lockoutDuration() time.Duration {
+	if k.LockoutDuration == 0 {
+		return defaultLockoutDuration
+	}
+	return k.LockoutDuration
+}
+
+func (k *keyEntry) lockoutCounterReset() time.Duration {
+	if k.LockoutCounterReset == 0 {
+		return defaultLockoutCounterReset
+	}
+	return k.LockoutCounterReset
+}
+
+// failedAttempts returns the number of failed validation attempts which still
+// count towards the lockout threshold; the count starts over once the counter
+// reset period has passed since the last failed attempt.
+func (k *keyEntry) failedAttempts(entry *lockoutEntry) uint {
+	if entry == nil || time.Now().After(entry.LastFailedAttempt.Add(k.lockoutCounterReset())) {
+		return 0
+	}
+	return entry.FailedAttempts
+}
+
+// lockedUntil returns the time until which the key is locked out, or the zero
+// time if it isn't.
+func (k *keyEntry) lockedUntil(entry *lockoutEntry) time.Time {
+	if k.DisableLockout || entry == nil || entry.FailedAttempts < k.lockoutThreshold() {
+		return time.Time{}
+	}
+
+	lockedUntil := entry.LastFailedAttempt.Add(k.lockoutDuration())
+	if !time.Now().Before(lockedUntil) {
+		return time.Time{}
+	}
+	return lockedUntil
+}
+
+const pathLockoutHelpSyn = `
+Read or reset the lockout state of a key.
+`
+
+const pathLockoutHelpDesc = `
+Validating codes of a key is locked out for 'lockout_duration' after
+'lockout_threshold' consecutive failed validation attempts, to protect the key
+against brute force attacks. Failed attempts are forgotten once
+'lockout_counter_reset' passes without another one.
+
+Reading this path returns the number of failed attempts and whether the key is
+locked out; deleting it resets the failed attempts, lifting any lockout.
+`
@@ -0,0 +1,147 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package totp
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/helper/locksutil"
+	"github.com/hashicorp/vault/sdk/logical"
+	otplib "github.com/pquerna/otp"
+	hotplib "github.com/pquerna/otp/hotp"
+)
+
+// hotpResyncWindow is the number of counter values past the current one that
+// are searched when resynchronizing an HOTP key.
+const hotpResyncWindow = 1000
+
+func pathResync(b *backend) *framework.Path {
+	return &framework.Path{
+		Pattern: "resync/" + framework.GenericNameWithAtRegex("name"),
+
+		DisplayAttrs: &framework.DisplayAttributes{
+			OperationPrefix: operationPrefixTOTP,
+			OperationVerb:   "resync",
+			OperationSuffix: "key",
+		},
+
+		Fields: map[string]*framework.FieldSchema{
+			"name": {
+				Type:        framework.TypeString,
+				Description: "Name of the key.",
+			},
+			"code": {
+				Type:        framework.TypeString,
+				Description: "HOTP code generated by the token.",
+			},
+			"next_code": {
+				Type:        framework.TypeString,
+				Description: "HOTP code generated by the token right after code.",
+			},
+		},
+
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.UpdateOperation: &framework.PathOperation{
+				Callback: b.pathResyncWrite,
+			},
+		},
+
+		HelpSynopsis:    pathResyncHelpSyn,
+		HelpDescription: pathResyncHelpDesc,
+	}
+}
+
+func (b *backend) pathResyncWrite(ctx context.Context, req *logical.Request, data *framework.FieldData) (*logical.Response, error) {
+	name := data.Get("name").(string)
+	code := data.Get("code").(string)
+	nextCode := data.Get("next_code").(string)
+
+	// Enforce input value requirements
+	if code == "" || nextCode == "" {
+		return logical.ErrorResponse("the code and next_code values are required"), nil
+	}
+
+	lock := locksutil.LockForKey(b.keyLocks, name)
+	lock.Lock()
+	defer lock.Unlock()
+
+	key, err := b.Key(ctx, req.Storage, name)
+	if err != nil {
+		return nil, err
+	}
+	if key == nil {
+		return logical.ErrorResponse(fmt.Sprintf("unknown key: %s", name)), nil
+	}
+	if key.keyType() != keyTypeHOTP {
+		return logical.ErrorResponse("only hotp keys can be resynchronized"), nil
+	}
+
+	if resp, err := b.checkLockout(ctx, req.Storage, name, key); resp != nil || err != nil {
+		return resp, err
+	}
+
+	opts := hotplib.ValidateOpts{
+		Digits:    key.Digits,
+		Algorithm: key.Algorithm,
+	}
+
+	// Find two consecutive counter values matching the codes; the counter is
+	// never moved backwards, so that used codes can't be replayed.
+	for i := uint64(0); i <= hotpResyncWindow; i++ {
+		counter := key.Counter + i
+		ok, err := hotplib.ValidateCustom(code, counter, key.Key, opts)
+		if err != nil {
+			if err == otplib.ErrValidateInputInvalidLength {
+				break
+			}
+			return logical.ErrorResponse("an error occurred while validating the code"), err
+		}
+		if !ok {
+			continue
+		}
+
+		ok, err = hotplib.ValidateCustom(nextCode, counter+1, key.Key, opts)
+		if err != nil && err != otplib.ErrValidateInputInvalidLength {
+			return logical.ErrorResponse("an error occurred while validating the code"), err
+		}
+		if !ok {
+			continue
+		}
+
+		key.Counter = counter + 2
+		if err := b.setKey(ctx, req.Storage, name, key); err != nil {
+			return nil, err
+		}
+		if err := b.resetFailedValidations(ctx, req.Storage, name); err != nil {
+			return nil, err
+		}
+
+		return &logical.Response{
+			Data: map[string]interface{}{
+				"counter": key.Counter,
+			},
+		}, nil
+	}
+
+	// Failed resynchronizations count towards the lockout like failed
+	// validations, as they search a much larger window
+	if err := b.recordFailedValidation(ctx, req.Storage, name, key); err != nil {
+		return nil, err
+	}
+
+	return logical.ErrorResponse("the codes do not match consecutive counter values of the key"), nil
+}
+
+const pathResyncHelpSyn = `
+Resynchronize the counter of an HOTP key with its token.
+`
+
+const pathResyncHelpDesc = `
+This path resynchronizes the counter of an HOTP key whose token generated more
+codes than the look ahead window of the key covers. It takes two consecutive
+codes generated by the token and, when they match consecutive counter values
+within the next 1000, moves the counter of the key past them.
+`
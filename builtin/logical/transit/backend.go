@@ -45,6 +45,17 @@ func Backend(ctx context.Context, conf *logical.BackendConfig) (*backend, error)
 				"archive/",
 				"policy/",
 			},
+
+			// Streaming paths read the request body and write the response
+			// body directly
+			Binary: []string{
+				"encrypt-stream/*",
+				"decrypt-stream/*",
+			},
+			Streaming: []string{
+				"encrypt-stream/*",
+				"decrypt-stream/*",
+			},
 		},
 
 		Paths: []*framework.Path{
@@ -59,13 +70,21 @@ func Backend(ctx context.Context, conf *logical.BackendConfig) (*backend, error)
 			b.pathListKeys(),
 			b.pathBYOKExportKeys(),
 			b.pathExportKeys(),
+			b.pathExportRequests(),
+			b.pathExportRequest(),
+			b.pathExportRequestApprove(),
+			b.pathExportRequestRelease(),
 			b.pathKeysConfig(),
 			b.pathEncrypt(),
 			b.pathDecrypt(),
+			b.pathEncryptStream(),
+			b.pathDecryptStream(),
 			b.pathDatakey(),
 			b.pathRandom(),
 			b.pathHash(),
 			b.pathHMAC(),
+			b.pathCMAC(),
+			b.pathKMAC(),
 			b.pathSign(),
 			b.pathVerify(),
 			b.pathBackup(),
@@ -119,6 +138,10 @@ type backend struct {
 	checkAutoRotateAfter time.Time
 	autoRotateOnce       sync.Once
 	backendUUID          string
+	// Policies with operation counts which have not been persisted yet
+	keyUsage sync.Map
+	// Lock to serialize changes to export requests
+	exportRequestLock sync.Mutex
 }
 
 func GetCacheSizeFromStorage(ctx context.Context, s logical.Storage) (int, error) {
@@ -203,6 +226,10 @@ func (b *backend) periodicFunc(ctx context.Context, req *logical.Request) error
 		b.autoRotateOnce = sync.Once{}
 	}
 
+	if flushErr := b.flushAllKeyUsage(ctx, req); flushErr != nil {
+		err = multierror.Append(err, flushErr)
+	}
+
 	return err
 }
 
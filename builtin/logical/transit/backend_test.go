@@ -391,6 +391,79 @@ func TestBackend_datakey(t *testing.T) {
 	})
 }
 
+// TestBackend_datakey_MLKEM ensures that data keys generated with ML-KEM keys
+// are encapsulated shared secrets which can be recovered through decryption.
+func TestBackend_datakey_MLKEM(t *testing.T) {
+	for _, keyType := range []string{"ml-kem-768", "ml-kem-1024"} {
+		keyType := keyType
+		t.Run(keyType, func(t *testing.T) {
+			b, storage := createBackendWithSysView(t)
+
+			resp, err := b.HandleRequest(context.Background(), &logical.Request{
+				Storage:   storage,
+				Operation: logical.UpdateOperation,
+				Path:      "keys/kem",
+				Data: map[string]interface{}{
+					"type": keyType,
+				},
+			})
+			require.NoError(t, err)
+			require.False(t, resp.IsError(), "got error response: %#v", resp)
+			require.Equal(t, true, resp.Data["supports_encapsulation"])
+			require.Equal(t, true, resp.Data["supports_decryption"])
+			require.Equal(t, false, resp.Data["supports_encryption"])
+
+			resp, err = b.HandleRequest(context.Background(), &logical.Request{
+				Storage:   storage,
+				Operation: logical.UpdateOperation,
+				Path:      "datakey/plaintext/kem",
+			})
+			require.NoError(t, err)
+			require.False(t, resp.IsError(), "got error response: %#v", resp)
+
+			plaintext := resp.Data["plaintext"].(string)
+			plainBytes, err := base64.StdEncoding.DecodeString(plaintext)
+			require.NoError(t, err)
+			require.Len(t, plainBytes, 32)
+
+			resp, err = b.HandleRequest(context.Background(), &logical.Request{
+				Storage:   storage,
+				Operation: logical.UpdateOperation,
+				Path:      "decrypt/kem",
+				Data: map[string]interface{}{
+					"ciphertext": resp.Data["ciphertext"],
+				},
+			})
+			require.NoError(t, err)
+			require.False(t, resp.IsError(), "got error response: %#v", resp)
+			require.Equal(t, plaintext, resp.Data["plaintext"])
+
+			// Only 256 bit data keys can be encapsulated
+			resp, err = b.HandleRequest(context.Background(), &logical.Request{
+				Storage:   storage,
+				Operation: logical.UpdateOperation,
+				Path:      "datakey/wrapped/kem",
+				Data: map[string]interface{}{
+					"bits": 512,
+				},
+			})
+			require.ErrorIs(t, err, logical.ErrInvalidRequest)
+			require.True(t, resp.IsError())
+
+			// Arbitrary plaintext can not be encrypted
+			resp, err = b.HandleRequest(context.Background(), &logical.Request{
+				Storage:   storage,
+				Operation: logical.UpdateOperation,
+				Path:      "encrypt/kem",
+				Data: map[string]interface{}{
+					"plaintext": plaintext,
+				},
+			})
+			require.Error(t, err)
+		})
+	}
+}
+
 func TestBackend_rotation(t *testing.T) {
 	defer os.Setenv("TRANSIT_ACC_KEY_TYPE", "")
 	testBackendRotation(t)
@@ -0,0 +1,201 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package transit
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/armon/go-metrics"
+	"github.com/hashicorp/go-multierror"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/helper/consts"
+	"github.com/hashicorp/vault/sdk/helper/keysutil"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+// trackKeyUsage records that operations have been counted with the policy, so
+// the counts are persisted by the periodic function. If caching is disabled,
+// or the key has reached its operation limit and should be rotated, they are
+// persisted right away. It must be called once the policy has been unlocked.
+//
+// Counts are not forwarded from nodes which can't persist them, such as
+// performance standbys, so operations performed there with keys without an
+// operation limit are not counted; keys with a limit have their requests
+// forwarded to the active node by lockKeyForOperations instead.
+func (b *backend) trackKeyUsage(ctx context.Context, storage logical.Storage, p *keysutil.Policy) {
+	if !p.HasPendingOperationCounts() || !b.canPersistKeyUsage() {
+		return
+	}
+
+	if b.System().CachingDisabled() || p.RotationRequired() {
+		if err := b.flushKeyUsage(ctx, storage, p); err != nil {
+			b.Logger().Error("failed to persist key operation counts", "key", p.Name, "error", err)
+			b.keyUsage.Store(p, struct{}{})
+		}
+		return
+	}
+
+	b.keyUsage.Store(p, struct{}{})
+}
+
+// lockKeyForOperations read locks the policy for serving n operations with
+// it, once enough operations have been reserved for the versions of the key
+// with an operation limit. Reservations are persisted before any operation is
+// served within them, so that operations served but not yet persisted still
+// count towards the limit if this node is lost. When the policy is cached,
+// operations are reserved in blocks to avoid persisting it for every request.
+//
+// Reservations can only be made on nodes which can persist the policy; on
+// others, such as performance standbys, logical.ErrReadOnly is returned so
+// that the request is forwarded to the active node. The policy is unlocked if
+// an error is returned.
+func (b *backend) lockKeyForOperations(ctx context.Context, storage logical.Storage, p *keysutil.Policy, n int) error {
+	// Without caching the policy is already exclusively locked, and only
+	// used for this request, so only its operations are reserved
+	if b.System().CachingDisabled() {
+		if err := b.reserveKeyUsage(ctx, storage, p, uint64(n), uint64(n)); err != nil {
+			p.Unlock()
+			return err
+		}
+		return nil
+	}
+
+	p.Lock(false)
+	if p.OperationsReserved(uint64(n)) {
+		return nil
+	}
+	p.Unlock()
+
+	p.Lock(true)
+	err := b.reserveKeyUsage(ctx, storage, p, uint64(n), uint64(n)+keysutil.OperationReservationSize)
+	p.Unlock()
+	if err != nil {
+		return err
+	}
+
+	// Concurrent requests may use up the reservation before the policy is
+	// read locked again, in which case their operations are refused
+	p.Lock(false)
+	return nil
+}
+
+// reserveKeyUsage reserves size operations for the versions of the key
+// which do not have n operations reserved. The policy must be exclusively
+// locked.
+func (b *backend) reserveKeyUsage(ctx context.Context, storage logical.Storage, p *keysutil.Policy, n, size uint64) error {
+	if p.OperationsReserved(n) {
+		return nil
+	}
+	if !b.canPersistKeyUsage() {
+		return logical.ErrReadOnly
+	}
+
+	if err := p.ReserveOperations(ctx, storage, size); err != nil {
+		if strings.Contains(err.Error(), logical.ErrReadOnly.Error()) {
+			return logical.ErrReadOnly
+		}
+		return fmt.Errorf("failed to reserve key operations: %w", err)
+	}
+
+	return nil
+}
+
+// batchSize returns the number of items in the batch input of the request,
+// or 1 if there is none.
+func batchSize(d *framework.FieldData) int {
+	if items, ok := d.Raw["batch_input"].([]interface{}); ok {
+		return len(items)
+	}
+	return 1
+}
+
+// flushKeyUsage persists the operation counts of the policy, rotating the
+// key if its latest version has reached its operation limit. The policy may
+// be an instance which is no longer cached, in which case its counts are
+// merged into the current one.
+func (b *backend) flushKeyUsage(ctx context.Context, storage logical.Storage, p *keysutil.Policy) error {
+	current, _, err := b.GetPolicy(ctx, keysutil.PolicyRequest{
+		Storage: storage,
+		Name:    p.Name,
+	}, b.GetRandomReader())
+	if err != nil {
+		return err
+	}
+
+	// The key has been deleted, so there is nothing to count against
+	if current == nil {
+		return nil
+	}
+
+	if !b.System().CachingDisabled() {
+		current.Lock(true)
+	}
+	defer current.Unlock()
+
+	current.MergeOperationCounts(p)
+
+	switch {
+	case current.RotationRequired():
+		if b.Logger().IsDebug() {
+			b.Logger().Debug("rotating key which has reached its operation limit", "key", current.Name)
+		}
+		err = current.Rotate(ctx, storage, b.GetRandomReader())
+	case current.HasPendingOperationCounts():
+		err = current.Persist(ctx, storage)
+	}
+	if err != nil {
+		return err
+	}
+
+	b.emitKeyUsageMetrics(current)
+
+	return nil
+}
+
+// flushAllKeyUsage persists the operation counts of every policy with
+// operations which have not been persisted yet.
+func (b *backend) flushAllKeyUsage(ctx context.Context, req *logical.Request) error {
+	if !b.canPersistKeyUsage() {
+		return nil
+	}
+
+	var errs *multierror.Error
+
+	b.keyUsage.Range(func(k, _ interface{}) bool {
+		p := k.(*keysutil.Policy)
+		b.keyUsage.Delete(p)
+
+		if err := b.flushKeyUsage(ctx, req.Storage, p); err != nil {
+			errs = multierror.Append(errs, err)
+			b.keyUsage.Store(p, struct{}{})
+		}
+		return true
+	})
+
+	return errs.ErrorOrNil()
+}
+
+// canPersistKeyUsage returns whether operation counts can be written to
+// storage on this node. Otherwise they are only kept in memory, and are lost
+// when the policy is reloaded.
+func (b *backend) canPersistKeyUsage() bool {
+	return !(b.System().ReplicationState().HasState(consts.ReplicationDRSecondary|consts.ReplicationPerformanceStandby) ||
+		(!b.System().LocalMount() && b.System().ReplicationState().HasState(consts.ReplicationPerformanceSecondary)))
+}
+
+// emitKeyUsageMetrics publishes the number of operations performed with each
+// version of the key.
+func (b *backend) emitKeyUsageMetrics(p *keysutil.Policy) {
+	for ver, count := range p.GetOperationCounts() {
+		labels := []metrics.Label{
+			{Name: "key", Value: p.Name},
+			{Name: "version", Value: strconv.Itoa(ver)},
+		}
+		metrics.SetGaugeWithLabels([]string{"secrets", "transit", b.backendUUID, "key_encryptions"}, float32(count.Encryptions), labels)
+		metrics.SetGaugeWithLabels([]string{"secrets", "transit", b.backendUUID, "key_signatures"}, float32(count.Signatures), labels)
+	}
+}
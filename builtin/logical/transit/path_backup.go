@@ -5,6 +5,7 @@ package transit
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/hashicorp/vault/sdk/framework"
 	"github.com/hashicorp/vault/sdk/logical"
@@ -37,7 +38,17 @@ func (b *backend) pathBackup() *framework.Path {
 }
 
 func (b *backend) pathBackupRead(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
-	backup, err := b.lm.BackupPolicy(ctx, req.Storage, d.Get("name").(string))
+	name := d.Get("name").(string)
+
+	approvalsRequired, err := b.exportApprovalsRequired(ctx, req.Storage, name)
+	if err != nil {
+		return nil, err
+	}
+	if approvalsRequired > 0 {
+		return logical.ErrorResponse(fmt.Sprintf("backup of the key requires approval; create an export request at export-requests/%s", name)), nil
+	}
+
+	backup, err := b.lm.BackupPolicy(ctx, req.Storage, name)
 	if err != nil {
 		return nil, err
 	}
@@ -8,11 +8,14 @@ import (
 	"crypto/ecdsa"
 	"crypto/ed25519"
 	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
 	"errors"
 	"fmt"
 	"strconv"
 	"strings"
 
+	"github.com/go-jose/go-jose/v3"
 	"github.com/hashicorp/vault/sdk/framework"
 	"github.com/hashicorp/vault/sdk/helper/keysutil"
 	"github.com/hashicorp/vault/sdk/logical"
@@ -46,6 +49,15 @@ func (b *backend) pathBYOKExportKeys() *framework.Path {
 				Description: "Hash function to use for inner OAEP encryption. Defaults to SHA256.",
 				Default:     "SHA256",
 			},
+			"oaep_label": {
+				Type:        framework.TypeString,
+				Description: "Base64-encoded label to use for inner OAEP encryption. Defaults to an empty label. Not supported with the jwe format.",
+			},
+			"format": {
+				Type:        framework.TypeString,
+				Description: `Format of the exported keys: "wrapped" (default), the format /import expects by default, or "jwe", a JWE of the JWK of the key. The jwe format supports the SHA1 and SHA256 hash functions.`,
+				Default:     importFormatWrapped,
+			},
 		},
 
 		Callbacks: map[logical.Operation]framework.OperationFunc{
@@ -61,7 +73,14 @@ func (b *backend) pathPolicyBYOKExportRead(ctx context.Context, req *logical.Req
 	dst := d.Get("destination").(string)
 	src := d.Get("source").(string)
 	version := d.Get("version").(string)
-	hash := d.Get("hash").(string)
+	opts := byokExportOptions{
+		Hash:      d.Get("hash").(string),
+		Format:    d.Get("format").(string),
+		OAEPLabel: d.Get("oaep_label").(string),
+	}
+	if err := opts.validate(); err != nil {
+		return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+	}
 
 	dstP, _, err := b.GetPolicy(ctx, keysutil.PolicyRequest{
 		Storage: req.Storage,
@@ -97,11 +116,64 @@ func (b *backend) pathPolicyBYOKExportRead(ctx context.Context, req *logical.Req
 		return logical.ErrorResponse("key is not exportable"), nil
 	}
 
+	if srcP.ExportApprovalsRequired > 0 {
+		return logical.ErrorResponse(fmt.Sprintf("export of the key requires approval; create an export request at export-requests/%s", srcP.Name)), nil
+	}
+
+	return exportBYOKPolicyKeys(dstP, srcP, version, opts)
+}
+
+// byokExportOptions are the parameters of the wrapping of BYOK exports.
+type byokExportOptions struct {
+	Hash      string
+	Format    string
+	OAEPLabel string
+}
+
+func (o byokExportOptions) validate() error {
+	if _, err := keysutil.ParseOAEPHash(o.Hash); err != nil {
+		return err
+	}
+	if _, err := base64.StdEncoding.DecodeString(o.OAEPLabel); err != nil {
+		return fmt.Errorf("error base64 decoding oaep_label: %w", err)
+	}
+
+	switch o.Format {
+	case importFormatWrapped:
+	case importFormatJWE:
+		if _, err := o.jweAlgorithm(); err != nil {
+			return err
+		}
+		if o.OAEPLabel != "" {
+			return errors.New("oaep_label is not supported with the jwe format")
+		}
+	default:
+		return fmt.Errorf("unknown export format: %s", o.Format)
+	}
+
+	return nil
+}
+
+// jweAlgorithm returns the JWE key management algorithm of the hash function.
+func (o byokExportOptions) jweAlgorithm() (jose.KeyAlgorithm, error) {
+	switch strings.ToUpper(o.Hash) {
+	case "SHA1":
+		return jose.RSA_OAEP, nil
+	case "SHA256":
+		return jose.RSA_OAEP_256, nil
+	default:
+		return "", fmt.Errorf("hash function %s is not supported with the jwe format", o.Hash)
+	}
+}
+
+// exportBYOKPolicyKeys returns the source key material wrapped with the
+// destination key for the version of the key, or all versions if empty.
+func exportBYOKPolicyKeys(dstP *keysutil.Policy, srcP *keysutil.Policy, version string, opts byokExportOptions) (*logical.Response, error) {
 	retKeys := map[string]string{}
 	switch version {
 	case "":
 		for k, v := range srcP.Keys {
-			exportKey, err := getBYOKExportKey(dstP, srcP, &v, hash)
+			exportKey, err := getBYOKExportKey(dstP, srcP, &v, opts)
 			if err != nil {
 				return nil, err
 			}
@@ -113,6 +185,7 @@ func (b *backend) pathPolicyBYOKExportRead(ctx context.Context, req *logical.Req
 		if version == "latest" {
 			versionValue = srcP.LatestVersion
 		} else {
+			var err error
 			version = strings.TrimPrefix(version, "v")
 			versionValue, err = strconv.Atoi(version)
 			if err != nil {
@@ -128,7 +201,7 @@ func (b *backend) pathPolicyBYOKExportRead(ctx context.Context, req *logical.Req
 			return logical.ErrorResponse("version does not exist or cannot be found"), logical.ErrInvalidRequest
 		}
 
-		exportKey, err := getBYOKExportKey(dstP, srcP, &key, hash)
+		exportKey, err := getBYOKExportKey(dstP, srcP, &key, opts)
 		if err != nil {
 			return nil, err
 		}
@@ -147,7 +220,7 @@ func (b *backend) pathPolicyBYOKExportRead(ctx context.Context, req *logical.Req
 	return resp, nil
 }
 
-func getBYOKExportKey(dstP *keysutil.Policy, srcP *keysutil.Policy, key *keysutil.KeyEntry, hash string) (string, error) {
+func getBYOKExportKey(dstP *keysutil.Policy, srcP *keysutil.Policy, key *keysutil.KeyEntry, opts byokExportOptions) (string, error) {
 	if dstP == nil || srcP == nil {
 		return "", errors.New("nil policy provided")
 	}
@@ -183,12 +256,58 @@ func getBYOKExportKey(dstP *keysutil.Policy, srcP *keysutil.Policy, key *keysuti
 		return "", fmt.Errorf("unable to export to unknown key type: %v", srcP.Type)
 	}
 
-	hasher, err := parseHashFn(hash)
+	if opts.Format == importFormatJWE {
+		return getBYOKExportJWE(dstP, targetKey, opts)
+	}
+
+	hasher, err := parseHashFn(opts.Hash)
+	if err != nil {
+		return "", err
+	}
+	label, err := base64.StdEncoding.DecodeString(opts.OAEPLabel)
 	if err != nil {
 		return "", err
 	}
 
-	return dstP.WrapKey(0, targetKey, srcP.Type, hasher)
+	return dstP.WrapKeyWithLabel(0, targetKey, srcP.Type, hasher, label)
+}
+
+// getBYOKExportJWE returns the compact serialization of a JWE of the JWK of
+// the target key, encrypted to the latest version of the destination key.
+func getBYOKExportJWE(dstP *keysutil.Policy, targetKey interface{}, opts byokExportOptions) (string, error) {
+	dstKey, ok := dstP.Keys[strconv.Itoa(dstP.LatestVersion)]
+	if !ok {
+		return "", errors.New("destination key version not found")
+	}
+	var wrappingKey *rsa.PublicKey
+	switch {
+	case dstKey.RSAPublicKey != nil:
+		wrappingKey = dstKey.RSAPublicKey
+	case dstKey.RSAKey != nil:
+		wrappingKey = &dstKey.RSAKey.PublicKey
+	default:
+		return "", errors.New("unsupported destination key type in use; must be a rsa key")
+	}
+
+	alg, err := opts.jweAlgorithm()
+	if err != nil {
+		return "", err
+	}
+	encrypter, err := jose.NewEncrypter(jose.A256GCM, jose.Recipient{Algorithm: alg, Key: wrappingKey}, nil)
+	if err != nil {
+		return "", err
+	}
+
+	jwk, err := jose.JSONWebKey{Key: targetKey}.MarshalJSON()
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal target key as JWK: %w", err)
+	}
+	jwe, err := encrypter.Encrypt(jwk)
+	if err != nil {
+		return "", fmt.Errorf("failed to encrypt target key: %w", err)
+	}
+
+	return jwe.CompactSerialize()
 }
 
 const pathBYOKExportHelpSyn = `Securely export named encryption or signing key`
@@ -202,5 +321,9 @@ the same encryption specification /import, allowing secure migration
 of keys between clusters to enable workloads to communicate between
 them.
 
+The keys are wrapped in the format /import expects by default, with the
+'hash' and 'oaep_label' OAEP parameters, or as JWEs of their JWKs with the
+"jwe" format.
+
 Presently this only works for RSA destination keys.
 `
@@ -5,6 +5,8 @@ package transit
 
 import (
 	"context"
+	"encoding/base64"
+	"fmt"
 	"testing"
 
 	"github.com/hashicorp/vault/sdk/logical"
@@ -227,3 +229,90 @@ func testBYOKExportImport(t *testing.T, keyType, feature string) {
 	// Ensure the original key is functional
 	validationFunc("test-source")
 }
+
+func TestTransit_BYOKExportFormats(t *testing.T) {
+	b, s := createBackendWithStorage(t)
+
+	doReq := func(op logical.Operation, path string, data map[string]interface{}) *logical.Response {
+		t.Helper()
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Path:      path,
+			Operation: op,
+			Storage:   s,
+			Data:      data,
+		})
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("resp: %#v\nerr: %v", resp, err)
+		}
+		return resp
+	}
+
+	doReq(logical.UpdateOperation, "keys/test-source", map[string]interface{}{
+		"type":       "aes256-gcm96",
+		"exportable": true,
+	})
+	resp := doReq(logical.ReadOperation, "wrapping_key", nil)
+	doReq(logical.UpdateOperation, "keys/wrapper/import", map[string]interface{}{
+		"public_key": resp.Data["public_key"],
+		"type":       "rsa-4096",
+	})
+
+	plaintextB64 := "dGhlIHF1aWNrIGJyb3duIGZveA==" // "the quick brown fox"
+	resp = doReq(logical.UpdateOperation, "encrypt/test-source", map[string]interface{}{
+		"plaintext": plaintextB64,
+	})
+	ciphertext := resp.Data["ciphertext"].(string)
+
+	label := base64.StdEncoding.EncodeToString([]byte("byok"))
+	testCases := []struct {
+		name         string
+		exportParams map[string]interface{}
+		importParams map[string]interface{}
+	}{
+		{
+			"wrapped with label",
+			map[string]interface{}{"hash": "SHA512", "oaep_label": label},
+			map[string]interface{}{"hash_function": "SHA512", "oaep_label": label},
+		},
+		{
+			"jwe",
+			map[string]interface{}{"format": "jwe"},
+			map[string]interface{}{"format": "jwe"},
+		},
+		{
+			"jwe with sha1",
+			map[string]interface{}{"format": "jwe", "hash": "SHA1"},
+			map[string]interface{}{"format": "jwe"},
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			resp := doReq(logical.ReadOperation, "byok-export/wrapper/test-source", tc.exportParams)
+			keys := resp.Data["keys"].(map[string]string)
+
+			name := fmt.Sprintf("test-%d", i)
+			tc.importParams["ciphertext"] = keys["1"]
+			tc.importParams["type"] = "aes256-gcm96"
+			doReq(logical.UpdateOperation, "keys/"+name+"/import", tc.importParams)
+
+			resp = doReq(logical.UpdateOperation, "decrypt/"+name, map[string]interface{}{
+				"ciphertext": ciphertext,
+			})
+			if resp.Data["plaintext"].(string) != plaintextB64 {
+				t.Fatalf("bad: plaintext; expected: %q, actual: %q", plaintextB64, resp.Data["plaintext"].(string))
+			}
+		})
+	}
+
+	// The jwe format only supports the hash functions of the JWE algorithms
+	_, err := b.HandleRequest(context.Background(), &logical.Request{
+		Path:      "byok-export/wrapper/test-source",
+		Operation: logical.ReadOperation,
+		Storage:   s,
+		Data:      map[string]interface{}{"format": "jwe", "hash": "SHA384"},
+	})
+	if err == nil {
+		t.Fatal("expected jwe export with SHA384 to fail")
+	}
+}
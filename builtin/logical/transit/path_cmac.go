@@ -0,0 +1,220 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package transit
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/helper/errutil"
+	"github.com/hashicorp/vault/sdk/helper/keysutil"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+func (b *backend) pathCMAC() *framework.Path {
+	return &framework.Path{
+		Pattern: "cmac/" + framework.GenericNameRegex("name"),
+
+		DisplayAttrs: &framework.DisplayAttributes{
+			OperationPrefix: operationPrefixTransit,
+			OperationVerb:   "generate",
+			OperationSuffix: "cmac",
+		},
+
+		Fields: map[string]*framework.FieldSchema{
+			"name": {
+				Type:        framework.TypeString,
+				Description: "The AES key to use for the CMAC function",
+			},
+
+			"input": {
+				Type:        framework.TypeString,
+				Description: "The base64-encoded input data",
+			},
+
+			"key_version": {
+				Type: framework.TypeInt,
+				Description: `The version of the key to use for generating the CMAC.
+Must be 0 (for latest) or a value greater than or equal
+to the min_encryption_version configured on the key.`,
+			},
+
+			"batch_input": {
+				Type: framework.TypeSlice,
+				Description: `
+Specifies a list of items to be processed in a single batch. When this parameter
+is set, if the parameter 'input' is also set, it will be ignored.
+Any batch output will preserve the order of the batch input.`,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathCMACWrite,
+		},
+
+		HelpSynopsis:    pathCMACHelpSyn,
+		HelpDescription: pathCMACHelpDesc,
+	}
+}
+
+func (b *backend) pathKMAC() *framework.Path {
+	return &framework.Path{
+		Pattern: "kmac/" + framework.GenericNameRegex("name") + framework.OptionalParamRegex("urlalgorithm"),
+
+		DisplayAttrs: &framework.DisplayAttributes{
+			OperationPrefix: operationPrefixTransit,
+			OperationVerb:   "generate",
+			OperationSuffix: "kmac|kmac-with-algorithm",
+		},
+
+		Fields: map[string]*framework.FieldSchema{
+			"name": {
+				Type:        framework.TypeString,
+				Description: "The key to use for the KMAC function",
+			},
+
+			"input": {
+				Type:        framework.TypeString,
+				Description: "The base64-encoded input data",
+			},
+
+			"algorithm": {
+				Type:    framework.TypeString,
+				Default: "kmac256",
+				Description: `Algorithm to use (POST body parameter). Valid values are:
+
+* kmac128
+* kmac256
+
+Defaults to "kmac256".`,
+			},
+
+			"urlalgorithm": {
+				Type:        framework.TypeString,
+				Description: `Algorithm to use (POST URL parameter)`,
+			},
+
+			"customization": {
+				Type:        framework.TypeString,
+				Description: "The base64-encoded customization string, used for domain separation",
+			},
+
+			"length": {
+				Type:        framework.TypeInt,
+				Default:     keysutil.KMACDefaultLength,
+				Description: "The length of the KMAC in bytes, between 16 and 64. Defaults to 32.",
+			},
+
+			"key_version": {
+				Type: framework.TypeInt,
+				Description: `The version of the key to use for generating the KMAC.
+Must be 0 (for latest) or a value greater than or equal
+to the min_encryption_version configured on the key.`,
+			},
+
+			"batch_input": {
+				Type: framework.TypeSlice,
+				Description: `
+Specifies a list of items to be processed in a single batch. When this parameter
+is set, if the parameter 'input' is also set, it will be ignored.
+Any batch output will preserve the order of the batch input.`,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathKMACWrite,
+		},
+
+		HelpSynopsis:    pathKMACHelpSyn,
+		HelpDescription: pathKMACHelpDesc,
+	}
+}
+
+func cmacFunc(p *keysutil.Policy, ver int, input []byte, _ int) ([]byte, error) {
+	key, err := p.CMACKey(ver)
+	if err != nil {
+		return nil, errutil.UserError{Err: err.Error()}
+	}
+
+	return keysutil.CMAC(key, input)
+}
+
+// kmacFunc returns the macFunc computing KMAC with the given algorithm and
+// customization string, keyed with the HMAC key of each key version.
+func kmacFunc(d *framework.FieldData, algorithmField string) (macFunc, error) {
+	algorithm := d.Get("urlalgorithm").(string)
+	if algorithm == "" {
+		algorithm = d.Get(algorithmField).(string)
+	}
+
+	var bits int
+	switch algorithm {
+	case "kmac128":
+		bits = 128
+	case "kmac256":
+		bits = 256
+	default:
+		return nil, fmt.Errorf("unsupported algorithm %q", algorithm)
+	}
+
+	customization, err := base64.StdEncoding.DecodeString(d.Get("customization").(string))
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode customization as base64: %w", err)
+	}
+
+	return func(p *keysutil.Policy, ver int, input []byte, length int) ([]byte, error) {
+		key, err := p.HMACKey(ver)
+		if err != nil {
+			return nil, errutil.UserError{Err: err.Error()}
+		}
+		if key == nil {
+			return nil, fmt.Errorf("KMAC key value could not be computed")
+		}
+		if length == 0 {
+			length = keysutil.KMACDefaultLength
+		}
+
+		out, err := keysutil.KMAC(bits, key, input, customization, length)
+		if err != nil {
+			return nil, errutil.UserError{Err: err.Error()}
+		}
+		return out, nil
+	}, nil
+}
+
+func (b *backend) pathCMACWrite(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	return b.macWrite(ctx, req, d, "cmac", cmacFunc, 0)
+}
+
+func (b *backend) pathKMACWrite(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	mac, err := kmacFunc(d, "algorithm")
+	if err != nil {
+		return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+	}
+
+	length := d.Get("length").(int)
+	if length < keysutil.KMACMinLength || length > keysutil.KMACMaxLength {
+		return logical.ErrorResponse("length must be between %d and %d bytes", keysutil.KMACMinLength, keysutil.KMACMaxLength), logical.ErrInvalidRequest
+	}
+
+	return b.macWrite(ctx, req, d, "kmac", mac, length)
+}
+
+const pathCMACHelpSyn = `Generate an AES-CMAC for input data using the named key`
+
+const pathCMACHelpDesc = `
+Generates an AES-CMAC (NIST SP 800-38B) of the given input data using the named
+AES key. Only aes128-gcm96 and aes256-gcm96 keys without key derivation can be
+used; keys used for CMAC should not also be used for encryption.
+`
+
+const pathKMACHelpSyn = `Generate a KMAC for input data using the named key`
+
+const pathKMACHelpDesc = `
+Generates a KMAC128 or KMAC256 (NIST SP 800-185) of the given input data,
+keyed with the HMAC key of the named key. An optional customization string
+can be given for domain separation, and must also be given on verification.
+`
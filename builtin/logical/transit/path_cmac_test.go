@@ -0,0 +1,215 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package transit
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"strconv"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/helper/keysutil"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransit_CMAC(t *testing.T) {
+	b, storage := createBackendWithSysView(t)
+
+	doReq := func(path string, data map[string]interface{}) (*logical.Response, error) {
+		return b.HandleRequest(context.Background(), &logical.Request{
+			Storage:   storage,
+			Operation: logical.UpdateOperation,
+			Path:      path,
+			Data:      data,
+		})
+	}
+
+	// Import the key of the RFC 4493 test vectors, as a peer holding the
+	// same AES key would
+	wrappingKey, err := b.getWrappingKey(context.Background(), storage)
+	require.NoError(t, err)
+	aesKey, _ := hex.DecodeString("2b7e151628aed2a6abf7158809cf4f3c")
+	importBlob := wrapTargetKeyForImport(t, &wrappingKey.Keys[strconv.Itoa(wrappingKey.LatestVersion)].RSAKey.PublicKey, aesKey, "aes128-gcm96", "SHA256")
+	_, err = doReq("keys/aes/import", map[string]interface{}{
+		"ciphertext":     importBlob,
+		"type":           "aes128-gcm96",
+		"allow_rotation": true,
+	})
+	require.NoError(t, err)
+
+	// RFC 4493 Section 4 and NIST SP 800-38B Appendix D.1 examples
+	message, _ := hex.DecodeString("6bc1bee22e409f96e93d7e117393172a" +
+		"ae2d8a571e03ac9c9eb76fac45af8e51" +
+		"30c81c46a35ce411e5fbc1191a0a52ef" +
+		"f69f2445df4f9b17ad2b417be66c3710")
+	for length, expected := range map[int]string{
+		16: "070a16b46b4d4144f79bdd9dd04a287c",
+		40: "dfa66747de9ae63030ca32611497c827",
+		64: "51f0bebf7e3b9d92fc49741779363cfe",
+	} {
+		expectedMAC, _ := hex.DecodeString(expected)
+		resp, err := doReq("cmac/aes", map[string]interface{}{"input": base64.StdEncoding.EncodeToString(message[:length])})
+		require.NoError(t, err)
+		require.Equal(t, "vault:v1:"+base64.StdEncoding.EncodeToString(expectedMAC), resp.Data["cmac"], "message length %d", length)
+	}
+
+	input := base64.StdEncoding.EncodeToString(message[:16])
+	resp, err := doReq("cmac/aes", map[string]interface{}{"input": input})
+	require.NoError(t, err)
+	cmac := resp.Data["cmac"].(string)
+
+	resp, err = doReq("verify/aes", map[string]interface{}{"input": input, "cmac": cmac})
+	require.NoError(t, err)
+	require.Equal(t, true, resp.Data["valid"])
+
+	resp, err = doReq("verify/aes", map[string]interface{}{"input": "dGhlIHF1aWNrIGJyb3duIGZveA==", "cmac": cmac})
+	require.NoError(t, err)
+	require.Equal(t, false, resp.Data["valid"])
+
+	// Batch generation and verification
+	resp, err = doReq("cmac/aes", map[string]interface{}{
+		"batch_input": []interface{}{
+			map[string]interface{}{"input": input, "reference": "one"},
+			map[string]interface{}{"input": "not base64", "reference": "two"},
+		},
+	})
+	require.NoError(t, err)
+	results := resp.Data["batch_results"].([]map[string]interface{})
+	require.Equal(t, cmac, results[0]["cmac"])
+	require.Equal(t, "one", results[0]["reference"])
+	require.NotEmpty(t, results[1]["error"])
+	require.Equal(t, "two", results[1]["reference"])
+
+	resp, err = doReq("verify/aes", map[string]interface{}{
+		"batch_input": []interface{}{
+			map[string]interface{}{"input": input, "cmac": cmac},
+			map[string]interface{}{"input": input, "cmac": "vault:v1:AAAA"},
+		},
+	})
+	require.NoError(t, err)
+	verifyResults := resp.Data["batch_results"].([]batchResponseHMACItem)
+	require.True(t, verifyResults[0].Valid)
+	require.False(t, verifyResults[1].Valid)
+
+	// CMACs and HMACs can not be mixed in a batch
+	resp, err = doReq("verify/aes", map[string]interface{}{
+		"batch_input": []interface{}{
+			map[string]interface{}{"input": input, "cmac": cmac},
+			map[string]interface{}{"input": input, "hmac": cmac},
+		},
+	})
+	require.ErrorIs(t, err, logical.ErrInvalidRequest)
+	require.True(t, resp.IsError())
+
+	// MACs from versions below the minimum decryption version are rejected
+	_, err = doReq("keys/aes/rotate", nil)
+	require.NoError(t, err)
+	_, err = doReq("keys/aes/config", map[string]interface{}{"min_decryption_version": 2})
+	require.NoError(t, err)
+	resp, err = doReq("verify/aes", map[string]interface{}{"input": input, "cmac": cmac})
+	require.ErrorIs(t, err, logical.ErrInvalidRequest)
+	require.True(t, resp.IsError())
+
+	// Only AES keys can be used
+	_, err = doReq("keys/chacha", map[string]interface{}{"type": "chacha20-poly1305"})
+	require.NoError(t, err)
+	resp, err = doReq("cmac/chacha", map[string]interface{}{"input": input})
+	require.ErrorIs(t, err, logical.ErrInvalidRequest)
+	require.True(t, resp.IsError())
+}
+
+func TestTransit_KMAC(t *testing.T) {
+	b, storage := createBackendWithSysView(t)
+
+	doReq := func(path string, data map[string]interface{}) (*logical.Response, error) {
+		return b.HandleRequest(context.Background(), &logical.Request{
+			Storage:   storage,
+			Operation: logical.UpdateOperation,
+			Path:      path,
+			Data:      data,
+		})
+	}
+
+	_, err := doReq("keys/hmac", map[string]interface{}{"type": "hmac", "key_size": 32})
+	require.NoError(t, err)
+
+	// Set the key to the one of the NIST SP 800-185 samples
+	p, _, err := b.GetPolicy(context.Background(), keysutil.PolicyRequest{
+		Storage: storage,
+		Name:    "hmac",
+	}, b.GetRandomReader())
+	require.NoError(t, err)
+	latestVersion := strconv.Itoa(p.LatestVersion)
+	keyEntry := p.Keys[latestVersion]
+	keyEntry.Key, _ = hex.DecodeString("404142434445464748494a4b4c4d4e4f505152535455565758595a5b5c5d5e5f")
+	p.Keys[latestVersion] = keyEntry
+	require.NoError(t, p.Persist(context.Background(), storage))
+
+	input := base64.StdEncoding.EncodeToString([]byte{0, 1, 2, 3})
+	customization := base64.StdEncoding.EncodeToString([]byte("My Tagged Application"))
+	expected, _ := hex.DecodeString("3b1fba963cd8b0b59e8c1a6d71888b7143651af8ba0a7070c0979e2811324aa5")
+
+	resp, err := doReq("kmac/hmac/kmac128", map[string]interface{}{
+		"input":         input,
+		"customization": customization,
+	})
+	require.NoError(t, err)
+	require.Equal(t, "vault:v1:"+base64.StdEncoding.EncodeToString(expected), resp.Data["kmac"])
+	kmac := resp.Data["kmac"].(string)
+
+	resp, err = doReq("verify/hmac", map[string]interface{}{
+		"input":          input,
+		"kmac":           kmac,
+		"kmac_algorithm": "kmac128",
+		"customization":  customization,
+	})
+	require.NoError(t, err)
+	require.Equal(t, true, resp.Data["valid"])
+
+	// The customization string and algorithm must match
+	resp, err = doReq("verify/hmac", map[string]interface{}{
+		"input":          input,
+		"kmac":           kmac,
+		"kmac_algorithm": "kmac128",
+	})
+	require.NoError(t, err)
+	require.Equal(t, false, resp.Data["valid"])
+
+	resp, err = doReq("verify/hmac", map[string]interface{}{
+		"input":         input,
+		"kmac":          kmac,
+		"customization": customization,
+	})
+	require.NoError(t, err)
+	require.Equal(t, false, resp.Data["valid"])
+
+	// The length of the output is configurable, and verified as given
+	resp, err = doReq("kmac/hmac", map[string]interface{}{
+		"input":  input,
+		"length": 64,
+	})
+	require.NoError(t, err)
+	kmac = resp.Data["kmac"].(string)
+	resp, err = doReq("verify/hmac", map[string]interface{}{
+		"input": input,
+		"kmac":  kmac,
+	})
+	require.NoError(t, err)
+	require.Equal(t, true, resp.Data["valid"])
+
+	resp, err = doReq("kmac/hmac", map[string]interface{}{
+		"input":  input,
+		"length": 8,
+	})
+	require.ErrorIs(t, err, logical.ErrInvalidRequest)
+	require.True(t, resp.IsError())
+
+	resp, err = doReq("kmac/hmac/sha2-256", map[string]interface{}{
+		"input": input,
+	})
+	require.ErrorIs(t, err, logical.ErrInvalidRequest)
+	require.True(t, resp.IsError())
+}
@@ -121,53 +121,76 @@ func (b *backend) pathDatakeyWrite(ctx context.Context, req *logical.Request, d
 	if p == nil {
 		return logical.ErrorResponse("encryption key not found"), logical.ErrInvalidRequest
 	}
-	if !b.System().CachingDisabled() {
-		p.Lock(false)
+	defer b.trackKeyUsage(ctx, req.Storage, p)
+	if err := b.lockKeyForOperations(ctx, req.Storage, p, 1); err != nil {
+		return nil, err
 	}
 	defer p.Unlock()
 
-	newKey := make([]byte, 32)
 	bits := d.Get("bits").(int)
-	switch bits {
-	case 512:
-		newKey = make([]byte, 64)
-	case 256:
-	case 128:
-		newKey = make([]byte, 16)
-	default:
-		return logical.ErrorResponse("invalid bit length"), logical.ErrInvalidRequest
-	}
-	_, err = rand.Read(newKey)
-	if err != nil {
-		return nil, err
-	}
 
-	var managedKeyFactory ManagedKeyFactory
-	if p.Type == keysutil.KeyType_MANAGED_KEY {
-		managedKeySystemView, ok := b.System().(logical.ManagedKeySystemView)
-		if !ok {
-			return nil, errors.New("unsupported system view")
+	var ciphertext string
+	var newKey []byte
+	if p.Type.KeyEncapsulationSupported() {
+		// Key encapsulation keys establish the data key themselves rather than
+		// encrypting a randomly generated one; the data key is the shared
+		// secret, which is always 256 bits long.
+		if bits != 256 {
+			return logical.ErrorResponse("invalid bit length: key encapsulation keys only support 256 bits"), logical.ErrInvalidRequest
 		}
 
-		managedKeyFactory = ManagedKeyFactory{
-			managedKeyParams: keysutil.ManagedKeyParameters{
-				ManagedKeySystemView: managedKeySystemView,
-				BackendUUID:          b.backendUUID,
-				Context:              ctx,
-			},
+		ciphertext, newKey, err = p.Encapsulate(ver)
+		if err != nil {
+			switch err.(type) {
+			case errutil.UserError:
+				return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+			default:
+				return nil, err
+			}
 		}
-	}
-
-	ciphertext, err := p.EncryptWithFactory(ver, context, nonce, base64.StdEncoding.EncodeToString(newKey), nil, managedKeyFactory)
-	if err != nil {
-		switch err.(type) {
-		case errutil.UserError:
-			return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
-		case errutil.InternalError:
-			return nil, err
+	} else {
+		newKey = make([]byte, 32)
+		switch bits {
+		case 512:
+			newKey = make([]byte, 64)
+		case 256:
+		case 128:
+			newKey = make([]byte, 16)
 		default:
+			return logical.ErrorResponse("invalid bit length"), logical.ErrInvalidRequest
+		}
+		_, err = rand.Read(newKey)
+		if err != nil {
 			return nil, err
 		}
+
+		var managedKeyFactory ManagedKeyFactory
+		if p.Type == keysutil.KeyType_MANAGED_KEY {
+			managedKeySystemView, ok := b.System().(logical.ManagedKeySystemView)
+			if !ok {
+				return nil, errors.New("unsupported system view")
+			}
+
+			managedKeyFactory = ManagedKeyFactory{
+				managedKeyParams: keysutil.ManagedKeyParameters{
+					ManagedKeySystemView: managedKeySystemView,
+					BackendUUID:          b.backendUUID,
+					Context:              ctx,
+				},
+			}
+		}
+
+		ciphertext, err = p.EncryptWithFactory(ver, context, nonce, base64.StdEncoding.EncodeToString(newKey), nil, managedKeyFactory)
+		if err != nil {
+			switch err.(type) {
+			case errutil.UserError:
+				return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+			case errutil.InternalError:
+				return nil, err
+			default:
+				return nil, err
+			}
+		}
 	}
 
 	if ciphertext == "" {
@@ -212,4 +235,9 @@ or 512 bits can be specified; if not specified, the default
 is 256 bits. Call with the the "wrapped" path to prevent the
 (base64-encoded) plaintext key from being returned along with
 the encrypted key, the "plaintext" path returns both.
+
+When the named key is an ML-KEM key encapsulation key, the
+data key is a 256-bit shared secret encapsulated to the key's
+public key. The ciphertext is recovered through the decrypt
+endpoint, just like other data keys.
 `
@@ -81,7 +81,8 @@ When using an AEAD cipher mode, such as AES-GCM, this parameter allows
 passing associated data (AD/AAD) into the encryption function; this data
 must be passed on subsequent decryption requests but can be transited in
 plaintext. On successful decryption, both the ciphertext and the associated
-data are attested not to have been tampered with.
+data are attested not to have been tampered with. For format-preserving
+encryption keys, the associated data is used as the tweak.
                 `,
 			},
 
@@ -166,7 +166,8 @@ When using an AEAD cipher mode, such as AES-GCM, this parameter allows
 passing associated data (AD/AAD) into the encryption function; this data
 must be passed on subsequent decryption requests but can be transited in
 plaintext. On successful decryption, both the ciphertext and the associated
-data are attested not to have been tampered with.
+data are attested not to have been tampered with. For format-preserving
+encryption keys, the associated data is used as the tweak.
 				`,
 			},
 
@@ -456,8 +457,9 @@ func (b *backend) pathEncryptWrite(ctx context.Context, req *logical.Request, d
 	if p == nil {
 		return logical.ErrorResponse("encryption key not found"), logical.ErrInvalidRequest
 	}
-	if !b.System().CachingDisabled() {
-		p.Lock(false)
+	defer b.trackKeyUsage(ctx, req.Storage, p)
+	if err := b.lockKeyForOperations(ctx, req.Storage, p, len(batchInputItems)); err != nil {
+		return nil, err
 	}
 	defer p.Unlock()
 
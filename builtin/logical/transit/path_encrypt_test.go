@@ -5,10 +5,12 @@ package transit
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"reflect"
+	"regexp"
 	"strings"
 	"testing"
 
@@ -1065,3 +1067,136 @@ func TestTransit_EncryptWithRSAPublicKey(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestTransit_FormatPreservingEncryption(t *testing.T) {
+	b, s := createBackendWithStorage(t)
+
+	doReq := func(path string, data map[string]interface{}) (*logical.Response, error) {
+		return b.HandleRequest(context.Background(), &logical.Request{
+			Storage:   s,
+			Operation: logical.UpdateOperation,
+			Path:      path,
+			Data:      data,
+		})
+	}
+
+	for _, keyType := range []string{"aes256-ff1", "aes256-ff3-1"} {
+		resp, err := doReq("keys/"+keyType, map[string]interface{}{
+			"type":     keyType,
+			"alphabet": "numeric",
+			"template": `(?P<version>\d{2})(\d{4})-(\d{4})-(\d{4})-(\d{4})`,
+		})
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("%s: bad: err: %v, resp: %#v", keyType, err, resp)
+		}
+		if resp.Data["alphabet"] != "0123456789" || resp.Data["supports_encryption"] != true {
+			t.Fatalf("%s: bad: %#v", keyType, resp.Data)
+		}
+
+		plaintext := base64.StdEncoding.EncodeToString([]byte("4111-1111-1111-1111"))
+		tweak := base64.StdEncoding.EncodeToString([]byte("1234567"))
+		resp, err = doReq("encrypt/"+keyType, map[string]interface{}{
+			"plaintext":       plaintext,
+			"associated_data": tweak,
+		})
+		if err != nil || resp.IsError() {
+			t.Fatalf("%s: bad: err: %v, resp: %#v", keyType, err, resp)
+		}
+		// The key version is held by the version group of the template
+		ciphertext := resp.Data["ciphertext"].(string)
+		if !regexp.MustCompile(`^01\d{4}-\d{4}-\d{4}-\d{4}$`).MatchString(ciphertext) {
+			t.Fatalf("%s: unexpected ciphertext %q", keyType, ciphertext)
+		}
+		if resp.Data["key_version"] != 1 {
+			t.Fatalf("%s: unexpected key version %v", keyType, resp.Data["key_version"])
+		}
+
+		resp, err = doReq("keys/"+keyType+"/rotate", nil)
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("%s: bad: err: %v, resp: %#v", keyType, err, resp)
+		}
+
+		resp, err = doReq("rewrap/"+keyType, map[string]interface{}{
+			"ciphertext":      ciphertext,
+			"associated_data": tweak,
+		})
+		if err != nil || resp.IsError() {
+			t.Fatalf("%s: bad: err: %v, resp: %#v", keyType, err, resp)
+		}
+		rewrapped := resp.Data["ciphertext"].(string)
+		if !regexp.MustCompile(`^02\d{4}-\d{4}-\d{4}-\d{4}$`).MatchString(rewrapped) {
+			t.Fatalf("%s: unexpected ciphertext %q", keyType, rewrapped)
+		}
+		if resp.Data["key_version"] != 2 {
+			t.Fatalf("%s: unexpected key version %v", keyType, resp.Data["key_version"])
+		}
+
+		resp, err = doReq("decrypt/"+keyType, map[string]interface{}{
+			"ciphertext":      rewrapped,
+			"associated_data": tweak,
+		})
+		if err != nil || resp.IsError() {
+			t.Fatalf("%s: bad: err: %v, resp: %#v", keyType, err, resp)
+		}
+		if resp.Data["plaintext"] != plaintext {
+			t.Fatalf("%s: expected %q, got %q", keyType, plaintext, resp.Data["plaintext"])
+		}
+
+		resp, err = doReq("decrypt/"+keyType, map[string]interface{}{
+			"batch_input": []interface{}{
+				map[string]interface{}{"ciphertext": ciphertext, "associated_data": tweak},
+				map[string]interface{}{"ciphertext": rewrapped, "associated_data": tweak},
+			},
+		})
+		if err != nil || resp.IsError() {
+			t.Fatalf("%s: bad: err: %v, resp: %#v", keyType, err, resp)
+		}
+		for _, item := range resp.Data["batch_results"].([]DecryptBatchResponseItem) {
+			if item.Plaintext != plaintext {
+				t.Fatalf("%s: expected %q, got %#v", keyType, plaintext, item)
+			}
+		}
+
+		// Values not matching the template are rejected
+		resp, err = doReq("encrypt/"+keyType, map[string]interface{}{
+			"plaintext": base64.StdEncoding.EncodeToString([]byte("4111111111111111")),
+		})
+		if err == nil || !resp.IsError() {
+			t.Fatalf("%s: expected error, got %#v", keyType, resp)
+		}
+	}
+
+	// An alphabet is required for format-preserving keys, and only valid for them
+	resp, err := doReq("keys/missing-alphabet", map[string]interface{}{
+		"type": "aes256-ff1",
+	})
+	if err == nil || !resp.IsError() {
+		t.Fatalf("expected error, got %#v", resp)
+	}
+	resp, err = doReq("keys/aes", map[string]interface{}{
+		"alphabet": "numeric",
+	})
+	if err == nil || !resp.IsError() {
+		t.Fatalf("expected error, got %#v", resp)
+	}
+
+	// Templates must only encrypt characters of the alphabet
+	resp, err = doReq("keys/hex", map[string]interface{}{
+		"type":     "aes256-ff1",
+		"alphabet": "numeric",
+		"template": `(?P<version>\d{2})([0-9a-f]{8})`,
+	})
+	if err == nil || !resp.IsError() {
+		t.Fatalf("expected error, got %#v", resp)
+	}
+
+	// Templates must have a version group
+	resp, err = doReq("keys/no-version", map[string]interface{}{
+		"type":     "aes256-ff1",
+		"alphabet": "numeric",
+		"template": `(\d{4})-(\d{4})`,
+	})
+	if err == nil || !resp.IsError() {
+		t.Fatalf("expected error, got %#v", resp)
+	}
+}
@@ -97,9 +97,19 @@ func (b *backend) pathPolicyExportRead(ctx context.Context, req *logical.Request
 		return logical.ErrorResponse("private key material is not exportable"), nil
 	}
 
+	if p.ExportApprovalsRequired > 0 && exportType != exportTypePublicKey && exportType != exportTypeCertificateChain {
+		return logical.ErrorResponse(fmt.Sprintf("export of private key material requires approval; create an export request at export-requests/%s", p.Name)), nil
+	}
+
+	return exportPolicyKeys(p, exportType, version)
+}
+
+// exportPolicyKeys returns the key material of the given type for the version
+// of the key, or all versions if empty.
+func exportPolicyKeys(p *keysutil.Policy, exportType string, version string) (*logical.Response, error) {
 	switch exportType {
 	case exportTypeEncryptionKey:
-		if !p.Type.EncryptionSupported() {
+		if !p.Type.EncryptionSupported() && !p.Type.KeyEncapsulationSupported() {
 			return logical.ErrorResponse("encryption not supported for the key"), logical.ErrInvalidRequest
 		}
 	case exportTypeSigningKey:
@@ -128,6 +138,7 @@ func (b *backend) pathPolicyExportRead(ctx context.Context, req *logical.Request
 		if version == "latest" {
 			versionValue = p.LatestVersion
 		} else {
+			var err error
 			version = strings.TrimPrefix(version, "v")
 			versionValue, err = strconv.Atoi(version)
 			if err != nil {
@@ -177,7 +188,7 @@ func getExportKey(policy *keysutil.Policy, key *keysutil.KeyEntry, exportType st
 
 	case exportTypeEncryptionKey:
 		switch policy.Type {
-		case keysutil.KeyType_AES128_GCM96, keysutil.KeyType_AES256_GCM96, keysutil.KeyType_ChaCha20_Poly1305:
+		case keysutil.KeyType_AES128_GCM96, keysutil.KeyType_AES256_GCM96, keysutil.KeyType_ChaCha20_Poly1305, keysutil.KeyType_FF1_AES256, keysutil.KeyType_FF3_1_AES256:
 			return strings.TrimSpace(base64.StdEncoding.EncodeToString(key.Key)), nil
 
 		case keysutil.KeyType_RSA2048, keysutil.KeyType_RSA3072, keysutil.KeyType_RSA4096:
@@ -186,6 +197,15 @@ func getExportKey(policy *keysutil.Policy, key *keysutil.KeyEntry, exportType st
 				return "", err
 			}
 			return rsaKey, nil
+
+		case keysutil.KeyType_ML_KEM_768, keysutil.KeyType_ML_KEM_1024:
+			// ML-KEM private keys are exported as the 64-byte seed they are
+			// derived from
+			if len(key.Key) == 0 {
+				return "", nil
+			}
+
+			return strings.TrimSpace(base64.StdEncoding.EncodeToString(key.Key)), nil
 		}
 
 	case exportTypeSigningKey:
@@ -219,6 +239,15 @@ func getExportKey(policy *keysutil.Policy, key *keysutil.KeyEntry, exportType st
 				return "", err
 			}
 			return rsaKey, nil
+
+		case keysutil.KeyType_ML_DSA_44, keysutil.KeyType_ML_DSA_65, keysutil.KeyType_ML_DSA_87, keysutil.KeyType_HYBRID_ED25519_ML_DSA_65:
+			// ML-DSA private keys are exported as the 32-byte seed they are
+			// derived from, preceded by the Ed25519 private key for hybrid keys
+			if len(key.Key) == 0 {
+				return "", nil
+			}
+
+			return strings.TrimSpace(base64.StdEncoding.EncodeToString(key.Key)), nil
 		}
 	case exportTypePublicKey:
 		switch policy.Type {
@@ -238,7 +267,8 @@ func getExportKey(policy *keysutil.Policy, key *keysutil.KeyEntry, exportType st
 			}
 			return ecKey, nil
 
-		case keysutil.KeyType_ED25519:
+		case keysutil.KeyType_ED25519, keysutil.KeyType_ML_DSA_44, keysutil.KeyType_ML_DSA_65, keysutil.KeyType_ML_DSA_87,
+			keysutil.KeyType_ML_KEM_768, keysutil.KeyType_ML_KEM_1024, keysutil.KeyType_HYBRID_ED25519_ML_DSA_65:
 			return strings.TrimSpace(key.FormattedPublicKey), nil
 
 		case keysutil.KeyType_RSA2048, keysutil.KeyType_RSA3072, keysutil.KeyType_RSA4096:
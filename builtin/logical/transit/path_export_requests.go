@@ -0,0 +1,792 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package transit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-secure-stdlib/strutil"
+	uuid "github.com/hashicorp/go-uuid"
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/helper/keysutil"
+	"github.com/hashicorp/vault/sdk/helper/wrapping"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+const (
+	exportRequestPrefix = "export-request/"
+
+	// Export request types in addition to the export types, for keys
+	// exported with byok-export and backup respectively
+	exportRequestTypeBYOK   = "byok"
+	exportRequestTypeBackup = "backup"
+
+	exportRequestStatusPending  = "pending"
+	exportRequestStatusApproved = "approved"
+	exportRequestStatusExpired  = "expired"
+
+	defaultExportRequestTTL = 24 * time.Hour
+
+	// exportReleaseWrapTTL is the TTL of the wrapping token the released key
+	// material is returned in, unless the client requests a shorter one
+	exportReleaseWrapTTL = 5 * time.Minute
+)
+
+// exportRequest is a request to export key material which requires the
+// approval of other entities before it is released.
+type exportRequest struct {
+	ID                     string           `json:"id"`
+	Name                   string           `json:"name"`
+	ExportType             string           `json:"export_type"`
+	Version                string           `json:"version"`
+	Destination            string           `json:"destination,omitempty"`
+	Hash                   string           `json:"hash,omitempty"`
+	Format                 string           `json:"format,omitempty"`
+	OAEPLabel              string           `json:"oaep_label,omitempty"`
+	RequesterEntityID      string           `json:"requester_entity_id"`
+	RequesterTokenAccessor string           `json:"requester_token_accessor"`
+	CreationTime           time.Time        `json:"creation_time"`
+	ExpirationTime         time.Time        `json:"expiration_time"`
+	Approvals              []exportApproval `json:"approvals"`
+}
+
+type exportApproval struct {
+	EntityID string    `json:"entity_id"`
+	Time     time.Time `json:"time"`
+}
+
+// byokExportOptions returns the wrapping parameters of byok export requests;
+// requests created before the format could be chosen use the wrapped format.
+func (r *exportRequest) byokExportOptions() byokExportOptions {
+	opts := byokExportOptions{
+		Hash:      r.Hash,
+		Format:    r.Format,
+		OAEPLabel: r.OAEPLabel,
+	}
+	if opts.Format == "" {
+		opts.Format = importFormatWrapped
+	}
+	return opts
+}
+
+func (r *exportRequest) status(approvalsRequired int) string {
+	switch {
+	case time.Now().After(r.ExpirationTime):
+		return exportRequestStatusExpired
+	case len(r.Approvals) >= approvalsRequired:
+		return exportRequestStatusApproved
+	default:
+		return exportRequestStatusPending
+	}
+}
+
+// isRequester returns whether the request was made by the creator of the
+// export request; by the same entity, or if it had none the same token.
+func (r *exportRequest) isRequester(req *logical.Request) bool {
+	if r.RequesterEntityID != "" {
+		return req.EntityID == r.RequesterEntityID
+	}
+	return req.ClientTokenAccessor != "" && req.ClientTokenAccessor == r.RequesterTokenAccessor
+}
+
+func (b *backend) pathExportRequests() *framework.Path {
+	return &framework.Path{
+		Pattern: "export-requests/" + framework.GenericNameRegex("name") + "/?$",
+
+		DisplayAttrs: &framework.DisplayAttributes{
+			OperationPrefix: operationPrefixTransit,
+			OperationSuffix: "export-request|export-requests",
+		},
+
+		Fields: map[string]*framework.FieldSchema{
+			"name": {
+				Type:        framework.TypeString,
+				Description: "Name of the key",
+			},
+			"type": {
+				Type:        framework.TypeString,
+				Description: "Type of export to request (encryption-key, signing-key, hmac-key, byok, backup)",
+			},
+			"version": {
+				Type:        framework.TypeString,
+				Description: "Optional version of the key to export, else all key versions are exported. Not supported for backups.",
+			},
+			"destination": {
+				Type:        framework.TypeString,
+				Description: "Destination key to wrap the key material with, for byok exports.",
+			},
+			"hash": {
+				Type:        framework.TypeString,
+				Description: "Hash function to use for inner OAEP encryption, for byok exports. Defaults to SHA256.",
+				Default:     "SHA256",
+			},
+			"oaep_label": {
+				Type:        framework.TypeString,
+				Description: "Base64-encoded label to use for inner OAEP encryption, for byok exports. Defaults to an empty label.",
+			},
+			"format": {
+				Type:        framework.TypeString,
+				Description: `Format of the exported keys, for byok exports: "wrapped" (default) or "jwe".`,
+				Default:     importFormatWrapped,
+			},
+			"ttl": {
+				Type:        framework.TypeDurationSecond,
+				Description: "Amount of time the request may be approved and released within. Defaults to 24 hours.",
+			},
+		},
+
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.UpdateOperation: &framework.PathOperation{
+				Callback: b.pathExportRequestCreate,
+				DisplayAttrs: &framework.DisplayAttributes{
+					OperationVerb: "create",
+				},
+			},
+			logical.ListOperation: &framework.PathOperation{
+				Callback: b.pathExportRequestList,
+				DisplayAttrs: &framework.DisplayAttributes{
+					OperationVerb: "list",
+				},
+			},
+		},
+
+		HelpSynopsis:    pathExportRequestsHelpSyn,
+		HelpDescription: pathExportRequestsHelpDesc,
+	}
+}
+
+func (b *backend) pathExportRequest() *framework.Path {
+	return &framework.Path{
+		Pattern: "export-requests/" + framework.GenericNameRegex("name") + "/" + framework.GenericNameRegex("id") + "$",
+
+		DisplayAttrs: &framework.DisplayAttributes{
+			OperationPrefix: operationPrefixTransit,
+			OperationSuffix: "export-request",
+		},
+
+		Fields: map[string]*framework.FieldSchema{
+			"name": {
+				Type:        framework.TypeString,
+				Description: "Name of the key",
+			},
+			"id": {
+				Type:        framework.TypeString,
+				Description: "ID of the export request",
+			},
+		},
+
+		Operations: map[logical.Operation]framework.OperationHandler{
+			logical.ReadOperation: &framework.PathOperation{
+				Callback: b.pathExportRequestRead,
+				DisplayAttrs: &framework.DisplayAttributes{
+					OperationVerb: "read",
+				},
+			},
+			logical.DeleteOperation: &framework.PathOperation{
+				Callback: b.pathExportRequestDelete,
+				DisplayAttrs: &framework.DisplayAttributes{
+					OperationVerb: "delete",
+				},
+			},
+		},
+
+		HelpSynopsis:    pathExportRequestHelpSyn,
+		HelpDescription: pathExportRequestHelpDesc,
+	}
+}
+
+func (b *backend) pathExportRequestApprove() *framework.Path {
+	return &framework.Path{
+		Pattern: "export-requests/" + framework.GenericNameRegex("name") + "/" + framework.GenericNameRegex("id") + "/approve",
+
+		DisplayAttrs: &framework.DisplayAttributes{
+			OperationPrefix: operationPrefixTransit,
+			OperationVerb:   "approve",
+			OperationSuffix: "export-request",
+		},
+
+		Fields: map[string]*framework.FieldSchema{
+			"name": {
+				Type:        framework.TypeString,
+				Description: "Name of the key",
+			},
+			"id": {
+				Type:        framework.TypeString,
+				Description: "ID of the export request",
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathExportRequestApproveWrite,
+		},
+
+		HelpSynopsis:    pathExportRequestApproveHelpSyn,
+		HelpDescription: pathExportRequestApproveHelpDesc,
+	}
+}
+
+func (b *backend) pathExportRequestRelease() *framework.Path {
+	return &framework.Path{
+		Pattern: "export-requests/" + framework.GenericNameRegex("name") + "/" + framework.GenericNameRegex("id") + "/release",
+
+		DisplayAttrs: &framework.DisplayAttributes{
+			OperationPrefix: operationPrefixTransit,
+			OperationVerb:   "release",
+			OperationSuffix: "export-request",
+		},
+
+		Fields: map[string]*framework.FieldSchema{
+			"name": {
+				Type:        framework.TypeString,
+				Description: "Name of the key",
+			},
+			"id": {
+				Type:        framework.TypeString,
+				Description: "ID of the export request",
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathExportRequestReleaseWrite,
+		},
+
+		HelpSynopsis:    pathExportRequestReleaseHelpSyn,
+		HelpDescription: pathExportRequestReleaseHelpDesc,
+	}
+}
+
+func (b *backend) pathExportRequestCreate(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("name").(string)
+	exportType := d.Get("type").(string)
+	version := d.Get("version").(string)
+	destination := d.Get("destination").(string)
+	byokOpts := byokExportOptions{
+		Hash:      d.Get("hash").(string),
+		Format:    d.Get("format").(string),
+		OAEPLabel: d.Get("oaep_label").(string),
+	}
+
+	ttl := defaultExportRequestTTL
+	if ttlRaw, ok := d.GetOk("ttl"); ok {
+		ttl = time.Duration(ttlRaw.(int)) * time.Second
+	}
+	if ttl <= 0 {
+		return logical.ErrorResponse("ttl must be greater than 0"), logical.ErrInvalidRequest
+	}
+
+	if exportType != exportRequestTypeBYOK {
+		destination = ""
+		byokOpts = byokExportOptions{}
+	}
+
+	p, _, err := b.GetPolicy(ctx, keysutil.PolicyRequest{
+		Storage: req.Storage,
+		Name:    name,
+	}, b.GetRandomReader())
+	if err != nil {
+		return nil, err
+	}
+	if p == nil {
+		return logical.ErrorResponse(fmt.Sprintf("no existing key named %s could be found", name)), logical.ErrInvalidRequest
+	}
+	if !b.System().CachingDisabled() {
+		p.Lock(false)
+	}
+	defer p.Unlock()
+
+	if !p.Exportable {
+		return logical.ErrorResponse("private key material is not exportable"), nil
+	}
+	if p.ExportApprovalsRequired == 0 {
+		return logical.ErrorResponse("export of the key does not require approval"), nil
+	}
+
+	switch exportType {
+	case exportTypeEncryptionKey:
+		if !p.Type.EncryptionSupported() && !p.Type.KeyEncapsulationSupported() {
+			return logical.ErrorResponse("encryption not supported for the key"), logical.ErrInvalidRequest
+		}
+	case exportTypeSigningKey:
+		if !p.Type.SigningSupported() {
+			return logical.ErrorResponse("signing not supported for the key"), logical.ErrInvalidRequest
+		}
+	case exportTypeHMACKey:
+	case exportRequestTypeBYOK:
+		if destination == "" {
+			return logical.ErrorResponse("destination is required for byok exports"), logical.ErrInvalidRequest
+		}
+		if err := byokOpts.validate(); err != nil {
+			return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+		}
+	case exportRequestTypeBackup:
+		if !p.AllowPlaintextBackup {
+			return logical.ErrorResponse("plaintext backup is disallowed on the policy"), nil
+		}
+		if version != "" {
+			return logical.ErrorResponse("backups always include all key versions"), logical.ErrInvalidRequest
+		}
+	default:
+		return logical.ErrorResponse(fmt.Sprintf("invalid export type: %s", exportType)), logical.ErrInvalidRequest
+	}
+
+	version, err = exportRequestVersion(p, version)
+	if err != nil {
+		return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+	}
+
+	id, err := uuid.GenerateUUID()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	exportReq := &exportRequest{
+		ID:                     id,
+		Name:                   name,
+		ExportType:             exportType,
+		Version:                version,
+		Destination:            destination,
+		Hash:                   byokOpts.Hash,
+		Format:                 byokOpts.Format,
+		OAEPLabel:              byokOpts.OAEPLabel,
+		RequesterEntityID:      req.EntityID,
+		RequesterTokenAccessor: req.ClientTokenAccessor,
+		CreationTime:           now,
+		ExpirationTime:         now.Add(ttl),
+	}
+
+	b.exportRequestLock.Lock()
+	defer b.exportRequestLock.Unlock()
+
+	if err := b.pruneExportRequests(ctx, req.Storage, name); err != nil {
+		return nil, err
+	}
+	if err := putExportRequest(ctx, req.Storage, exportReq); err != nil {
+		return nil, err
+	}
+
+	b.Logger().Info("export request created", "key", name, "id", id, "type", exportType, "entity_id", req.EntityID)
+
+	return formatExportRequest(exportReq, p.ExportApprovalsRequired), nil
+}
+
+func (b *backend) pathExportRequestList(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	ids, err := req.Storage.List(ctx, exportRequestPrefix+d.Get("name").(string)+"/")
+	if err != nil {
+		return nil, err
+	}
+
+	return logical.ListResponse(ids), nil
+}
+
+func (b *backend) pathExportRequestRead(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	exportReq, err := getExportRequest(ctx, req.Storage, d.Get("name").(string), d.Get("id").(string))
+	if err != nil {
+		return nil, err
+	}
+	if exportReq == nil {
+		return nil, nil
+	}
+
+	approvalsRequired, err := b.exportApprovalsRequired(ctx, req.Storage, exportReq.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	return formatExportRequest(exportReq, approvalsRequired), nil
+}
+
+func (b *backend) pathExportRequestDelete(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("name").(string)
+	id := d.Get("id").(string)
+
+	b.exportRequestLock.Lock()
+	defer b.exportRequestLock.Unlock()
+
+	if err := req.Storage.Delete(ctx, exportRequestPrefix+name+"/"+id); err != nil {
+		return nil, err
+	}
+
+	b.Logger().Info("export request deleted", "key", name, "id", id, "entity_id", req.EntityID)
+
+	return nil, nil
+}
+
+func (b *backend) pathExportRequestApproveWrite(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("name").(string)
+	id := d.Get("id").(string)
+
+	// Approvals are counted per entity, so each approval is by a distinct
+	// identity regardless of how many tokens it holds
+	if req.EntityID == "" {
+		return logical.ErrorResponse("approving an export request requires a token with an identity entity"), logical.ErrPermissionDenied
+	}
+
+	b.exportRequestLock.Lock()
+	defer b.exportRequestLock.Unlock()
+
+	exportReq, err := getExportRequest(ctx, req.Storage, name, id)
+	if err != nil {
+		return nil, err
+	}
+	if exportReq == nil {
+		return logical.ErrorResponse("export request not found"), logical.ErrInvalidRequest
+	}
+
+	approvalsRequired, approverPolicy, err := b.exportApprovalConfig(ctx, req.Storage, name)
+	if err != nil {
+		return nil, err
+	}
+
+	if exportReq.status(approvalsRequired) == exportRequestStatusExpired {
+		return logical.ErrorResponse("export request has expired"), logical.ErrInvalidRequest
+	}
+	if exportReq.isRequester(req) {
+		return logical.ErrorResponse("export requests can not be approved by their requester"), logical.ErrPermissionDenied
+	}
+	for _, approval := range exportReq.Approvals {
+		if approval.EntityID == req.EntityID {
+			return logical.ErrorResponse("export request has already been approved by this entity"), logical.ErrInvalidRequest
+		}
+	}
+	if resp, err := b.checkExportApprover(ctx, req, approverPolicy); resp != nil || err != nil {
+		return resp, err
+	}
+
+	exportReq.Approvals = append(exportReq.Approvals, exportApproval{
+		EntityID: req.EntityID,
+		Time:     time.Now(),
+	})
+	if err := putExportRequest(ctx, req.Storage, exportReq); err != nil {
+		return nil, err
+	}
+
+	b.Logger().Info("export request approved", "key", name, "id", id, "entity_id", req.EntityID, "approvals", len(exportReq.Approvals), "approvals_required", approvalsRequired)
+
+	return formatExportRequest(exportReq, approvalsRequired), nil
+}
+
+func (b *backend) pathExportRequestReleaseWrite(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	name := d.Get("name").(string)
+	id := d.Get("id").(string)
+
+	b.exportRequestLock.Lock()
+	defer b.exportRequestLock.Unlock()
+
+	exportReq, err := getExportRequest(ctx, req.Storage, name, id)
+	if err != nil {
+		return nil, err
+	}
+	if exportReq == nil {
+		return logical.ErrorResponse("export request not found"), logical.ErrInvalidRequest
+	}
+	if !exportReq.isRequester(req) {
+		return logical.ErrorResponse("export requests can only be released by their requester"), logical.ErrPermissionDenied
+	}
+
+	approvalsRequired, err := b.exportApprovalsRequired(ctx, req.Storage, name)
+	if err != nil {
+		return nil, err
+	}
+
+	switch exportReq.status(approvalsRequired) {
+	case exportRequestStatusExpired:
+		return logical.ErrorResponse("export request has expired"), logical.ErrInvalidRequest
+	case exportRequestStatusPending:
+		return logical.ErrorResponse(fmt.Sprintf("export request has %d of the %d required approvals", len(exportReq.Approvals), approvalsRequired)), logical.ErrPermissionDenied
+	}
+
+	resp, err := b.releaseExportRequest(ctx, req.Storage, exportReq)
+	if err != nil || resp.IsError() {
+		return resp, err
+	}
+
+	// Requests can only be released once
+	if err := req.Storage.Delete(ctx, exportRequestPrefix+name+"/"+id); err != nil {
+		return nil, err
+	}
+
+	b.Logger().Info("export request released", "key", name, "id", id, "entity_id", req.EntityID)
+
+	// The key material is only ever returned response wrapped
+	resp.WrapInfo = &wrapping.ResponseWrapInfo{
+		TTL: exportReleaseWrapTTL,
+	}
+
+	return resp, nil
+}
+
+// releaseExportRequest returns the key material of an approved export
+// request.
+func (b *backend) releaseExportRequest(ctx context.Context, storage logical.Storage, exportReq *exportRequest) (*logical.Response, error) {
+	// Backups take their own lock on the policy
+	if exportReq.ExportType == exportRequestTypeBackup {
+		backup, err := b.lm.BackupPolicy(ctx, storage, exportReq.Name)
+		if err != nil {
+			return nil, err
+		}
+
+		return &logical.Response{
+			Data: map[string]interface{}{
+				"backup": backup,
+			},
+		}, nil
+	}
+
+	p, _, err := b.GetPolicy(ctx, keysutil.PolicyRequest{
+		Storage: storage,
+		Name:    exportReq.Name,
+	}, b.GetRandomReader())
+	if err != nil {
+		return nil, err
+	}
+	if p == nil {
+		return logical.ErrorResponse(fmt.Sprintf("no existing key named %s could be found", exportReq.Name)), logical.ErrInvalidRequest
+	}
+	if !b.System().CachingDisabled() {
+		p.Lock(false)
+	}
+	defer p.Unlock()
+
+	if !p.Exportable {
+		return logical.ErrorResponse("private key material is not exportable"), nil
+	}
+
+	if exportReq.ExportType != exportRequestTypeBYOK {
+		return exportPolicyKeys(p, exportReq.ExportType, exportReq.Version)
+	}
+
+	dstP, _, err := b.GetPolicy(ctx, keysutil.PolicyRequest{
+		Storage: storage,
+		Name:    exportReq.Destination,
+	}, b.GetRandomReader())
+	if err != nil {
+		return nil, err
+	}
+	if dstP == nil {
+		return logical.ErrorResponse("no such destination key to export to"), logical.ErrInvalidRequest
+	}
+	if !b.System().CachingDisabled() {
+		dstP.Lock(false)
+	}
+	defer dstP.Unlock()
+
+	return exportBYOKPolicyKeys(dstP, p, exportReq.Version, exportReq.byokExportOptions())
+}
+
+// exportApprovalsRequired returns the number of approvals currently required
+// to export the named key.
+func (b *backend) exportApprovalsRequired(ctx context.Context, storage logical.Storage, name string) (int, error) {
+	approvalsRequired, _, err := b.exportApprovalConfig(ctx, storage, name)
+	return approvalsRequired, err
+}
+
+// exportApprovalConfig returns the number of approvals currently required to
+// export the named key, and the policy its approvers must have.
+func (b *backend) exportApprovalConfig(ctx context.Context, storage logical.Storage, name string) (int, string, error) {
+	p, _, err := b.GetPolicy(ctx, keysutil.PolicyRequest{
+		Storage: storage,
+		Name:    name,
+	}, b.GetRandomReader())
+	if err != nil {
+		return 0, "", err
+	}
+	if p == nil {
+		return 0, "", fmt.Errorf("key %q not found", name)
+	}
+	if !b.System().CachingDisabled() {
+		p.Lock(false)
+	}
+	defer p.Unlock()
+
+	return p.ExportApprovalsRequired, p.ExportApproverPolicy, nil
+}
+
+// checkExportApprover returns an error response unless the token of the
+// request has the policy designated for approvers of the key.
+func (b *backend) checkExportApprover(ctx context.Context, req *logical.Request, approverPolicy string) (*logical.Response, error) {
+	if approverPolicy == "" {
+		return logical.ErrorResponse("the key has no export approver policy configured"), logical.ErrInvalidRequest
+	}
+
+	policyView, ok := b.System().(logical.TokenPolicySystemView)
+	if !ok {
+		return nil, fmt.Errorf("the policies of export request approvers can not be checked")
+	}
+	if req.ClientTokenAccessor == "" {
+		return logical.ErrorResponse("approving an export request requires a token with an accessor"), logical.ErrPermissionDenied
+	}
+
+	policies, err := policyView.TokenPolicies(ctx, req.ClientTokenAccessor)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up the policies of the approver: %w", err)
+	}
+	if !strutil.StrListContains(policies, approverPolicy) {
+		return logical.ErrorResponse(fmt.Sprintf("approving export requests of the key requires the %q policy", approverPolicy)), logical.ErrPermissionDenied
+	}
+
+	return nil, nil
+}
+
+// exportRequestVersion validates the version of the key requested for
+// export. The latest version is resolved, so that approvers approve the
+// export of a fixed version.
+func exportRequestVersion(p *keysutil.Policy, version string) (string, error) {
+	if version == "" {
+		return "", nil
+	}
+
+	versionValue := p.LatestVersion
+	if version != "latest" {
+		var err error
+		versionValue, err = strconv.Atoi(strings.TrimPrefix(version, "v"))
+		if err != nil {
+			return "", errors.New("invalid key version")
+		}
+	}
+
+	if versionValue < p.MinDecryptionVersion {
+		return "", errors.New("version for export is below minimum decryption version")
+	}
+	if _, ok := p.Keys[strconv.Itoa(versionValue)]; !ok {
+		return "", errors.New("version does not exist or cannot be found")
+	}
+
+	return strconv.Itoa(versionValue), nil
+}
+
+// pruneExportRequests removes the expired export requests of the named key.
+func (b *backend) pruneExportRequests(ctx context.Context, storage logical.Storage, name string) error {
+	ids, err := storage.List(ctx, exportRequestPrefix+name+"/")
+	if err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		exportReq, err := getExportRequest(ctx, storage, name, id)
+		if err != nil {
+			return err
+		}
+		if exportReq != nil && time.Now().After(exportReq.ExpirationTime) {
+			if err := storage.Delete(ctx, exportRequestPrefix+name+"/"+id); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// deleteExportRequests removes all export requests of the named key.
+func deleteExportRequests(ctx context.Context, storage logical.Storage, name string) error {
+	ids, err := storage.List(ctx, exportRequestPrefix+name+"/")
+	if err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		if err := storage.Delete(ctx, exportRequestPrefix+name+"/"+id); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func getExportRequest(ctx context.Context, storage logical.Storage, name string, id string) (*exportRequest, error) {
+	entry, err := storage.Get(ctx, exportRequestPrefix+name+"/"+id)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, nil
+	}
+
+	var exportReq exportRequest
+	if err := entry.DecodeJSON(&exportReq); err != nil {
+		return nil, err
+	}
+
+	return &exportReq, nil
+}
+
+func putExportRequest(ctx context.Context, storage logical.Storage, exportReq *exportRequest) error {
+	entry, err := logical.StorageEntryJSON(exportRequestPrefix+exportReq.Name+"/"+exportReq.ID, exportReq)
+	if err != nil {
+		return err
+	}
+
+	return storage.Put(ctx, entry)
+}
+
+func formatExportRequest(exportReq *exportRequest, approvalsRequired int) *logical.Response {
+	approvals := make([]map[string]interface{}, 0, len(exportReq.Approvals))
+	for _, approval := range exportReq.Approvals {
+		approvals = append(approvals, map[string]interface{}{
+			"entity_id": approval.EntityID,
+			"time":      approval.Time.Format(time.RFC3339Nano),
+		})
+	}
+
+	data := map[string]interface{}{
+		"id":                  exportReq.ID,
+		"name":                exportReq.Name,
+		"type":                exportReq.ExportType,
+		"version":             exportReq.Version,
+		"requester_entity_id": exportReq.RequesterEntityID,
+		"creation_time":       exportReq.CreationTime.Format(time.RFC3339Nano),
+		"expiration_time":     exportReq.ExpirationTime.Format(time.RFC3339Nano),
+		"approvals":           approvals,
+		"approvals_required":  approvalsRequired,
+		"status":              exportReq.status(approvalsRequired),
+	}
+	if exportReq.ExportType == exportRequestTypeBYOK {
+		data["destination"] = exportReq.Destination
+		data["hash"] = exportReq.Hash
+		data["format"] = exportReq.byokExportOptions().Format
+		data["oaep_label"] = exportReq.OAEPLabel
+	}
+
+	return &logical.Response{
+		Data: data,
+	}
+}
+
+const pathExportRequestsHelpSyn = `Request the export of a key which requires approval`
+
+const pathExportRequestsHelpDesc = `
+This path is used to create and list requests to export the named key,
+when it is configured to require approval of exports with the
+export_approvals_required parameter. Once a request has been approved
+by enough other entities, its key material can be released by its
+requester at export-requests/:name/:id/release.
+`
+
+const pathExportRequestHelpSyn = `Read or delete an export request`
+
+const pathExportRequestHelpDesc = `
+This path is used to read the status and approvals of an export
+request, or to delete it.
+`
+
+const pathExportRequestApproveHelpSyn = `Approve an export request`
+
+const pathExportRequestApproveHelpDesc = `
+This path is used to approve an export request. Each entity other
+than the requester may approve a request once, with a token which has
+the policy configured as export_approver_policy on the key.
+`
+
+const pathExportRequestReleaseHelpSyn = `Release the key material of an approved export request`
+
+const pathExportRequestReleaseHelpDesc = `
+This path is used by the requester of an approved export request to
+release its key material, which is always returned response wrapped.
+A request can only be released once.
+`
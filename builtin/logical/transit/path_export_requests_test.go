@@ -0,0 +1,224 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package transit
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+// tokenPolicySystemView resolves the policies of tokens by their accessor.
+type tokenPolicySystemView struct {
+	*logical.StaticSystemView
+	policies map[string][]string
+}
+
+func (s tokenPolicySystemView) TokenPolicies(_ context.Context, accessor string) ([]string, error) {
+	return s.policies[accessor], nil
+}
+
+func TestTransit_ExportRequests(t *testing.T) {
+	storage := &logical.InmemStorage{}
+	conf := &logical.BackendConfig{
+		StorageView: storage,
+		System: tokenPolicySystemView{
+			StaticSystemView: logical.TestSystemView(),
+			policies: map[string][]string{
+				"accessor-approver-1": {"default", "transit-approver"},
+				"accessor-approver-2": {"transit-approver"},
+				"accessor-other":      {"default"},
+			},
+		},
+	}
+	b, _ := Backend(context.Background(), conf)
+	if err := b.Backend.Setup(context.Background(), conf); err != nil {
+		t.Fatal(err)
+	}
+
+	doReq := func(op logical.Operation, path, entityID string, data map[string]interface{}) (*logical.Response, error) {
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Storage:             storage,
+			Operation:           op,
+			Path:                path,
+			Data:                data,
+			EntityID:            entityID,
+			ClientTokenAccessor: "accessor-" + entityID,
+		})
+		if err == nil && resp != nil && resp.IsError() {
+			err = resp.Error()
+		}
+		return resp, err
+	}
+
+	if _, err := doReq(logical.UpdateOperation, "keys/root", "", map[string]interface{}{
+		"exportable":             true,
+		"allow_plaintext_backup": true,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Keys which do not require approval can not have export requests
+	if _, err := doReq(logical.UpdateOperation, "export-requests/root", "requester", map[string]interface{}{
+		"type": "encryption-key",
+	}); err == nil {
+		t.Fatal("expected export request of a key not requiring approval to fail")
+	}
+
+	if _, err := doReq(logical.UpdateOperation, "keys/root/config", "", map[string]interface{}{
+		"export_approvals_required": 2,
+	}); err == nil {
+		t.Fatal("expected requiring export approvals without an approver policy to fail")
+	}
+	if _, err := doReq(logical.UpdateOperation, "keys/root/config", "", map[string]interface{}{
+		"export_approvals_required": 2,
+		"export_approver_policy":    "transit-approver",
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := doReq(logical.UpdateOperation, "keys/root/config", "", map[string]interface{}{
+		"export_approver_policy": "default",
+	}); err == nil {
+		t.Fatal("expected changing the export approver policy to fail")
+	}
+	if _, err := doReq(logical.UpdateOperation, "keys/root/config", "", map[string]interface{}{
+		"export_approvals_required": 1,
+	}); err == nil {
+		t.Fatal("expected lowering the export approvals required to fail")
+	}
+
+	// Key material can no longer be exported directly
+	for _, path := range []string{"export/encryption-key/root", "backup/root"} {
+		if _, err := doReq(logical.ReadOperation, path, "", nil); err == nil {
+			t.Fatalf("expected direct export at %s to fail", path)
+		}
+	}
+
+	// Versions are checked when the request is created
+	for _, version := range []string{"2", "v0", "latest2"} {
+		if _, err := doReq(logical.UpdateOperation, "export-requests/root", "requester", map[string]interface{}{
+			"type":    "encryption-key",
+			"version": version,
+		}); err == nil {
+			t.Fatalf("expected export request of version %q to fail", version)
+		}
+	}
+
+	resp, err := doReq(logical.UpdateOperation, "export-requests/root", "requester", map[string]interface{}{
+		"type":    "encryption-key",
+		"version": "latest",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := resp.Data["id"].(string)
+	if resp.Data["status"] != exportRequestStatusPending || resp.Data["approvals_required"] != 2 || resp.Data["version"] != "1" {
+		t.Fatalf("unexpected export request: %#v", resp.Data)
+	}
+
+	resp, err = doReq(logical.ListOperation, "export-requests/root/", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keys := resp.Data["keys"].([]string); len(keys) != 1 || keys[0] != id {
+		t.Fatalf("unexpected export requests: %v", keys)
+	}
+
+	requestPath := "export-requests/root/" + id
+	if _, err := doReq(logical.UpdateOperation, requestPath+"/release", "requester", nil); err == nil {
+		t.Fatal("expected release of an unapproved export request to fail")
+	}
+	if _, err := doReq(logical.UpdateOperation, requestPath+"/approve", "requester", nil); err == nil {
+		t.Fatal("expected approval by the requester to fail")
+	}
+	if _, err := doReq(logical.UpdateOperation, requestPath+"/approve", "", nil); err == nil {
+		t.Fatal("expected approval without an entity to fail")
+	}
+	if _, err := doReq(logical.UpdateOperation, requestPath+"/approve", "other", nil); err == nil {
+		t.Fatal("expected approval without the approver policy to fail")
+	}
+	if _, err := doReq(logical.UpdateOperation, requestPath+"/approve", "approver-1", nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := doReq(logical.UpdateOperation, requestPath+"/approve", "approver-1", nil); err == nil {
+		t.Fatal("expected a second approval by the same entity to fail")
+	}
+	if _, err := doReq(logical.UpdateOperation, requestPath+"/release", "requester", nil); err == nil {
+		t.Fatal("expected release of a partially approved export request to fail")
+	}
+	if _, err := doReq(logical.UpdateOperation, requestPath+"/approve", "approver-2", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err = doReq(logical.ReadOperation, requestPath, "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Data["status"] != exportRequestStatusApproved || len(resp.Data["approvals"].([]map[string]interface{})) != 2 {
+		t.Fatalf("unexpected export request: %#v", resp.Data)
+	}
+
+	if _, err := doReq(logical.UpdateOperation, requestPath+"/release", "approver-1", nil); err == nil {
+		t.Fatal("expected release by an entity other than the requester to fail")
+	}
+	resp, err = doReq(logical.UpdateOperation, requestPath+"/release", "requester", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.WrapInfo == nil || resp.WrapInfo.TTL != exportReleaseWrapTTL {
+		t.Fatalf("expected the released key material to be response wrapped: %#v", resp.WrapInfo)
+	}
+	if keys := resp.Data["keys"].(map[string]string); len(keys) != 1 || keys["1"] == "" {
+		t.Fatalf("unexpected released keys: %v", keys)
+	}
+
+	// Requests can only be released once
+	if _, err := doReq(logical.UpdateOperation, requestPath+"/release", "requester", nil); err == nil {
+		t.Fatal("expected a second release to fail")
+	}
+
+	// Backups can be requested too
+	resp, err = doReq(logical.UpdateOperation, "export-requests/root", "requester", map[string]interface{}{
+		"type": "backup",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	requestPath = "export-requests/root/" + resp.Data["id"].(string)
+	for _, approver := range []string{"approver-1", "approver-2"} {
+		if _, err := doReq(logical.UpdateOperation, requestPath+"/approve", approver, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	resp, err = doReq(logical.UpdateOperation, requestPath+"/release", "requester", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Data["backup"] == "" {
+		t.Fatal("expected backup to be released")
+	}
+
+	// Deleting the key removes its export requests
+	if _, err := doReq(logical.UpdateOperation, "export-requests/root", "requester", map[string]interface{}{
+		"type": "encryption-key",
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := doReq(logical.UpdateOperation, "keys/root/config", "", map[string]interface{}{
+		"deletion_allowed": true,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := doReq(logical.DeleteOperation, "keys/root", "", nil); err != nil {
+		t.Fatal(err)
+	}
+	resp, err = doReq(logical.ListOperation, "export-requests/root/", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keys, ok := resp.Data["keys"]; ok && len(keys.([]string)) != 0 {
+		t.Fatalf("expected export requests to be deleted, got %v", keys)
+	}
+}
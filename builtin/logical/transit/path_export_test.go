@@ -90,6 +90,15 @@ func TestTransit_Export_KeyVersion_ExportsCorrectVersion(t *testing.T) {
 	verifyExportsCorrectVersion(t, "public-key", "ecdsa-p384")
 	verifyExportsCorrectVersion(t, "public-key", "ecdsa-p521")
 	verifyExportsCorrectVersion(t, "public-key", "ed25519")
+	verifyExportsCorrectVersion(t, "signing-key", "ml-dsa-44")
+	verifyExportsCorrectVersion(t, "signing-key", "ml-dsa-65")
+	verifyExportsCorrectVersion(t, "signing-key", "ml-dsa-87")
+	verifyExportsCorrectVersion(t, "signing-key", "ed25519-ml-dsa-65")
+	verifyExportsCorrectVersion(t, "encryption-key", "ml-kem-768")
+	verifyExportsCorrectVersion(t, "encryption-key", "ml-kem-1024")
+	verifyExportsCorrectVersion(t, "public-key", "ml-dsa-65")
+	verifyExportsCorrectVersion(t, "public-key", "ml-kem-768")
+	verifyExportsCorrectVersion(t, "public-key", "ed25519-ml-dsa-65")
 }
 
 func verifyExportsCorrectVersion(t *testing.T, exportType, keyType string) {
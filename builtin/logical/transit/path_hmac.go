@@ -13,6 +13,7 @@ import (
 	"strings"
 
 	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/helper/errutil"
 	"github.com/hashicorp/vault/sdk/helper/keysutil"
 	"github.com/hashicorp/vault/sdk/logical"
 	"github.com/mitchellh/mapstructure"
@@ -45,6 +46,11 @@ type batchResponseHMACItem struct {
 	Reference string `json:"reference" mapstructure:"reference"`
 }
 
+// macFunc computes a MAC of the given length over the input, with the given
+// version of the key. A length of 0 selects the default length. Errors of type
+// errutil.UserError are returned to the client as invalid requests.
+type macFunc func(p *keysutil.Policy, ver int, input []byte, length int) ([]byte, error)
+
 func (b *backend) pathHMAC() *framework.Path {
 	return &framework.Path{
 		Pattern: "hmac/" + framework.GenericNameRegex("name") + framework.OptionalParamRegex("urlalgorithm"),
@@ -114,14 +120,69 @@ Any batch output will preserve the order of the batch input.`,
 }
 
 func (b *backend) pathHMACWrite(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
-	name := d.Get("name").(string)
-	ver := d.Get("key_version").(int)
+	mac, err := b.hmacFunc(ctx, d)
+	if err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+
+	return b.macWrite(ctx, req, d, "hmac", mac, 0)
+}
+
+func (b *backend) pathHMACVerify(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	mac, err := b.hmacFunc(ctx, d)
+	if err != nil {
+		return logical.ErrorResponse(err.Error()), nil
+	}
+
+	return b.macVerify(ctx, req, d, "hmac", mac)
+}
 
+// hmacFunc returns the macFunc computing HMAC with the requested hash
+// algorithm, keyed with the HMAC key of each key version.
+func (b *backend) hmacFunc(ctx context.Context, d *framework.FieldData) (macFunc, error) {
 	algorithm := d.Get("urlalgorithm").(string)
 	if algorithm == "" {
 		algorithm = d.Get("algorithm").(string)
 	}
 
+	hashAlgorithm, ok := keysutil.HashTypeMap[algorithm]
+	if !ok {
+		return nil, fmt.Errorf("unsupported algorithm %q", algorithm)
+	}
+
+	hashAlg := keysutil.HashFuncMap[hashAlgorithm]
+
+	return func(p *keysutil.Policy, ver int, input []byte, _ int) ([]byte, error) {
+		if p.Type == keysutil.KeyType_MANAGED_KEY {
+			managedKeySystemView, ok := b.System().(logical.ManagedKeySystemView)
+			if !ok {
+				return nil, errors.New("unsupported system view")
+			}
+
+			return p.HMACWithManagedKey(ctx, ver, managedKeySystemView, b.backendUUID, algorithm, input)
+		}
+
+		key, err := p.HMACKey(ver)
+		if err != nil {
+			return nil, errutil.UserError{Err: err.Error()}
+		}
+		if key == nil {
+			return nil, fmt.Errorf("HMAC key value could not be computed")
+		}
+
+		hf := hmac.New(hashAlg, key)
+		hf.Write(input)
+		return hf.Sum(nil), nil
+	}, nil
+}
+
+// macWrite generates MACs of the input or batch input with the named key,
+// following the semantics of HMAC generation. The MACs are returned in the
+// given field of the response.
+func (b *backend) macWrite(ctx context.Context, req *logical.Request, d *framework.FieldData, field string, mac macFunc, length int) (*logical.Response, error) {
+	name := d.Get("name").(string)
+	ver := d.Get("key_version").(int)
+
 	// Get the policy
 	p, _, err := b.GetPolicy(ctx, keysutil.PolicyRequest{
 		Storage: req.Storage,
@@ -146,23 +207,8 @@ func (b *backend) pathHMACWrite(ctx context.Context, req *logical.Request, d *fr
 	case ver == p.LatestVersion:
 		// Allowed
 	case p.MinEncryptionVersion > 0 && ver < p.MinEncryptionVersion:
-		return logical.ErrorResponse("cannot generate HMAC: version is too old (disallowed by policy)"), logical.ErrInvalidRequest
-	}
-
-	key, err := p.HMACKey(ver)
-	if err != nil {
-		return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+		return logical.ErrorResponse("cannot generate %s: version is too old (disallowed by policy)", strings.ToUpper(field)), logical.ErrInvalidRequest
 	}
-	if key == nil && p.Type != keysutil.KeyType_MANAGED_KEY {
-		return nil, fmt.Errorf("HMAC key value could not be computed")
-	}
-
-	hashAlgorithm, ok := keysutil.HashTypeMap[algorithm]
-	if !ok {
-		return logical.ErrorResponse("unsupported algorithm %q", hashAlgorithm), nil
-	}
-
-	hashAlg := keysutil.HashFuncMap[hashAlgorithm]
 
 	batchInputRaw := d.Raw["batch_input"]
 	var batchInputItems []batchRequestHMACItem
@@ -178,21 +224,19 @@ func (b *backend) pathHMACWrite(ctx context.Context, req *logical.Request, d *fr
 	} else {
 		valueRaw, ok := d.GetOk("input")
 		if !ok {
-			return logical.ErrorResponse("missing input for HMAC"), logical.ErrInvalidRequest
+			return logical.ErrorResponse("missing input for %s", strings.ToUpper(field)), logical.ErrInvalidRequest
 		}
 
-		batchInputItems = make([]batchRequestHMACItem, 1)
-		batchInputItems[0] = batchRequestHMACItem{
-			"input": valueRaw.(string),
+		batchInputItems = []batchRequestHMACItem{
+			{"input": valueRaw.(string)},
 		}
 	}
 
 	response := make([]batchResponseHMACItem, len(batchInputItems))
-
 	for i, item := range batchInputItems {
 		rawInput, ok := item["input"]
 		if !ok {
-			response[i].Error = "missing input for HMAC"
+			response[i].Error = fmt.Sprintf("missing input for %s", strings.ToUpper(field))
 			response[i].err = logical.ErrInvalidRequest
 			continue
 		}
@@ -204,61 +248,60 @@ func (b *backend) pathHMACWrite(ctx context.Context, req *logical.Request, d *fr
 			continue
 		}
 
-		var retBytes []byte
-
-		if p.Type == keysutil.KeyType_MANAGED_KEY {
-			managedKeySystemView, ok := b.System().(logical.ManagedKeySystemView)
-			if !ok {
-				response[i].err = errors.New("unsupported system view")
-			}
-
-			retBytes, err = p.HMACWithManagedKey(ctx, ver, managedKeySystemView, b.backendUUID, algorithm, input)
-			if err != nil {
-				response[i].err = err
-			}
-		} else {
-			hf := hmac.New(hashAlg, key)
-			hf.Write(input)
-			retBytes = hf.Sum(nil)
+		retBytes, err := mac(p, ver, input, length)
+		if err != nil {
+			setMACError(&response[i], err)
+			continue
 		}
 
-		retStr := base64.StdEncoding.EncodeToString(retBytes)
-		retStr = fmt.Sprintf("vault:v%s:%s", strconv.Itoa(ver), retStr)
-		response[i].HMAC = retStr
+		response[i].HMAC = fmt.Sprintf("vault:v%s:%s", strconv.Itoa(ver), base64.StdEncoding.EncodeToString(retBytes))
 	}
 
 	// Generate the response
 	resp := &logical.Response{}
-	if batchInputRaw != nil {
-		// Copy the references
+	if batchInputRaw != nil && field == "hmac" {
+		// HMAC batch results keep their original form; copy the references
 		for i := range batchInputItems {
 			response[i].Reference = batchInputItems[i]["reference"]
 		}
 		resp.Data = map[string]interface{}{
 			"batch_results": response,
 		}
-	} else {
-		if response[0].Error != "" || response[0].err != nil {
-			if response[0].Error != "" {
-				return logical.ErrorResponse(response[0].Error), response[0].err
-			} else {
-				return nil, response[0].err
+	} else if batchInputRaw != nil {
+		results := make([]map[string]interface{}, len(response))
+		for i := range response {
+			results[i] = map[string]interface{}{
+				"reference": batchInputItems[i]["reference"],
+			}
+			if response[i].HMAC != "" {
+				results[i][field] = response[i].HMAC
 			}
+			if response[i].Error != "" {
+				results[i]["error"] = response[i].Error
+			}
+		}
+		resp.Data = map[string]interface{}{
+			"batch_results": results,
+		}
+	} else {
+		if response[0].Error != "" {
+			return logical.ErrorResponse(response[0].Error), response[0].err
+		}
+		if response[0].err != nil {
+			return nil, response[0].err
 		}
 		resp.Data = map[string]interface{}{
-			"hmac": response[0].HMAC,
+			field: response[0].HMAC,
 		}
 	}
 
 	return resp, nil
 }
 
-func (b *backend) pathHMACVerify(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+// macVerify verifies the MACs given in the field of the input or batch input
+// with the named key, following the semantics of HMAC verification.
+func (b *backend) macVerify(ctx context.Context, req *logical.Request, d *framework.FieldData, field string, mac macFunc) (*logical.Response, error) {
 	name := d.Get("name").(string)
-	algorithm := d.Get("urlalgorithm").(string)
-	if algorithm == "" {
-		algorithm = d.Get("algorithm").(string)
-	}
 
 	// Get the policy
 	p, _, err := b.GetPolicy(ctx, keysutil.PolicyRequest{
@@ -276,13 +319,6 @@ func (b *backend) pathHMACVerify(ctx context.Context, req *logical.Request, d *f
 	}
 	defer p.Unlock()
 
-	hashAlgorithm, ok := keysutil.HashTypeMap[algorithm]
-	if !ok {
-		return logical.ErrorResponse("unsupported algorithm %q", hashAlgorithm), nil
-	}
-
-	hashAlg := keysutil.HashFuncMap[hashAlgorithm]
-
 	batchInputRaw := d.Raw["batch_input"]
 	var batchInputItems []batchRequestHMACItem
 	if batchInputRaw != nil {
@@ -296,18 +332,15 @@ func (b *backend) pathHMACVerify(ctx context.Context, req *logical.Request, d *f
 		}
 	} else {
 		// use empty string if input is missing - not an error
-		inputB64 := d.Get("input").(string)
-		hmac := d.Get("hmac").(string)
-
-		batchInputItems = make([]batchRequestHMACItem, 1)
-		batchInputItems[0] = batchRequestHMACItem{
-			"input": inputB64,
-			"hmac":  hmac,
+		batchInputItems = []batchRequestHMACItem{
+			{
+				"input": d.Get("input").(string),
+				field:   d.Get(field).(string),
+			},
 		}
 	}
 
 	response := make([]batchResponseHMACItem, len(batchInputItems))
-
 	for i, item := range batchInputItems {
 		rawInput, ok := item["input"]
 		if !ok {
@@ -323,68 +356,58 @@ func (b *backend) pathHMACVerify(ctx context.Context, req *logical.Request, d *f
 			continue
 		}
 
-		verificationHMAC, ok := item["hmac"]
+		verificationMAC, ok := item[field]
 		if !ok {
-			response[i].Error = "missing hmac"
+			response[i].Error = fmt.Sprintf("missing %s", field)
 			response[i].err = logical.ErrInvalidRequest
 			continue
 		}
 
 		// Verify the prefix
-		if !strings.HasPrefix(verificationHMAC, "vault:v") {
-			response[i].Error = "invalid HMAC to verify: no prefix"
+		if !strings.HasPrefix(verificationMAC, "vault:v") {
+			response[i].Error = fmt.Sprintf("invalid %s to verify: no prefix", strings.ToUpper(field))
 			response[i].err = logical.ErrInvalidRequest
 			continue
 		}
 
-		splitVerificationHMAC := strings.SplitN(strings.TrimPrefix(verificationHMAC, "vault:v"), ":", 2)
-		if len(splitVerificationHMAC) != 2 {
-			response[i].Error = "invalid HMAC: wrong number of fields"
+		splitVerificationMAC := strings.SplitN(strings.TrimPrefix(verificationMAC, "vault:v"), ":", 2)
+		if len(splitVerificationMAC) != 2 {
+			response[i].Error = fmt.Sprintf("invalid %s: wrong number of fields", strings.ToUpper(field))
 			response[i].err = logical.ErrInvalidRequest
 			continue
 		}
 
-		ver, err := strconv.Atoi(splitVerificationHMAC[0])
+		ver, err := strconv.Atoi(splitVerificationMAC[0])
 		if err != nil {
-			response[i].Error = "invalid HMAC: version number could not be decoded"
+			response[i].Error = fmt.Sprintf("invalid %s: version number could not be decoded", strings.ToUpper(field))
 			response[i].err = logical.ErrInvalidRequest
 			continue
 		}
 
-		verBytes, err := base64.StdEncoding.DecodeString(splitVerificationHMAC[1])
+		verBytes, err := base64.StdEncoding.DecodeString(splitVerificationMAC[1])
 		if err != nil {
-			response[i].Error = fmt.Sprintf("unable to decode verification HMAC as base64: %s", err)
+			response[i].Error = fmt.Sprintf("unable to decode verification %s as base64: %s", strings.ToUpper(field), err)
 			response[i].err = logical.ErrInvalidRequest
 			continue
 		}
 
 		if ver > p.LatestVersion {
-			response[i].Error = "invalid HMAC: version is too new"
+			response[i].Error = fmt.Sprintf("invalid %s: version is too new", strings.ToUpper(field))
 			response[i].err = logical.ErrInvalidRequest
 			continue
 		}
 
 		if p.MinDecryptionVersion > 0 && ver < p.MinDecryptionVersion {
-			response[i].Error = "cannot verify HMAC: version is too old (disallowed by policy)"
+			response[i].Error = fmt.Sprintf("cannot verify %s: version is too old (disallowed by policy)", strings.ToUpper(field))
 			response[i].err = logical.ErrInvalidRequest
 			continue
 		}
 
-		key, err := p.HMACKey(ver)
+		retBytes, err := mac(p, ver, input, len(verBytes))
 		if err != nil {
-			response[i].Error = err.Error()
-			response[i].err = logical.ErrInvalidRequest
-			continue
-		}
-		if key == nil {
-			response[i].Error = ""
-			response[i].err = fmt.Errorf("HMAC key value could not be computed")
+			setMACError(&response[i], err)
 			continue
 		}
-
-		hf := hmac.New(hashAlg, key)
-		hf.Write(input)
-		retBytes := hf.Sum(nil)
 		response[i].Valid = hmac.Equal(retBytes, verBytes)
 	}
 
@@ -399,12 +422,11 @@ func (b *backend) pathHMACVerify(ctx context.Context, req *logical.Request, d *f
 			"batch_results": response,
 		}
 	} else {
-		if response[0].Error != "" || response[0].err != nil {
-			if response[0].Error != "" {
-				return logical.ErrorResponse(response[0].Error), response[0].err
-			} else {
-				return nil, response[0].err
-			}
+		if response[0].Error != "" {
+			return logical.ErrorResponse(response[0].Error), response[0].err
+		}
+		if response[0].err != nil {
+			return nil, response[0].err
 		}
 		resp.Data = map[string]interface{}{
 			"valid": response[0].Valid,
@@ -414,6 +436,16 @@ func (b *backend) pathHMACVerify(ctx context.Context, req *logical.Request, d *f
 	return resp, nil
 }
 
+// setMACError records the error computing a MAC on the batch response item.
+func setMACError(item *batchResponseHMACItem, err error) {
+	if _, ok := err.(errutil.UserError); ok {
+		item.Error = err.Error()
+		item.err = logical.ErrInvalidRequest
+		return
+	}
+	item.err = err
+}
+
 const pathHMACHelpSyn = `Generate an HMAC for input data using the named key`
 
 const pathHMACHelpDesc = `
@@ -6,10 +6,9 @@ package transit
 import (
 	"context"
 	"crypto/rsa"
-	"crypto/sha1"
-	"crypto/sha256"
-	"crypto/sha512"
+	"crypto/x509"
 	"encoding/base64"
+	"encoding/pem"
 	"errors"
 	"fmt"
 	"hash"
@@ -17,6 +16,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/go-jose/go-jose/v3"
 	"github.com/google/tink/go/kwp/subtle"
 	"github.com/hashicorp/vault/sdk/framework"
 	"github.com/hashicorp/vault/sdk/helper/keysutil"
@@ -25,6 +25,12 @@ import (
 
 const EncryptedKeyBytes = 512
 
+// The formats of the ciphertext of imported keys
+const (
+	importFormatWrapped = "wrapped"
+	importFormatJWE     = "jwe"
+)
+
 func (b *backend) pathImport() *framework.Path {
 	return &framework.Path{
 		Pattern: "keys/" + framework.GenericNameRegex("name") + "/import",
@@ -53,15 +59,33 @@ func (b *backend) pathImport() *framework.Path {
 				Default: "SHA256",
 				Description: `The hash function used as a random oracle in the OAEP wrapping of the user-generated,
 ephemeral AES key. Can be one of "SHA1", "SHA224", "SHA256" (default), "SHA384", or "SHA512"`,
+			},
+			"mgf1_hash_function": {
+				Type: framework.TypeString,
+				Description: `The hash function used by the MGF1 mask generation function in the OAEP wrapping
+of the ephemeral AES key, if it differs from "hash_function". Can be one of "SHA1", "SHA224", "SHA256",
+"SHA384", or "SHA512"`,
+			},
+			"oaep_label": {
+				Type:        framework.TypeString,
+				Description: `The base64-encoded label used in the OAEP wrapping of the ephemeral AES key. Defaults to an empty label.`,
+			},
+			"format": {
+				Type:    framework.TypeString,
+				Default: importFormatWrapped,
+				Description: `The format of "ciphertext". Can be "wrapped" (default), for the RSA-OAEP and AES-KWP
+wrapped format, or "jwe", for a JWE in compact serialization encrypted to the wrapping key with the
+"RSA-OAEP" or "RSA-OAEP-256" algorithm, whose payload is the JWK of the key.`,
 			},
 			"ciphertext": {
 				Type: framework.TypeString,
 				Description: `The base64-encoded ciphertext of the keys. The AES key should be encrypted using OAEP 
-with the wrapping key and then concatenated with the import key, wrapped by the AES key.`,
+with the wrapping key and then concatenated with the import key, wrapped by the AES key. With the
+"jwe" format, the compact serialization of the JWE of the key.`,
 			},
 			"public_key": {
 				Type:        framework.TypeString,
-				Description: `The plaintext PEM public key to be imported. If "ciphertext" is set, this field is ignored.`,
+				Description: `The plaintext PEM or JWK public key to be imported. If "ciphertext" is set, this field is ignored.`,
 			},
 			"allow_rotation": {
 				Type:        framework.TypeBool,
@@ -130,17 +154,35 @@ func (b *backend) pathImportVersion() *framework.Path {
 			"ciphertext": {
 				Type: framework.TypeString,
 				Description: `The base64-encoded ciphertext of the keys. The AES key should be encrypted using OAEP 
-with the wrapping key and then concatenated with the import key, wrapped by the AES key.`,
+with the wrapping key and then concatenated with the import key, wrapped by the AES key. With the
+"jwe" format, the compact serialization of the JWE of the key.`,
 			},
 			"public_key": {
 				Type:        framework.TypeString,
-				Description: `The plaintext public key to be imported. If "ciphertext" is set, this field is ignored.`,
+				Description: `The plaintext PEM or JWK public key to be imported. If "ciphertext" is set, this field is ignored.`,
 			},
 			"hash_function": {
 				Type:    framework.TypeString,
 				Default: "SHA256",
 				Description: `The hash function used as a random oracle in the OAEP wrapping of the user-generated,
 ephemeral AES key. Can be one of "SHA1", "SHA224", "SHA256" (default), "SHA384", or "SHA512"`,
+			},
+			"mgf1_hash_function": {
+				Type: framework.TypeString,
+				Description: `The hash function used by the MGF1 mask generation function in the OAEP wrapping
+of the ephemeral AES key, if it differs from "hash_function". Can be one of "SHA1", "SHA224", "SHA256",
+"SHA384", or "SHA512"`,
+			},
+			"oaep_label": {
+				Type:        framework.TypeString,
+				Description: `The base64-encoded label used in the OAEP wrapping of the ephemeral AES key. Defaults to an empty label.`,
+			},
+			"format": {
+				Type:    framework.TypeString,
+				Default: importFormatWrapped,
+				Description: `The format of "ciphertext". Can be "wrapped" (default), for the RSA-OAEP and AES-KWP
+wrapped format, or "jwe", for a JWE in compact serialization encrypted to the wrapping key with the
+"RSA-OAEP" or "RSA-OAEP-256" algorithm, whose payload is the JWK of the key.`,
 			},
 			"version": {
 				Type: framework.TypeInt,
@@ -301,7 +343,7 @@ func (b *backend) pathImportVersionWrite(ctx context.Context, req *logical.Reque
 	return nil, nil
 }
 
-func (b *backend) decryptImportedKey(ctx context.Context, storage logical.Storage, ciphertext []byte, hashFn hash.Hash) ([]byte, error) {
+func (b *backend) decryptImportedKey(ctx context.Context, storage logical.Storage, ciphertext []byte, opts *rsa.OAEPOptions) ([]byte, error) {
 	// Bounds check the ciphertext to avoid panics
 	if len(ciphertext) <= EncryptedKeyBytes {
 		return nil, errors.New("provided ciphertext is too short")
@@ -310,16 +352,11 @@ func (b *backend) decryptImportedKey(ctx context.Context, storage logical.Storag
 	wrappedEphKey := ciphertext[:EncryptedKeyBytes]
 	wrappedImportKey := ciphertext[EncryptedKeyBytes:]
 
-	wrappingKey, err := b.getWrappingKey(ctx, storage)
+	privWrappingKey, err := b.getPrivateWrappingKey(ctx, storage)
 	if err != nil {
 		return nil, err
 	}
-	if wrappingKey == nil {
-		return nil, fmt.Errorf("error importing key: wrapping key was nil")
-	}
-
-	privWrappingKey := wrappingKey.Keys[strconv.Itoa(wrappingKey.LatestVersion)].RSAKey
-	ephKey, err := rsa.DecryptOAEP(hashFn, b.GetRandomReader(), privWrappingKey, wrappedEphKey, []byte{})
+	ephKey, err := privWrappingKey.Decrypt(b.GetRandomReader(), wrappedEphKey, opts)
 	if err != nil {
 		return nil, err
 	}
@@ -351,24 +388,98 @@ func (b *backend) decryptImportedKey(ctx context.Context, storage logical.Storag
 	return importKey, nil
 }
 
+// decryptImportedJWE decrypts the JWE of an imported key and returns the key
+// in the same form as the wrapped format does: the raw bytes of symmetric keys
+// and the PKCS#8 encoding of private keys.
+func (b *backend) decryptImportedJWE(ctx context.Context, storage logical.Storage, ciphertext string, keyType keysutil.KeyType) ([]byte, error) {
+	jwe, err := jose.ParseEncrypted(strings.TrimSpace(ciphertext))
+	if err != nil {
+		return nil, fmt.Errorf("error parsing JWE: %w", err)
+	}
+
+	// Only allow the OAEP key management algorithms, like the wrapped format
+	switch jose.KeyAlgorithm(jwe.Header.Algorithm) {
+	case jose.RSA_OAEP, jose.RSA_OAEP_256:
+	default:
+		return nil, fmt.Errorf("unsupported JWE key management algorithm: %q", jwe.Header.Algorithm)
+	}
+
+	privWrappingKey, err := b.getPrivateWrappingKey(ctx, storage)
+	if err != nil {
+		return nil, err
+	}
+	payload, err := jwe.Decrypt(privWrappingKey)
+	if err != nil {
+		return nil, fmt.Errorf("error decrypting JWE: %w", err)
+	}
+
+	var jwk jose.JSONWebKey
+	if err := jwk.UnmarshalJSON(payload); err != nil {
+		return nil, fmt.Errorf("error parsing JWK: %w", err)
+	}
+
+	switch keyType {
+	case keysutil.KeyType_AES128_GCM96, keysutil.KeyType_AES256_GCM96, keysutil.KeyType_ChaCha20_Poly1305, keysutil.KeyType_HMAC:
+		key, ok := jwk.Key.([]byte)
+		if !ok {
+			return nil, fmt.Errorf("expected a symmetric JWK for key type %v", keyType)
+		}
+		return key, nil
+	default:
+		if jwk.IsPublic() {
+			return nil, fmt.Errorf("expected a private JWK for key type %v", keyType)
+		}
+		return x509.MarshalPKCS8PrivateKey(jwk.Key)
+	}
+}
+
+// getPrivateWrappingKey returns the private key of the latest version of the
+// wrapping key.
+func (b *backend) getPrivateWrappingKey(ctx context.Context, storage logical.Storage) (*rsa.PrivateKey, error) {
+	wrappingKey, err := b.getWrappingKey(ctx, storage)
+	if err != nil {
+		return nil, err
+	}
+	if wrappingKey == nil {
+		return nil, fmt.Errorf("error importing key: wrapping key was nil")
+	}
+
+	return wrappingKey.Keys[strconv.Itoa(wrappingKey.LatestVersion)].RSAKey, nil
+}
+
 func (b *backend) extractKeyFromFields(ctx context.Context, req *logical.Request, d *framework.FieldData, keyType keysutil.KeyType, isPrivateKey bool) ([]byte, *logical.Response, error) {
 	var key []byte
 	if isPrivateKey {
-		hashFnStr := d.Get("hash_function").(string)
-		hashFn, err := parseHashFn(hashFnStr)
-		if err != nil {
-			return key, logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
-		}
-
 		ciphertextString := d.Get("ciphertext").(string)
-		ciphertext, err := base64.StdEncoding.DecodeString(ciphertextString)
-		if err != nil {
-			return key, nil, err
-		}
 
-		key, err = b.decryptImportedKey(ctx, req.Storage, ciphertext, hashFn)
-		if err != nil {
-			return key, nil, err
+		switch format := d.Get("format").(string); format {
+		case importFormatWrapped:
+			opts, err := parseOAEPOptions(d)
+			if err != nil {
+				return key, logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+			}
+
+			ciphertext, err := base64.StdEncoding.DecodeString(ciphertextString)
+			if err != nil {
+				return key, nil, err
+			}
+
+			key, err = b.decryptImportedKey(ctx, req.Storage, ciphertext, opts)
+			if err != nil {
+				return key, nil, err
+			}
+		case importFormatJWE:
+			if isFieldSet("mgf1_hash_function", d) || isFieldSet("oaep_label", d) {
+				return key, logical.ErrorResponse("mgf1_hash_function and oaep_label are not supported with the jwe format"), logical.ErrInvalidRequest
+			}
+
+			var err error
+			key, err = b.decryptImportedJWE(ctx, req.Storage, ciphertextString, keyType)
+			if err != nil {
+				return key, nil, err
+			}
+		default:
+			return key, logical.ErrorResponse(fmt.Sprintf("unknown import format: %s", format)), logical.ErrInvalidRequest
 		}
 	} else {
 		publicKeyString := d.Get("public_key").(string)
@@ -376,26 +487,75 @@ func (b *backend) extractKeyFromFields(ctx context.Context, req *logical.Request
 			return key, nil, errors.New("provided type does not support public_key import")
 		}
 		key = []byte(publicKeyString)
+
+		// Convert JWK public keys to the PEM format the policy expects
+		if strings.HasPrefix(strings.TrimSpace(publicKeyString), "{") {
+			var err error
+			key, err = jwkToPEMPublicKey(publicKeyString)
+			if err != nil {
+				return nil, logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+			}
+		}
 	}
 
 	return key, nil, nil
 }
 
+// jwkToPEMPublicKey converts a public JWK to a PEM-encoded PKIX public key.
+func jwkToPEMPublicKey(publicKey string) ([]byte, error) {
+	var jwk jose.JSONWebKey
+	if err := jwk.UnmarshalJSON([]byte(publicKey)); err != nil {
+		return nil, fmt.Errorf("error parsing JWK: %w", err)
+	}
+	if !jwk.IsPublic() {
+		return nil, errors.New("public_key must be a public JWK")
+	}
+
+	der, err := x509.MarshalPKIXPublicKey(jwk.Key)
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling JWK public key: %w", err)
+	}
+
+	return pem.EncodeToMemory(&pem.Block{
+		Type:  "PUBLIC KEY",
+		Bytes: der,
+	}), nil
+}
+
+// parseOAEPOptions returns the OAEP parameters of the wrapping of the
+// ephemeral AES key.
+func parseOAEPOptions(d *framework.FieldData) (*rsa.OAEPOptions, error) {
+	hashFn, err := keysutil.ParseOAEPHash(d.Get("hash_function").(string))
+	if err != nil {
+		return nil, err
+	}
+
+	opts := &rsa.OAEPOptions{
+		Hash: hashFn,
+	}
+	if mgfHashFn := d.Get("mgf1_hash_function").(string); mgfHashFn != "" {
+		opts.MGFHash, err = keysutil.ParseOAEPHash(mgfHashFn)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if label := d.Get("oaep_label").(string); label != "" {
+		opts.Label, err = base64.StdEncoding.DecodeString(label)
+		if err != nil {
+			return nil, fmt.Errorf("error base64 decoding oaep_label: %w", err)
+		}
+	}
+
+	return opts, nil
+}
+
 func parseHashFn(hashFn string) (hash.Hash, error) {
-	switch strings.ToUpper(hashFn) {
-	case "SHA1":
-		return sha1.New(), nil
-	case "SHA224":
-		return sha256.New224(), nil
-	case "SHA256":
-		return sha256.New(), nil
-	case "SHA384":
-		return sha512.New384(), nil
-	case "SHA512":
-		return sha512.New(), nil
-	default:
-		return nil, fmt.Errorf("unknown hash function: %s", hashFn)
+	h, err := keysutil.ParseOAEPHash(hashFn)
+	if err != nil {
+		return nil, err
 	}
+
+	return h.New(), nil
 }
 
 // checkKeyFieldsSet: Checks which key fields are set. If both are set, an error is returned
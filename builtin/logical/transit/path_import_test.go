@@ -11,16 +11,20 @@ import (
 	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/rsa"
+	"crypto/sha512"
 	"crypto/x509"
 	"encoding/base64"
 	"encoding/pem"
 	"fmt"
 	"strconv"
+	"strings"
 	"sync"
 	"testing"
 
+	"github.com/go-jose/go-jose/v3"
 	"github.com/google/tink/go/kwp/subtle"
 	uuid "github.com/hashicorp/go-uuid"
+	"github.com/hashicorp/vault/sdk/helper/keysutil"
 	"github.com/hashicorp/vault/sdk/logical"
 )
 
@@ -948,6 +952,181 @@ func TestTransit_ImportVersionWithPublicKeys(t *testing.T) {
 	)
 }
 
+func TestTransit_ImportOAEPParameters(t *testing.T) {
+	generateKeys(t)
+	b, s := createBackendWithStorage(t)
+
+	wrappingKey, err := b.getWrappingKey(context.Background(), s)
+	if err != nil || wrappingKey == nil {
+		t.Fatalf("failed to retrieve public wrapping key: %s", err)
+	}
+	privWrappingKey := wrappingKey.Keys[strconv.Itoa(wrappingKey.LatestVersion)].RSAKey
+	wrappingKeyEntry := &keysutil.KeyEntry{RSAPublicKey: &privWrappingKey.PublicKey}
+
+	label := []byte("key import")
+	blob, err := wrappingKeyEntry.WrapKeyWithLabel(getKey(t, "aes256-gcm96"), keysutil.KeyType_AES256_GCM96, sha512.New384(), label)
+	if err != nil {
+		t.Fatalf("failed to wrap target key for import: %s", err)
+	}
+
+	testCases := []struct {
+		name        string
+		data        map[string]interface{}
+		shouldError bool
+	}{
+		{"missing label", map[string]interface{}{"hash_function": "SHA384"}, true},
+		{"wrong label", map[string]interface{}{"hash_function": "SHA384", "oaep_label": base64.StdEncoding.EncodeToString([]byte("other"))}, true},
+		{"wrong mgf1 hash", map[string]interface{}{"hash_function": "SHA384", "mgf1_hash_function": "SHA1", "oaep_label": base64.StdEncoding.EncodeToString(label)}, true},
+		{"invalid label", map[string]interface{}{"hash_function": "SHA384", "oaep_label": "not base64!"}, true},
+		{"matching parameters", map[string]interface{}{"hash_function": "SHA384", "mgf1_hash_function": "SHA384", "oaep_label": base64.StdEncoding.EncodeToString(label)}, false},
+	}
+
+	for i, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.data["ciphertext"] = blob
+			resp, err := b.HandleRequest(context.Background(), &logical.Request{
+				Storage:   s,
+				Operation: logical.UpdateOperation,
+				Path:      fmt.Sprintf("keys/oaep-%d/import", i),
+				Data:      tc.data,
+			})
+			if err == nil && resp != nil && resp.IsError() {
+				err = resp.Error()
+			}
+			if tc.shouldError && err == nil {
+				t.Fatal("expected import to fail")
+			}
+			if !tc.shouldError && err != nil {
+				t.Fatalf("failed to import key: %s", err)
+			}
+		})
+	}
+}
+
+func TestTransit_ImportJWE(t *testing.T) {
+	generateKeys(t)
+	b, s := createBackendWithStorage(t)
+
+	wrappingKey, err := b.getWrappingKey(context.Background(), s)
+	if err != nil || wrappingKey == nil {
+		t.Fatalf("failed to retrieve public wrapping key: %s", err)
+	}
+	privWrappingKey := wrappingKey.Keys[strconv.Itoa(wrappingKey.LatestVersion)].RSAKey
+	pubWrappingKey := &privWrappingKey.PublicKey
+
+	for _, keyType := range keyTypes {
+		t.Run(keyType, func(t *testing.T) {
+			jwe := encryptTargetKeyJWE(t, pubWrappingKey, getKey(t, keyType), jose.RSA_OAEP_256)
+			req := &logical.Request{
+				Storage:   s,
+				Operation: logical.UpdateOperation,
+				Path:      fmt.Sprintf("keys/jwe-%s/import", keyType),
+				Data: map[string]interface{}{
+					"ciphertext": jwe,
+					"format":     "jwe",
+					"type":       keyType,
+				},
+			}
+			resp, err := b.HandleRequest(context.Background(), req)
+			if err != nil || (resp != nil && resp.IsError()) {
+				t.Fatalf("failed to import key: resp: %#v\nerr: %v", resp, err)
+			}
+		})
+	}
+
+	t.Run("mismatched key type", func(t *testing.T) {
+		jwe := encryptTargetKeyJWE(t, pubWrappingKey, getKey(t, "ecdsa-p256"), jose.RSA_OAEP)
+		_, err := b.HandleRequest(context.Background(), &logical.Request{
+			Storage:   s,
+			Operation: logical.UpdateOperation,
+			Path:      "keys/jwe-mismatched/import",
+			Data: map[string]interface{}{
+				"ciphertext": jwe,
+				"format":     "jwe",
+				"type":       "aes256-gcm96",
+			},
+		})
+		if err == nil {
+			t.Fatal("expected import of an ecdsa JWK as an aes key to fail")
+		}
+	})
+
+	t.Run("rsa1_5 algorithm", func(t *testing.T) {
+		jwe := encryptTargetKeyJWE(t, pubWrappingKey, getKey(t, "aes256-gcm96"), jose.RSA1_5)
+		_, err := b.HandleRequest(context.Background(), &logical.Request{
+			Storage:   s,
+			Operation: logical.UpdateOperation,
+			Path:      "keys/jwe-rsa1_5/import",
+			Data: map[string]interface{}{
+				"ciphertext": jwe,
+				"format":     "jwe",
+			},
+		})
+		if err == nil {
+			t.Fatal("expected import of a JWE using RSA1_5 to fail")
+		}
+	})
+
+	t.Run("public key", func(t *testing.T) {
+		privateKey := getKey(t, "ecdsa-p256").(*ecdsa.PrivateKey)
+		jwk, err := jose.JSONWebKey{Key: privateKey.Public()}.MarshalJSON()
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Storage:   s,
+			Operation: logical.UpdateOperation,
+			Path:      "keys/jwk-public/import",
+			Data: map[string]interface{}{
+				"public_key": string(jwk),
+				"type":       "ecdsa-p256",
+			},
+		})
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("failed to import key: resp: %#v\nerr: %v", resp, err)
+		}
+
+		resp, err = b.HandleRequest(context.Background(), &logical.Request{
+			Storage:   s,
+			Operation: logical.ReadOperation,
+			Path:      "export/public-key/jwk-public/1",
+		})
+		if err != nil || (resp != nil && resp.IsError()) {
+			t.Fatalf("failed to export key: resp: %#v\nerr: %v", resp, err)
+		}
+		expected, err := getPublicKey(privateKey, "ecdsa-p256")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if actual := resp.Data["keys"].(map[string]string)["1"]; actual != strings.TrimSpace(string(expected)) {
+			t.Fatalf("expected public key %q, got %q", expected, actual)
+		}
+	})
+}
+
+func encryptTargetKeyJWE(t *testing.T, wrappingKey *rsa.PublicKey, targetKey interface{}, alg jose.KeyAlgorithm) string {
+	t.Helper()
+
+	encrypter, err := jose.NewEncrypter(jose.A256GCM, jose.Recipient{Algorithm: alg, Key: wrappingKey}, nil)
+	if err != nil {
+		t.Fatalf("failed to create JWE encrypter: %s", err)
+	}
+	jwk, err := jose.JSONWebKey{Key: targetKey}.MarshalJSON()
+	if err != nil {
+		t.Fatalf("failed to marshal target key as JWK: %s", err)
+	}
+	jwe, err := encrypter.Encrypt(jwk)
+	if err != nil {
+		t.Fatalf("failed to encrypt target key: %s", err)
+	}
+	serialized, err := jwe.CompactSerialize()
+	if err != nil {
+		t.Fatalf("failed to serialize JWE: %s", err)
+	}
+
+	return serialized
+}
+
 func wrapTargetKeyForImport(t *testing.T, wrappingKey *rsa.PublicKey, targetKey interface{}, targetKeyType string, hashFnName string) string {
 	t.Helper()
 
@@ -59,7 +59,9 @@ func (b *backend) pathKeys() *framework.Path {
 				Description: `
 The type of key to create. Currently, "aes128-gcm96" (symmetric), "aes256-gcm96" (symmetric), "ecdsa-p256"
 (asymmetric), "ecdsa-p384" (asymmetric), "ecdsa-p521" (asymmetric), "ed25519" (asymmetric), "rsa-2048" (asymmetric), "rsa-3072"
-(asymmetric), "rsa-4096" (asymmetric) are supported.  Defaults to "aes256-gcm96".
+(asymmetric), "rsa-4096" (asymmetric), "ml-dsa-44" (asymmetric), "ml-dsa-65" (asymmetric), "ml-dsa-87" (asymmetric),
+"ml-kem-768" (asymmetric), "ml-kem-1024" (asymmetric), "ed25519-ml-dsa-65" (asymmetric), "aes256-ff1"
+(format-preserving) and "aes256-ff3-1" (format-preserving) are supported. Defaults to "aes256-gcm96".
 `,
 			},
 
@@ -121,6 +123,24 @@ key.`,
 				Default:     0,
 				Description: fmt.Sprintf("The key size in bytes for the algorithm.  Only applies to HMAC and must be no fewer than %d bytes and no more than %d", keysutil.HmacMinKeySize, keysutil.HmacMaxKeySize),
 			},
+			"alphabet": {
+				Type: framework.TypeString,
+				Description: `The characters encrypted by a format-preserving
+encryption key, either the name of a built-in alphabet
+("numeric", "alphalower", "alphaupper", "alphanumericlower",
+"alphanumericupper" or "alphanumeric") or the characters
+themselves. Required for format-preserving encryption keys.`,
+			},
+			"template": {
+				Type: framework.TypeString,
+				Description: `A regular expression that values encrypted by a
+format-preserving encryption key must match. The characters
+matched by its capture groups are encrypted, and all others
+are left as is. A fixed length capture group named version
+holds the key version in ciphertexts, and is omitted from
+plaintexts. If not set, the whole value is encrypted and the
+key version is held by the first 2 characters of ciphertexts.`,
+			},
 			"managed_key_name": {
 				Type:        framework.TypeString,
 				Description: "The name of the managed key to use for this transit key",
@@ -177,6 +197,8 @@ func (b *backend) pathPolicyWrite(ctx context.Context, req *logical.Request, d *
 	autoRotatePeriod := time.Second * time.Duration(d.Get("auto_rotate_period").(int))
 	managedKeyName := d.Get("managed_key_name").(string)
 	managedKeyId := d.Get("managed_key_id").(string)
+	alphabet := d.Get("alphabet").(string)
+	template := d.Get("template").(string)
 
 	if autoRotatePeriod != 0 && autoRotatePeriod < time.Hour {
 		return logical.ErrorResponse("auto rotate period must be 0 to disable or at least an hour"), nil
@@ -195,6 +217,8 @@ func (b *backend) pathPolicyWrite(ctx context.Context, req *logical.Request, d *
 		Exportable:           exportable,
 		AllowPlaintextBackup: allowPlaintextBackup,
 		AutoRotatePeriod:     autoRotatePeriod,
+		Alphabet:             alphabet,
+		Template:             template,
 	}
 
 	switch keyType {
@@ -222,6 +246,22 @@ func (b *backend) pathPolicyWrite(ctx context.Context, req *logical.Request, d *
 		polReq.KeyType = keysutil.KeyType_HMAC
 	case "managed_key":
 		polReq.KeyType = keysutil.KeyType_MANAGED_KEY
+	case "ml-dsa-44":
+		polReq.KeyType = keysutil.KeyType_ML_DSA_44
+	case "ml-dsa-65":
+		polReq.KeyType = keysutil.KeyType_ML_DSA_65
+	case "ml-dsa-87":
+		polReq.KeyType = keysutil.KeyType_ML_DSA_87
+	case "ml-kem-768":
+		polReq.KeyType = keysutil.KeyType_ML_KEM_768
+	case "ml-kem-1024":
+		polReq.KeyType = keysutil.KeyType_ML_KEM_1024
+	case "ed25519-ml-dsa-65":
+		polReq.KeyType = keysutil.KeyType_HYBRID_ED25519_ML_DSA_65
+	case "aes256-ff1":
+		polReq.KeyType = keysutil.KeyType_FF1_AES256
+	case "aes256-ff3-1":
+		polReq.KeyType = keysutil.KeyType_FF3_1_AES256
 	default:
 		return logical.ErrorResponse(fmt.Sprintf("unknown key type %v", keyType)), logical.ErrInvalidRequest
 	}
@@ -234,6 +274,20 @@ func (b *backend) pathPolicyWrite(ctx context.Context, req *logical.Request, d *
 		}
 		polReq.KeySize = keySize
 	}
+	if polReq.KeyType.FormatPreserving() {
+		if alphabet == "" {
+			return logical.ErrorResponse(fmt.Sprintf("alphabet is required for algorithm %v", polReq.KeyType)), logical.ErrInvalidRequest
+		}
+		characters, err := keysutil.ParseFPEAlphabet(alphabet)
+		if err != nil {
+			return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+		}
+		if err := keysutil.ValidateFPETemplate(template, characters); err != nil {
+			return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+		}
+	} else if alphabet != "" || template != "" {
+		return logical.ErrorResponse(fmt.Sprintf("alphabet and template are not valid for algorithm %v", polReq.KeyType)), logical.ErrInvalidRequest
+	}
 
 	if polReq.KeyType == keysutil.KeyType_MANAGED_KEY {
 		keyId, err := GetManagedKeyUUID(ctx, b, managedKeyName, managedKeyId)
@@ -321,6 +375,7 @@ func (b *backend) formatKeyPolicy(p *keysutil.Policy, context []byte) (*logical.
 			"supports_decryption":    p.Type.DecryptionSupported(),
 			"supports_signing":       p.Type.SigningSupported(),
 			"supports_derivation":    p.Type.DerivationSupported(),
+			"supports_encapsulation": p.Type.KeyEncapsulationSupported(),
 			"auto_rotate_period":     int64(p.AutoRotatePeriod.Seconds()),
 			"imported_key":           p.Imported,
 		},
@@ -329,10 +384,36 @@ func (b *backend) formatKeyPolicy(p *keysutil.Policy, context []byte) (*logical.
 		resp.Data["key_size"] = p.KeySize
 	}
 
+	if p.Type.FormatPreserving() {
+		resp.Data["alphabet"] = p.Alphabet
+		resp.Data["template"] = p.Template
+	}
+
 	if p.Imported {
 		resp.Data["imported_key_allow_rotation"] = p.AllowImportedKeyRotation
 	}
 
+	operationLimitAction := p.OperationLimitAction
+	if operationLimitAction == "" {
+		operationLimitAction = keysutil.OperationLimitActionRotate
+	}
+	resp.Data["max_operations_per_version"] = p.MaxOperationsPerVersion
+	resp.Data["operation_limit_action"] = operationLimitAction
+
+	operationCounts := map[string]interface{}{}
+	for ver, count := range p.GetOperationCounts() {
+		operationCounts[strconv.Itoa(ver)] = map[string]interface{}{
+			"encryptions": count.Encryptions,
+			"signatures":  count.Signatures,
+		}
+	}
+	resp.Data["operation_counts"] = operationCounts
+
+	if p.ExportApprovalsRequired > 0 {
+		resp.Data["export_approvals_required"] = p.ExportApprovalsRequired
+		resp.Data["export_approver_policy"] = p.ExportApproverPolicy
+	}
+
 	if p.BackupInfo != nil {
 		resp.Data["backup_info"] = map[string]interface{}{
 			"time":    p.BackupInfo.Time,
@@ -361,14 +442,15 @@ func (b *backend) formatKeyPolicy(p *keysutil.Policy, context []byte) (*logical.
 	}
 
 	switch p.Type {
-	case keysutil.KeyType_AES128_GCM96, keysutil.KeyType_AES256_GCM96, keysutil.KeyType_ChaCha20_Poly1305:
+	case keysutil.KeyType_AES128_GCM96, keysutil.KeyType_AES256_GCM96, keysutil.KeyType_ChaCha20_Poly1305, keysutil.KeyType_FF1_AES256, keysutil.KeyType_FF3_1_AES256:
 		retKeys := map[string]int64{}
 		for k, v := range p.Keys {
 			retKeys[k] = v.DeprecatedCreationTime
 		}
 		resp.Data["keys"] = retKeys
 
-	case keysutil.KeyType_ECDSA_P256, keysutil.KeyType_ECDSA_P384, keysutil.KeyType_ECDSA_P521, keysutil.KeyType_ED25519, keysutil.KeyType_RSA2048, keysutil.KeyType_RSA3072, keysutil.KeyType_RSA4096:
+	case keysutil.KeyType_ECDSA_P256, keysutil.KeyType_ECDSA_P384, keysutil.KeyType_ECDSA_P521, keysutil.KeyType_ED25519, keysutil.KeyType_RSA2048, keysutil.KeyType_RSA3072, keysutil.KeyType_RSA4096,
+		keysutil.KeyType_ML_DSA_44, keysutil.KeyType_ML_DSA_65, keysutil.KeyType_ML_DSA_87, keysutil.KeyType_ML_KEM_768, keysutil.KeyType_ML_KEM_1024, keysutil.KeyType_HYBRID_ED25519_ML_DSA_65:
 		retKeys := map[string]map[string]interface{}{}
 		for k, v := range p.Keys {
 			key := asymKey{
@@ -431,6 +513,8 @@ func (b *backend) formatKeyPolicy(p *keysutil.Policy, context []byte) (*logical.
 					return nil, err
 				}
 				key.PublicKey = pubKey
+			default:
+				key.Name = p.Type.String()
 			}
 
 			retKeys[k] = structs.New(key).Map()
@@ -450,6 +534,12 @@ func (b *backend) pathPolicyDelete(ctx context.Context, req *logical.Request, d
 		return logical.ErrorResponse(fmt.Sprintf("error deleting policy %s: %s", name, err)), err
 	}
 
+	b.exportRequestLock.Lock()
+	defer b.exportRequestLock.Unlock()
+	if err := deleteExportRequests(ctx, req.Storage, name); err != nil {
+		return nil, fmt.Errorf("error deleting export requests of policy %s: %w", name, err)
+	}
+
 	return nil, nil
 }
 
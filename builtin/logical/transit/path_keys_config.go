@@ -65,6 +65,39 @@ the latest version of the key is allowed.`,
 being automatically rotated. A value of 0
 disables automatic rotation for the key.`,
 			},
+
+			"max_operations_per_version": {
+				Type: framework.TypeInt,
+				Description: `Number of encryption and signing operations
+after which a key version reaches its operation
+limit. A value of 0 disables the limit.`,
+			},
+
+			"operation_limit_action": {
+				Type: framework.TypeString,
+				Description: `Action taken once the latest key version
+reaches its operation limit; either "rotate" to
+rotate the key, or "refuse" to refuse further
+operations until the key is rotated. Defaults
+to "rotate".`,
+			},
+
+			"export_approvals_required": {
+				Type: framework.TypeInt,
+				Description: `Number of approvals by other entities an
+export request needs before the key material
+is released. Once set, key material can only
+be exported through export requests, and this
+cannot be lowered.`,
+			},
+
+			"export_approver_policy": {
+				Type: framework.TypeString,
+				Description: `Name of the policy the tokens approving
+export requests must have. Required to require
+export approvals, and cannot be changed once
+set.`,
+			},
 		},
 
 		Callbacks: map[logical.Operation]framework.OperationFunc{
@@ -104,6 +137,10 @@ func (b *backend) pathKeysConfigWrite(ctx context.Context, req *logical.Request,
 	originalDeletionAllowed := p.DeletionAllowed
 	originalExportable := p.Exportable
 	originalAllowPlaintextBackup := p.AllowPlaintextBackup
+	originalMaxOperationsPerVersion := p.MaxOperationsPerVersion
+	originalOperationLimitAction := p.OperationLimitAction
+	originalExportApprovalsRequired := p.ExportApprovalsRequired
+	originalExportApproverPolicy := p.ExportApproverPolicy
 
 	defer func() {
 		if retErr != nil || (resp != nil && resp.IsError()) {
@@ -112,6 +149,9 @@ func (b *backend) pathKeysConfigWrite(ctx context.Context, req *logical.Request,
 			p.DeletionAllowed = originalDeletionAllowed
 			p.Exportable = originalExportable
 			p.AllowPlaintextBackup = originalAllowPlaintextBackup
+			p.SetOperationLimit(originalMaxOperationsPerVersion, originalOperationLimitAction)
+			p.ExportApprovalsRequired = originalExportApprovalsRequired
+			p.ExportApproverPolicy = originalExportApproverPolicy
 		}
 	}()
 
@@ -224,6 +264,75 @@ func (b *backend) pathKeysConfigWrite(ctx context.Context, req *logical.Request,
 		}
 	}
 
+	maxOperationsRaw, maxOperationsOk := d.GetOk("max_operations_per_version")
+	operationLimitActionRaw, operationLimitActionOk := d.GetOk("operation_limit_action")
+	if maxOperationsOk || operationLimitActionOk {
+		maxOperations := p.MaxOperationsPerVersion
+		if maxOperationsOk {
+			if maxOperationsRaw.(int) < 0 {
+				return logical.ErrorResponse("max operations per version cannot be negative"), nil
+			}
+			maxOperations = uint64(maxOperationsRaw.(int))
+		}
+
+		operationLimitAction := p.OperationLimitAction
+		if operationLimitActionOk {
+			operationLimitAction = operationLimitActionRaw.(string)
+		}
+
+		if err := p.SetOperationLimit(maxOperations, operationLimitAction); err != nil {
+			return logical.ErrorResponse(err.Error()), nil
+		}
+
+		if p.MaxOperationsPerVersion != 0 && p.OperationLimitAction != keysutil.OperationLimitActionRefuse {
+			if p.Type == keysutil.KeyType_MANAGED_KEY {
+				return logical.ErrorResponse("managed keys can not be rotated once they reach their operation limit"), nil
+			}
+			if p.Imported && !p.AllowImportedKeyRotation {
+				return logical.ErrorResponse("imported keys which do not allow rotation can not be rotated once they reach their operation limit"), nil
+			}
+		}
+
+		if p.MaxOperationsPerVersion != originalMaxOperationsPerVersion || p.OperationLimitAction != originalOperationLimitAction {
+			persistNeeded = true
+		}
+	}
+
+	exportApprovalsRequiredRaw, ok := d.GetOk("export_approvals_required")
+	if ok {
+		exportApprovalsRequired := exportApprovalsRequiredRaw.(int)
+		if exportApprovalsRequired < 0 {
+			return logical.ErrorResponse("export approvals required cannot be negative"), nil
+		}
+
+		// Lowering the number of approvals would allow the key material to be
+		// exported with fewer approvals than agreed
+		if exportApprovalsRequired < p.ExportApprovalsRequired {
+			return logical.ErrorResponse("export approvals required cannot be lowered"), nil
+		}
+		if exportApprovalsRequired != p.ExportApprovalsRequired {
+			p.ExportApprovalsRequired = exportApprovalsRequired
+			persistNeeded = true
+		}
+	}
+
+	exportApproverPolicyRaw, ok := d.GetOk("export_approver_policy")
+	if ok {
+		exportApproverPolicy := exportApproverPolicyRaw.(string)
+
+		// Changing the policy would allow other tokens to approve exports
+		if p.ExportApproverPolicy != "" && exportApproverPolicy != p.ExportApproverPolicy {
+			return logical.ErrorResponse("export approver policy cannot be changed"), nil
+		}
+		if exportApproverPolicy != p.ExportApproverPolicy {
+			p.ExportApproverPolicy = exportApproverPolicy
+			persistNeeded = true
+		}
+	}
+	if p.ExportApprovalsRequired > 0 && p.ExportApproverPolicy == "" {
+		return logical.ErrorResponse("export approver policy is required when export approvals are required"), nil
+	}
+
 	if !persistNeeded {
 		resp, err := b.formatKeyPolicy(p, nil)
 		if err != nil {
@@ -242,7 +351,12 @@ func (b *backend) pathKeysConfigWrite(ctx context.Context, req *logical.Request,
 		return logical.ErrorResponse("min decryption version should not be less then min available version"), nil
 	}
 
-	if err := p.Persist(ctx, req.Storage); err != nil {
+	// The latest version may already have reached a newly set limit
+	if p.RotationRequired() {
+		if err := p.Rotate(ctx, req.Storage, b.GetRandomReader()); err != nil {
+			return nil, err
+		}
+	} else if err := p.Persist(ctx, req.Storage); err != nil {
 		return nil, err
 	}
 
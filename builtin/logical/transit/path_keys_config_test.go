@@ -16,6 +16,8 @@ import (
 	uuid "github.com/hashicorp/go-uuid"
 	"github.com/hashicorp/vault/api"
 	vaulthttp "github.com/hashicorp/vault/http"
+	"github.com/hashicorp/vault/sdk/helper/consts"
+	"github.com/hashicorp/vault/sdk/helper/keysutil"
 	"github.com/hashicorp/vault/sdk/logical"
 	"github.com/hashicorp/vault/vault"
 )
@@ -406,3 +408,178 @@ func TestTransit_UpdateKeyConfigWithAutorotation(t *testing.T) {
 		})
 	}
 }
+
+func TestTransit_OperationLimits(t *testing.T) {
+	b, storage := createBackendWithSysView(t)
+
+	doReq := func(path string, data map[string]interface{}) (*logical.Response, error) {
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Storage:   storage,
+			Operation: logical.UpdateOperation,
+			Path:      path,
+			Data:      data,
+		})
+		if err == nil && resp != nil && resp.IsError() {
+			err = resp.Error()
+		}
+		return resp, err
+	}
+	readKey := func() map[string]interface{} {
+		resp, err := b.HandleRequest(context.Background(), &logical.Request{
+			Storage:   storage,
+			Operation: logical.ReadOperation,
+			Path:      "keys/test",
+		})
+		if err != nil || resp == nil || resp.IsError() {
+			t.Fatalf("failed to read key: %v %#v", err, resp)
+		}
+		return resp.Data
+	}
+	encrypt := func(version int) error {
+		_, err := doReq("encrypt/test", map[string]interface{}{
+			"plaintext":   "dGhlIHF1aWNrIGJyb3duIGZveA==",
+			"key_version": version,
+		})
+		return err
+	}
+	encryptions := func(data map[string]interface{}, version string) uint64 {
+		counts := data["operation_counts"].(map[string]interface{})
+		return counts[version].(map[string]interface{})["encryptions"].(uint64)
+	}
+
+	if _, err := doReq("keys/test", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, data := range []map[string]interface{}{
+		{"max_operations_per_version": -1},
+		{"max_operations_per_version": 3, "operation_limit_action": "unknown"},
+	} {
+		if _, err := doReq("keys/test/config", data); err == nil {
+			t.Fatalf("expected error configuring %v", data)
+		}
+	}
+
+	if _, err := doReq("keys/test/config", map[string]interface{}{
+		"max_operations_per_version": 3,
+		"operation_limit_action":     "refuse",
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 3; i++ {
+		if err := encrypt(0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := encrypt(0); err == nil {
+		t.Fatal("expected encryption past the operation limit to be refused")
+	}
+
+	data := readKey()
+	if data["max_operations_per_version"].(uint64) != 3 || data["operation_limit_action"] != "refuse" {
+		t.Fatalf("unexpected operation limit: %#v", data)
+	}
+	if got := encryptions(data, "1"); got != 3 {
+		t.Fatalf("expected 3 encryptions, got %d", got)
+	}
+
+	// Counts are persisted by the periodic function
+	if err := b.periodicFunc(context.Background(), &logical.Request{Storage: storage}); err != nil {
+		t.Fatal(err)
+	}
+	b = createBackendWithSysViewWithStorage(t, storage)
+	if got := encryptions(readKey(), "1"); got != 3 {
+		t.Fatalf("expected 3 persisted encryptions, got %d", got)
+	}
+
+	// Switching to rotation rotates the key, as it has already reached its
+	// limit
+	if _, err := doReq("keys/test/config", map[string]interface{}{
+		"operation_limit_action": "rotate",
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if latest := readKey()["latest_version"].(int); latest != 2 {
+		t.Fatalf("expected key to be rotated, latest version is %d", latest)
+	}
+
+	// Reaching the limit of the latest version rotates the key, while
+	// versions which have reached it are refused
+	for i := 0; i < 3; i++ {
+		if err := encrypt(0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	data = readKey()
+	if latest := data["latest_version"].(int); latest != 3 {
+		t.Fatalf("expected key to be rotated, latest version is %d", latest)
+	}
+	if got := encryptions(data, "2"); got != 3 {
+		t.Fatalf("expected 3 encryptions, got %d", got)
+	}
+	if err := encrypt(1); err == nil {
+		t.Fatal("expected encryption with a version past the operation limit to be refused")
+	}
+	if err := encrypt(3); err != nil {
+		t.Fatal(err)
+	}
+
+	// Operations are reserved in storage before they are served, so those
+	// whose counts were not persisted still count towards the limit once the
+	// key is loaded by another node
+	if _, err := doReq("keys/test/config", map[string]interface{}{
+		"max_operations_per_version": 100000,
+		"operation_limit_action":     "refuse",
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := encrypt(0); err != nil {
+		t.Fatal(err)
+	}
+	b = createBackendWithSysViewWithStorage(t, storage)
+	data = readKey()
+	counts := data["operation_counts"].(map[string]interface{})["3"].(map[string]interface{})
+	if counts["encryptions"].(uint64) != 1 {
+		t.Fatalf("expected 1 persisted encryption, got %#v", counts)
+	}
+	p, _, err := b.GetPolicy(context.Background(), keysutil.PolicyRequest{
+		Storage: storage,
+		Name:    "test",
+	}, b.GetRandomReader())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.OperationsReserved(1) {
+		t.Fatal("expected the reservation of the previous node to be used up")
+	}
+	if err := encrypt(0); err != nil {
+		t.Fatal(err)
+	}
+
+	// Performance standbys can not reserve operations, so forward requests
+	// to the active node
+	sysView := logical.TestSystemView()
+	sysView.ReplicationStateVal = consts.ReplicationPerformanceStandby
+	standby, _ := Backend(context.Background(), &logical.BackendConfig{
+		StorageView: storage,
+		System:      sysView,
+	})
+	if err := standby.Backend.Setup(context.Background(), &logical.BackendConfig{
+		StorageView: storage,
+		System:      sysView,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := standby.HandleRequest(context.Background(), &logical.Request{
+		Storage:   storage,
+		Operation: logical.UpdateOperation,
+		Path:      "encrypt/test",
+		Data: map[string]interface{}{
+			"plaintext": "dGhlIHF1aWNrIGJyb3duIGZveA==",
+		},
+	})
+	if err != logical.ErrReadOnly {
+		t.Fatalf("expected the request to be forwarded, got %v: %#v", err, resp)
+	}
+}
@@ -49,6 +49,14 @@ func (b *backend) pathRewrap() *framework.Path {
 				Description: "Nonce for when convergent encryption is used",
 			},
 
+			"associated_data": {
+				Type: framework.TypeString,
+				Description: `
+Base64 encoded associated data the ciphertext was encrypted with, which is
+also used to encrypt the new ciphertext. For format-preserving encryption
+keys, the associated data is used as the tweak.`,
+			},
+
 			"key_version": {
 				Type: framework.TypeInt,
 				Description: `The version of the key to use for encryption.
@@ -60,7 +68,8 @@ to the min_encryption_version configured on the key.`,
 				Type: framework.TypeSlice,
 				Description: `
 Specifies a list of items to be re-encrypted in a single batch. When this parameter is set,
-if the parameters 'ciphertext', 'context' and 'nonce' are also set, they will be ignored.
+if the parameters 'ciphertext', 'context', 'nonce' and 'associated_data' are also set, they
+will be ignored.
 Any batch output will preserve the order of the batch input.`,
 			},
 		},
@@ -95,10 +104,11 @@ func (b *backend) pathRewrapWrite(ctx context.Context, req *logical.Request, d *
 
 		batchInputItems = make([]BatchRequestItem, 1)
 		batchInputItems[0] = BatchRequestItem{
-			Ciphertext: ciphertext,
-			Context:    d.Get("context").(string),
-			Nonce:      d.Get("nonce").(string),
-			KeyVersion: d.Get("key_version").(int),
+			Ciphertext:     ciphertext,
+			Context:        d.Get("context").(string),
+			Nonce:          d.Get("nonce").(string),
+			KeyVersion:     d.Get("key_version").(int),
+			AssociatedData: d.Get("associated_data").(string),
 		}
 	}
 
@@ -145,8 +155,9 @@ func (b *backend) pathRewrapWrite(ctx context.Context, req *logical.Request, d *
 	if p == nil {
 		return logical.ErrorResponse("encryption key not found"), logical.ErrInvalidRequest
 	}
-	if !b.System().CachingDisabled() {
-		p.Lock(false)
+	defer b.trackKeyUsage(ctx, req.Storage, p)
+	if err := b.lockKeyForOperations(ctx, req.Storage, p, len(batchInputItems)); err != nil {
+		return nil, err
 	}
 	defer p.Unlock()
 
@@ -161,7 +172,17 @@ func (b *backend) pathRewrapWrite(ctx context.Context, req *logical.Request, d *
 			continue
 		}
 
-		plaintext, err := p.Decrypt(item.DecodedContext, item.DecodedNonce, item.Ciphertext)
+		var factory interface{}
+		if item.AssociatedData != "" {
+			if !p.Type.AssociatedDataSupported() {
+				batchResponseItems[i].Error = fmt.Sprintf("'[%d].associated_data' provided for non-AEAD cipher suite %v", i, p.Type.String())
+				continue
+			}
+
+			factory = AssocDataFactory{item.AssociatedData}
+		}
+
+		plaintext, err := p.DecryptWithFactory(item.DecodedContext, item.DecodedNonce, item.Ciphertext, factory)
 		if err != nil {
 			switch err.(type) {
 			case errutil.UserError:
@@ -176,7 +197,7 @@ func (b *backend) pathRewrapWrite(ctx context.Context, req *logical.Request, d *
 			warnAboutNonceUsage = true
 		}
 
-		ciphertext, err := p.Encrypt(item.KeyVersion, item.DecodedContext, item.DecodedNonce, plaintext)
+		ciphertext, err := p.EncryptWithFactory(item.KeyVersion, item.DecodedContext, item.DecodedNonce, plaintext, factory)
 		if err != nil {
 			switch err.(type) {
 			case errutil.UserError:
@@ -75,6 +75,10 @@ type batchResponseVerifyItem struct {
 
 const defaultHashAlgorithm = "sha2-256"
 
+// verifyFields are the fields holding the value to verify, of which each
+// verification request gives exactly one.
+var verifyFields = []string{"signature", "hmac", "cmac", "kmac"}
+
 func (b *backend) pathSign() *framework.Path {
 	return &framework.Path{
 		Pattern: "sign/" + framework.GenericNameRegex("name") + framework.OptionalParamRegex("urlalgorithm"),
@@ -216,6 +220,28 @@ derivation is enabled; currently only available with ed25519 keys.`,
 				Description: "The HMAC, including vault header/key version",
 			},
 
+			"cmac": {
+				Type:        framework.TypeString,
+				Description: "The AES-CMAC, including vault header/key version",
+			},
+
+			"kmac": {
+				Type:        framework.TypeString,
+				Description: "The KMAC, including vault header/key version",
+			},
+
+			"kmac_algorithm": {
+				Type:    framework.TypeString,
+				Default: "kmac256",
+				Description: `The KMAC algorithm the KMAC was generated with, "kmac128" or "kmac256".
+Defaults to "kmac256".`,
+			},
+
+			"customization": {
+				Type:        framework.TypeString,
+				Description: "The base64-encoded customization string the KMAC was generated with",
+			},
+
 			"input": {
 				Type:        framework.TypeString,
 				Description: "The base64-encoded input data to verify",
@@ -279,7 +305,7 @@ Options are 'auto' (the default used by Golang, causing the salt to be as large
 			"batch_input": {
 				Type: framework.TypeSlice,
 				Description: `Specifies a list of items for processing. When this parameter is set,
-any supplied  'input', 'hmac' or 'signature' parameters will be ignored. Responses are returned in the
+any supplied  'input', 'hmac', 'cmac', 'kmac' or 'signature' parameters will be ignored. Responses are returned in the
 'batch_results' array component of the 'data' element of the response. Any batch output will
 preserve the order of the batch input`,
 			},
@@ -364,8 +390,9 @@ func (b *backend) pathSignWrite(ctx context.Context, req *logical.Request, d *fr
 	if p == nil {
 		return logical.ErrorResponse("signing key not found"), logical.ErrInvalidRequest
 	}
-	if !b.System().CachingDisabled() {
-		p.Lock(false)
+	defer b.trackKeyUsage(ctx, req.Storage, p)
+	if err := b.lockKeyForOperations(ctx, req.Storage, p, batchSize(d)); err != nil {
+		return nil, err
 	}
 	defer p.Unlock()
 
@@ -531,49 +558,69 @@ func (b *backend) pathVerifyWrite(ctx context.Context, req *logical.Request, d *
 		if sig, ok := d.GetOk("signature"); ok {
 			batchInputItems[0]["signature"] = sig.(string)
 		}
-		if hmac, ok := d.GetOk("hmac"); ok {
-			batchInputItems[0]["hmac"] = hmac.(string)
+		for _, field := range verifyFields {
+			if value, ok := d.GetOk(field); ok {
+				batchInputItems[0][field] = value.(string)
+			}
 		}
 		batchInputItems[0]["context"] = d.Get("context").(string)
 	}
 
-	// For simplicity, 'signature' and 'hmac' cannot be mixed across batch_input elements.
-	// If one batch_input item is 'signature', they all must be 'signature'.
-	// If one batch_input item is 'hmac', they all must be 'hmac'.
-	sigFound := false
-	hmacFound := false
+	// For simplicity, 'signature', 'hmac', 'cmac' and 'kmac' cannot be mixed
+	// across batch_input elements. If one batch_input item is 'signature',
+	// they all must be 'signature', and likewise for the others.
+	found := map[string]bool{}
 	missing := false
 	for _, v := range batchInputItems {
-		if _, ok := v["signature"]; ok {
-			sigFound = true
-		} else if _, ok := v["hmac"]; ok {
-			hmacFound = true
-		} else {
+		itemFound := false
+		for _, field := range verifyFields {
+			if _, ok := v[field]; ok {
+				found[field] = true
+				itemFound = true
+				break
+			}
+		}
+		if !itemFound {
 			missing = true
 		}
 	}
 
-	switch {
-	case batchInputRaw == nil && sigFound && hmacFound:
-		return logical.ErrorResponse("provide one of 'signature' or 'hmac'"), logical.ErrInvalidRequest
+	var field string
+	for _, f := range verifyFields {
+		if found[f] {
+			field = f
+			break
+		}
+	}
 
-	case batchInputRaw == nil && !sigFound && !hmacFound:
-		return logical.ErrorResponse("neither a 'signature' nor an 'hmac' were given to verify"), logical.ErrInvalidRequest
+	switch {
+	case batchInputRaw == nil && len(found) > 1:
+		return logical.ErrorResponse("provide one of 'signature', 'hmac', 'cmac' or 'kmac'"), logical.ErrInvalidRequest
 
-	case sigFound && hmacFound:
-		return logical.ErrorResponse("elements of batch_input must all provide 'signature' or all provide 'hmac'"), logical.ErrInvalidRequest
+	case batchInputRaw == nil && len(found) == 0:
+		return logical.ErrorResponse("neither a 'signature', 'hmac', 'cmac' nor 'kmac' were given to verify"), logical.ErrInvalidRequest
 
-	case missing && sigFound:
-		return logical.ErrorResponse("some elements of batch_input are missing 'signature'"), logical.ErrInvalidRequest
+	case len(found) > 1:
+		return logical.ErrorResponse("elements of batch_input must all provide 'signature', all provide 'hmac', all provide 'cmac' or all provide 'kmac'"), logical.ErrInvalidRequest
 
-	case missing && hmacFound:
-		return logical.ErrorResponse("some elements of batch_input are missing 'hmac'"), logical.ErrInvalidRequest
+	case missing && field != "":
+		return logical.ErrorResponse(fmt.Sprintf("some elements of batch_input are missing '%s'", field)), logical.ErrInvalidRequest
 
 	case missing:
-		return logical.ErrorResponse("no batch_input elements have 'signature' or 'hmac'"), logical.ErrInvalidRequest
+		return logical.ErrorResponse("no batch_input elements have 'signature', 'hmac', 'cmac' or 'kmac'"), logical.ErrInvalidRequest
 
-	case hmacFound:
+	case field == "hmac":
 		return b.pathHMACVerify(ctx, req, d)
+
+	case field == "cmac":
+		return b.macVerify(ctx, req, d, field, cmacFunc)
+
+	case field == "kmac":
+		mac, err := kmacFunc(d, "kmac_algorithm")
+		if err != nil {
+			return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+		}
+		return b.macVerify(ctx, req, d, field, mac)
 	}
 
 	name := d.Get("name").(string)
@@ -745,8 +792,9 @@ const pathSignHelpSyn = `Generate a signature for input data using the named key
 const pathSignHelpDesc = `
 Generates a signature of the input data using the named key and the given hash algorithm.
 `
-const pathVerifyHelpSyn = `Verify a signature or HMAC for input data created using the named key`
+const pathVerifyHelpSyn = `Verify a signature, HMAC, CMAC or KMAC for input data created using the named key`
 
 const pathVerifyHelpDesc = `
-Verifies a signature or HMAC of the input data using the named key and the given hash algorithm.
+Verifies a signature, HMAC, CMAC or KMAC of the input data using the named key and the given
+algorithm.
 `
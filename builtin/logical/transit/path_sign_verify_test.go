@@ -15,6 +15,7 @@ import (
 	"github.com/hashicorp/vault/sdk/helper/keysutil"
 	"github.com/hashicorp/vault/sdk/logical"
 	"github.com/mitchellh/mapstructure"
+	"github.com/stretchr/testify/require"
 	"golang.org/x/crypto/ed25519"
 )
 
@@ -712,6 +713,85 @@ func TestTransit_SignVerify_ED25519(t *testing.T) {
 	verifyRequest(req, false, outcome, "bar", goodsig, true)
 }
 
+// TestTransit_SignVerify_MLDSA ensures that ML-DSA and hybrid Ed25519 and
+// ML-DSA keys sign and verify input, and that signatures are only valid for
+// the key version and input they were made with.
+func TestTransit_SignVerify_MLDSA(t *testing.T) {
+	for _, keyType := range []string{"ml-dsa-44", "ml-dsa-65", "ml-dsa-87", "ed25519-ml-dsa-65"} {
+		keyType := keyType
+		t.Run(keyType, func(t *testing.T) {
+			b, storage := createBackendWithSysView(t)
+
+			resp, err := b.HandleRequest(context.Background(), &logical.Request{
+				Storage:   storage,
+				Operation: logical.UpdateOperation,
+				Path:      "keys/foo",
+				Data: map[string]interface{}{
+					"type": keyType,
+				},
+			})
+			require.NoError(t, err)
+			require.False(t, resp.IsError(), "got error response: %#v", resp)
+			require.Equal(t, true, resp.Data["supports_signing"])
+
+			keys := resp.Data["keys"].(map[string]map[string]interface{})
+			require.Equal(t, keyType, keys["1"]["name"])
+			require.NotEmpty(t, keys["1"]["public_key"])
+
+			input := base64.StdEncoding.EncodeToString([]byte("the quick brown fox"))
+			sign := func() string {
+				t.Helper()
+				resp, err := b.HandleRequest(context.Background(), &logical.Request{
+					Storage:   storage,
+					Operation: logical.UpdateOperation,
+					Path:      "sign/foo",
+					Data: map[string]interface{}{
+						"input": input,
+					},
+				})
+				require.NoError(t, err)
+				require.False(t, resp.IsError(), "got error response: %#v", resp)
+				return resp.Data["signature"].(string)
+			}
+			verify := func(input, signature string) bool {
+				t.Helper()
+				resp, err := b.HandleRequest(context.Background(), &logical.Request{
+					Storage:   storage,
+					Operation: logical.UpdateOperation,
+					Path:      "verify/foo",
+					Data: map[string]interface{}{
+						"input":     input,
+						"signature": signature,
+					},
+				})
+				require.NoError(t, err)
+				require.False(t, resp.IsError(), "got error response: %#v", resp)
+				return resp.Data["valid"].(bool)
+			}
+
+			sig := sign()
+			require.True(t, strings.HasPrefix(sig, "vault:v1:"))
+			require.True(t, verify(input, sig))
+			require.False(t, verify(base64.StdEncoding.EncodeToString([]byte("jumps over the lazy dog")), sig))
+
+			// Signatures made with the first version must not verify as
+			// signatures of the second version.
+			_, err = b.HandleRequest(context.Background(), &logical.Request{
+				Storage:   storage,
+				Operation: logical.UpdateOperation,
+				Path:      "keys/foo/rotate",
+			})
+			require.NoError(t, err)
+			require.True(t, verify(input, sig))
+			require.False(t, verify(input, strings.Replace(sig, "vault:v1:", "vault:v2:", 1)))
+
+			sig = sign()
+			require.True(t, strings.HasPrefix(sig, "vault:v2:"))
+			require.True(t, verify(input, sig))
+		})
+	}
+}
+
 func TestTransit_SignVerify_RSA_PSS(t *testing.T) {
 	t.Run("2048", func(t *testing.T) {
 		testTransit_SignVerify_RSA_PSS(t, 2048)
@@ -0,0 +1,320 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package transit
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/hashicorp/vault/sdk/framework"
+	"github.com/hashicorp/vault/sdk/helper/errutil"
+	"github.com/hashicorp/vault/sdk/helper/keysutil"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+// StreamErrorTrailer is the HTTP trailer set when an error is encountered
+// after a streamed response has started. A streamed response is only complete
+// when this trailer is absent.
+const StreamErrorTrailer = "X-Vault-Stream-Error"
+
+func (b *backend) pathEncryptStream() *framework.Path {
+	return &framework.Path{
+		Pattern: "encrypt-stream/" + framework.GenericNameRegex("name"),
+
+		DisplayAttrs: &framework.DisplayAttributes{
+			OperationPrefix: operationPrefixTransit,
+			OperationVerb:   "encrypt-stream",
+		},
+
+		Fields: map[string]*framework.FieldSchema{
+			"name": {
+				Type:        framework.TypeString,
+				Description: "Name of the key",
+			},
+
+			"context": {
+				Type: framework.TypeString,
+				Description: `
+Base64 encoded context for key derivation. Required if key derivation is
+enabled. Provided as a query parameter.`,
+			},
+
+			"key_version": {
+				Type: framework.TypeInt,
+				Description: `
+The version of the key to use for encryption. Must be 0 (for latest) or a
+value greater than or equal to the min_encryption_version configured on the
+key. Provided as a query parameter.`,
+			},
+
+			"chunk_size": {
+				Type:    framework.TypeInt,
+				Default: keysutil.DefaultStreamChunkSize,
+				Description: `
+The size in bytes of the plaintext of each encrypted chunk, between 1KiB and
+16MiB. Defaults to 64KiB. Provided as a query parameter.`,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathEncryptStreamWrite,
+		},
+
+		HelpSynopsis:    pathEncryptStreamHelpSyn,
+		HelpDescription: pathEncryptStreamHelpDesc,
+	}
+}
+
+func (b *backend) pathDecryptStream() *framework.Path {
+	return &framework.Path{
+		Pattern: "decrypt-stream/" + framework.GenericNameRegex("name"),
+
+		DisplayAttrs: &framework.DisplayAttributes{
+			OperationPrefix: operationPrefixTransit,
+			OperationVerb:   "decrypt-stream",
+		},
+
+		Fields: map[string]*framework.FieldSchema{
+			"name": {
+				Type:        framework.TypeString,
+				Description: "Name of the key",
+			},
+
+			"context": {
+				Type: framework.TypeString,
+				Description: `
+Base64 encoded context for key derivation. Required if key derivation is
+enabled. Provided as a query parameter.`,
+			},
+		},
+
+		Callbacks: map[logical.Operation]framework.OperationFunc{
+			logical.UpdateOperation: b.pathDecryptStreamWrite,
+		},
+
+		HelpSynopsis:    pathDecryptStreamHelpSyn,
+		HelpDescription: pathDecryptStreamHelpDesc,
+	}
+}
+
+func (b *backend) pathEncryptStreamWrite(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	body, err := streamRequestBody(req)
+	if err != nil {
+		return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+	}
+
+	derivationContext, err := decodeStreamContext(d)
+	if err != nil {
+		return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+	}
+
+	w := &streamResponseWriter{w: req.ResponseWriter}
+
+	// The key is only needed to set up the stream, so the policy lock is not
+	// held while the stream is read
+	encrypter, err := func() (*keysutil.StreamEncrypter, error) {
+		p, err := b.getStreamPolicy(ctx, req, d, 1)
+		if err != nil {
+			return nil, err
+		}
+		defer b.trackKeyUsage(ctx, req.Storage, p)
+		defer p.Unlock()
+
+		return p.NewStreamEncrypter(d.Get("key_version").(int), derivationContext, w, d.Get("chunk_size").(int))
+	}()
+	if err != nil {
+		return streamErrorResponse(err)
+	}
+
+	if _, err := io.Copy(encrypter, body); err != nil {
+		return w.fail(err)
+	}
+	if err := encrypter.Close(); err != nil {
+		return w.fail(err)
+	}
+
+	return nil, nil
+}
+
+func (b *backend) pathDecryptStreamWrite(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+	body, err := streamRequestBody(req)
+	if err != nil {
+		return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+	}
+
+	derivationContext, err := decodeStreamContext(d)
+	if err != nil {
+		return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+	}
+
+	// Read the stream header before taking the policy lock, so a slow client
+	// can not hold it
+	header := make([]byte, keysutil.StreamHeaderSize)
+	if _, err := io.ReadFull(body, header); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return logical.ErrorResponse("invalid ciphertext: stream header is truncated"), logical.ErrInvalidRequest
+		}
+		return streamErrorResponse(err)
+	}
+
+	decrypter, err := func() (*keysutil.StreamDecrypter, error) {
+		p, err := b.getStreamPolicy(ctx, req, d, 0)
+		if err != nil {
+			return nil, err
+		}
+		defer p.Unlock()
+
+		return p.NewStreamDecrypter(derivationContext, io.MultiReader(bytes.NewReader(header), body))
+	}()
+	if err != nil {
+		return streamErrorResponse(err)
+	}
+
+	w := &streamResponseWriter{w: req.ResponseWriter}
+	if _, err := io.Copy(w, decrypter); err != nil {
+		return w.fail(err)
+	}
+
+	// An empty plaintext still needs the response headers to be sent
+	if !w.w.Written() {
+		w.Write(nil)
+	}
+
+	return nil, nil
+}
+
+// getStreamPolicy returns the locked policy named in the request, ready to
+// serve the given number of counted operations.
+func (b *backend) getStreamPolicy(ctx context.Context, req *logical.Request, d *framework.FieldData, operations int) (*keysutil.Policy, error) {
+	p, _, err := b.GetPolicy(ctx, keysutil.PolicyRequest{
+		Storage: req.Storage,
+		Name:    d.Get("name").(string),
+	}, b.GetRandomReader())
+	if err != nil {
+		return nil, err
+	}
+	if p == nil {
+		return nil, errutil.UserError{Err: "encryption key not found"}
+	}
+	if operations > 0 {
+		if err := b.lockKeyForOperations(ctx, req.Storage, p, operations); err != nil {
+			return nil, err
+		}
+	} else if !b.System().CachingDisabled() {
+		p.Lock(false)
+	}
+
+	return p, nil
+}
+
+// streamRequestBody returns the request body to be streamed. Responses are
+// written while the body is still being read, which HTTP/1.x servers only
+// allow once full duplex has been enabled; where it can not be, the body is
+// read in full before the response is written.
+func streamRequestBody(req *logical.Request) (io.Reader, error) {
+	if req.HTTPRequest == nil || req.HTTPRequest.Body == nil || req.ResponseWriter == nil {
+		return nil, errors.New("streaming requests must be made over HTTP with the data as the request body")
+	}
+
+	body := req.HTTPRequest.Body
+	if req.HTTPRequest.ProtoMajor >= 2 {
+		return body, nil
+	}
+
+	err := http.NewResponseController(req.ResponseWriter).EnableFullDuplex()
+	switch {
+	case err == nil:
+		return body, nil
+	case errors.Is(err, http.ErrNotSupported):
+		buf, err := io.ReadAll(body)
+		if err != nil {
+			return nil, err
+		}
+		return bytes.NewReader(buf), nil
+	default:
+		return nil, err
+	}
+}
+
+func decodeStreamContext(d *framework.FieldData) ([]byte, error) {
+	contextRaw := d.Get("context").(string)
+	if contextRaw == "" {
+		return nil, nil
+	}
+
+	derivationContext, err := base64.StdEncoding.DecodeString(contextRaw)
+	if err != nil {
+		return nil, errors.New("failed to base64-decode context")
+	}
+
+	return derivationContext, nil
+}
+
+func streamErrorResponse(err error) (*logical.Response, error) {
+	switch err.(type) {
+	case errutil.UserError:
+		return logical.ErrorResponse(err.Error()), logical.ErrInvalidRequest
+	default:
+		return nil, err
+	}
+}
+
+// streamResponseWriter writes a streamed response, sending the response
+// headers with the first write.
+type streamResponseWriter struct {
+	w *logical.HTTPResponseWriter
+}
+
+func (s *streamResponseWriter) Write(p []byte) (int, error) {
+	if !s.w.Written() {
+		s.w.Header().Set("Content-Type", "application/octet-stream")
+		s.w.Header().Set("Trailer", StreamErrorTrailer)
+	}
+
+	return s.w.Write(p)
+}
+
+// fail handles an error encountered while streaming. If the response has not
+// started the error is returned as usual, otherwise it is reported in the
+// error trailer.
+func (s *streamResponseWriter) fail(err error) (*logical.Response, error) {
+	if !s.w.Written() {
+		return streamErrorResponse(err)
+	}
+
+	s.w.Header().Set(StreamErrorTrailer, err.Error())
+	return nil, fmt.Errorf("error streaming response: %w", err)
+}
+
+const pathEncryptStreamHelpSyn = `Encrypt a stream of data using a named key`
+
+const pathEncryptStreamHelpDesc = `
+This path encrypts the request body as a stream, returning the ciphertext as
+the response body. The plaintext is split into chunks, each of which is
+encrypted and authenticated separately, so payloads of any size can be
+encrypted without being held in memory. Only aes128-gcm96, aes256-gcm96 and
+chacha20-poly1305 keys without convergent encryption are supported.
+
+Parameters are provided in the query string. If an error is encountered after
+the response has started, it is reported in the X-Vault-Stream-Error trailer
+and the ciphertext must be discarded.
+`
+
+const pathDecryptStreamHelpSyn = `Decrypt a stream of data using a named key`
+
+const pathDecryptStreamHelpDesc = `
+This path decrypts a ciphertext stream returned by the encrypt-stream path,
+given as the request body, returning the plaintext as the response body.
+Each chunk is authenticated before its plaintext is returned, and
+reordering, removal or truncation of chunks is detected.
+
+Parameters are provided in the query string. If an error is encountered after
+the response has started, it is reported in the X-Vault-Stream-Error trailer
+and the plaintext received so far must be discarded.
+`
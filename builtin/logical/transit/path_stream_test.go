@@ -0,0 +1,246 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package transit
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/hashicorp/vault/api"
+	vaulthttp "github.com/hashicorp/vault/http"
+	"github.com/hashicorp/vault/internalshared/configutil"
+	"github.com/hashicorp/vault/sdk/helper/keysutil"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/hashicorp/vault/vault"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransit_Stream(t *testing.T) {
+	b, storage := createBackendWithStorage(t)
+
+	doStreamReq := func(path string, data map[string]interface{}, body []byte) (*httptest.ResponseRecorder, *logical.Response, error) {
+		recorder := httptest.NewRecorder()
+		req := &logical.Request{
+			Storage:        storage,
+			Operation:      logical.UpdateOperation,
+			Path:           path,
+			Data:           data,
+			HTTPRequest:    httptest.NewRequest(http.MethodPost, "/v1/transit/"+path, bytes.NewReader(body)),
+			ResponseWriter: logical.NewHTTPResponseWriter(recorder),
+		}
+		resp, err := b.HandleRequest(context.Background(), req)
+		return recorder, resp, err
+	}
+
+	for _, keyType := range []string{"aes128-gcm96", "aes256-gcm96", "chacha20-poly1305"} {
+		keyType := keyType
+		t.Run(keyType, func(t *testing.T) {
+			_, err := b.HandleRequest(context.Background(), &logical.Request{
+				Storage:   storage,
+				Operation: logical.UpdateOperation,
+				Path:      "keys/" + keyType,
+				Data: map[string]interface{}{
+					"type": keyType,
+				},
+			})
+			require.NoError(t, err)
+
+			plaintext := make([]byte, 3*keysutil.MinStreamChunkSize+17)
+			_, err = rand.Read(plaintext)
+			require.NoError(t, err)
+
+			recorder, _, err := doStreamReq("encrypt-stream/"+keyType, map[string]interface{}{
+				"chunk_size": keysutil.MinStreamChunkSize,
+			}, plaintext)
+			require.NoError(t, err)
+			require.Equal(t, http.StatusOK, recorder.Code)
+			require.Equal(t, "application/octet-stream", recorder.Header().Get("Content-Type"))
+			require.Empty(t, recorder.Header().Get(StreamErrorTrailer))
+			ciphertext := recorder.Body.Bytes()
+
+			recorder, _, err = doStreamReq("decrypt-stream/"+keyType, nil, ciphertext)
+			require.NoError(t, err)
+			require.Empty(t, recorder.Header().Get(StreamErrorTrailer))
+			require.Equal(t, plaintext, recorder.Body.Bytes())
+
+			// A truncated stream fails once the plaintext of the preceding
+			// chunks has been returned
+			recorder, _, err = doStreamReq("decrypt-stream/"+keyType, nil, ciphertext[:keysutil.StreamHeaderSize+2*(keysutil.MinStreamChunkSize+16)])
+			require.Error(t, err)
+			require.NotEmpty(t, recorder.Header().Get(StreamErrorTrailer))
+			require.Equal(t, plaintext[:keysutil.MinStreamChunkSize], recorder.Body.Bytes())
+
+			// An invalid header fails before the response starts
+			_, resp, err := doStreamReq("decrypt-stream/"+keyType, nil, ciphertext[:10])
+			require.ErrorIs(t, err, logical.ErrInvalidRequest)
+			require.True(t, resp.IsError())
+		})
+	}
+
+	// Derived keys require a context
+	_, err := b.HandleRequest(context.Background(), &logical.Request{
+		Storage:   storage,
+		Operation: logical.UpdateOperation,
+		Path:      "keys/derived",
+		Data: map[string]interface{}{
+			"derived": true,
+		},
+	})
+	require.NoError(t, err)
+
+	derivationContext := base64.StdEncoding.EncodeToString([]byte("context"))
+	recorder, _, err := doStreamReq("encrypt-stream/derived", map[string]interface{}{
+		"context": derivationContext,
+	}, []byte("Sphinx of black quartz, judge my vow"))
+	require.NoError(t, err)
+	ciphertext := recorder.Body.Bytes()
+
+	_, resp, err := doStreamReq("decrypt-stream/derived", nil, ciphertext)
+	require.ErrorIs(t, err, logical.ErrInvalidRequest)
+	require.True(t, resp.IsError())
+
+	recorder, _, err = doStreamReq("decrypt-stream/derived", map[string]interface{}{
+		"context": derivationContext,
+	}, ciphertext)
+	require.NoError(t, err)
+	require.Equal(t, "Sphinx of black quartz, judge my vow", recorder.Body.String())
+
+	// Asymmetric keys can not be used for streaming encryption
+	_, err = b.HandleRequest(context.Background(), &logical.Request{
+		Storage:   storage,
+		Operation: logical.UpdateOperation,
+		Path:      "keys/rsa",
+		Data: map[string]interface{}{
+			"type": "rsa-2048",
+		},
+	})
+	require.NoError(t, err)
+	_, resp, err = doStreamReq("encrypt-stream/rsa", nil, []byte("Sphinx of black quartz, judge my vow"))
+	require.ErrorIs(t, err, logical.ErrInvalidRequest)
+	require.True(t, resp.IsError())
+
+	// Requests must carry the HTTP request
+	resp, err = b.HandleRequest(context.Background(), &logical.Request{
+		Storage:   storage,
+		Operation: logical.UpdateOperation,
+		Path:      "encrypt-stream/aes256-gcm96",
+	})
+	require.ErrorIs(t, err, logical.ErrInvalidRequest)
+	require.True(t, resp.IsError())
+}
+
+// TestTransit_Stream_HTTP streams a payload larger than the net/http server
+// will buffer through a cluster, ensuring the response can be written while the
+// request body is read.
+func TestTransit_Stream_HTTP(t *testing.T) {
+	coreConfig := &vault.CoreConfig{
+		LogicalBackends: map[string]logical.Factory{
+			"transit": Factory,
+		},
+	}
+	cluster := vault.NewTestCluster(t, coreConfig, &vault.TestClusterOptions{
+		HandlerFunc: vaulthttp.Handler,
+	})
+	cluster.Start()
+	defer cluster.Cleanup()
+	cores := cluster.Cores
+	vault.TestWaitActive(t, cores[0].Core)
+	client := cores[0].Client
+
+	err := client.Sys().Mount("transit", &api.MountInput{
+		Type: "transit",
+	})
+	require.NoError(t, err)
+	_, err = client.Logical().Write("transit/keys/stream", nil)
+	require.NoError(t, err)
+
+	plaintext := make([]byte, 4*1024*1024+17)
+	_, err = rand.Read(plaintext)
+	require.NoError(t, err)
+
+	doStreamReq := func(path string, body io.Reader) []byte {
+		t.Helper()
+		req := client.NewRequest(http.MethodPost, "/v1/transit/"+path)
+		req.Body = body
+		resp, err := client.RawRequestWithContext(context.Background(), req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		out, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		require.Empty(t, resp.Trailer.Get(StreamErrorTrailer))
+		return out
+	}
+
+	ciphertext := doStreamReq("encrypt-stream/stream", bytes.NewReader(plaintext))
+	decrypted := doStreamReq("decrypt-stream/stream", bytes.NewReader(ciphertext))
+	require.Equal(t, plaintext, decrypted)
+}
+
+// TestTransit_Stream_HTTP_MaxRequestSize ensures that streamed payloads are
+// limited by max_stream_request_size rather than max_request_size.
+func TestTransit_Stream_HTTP_MaxRequestSize(t *testing.T) {
+	coreConfig := &vault.CoreConfig{
+		LogicalBackends: map[string]logical.Factory{
+			"transit": Factory,
+		},
+	}
+	cluster := vault.NewTestCluster(t, coreConfig, &vault.TestClusterOptions{
+		HandlerFunc: vaulthttp.Handler,
+		NumCores:    1,
+		DefaultHandlerProperties: vault.HandlerProperties{
+			ListenerConfig: &configutil.Listener{
+				MaxStreamRequestSize: vaulthttp.DefaultMaxRequestSize + 1024*1024,
+			},
+		},
+	})
+	cluster.Start()
+	defer cluster.Cleanup()
+	client := cluster.Cores[0].Client
+
+	require.NoError(t, client.Sys().Mount("transit", &api.MountInput{
+		Type: "transit",
+	}))
+	_, err := client.Logical().Write("transit/keys/stream", nil)
+	require.NoError(t, err)
+
+	encrypt := func(size int) ([]byte, string, error) {
+		t.Helper()
+		req := client.NewRequest(http.MethodPost, "/v1/transit/encrypt-stream/stream")
+		req.Body = bytes.NewReader(make([]byte, size))
+		resp, err := client.RawRequestWithContext(context.Background(), req)
+		if err != nil {
+			return nil, "", err
+		}
+		defer resp.Body.Close()
+
+		out, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return out, resp.Trailer.Get(StreamErrorTrailer), nil
+	}
+
+	// A payload larger than the default max_request_size is accepted.
+	out, streamErr, err := encrypt(vaulthttp.DefaultMaxRequestSize + 1)
+	require.NoError(t, err)
+	require.Empty(t, streamErr)
+	require.Greater(t, len(out), vaulthttp.DefaultMaxRequestSize)
+
+	// A payload larger than max_stream_request_size is rejected.
+	_, streamErr, err = encrypt(vaulthttp.DefaultMaxRequestSize + 2*1024*1024)
+	if err == nil {
+		require.NotEmpty(t, streamErr)
+	}
+
+	// Other requests are still subject to max_request_size.
+	_, err = client.Logical().Write("transit/encrypt/stream", map[string]interface{}{
+		"plaintext": base64.StdEncoding.EncodeToString(make([]byte, vaulthttp.DefaultMaxRequestSize)),
+	})
+	require.Error(t, err)
+}
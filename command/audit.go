@@ -24,7 +24,8 @@ func (c *AuditCommand) Help() string {
 Usage: vault audit <subcommand> [options] [args]
 
   This command groups subcommands for interacting with Vault's audit devices.
-  Users can list, enable, and disable audit devices.
+  Users can list, enable, and disable audit devices, and verify the hash chain
+  of a local audit log.
 
   *NOTE*: Once an audit device has been enabled, failure to audit could prevent
   Vault from servicing future requests. It is highly recommended that you enable
@@ -38,6 +39,10 @@ Usage: vault audit <subcommand> [options] [args]
 
        $ vault audit enable file file_path=/var/log/audit.log
 
+  Verify the hash chain of a local audit log:
+
+      $ vault audit verify /var/log/audit.log
+
   Please see the individual subcommand help for detailed usage information.
 `
 
@@ -0,0 +1,198 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package command
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/hashicorp/cli"
+	"github.com/hashicorp/vault/audit"
+	homedir "github.com/mitchellh/go-homedir"
+	"github.com/posener/complete"
+)
+
+var (
+	_ cli.Command             = (*AuditVerifyCommand)(nil)
+	_ cli.CommandAutocomplete = (*AuditVerifyCommand)(nil)
+)
+
+type AuditVerifyCommand struct {
+	*BaseCommand
+
+	flagDevice string
+	flagPrefix string
+}
+
+func (c *AuditVerifyCommand) Synopsis() string {
+	return "Verifies the hash chain of an audit log"
+}
+
+func (c *AuditVerifyCommand) Help() string {
+	helpText := `
+Usage: vault audit verify [options] PATH
+
+  Verifies the hash chain of a local audit log written by an audit device with
+  "hash_chain" enabled. Any entries which have been removed, modified or
+  duplicated are reported.
+
+  Entries are linked using the HMAC of the previous entry, keyed using the salt
+  of the audit device, so the audit device must still be enabled. The HMAC of
+  each entry is obtained from the "sys/audit-hash" endpoint for the device.
+
+  A new hash chain is started each time an audit device is enabled. A log which
+  starts part way through a hash chain, for example after the log has been
+  rotated, is verified from its first entry.
+
+  The exit code is 0 when the log is intact and 2 when problems are found.
+
+  Verify the audit log "/var/log/vault_audit.log" written by the audit device
+  enabled at "file/":
+
+      $ vault audit verify -device=file/ /var/log/vault_audit.log
+
+  Verify an audit log written by a device configured with a prefix:
+
+      $ vault audit verify -device=file/ -prefix="vault: " /var/log/vault_audit.log
+
+` + c.Flags().Help()
+
+	return strings.TrimSpace(helpText)
+}
+
+func (c *AuditVerifyCommand) Flags() *FlagSets {
+	set := c.flagSet(FlagSetOutputFormat)
+
+	f := set.NewFlagSet("Command Options")
+
+	f.StringVar(&StringVar{
+		Name:    "device",
+		Target:  &c.flagDevice,
+		Default: "",
+		EnvVar:  "",
+		Usage: "The path of the audit device which wrote the log, which is " +
+			"used to compute the HMAC of each entry. This is required.",
+	})
+
+	f.StringVar(&StringVar{
+		Name:    "prefix",
+		Target:  &c.flagPrefix,
+		Default: "",
+		EnvVar:  "",
+		Usage: "The prefix configured on the audit device, which is removed " +
+			"from each line before the entry is parsed.",
+	})
+
+	return set
+}
+
+func (c *AuditVerifyCommand) AutocompleteArgs() complete.Predictor {
+	return complete.PredictFiles("*")
+}
+
+func (c *AuditVerifyCommand) AutocompleteFlags() complete.Flags {
+	return c.Flags().Completions()
+}
+
+func (c *AuditVerifyCommand) Run(args []string) int {
+	f := c.Flags()
+
+	if err := f.Parse(args); err != nil {
+		c.UI.Error(err.Error())
+		return 1
+	}
+
+	args = f.Args()
+	switch {
+	case len(args) < 1:
+		c.UI.Error(fmt.Sprintf("Not enough arguments (expected 1, got %d)", len(args)))
+		return 1
+	case len(args) > 1:
+		c.UI.Error(fmt.Sprintf("Too many arguments (expected 1, got %d)", len(args)))
+		return 1
+	}
+
+	device := strings.TrimSpace(c.flagDevice)
+	if device == "" {
+		c.UI.Error("The -device flag is required")
+		return 1
+	}
+
+	// Exit code 2 is reserved for a log with problems.
+	client, err := c.Client()
+	if err != nil {
+		c.UI.Error(err.Error())
+		return 1
+	}
+
+	// Get the filepath, accounting for ~ and stuff
+	path, err := homedir.Expand(strings.TrimSpace(args[0]))
+	if err != nil {
+		c.UI.Error(fmt.Sprintf("Failed to expand path: %s", err))
+		return 1
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		c.UI.Error(fmt.Sprintf("Error opening audit log: %s", err))
+		return 1
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		c.UI.Error(fmt.Sprintf("Error reading audit log: %s", err))
+		return 1
+	}
+
+	mac := func(entry []byte) (string, error) {
+		return client.Sys().AuditHash(device, string(entry))
+	}
+
+	// Requests made to compute the HMAC of each entry are audited too, possibly
+	// to the same log, so only the entries present when we started are verified.
+	report, err := audit.VerifyHashChain(io.LimitReader(file, info.Size()), c.flagPrefix, mac)
+	if err != nil {
+		c.UI.Error(fmt.Sprintf("Error verifying audit log: %s", err))
+		return 1
+	}
+
+	code := 0
+	if !report.Valid() {
+		code = 2
+	}
+
+	switch Format(c.UI) {
+	case "table":
+		if len(report.Issues) > 0 {
+			columns := []string{"Line | Sequence | Issue | Detail"}
+			for _, issue := range report.Issues {
+				columns = append(columns, fmt.Sprintf("%d | %d | %s | %s",
+					issue.Line,
+					issue.Sequence,
+					issue.Kind,
+					issue.Detail,
+				))
+			}
+			c.UI.Output(tableOutput(columns, nil))
+			c.UI.Output("")
+		}
+
+		if code != 0 {
+			c.UI.Error(fmt.Sprintf("Verification failed: %d issue(s) found in %d entries (%d hash chain(s))",
+				len(report.Issues), report.Entries, report.Chains))
+			return code
+		}
+
+		c.UI.Output(fmt.Sprintf("Success! Verified %d entries (%d hash chain(s))", report.Entries, report.Chains))
+		return code
+	default:
+		if ret := OutputData(c.UI, report); ret != 0 {
+			return ret
+		}
+		return code
+	}
+}
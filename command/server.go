@@ -900,6 +900,11 @@ func (c *ServerCommand) InitListeners(config *server.Config, disableClustering b
 		}
 		props["max_request_size"] = fmt.Sprintf("%d", lnConfig.MaxRequestSize)
 
+		if lnConfig.MaxStreamRequestSize == 0 {
+			lnConfig.MaxStreamRequestSize = vaulthttp.DefaultMaxStreamRequestSize
+		}
+		props["max_stream_request_size"] = fmt.Sprintf("%d", lnConfig.MaxStreamRequestSize)
+
 		if lnConfig.MaxRequestDuration == 0 {
 			lnConfig.MaxRequestDuration = vault.DefaultMaxRequestDuration
 		}
@@ -2489,6 +2494,15 @@ func (c *ServerCommand) Reload(lock *sync.RWMutex, reloadFuncs *map[string][]rel
 					}
 				}
 			}
+
+		case strings.HasPrefix(k, "audit_http|"):
+			for _, relFunc := range relFuncs {
+				if relFunc != nil {
+					if err := relFunc(); err != nil {
+						reloadErrors = multierror.Append(reloadErrors, fmt.Errorf("error encountered flushing http audit device at path %q: %w", strings.TrimPrefix(k, "audit_http|"), err))
+					}
+				}
+			}
 		}
 	}
 
@@ -62,7 +62,7 @@ func (c *TransformImportCommand) AutocompleteFlags() complete.Flags {
 }
 
 func (c *TransformImportCommand) Run(args []string) int {
-	return ImportKey(c.BaseCommand, "import", transformImportKeyPath, c.Flags(), args)
+	return ImportKey(c.BaseCommand, "import", transformImportKeyPath, c.Flags(), args, nil)
 }
 
 func transformImportKeyPath(s string, operation string) (path string, apiPath string, err error) {
@@ -55,5 +55,5 @@ func (c *TransformImportVersionCommand) AutocompleteFlags() complete.Flags {
 }
 
 func (c *TransformImportVersionCommand) Run(args []string) int {
-	return ImportKey(c.BaseCommand, "import_version", transformImportKeyPath, c.Flags(), args)
+	return ImportKey(c.BaseCommand, "import_version", transformImportKeyPath, c.Flags(), args, nil)
 }
@@ -0,0 +1,92 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package command
+
+import (
+	"strings"
+
+	"github.com/hashicorp/cli"
+	"github.com/posener/complete"
+)
+
+var (
+	_ cli.Command             = (*TransitDecryptFileCommand)(nil)
+	_ cli.CommandAutocomplete = (*TransitDecryptFileCommand)(nil)
+)
+
+type TransitDecryptFileCommand struct {
+	*BaseCommand
+
+	flagMount   string
+	flagContext string
+}
+
+func (c *TransitDecryptFileCommand) Synopsis() string {
+	return "Decrypt a file encrypted with the Transit secrets engine."
+}
+
+func (c *TransitDecryptFileCommand) Help() string {
+	helpText := `
+Usage: vault transit decrypt-file [options] KEY INPUT OUTPUT
+
+  Decrypts the file INPUT, encrypted by the encrypt-file command with the
+  Transit key KEY, writing the plaintext to the file OUTPUT. The file is
+  streamed to Vault and decrypted in chunks, so files of any size can be
+  decrypted.
+
+      $ vault transit decrypt-file my-key backup.tar.enc backup.tar
+
+  OUTPUT is only written once the whole file has been decrypted and
+  authenticated; if the ciphertext has been tampered with or truncated, an
+  error is returned and OUTPUT is not written.
+
+` + c.Flags().Help()
+
+	return strings.TrimSpace(helpText)
+}
+
+func (c *TransitDecryptFileCommand) Flags() *FlagSets {
+	set := c.flagSet(FlagSetHTTP)
+
+	f := set.NewFlagSet("Command Options")
+
+	f.StringVar(&StringVar{
+		Name:    "mount",
+		Target:  &c.flagMount,
+		Default: "transit",
+		Usage:   "Path where the Transit secrets engine is mounted.",
+	})
+
+	f.StringVar(&StringVar{
+		Name:    "context",
+		Target:  &c.flagContext,
+		Default: "",
+		Usage:   "Base64 encoded context for key derivation. Required if key derivation is enabled for the key.",
+	})
+
+	return set
+}
+
+func (c *TransitDecryptFileCommand) AutocompleteArgs() complete.Predictor {
+	return complete.PredictFiles("*")
+}
+
+func (c *TransitDecryptFileCommand) AutocompleteFlags() complete.Flags {
+	return c.Flags().Completions()
+}
+
+func (c *TransitDecryptFileCommand) Run(args []string) int {
+	f := c.Flags()
+
+	if err := f.Parse(args); err != nil {
+		c.UI.Error(err.Error())
+		return 1
+	}
+
+	params := map[string]string{
+		"context": c.flagContext,
+	}
+
+	return transitStreamFile(c.BaseCommand, c.flagMount, "decrypt-stream", params, f.Args())
+}
@@ -0,0 +1,222 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package command
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/cli"
+	"github.com/hashicorp/vault/api"
+	"github.com/posener/complete"
+)
+
+// transitStreamErrorTrailer is the trailer set by the Transit streaming
+// endpoints when an error is encountered after the response has started.
+const transitStreamErrorTrailer = "X-Vault-Stream-Error"
+
+var (
+	_ cli.Command             = (*TransitEncryptFileCommand)(nil)
+	_ cli.CommandAutocomplete = (*TransitEncryptFileCommand)(nil)
+)
+
+type TransitEncryptFileCommand struct {
+	*BaseCommand
+
+	flagMount      string
+	flagContext    string
+	flagKeyVersion int
+	flagChunkSize  int
+}
+
+func (c *TransitEncryptFileCommand) Synopsis() string {
+	return "Encrypt a file with the Transit secrets engine."
+}
+
+func (c *TransitEncryptFileCommand) Help() string {
+	helpText := `
+Usage: vault transit encrypt-file [options] KEY INPUT OUTPUT
+
+  Encrypts the file INPUT with the Transit key KEY, writing the ciphertext to
+  the file OUTPUT. The file is streamed to Vault and encrypted in chunks, so
+  files of any size can be encrypted. The key must be of type aes128-gcm96,
+  aes256-gcm96 or chacha20-poly1305.
+
+      $ vault transit encrypt-file my-key backup.tar backup.tar.enc
+
+  The ciphertext can be decrypted with the decrypt-file command. OUTPUT is only
+  written once the whole file has been encrypted.
+
+  Large files may take longer to encrypt than the client timeout, which can
+  be raised with VAULT_CLIENT_TIMEOUT, and be larger than the max_request_size
+  of the Vault listener.
+
+` + c.Flags().Help()
+
+	return strings.TrimSpace(helpText)
+}
+
+func (c *TransitEncryptFileCommand) Flags() *FlagSets {
+	set := c.flagSet(FlagSetHTTP)
+
+	f := set.NewFlagSet("Command Options")
+
+	f.StringVar(&StringVar{
+		Name:    "mount",
+		Target:  &c.flagMount,
+		Default: "transit",
+		Usage:   "Path where the Transit secrets engine is mounted.",
+	})
+
+	f.StringVar(&StringVar{
+		Name:    "context",
+		Target:  &c.flagContext,
+		Default: "",
+		Usage:   "Base64 encoded context for key derivation. Required if key derivation is enabled for the key.",
+	})
+
+	f.IntVar(&IntVar{
+		Name:    "key-version",
+		Target:  &c.flagKeyVersion,
+		Default: 0,
+		Usage:   "Version of the key to encrypt with. Defaults to the latest version.",
+	})
+
+	f.IntVar(&IntVar{
+		Name:    "chunk-size",
+		Target:  &c.flagChunkSize,
+		Default: 0,
+		Usage:   "Size in bytes of the plaintext of each encrypted chunk. Defaults to 64KiB.",
+	})
+
+	return set
+}
+
+func (c *TransitEncryptFileCommand) AutocompleteArgs() complete.Predictor {
+	return complete.PredictFiles("*")
+}
+
+func (c *TransitEncryptFileCommand) AutocompleteFlags() complete.Flags {
+	return c.Flags().Completions()
+}
+
+func (c *TransitEncryptFileCommand) Run(args []string) int {
+	f := c.Flags()
+
+	if err := f.Parse(args); err != nil {
+		c.UI.Error(err.Error())
+		return 1
+	}
+
+	params := map[string]string{
+		"context": c.flagContext,
+	}
+	if c.flagKeyVersion != 0 {
+		params["key_version"] = strconv.Itoa(c.flagKeyVersion)
+	}
+	if c.flagChunkSize != 0 {
+		params["chunk_size"] = strconv.Itoa(c.flagChunkSize)
+	}
+
+	return transitStreamFile(c.BaseCommand, c.flagMount, "encrypt-stream", params, f.Args())
+}
+
+// transitStreamFile streams the input file named in args through the given
+// Transit streaming endpoint, writing the response to the output file.
+func transitStreamFile(c *BaseCommand, mount, operation string, params map[string]string, args []string) int {
+	switch {
+	case len(args) < 3:
+		c.UI.Error(fmt.Sprintf("Not enough arguments (expected 3, got %d)", len(args)))
+		return 1
+	case len(args) > 3:
+		c.UI.Error(fmt.Sprintf("Too many arguments (expected 3, got %d)", len(args)))
+		return 1
+	}
+	key, inputPath, outputPath := args[0], args[1], args[2]
+
+	if derivationContext := params["context"]; derivationContext != "" {
+		if _, err := base64.StdEncoding.DecodeString(derivationContext); err != nil {
+			c.UI.Error("Context must be base64 encoded")
+			return 1
+		}
+	}
+
+	client, err := c.Client()
+	if err != nil {
+		c.UI.Error(err.Error())
+		return 2
+	}
+
+	input, err := os.Open(inputPath)
+	if err != nil {
+		c.UI.Error(fmt.Sprintf("Error opening input file: %s", err))
+		return 1
+	}
+	defer input.Close()
+
+	// Write to a temporary file alongside the output, so the output is only
+	// created once the whole stream has been received
+	output, err := os.CreateTemp(filepath.Dir(outputPath), "."+filepath.Base(outputPath)+".*")
+	if err != nil {
+		c.UI.Error(fmt.Sprintf("Error creating output file: %s", err))
+		return 1
+	}
+	defer os.Remove(output.Name())
+	defer output.Close()
+
+	// The file is passed as the body as is, so the request is streamed rather
+	// than read into memory
+	r := client.NewRequest(http.MethodPost, "/v1/"+sanitizePath(mount)+"/"+operation+"/"+key)
+	for k, v := range params {
+		if v != "" {
+			r.Params.Set(k, v)
+		}
+	}
+	r.Body = input
+
+	if err := transitStreamRequest(client, r, output); err != nil {
+		c.UI.Error(fmt.Sprintf("Error writing data to %s: %s", operation, err))
+		return 2
+	}
+
+	if err := output.Close(); err != nil {
+		c.UI.Error(fmt.Sprintf("Error writing output file: %s", err))
+		return 1
+	}
+	if err := os.Rename(output.Name(), outputPath); err != nil {
+		c.UI.Error(fmt.Sprintf("Error writing output file: %s", err))
+		return 1
+	}
+
+	return 0
+}
+
+func transitStreamRequest(client *api.Client, r *api.Request, w io.Writer) error {
+	resp, err := client.RawRequestWithContext(context.Background(), r)
+	if resp != nil {
+		defer resp.Body.Close()
+	}
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(w, resp.Body); err != nil {
+		return err
+	}
+
+	// Trailers are only available once the body has been read in full
+	if streamErr := resp.Trailer.Get(transitStreamErrorTrailer); streamErr != "" {
+		return errors.New(streamErr)
+	}
+
+	return nil
+}
@@ -0,0 +1,76 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package command
+
+import (
+	"bytes"
+	"crypto/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/vault/api"
+	"github.com/stretchr/testify/require"
+)
+
+// Validate the `vault transit encrypt-file` and `decrypt-file` commands work.
+func TestTransitEncryptDecryptFile(t *testing.T) {
+	t.Parallel()
+
+	client, closer := testVaultServer(t)
+	defer closer()
+
+	err := client.Sys().Mount("transit-files", &api.MountInput{
+		Type: "transit",
+	})
+	require.NoError(t, err)
+	_, err = client.Logical().Write("transit-files/keys/my-key", nil)
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	plaintextPath := filepath.Join(dir, "plaintext")
+	ciphertextPath := filepath.Join(dir, "ciphertext")
+	decryptedPath := filepath.Join(dir, "decrypted")
+
+	plaintext := make([]byte, 1024*1024+17)
+	_, err = rand.Read(plaintext)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(plaintextPath, plaintext, 0o600))
+
+	run := func(args ...string) (int, string) {
+		stdout := bytes.NewBuffer(nil)
+		stderr := bytes.NewBuffer(nil)
+		code := RunCustom(args, &RunOptions{
+			Stdout: stdout,
+			Stderr: stderr,
+			Client: client,
+		})
+		return code, stdout.String() + stderr.String()
+	}
+
+	code, combined := run("transit", "encrypt-file", "-mount=transit-files", "-chunk-size=4096", "my-key", plaintextPath, ciphertextPath)
+	require.Equal(t, 0, code, combined)
+
+	code, combined = run("transit", "decrypt-file", "-mount=transit-files", "my-key", ciphertextPath, decryptedPath)
+	require.Equal(t, 0, code, combined)
+
+	decrypted, err := os.ReadFile(decryptedPath)
+	require.NoError(t, err)
+	require.Equal(t, plaintext, decrypted)
+
+	// A truncated ciphertext fails to decrypt, and the output is not written
+	ciphertext, err := os.ReadFile(ciphertextPath)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(ciphertextPath, ciphertext[:len(ciphertext)-100], 0o600))
+	require.NoError(t, os.Remove(decryptedPath))
+
+	code, combined = run("transit", "decrypt-file", "-mount=transit-files", "my-key", ciphertextPath, decryptedPath)
+	require.Equal(t, 2, code, combined)
+	require.Contains(t, combined, "invalid ciphertext")
+	require.NoFileExists(t, decryptedPath)
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+}
@@ -4,21 +4,28 @@
 package command
 
 import (
+	"crypto"
 	"crypto/rand"
 	"crypto/rsa"
-	"crypto/sha256"
+	_ "crypto/sha1"
+	_ "crypto/sha512"
 	"crypto/x509"
 	"encoding/base64"
+	"encoding/binary"
 	"encoding/pem"
 	"errors"
 	"fmt"
+	"io"
+	"math/big"
 	"os"
 	"regexp"
 	"strings"
 
+	"github.com/go-jose/go-jose/v3"
 	"github.com/google/tink/go/kwp/subtle"
 	"github.com/hashicorp/cli"
 	"github.com/hashicorp/vault/api"
+	"github.com/hashicorp/vault/sdk/helper/keysutil"
 	"github.com/posener/complete"
 )
 
@@ -30,6 +37,8 @@ var (
 
 type TransitImportCommand struct {
 	*BaseCommand
+
+	importKeyOptions ImportKeyOptions
 }
 
 func (c *TransitImportCommand) Synopsis() string {
@@ -49,13 +58,18 @@ Usage: vault transit import PATH KEY [options...]
   the PKCS#11 mechanism CKM_RSA_AES_KEY_WRAP), you should use it directly
   rather than this command.
 
+  With -import-format=jwe, the key is instead sent as a JWE of its JWK,
+  encrypted to the wrapping key. KEY may then also be a JWK.
+
 ` + c.Flags().Help()
 
 	return strings.TrimSpace(helpText)
 }
 
 func (c *TransitImportCommand) Flags() *FlagSets {
-	return c.flagSet(FlagSetHTTP)
+	set := c.flagSet(FlagSetHTTP)
+	c.importKeyOptions.addFlags(set)
+	return set
 }
 
 func (c *TransitImportCommand) AutocompleteArgs() complete.Predictor {
@@ -67,7 +81,7 @@ func (c *TransitImportCommand) AutocompleteFlags() complete.Flags {
 }
 
 func (c *TransitImportCommand) Run(args []string) int {
-	return ImportKey(c.BaseCommand, "import", transitImportKeyPath, c.Flags(), args)
+	return ImportKey(c.BaseCommand, "import", transitImportKeyPath, c.Flags(), args, &c.importKeyOptions)
 }
 
 func transitImportKeyPath(s string, operation string) (path string, apiPath string, err error) {
@@ -84,8 +98,53 @@ func transitImportKeyPath(s string, operation string) (path string, apiPath stri
 
 type ImportKeyFunc func(s string, operation string) (path string, apiPath string, err error)
 
+// ImportKeyOptions are the parameters of the wrapping of imported keys. They
+// are only supported by the Transit secrets engine.
+type ImportKeyOptions struct {
+	Format           string
+	HashFunction     string
+	MGF1HashFunction string
+	OAEPLabel        string
+}
+
+func (o *ImportKeyOptions) addFlags(set *FlagSets) {
+	f := set.NewFlagSet("Command Options")
+
+	f.StringVar(&StringVar{
+		Name:    "import-format",
+		Target:  &o.Format,
+		Default: "wrapped",
+		Usage: "Format to send the key in: \"wrapped\", wrapped with RSA-OAEP and " +
+			"AES-KWP, or \"jwe\", as a JWE of its JWK.",
+	})
+
+	f.StringVar(&StringVar{
+		Name:    "hash-function",
+		Target:  &o.HashFunction,
+		Default: "SHA256",
+		Usage: "Hash function used for the RSA-OAEP encryption of the key. One of " +
+			"SHA1, SHA224, SHA256, SHA384 or SHA512; only SHA1 and SHA256 are " +
+			"supported with the jwe format.",
+	})
+
+	f.StringVar(&StringVar{
+		Name:    "mgf1-hash-function",
+		Target:  &o.MGF1HashFunction,
+		Default: "",
+		Usage: "Hash function used by MGF1 in the RSA-OAEP encryption of the key, " +
+			"if it differs from the hash function. Not supported with the jwe format.",
+	})
+
+	f.StringVar(&StringVar{
+		Name:    "oaep-label",
+		Target:  &o.OAEPLabel,
+		Default: "",
+		Usage:   "Base64 encoded label used for the RSA-OAEP encryption of the key. Not supported with the jwe format.",
+	})
+}
+
 // error codes: 1: user error, 2: internal computation error, 3: remote api call error
-func ImportKey(c *BaseCommand, operation string, pathFunc ImportKeyFunc, flags *FlagSets, args []string) int {
+func ImportKey(c *BaseCommand, operation string, pathFunc ImportKeyFunc, flags *FlagSets, args []string, opts *ImportKeyOptions) int {
 	// Parse and validate the arguments.
 	if err := flags.Parse(args); err != nil {
 		c.UI.Error(err.Error())
@@ -125,11 +184,51 @@ func ImportKey(c *BaseCommand, operation string, pathFunc ImportKeyFunc, flags *
 		keyMaterial = string(keyMaterialBytes)
 	}
 
-	key, err := base64.StdEncoding.DecodeString(keyMaterial)
+	if opts == nil {
+		opts = &ImportKeyOptions{
+			Format:       "wrapped",
+			HashFunction: "SHA256",
+		}
+	}
+	hashFn, err := keysutil.ParseOAEPHash(opts.HashFunction)
 	if err != nil {
-		c.UI.Error(fmt.Sprintf("error base64 decoding source key material: %v", err))
+		c.UI.Error(err.Error())
+		return 1
+	}
+	mgfHashFn := hashFn
+	if opts.MGF1HashFunction != "" {
+		mgfHashFn, err = keysutil.ParseOAEPHash(opts.MGF1HashFunction)
+		if err != nil {
+			c.UI.Error(err.Error())
+			return 1
+		}
+	}
+	label, err := base64.StdEncoding.DecodeString(opts.OAEPLabel)
+	if err != nil {
+		c.UI.Error(fmt.Sprintf("error base64 decoding OAEP label: %v", err))
+		return 1
+	}
+
+	var jwk *jose.JSONWebKey
+	var key []byte
+	switch {
+	case opts.Format == "jwe" && strings.HasPrefix(strings.TrimSpace(keyMaterial), "{"):
+		jwk = &jose.JSONWebKey{}
+		if err := jwk.UnmarshalJSON([]byte(keyMaterial)); err != nil {
+			c.UI.Error(fmt.Sprintf("error parsing source key JWK: %v", err))
+			return 1
+		}
+	case opts.Format == "jwe", opts.Format == "wrapped":
+		key, err = base64.StdEncoding.DecodeString(keyMaterial)
+		if err != nil {
+			c.UI.Error(fmt.Sprintf("error base64 decoding source key material: %v", err))
+			return 1
+		}
+	default:
+		c.UI.Error(fmt.Sprintf("unknown format: %s", opts.Format))
 		return 1
 	}
+
 	// Fetch the wrapping key
 	c.UI.Output("Retrieving wrapping key.")
 	wrappingKey, err := fetchWrappingKey(client, path)
@@ -137,31 +236,54 @@ func ImportKey(c *BaseCommand, operation string, pathFunc ImportKeyFunc, flags *
 		c.UI.Error(fmt.Sprintf("failed to fetch wrapping key: %v", err))
 		return 3
 	}
-	c.UI.Output("Wrapping source key with ephemeral key.")
-	wrapKWP, err := subtle.NewKWP(ephemeralAESKey)
-	if err != nil {
-		c.UI.Error(fmt.Sprintf("failure building key wrapping key: %v", err))
-		return 2
-	}
-	wrappedTargetKey, err := wrapKWP.Wrap(key)
-	if err != nil {
-		c.UI.Error(fmt.Sprintf("failure wrapping source key: %v", err))
-		return 2
-	}
-	c.UI.Output("Encrypting ephemeral key with wrapping key.")
-	wrappedAESKey, err := rsa.EncryptOAEP(
-		sha256.New(),
-		rand.Reader,
-		wrappingKey,
-		ephemeralAESKey,
-		[]byte{},
-	)
-	if err != nil {
-		c.UI.Error(fmt.Sprintf("failure encrypting wrapped key: %v", err))
-		return 2
+
+	var importCiphertext string
+	if opts.Format == "jwe" {
+		if len(label) > 0 {
+			c.UI.Error("an OAEP label is not supported with the jwe format")
+			return 1
+		}
+		if opts.MGF1HashFunction != "" {
+			c.UI.Error("an MGF1 hash function is not supported with the jwe format")
+			return 1
+		}
+		if jwk == nil {
+			jwk = sourceKeyJWK(key)
+		}
+
+		c.UI.Output("Encrypting source key with wrapping key.")
+		importCiphertext, err = encryptSourceKeyJWE(wrappingKey, jwk, opts.HashFunction)
+		if err != nil {
+			c.UI.Error(fmt.Sprintf("failure encrypting source key: %v", err))
+			return 2
+		}
+	} else {
+		c.UI.Output("Wrapping source key with ephemeral key.")
+		wrapKWP, err := subtle.NewKWP(ephemeralAESKey)
+		if err != nil {
+			c.UI.Error(fmt.Sprintf("failure building key wrapping key: %v", err))
+			return 2
+		}
+		wrappedTargetKey, err := wrapKWP.Wrap(key)
+		if err != nil {
+			c.UI.Error(fmt.Sprintf("failure wrapping source key: %v", err))
+			return 2
+		}
+		c.UI.Output("Encrypting ephemeral key with wrapping key.")
+		wrappedAESKey, err := encryptOAEP(
+			hashFn,
+			mgfHashFn,
+			wrappingKey,
+			ephemeralAESKey,
+			label,
+		)
+		if err != nil {
+			c.UI.Error(fmt.Sprintf("failure encrypting wrapped key: %v", err))
+			return 2
+		}
+		combinedCiphertext := append(wrappedAESKey, wrappedTargetKey...)
+		importCiphertext = base64.StdEncoding.EncodeToString(combinedCiphertext)
 	}
-	combinedCiphertext := append(wrappedAESKey, wrappedTargetKey...)
-	importCiphertext := base64.StdEncoding.EncodeToString(combinedCiphertext)
 
 	// Parse all the key options
 	data, err := parseArgsData(os.Stdin, args[2:])
@@ -174,6 +296,15 @@ func ImportKey(c *BaseCommand, operation string, pathFunc ImportKeyFunc, flags *
 	}
 
 	data["ciphertext"] = importCiphertext
+	if opts.Format == "jwe" {
+		data["format"] = opts.Format
+	} else if opts.HashFunction != "SHA256" || mgfHashFn != hashFn || len(label) > 0 {
+		data["hash_function"] = opts.HashFunction
+		data["oaep_label"] = opts.OAEPLabel
+		if mgfHashFn != hashFn {
+			data["mgf1_hash_function"] = opts.MGF1HashFunction
+		}
+	}
 
 	c.UI.Output("Submitting wrapped key.")
 	// Finally, call import
@@ -214,3 +345,99 @@ func fetchWrappingKey(client *api.Client, path string) (*rsa.PublicKey, error) {
 	}
 	return rsaKey, nil
 }
+
+// encryptOAEP encrypts the message with RSA-OAEP, as rsa.EncryptOAEP does,
+// but allows the hash function used by MGF1 to differ from the one used for
+// the label, as described in RFC 8017 section 7.1.1.
+func encryptOAEP(hashFn, mgfHashFn crypto.Hash, pub *rsa.PublicKey, msg, label []byte) ([]byte, error) {
+	if hashFn == mgfHashFn {
+		return rsa.EncryptOAEP(hashFn.New(), rand.Reader, pub, msg, label)
+	}
+
+	k := pub.Size()
+	hLen := hashFn.Size()
+	if len(msg) > k-2*hLen-2 {
+		return nil, rsa.ErrMessageTooLong
+	}
+
+	h := hashFn.New()
+	h.Write(label)
+	lHash := h.Sum(nil)
+
+	em := make([]byte, k)
+	seed := em[1 : 1+hLen]
+	db := em[1+hLen:]
+	copy(db, lHash)
+	db[len(db)-len(msg)-1] = 1
+	copy(db[len(db)-len(msg):], msg)
+
+	if _, err := io.ReadFull(rand.Reader, seed); err != nil {
+		return nil, err
+	}
+
+	mgf1XOR(db, mgfHashFn, seed)
+	mgf1XOR(seed, mgfHashFn, db)
+
+	m := new(big.Int).SetBytes(em)
+	c := m.Exp(m, big.NewInt(int64(pub.E)), pub.N)
+
+	return c.FillBytes(make([]byte, k)), nil
+}
+
+// mgf1XOR XORs out with the MGF1 mask generated from the seed.
+func mgf1XOR(out []byte, hashFn crypto.Hash, seed []byte) {
+	var counter [4]byte
+	var digest []byte
+	h := hashFn.New()
+
+	for done := 0; done < len(out); {
+		h.Reset()
+		h.Write(seed)
+		h.Write(counter[:])
+		digest = h.Sum(digest[:0])
+
+		for i := 0; i < len(digest) && done < len(out); i++ {
+			out[done] ^= digest[i]
+			done++
+		}
+		binary.BigEndian.PutUint32(counter[:], binary.BigEndian.Uint32(counter[:])+1)
+	}
+}
+
+// sourceKeyJWK returns the JWK of the key material, which is either a PKCS#8
+// private key or the raw bytes of a symmetric key.
+func sourceKeyJWK(key []byte) *jose.JSONWebKey {
+	if privateKey, err := x509.ParsePKCS8PrivateKey(key); err == nil {
+		return &jose.JSONWebKey{Key: privateKey}
+	}
+	return &jose.JSONWebKey{Key: key}
+}
+
+// encryptSourceKeyJWE returns the compact serialization of a JWE of the JWK,
+// encrypted to the wrapping key.
+func encryptSourceKeyJWE(wrappingKey *rsa.PublicKey, jwk *jose.JSONWebKey, hashFn string) (string, error) {
+	var alg jose.KeyAlgorithm
+	switch strings.ToUpper(hashFn) {
+	case "SHA1":
+		alg = jose.RSA_OAEP
+	case "SHA256":
+		alg = jose.RSA_OAEP_256
+	default:
+		return "", fmt.Errorf("hash function %s is not supported with the jwe format", hashFn)
+	}
+
+	encrypter, err := jose.NewEncrypter(jose.A256GCM, jose.Recipient{Algorithm: alg, Key: wrappingKey}, nil)
+	if err != nil {
+		return "", err
+	}
+	payload, err := jwk.MarshalJSON()
+	if err != nil {
+		return "", err
+	}
+	jwe, err := encrypter.Encrypt(payload)
+	if err != nil {
+		return "", err
+	}
+
+	return jwe.CompactSerialize()
+}
@@ -6,6 +6,7 @@ package command
 import (
 	"bytes"
 	"context"
+	"crypto"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/x509"
@@ -134,14 +135,139 @@ func TestTransitImport(t *testing.T) {
 	}
 }
 
+// Validate the wrapping options of `vault transit import`.
+func TestTransitImport_WrappingOptions(t *testing.T) {
+	t.Parallel()
+
+	client, closer := testVaultServer(t)
+	defer closer()
+
+	if err := client.Sys().Mount("transit", &api.MountInput{
+		Type: "transit",
+	}); err != nil {
+		t.Fatalf("transit mount error: %#v", err)
+	}
+
+	genWrappingKeyCtx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+	if _, err := client.Logical().ReadWithContext(genWrappingKeyCtx, "transit/wrapping_key"); err != nil {
+		t.Fatalf("transit failed generating wrapping key: %#v", err)
+	}
+
+	rsa1, _, _, aes256 := generateKeys(t)
+	label := base64.StdEncoding.EncodeToString([]byte("label"))
+
+	type testCase struct {
+		path       string
+		key        []byte
+		flags      []string
+		args       []string
+		shouldFail bool
+	}
+	tests := []testCase{
+		{
+			"transit/keys/oaep",
+			aes256,
+			[]string{"-hash-function=SHA512", "-oaep-label=" + label},
+			[]string{"type=aes256-gcm96"},
+			false,
+		},
+		{
+			"transit/keys/oaep-mgf1",
+			aes256,
+			[]string{"-hash-function=SHA384", "-mgf1-hash-function=SHA1", "-oaep-label=" + label},
+			[]string{"type=aes256-gcm96"},
+			false,
+		},
+		{
+			"transit/keys/jwe-aes",
+			aes256,
+			[]string{"-import-format=jwe"},
+			[]string{"type=aes256-gcm96"},
+			false,
+		},
+		{
+			"transit/keys/jwe-rsa",
+			rsa1,
+			[]string{"-import-format=jwe", "-hash-function=SHA1"},
+			[]string{"type=rsa-2048"},
+			false,
+		},
+		{
+			"transit/keys/jwe-sha512",
+			aes256,
+			[]string{"-import-format=jwe", "-hash-function=SHA512"},
+			[]string{"type=aes256-gcm96"},
+			true, /* unsupported hash function */
+		},
+		{
+			"transit/keys/jwe-mgf1",
+			aes256,
+			[]string{"-import-format=jwe", "-mgf1-hash-function=SHA1"},
+			[]string{"type=aes256-gcm96"},
+			true, /* unsupported mgf1 hash function */
+		},
+		{
+			"transit/keys/jwe-label",
+			aes256,
+			[]string{"-import-format=jwe", "-oaep-label=" + label},
+			[]string{"type=aes256-gcm96"},
+			true, /* unsupported label */
+		},
+	}
+
+	for index, tc := range tests {
+		t.Logf("Running test case %d: %v", index, tc)
+		execTransitImportWithFlags(t, client, "import", tc.flags, tc.path, tc.key, tc.args, tc.shouldFail)
+	}
+}
+
+// TestEncryptOAEP checks that keys encrypted with distinct OAEP and MGF1 hash
+// functions can be decrypted with the same options.
+func TestEncryptOAEP(t *testing.T) {
+	t.Parallel()
+
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := []byte("ephemeral key material")
+	label := []byte("label")
+
+	for _, opts := range []*rsa.OAEPOptions{
+		{Hash: crypto.SHA256, MGFHash: crypto.SHA256},
+		{Hash: crypto.SHA256, MGFHash: crypto.SHA1, Label: label},
+		{Hash: crypto.SHA512, MGFHash: crypto.SHA384},
+	} {
+		ciphertext, err := encryptOAEP(opts.Hash, opts.MGFHash, &priv.PublicKey, msg, opts.Label)
+		if err != nil {
+			t.Fatalf("failed to encrypt with %v/%v: %v", opts.Hash, opts.MGFHash, err)
+		}
+		plaintext, err := priv.Decrypt(nil, ciphertext, opts)
+		if err != nil {
+			t.Fatalf("failed to decrypt with %v/%v: %v", opts.Hash, opts.MGFHash, err)
+		}
+		if !bytes.Equal(plaintext, msg) {
+			t.Fatalf("expected %q, got %q", msg, plaintext)
+		}
+	}
+}
+
 func execTransitImport(t *testing.T, client *api.Client, method string, path string, key []byte, data []string, expectFailure bool) {
 	t.Helper()
 
+	execTransitImportWithFlags(t, client, method, nil, path, key, data, expectFailure)
+}
+
+func execTransitImportWithFlags(t *testing.T, client *api.Client, method string, flags []string, path string, key []byte, data []string, expectFailure bool) {
+	t.Helper()
+
 	keyBase64 := base64.StdEncoding.EncodeToString(key)
 
 	var args []string
 	args = append(args, "transit")
 	args = append(args, method)
+	args = append(args, flags...)
 	args = append(args, path)
 	args = append(args, keyBase64)
 	args = append(args, data...)
@@ -17,6 +17,8 @@ var (
 
 type TransitImportVersionCommand struct {
 	*BaseCommand
+
+	importKeyOptions ImportKeyOptions
 }
 
 func (c *TransitImportVersionCommand) Synopsis() string {
@@ -36,13 +38,18 @@ Usage: vault transit import-version PATH KEY [...]
   (such as the PKCS#11 mechanism CKM_RSA_AES_KEY_WRAP), you should use it
   directly rather than this command.
 
+  With -import-format=jwe, the key is instead sent as a JWE of its JWK,
+  encrypted to the wrapping key. KEY may then also be a JWK.
+
 ` + c.Flags().Help()
 
 	return strings.TrimSpace(helpText)
 }
 
 func (c *TransitImportVersionCommand) Flags() *FlagSets {
-	return c.flagSet(FlagSetHTTP)
+	set := c.flagSet(FlagSetHTTP)
+	c.importKeyOptions.addFlags(set)
+	return set
 }
 
 func (c *TransitImportVersionCommand) AutocompleteArgs() complete.Predictor {
@@ -54,5 +61,5 @@ func (c *TransitImportVersionCommand) AutocompleteFlags() complete.Flags {
 }
 
 func (c *TransitImportVersionCommand) Run(args []string) int {
-	return ImportKey(c.BaseCommand, "import_version", transitImportKeyPath, c.Flags(), args)
+	return ImportKey(c.BaseCommand, "import_version", transitImportKeyPath, c.Flags(), args, &c.importKeyOptions)
 }
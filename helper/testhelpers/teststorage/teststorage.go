@@ -15,6 +15,7 @@ import (
 	logicalKv "github.com/hashicorp/vault-plugin-secrets-kv"
 	"github.com/hashicorp/vault/audit"
 	auditFile "github.com/hashicorp/vault/builtin/audit/file"
+	auditHTTP "github.com/hashicorp/vault/builtin/audit/http"
 	auditSocket "github.com/hashicorp/vault/builtin/audit/socket"
 	auditSyslog "github.com/hashicorp/vault/builtin/audit/syslog"
 	logicalDb "github.com/hashicorp/vault/builtin/logical/database"
@@ -322,6 +323,7 @@ func ClusterSetup(conf *vault.CoreConfig, opts *vault.TestClusterOptions, setup
 	if localConf.AuditBackends == nil {
 		localConf.AuditBackends = map[string]audit.Factory{
 			"file":   auditFile.Factory,
+			"http":   auditHTTP.Factory,
 			"socket": auditSocket.Factory,
 			"syslog": auditSyslog.Factory,
 			"noop":   corehelpers.NoopAuditFactory(nil),
@@ -78,6 +78,11 @@ const (
 	// provided and the server is fed ever more data until it exhausts memory.
 	// Can be overridden per listener.
 	DefaultMaxRequestSize = 32 * 1024 * 1024
+
+	// DefaultMaxStreamRequestSize is the default maximum accepted request size
+	// for paths which stream their request body, such as transit's
+	// encrypt-stream. Can be overridden per listener.
+	DefaultMaxStreamRequestSize = 1024 * 1024 * 1024
 )
 
 var (
@@ -112,11 +112,26 @@ func buildLogicalRequestNoAuth(perfStandby bool, ra *vault.RouterAccess, w http.
 		// add the HTTP request to the logical request object for later consumption.
 		contentType := r.Header.Get("Content-Type")
 
-		if (ra != nil && ra.IsBinaryPath(r.Context(), path)) ||
-			path == "sys/storage/raft/snapshot" || path == "sys/storage/raft/snapshot-force" {
+		switch {
+		case ra != nil && ra.IsStreamingPath(r.Context(), path):
+			// Streaming paths are limited by max_stream_request_size rather
+			// than max_request_size, take their parameters from the query
+			// string, and write their response directly.
+			if body, ok := logical.ContextOriginalBodyValue(r.Context()); ok {
+				r.Body = body
+				if limit, ok := r.Context().Value(ctxKeyMaxStreamRequestSize{}).(int64); ok && limit > 0 {
+					r.Body = http.MaxBytesReader(w, body, limit)
+				}
+			}
 			passHTTPReq = true
 			origBody = r.Body
-		} else {
+			data = parseQuery(r.URL.Query())
+			responseWriter = w
+		case (ra != nil && ra.IsBinaryPath(r.Context(), path)) ||
+			path == "sys/storage/raft/snapshot" || path == "sys/storage/raft/snapshot-force":
+			passHTTPReq = true
+			origBody = r.Body
+		default:
 			// Sample the first bytes to determine whether this should be parsed as
 			// a form or as JSON. The amount to look ahead (512 bytes) is arbitrary
 			// but extremely tolerant (i.e. allowing 511 bytes of leading whitespace
@@ -22,21 +22,30 @@ import (
 
 var nonVotersAllowed = false
 
+// ctxKeyMaxStreamRequestSize is the context key for the maximum request size
+// of paths which stream their request body.
+type ctxKeyMaxStreamRequestSize struct{}
+
 func wrapMaxRequestSizeHandler(handler http.Handler, props *vault.HandlerProperties) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		var maxRequestSize int64
+		var maxRequestSize, maxStreamRequestSize int64
 		if props.ListenerConfig != nil {
 			maxRequestSize = props.ListenerConfig.MaxRequestSize
+			maxStreamRequestSize = props.ListenerConfig.MaxStreamRequestSize
 		}
 		if maxRequestSize == 0 {
 			maxRequestSize = DefaultMaxRequestSize
 		}
+		if maxStreamRequestSize == 0 {
+			maxStreamRequestSize = DefaultMaxStreamRequestSize
+		}
 		ctx := r.Context()
 		originalBody := r.Body
 		if maxRequestSize > 0 {
 			r.Body = http.MaxBytesReader(w, r.Body, maxRequestSize)
 		}
 		ctx = logical.CreateContextOriginalBody(ctx, originalBody)
+		ctx = context.WithValue(ctx, ctxKeyMaxStreamRequestSize{}, maxStreamRequestSize)
 		r = r.WithContext(ctx)
 
 		handler.ServeHTTP(w, r)
@@ -82,7 +91,7 @@ func rateLimitQuotaWrapping(handler http.Handler, core *vault.Core) http.Handler
 			ClientAddress: parseRemoteIPAddress(r),
 		}
 
-		// This checks if any role based quota is required (LCQ or RLQ).
+		// This checks if any role based quota is required (LCQ, RLQ or concurrency).
 		requiresResolveRole, err := core.ResolveRoleForQuotas(r.Context(), quotaReq)
 		if err != nil {
 			core.Logger().Error("failed to lookup quotas", "path", path, "error", err)
@@ -110,6 +119,17 @@ func rateLimitQuotaWrapping(handler http.Handler, core *vault.Core) http.Handler
 			quotaReq.Role = role
 		}
 
+		// If the rate limit quota is keyed on the identity of the client, look
+		// up the client token.
+		token, _ := getTokenFromReq(r)
+		ctx, err := core.ResolveIdentityForQuotas(r.Context(), quotaReq, token)
+		if err != nil {
+			core.Logger().Error("failed to lookup quotas", "path", path, "error", err)
+			respondError(w, http.StatusInternalServerError, err)
+			return
+		}
+		r = r.WithContext(ctx)
+
 		quotaResp, err := core.ApplyRateLimitQuota(r.Context(), quotaReq)
 		if err != nil {
 			core.Logger().Error("failed to apply quota", "path", path, "error", err)
@@ -132,29 +152,65 @@ func rateLimitQuotaWrapping(handler http.Handler, core *vault.Core) http.Handler
 			}
 
 			if core.RateLimitAuditLoggingEnabled() {
-				req, _, status, err := buildLogicalRequestNoAuth(core.PerfStandby(), core.RouterAccess(), w, r)
-				if err != nil || status != 0 {
-					respondError(w, status, err)
-					return
-				}
+				auditQuotaRejection(core, w, r, quotaErr, "rate limit")
+			}
 
-				err = core.AuditLogger().AuditRequest(r.Context(), &logical.LogInput{
-					Request:  req,
-					OuterErr: quotaErr,
-				})
-				if err != nil {
-					core.Logger().Warn("failed to audit log request rejection caused by rate limit quota violation", "error", err)
-				}
+			return
+		}
+
+		concurrencyResp, err := core.ApplyConcurrencyQuota(r.Context(), quotaReq)
+		if err != nil {
+			core.Logger().Error("failed to apply quota", "path", path, "error", err)
+			respondError(w, http.StatusInternalServerError, err)
+			return
+		}
+
+		if !concurrencyResp.Allowed {
+			quotaErr := fmt.Errorf("request path %q: %w", path, quotas.ErrConcurrencyQuotaExceeded)
+			respondError(w, http.StatusTooManyRequests, quotaErr)
+
+			if core.Logger().IsTrace() {
+				core.Logger().Trace("request rejected due to concurrency quota violation", "request_path", path)
+			}
+
+			if core.RateLimitAuditLoggingEnabled() {
+				auditQuotaRejection(core, w, r, quotaErr, "concurrency")
 			}
 
 			return
 		}
 
+		if concurrencyResp.Access != nil {
+			defer func() {
+				if err := core.ReleaseConcurrencyQuota(concurrencyResp.Access); err != nil {
+					core.Logger().Error("failed to release concurrency quota", "path", path, "error", err)
+				}
+			}()
+		}
+
 		handler.ServeHTTP(w, r)
 		return
 	})
 }
 
+// auditQuotaRejection audit logs a request which was rejected due to a quota
+// rule violation of the specified kind.
+func auditQuotaRejection(core *vault.Core, w http.ResponseWriter, r *http.Request, quotaErr error, kind string) {
+	req, _, status, err := buildLogicalRequestNoAuth(core.PerfStandby(), core.RouterAccess(), w, r)
+	if err != nil || status != 0 {
+		respondError(w, status, err)
+		return
+	}
+
+	err = core.AuditLogger().AuditRequest(r.Context(), &logical.LogInput{
+		Request:  req,
+		OuterErr: quotaErr,
+	})
+	if err != nil {
+		core.Logger().Warn(fmt.Sprintf("failed to audit log request rejection caused by %s quota violation", kind), "error", err)
+	}
+}
+
 func disableReplicationStatusEndpointWrapping(h http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		request := r.WithContext(logical.CreateContextDisableReplicationStatusEndpoints(r.Context(), true))
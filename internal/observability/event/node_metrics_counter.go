@@ -13,7 +13,10 @@ import (
 	"github.com/hashicorp/eventlogger"
 )
 
-var _ eventlogger.Node = (*MetricsCounter)(nil)
+var (
+	_ eventlogger.Node          = (*MetricsCounter)(nil)
+	_ eventlogger.NodeUnwrapper = (*MetricsCounter)(nil)
+)
 
 // MetricsCounter offers a way for nodes to emit metrics which increment a label by 1.
 type MetricsCounter struct {
@@ -71,6 +74,12 @@ func (m MetricsCounter) Reopen() error {
 	return m.Node.Reopen()
 }
 
+// Unwrap returns the underlying eventlogger.Node, this allows the broker to close
+// the node when it is removed.
+func (m MetricsCounter) Unwrap() eventlogger.Node {
+	return m.Node
+}
+
 // Type returns the type for the underlying eventlogger.Node.
 func (m MetricsCounter) Type() eventlogger.NodeType {
 	return m.Node.Type()
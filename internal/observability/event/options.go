@@ -4,12 +4,14 @@
 package event
 
 import (
+	"crypto/tls"
 	"fmt"
 	"os"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/hashicorp/go-hclog"
 	"github.com/hashicorp/go-secure-stdlib/parseutil"
 	"github.com/hashicorp/go-uuid"
 )
@@ -19,13 +21,27 @@ type Option func(*options) error
 
 // Options are used to represent configuration for an Event.
 type options struct {
-	withID          string
-	withNow         time.Time
-	withFacility    string
-	withTag         string
-	withSocketType  string
-	withMaxDuration time.Duration
-	withFileMode    *os.FileMode
+	withID            string
+	withNow           time.Time
+	withFacility      string
+	withTag           string
+	withSocketType    string
+	withMaxDuration   time.Duration
+	withFileMode      *os.FileMode
+	withHeaders       map[string]string
+	withTLSConfig     *tls.Config
+	withBatchSize     int
+	withBatchInterval time.Duration
+	withMaxRetries    int
+	withRetryWait     time.Duration
+	withSpoolDir      string
+	withSpoolSize     int64
+	withMaxBuffered   int
+	withMaxBytes      int64
+	withMaxAge        time.Duration
+	withMaxFiles      int
+	withGzip          bool
+	withLogger        hclog.Logger
 }
 
 // getDefaultOptions returns Options with their default values.
@@ -33,12 +49,18 @@ func getDefaultOptions() options {
 	fileMode := os.FileMode(0o600)
 
 	return options{
-		withNow:         time.Now(),
-		withFacility:    "AUTH",
-		withTag:         "vault",
-		withSocketType:  "tcp",
-		withMaxDuration: 2 * time.Second,
-		withFileMode:    &fileMode,
+		withNow:           time.Now(),
+		withFacility:      "AUTH",
+		withTag:           "vault",
+		withSocketType:    "tcp",
+		withMaxDuration:   2 * time.Second,
+		withFileMode:      &fileMode,
+		withBatchSize:     100,
+		withBatchInterval: time.Second,
+		withMaxRetries:    3,
+		withRetryWait:     250 * time.Millisecond,
+		withSpoolSize:     100 * 1024 * 1024,
+		withMaxBuffered:   10000,
 	}
 }
 
@@ -201,3 +223,280 @@ func WithFileMode(mode string) Option {
 		return nil
 	}
 }
+
+// WithHeaders provides an Option to represent the headers which should be sent
+// with each request made by an HTTP sink.
+func WithHeaders(headers map[string]string) Option {
+	return func(o *options) error {
+		if len(headers) == 0 {
+			return nil
+		}
+
+		h := make(map[string]string, len(headers))
+		for k, v := range headers {
+			k = strings.TrimSpace(k)
+			if k == "" {
+				return fmt.Errorf("header name cannot be empty: %w", ErrInvalidParameter)
+			}
+			h[k] = v
+		}
+
+		o.withHeaders = h
+
+		return nil
+	}
+}
+
+// WithTLSConfig provides an Option to represent the TLS configuration used by
+// an HTTP sink when connecting to its endpoint.
+func WithTLSConfig(cfg *tls.Config) Option {
+	return func(o *options) error {
+		o.withTLSConfig = cfg
+
+		return nil
+	}
+}
+
+// WithBatchSize provides an Option to represent the maximum number of events an
+// HTTP sink will buffer before sending them as a single request.
+func WithBatchSize(size string) Option {
+	return func(o *options) error {
+		size = strings.TrimSpace(size)
+		if size == "" {
+			return nil
+		}
+
+		parsed, err := strconv.Atoi(size)
+		switch {
+		case err != nil:
+			return fmt.Errorf("unable to parse batch size: %w: %w", ErrInvalidParameter, err)
+		case parsed < 1:
+			return fmt.Errorf("batch size must be at least 1: %w", ErrInvalidParameter)
+		}
+
+		o.withBatchSize = parsed
+
+		return nil
+	}
+}
+
+// WithMaxBufferedEvents provides an Option to represent the maximum number of
+// events an HTTP sink will hold in memory while waiting to send them.
+func WithMaxBufferedEvents(max string) Option {
+	return func(o *options) error {
+		max = strings.TrimSpace(max)
+		if max == "" {
+			return nil
+		}
+
+		parsed, err := strconv.Atoi(max)
+		switch {
+		case err != nil:
+			return fmt.Errorf("unable to parse max buffered events: %w: %w", ErrInvalidParameter, err)
+		case parsed < 1:
+			return fmt.Errorf("max buffered events must be at least 1: %w", ErrInvalidParameter)
+		}
+
+		o.withMaxBuffered = parsed
+
+		return nil
+	}
+}
+
+// WithBatchInterval provides an Option to represent the maximum amount of time an
+// HTTP sink will hold a partial batch of events before sending it.
+func WithBatchInterval(interval string) Option {
+	return func(o *options) error {
+		interval = strings.TrimSpace(interval)
+		if interval == "" {
+			return nil
+		}
+
+		parsed, err := parseutil.ParseDurationSecond(interval)
+		switch {
+		case err != nil:
+			return fmt.Errorf("unable to parse batch interval: %w: %w", ErrInvalidParameter, err)
+		case parsed <= 0:
+			return fmt.Errorf("batch interval must be greater than zero: %w", ErrInvalidParameter)
+		}
+
+		o.withBatchInterval = parsed
+
+		return nil
+	}
+}
+
+// WithMaxRetries provides an Option to represent the number of times an HTTP sink
+// will retry sending a batch of events before giving up on it.
+func WithMaxRetries(retries string) Option {
+	return func(o *options) error {
+		retries = strings.TrimSpace(retries)
+		if retries == "" {
+			return nil
+		}
+
+		parsed, err := strconv.Atoi(retries)
+		switch {
+		case err != nil:
+			return fmt.Errorf("unable to parse max retries: %w: %w", ErrInvalidParameter, err)
+		case parsed < 0:
+			return fmt.Errorf("max retries cannot be negative: %w", ErrInvalidParameter)
+		}
+
+		o.withMaxRetries = parsed
+
+		return nil
+	}
+}
+
+// WithRetryWait provides an Option to represent the initial amount of time an
+// HTTP sink waits between retries, the wait is doubled after each attempt.
+func WithRetryWait(wait string) Option {
+	return func(o *options) error {
+		wait = strings.TrimSpace(wait)
+		if wait == "" {
+			return nil
+		}
+
+		parsed, err := parseutil.ParseDurationSecond(wait)
+		switch {
+		case err != nil:
+			return fmt.Errorf("unable to parse retry wait: %w: %w", ErrInvalidParameter, err)
+		case parsed < 0:
+			return fmt.Errorf("retry wait cannot be negative: %w", ErrInvalidParameter)
+		}
+
+		o.withRetryWait = parsed
+
+		return nil
+	}
+}
+
+// WithSpoolDir provides an Option to represent the directory an HTTP sink uses
+// to store batches of events which could not be delivered.
+func WithSpoolDir(dir string) Option {
+	return func(o *options) error {
+		o.withSpoolDir = strings.TrimSpace(dir)
+
+		return nil
+	}
+}
+
+// WithSpoolSize provides an Option to represent the maximum number of bytes an
+// HTTP sink may store in its spool directory.
+func WithSpoolSize(size string) Option {
+	return func(o *options) error {
+		size = strings.TrimSpace(size)
+		if size == "" {
+			return nil
+		}
+
+		parsed, err := parseutil.ParseCapacityString(size)
+		switch {
+		case err != nil:
+			return fmt.Errorf("unable to parse spool size: %w: %w", ErrInvalidParameter, err)
+		case parsed == 0:
+			return fmt.Errorf("spool size must be greater than zero: %w", ErrInvalidParameter)
+		}
+
+		o.withSpoolSize = int64(parsed)
+
+		return nil
+	}
+}
+
+// WithMaxBytes provides an Option to represent the size a file sink's file may
+// reach before it is rotated. A zero value disables size based rotation.
+func WithMaxBytes(size string) Option {
+	return func(o *options) error {
+		size = strings.TrimSpace(size)
+		if size == "" {
+			return nil
+		}
+
+		parsed, err := parseutil.ParseCapacityString(size)
+		if err != nil {
+			return fmt.Errorf("unable to parse max bytes: %w: %w", ErrInvalidParameter, err)
+		}
+
+		o.withMaxBytes = int64(parsed)
+
+		return nil
+	}
+}
+
+// WithMaxAge provides an Option to represent how long a file sink writes to a
+// file before it is rotated. A zero value disables time based rotation.
+func WithMaxAge(age string) Option {
+	return func(o *options) error {
+		age = strings.TrimSpace(age)
+		if age == "" {
+			return nil
+		}
+
+		parsed, err := parseutil.ParseDurationSecond(age)
+		switch {
+		case err != nil:
+			return fmt.Errorf("unable to parse max age: %w: %w", ErrInvalidParameter, err)
+		case parsed < 0:
+			return fmt.Errorf("max age cannot be negative: %w", ErrInvalidParameter)
+		}
+
+		o.withMaxAge = parsed
+
+		return nil
+	}
+}
+
+// WithMaxFiles provides an Option to represent the number of rotated files a
+// file sink keeps. A zero value keeps all rotated files.
+func WithMaxFiles(files string) Option {
+	return func(o *options) error {
+		files = strings.TrimSpace(files)
+		if files == "" {
+			return nil
+		}
+
+		parsed, err := strconv.Atoi(files)
+		switch {
+		case err != nil:
+			return fmt.Errorf("unable to parse max files: %w: %w", ErrInvalidParameter, err)
+		case parsed < 0:
+			return fmt.Errorf("max files cannot be negative: %w", ErrInvalidParameter)
+		}
+
+		o.withMaxFiles = parsed
+
+		return nil
+	}
+}
+
+// WithGzip provides an Option to represent whether a file sink compresses its
+// rotated files using gzip.
+func WithGzip(enable string) Option {
+	return func(o *options) error {
+		enable = strings.TrimSpace(enable)
+		if enable == "" {
+			return nil
+		}
+
+		parsed, err := parseutil.ParseBool(enable)
+		if err != nil {
+			return fmt.Errorf("unable to parse gzip: %w: %w", ErrInvalidParameter, err)
+		}
+
+		o.withGzip = parsed
+
+		return nil
+	}
+}
+
+// WithLogger provides an Option to represent the logger a sink uses to report
+// failures which can't be returned to the caller.
+func WithLogger(l hclog.Logger) Option {
+	return func(o *options) error {
+		o.withLogger = l
+
+		return nil
+	}
+}
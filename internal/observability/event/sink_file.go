@@ -5,36 +5,70 @@ package event
 
 import (
 	"bytes"
+	"compress/gzip"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
 
+	"github.com/armon/go-metrics"
 	"github.com/hashicorp/eventlogger"
+	"github.com/hashicorp/go-multierror"
 )
 
 // defaultFileMode is the default file permissions (read/write for everyone).
 const (
 	defaultFileMode = 0o600
 	devnull         = "/dev/null"
+	gzipExt         = ".gz"
 )
 
-var _ eventlogger.Node = (*FileSink)(nil)
+var (
+	_ eventlogger.Node   = (*FileSink)(nil)
+	_ eventlogger.Closer = (*FileSink)(nil)
+)
 
 // FileSink is a sink node which handles writing events to file.
+// The file can optionally be rotated when it reaches a maximum size or age, in
+// which case it is renamed to include the time of rotation (e.g. 'audit.log'
+// becomes 'audit-1713888000000000000.log'), optionally compressed, and a new
+// file is opened. Rotation only ever happens between events, so an event is
+// never split across files.
 type FileSink struct {
 	file           *os.File
 	fileLock       sync.RWMutex
 	fileMode       os.FileMode
 	path           string
 	requiredFormat string
+
+	// Rotation settings, zero values disable the relevant behavior.
+	maxBytes int64
+	maxAge   time.Duration
+	maxFiles int
+	gzip     bool
+
+	// bytesWritten is the size of the current file.
+	bytesWritten int64
+
+	// startedAt is the time the current file was started, which is used for
+	// age based rotation.
+	startedAt time.Time
+
+	// archiveLock serializes compression and pruning of rotated files, which
+	// happen in the background so that they don't block writing events.
+	archiveLock sync.Mutex
+	archiveWG   sync.WaitGroup
 }
 
 // NewFileSink should be used to create a new FileSink.
-// Accepted options: WithFileMode.
+// Accepted options: WithFileMode, WithGzip, WithMaxAge, WithMaxBytes and WithMaxFiles.
 func NewFileSink(path string, format string, opt ...Option) (*FileSink, error) {
 	// Parse and check path
 	p := strings.TrimSpace(path)
@@ -69,6 +103,10 @@ func NewFileSink(path string, format string, opt ...Option) (*FileSink, error) {
 		fileMode:       mode,
 		requiredFormat: format,
 		path:           p,
+		maxBytes:       opts.withMaxBytes,
+		maxAge:         opts.withMaxAge,
+		maxFiles:       opts.withMaxFiles,
+		gzip:           opts.withGzip,
 	}
 
 	// Ensure that the file can be successfully opened for writing;
@@ -141,6 +179,26 @@ func (s *FileSink) Type() eventlogger.NodeType {
 	return eventlogger.NodeTypeSink
 }
 
+// Close closes the file and waits for any rotated files to finish being archived.
+func (s *FileSink) Close(_ context.Context) error {
+	s.fileLock.Lock()
+	defer s.fileLock.Unlock()
+
+	defer s.archiveWG.Wait()
+
+	if s.file == nil {
+		return nil
+	}
+
+	err := s.file.Close()
+	s.file = nil
+	if err != nil {
+		return fmt.Errorf("unable to close file for sink %q: %w", s.path, err)
+	}
+
+	return nil
+}
+
 // open attempts to open a file at the sink's path, with the sink's fileMode permissions
 // if one is not already open.
 // It doesn't have any locking and relies on calling functions of FileSink to
@@ -160,6 +218,15 @@ func (s *FileSink) open() error {
 		return fmt.Errorf("unable to open file for sink %q: %w", s.path, err)
 	}
 
+	// Track the size of the file we're appending to, so that size based
+	// rotation also accounts for anything written before we opened it.
+	info, err := s.file.Stat()
+	if err != nil {
+		return fmt.Errorf("unable to stat file for sink %q: %w", s.path, err)
+	}
+	s.bytesWritten = info.Size()
+	s.startedAt = s.fileStartTime(info)
+
 	// Change the file mode in case the log file already existed.
 	// We special case '/dev/null' since we can't chmod it, and bypass if the mode is zero.
 	switch s.path {
@@ -188,7 +255,14 @@ func (s *FileSink) log(data []byte) error {
 		return fmt.Errorf("unable to open file for sink %q: %w", s.path, err)
 	}
 
-	if _, err := reader.WriteTo(s.file); err == nil {
+	if s.shouldRotate(int64(len(data))) {
+		if err := s.rotate(); err != nil {
+			return fmt.Errorf("unable to rotate file for sink %q: %w", s.path, err)
+		}
+	}
+
+	if n, err := reader.WriteTo(s.file); err == nil {
+		s.bytesWritten += n
 		return nil
 	}
 
@@ -209,10 +283,227 @@ func (s *FileSink) log(data []byte) error {
 		return fmt.Errorf("unable to seek to start of file for sink %q: %w", s.path, err)
 	}
 
-	_, err = reader.WriteTo(s.file)
+	n, err := reader.WriteTo(s.file)
 	if err != nil {
 		return fmt.Errorf("unable to re-write to file for sink %q: %w", s.path, err)
 	}
+	s.bytesWritten += n
+
+	return nil
+}
+
+// shouldRotate determines if the current file should be rotated before writing
+// the specified number of bytes to it.
+// It relies on the caller holding the fileLock.
+func (s *FileSink) shouldRotate(size int64) bool {
+	switch {
+	case s.path == devnull:
+		return false
+	case s.maxAge > 0 && time.Since(s.startedAt) >= s.maxAge && s.bytesWritten > 0:
+		return true
+	case s.maxBytes > 0 && s.bytesWritten > 0 && s.bytesWritten+size > s.maxBytes:
+		return true
+	default:
+		return false
+	}
+}
+
+// rotate closes the current file, renames it to include the time of rotation
+// and opens a new file. Compression and pruning of rotated files happens in
+// the background.
+// It relies on the caller holding the fileLock.
+func (s *FileSink) rotate() error {
+	if err := s.file.Close(); err != nil {
+		return fmt.Errorf("unable to close file: %w", err)
+	}
+	s.file = nil
+
+	rotatedPath := s.rotatedPath(strconv.FormatInt(time.Now().UnixNano(), 10))
+	if err := os.Rename(s.path, rotatedPath); err != nil {
+		metrics.IncrCounter([]string{"audit", "file", "rotate_failure"}, 1)
+		// Carry on with the existing file rather than losing the event.
+		return errors.Join(fmt.Errorf("unable to rename file: %w", err), s.open())
+	}
+
+	metrics.IncrCounter([]string{"audit", "file", "rotate"}, 1)
+
+	s.archiveWG.Add(1)
+	go func() {
+		defer s.archiveWG.Done()
+
+		s.archiveLock.Lock()
+		defer s.archiveLock.Unlock()
+
+		if err := s.archive(rotatedPath); err != nil {
+			metrics.IncrCounter([]string{"audit", "file", "archive_failure"}, 1)
+		}
+	}()
+
+	return s.open()
+}
+
+// archive compresses the rotated file (when required) and removes the oldest
+// rotated files if there are more than the maximum number allowed.
+// It relies on the caller holding the archiveLock.
+func (s *FileSink) archive(rotatedPath string) error {
+	var err error
+
+	if s.gzip {
+		if compressErr := compressFile(rotatedPath, s.fileMode); compressErr != nil {
+			err = multierror.Append(err, compressErr)
+		}
+	}
+
+	if s.maxFiles == 0 {
+		return err
+	}
+
+	rotated, listErr := s.rotatedFiles()
+	if listErr != nil {
+		return multierror.Append(err, listErr)
+	}
+
+	if len(rotated) <= s.maxFiles {
+		return err
+	}
+
+	for _, r := range rotated[:len(rotated)-s.maxFiles] {
+		if removeErr := os.Remove(r.path); removeErr != nil {
+			err = multierror.Append(err, fmt.Errorf("unable to remove rotated file %q: %w", r.path, removeErr))
+		}
+	}
+
+	return err
+}
+
+// fileStartTime determines when the file the sink is appending to was started,
+// so that reopening the file (or restarting Vault) doesn't reset the clock for
+// age based rotation. Files are started when the previous file is rotated, so
+// the time of the most recent rotation is used, falling back to the modification
+// time of a file which has never been rotated.
+// It relies on the caller holding the fileLock.
+func (s *FileSink) fileStartTime(info os.FileInfo) time.Time {
+	now := time.Now()
+	if s.maxAge == 0 || info.Size() == 0 {
+		return now
+	}
+
+	if rotated, err := s.rotatedFiles(); err == nil && len(rotated) > 0 {
+		if last := rotated[len(rotated)-1].rotatedAt; !last.After(now) {
+			return last
+		}
+	}
+
+	if modTime := info.ModTime(); !modTime.After(now) {
+		return modTime
+	}
+
+	return now
+}
+
+// rotatedFile is a file previously rotated by the sink.
+type rotatedFile struct {
+	path      string
+	rotatedAt time.Time
+}
+
+// rotatedFiles returns the files which were rotated from the sink's file, ordered
+// from oldest to newest. Only files which match the exact name used when rotating
+// (with or without compression) are returned, so that other files in the same
+// directory which happen to share a prefix are never mistaken for rotated files.
+func (s *FileSink) rotatedFiles() ([]rotatedFile, error) {
+	dir := filepath.Dir(s.path)
+	ext := filepath.Ext(s.path)
+	prefix := strings.TrimSuffix(filepath.Base(s.path), ext) + "-"
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read directory %q: %w", dir, err)
+	}
+
+	var rotated []rotatedFile
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, prefix) {
+			continue
+		}
+
+		value := strings.TrimPrefix(name, prefix)
+		switch {
+		case strings.HasSuffix(value, ext+gzipExt):
+			value = strings.TrimSuffix(value, ext+gzipExt)
+		case strings.HasSuffix(value, ext):
+			value = strings.TrimSuffix(value, ext)
+		default:
+			continue
+		}
+
+		if value == "" || strings.TrimLeft(value, "0123456789") != "" {
+			continue
+		}
+
+		nanos, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			continue
+		}
+
+		rotated = append(rotated, rotatedFile{
+			path:      filepath.Join(dir, name),
+			rotatedAt: time.Unix(0, nanos),
+		})
+	}
+
+	sort.Slice(rotated, func(i, j int) bool {
+		return rotated[i].rotatedAt.Before(rotated[j].rotatedAt)
+	})
+
+	return rotated, nil
+}
+
+// rotatedPath returns the path a rotated file should use, based on the supplied
+// value (usually the time of rotation) and the path of the sink's file.
+func (s *FileSink) rotatedPath(value string) string {
+	ext := filepath.Ext(s.path)
+	return strings.TrimSuffix(s.path, ext) + "-" + value + ext
+}
+
+// compressFile writes a gzip compressed copy of the file at path, then removes
+// the original once the compressed copy is complete.
+func compressFile(path string, mode os.FileMode) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("unable to open rotated file %q: %w", path, err)
+	}
+	defer src.Close()
+
+	if mode == 0 {
+		mode = defaultFileMode
+	}
+
+	// Write to a temporary file so that a partial archive is never mistaken
+	// for a complete one.
+	tmp := path + gzipExt + ".tmp"
+	dst, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, mode)
+	if err != nil {
+		return fmt.Errorf("unable to create compressed file %q: %w", tmp, err)
+	}
+
+	zw := gzip.NewWriter(dst)
+	_, err = io.Copy(zw, src)
+	err = errors.Join(err, zw.Close(), dst.Close())
+	if err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("unable to compress rotated file %q: %w", path, err)
+	}
+
+	if err := os.Rename(tmp, path+gzipExt); err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("unable to rename compressed file %q: %w", tmp, err)
+	}
+
+	if err := os.Remove(path); err != nil {
+		return fmt.Errorf("unable to remove rotated file %q: %w", path, err)
+	}
 
 	return nil
 }
@@ -4,6 +4,9 @@
 package event
 
 import (
+	"compress/gzip"
+	"context"
+	"io"
 	"os"
 	"path/filepath"
 	"testing"
@@ -297,3 +300,205 @@ func TestFileSink_Process(t *testing.T) {
 		})
 	}
 }
+
+// newTestFileEvent creates an event which is already formatted as JSON.
+func newTestFileEvent(data string) *eventlogger.Event {
+	e := &eventlogger.Event{
+		Type:      "audit",
+		CreatedAt: time.Now(),
+		Formatted: make(map[string][]byte),
+		Payload:   struct{ ID string }{ID: "123"},
+	}
+	e.FormattedAs("json", []byte(data))
+
+	return e
+}
+
+// TestFileSink_Rotate_MaxBytes ensures that the file is rotated before it would
+// exceed the maximum size, that events are never split across files and that
+// only the configured number of rotated files are kept.
+func TestFileSink_Rotate_MaxBytes(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "audit.log")
+
+	sink, err := NewFileSink(path, "json", WithMaxBytes("10"), WithMaxFiles("2"))
+	require.NoError(t, err)
+
+	ctx := namespace.RootContext(nil)
+	for _, data := range []string{"aaaaaa\n", "bbbbbb\n", "cccccc\n", "dddddd\n"} {
+		_, err = sink.Process(ctx, newTestFileEvent(data))
+		require.NoError(t, err)
+	}
+	require.NoError(t, sink.Close(context.Background()))
+
+	current, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, "dddddd\n", string(current))
+
+	rotated, err := filepath.Glob(filepath.Join(dir, "audit-*.log"))
+	require.NoError(t, err)
+	require.Len(t, rotated, 2)
+
+	var contents []string
+	for _, r := range rotated {
+		b, err := os.ReadFile(r)
+		require.NoError(t, err)
+		contents = append(contents, string(b))
+	}
+	require.ElementsMatch(t, []string{"bbbbbb\n", "cccccc\n"}, contents)
+}
+
+// TestFileSink_Rotate_MaxAge ensures that the file is rotated once it has been
+// written to for longer than the maximum age, and that the rotated file is
+// compressed when required.
+func TestFileSink_Rotate_MaxAge(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "audit.log")
+
+	sink, err := NewFileSink(path, "json", WithMaxAge("1h"), WithGzip("true"))
+	require.NoError(t, err)
+
+	ctx := namespace.RootContext(nil)
+	_, err = sink.Process(ctx, newTestFileEvent("old\n"))
+	require.NoError(t, err)
+
+	// Pretend the file was started long enough ago that it needs rotating.
+	sink.fileLock.Lock()
+	sink.startedAt = time.Now().Add(-2 * time.Hour)
+	sink.fileLock.Unlock()
+
+	_, err = sink.Process(ctx, newTestFileEvent("new\n"))
+	require.NoError(t, err)
+	require.NoError(t, sink.Close(context.Background()))
+
+	current, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, "new\n", string(current))
+
+	rotated, err := filepath.Glob(filepath.Join(dir, "audit-*.log*"))
+	require.NoError(t, err)
+	require.Len(t, rotated, 1)
+	require.Equal(t, gzipExt, filepath.Ext(rotated[0]))
+
+	f, err := os.Open(rotated[0])
+	require.NoError(t, err)
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	require.NoError(t, err)
+	b, err := io.ReadAll(zr)
+	require.NoError(t, err)
+	require.Equal(t, "old\n", string(b))
+}
+
+// TestFileSink_Rotate_ExistingFile ensures that the size of a pre-existing file
+// is taken into account when deciding whether to rotate.
+func TestFileSink_Rotate_ExistingFile(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "audit.log")
+	require.NoError(t, os.WriteFile(path, []byte("existing\n"), defaultFileMode))
+
+	sink, err := NewFileSink(path, "json", WithMaxBytes("10"))
+	require.NoError(t, err)
+
+	_, err = sink.Process(namespace.RootContext(nil), newTestFileEvent("next\n"))
+	require.NoError(t, err)
+	require.NoError(t, sink.Close(context.Background()))
+
+	rotated, err := filepath.Glob(filepath.Join(dir, "audit-*.log"))
+	require.NoError(t, err)
+	require.Len(t, rotated, 1)
+
+	b, err := os.ReadFile(rotated[0])
+	require.NoError(t, err)
+	require.Equal(t, "existing\n", string(b))
+}
+
+// TestFileSink_Rotate_MaxAge_Reopen ensures that reopening the file doesn't
+// reset the age used for rotation.
+func TestFileSink_Rotate_MaxAge_Reopen(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "audit.log")
+
+	sink, err := NewFileSink(path, "json", WithMaxAge("1h"))
+	require.NoError(t, err)
+
+	ctx := namespace.RootContext(nil)
+	_, err = sink.Process(ctx, newTestFileEvent("old\n"))
+	require.NoError(t, err)
+
+	// Pretend the file was last written long enough ago that it needs
+	// rotating, then reopen it as would happen on SIGHUP.
+	old := time.Now().Add(-2 * time.Hour)
+	require.NoError(t, os.Chtimes(path, old, old))
+	require.NoError(t, sink.Reopen())
+
+	_, err = sink.Process(ctx, newTestFileEvent("new\n"))
+	require.NoError(t, err)
+
+	// Reopening a file started by a recent rotation shouldn't rotate it again,
+	// even if it has an old modification time.
+	require.NoError(t, os.Chtimes(path, old, old))
+	require.NoError(t, sink.Reopen())
+
+	_, err = sink.Process(ctx, newTestFileEvent("newer\n"))
+	require.NoError(t, err)
+	require.NoError(t, sink.Close(context.Background()))
+
+	current, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, "new\nnewer\n", string(current))
+
+	rotated, err := filepath.Glob(filepath.Join(dir, "audit-*.log"))
+	require.NoError(t, err)
+	require.Len(t, rotated, 1)
+}
+
+// TestFileSink_Rotate_MaxFiles_UnrelatedFiles ensures that removing the oldest
+// rotated files never removes other files which share the same prefix.
+func TestFileSink_Rotate_MaxFiles_UnrelatedFiles(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "audit.log")
+
+	unrelated := []string{
+		"audit-secondary.log",
+		"audit-secondary.log.gz",
+		"audit-1.log.bak",
+		"audit-123abc.log",
+		"audit-.log",
+	}
+	for _, name := range unrelated {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte("keep\n"), defaultFileMode))
+	}
+
+	sink, err := NewFileSink(path, "json", WithMaxBytes("10"), WithMaxFiles("1"))
+	require.NoError(t, err)
+
+	ctx := namespace.RootContext(nil)
+	for _, data := range []string{"aaaaaa\n", "bbbbbb\n", "cccccc\n"} {
+		_, err = sink.Process(ctx, newTestFileEvent(data))
+		require.NoError(t, err)
+	}
+	require.NoError(t, sink.Close(context.Background()))
+
+	for _, name := range unrelated {
+		require.FileExists(t, filepath.Join(dir, name))
+	}
+
+	rotated, err := sink.rotatedFiles()
+	require.NoError(t, err)
+	require.Len(t, rotated, 1)
+
+	b, err := os.ReadFile(rotated[0].path)
+	require.NoError(t, err)
+	require.Equal(t, "bbbbbb\n", string(b))
+}
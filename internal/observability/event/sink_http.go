@@ -0,0 +1,571 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package event
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/armon/go-metrics"
+	"github.com/hashicorp/eventlogger"
+	"github.com/hashicorp/go-cleanhttp"
+	"github.com/hashicorp/go-hclog"
+)
+
+const (
+	// maxRetryWait is the upper bound for the wait between attempts to send a batch.
+	maxRetryWait = 30 * time.Second
+
+	// spoolFileSuffix is the suffix used for batches stored in the spool directory.
+	spoolFileSuffix = ".batch"
+)
+
+var (
+	_ eventlogger.Node   = (*HTTPSink)(nil)
+	_ eventlogger.Closer = (*HTTPSink)(nil)
+
+	// ErrSpoolFull is returned when a batch cannot be delivered and storing it
+	// in the spool would exceed the configured spool size.
+	ErrSpoolFull = errors.New("spool is full")
+
+	// ErrBufferFull is returned when an event cannot be added to the batch as
+	// the sink already holds the maximum number of events waiting to be sent.
+	ErrBufferFull = errors.New("buffer is full")
+)
+
+// HTTPSink is a sink node which handles sending batches of events to an HTTP
+// endpoint using POST requests.
+// With a batch size of 1, each event is sent before Process returns, so that a
+// failure to deliver it fails the request being audited. Otherwise events are
+// buffered in memory, up to a maximum, until either the batch size is reached
+// or the batch interval elapses. Batches which cannot be delivered, after
+// retrying, are written to an (optional) spool directory and re-sent in order
+// once the endpoint is available again.
+type HTTPSink struct {
+	requiredFormat string
+	address        string
+	headers        map[string]string
+	client         *http.Client
+	batchSize      int
+	batchInterval  time.Duration
+	maxBuffered    int
+	maxRetries     int
+	retryWait      time.Duration
+	spoolDir       string
+	spoolSize      int64
+	logger         hclog.Logger
+
+	// bufferLock protects the in-memory batch of events.
+	bufferLock sync.Mutex
+	buffer     [][]byte
+
+	// sendLock serializes delivery of batches (and access to the spool) so
+	// that events are sent in the order they were processed.
+	sendLock sync.Mutex
+
+	// The goroutine which sends batches in the background is only started
+	// once the sink processes its first event, so that a sink which is
+	// discarded before it is used doesn't leak it.
+	startOnce sync.Once
+	running   bool
+	flushCh   chan struct{}
+	closeOnce sync.Once
+	stopCh    chan struct{}
+	doneCh    chan struct{}
+}
+
+// NewHTTPSink should be used to create a new HTTPSink.
+// Accepted options: WithBatchInterval, WithBatchSize, WithHeaders, WithLogger,
+// WithMaxBufferedEvents, WithMaxDuration, WithMaxRetries, WithRetryWait,
+// WithSpoolDir, WithSpoolSize and WithTLSConfig.
+func NewHTTPSink(address string, format string, opt ...Option) (*HTTPSink, error) {
+	address = strings.TrimSpace(address)
+	if address == "" {
+		return nil, fmt.Errorf("address is required: %w", ErrInvalidParameter)
+	}
+
+	u, err := url.Parse(address)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse address: %w: %w", ErrInvalidParameter, err)
+	}
+
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("address must use the http or https scheme: %w", ErrInvalidParameter)
+	}
+
+	format = strings.TrimSpace(format)
+	if format == "" {
+		return nil, fmt.Errorf("format is required: %w", ErrInvalidParameter)
+	}
+
+	opts, err := getOpts(opt...)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.withMaxBuffered < opts.withBatchSize {
+		return nil, fmt.Errorf("max buffered events must be at least the batch size: %w", ErrInvalidParameter)
+	}
+
+	if opts.withSpoolDir != "" {
+		if err := os.MkdirAll(opts.withSpoolDir, 0o700); err != nil {
+			return nil, fmt.Errorf("unable to create spool directory %q: %w", opts.withSpoolDir, err)
+		}
+	}
+
+	logger := opts.withLogger
+	if logger == nil || reflect.ValueOf(logger).IsNil() {
+		logger = hclog.NewNullLogger()
+	}
+
+	transport := cleanhttp.DefaultPooledTransport()
+	if opts.withTLSConfig != nil {
+		transport.TLSClientConfig = opts.withTLSConfig
+	}
+
+	sink := &HTTPSink{
+		requiredFormat: format,
+		address:        address,
+		headers:        opts.withHeaders,
+		client: &http.Client{
+			Transport: transport,
+			Timeout:   opts.withMaxDuration,
+		},
+		batchSize:     opts.withBatchSize,
+		batchInterval: opts.withBatchInterval,
+		maxBuffered:   opts.withMaxBuffered,
+		maxRetries:    opts.withMaxRetries,
+		retryWait:     opts.withRetryWait,
+		spoolDir:      opts.withSpoolDir,
+		spoolSize:     opts.withSpoolSize,
+		logger:        logger,
+		flushCh:       make(chan struct{}, 1),
+		stopCh:        make(chan struct{}),
+		doneCh:        make(chan struct{}),
+	}
+
+	return sink, nil
+}
+
+// Process handles adding the event to the current batch. Once the batch reaches
+// the configured batch size it is handed to the background sender, so that a
+// slow endpoint doesn't hold up the request being audited. With a batch size of
+// 1 the event is instead sent (or spooled) before returning, and any failure to
+// do so is returned.
+func (s *HTTPSink) Process(ctx context.Context, e *eventlogger.Event) (*eventlogger.Event, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
+	}
+
+	if e == nil {
+		return nil, fmt.Errorf("event is nil: %w", ErrInvalidParameter)
+	}
+
+	formatted, found := e.Format(s.requiredFormat)
+	if !found {
+		return nil, fmt.Errorf("unable to retrieve event formatted as %q: %w", s.requiredFormat, ErrInvalidParameter)
+	}
+
+	if s.batchSize == 1 {
+		s.sendLock.Lock()
+		defer s.sendLock.Unlock()
+
+		return nil, s.deliver(ctx, [][]byte{formatted})
+	}
+
+	s.start()
+
+	s.bufferLock.Lock()
+	if len(s.buffer) >= s.maxBuffered {
+		s.bufferLock.Unlock()
+		metrics.IncrCounter([]string{"audit", "http", "buffer_full"}, 1)
+		return nil, fmt.Errorf("unable to buffer event (%d events waiting to be sent): %w", s.maxBuffered, ErrBufferFull)
+	}
+	s.buffer = append(s.buffer, formatted)
+	full := len(s.buffer) >= s.batchSize
+	s.bufferLock.Unlock()
+
+	if full {
+		// A send is already pending if the channel is full, and it will pick
+		// up this event along with the rest of the buffer.
+		select {
+		case s.flushCh <- struct{}{}:
+		default:
+		}
+	}
+
+	// return nil for the event to indicate the pipeline is complete.
+	return nil, nil
+}
+
+// Reopen attempts to send any buffered events and any batches waiting in the spool.
+func (s *HTTPSink) Reopen() error {
+	ctx, cancel := context.WithTimeout(context.Background(), s.client.Timeout*time.Duration(s.maxRetries+1))
+	defer cancel()
+
+	return s.Flush(ctx)
+}
+
+// Type describes the type of this node (sink).
+func (*HTTPSink) Type() eventlogger.NodeType {
+	return eventlogger.NodeTypeSink
+}
+
+// Close stops the periodic sending of batches and attempts to send (or spool)
+// any events which are still buffered.
+func (s *HTTPSink) Close(ctx context.Context) error {
+	var err error
+
+	s.closeOnce.Do(func() {
+		// Prevent the background sender from being started after closing.
+		s.startOnce.Do(func() {})
+		close(s.stopCh)
+		if s.running {
+			<-s.doneCh
+		}
+		err = s.Flush(ctx)
+	})
+
+	return err
+}
+
+// Flush sends any buffered events as a single batch. When a spool directory is
+// configured, spooled batches are sent first to preserve ordering, and a batch
+// which cannot be delivered is spooled rather than returned as an error.
+func (s *HTTPSink) Flush(ctx context.Context) error {
+	s.sendLock.Lock()
+	defer s.sendLock.Unlock()
+
+	s.bufferLock.Lock()
+	events := s.buffer
+	s.buffer = nil
+	s.bufferLock.Unlock()
+
+	return s.deliver(ctx, events)
+}
+
+// deliver sends the events as a single batch, after any spooled batches, and
+// spools it when it cannot be delivered.
+// It relies on the caller holding the sendLock.
+func (s *HTTPSink) deliver(ctx context.Context, events [][]byte) error {
+	var batch []byte
+	if len(events) > 0 {
+		var dropped int
+		batch, dropped = s.encodeBatch(events)
+		if dropped > 0 {
+			// Events which can't be encoded will never succeed, so rather than
+			// retrying them we drop them and send the rest of the batch.
+			metrics.IncrCounter([]string{"audit", "http", "encode_failure"}, float32(dropped))
+			s.logger.Error("dropped audit events which could not be encoded", "dropped", dropped, "batch_size", len(events))
+		}
+	}
+
+	if s.spoolDir == "" {
+		if batch == nil {
+			return nil
+		}
+
+		return s.send(ctx, batch)
+	}
+
+	drainErr := s.drainSpool(ctx)
+	if batch == nil {
+		return drainErr
+	}
+
+	// Only attempt delivery if the spool is empty, otherwise the batch must
+	// wait its turn behind the batches which are already spooled.
+	if drainErr == nil {
+		err := s.send(ctx, batch)
+		if err == nil || !isRetryable(err) {
+			return err
+		}
+	}
+
+	return s.spool(batch)
+}
+
+// start starts the background sender, unless it is already running or the
+// sink has been closed.
+func (s *HTTPSink) start() {
+	s.startOnce.Do(func() {
+		s.running = true
+		go s.run()
+	})
+}
+
+// run sends any buffered events, periodically and whenever the batch is full,
+// until the sink is closed.
+func (s *HTTPSink) run() {
+	defer close(s.doneCh)
+
+	ticker := time.NewTicker(s.batchInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stopCh:
+			return
+		case <-s.flushCh:
+		case <-ticker.C:
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), s.batchInterval+s.client.Timeout*time.Duration(s.maxRetries+1))
+		err := s.Flush(ctx)
+		cancel()
+		if err != nil {
+			metrics.IncrCounter([]string{"audit", "http", "flush_failure"}, 1)
+			s.logger.Error("unable to send batch of audit events", "error", err)
+		}
+	}
+}
+
+// send attempts to POST the batch to the configured address, retrying with
+// an exponential backoff when the failure is considered temporary.
+func (s *HTTPSink) send(ctx context.Context, batch []byte) error {
+	wait := s.retryWait
+
+	var err error
+	for attempt := 0; attempt <= s.maxRetries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return fmt.Errorf("%w: %w", err, ctx.Err())
+			case <-time.After(wait):
+			}
+
+			wait *= 2
+			if wait > maxRetryWait {
+				wait = maxRetryWait
+			}
+		}
+
+		err = s.post(ctx, batch)
+		if err == nil || !isRetryable(err) {
+			return err
+		}
+	}
+
+	return err
+}
+
+// post makes a single POST request containing the batch.
+func (s *HTTPSink) post(ctx context.Context, batch []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.address, bytes.NewReader(batch))
+	if err != nil {
+		return &sendError{err: fmt.Errorf("unable to create request: %w", err)}
+	}
+
+	req.Header.Set("Content-Type", s.contentType())
+	for k, v := range s.headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return &sendError{err: fmt.Errorf("unable to send request: %w", err), retryable: true}
+	}
+	defer resp.Body.Close()
+
+	// Drain (a reasonable amount of) the body so that the connection can be reused.
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return nil
+	case resp.StatusCode == http.StatusTooManyRequests, resp.StatusCode == http.StatusRequestTimeout, resp.StatusCode >= 500:
+		return &sendError{err: fmt.Errorf("unexpected response status: %d", resp.StatusCode), retryable: true}
+	default:
+		return &sendError{err: fmt.Errorf("unexpected response status: %d", resp.StatusCode)}
+	}
+}
+
+// encodeBatch combines events into the body of a single request, returning
+// the number of events which could not be included. Events are usually sent
+// one per line, but OTLP events are each a complete export request, so their
+// resource logs are combined into a single export request.
+func (s *HTTPSink) encodeBatch(events [][]byte) ([]byte, int) {
+	if s.requiredFormat != "otlp" {
+		return bytes.Join(events, nil), 0
+	}
+
+	var dropped int
+	var batch struct {
+		ResourceLogs []json.RawMessage `json:"resourceLogs"`
+	}
+	for _, e := range events {
+		var req struct {
+			ResourceLogs []json.RawMessage `json:"resourceLogs"`
+		}
+		if err := json.Unmarshal(e, &req); err != nil {
+			dropped++
+			continue
+		}
+		batch.ResourceLogs = append(batch.ResourceLogs, req.ResourceLogs...)
+	}
+
+	if len(batch.ResourceLogs) == 0 {
+		return nil, dropped
+	}
+
+	b, err := json.Marshal(&batch)
+	if err != nil {
+		return nil, len(events)
+	}
+
+	return b, dropped
+}
+
+// contentType returns the value of the Content-Type header based on the required format.
+func (s *HTTPSink) contentType() string {
+	switch s.requiredFormat {
+	case "jsonx":
+		return "application/xml"
+	case "json":
+		return "application/x-ndjson"
+	case "otlp":
+		return "application/json"
+	case "cef", "leef":
+		return "text/plain"
+	default:
+		return "application/octet-stream"
+	}
+}
+
+// spool writes the batch to a new file in the spool directory, provided doing
+// so would not exceed the configured spool size.
+// It relies on the caller holding the sendLock.
+func (s *HTTPSink) spool(batch []byte) error {
+	files, used, err := s.spoolFiles()
+	if err != nil {
+		return err
+	}
+
+	if used+int64(len(batch)) > s.spoolSize {
+		metrics.IncrCounter([]string{"audit", "http", "spool_full"}, 1)
+		return fmt.Errorf("unable to spool batch of %d bytes (%d of %d bytes used): %w", len(batch), used, s.spoolSize, ErrSpoolFull)
+	}
+
+	// Names are based on the time and the number of existing files so that
+	// sorting them lexically gives the order in which they were written.
+	name := fmt.Sprintf("%020d-%010d%s", time.Now().UnixNano(), len(files), spoolFileSuffix)
+	path := filepath.Join(s.spoolDir, name)
+	tmp := path + ".tmp"
+
+	if err := os.WriteFile(tmp, batch, 0o600); err != nil {
+		return fmt.Errorf("unable to write spool file %q: %w", tmp, err)
+	}
+
+	if err := os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("unable to rename spool file %q: %w", tmp, err)
+	}
+
+	metrics.IncrCounter([]string{"audit", "http", "spooled"}, 1)
+
+	return nil
+}
+
+// drainSpool sends each spooled batch in order, removing it once delivered.
+// It stops at the first batch which cannot be delivered due to a temporary failure.
+// Batches which are rejected outright by the endpoint are discarded.
+// It relies on the caller holding the sendLock.
+func (s *HTTPSink) drainSpool(ctx context.Context) error {
+	files, _, err := s.spoolFiles()
+	if err != nil {
+		return err
+	}
+
+	for _, path := range files {
+		batch, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("unable to read spool file %q: %w", path, err)
+		}
+
+		err = s.send(ctx, batch)
+		if err != nil && isRetryable(err) {
+			return err
+		}
+
+		if err != nil {
+			metrics.IncrCounter([]string{"audit", "http", "spool_rejected"}, 1)
+		}
+
+		if err := os.Remove(path); err != nil {
+			return fmt.Errorf("unable to remove spool file %q: %w", path, err)
+		}
+	}
+
+	return nil
+}
+
+// spoolFiles returns the paths of the batches in the spool directory, in the
+// order they should be sent, along with the total number of bytes they use.
+func (s *HTTPSink) spoolFiles() ([]string, int64, error) {
+	entries, err := os.ReadDir(s.spoolDir)
+	if err != nil {
+		return nil, 0, fmt.Errorf("unable to read spool directory %q: %w", s.spoolDir, err)
+	}
+
+	var files []string
+	var used int64
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), spoolFileSuffix) {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return nil, 0, fmt.Errorf("unable to stat spool file %q: %w", entry.Name(), err)
+		}
+
+		files = append(files, filepath.Join(s.spoolDir, entry.Name()))
+		used += info.Size()
+	}
+
+	sort.Strings(files)
+
+	return files, used, nil
+}
+
+// sendError is returned when a batch could not be sent, and records whether
+// the failure is worth retrying.
+type sendError struct {
+	err       error
+	retryable bool
+}
+
+func (e *sendError) Error() string {
+	return e.err.Error()
+}
+
+func (e *sendError) Unwrap() error {
+	return e.err
+}
+
+// isRetryable determines if the error returned when sending a batch is temporary.
+func isRetryable(err error) bool {
+	var se *sendError
+	if errors.As(err, &se) {
+		return se.retryable
+	}
+
+	// Anything else (e.g. cancellation while waiting to retry) is treated as temporary.
+	return true
+}
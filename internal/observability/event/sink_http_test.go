@@ -0,0 +1,423 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package event
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/eventlogger"
+	"github.com/hashicorp/go-hclog"
+	"github.com/stretchr/testify/require"
+)
+
+// testCollector is a test HTTP server which records the bodies of the requests
+// it receives, and can be told to fail requests with a specific status code.
+type testCollector struct {
+	server     *httptest.Server
+	lock       sync.Mutex
+	bodies     []string
+	headers    []http.Header
+	failStatus atomic.Int32
+}
+
+func newTestCollector(t *testing.T) *testCollector {
+	t.Helper()
+
+	c := &testCollector{}
+	c.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if status := c.failStatus.Load(); status != 0 {
+			w.WriteHeader(int(status))
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		c.lock.Lock()
+		c.bodies = append(c.bodies, string(body))
+		c.headers = append(c.headers, r.Header.Clone())
+		c.lock.Unlock()
+	}))
+	t.Cleanup(c.server.Close)
+
+	return c
+}
+
+func (c *testCollector) received() []string {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return append([]string(nil), c.bodies...)
+}
+
+// newTestHTTPEvent creates an event which is already formatted as JSON.
+func newTestHTTPEvent(data string) *eventlogger.Event {
+	e := &eventlogger.Event{
+		Type:      "audit",
+		CreatedAt: time.Now(),
+		Formatted: make(map[string][]byte),
+	}
+	e.FormattedAs("json", []byte(data))
+
+	return e
+}
+
+// TestNewHTTPSink ensures that we validate the input arguments and can create
+// the HTTPSink if everything goes to plan.
+func TestNewHTTPSink(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		address        string
+		format         string
+		opts           []Option
+		wantErr        bool
+		expectedErrMsg string
+	}{
+		"address-empty": {
+			address:        "",
+			wantErr:        true,
+			expectedErrMsg: "address is required: invalid parameter",
+		},
+		"address-bad-scheme": {
+			address:        "tcp://foo",
+			format:         "json",
+			wantErr:        true,
+			expectedErrMsg: "address must use the http or https scheme: invalid parameter",
+		},
+		"format-whitespace": {
+			address:        "https://foo",
+			format:         "   ",
+			wantErr:        true,
+			expectedErrMsg: "format is required: invalid parameter",
+		},
+		"bad-batch-size": {
+			address:        "https://foo",
+			format:         "json",
+			opts:           []Option{WithBatchSize("0")},
+			wantErr:        true,
+			expectedErrMsg: "batch size must be at least 1: invalid parameter",
+		},
+		"bad-max-buffered-events": {
+			address:        "https://foo",
+			format:         "json",
+			opts:           []Option{WithBatchSize("10"), WithMaxBufferedEvents("5")},
+			wantErr:        true,
+			expectedErrMsg: "max buffered events must be at least the batch size: invalid parameter",
+		},
+		"bad-spool-size": {
+			address:        "https://foo",
+			format:         "json",
+			opts:           []Option{WithSpoolSize("lots")},
+			wantErr:        true,
+			expectedErrMsg: "unable to parse spool size: invalid parameter: could not parse capacity from input",
+		},
+		"happy": {
+			address: "https://foo",
+			format:  "json",
+		},
+	}
+
+	for name, tc := range tests {
+		name := name
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := NewHTTPSink(tc.address, tc.format, tc.opts...)
+
+			if tc.wantErr {
+				require.Error(t, err)
+				require.EqualError(t, err, tc.expectedErrMsg)
+				require.Nil(t, got)
+			} else {
+				require.NoError(t, err)
+				require.NotNil(t, got)
+				require.Equal(t, 100, got.batchSize)
+				require.Equal(t, time.Second, got.batchInterval)
+				require.NoError(t, got.Close(context.Background()))
+			}
+		})
+	}
+}
+
+// TestHTTPSink_Process_Batch ensures that events are only sent once the batch
+// size is reached, and that they are sent together with the configured headers.
+func TestHTTPSink_Process_Batch(t *testing.T) {
+	t.Parallel()
+
+	c := newTestCollector(t)
+	s, err := NewHTTPSink(c.server.URL, "json",
+		WithBatchSize("2"),
+		WithBatchInterval("1h"),
+		WithHeaders(map[string]string{"Authorization": "Bearer foo"}),
+	)
+	require.NoError(t, err)
+	defer s.Close(context.Background())
+
+	_, err = s.Process(context.Background(), newTestHTTPEvent("{\"a\":1}\n"))
+	require.NoError(t, err)
+	require.Empty(t, c.received())
+
+	_, err = s.Process(context.Background(), newTestHTTPEvent("{\"b\":2}\n"))
+	require.NoError(t, err)
+	require.Eventually(t, func() bool {
+		return len(c.received()) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, []string{"{\"a\":1}\n{\"b\":2}\n"}, c.received())
+	require.Equal(t, "Bearer foo", c.headers[0].Get("Authorization"))
+	require.Equal(t, "application/x-ndjson", c.headers[0].Get("Content-Type"))
+}
+
+// TestHTTPSink_Flush_Retry ensures that temporary failures are retried, and
+// that permanent failures are returned without retrying.
+func TestHTTPSink_Flush_Retry(t *testing.T) {
+	t.Parallel()
+
+	var attempts atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch attempts.Add(1) {
+		case 1:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case 2:
+			w.WriteHeader(http.StatusTooManyRequests)
+		case 3:
+			w.WriteHeader(http.StatusOK)
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+
+	s, err := NewHTTPSink(server.URL, "json", WithBatchInterval("1h"), WithRetryWait("1ms"))
+	require.NoError(t, err)
+	defer s.Close(context.Background())
+
+	_, err = s.Process(context.Background(), newTestHTTPEvent("{}\n"))
+	require.NoError(t, err)
+	require.NoError(t, s.Flush(context.Background()))
+	require.Equal(t, int32(3), attempts.Load())
+
+	_, err = s.Process(context.Background(), newTestHTTPEvent("{}\n"))
+	require.NoError(t, err)
+	err = s.Flush(context.Background())
+	require.EqualError(t, err, "unexpected response status: 400")
+	require.Equal(t, int32(4), attempts.Load())
+}
+
+// TestHTTPSink_Spool ensures that batches which cannot be delivered are
+// spooled, that the spool is bounded, and that spooled batches are sent in
+// order once the endpoint recovers.
+func TestHTTPSink_Spool(t *testing.T) {
+	t.Parallel()
+
+	c := newTestCollector(t)
+	c.failStatus.Store(http.StatusServiceUnavailable)
+	dir := t.TempDir()
+
+	s, err := NewHTTPSink(c.server.URL, "json",
+		WithBatchInterval("1h"),
+		WithMaxRetries("0"),
+		WithSpoolDir(dir),
+		WithSpoolSize("10"),
+	)
+	require.NoError(t, err)
+	defer s.Close(context.Background())
+
+	_, err = s.Process(context.Background(), newTestHTTPEvent("first\n"))
+	require.NoError(t, err)
+	require.NoError(t, s.Flush(context.Background()))
+
+	_, err = s.Process(context.Background(), newTestHTTPEvent("next\n"))
+	require.NoError(t, err)
+	require.ErrorIs(t, s.Flush(context.Background()), ErrSpoolFull)
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+
+	c.failStatus.Store(0)
+
+	_, err = s.Process(context.Background(), newTestHTTPEvent("last\n"))
+	require.NoError(t, err)
+	require.NoError(t, s.Flush(context.Background()))
+	require.Equal(t, []string{"first\n", "last\n"}, c.received())
+
+	entries, err = os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Empty(t, entries)
+}
+
+// TestHTTPSink_Process_SlowEndpoint ensures that processing an event which
+// fills the batch doesn't wait for the batch to be sent, and that the number
+// of events waiting to be sent is bounded.
+func TestHTTPSink_Process_SlowEndpoint(t *testing.T) {
+	t.Parallel()
+
+	unblock := make(chan struct{})
+	var started, requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started.Add(1)
+		<-unblock
+		requests.Add(1)
+	}))
+	defer server.Close()
+
+	s, err := NewHTTPSink(server.URL, "json", WithBatchSize("2"), WithMaxBufferedEvents("2"), WithBatchInterval("1h"))
+	require.NoError(t, err)
+	defer func() {
+		close(unblock)
+		require.NoError(t, s.Close(context.Background()))
+	}()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 2; i++ {
+			_, err := s.Process(context.Background(), newTestHTTPEvent("{}\n"))
+			require.NoError(t, err)
+		}
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("processing events was blocked by the endpoint")
+	}
+
+	// Once the first batch is being sent, the buffer fills up again and
+	// further events are rejected.
+	require.Eventually(t, func() bool {
+		return started.Load() == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	for i := 0; i < 2; i++ {
+		_, err := s.Process(context.Background(), newTestHTTPEvent("{}\n"))
+		require.NoError(t, err)
+	}
+	_, err = s.Process(context.Background(), newTestHTTPEvent("{}\n"))
+	require.ErrorIs(t, err, ErrBufferFull)
+	require.Zero(t, requests.Load())
+}
+
+// TestHTTPSink_Process_Synchronous ensures that with a batch size of 1 each
+// event is sent before Process returns, and that failures are returned.
+func TestHTTPSink_Process_Synchronous(t *testing.T) {
+	t.Parallel()
+
+	c := newTestCollector(t)
+	s, err := NewHTTPSink(c.server.URL, "json", WithBatchSize("1"), WithMaxRetries("0"))
+	require.NoError(t, err)
+	defer s.Close(context.Background())
+
+	_, err = s.Process(context.Background(), newTestHTTPEvent("{}\n"))
+	require.NoError(t, err)
+	require.Equal(t, []string{"{}\n"}, c.received())
+	require.False(t, s.running)
+
+	c.failStatus.Store(http.StatusServiceUnavailable)
+	_, err = s.Process(context.Background(), newTestHTTPEvent("{}\n"))
+	require.EqualError(t, err, "unexpected response status: 503")
+}
+
+// TestHTTPSink_Close_Unused ensures that a sink which never processed an
+// event doesn't start sending in the background, and can still be closed.
+func TestHTTPSink_Close_Unused(t *testing.T) {
+	t.Parallel()
+
+	c := newTestCollector(t)
+	s, err := NewHTTPSink(c.server.URL, "json")
+	require.NoError(t, err)
+	require.False(t, s.running)
+
+	require.NoError(t, s.Close(context.Background()))
+	require.False(t, s.running)
+
+	// Processing after closing mustn't start the background sender.
+	_, err = s.Process(context.Background(), newTestHTTPEvent("{}\n"))
+	require.NoError(t, err)
+	require.False(t, s.running)
+}
+
+// TestHTTPSink_Close ensures that closing the sink sends any buffered events.
+func TestHTTPSink_Close(t *testing.T) {
+	t.Parallel()
+
+	c := newTestCollector(t)
+	s, err := NewHTTPSink(c.server.URL, "json", WithBatchInterval("1h"))
+	require.NoError(t, err)
+
+	_, err = s.Process(context.Background(), newTestHTTPEvent("{}\n"))
+	require.NoError(t, err)
+	require.Empty(t, c.received())
+
+	require.NoError(t, s.Close(context.Background()))
+	require.Equal(t, []string{"{}\n"}, c.received())
+
+	// Closing again should be a no-op.
+	require.NoError(t, s.Close(context.Background()))
+}
+
+// TestHTTPSink_Process_OTLP ensures that OTLP events are combined into a single
+// export request for each batch.
+func TestHTTPSink_Process_OTLP(t *testing.T) {
+	t.Parallel()
+
+	c := newTestCollector(t)
+	s, err := NewHTTPSink(c.server.URL, "otlp", WithBatchSize("2"), WithBatchInterval("1h"))
+	require.NoError(t, err)
+	defer s.Close(context.Background())
+
+	for _, data := range []string{`{"resourceLogs":[{"a":1}]}`, `{"resourceLogs":[{"b":2}]}`} {
+		e := newTestHTTPEvent("")
+		e.FormattedAs("otlp", []byte(data+"\n"))
+		_, err = s.Process(context.Background(), e)
+		require.NoError(t, err)
+	}
+
+	require.Eventually(t, func() bool {
+		return len(c.received()) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, []string{`{"resourceLogs":[{"a":1},{"b":2}]}`}, c.received())
+	require.Equal(t, "application/json", c.headers[0].Get("Content-Type"))
+}
+
+// TestHTTPSink_Flush_OTLP_Invalid ensures that an OTLP event which can't be
+// combined with the rest of the batch is dropped without losing the other events.
+func TestHTTPSink_Flush_OTLP_Invalid(t *testing.T) {
+	t.Parallel()
+
+	c := newTestCollector(t)
+	s, err := NewHTTPSink(c.server.URL, "otlp", WithBatchInterval("1h"), WithLogger(hclog.NewNullLogger()))
+	require.NoError(t, err)
+	defer s.Close(context.Background())
+
+	for _, data := range []string{`{"resourceLogs":[{"a":1}]}`, `not json`, `{"resourceLogs":[{"b":2}]}`} {
+		e := newTestHTTPEvent("")
+		e.FormattedAs("otlp", []byte(data+"\n"))
+		_, err = s.Process(context.Background(), e)
+		require.NoError(t, err)
+	}
+
+	require.NoError(t, s.Flush(context.Background()))
+	require.Equal(t, []string{`{"resourceLogs":[{"a":1},{"b":2}]}`}, c.received())
+
+	// A batch where no events can be encoded isn't sent at all.
+	e := newTestHTTPEvent("")
+	e.FormattedAs("otlp", []byte("not json\n"))
+	_, err = s.Process(context.Background(), e)
+	require.NoError(t, err)
+	require.NoError(t, s.Flush(context.Background()))
+	require.Len(t, c.received(), 1)
+}
@@ -62,6 +62,8 @@ type Listener struct {
 	ClusterAddress          string        `hcl:"cluster_address"`
 	MaxRequestSize          int64         `hcl:"-"`
 	MaxRequestSizeRaw       interface{}   `hcl:"max_request_size"`
+	MaxStreamRequestSize    int64         `hcl:"-"`
+	MaxStreamRequestSizeRaw interface{}   `hcl:"max_stream_request_size"`
 	MaxRequestDuration      time.Duration `hcl:"-"`
 	MaxRequestDurationRaw   interface{}   `hcl:"max_request_duration"`
 	RequireRequestHeader    bool          `hcl:"-"`
@@ -446,6 +448,10 @@ func (l *Listener) parseRequestSettings() error {
 		return fmt.Errorf("error parsing max_request_size: %w", err)
 	}
 
+	if err := parseAndClearInt(&l.MaxStreamRequestSizeRaw, &l.MaxStreamRequestSize); err != nil {
+		return fmt.Errorf("error parsing max_stream_request_size: %w", err)
+	}
+
 	if l.MaxRequestDurationRaw != nil {
 		maxRequestDuration, err := parseutil.ParseDurationSecond(l.MaxRequestDurationRaw)
 		if err != nil {
@@ -183,6 +183,8 @@ func TestListener_parseRequestSettings(t *testing.T) {
 	tests := map[string]struct {
 		rawMaxRequestSize             any
 		expectedMaxRequestSize        int64
+		rawMaxStreamRequestSize       any
+		expectedMaxStreamRequestSize  int64
 		rawMaxRequestDuration         any
 		expectedDuration              time.Duration
 		rawRequireRequestHeader       any
@@ -205,6 +207,16 @@ func TestListener_parseRequestSettings(t *testing.T) {
 			expectedMaxRequestSize: 5,
 			isErrorExpected:        false,
 		},
+		"max-stream-request-size-bad": {
+			rawMaxStreamRequestSize: "juan",
+			isErrorExpected:         true,
+			errorMessage:            "error parsing max_stream_request_size",
+		},
+		"max-stream-request-size-good": {
+			rawMaxStreamRequestSize:      "1073741824",
+			expectedMaxStreamRequestSize: 1073741824,
+			isErrorExpected:              false,
+		},
 		"max-request-duration-bad": {
 			rawMaxRequestDuration: "juan",
 			isErrorExpected:       true,
@@ -248,6 +260,7 @@ func TestListener_parseRequestSettings(t *testing.T) {
 			// Configure listener with raw values
 			l := &Listener{
 				MaxRequestSizeRaw:        tc.rawMaxRequestSize,
+				MaxStreamRequestSizeRaw:  tc.rawMaxStreamRequestSize,
 				MaxRequestDurationRaw:    tc.rawMaxRequestDuration,
 				RequireRequestHeaderRaw:  tc.rawRequireRequestHeader,
 				DisableRequestLimiterRaw: tc.rawDisableRequestLimiter,
@@ -263,12 +276,14 @@ func TestListener_parseRequestSettings(t *testing.T) {
 				// Assert we got the relevant values.
 				require.NoError(t, err)
 				require.Equal(t, tc.expectedMaxRequestSize, l.MaxRequestSize)
+				require.Equal(t, tc.expectedMaxStreamRequestSize, l.MaxStreamRequestSize)
 				require.Equal(t, tc.expectedDuration, l.MaxRequestDuration)
 				require.Equal(t, tc.expectedRequireRequestHeader, l.RequireRequestHeader)
 				require.Equal(t, tc.expectedDisableRequestLimiter, l.DisableRequestLimiter)
 
 				// Ensure the state was modified for the raw values.
 				require.Nil(t, l.MaxRequestSizeRaw)
+				require.Nil(t, l.MaxStreamRequestSizeRaw)
 				require.Nil(t, l.MaxRequestDurationRaw)
 				require.Nil(t, l.RequireRequestHeaderRaw)
 				require.Nil(t, l.DisableRequestLimiterRaw)
@@ -25,6 +25,7 @@ import (
 	"encoding/pem"
 	"errors"
 	"fmt"
+	"io"
 	"math/big"
 	"net"
 	"net/url"
@@ -729,6 +730,27 @@ type CAInfoBundle struct {
 	URLs                 *URLEntries
 	LeafNotAfterBehavior NotAfterBehavior
 	RevocationSigAlg     x509.SignatureAlgorithm
+
+	// Certificate Transparency logs to which pre-certificates of leaf
+	// certificates are submitted, how many of their SCTs must be embedded
+	// in the issued certificate, how long to wait on each log and what to
+	// do when not enough SCTs were obtained.
+	CTLogs          []CTLog
+	CTRequiredSCTs  int
+	CTLogTimeout    time.Duration
+	CTFailurePolicy string
+}
+
+// CTLog is an RFC 6962 Certificate Transparency log.
+type CTLog struct {
+	// URL is the base URL of the log, to which /ct/v1/add-pre-chain is
+	// appended on submission.
+	URL string `json:"url"`
+
+	// PublicKey is the PEM or base64 DER encoded public key of the log,
+	// used to verify the SCTs it returns. When empty, SCTs are embedded
+	// without verification.
+	PublicKey string `json:"public_key,omitempty"`
 }
 
 func (b *CAInfoBundle) GetCAChain() []*CertBlock {
@@ -805,8 +827,15 @@ type CreationParameters struct {
 	ForceAppendCaChain            bool
 
 	// Only used when signing a CA cert
-	UseCSRValues        bool
-	PermittedDNSDomains []string
+	UseCSRValues            bool
+	PermittedDNSDomains     []string
+	ExcludedDNSDomains      []string
+	PermittedIPRanges       []*net.IPNet
+	ExcludedIPRanges        []*net.IPNet
+	PermittedEmailAddresses []string
+	ExcludedEmailAddresses  []string
+	PermittedURIDomains     []string
+	ExcludedURIDomains      []string
 
 	// URLs to encode into the certificate
 	URLs *URLEntries
@@ -825,8 +854,18 @@ type CreationBundle struct {
 	Params        *CreationParameters
 	SigningBundle *CAInfoBundle
 	CSR           *x509.CertificateRequest
+
+	// CertificateSigner, when set, replaces the signing of the certificate
+	// template by the signing bundle.
+	CertificateSigner CertificateSigner
 }
 
+// CertificateSigner signs a certificate template for the given public key,
+// returning the DER encoded certificate. It allows callers to control what
+// is signed, e.g. to sign a Certificate Transparency pre-certificate before
+// the final certificate.
+type CertificateSigner func(randReader io.Reader, template *x509.Certificate, pub crypto.PublicKey) ([]byte, error)
+
 // addKeyUsages adds appropriate key usages to the template given the creation
 // information
 func AddKeyUsages(data *CreationBundle, certTemplate *x509.Certificate) {
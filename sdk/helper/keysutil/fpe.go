@@ -0,0 +1,758 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package keysutil
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"regexp"
+	"regexp/syntax"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/hashicorp/vault/sdk/helper/errutil"
+)
+
+const (
+	// FPEMinDomainSize is the minimum number of possible values of the
+	// encrypted portion of a value, as required by NIST SP 800-38G Rev. 1.
+	FPEMinDomainSize = 1000000
+
+	// FPEMaxAlphabetSize is the largest radix supported by FF1 and FF3-1.
+	FPEMaxAlphabetSize = 1 << 16
+
+	// FF3TweakSize is the size in bytes of FF3-1 tweaks.
+	FF3TweakSize = 7
+
+	// FPEVersionGroup is the name of the capture group of a template which
+	// holds the key version in ciphertexts.
+	FPEVersionGroup = "version"
+
+	// FPEDefaultVersionLength is the number of characters of the alphabet
+	// which hold the key version at the start of the ciphertexts of keys
+	// without a template.
+	FPEDefaultVersionLength = 2
+)
+
+// builtinFPEAlphabets are the alphabets which can be referred to by name when
+// creating a format-preserving encryption key.
+var builtinFPEAlphabets = map[string]string{
+	"numeric":           "0123456789",
+	"alphalower":        "abcdefghijklmnopqrstuvwxyz",
+	"alphaupper":        "ABCDEFGHIJKLMNOPQRSTUVWXYZ",
+	"alphanumericlower": "0123456789abcdefghijklmnopqrstuvwxyz",
+	"alphanumericupper": "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ",
+	"alphanumeric":      "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz",
+}
+
+// ParseFPEAlphabet returns the characters of the given alphabet, which is
+// either the name of a built-in alphabet or the characters themselves.
+func ParseFPEAlphabet(alphabet string) (string, error) {
+	if builtin, ok := builtinFPEAlphabets[alphabet]; ok {
+		return builtin, nil
+	}
+
+	if !utf8.ValidString(alphabet) {
+		return "", fmt.Errorf("alphabet must be valid UTF-8")
+	}
+
+	seen := make(map[rune]struct{})
+	for _, r := range alphabet {
+		if _, ok := seen[r]; ok {
+			return "", fmt.Errorf("alphabet contains duplicate character %q", r)
+		}
+		seen[r] = struct{}{}
+	}
+	if len(seen) < 2 || len(seen) > FPEMaxAlphabetSize {
+		return "", fmt.Errorf("alphabet must contain between 2 and %d characters", FPEMaxAlphabetSize)
+	}
+
+	return alphabet, nil
+}
+
+// ValidateFPETemplate checks that the given template is a valid regular
+// expression with a version group and at least one other capture group, that
+// capture groups are not nested, and that they only match characters of the
+// given alphabet.
+func ValidateFPETemplate(template, alphabet string) error {
+	if template == "" {
+		return nil
+	}
+
+	re, err := syntax.Parse(template, syntax.Perl)
+	if err != nil {
+		return fmt.Errorf("invalid template: %w", err)
+	}
+	if re.MaxCap() < 2 {
+		return fmt.Errorf("template must contain at least one capture group besides the %s group", FPEVersionGroup)
+	}
+
+	var nested func(re *syntax.Regexp, inCapture bool) bool
+	nested = func(re *syntax.Regexp, inCapture bool) bool {
+		if re.Op == syntax.OpCapture {
+			if inCapture {
+				return true
+			}
+			inCapture = true
+		}
+		for _, sub := range re.Sub {
+			if nested(sub, inCapture) {
+				return true
+			}
+		}
+		return false
+	}
+	if nested(re, false) {
+		return fmt.Errorf("template must not contain nested capture groups")
+	}
+
+	runes := make(map[rune]struct{})
+	for _, r := range alphabet {
+		runes[r] = struct{}{}
+	}
+
+	if err := checkFPETemplateAlphabet(re, runes, false); err != nil {
+		return err
+	}
+
+	_, err = compileFPETemplate(template, alphabet)
+	return err
+}
+
+// checkFPETemplateAlphabet checks that the capture groups of the parsed
+// template only match characters of the alphabet, so that every value matching
+// the template can be encrypted.
+func checkFPETemplateAlphabet(re *syntax.Regexp, alphabet map[rune]struct{}, inCapture bool) error {
+	notInAlphabet := func(r rune) error {
+		if _, ok := alphabet[r]; !ok {
+			return fmt.Errorf("template capture groups match character %q which is not in the alphabet", r)
+		}
+		return nil
+	}
+
+	switch {
+	case re.Op == syntax.OpCapture:
+		inCapture = true
+
+	case !inCapture:
+		// Characters outside of capture groups are not encrypted
+	case re.Op == syntax.OpAnyChar || re.Op == syntax.OpAnyCharNotNL:
+		return fmt.Errorf("template capture groups must not match any character")
+
+	case re.Op == syntax.OpLiteral:
+		for _, r := range re.Rune {
+			if err := notInAlphabet(r); err != nil {
+				return err
+			}
+			if re.Flags&syntax.FoldCase != 0 {
+				for f := unicode.SimpleFold(r); f != r; f = unicode.SimpleFold(f) {
+					if err := notInAlphabet(f); err != nil {
+						return err
+					}
+				}
+			}
+		}
+
+	case re.Op == syntax.OpCharClass:
+		for i := 0; i < len(re.Rune); i += 2 {
+			lo, hi := re.Rune[i], re.Rune[i+1]
+			if int(hi-lo) >= len(alphabet) {
+				return fmt.Errorf("template capture groups match characters which are not in the alphabet")
+			}
+			for r := lo; r <= hi; r++ {
+				if err := notInAlphabet(r); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	for _, sub := range re.Sub {
+		if err := checkFPETemplateAlphabet(sub, alphabet, inCapture); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// fpeTemplate is the compiled template of a format-preserving encryption key.
+type fpeTemplate struct {
+	// ciphertext matches ciphertexts, while plaintext matches plaintexts, in
+	// which the version group is empty.
+	ciphertext *regexp.Regexp
+	plaintext  *regexp.Regexp
+
+	// version is the index of the version group, which holds versionLength
+	// characters of the alphabet in ciphertexts.
+	version       int
+	versionLength int
+}
+
+// compileFPETemplate compiles the template of a format-preserving encryption
+// key. Keys without a template encrypt the whole value, and hold the key
+// version in the first FPEDefaultVersionLength characters of ciphertexts.
+func compileFPETemplate(template, alphabet string) (*fpeTemplate, error) {
+	if template == "" {
+		var class strings.Builder
+		for _, r := range alphabet {
+			fmt.Fprintf(&class, `\x{%x}`, r)
+		}
+		template = fmt.Sprintf(`(?s)(?P<%s>[%s]{%d})(.*)`, FPEVersionGroup, class.String(), FPEDefaultVersionLength)
+	}
+
+	re, err := syntax.Parse(template, syntax.Perl)
+	if err != nil {
+		return nil, fmt.Errorf("invalid template: %w", err)
+	}
+
+	var groups []*syntax.Regexp
+	var find func(re *syntax.Regexp)
+	find = func(re *syntax.Regexp) {
+		if re.Op == syntax.OpCapture && re.Name == FPEVersionGroup {
+			groups = append(groups, re)
+		}
+		for _, sub := range re.Sub {
+			find(sub)
+		}
+	}
+	find(re)
+	if len(groups) != 1 {
+		return nil, fmt.Errorf("template must contain one capture group named %s, which holds the key version", FPEVersionGroup)
+	}
+
+	length, ok := fpeFixedLength(groups[0].Sub[0])
+	if !ok || length == 0 {
+		return nil, fmt.Errorf("the %s group of the template must match a fixed number of characters", FPEVersionGroup)
+	}
+
+	ciphertext, err := regexp.Compile("^(?:" + template + ")$")
+	if err != nil {
+		return nil, fmt.Errorf("invalid template: %w", err)
+	}
+
+	// Plaintexts have nothing in place of the key version
+	groups[0].Sub[0] = &syntax.Regexp{Op: syntax.OpEmptyMatch}
+	plaintext, err := regexp.Compile("^(?:" + re.String() + ")$")
+	if err != nil {
+		return nil, fmt.Errorf("invalid template: %w", err)
+	}
+
+	return &fpeTemplate{
+		ciphertext:    ciphertext,
+		plaintext:     plaintext,
+		version:       ciphertext.SubexpIndex(FPEVersionGroup),
+		versionLength: length,
+	}, nil
+}
+
+// fpeFixedLength returns the number of characters matched by the parsed
+// regular expression, if it always matches the same number of characters.
+func fpeFixedLength(re *syntax.Regexp) (int, bool) {
+	switch re.Op {
+	case syntax.OpEmptyMatch:
+		return 0, true
+	case syntax.OpLiteral:
+		return len(re.Rune), true
+	case syntax.OpCharClass, syntax.OpAnyChar, syntax.OpAnyCharNotNL:
+		return 1, true
+	case syntax.OpCapture:
+		return fpeFixedLength(re.Sub[0])
+	case syntax.OpRepeat:
+		n, ok := fpeFixedLength(re.Sub[0])
+		return n * re.Min, ok && re.Min == re.Max
+	case syntax.OpConcat:
+		var total int
+		for _, sub := range re.Sub {
+			n, ok := fpeFixedLength(sub)
+			if !ok {
+				return 0, false
+			}
+			total += n
+		}
+		return total, true
+	}
+
+	return 0, false
+}
+
+// spans returns the byte offsets of the capture groups of the match, in order,
+// along with the position of the version group among them, which is -1 if the
+// version group didn't match.
+func (t *fpeTemplate) spans(match []int) ([][2]int, int) {
+	var spans [][2]int
+	version := -1
+	for i := 1; i < len(match)/2; i++ {
+		if match[2*i] < 0 {
+			continue
+		}
+		if i == t.version {
+			version = len(spans)
+		}
+		spans = append(spans, [2]int{match[2*i], match[2*i+1]})
+	}
+
+	return spans, version
+}
+
+// getFPETemplate returns the compiled template of the key, which is cached
+// so that it is only compiled once.
+func (p *Policy) getFPETemplate() (*fpeTemplate, error) {
+	if raw, ok := p.versionPrefixCache.Load("fpe-template"); ok {
+		return raw.(*fpeTemplate), nil
+	}
+
+	tpl, err := compileFPETemplate(p.Template, p.Alphabet)
+	if err != nil {
+		return nil, errutil.InternalError{Err: fmt.Sprintf("error parsing template: %v", err)}
+	}
+
+	p.versionPrefixCache.Store("fpe-template", tpl)
+	return tpl, nil
+}
+
+// DecryptFormatPreserving decrypts the ciphertext of a format-preserving
+// encryption key, using the key version held by the version group of the
+// template.
+func (p *Policy) DecryptFormatPreserving(value string, factories ...interface{}) (string, error) {
+	if !p.Type.FormatPreserving() {
+		return "", errutil.UserError{Err: fmt.Sprintf("format-preserving decryption not supported for key type %v", p.Type)}
+	}
+
+	tweak, err := fpeTweak(factories)
+	if err != nil {
+		return "", err
+	}
+
+	tpl, err := p.getFPETemplate()
+	if err != nil {
+		return "", err
+	}
+
+	match := tpl.ciphertext.FindStringSubmatchIndex(value)
+	if match == nil {
+		return "", errutil.UserError{Err: "invalid ciphertext: does not match the template of the key"}
+	}
+	spans, v := tpl.spans(match)
+	if v < 0 {
+		return "", errutil.UserError{Err: "invalid ciphertext: no key version"}
+	}
+
+	radix := utf8.RuneCountInString(p.Alphabet)
+	ver := 0
+	for _, r := range value[spans[v][0]:spans[v][1]] {
+		i := strings.IndexRune(p.Alphabet, r)
+		if i < 0 {
+			return "", errutil.UserError{Err: "invalid ciphertext: key version contains characters which are not in the alphabet of the key"}
+		}
+		// The version only grows, so stop before it can overflow
+		if ver > p.LatestVersion {
+			break
+		}
+		ver = ver*radix + utf8.RuneCountInString(p.Alphabet[:i])
+	}
+
+	switch {
+	case ver == 0:
+		return "", errutil.UserError{Err: "invalid ciphertext: key version is zero"}
+	case ver > p.LatestVersion:
+		return "", errutil.UserError{Err: "invalid key version: version is too new"}
+	case p.MinDecryptionVersion > 0 && ver < p.MinDecryptionVersion:
+		return "", errutil.UserError{Err: ErrTooOld}
+	}
+
+	data := append(append([][2]int(nil), spans[:v]...), spans[v+1:]...)
+	texts, err := p.formatPreservingTransform(ver, tweak, value, data, true)
+	if err != nil {
+		return "", err
+	}
+
+	// Remove the key version
+	texts = append(texts[:v], append([]string{""}, texts[v:]...)...)
+
+	return base64.StdEncoding.EncodeToString([]byte(fpeSplice(value, spans, texts))), nil
+}
+
+// formatPreservingEncrypt encrypts the value with the given key version, and
+// holds the key version in the version group of the template.
+func (p *Policy) formatPreservingEncrypt(ver int, tweak []byte, value string) (string, error) {
+	tpl, err := p.getFPETemplate()
+	if err != nil {
+		return "", err
+	}
+
+	alphabet := []rune(p.Alphabet)
+	version := make([]rune, tpl.versionLength)
+	remaining := ver
+	for i := len(version) - 1; i >= 0; i-- {
+		version[i] = alphabet[remaining%len(alphabet)]
+		remaining /= len(alphabet)
+	}
+	if remaining != 0 {
+		return "", errutil.UserError{Err: fmt.Sprintf("key version %d cannot be held by the %d characters of the %s group of the template", ver, tpl.versionLength, FPEVersionGroup)}
+	}
+
+	match := tpl.plaintext.FindStringSubmatchIndex(value)
+	if match == nil {
+		return "", errutil.UserError{Err: "value does not match the template of the key"}
+	}
+	spans, v := tpl.spans(match)
+	if v < 0 {
+		return "", errutil.UserError{Err: "value does not match the template of the key"}
+	}
+
+	data := append(append([][2]int(nil), spans[:v]...), spans[v+1:]...)
+	texts, err := p.formatPreservingTransform(ver, tweak, value, data, false)
+	if err != nil {
+		return "", err
+	}
+
+	// Insert the key version, where the version group is empty
+	texts = append(texts[:v], append([]string{string(version)}, texts[v:]...)...)
+	ciphertext := fpeSplice(value, spans, texts)
+
+	// The version group must be able to hold the encoded key version, and be
+	// found again when decrypting.
+	check := tpl.ciphertext.FindStringSubmatchIndex(ciphertext)
+	if check == nil || ciphertext[check[2*tpl.version]:check[2*tpl.version+1]] != string(version) {
+		return "", errutil.UserError{Err: fmt.Sprintf("key version %d cannot be held by the %s group of the template", ver, FPEVersionGroup)}
+	}
+
+	return ciphertext, nil
+}
+
+// fpeSplice replaces each of the spans of the value with the corresponding text.
+func fpeSplice(value string, spans [][2]int, texts []string) string {
+	var result strings.Builder
+	var last int
+	for i, span := range spans {
+		result.WriteString(value[last:span[0]])
+		result.WriteString(texts[i])
+		last = span[1]
+	}
+	result.WriteString(value[last:])
+
+	return result.String()
+}
+
+// fpeTweak returns the associated data given by the factories, which is used
+// as the tweak of format-preserving encryption.
+func fpeTweak(factories []interface{}) ([]byte, error) {
+	for index, rawFactory := range factories {
+		if factory, ok := rawFactory.(AssociatedDataFactory); ok && factory != nil {
+			tweak, err := factory.GetAssociatedData()
+			if err != nil {
+				return nil, errutil.InternalError{Err: fmt.Sprintf("unable to get associated_data/additional_data from factory[%d]: %v", index, err)}
+			}
+			return tweak, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// formatPreservingTransform encrypts or decrypts the given spans of the value
+// with FF1 or FF3-1, returning the resulting text of each span.
+func (p *Policy) formatPreservingTransform(ver int, tweak []byte, value string, spans [][2]int, decrypt bool) ([]string, error) {
+	if !utf8.ValidString(value) {
+		return nil, errutil.UserError{Err: "value must be valid UTF-8"}
+	}
+
+	alphabet := []rune(p.Alphabet)
+	radix := len(alphabet)
+	indexes := make(map[rune]int, radix)
+	for i, r := range alphabet {
+		indexes[r] = i
+	}
+
+	var numerals []int
+	for _, span := range spans {
+		for _, r := range value[span[0]:span[1]] {
+			i, ok := indexes[r]
+			if !ok {
+				return nil, errutil.UserError{Err: fmt.Sprintf("value contains character %q which is not in the alphabet of the key", r)}
+			}
+			numerals = append(numerals, i)
+		}
+	}
+
+	if err := checkFPELength(p.Type, radix, len(numerals)); err != nil {
+		return nil, err
+	}
+
+	keyEntry, err := p.safeGetKeyEntry(ver)
+	if err != nil {
+		return nil, err
+	}
+
+	var out []int
+	switch p.Type {
+	case KeyType_FF1_AES256:
+		block, err := aes.NewCipher(keyEntry.Key)
+		if err != nil {
+			return nil, errutil.InternalError{Err: err.Error()}
+		}
+		out = ff1(block, tweak, radix, numerals, decrypt)
+
+	case KeyType_FF3_1_AES256:
+		switch len(tweak) {
+		case 0:
+			tweak = make([]byte, FF3TweakSize)
+		case FF3TweakSize:
+		default:
+			return nil, errutil.UserError{Err: fmt.Sprintf("tweak must be %d bytes for key type %v", FF3TweakSize, p.Type)}
+		}
+
+		// FF3-1 uses the key with its bytes in reverse order
+		key := make([]byte, len(keyEntry.Key))
+		for i, b := range keyEntry.Key {
+			key[len(key)-1-i] = b
+		}
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, errutil.InternalError{Err: err.Error()}
+		}
+		out = ff3_1(block, tweak, radix, numerals, decrypt)
+
+	default:
+		return nil, errutil.InternalError{Err: fmt.Sprintf("unsupported key type %v", p.Type)}
+	}
+
+	texts := make([]string, len(spans))
+	var n int
+	for i, span := range spans {
+		var text strings.Builder
+		for range value[span[0]:span[1]] {
+			text.WriteRune(alphabet[out[n]])
+			n++
+		}
+		texts[i] = text.String()
+	}
+
+	return texts, nil
+}
+
+// checkFPELength checks that the number of characters to be encrypted is
+// within the bounds of NIST SP 800-38G Rev. 1 for the given radix.
+func checkFPELength(keyType KeyType, radix, length int) error {
+	bigRadix := big.NewInt(int64(radix))
+	domain := new(big.Int).Exp(bigRadix, big.NewInt(int64(length)), nil)
+	if domain.Cmp(big.NewInt(FPEMinDomainSize)) < 0 {
+		return errutil.UserError{Err: fmt.Sprintf("value is too short; at least %d possible values of the encrypted characters are required", FPEMinDomainSize)}
+	}
+
+	if keyType == KeyType_FF3_1_AES256 {
+		// The halves of the value must each fit in 96 bits
+		maxLength := 0
+		limit := new(big.Int).Lsh(big.NewInt(1), 96)
+		for v := new(big.Int).Set(bigRadix); v.Cmp(limit) <= 0; v.Mul(v, bigRadix) {
+			maxLength += 2
+		}
+		if length > maxLength {
+			return errutil.UserError{Err: fmt.Sprintf("value is too long; at most %d characters can be encrypted with key type %v", maxLength, keyType)}
+		}
+	}
+
+	return nil
+}
+
+// ff1 implements the FF1 mode of NIST SP 800-38G.
+func ff1(block cipher.Block, tweak []byte, radix int, x []int, decrypt bool) []int {
+	n := len(x)
+	u := n / 2
+	v := n - u
+
+	bigRadix := big.NewInt(int64(radix))
+	modU := new(big.Int).Exp(bigRadix, big.NewInt(int64(u)), nil)
+	modV := new(big.Int).Exp(bigRadix, big.NewInt(int64(v)), nil)
+
+	b := (new(big.Int).Sub(modV, big.NewInt(1)).BitLen() + 7) / 8
+	d := 4*((b+3)/4) + 4
+
+	t := len(tweak)
+	header := []byte{
+		1, 2, 1,
+		byte(radix >> 16), byte(radix >> 8), byte(radix),
+		10, byte(u),
+		byte(n >> 24), byte(n >> 16), byte(n >> 8), byte(n),
+		byte(t >> 24), byte(t >> 16), byte(t >> 8), byte(t),
+	}
+
+	// The first block of the PRF input is the same for every round
+	prfStart := make([]byte, aes.BlockSize)
+	block.Encrypt(prfStart, header)
+
+	pad := (16 - (t+b+1)%16) % 16
+	q := make([]byte, t+pad+1+b)
+	copy(q, tweak)
+
+	s := make([]byte, ((d+15)/16)*16)
+	r := make([]byte, aes.BlockSize)
+	tmp := make([]byte, aes.BlockSize)
+
+	a := append([]int(nil), x[:u]...)
+	bb := append([]int(nil), x[u:]...)
+
+	round := func(i int, in []int) *big.Int {
+		q[t+pad] = byte(i)
+		numBytes := fpeNum(in, bigRadix).Bytes()
+		for j := range q[t+pad+1:] {
+			q[t+pad+1+j] = 0
+		}
+		copy(q[len(q)-len(numBytes):], numBytes)
+
+		copy(r, prfStart)
+		for off := 0; off < len(q); off += aes.BlockSize {
+			for j := 0; j < aes.BlockSize; j++ {
+				r[j] ^= q[off+j]
+			}
+			block.Encrypt(r, r)
+		}
+
+		copy(s, r)
+		for j := 1; j*aes.BlockSize < d; j++ {
+			copy(tmp, r)
+			tmp[aes.BlockSize-1] ^= byte(j)
+			tmp[aes.BlockSize-2] ^= byte(j >> 8)
+			block.Encrypt(s[j*aes.BlockSize:], tmp)
+		}
+
+		return new(big.Int).SetBytes(s[:d])
+	}
+
+	if !decrypt {
+		for i := 0; i < 10; i++ {
+			m, mod := u, modU
+			if i%2 == 1 {
+				m, mod = v, modV
+			}
+			y := round(i, bb)
+			c := y.Add(y, fpeNum(a, bigRadix))
+			c.Mod(c, mod)
+			a, bb = bb, fpeStr(c, bigRadix, m)
+		}
+	} else {
+		for i := 9; i >= 0; i-- {
+			m, mod := u, modU
+			if i%2 == 1 {
+				m, mod = v, modV
+			}
+			y := round(i, a)
+			c := new(big.Int).Sub(fpeNum(bb, bigRadix), y)
+			c.Mod(c, mod)
+			bb, a = a, fpeStr(c, bigRadix, m)
+		}
+	}
+
+	return append(a, bb...)
+}
+
+// ff3_1 implements the FF3-1 mode of NIST SP 800-38G Rev. 1. The block cipher
+// must be keyed with the reversed key.
+func ff3_1(block cipher.Block, tweak []byte, radix int, x []int, decrypt bool) []int {
+	n := len(x)
+	u := (n + 1) / 2
+	v := n - u
+
+	bigRadix := big.NewInt(int64(radix))
+	modU := new(big.Int).Exp(bigRadix, big.NewInt(int64(u)), nil)
+	modV := new(big.Int).Exp(bigRadix, big.NewInt(int64(v)), nil)
+
+	tweakL := []byte{tweak[0], tweak[1], tweak[2], tweak[3] & 0xf0}
+	tweakR := []byte{tweak[4], tweak[5], tweak[6], (tweak[3] & 0x0f) << 4}
+
+	p := make([]byte, aes.BlockSize)
+	round := func(i int, in []int) *big.Int {
+		w := tweakR
+		if i%2 == 1 {
+			w = tweakL
+		}
+		copy(p, w)
+		p[3] ^= byte(i)
+
+		for j := range p[4:] {
+			p[4+j] = 0
+		}
+		numBytes := fpeNum(reverseNumerals(in), bigRadix).Bytes()
+		copy(p[aes.BlockSize-len(numBytes):], numBytes)
+
+		reverseBytes(p)
+		block.Encrypt(p, p)
+		reverseBytes(p)
+
+		return new(big.Int).SetBytes(p)
+	}
+
+	a := append([]int(nil), x[:u]...)
+	b := append([]int(nil), x[u:]...)
+
+	if !decrypt {
+		for i := 0; i < 8; i++ {
+			m, mod := u, modU
+			if i%2 == 1 {
+				m, mod = v, modV
+			}
+			y := round(i, b)
+			c := y.Add(y, fpeNum(reverseNumerals(a), bigRadix))
+			c.Mod(c, mod)
+			a, b = b, reverseNumerals(fpeStr(c, bigRadix, m))
+		}
+	} else {
+		for i := 7; i >= 0; i-- {
+			m, mod := u, modU
+			if i%2 == 1 {
+				m, mod = v, modV
+			}
+			y := round(i, a)
+			c := new(big.Int).Sub(fpeNum(reverseNumerals(b), bigRadix), y)
+			c.Mod(c, mod)
+			b, a = a, reverseNumerals(fpeStr(c, bigRadix, m))
+		}
+	}
+
+	return append(a, b...)
+}
+
+// fpeNum returns the number represented by the numerals, most significant
+// first.
+func fpeNum(x []int, radix *big.Int) *big.Int {
+	num := new(big.Int)
+	digit := new(big.Int)
+	for _, numeral := range x {
+		num.Mul(num, radix)
+		num.Add(num, digit.SetInt64(int64(numeral)))
+	}
+	return num
+}
+
+// fpeStr returns the m numerals representing the number, most significant
+// first.
+func fpeStr(num *big.Int, radix *big.Int, m int) []int {
+	x := make([]int, m)
+	num = new(big.Int).Set(num)
+	rem := new(big.Int)
+	for i := m - 1; i >= 0; i-- {
+		num.QuoRem(num, radix, rem)
+		x[i] = int(rem.Int64())
+	}
+	return x
+}
+
+func reverseNumerals(x []int) []int {
+	out := make([]int, len(x))
+	for i, numeral := range x {
+		out[len(x)-1-i] = numeral
+	}
+	return out
+}
+
+func reverseBytes(b []byte) {
+	for i, j := 0, len(b)-1; i < j; i, j = i+1, j-1 {
+		b[i], b[j] = b[j], b[i]
+	}
+}
@@ -68,6 +68,13 @@ type PolicyRequest struct {
 
 	// The UUID of the managed key, if using one
 	ManagedKeyUUID string
+
+	// The alphabet of format-preserving encryption keys, either the name of a
+	// built-in alphabet or its characters
+	Alphabet string
+
+	// The template of format-preserving encryption keys
+	Template string
 }
 
 type LockManager struct {
@@ -397,11 +404,41 @@ func (lm *LockManager) GetPolicy(ctx context.Context, req PolicyRequest, rand io
 				return nil, false, fmt.Errorf("key derivation and convergent encryption not supported for keys of type %v", req.KeyType)
 			}
 
+		case KeyType_ML_DSA_44, KeyType_ML_DSA_65, KeyType_ML_DSA_87, KeyType_ML_KEM_768, KeyType_ML_KEM_1024, KeyType_HYBRID_ED25519_ML_DSA_65:
+			if req.Derived || req.Convergent {
+				cleanup()
+				return nil, false, fmt.Errorf("key derivation and convergent encryption not supported for keys of type %v", req.KeyType)
+			}
+
+		case KeyType_FF1_AES256, KeyType_FF3_1_AES256:
+			if req.Derived || req.Convergent {
+				cleanup()
+				return nil, false, fmt.Errorf("key derivation and convergent encryption not supported for keys of type %v", req.KeyType)
+			}
+			if req.Alphabet == "" {
+				cleanup()
+				return nil, false, fmt.Errorf("alphabet is required for keys of type %v", req.KeyType)
+			}
+			req.Alphabet, err = ParseFPEAlphabet(req.Alphabet)
+			if err != nil {
+				cleanup()
+				return nil, false, err
+			}
+			if err := ValidateFPETemplate(req.Template, req.Alphabet); err != nil {
+				cleanup()
+				return nil, false, err
+			}
+
 		default:
 			cleanup()
 			return nil, false, fmt.Errorf("unsupported key type %v", req.KeyType)
 		}
 
+		if !req.KeyType.FormatPreserving() && (req.Alphabet != "" || req.Template != "") {
+			cleanup()
+			return nil, false, fmt.Errorf("alphabet and template are not supported for keys of type %v", req.KeyType)
+		}
+
 		p = &Policy{
 			l:                    new(sync.RWMutex),
 			Name:                 req.Name,
@@ -411,6 +448,8 @@ func (lm *LockManager) GetPolicy(ctx context.Context, req PolicyRequest, rand io
 			AllowPlaintextBackup: req.AllowPlaintextBackup,
 			AutoRotatePeriod:     req.AutoRotatePeriod,
 			KeySize:              req.KeySize,
+			Alphabet:             req.Alphabet,
+			Template:             req.Template,
 		}
 
 		if req.Derived {
@@ -0,0 +1,162 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package keysutil
+
+import (
+	"crypto/aes"
+	"crypto/subtle"
+	"encoding/binary"
+	"fmt"
+
+	"golang.org/x/crypto/sha3"
+)
+
+const (
+	// KMACDefaultLength is the default length in bytes of KMAC output.
+	KMACDefaultLength = 32
+
+	// KMACMinLength and KMACMaxLength bound the length in bytes of KMAC
+	// output.
+	KMACMinLength = 16
+	KMACMaxLength = 64
+)
+
+// CMACKey returns the AES key of the given version for computing AES-CMAC over
+// messages. The AES key is used as is, so that the MAC can be computed and
+// verified by other parties holding an imported or exported copy of the key;
+// for the same reason, keys with key derivation enabled are not supported.
+func (p *Policy) CMACKey(version int) ([]byte, error) {
+	if !p.Type.CMACSupported() {
+		return nil, fmt.Errorf("CMAC not supported for key type %v", p.Type)
+	}
+	if p.Derived {
+		return nil, fmt.Errorf("CMAC not supported for keys with key derivation enabled")
+	}
+
+	switch {
+	case version < 0:
+		return nil, fmt.Errorf("key version does not exist (cannot be negative)")
+	case version > p.LatestVersion:
+		return nil, fmt.Errorf("key version does not exist; latest key version is %d", p.LatestVersion)
+	}
+	keyEntry, err := p.safeGetKeyEntry(version)
+	if err != nil {
+		return nil, err
+	}
+
+	return keyEntry.Key, nil
+}
+
+// CMAC computes the AES-CMAC (NIST SP 800-38B, RFC 4493) of the input with
+// the given AES key.
+func CMAC(key, input []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	// Derive the subkeys from the encryption of the zero block
+	k1 := make([]byte, aes.BlockSize)
+	block.Encrypt(k1, k1)
+	k1 = cmacDouble(k1)
+	k2 := cmacDouble(k1)
+
+	n := (len(input) + aes.BlockSize - 1) / aes.BlockSize
+	complete := n > 0 && len(input)%aes.BlockSize == 0
+	if n == 0 {
+		n = 1
+	}
+
+	mac := make([]byte, aes.BlockSize)
+	for i := 0; i < n-1; i++ {
+		subtle.XORBytes(mac, mac, input[i*aes.BlockSize:(i+1)*aes.BlockSize])
+		block.Encrypt(mac, mac)
+	}
+
+	// The last block is masked with the first subkey when complete, and
+	// padded and masked with the second otherwise
+	last := make([]byte, aes.BlockSize)
+	copy(last, input[(n-1)*aes.BlockSize:])
+	if complete {
+		subtle.XORBytes(last, last, k1)
+	} else {
+		last[len(input)-(n-1)*aes.BlockSize] = 0x80
+		subtle.XORBytes(last, last, k2)
+	}
+	subtle.XORBytes(mac, mac, last)
+	block.Encrypt(mac, mac)
+
+	return mac, nil
+}
+
+// cmacDouble multiplies the block by x in GF(2^128).
+func cmacDouble(in []byte) []byte {
+	out := make([]byte, len(in))
+	var carry byte
+	for i := len(in) - 1; i >= 0; i-- {
+		out[i] = in[i]<<1 | carry
+		carry = in[i] >> 7
+	}
+	if carry != 0 {
+		out[len(out)-1] ^= 0x87
+	}
+	return out
+}
+
+// KMAC computes KMAC128 or KMAC256 (NIST SP 800-185) of the input with the
+// given key and customization string, returning length bytes of output.
+func KMAC(bits int, key, input, customization []byte, length int) ([]byte, error) {
+	if length < KMACMinLength || length > KMACMaxLength {
+		return nil, fmt.Errorf("KMAC length must be between %d and %d bytes", KMACMinLength, KMACMaxLength)
+	}
+
+	var h sha3.ShakeHash
+	var rate int
+	switch bits {
+	case 128:
+		h = sha3.NewCShake128([]byte("KMAC"), customization)
+		rate = 168
+	case 256:
+		h = sha3.NewCShake256([]byte("KMAC"), customization)
+		rate = 136
+	default:
+		return nil, fmt.Errorf("unsupported KMAC variant KMAC%d", bits)
+	}
+
+	h.Write(kmacBytepad(kmacEncodeString(key), rate))
+	h.Write(input)
+	h.Write(kmacRightEncode(uint64(length) * 8))
+
+	out := make([]byte, length)
+	h.Read(out)
+	return out, nil
+}
+
+// kmacLeftEncode and kmacRightEncode encode an integer as in NIST SP 800-185,
+// as its big-endian bytes preceded or followed by their count.
+func kmacLeftEncode(x uint64) []byte {
+	buf := binary.BigEndian.AppendUint64(nil, x)
+	i := 0
+	for i < len(buf)-1 && buf[i] == 0 {
+		i++
+	}
+	return append([]byte{byte(len(buf) - i)}, buf[i:]...)
+}
+
+func kmacRightEncode(x uint64) []byte {
+	buf := kmacLeftEncode(x)
+	return append(buf[1:], buf[0])
+}
+
+func kmacEncodeString(s []byte) []byte {
+	return append(kmacLeftEncode(uint64(len(s))*8), s...)
+}
+
+func kmacBytepad(x []byte, w int) []byte {
+	buf := append(kmacLeftEncode(uint64(w)), x...)
+	if pad := len(buf) % w; pad != 0 {
+		buf = append(buf, make([]byte, w-pad)...)
+	}
+	return buf
+}
@@ -68,6 +68,14 @@ const (
 	KeyType_RSA3072
 	KeyType_MANAGED_KEY
 	KeyType_HMAC
+	KeyType_ML_DSA_44
+	KeyType_ML_DSA_65
+	KeyType_ML_DSA_87
+	KeyType_ML_KEM_768
+	KeyType_ML_KEM_1024
+	KeyType_HYBRID_ED25519_ML_DSA_65
+	KeyType_FF1_AES256
+	KeyType_FF3_1_AES256
 )
 
 const (
@@ -122,7 +130,8 @@ type KeyType int
 
 func (kt KeyType) EncryptionSupported() bool {
 	switch kt {
-	case KeyType_AES128_GCM96, KeyType_AES256_GCM96, KeyType_ChaCha20_Poly1305, KeyType_RSA2048, KeyType_RSA3072, KeyType_RSA4096, KeyType_MANAGED_KEY:
+	case KeyType_AES128_GCM96, KeyType_AES256_GCM96, KeyType_ChaCha20_Poly1305, KeyType_RSA2048, KeyType_RSA3072, KeyType_RSA4096, KeyType_MANAGED_KEY,
+		KeyType_FF1_AES256, KeyType_FF3_1_AES256:
 		return true
 	}
 	return false
@@ -130,7 +139,19 @@ func (kt KeyType) EncryptionSupported() bool {
 
 func (kt KeyType) DecryptionSupported() bool {
 	switch kt {
-	case KeyType_AES128_GCM96, KeyType_AES256_GCM96, KeyType_ChaCha20_Poly1305, KeyType_RSA2048, KeyType_RSA3072, KeyType_RSA4096, KeyType_MANAGED_KEY:
+	case KeyType_AES128_GCM96, KeyType_AES256_GCM96, KeyType_ChaCha20_Poly1305, KeyType_RSA2048, KeyType_RSA3072, KeyType_RSA4096, KeyType_MANAGED_KEY, KeyType_ML_KEM_768, KeyType_ML_KEM_1024,
+		KeyType_FF1_AES256, KeyType_FF3_1_AES256:
+		return true
+	}
+	return false
+}
+
+// KeyEncapsulationSupported returns whether the key type establishes shared
+// secrets through a key encapsulation mechanism (KEM) rather than encrypting
+// arbitrary plaintext. Encapsulated secrets are recovered through decryption.
+func (kt KeyType) KeyEncapsulationSupported() bool {
+	switch kt {
+	case KeyType_ML_KEM_768, KeyType_ML_KEM_1024:
 		return true
 	}
 	return false
@@ -138,7 +159,8 @@ func (kt KeyType) DecryptionSupported() bool {
 
 func (kt KeyType) SigningSupported() bool {
 	switch kt {
-	case KeyType_ECDSA_P256, KeyType_ECDSA_P384, KeyType_ECDSA_P521, KeyType_ED25519, KeyType_RSA2048, KeyType_RSA3072, KeyType_RSA4096, KeyType_MANAGED_KEY:
+	case KeyType_ECDSA_P256, KeyType_ECDSA_P384, KeyType_ECDSA_P521, KeyType_ED25519, KeyType_RSA2048, KeyType_RSA3072, KeyType_RSA4096, KeyType_MANAGED_KEY,
+		KeyType_ML_DSA_44, KeyType_ML_DSA_65, KeyType_ML_DSA_87, KeyType_HYBRID_ED25519_ML_DSA_65:
 		return true
 	}
 	return false
@@ -162,7 +184,26 @@ func (kt KeyType) DerivationSupported() bool {
 
 func (kt KeyType) AssociatedDataSupported() bool {
 	switch kt {
-	case KeyType_AES128_GCM96, KeyType_AES256_GCM96, KeyType_ChaCha20_Poly1305, KeyType_MANAGED_KEY:
+	case KeyType_AES128_GCM96, KeyType_AES256_GCM96, KeyType_ChaCha20_Poly1305, KeyType_MANAGED_KEY, KeyType_FF1_AES256, KeyType_FF3_1_AES256:
+		return true
+	}
+	return false
+}
+
+// FormatPreserving returns whether the key type encrypts values into values
+// of the same format. The associated data of these key types is used as the
+// tweak.
+func (kt KeyType) FormatPreserving() bool {
+	switch kt {
+	case KeyType_FF1_AES256, KeyType_FF3_1_AES256:
+		return true
+	}
+	return false
+}
+
+func (kt KeyType) CMACSupported() bool {
+	switch kt {
+	case KeyType_AES128_GCM96, KeyType_AES256_GCM96:
 		return true
 	}
 	return false
@@ -202,6 +243,22 @@ func (kt KeyType) String() string {
 		return "hmac"
 	case KeyType_MANAGED_KEY:
 		return "managed_key"
+	case KeyType_ML_DSA_44:
+		return "ml-dsa-44"
+	case KeyType_ML_DSA_65:
+		return "ml-dsa-65"
+	case KeyType_ML_DSA_87:
+		return "ml-dsa-87"
+	case KeyType_ML_KEM_768:
+		return "ml-kem-768"
+	case KeyType_ML_KEM_1024:
+		return "ml-kem-1024"
+	case KeyType_HYBRID_ED25519_ML_DSA_65:
+		return "ed25519-ml-dsa-65"
+	case KeyType_FF1_AES256:
+		return "aes256-ff1"
+	case KeyType_FF3_1_AES256:
+		return "aes256-ff3-1"
 	}
 
 	return "[unknown]"
@@ -371,6 +428,8 @@ func LoadPolicy(ctx context.Context, s logical.Storage, path string) (*Policy, e
 	}
 
 	policy.l = new(sync.RWMutex)
+	policy.loadOperationCounts()
+	policy.checkOperationLimit()
 
 	return &policy, nil
 }
@@ -466,6 +525,40 @@ type Policy struct {
 
 	// AllowImportedKeyRotation indicates whether an imported key may be rotated by Vault
 	AllowImportedKeyRotation bool
+
+	// Alphabet is the set of characters encrypted by format-preserving
+	// encryption keys.
+	Alphabet string `json:"alphabet,omitempty"`
+
+	// Template is a regular expression whose capture groups select the
+	// characters encrypted by format-preserving encryption keys. If empty,
+	// the whole value is encrypted.
+	Template string `json:"template,omitempty"`
+
+	// MaxOperationsPerVersion is the number of encryption and signing
+	// operations after which a key version reaches its operation limit, or 0
+	// for no limit.
+	MaxOperationsPerVersion uint64 `json:"max_operations_per_version,omitempty"`
+
+	// OperationLimitAction is the action taken once the latest version of
+	// the key reaches its operation limit.
+	OperationLimitAction string `json:"operation_limit_action,omitempty"`
+
+	// OperationCounts holds the number of operations performed with each key
+	// version, keyed by version.
+	OperationCounts map[string]*OperationCount `json:"operation_counts,omitempty"`
+
+	// ExportApprovalsRequired is the number of approvals an export request
+	// needs before the key material is released. If 0, the key material can
+	// be exported without approval.
+	ExportApprovalsRequired int `json:"export_approvals_required,omitempty"`
+
+	// ExportApproverPolicy is the policy export request approvers must have.
+	ExportApproverPolicy string `json:"export_approver_policy,omitempty"`
+
+	// rotationRequired is set once the latest version of the key reaches its
+	// operation limit and the key should be rotated.
+	rotationRequired uint32
 }
 
 func (p *Policy) Lock(exclusive bool) {
@@ -647,6 +740,10 @@ func (p *Policy) Persist(ctx context.Context, storage logical.Storage) (retErr e
 		return err
 	}
 
+	p.initOperationCounts()
+	p.foldOperationCounts()
+	p.checkOperationLimit()
+
 	// Encode the policy
 	buf, err := p.Serialize()
 	if err != nil {
@@ -913,6 +1010,11 @@ func (p *Policy) DecryptWithFactory(context, nonce []byte, value string, factori
 		return "", errutil.UserError{Err: fmt.Sprintf("message decryption not supported for key type %v", p.Type)}
 	}
 
+	// Format-preserving ciphertexts have no version prefix
+	if p.Type.FormatPreserving() {
+		return p.DecryptFormatPreserving(value, factories...)
+	}
+
 	tplParts, err := p.getTemplateParts()
 	if err != nil {
 		return "", err
@@ -1043,6 +1145,19 @@ func (p *Policy) DecryptWithFactory(context, nonce []byte, value string, factori
 			return "", err
 		}
 
+	case KeyType_ML_KEM_768, KeyType_ML_KEM_1024:
+		keyEntry, err := p.safeGetKeyEntry(ver)
+		if err != nil {
+			return "", err
+		}
+		if keyEntry.IsPrivateKeyMissing() {
+			return "", errutil.UserError{Err: "cannot decrypt ciphertext, key version does not have a private counterpart"}
+		}
+		plain, err = decapsulate(p.Type, keyEntry.Key, decoded)
+		if err != nil {
+			return "", errutil.UserError{Err: fmt.Sprintf("failed to decapsulate the ciphertext: %v", err)}
+		}
+
 	default:
 		return "", errutil.InternalError{Err: fmt.Sprintf("unsupported key type %v", p.Type)}
 	}
@@ -1110,6 +1225,10 @@ func (p *Policy) SignWithOptions(ver int, context, input []byte, options *Signin
 		return nil, errutil.UserError{Err: "requested version for signing is less than the minimum encryption key version"}
 	}
 
+	if err := p.countOperation(ver, true); err != nil {
+		return nil, err
+	}
+
 	var sig []byte
 	var pubKey []byte
 	var err error
@@ -1256,6 +1375,13 @@ func (p *Policy) SignWithOptions(ver int, context, input []byte, options *Signin
 			return nil, err
 		}
 
+	case KeyType_ML_DSA_44, KeyType_ML_DSA_65, KeyType_ML_DSA_87, KeyType_HYBRID_ED25519_ML_DSA_65:
+		// Like ed25519, ML-DSA performs its own hashing of the input
+		sig, err = signPostQuantum(p.Type, keyParams.Key, input)
+		if err != nil {
+			return nil, err
+		}
+
 	default:
 		return nil, fmt.Errorf("unsupported key type %v", p.Type)
 	}
@@ -1453,6 +1579,14 @@ func (p *Policy) VerifySignatureWithOptions(context, input []byte, sig string, o
 
 		return p.verifyWithManagedKey(options, keyEntry, input, sigBytes)
 
+	case KeyType_ML_DSA_44, KeyType_ML_DSA_65, KeyType_ML_DSA_87, KeyType_HYBRID_ED25519_ML_DSA_65:
+		keyEntry, err := p.safeGetKeyEntry(ver)
+		if err != nil {
+			return false, err
+		}
+
+		return verifyPostQuantum(p.Type, keyEntry.FormattedPublicKey, input, sigBytes)
+
 	default:
 		return false, errutil.InternalError{Err: fmt.Sprintf("unsupported key type %v", p.Type)}
 	}
@@ -1618,7 +1752,7 @@ func (p *Policy) RotateInMemory(randReader io.Reader) (retErr error) {
 	entry.HMACKey = hmacKey
 
 	switch p.Type {
-	case KeyType_AES128_GCM96, KeyType_AES256_GCM96, KeyType_ChaCha20_Poly1305, KeyType_HMAC:
+	case KeyType_AES128_GCM96, KeyType_AES256_GCM96, KeyType_ChaCha20_Poly1305, KeyType_HMAC, KeyType_FF1_AES256, KeyType_FF3_1_AES256:
 		// Default to 256 bit key
 		numBytes := 32
 		if p.Type == KeyType_AES128_GCM96 {
@@ -1699,6 +1833,12 @@ func (p *Policy) RotateInMemory(randReader io.Reader) (retErr error) {
 		if err != nil {
 			return err
 		}
+
+	case KeyType_ML_DSA_44, KeyType_ML_DSA_65, KeyType_ML_DSA_87, KeyType_ML_KEM_768, KeyType_ML_KEM_1024, KeyType_HYBRID_ED25519_ML_DSA_65:
+		entry.Key, entry.FormattedPublicKey, err = generatePostQuantumKey(p.Type, randReader)
+		if err != nil {
+			return err
+		}
 	}
 
 	if p.ConvergentEncryption {
@@ -1724,6 +1864,9 @@ func (p *Policy) RotateInMemory(randReader io.Reader) (retErr error) {
 		p.MinDecryptionVersion = 1
 	}
 
+	p.initOperationCounts()
+	p.checkOperationLimit()
+
 	return nil
 }
 
@@ -2000,6 +2143,26 @@ func (p *Policy) EncryptWithFactory(ver int, context []byte, nonce []byte, value
 		return "", errutil.UserError{Err: "requested version for encryption is less than the minimum encryption key version"}
 	}
 
+	if err := p.countOperation(ver, false); err != nil {
+		return "", err
+	}
+
+	if p.Type.FormatPreserving() {
+		if len(nonce) > 0 {
+			return "", errutil.UserError{Err: "nonce provided when not allowed"}
+		}
+
+		tweak, err := fpeTweak(factories)
+		if err != nil {
+			return "", err
+		}
+
+		// The ciphertext has no version prefix, so that it has the format of
+		// the plaintext, instead the version group of the template holds the
+		// key version
+		return p.formatPreservingEncrypt(ver, tweak, string(plaintext))
+	}
+
 	var ciphertext []byte
 
 	switch p.Type {
@@ -2129,6 +2292,43 @@ func (p *Policy) EncryptWithFactory(ver int, context []byte, nonce []byte, value
 	return encoded, nil
 }
 
+// Encapsulate generates a new shared secret and encapsulates it to the public
+// key of the given version of a key encapsulation key. It returns the
+// versioned ciphertext, which can be decrypted to recover the shared secret,
+// along with the shared secret itself.
+func (p *Policy) Encapsulate(ver int) (string, []byte, error) {
+	if !p.Type.KeyEncapsulationSupported() {
+		return "", nil, errutil.UserError{Err: fmt.Sprintf("key encapsulation not supported for key type %v", p.Type)}
+	}
+
+	switch {
+	case ver == 0:
+		ver = p.LatestVersion
+	case ver < 0:
+		return "", nil, errutil.UserError{Err: "requested version for encapsulation is negative"}
+	case ver > p.LatestVersion:
+		return "", nil, errutil.UserError{Err: "requested version for encapsulation is higher than the latest key version"}
+	case ver < p.MinEncryptionVersion:
+		return "", nil, errutil.UserError{Err: "requested version for encapsulation is less than the minimum encryption key version"}
+	}
+
+	if err := p.countOperation(ver, false); err != nil {
+		return "", nil, err
+	}
+
+	keyEntry, err := p.safeGetKeyEntry(ver)
+	if err != nil {
+		return "", nil, err
+	}
+
+	ciphertext, sharedSecret, err := encapsulate(p.Type, keyEntry.FormattedPublicKey)
+	if err != nil {
+		return "", nil, errutil.InternalError{Err: fmt.Sprintf("failed to encapsulate shared secret: %v", err)}
+	}
+
+	return p.getVersionPrefix(ver) + base64.StdEncoding.EncodeToString(ciphertext), sharedSecret, nil
+}
+
 func (p *Policy) KeyVersionCanBeUpdated(keyVersion int, isPrivateKey bool) error {
 	keyEntry, err := p.safeGetKeyEntry(keyVersion)
 	if err != nil {
@@ -2325,6 +2525,12 @@ func (ke *KeyEntry) parseFromKey(PolKeyType KeyType, parsedKey any) error {
 }
 
 func (p *Policy) WrapKey(ver int, targetKey interface{}, targetKeyType KeyType, hash hash.Hash) (string, error) {
+	return p.WrapKeyWithLabel(ver, targetKey, targetKeyType, hash, nil)
+}
+
+// WrapKeyWithLabel is like WrapKey, but uses the given label for the OAEP
+// encryption of the ephemeral AES key.
+func (p *Policy) WrapKeyWithLabel(ver int, targetKey interface{}, targetKeyType KeyType, hash hash.Hash, label []byte) (string, error) {
 	if !p.Type.SigningSupported() {
 		return "", fmt.Errorf("message signing not supported for key type %v", p.Type)
 	}
@@ -2345,10 +2551,16 @@ func (p *Policy) WrapKey(ver int, targetKey interface{}, targetKeyType KeyType,
 		return "", err
 	}
 
-	return keyEntry.WrapKey(targetKey, targetKeyType, hash)
+	return keyEntry.WrapKeyWithLabel(targetKey, targetKeyType, hash, label)
 }
 
 func (ke *KeyEntry) WrapKey(targetKey interface{}, targetKeyType KeyType, hash hash.Hash) (string, error) {
+	return ke.WrapKeyWithLabel(targetKey, targetKeyType, hash, nil)
+}
+
+// WrapKeyWithLabel is like WrapKey, but uses the given label for the OAEP
+// encryption of the ephemeral AES key.
+func (ke *KeyEntry) WrapKeyWithLabel(targetKey interface{}, targetKeyType KeyType, hash hash.Hash, label []byte) (string, error) {
 	// Presently this method implements a CKM_RSA_AES_KEY_WRAP-compatible
 	// wrapping interface and only works on RSA keyEntries as a result.
 	if ke.RSAPublicKey == nil {
@@ -2371,7 +2583,7 @@ func (ke *KeyEntry) WrapKey(targetKey interface{}, targetKeyType KeyType, hash h
 		}
 	}
 
-	result, err := wrapTargetPKCS8ForImport(ke.RSAPublicKey, preppedTargetKey, hash)
+	result, err := wrapTargetPKCS8ForImport(ke.RSAPublicKey, preppedTargetKey, hash, label)
 	if err != nil {
 		return result, fmt.Errorf("failed to wrap target key for import: %w", err)
 	}
@@ -2379,7 +2591,7 @@ func (ke *KeyEntry) WrapKey(targetKey interface{}, targetKeyType KeyType, hash h
 	return result, nil
 }
 
-func wrapTargetPKCS8ForImport(wrappingKey *rsa.PublicKey, preppedTargetKey []byte, hash hash.Hash) (string, error) {
+func wrapTargetPKCS8ForImport(wrappingKey *rsa.PublicKey, preppedTargetKey []byte, hash hash.Hash, label []byte) (string, error) {
 	// Generate an ephemeral AES-256 key
 	ephKey, err := uuid.GenerateRandomBytes(32)
 	if err != nil {
@@ -2387,7 +2599,7 @@ func wrapTargetPKCS8ForImport(wrappingKey *rsa.PublicKey, preppedTargetKey []byt
 	}
 
 	// Wrap ephemeral AES key with public wrapping key
-	ephKeyWrapped, err := rsa.EncryptOAEP(hash, rand.Reader, wrappingKey, ephKey, []byte{} /* label */)
+	ephKeyWrapped, err := rsa.EncryptOAEP(hash, rand.Reader, wrappingKey, ephKey, label)
 	if err != nil {
 		return "", fmt.Errorf("failed to encrypt ephemeral wrapping key with public key: %w", err)
 	}
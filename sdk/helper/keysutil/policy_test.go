@@ -6,18 +6,23 @@ package keysutil
 import (
 	"bytes"
 	"context"
+	"crypto/aes"
 	"crypto/ecdsa"
 	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"io"
 	mathrand "math/rand"
 	"reflect"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -1066,3 +1071,809 @@ func isUnsupportedGoHashType(hashType HashType, err error) bool {
 
 	return false
 }
+
+func Test_PostQuantumSignatures(t *testing.T) {
+	ctx := context.Background()
+	storage := &logical.InmemStorage{}
+	input := []byte("Sphinx of black quartz, judge my vow")
+
+	for _, keyType := range []KeyType{KeyType_ML_DSA_44, KeyType_ML_DSA_65, KeyType_ML_DSA_87, KeyType_HYBRID_ED25519_ML_DSA_65} {
+		keyType := keyType
+		t.Run(keyType.String(), func(t *testing.T) {
+			p := NewPolicy(PolicyConfig{
+				Name: keyType.String(),
+				Type: keyType,
+			})
+			if err := p.Rotate(ctx, storage, rand.Reader); err != nil {
+				t.Fatal(err)
+			}
+
+			for marshalingName, marshalingType := range MarshalingTypeMap {
+				sig, err := p.Sign(0, nil, input, HashTypeNone, "", marshalingType)
+				if err != nil {
+					t.Fatalf("%s: failed to sign: %v", marshalingName, err)
+				}
+
+				valid, err := p.VerifySignature(nil, input, HashTypeNone, "", marshalingType, sig.Signature)
+				if err != nil {
+					t.Fatalf("%s: failed to verify: %v", marshalingName, err)
+				}
+				if !valid {
+					t.Fatalf("%s: expected signature to be valid", marshalingName)
+				}
+
+				valid, err = p.VerifySignature(nil, []byte("Sphinx of black quartz, judge my cow"), HashTypeNone, "", marshalingType, sig.Signature)
+				if err != nil {
+					t.Fatalf("%s: failed to verify: %v", marshalingName, err)
+				}
+				if valid {
+					t.Fatalf("%s: expected signature over different input to be invalid", marshalingName)
+				}
+			}
+
+			// Key encapsulation is not supported by signing keys
+			if _, _, err := p.Encapsulate(0); err == nil {
+				t.Fatal("expected encapsulation with a signing key to fail")
+			}
+		})
+	}
+
+	// The Ed25519 component of a hybrid signature must be valid on its own
+	p := NewPolicy(PolicyConfig{
+		Name: "hybrid",
+		Type: KeyType_HYBRID_ED25519_ML_DSA_65,
+	})
+	if err := p.Rotate(ctx, storage, rand.Reader); err != nil {
+		t.Fatal(err)
+	}
+	sig, err := p.Sign(0, nil, input, HashTypeNone, "", MarshalingTypeASN1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(sig.Signature, "vault:v1:"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw[0] ^= 0xff
+	valid, err := p.VerifySignature(nil, input, HashTypeNone, "", MarshalingTypeASN1, "vault:v1:"+base64.StdEncoding.EncodeToString(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if valid {
+		t.Fatal("expected hybrid signature with an invalid Ed25519 component to be invalid")
+	}
+}
+
+func Test_PostQuantumKeyEncapsulation(t *testing.T) {
+	ctx := context.Background()
+	storage := &logical.InmemStorage{}
+
+	for _, keyType := range []KeyType{KeyType_ML_KEM_768, KeyType_ML_KEM_1024} {
+		keyType := keyType
+		t.Run(keyType.String(), func(t *testing.T) {
+			p := NewPolicy(PolicyConfig{
+				Name: keyType.String(),
+				Type: keyType,
+			})
+			if err := p.Rotate(ctx, storage, rand.Reader); err != nil {
+				t.Fatal(err)
+			}
+
+			ciphertext, sharedSecret, err := p.Encapsulate(0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(sharedSecret) != 32 {
+				t.Fatalf("expected a 32 byte shared secret, got %d bytes", len(sharedSecret))
+			}
+
+			plaintext, err := p.Decrypt(nil, nil, ciphertext)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if plaintext != base64.StdEncoding.EncodeToString(sharedSecret) {
+				t.Fatal("decapsulated shared secret does not match the encapsulated shared secret")
+			}
+
+			// Arbitrary plaintext can not be encrypted
+			if _, err := p.Encrypt(0, nil, nil, plaintext); err == nil {
+				t.Fatal("expected encryption with a key encapsulation key to fail")
+			}
+
+			_, err = p.Decrypt(nil, nil, "vault:v1:"+base64.StdEncoding.EncodeToString([]byte("short")))
+			if _, ok := err.(errutil.UserError); !ok {
+				t.Fatalf("expected a user error decrypting an invalid ciphertext, got: %v", err)
+			}
+
+			// A rotated key can still recover secrets encapsulated to the
+			// prior version
+			if err := p.Rotate(ctx, storage, rand.Reader); err != nil {
+				t.Fatal(err)
+			}
+			plaintext, err = p.Decrypt(nil, nil, ciphertext)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if plaintext != base64.StdEncoding.EncodeToString(sharedSecret) {
+				t.Fatal("decapsulated shared secret does not match after rotation")
+			}
+		})
+	}
+}
+
+func Test_StreamEncryption(t *testing.T) {
+	ctx := context.Background()
+	storage := &logical.InmemStorage{}
+
+	encryptStream := func(t *testing.T, p *Policy, ver int, context, plaintext []byte, chunkSize int) []byte {
+		t.Helper()
+		var buf bytes.Buffer
+		e, err := p.NewStreamEncrypter(ver, context, &buf, chunkSize)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := e.Write(plaintext); err != nil {
+			t.Fatal(err)
+		}
+		if err := e.Close(); err != nil {
+			t.Fatal(err)
+		}
+		return buf.Bytes()
+	}
+
+	decryptStream := func(p *Policy, context, ciphertext []byte) ([]byte, error) {
+		d, err := p.NewStreamDecrypter(context, bytes.NewReader(ciphertext))
+		if err != nil {
+			return nil, err
+		}
+		return io.ReadAll(d)
+	}
+
+	for _, keyType := range []KeyType{KeyType_AES128_GCM96, KeyType_AES256_GCM96, KeyType_ChaCha20_Poly1305} {
+		keyType := keyType
+		t.Run(keyType.String(), func(t *testing.T) {
+			p := NewPolicy(PolicyConfig{
+				Name: keyType.String(),
+				Type: keyType,
+			})
+			if err := p.Rotate(ctx, storage, rand.Reader); err != nil {
+				t.Fatal(err)
+			}
+
+			// Exercise empty streams, streams ending on a chunk boundary and
+			// streams ending mid-chunk
+			for _, size := range []int{0, 1, MinStreamChunkSize, 3 * MinStreamChunkSize, 3*MinStreamChunkSize + 17} {
+				plaintext := make([]byte, size)
+				if _, err := rand.Read(plaintext); err != nil {
+					t.Fatal(err)
+				}
+
+				ciphertext := encryptStream(t, p, 0, nil, plaintext, MinStreamChunkSize)
+				decrypted, err := decryptStream(p, nil, ciphertext)
+				if err != nil {
+					t.Fatalf("size %d: %v", size, err)
+				}
+				if !bytes.Equal(plaintext, decrypted) {
+					t.Fatalf("size %d: decrypted plaintext does not match", size)
+				}
+			}
+
+			plaintext := make([]byte, 3*MinStreamChunkSize+17)
+			if _, err := rand.Read(plaintext); err != nil {
+				t.Fatal(err)
+			}
+			ciphertext := encryptStream(t, p, 0, nil, plaintext, MinStreamChunkSize)
+			encChunkSize := MinStreamChunkSize + 16
+
+			// Truncating the stream on a chunk boundary must be detected
+			if _, err := decryptStream(p, nil, ciphertext[:StreamHeaderSize+2*encChunkSize]); err == nil {
+				t.Fatal("expected truncated stream to fail decryption")
+			}
+			if _, err := decryptStream(p, nil, ciphertext[:StreamHeaderSize]); err == nil {
+				t.Fatal("expected stream without chunks to fail decryption")
+			}
+
+			// Tampering with a chunk or the header must be detected
+			tampered := bytes.Clone(ciphertext)
+			tampered[StreamHeaderSize+encChunkSize+5] ^= 0xff
+			if _, err := decryptStream(p, nil, tampered); err == nil {
+				t.Fatal("expected tampered chunk to fail decryption")
+			}
+			tampered = bytes.Clone(ciphertext)
+			tampered[StreamHeaderSize-1] ^= 0xff
+			if _, err := decryptStream(p, nil, tampered); err == nil {
+				t.Fatal("expected tampered header to fail decryption")
+			}
+
+			// Reordering chunks must be detected
+			reordered := bytes.Clone(ciphertext[:StreamHeaderSize])
+			reordered = append(reordered, ciphertext[StreamHeaderSize+encChunkSize:StreamHeaderSize+2*encChunkSize]...)
+			reordered = append(reordered, ciphertext[StreamHeaderSize:StreamHeaderSize+encChunkSize]...)
+			reordered = append(reordered, ciphertext[StreamHeaderSize+2*encChunkSize:]...)
+			if _, err := decryptStream(p, nil, reordered); err == nil {
+				t.Fatal("expected reordered stream to fail decryption")
+			}
+
+			// Streams encrypted with an older version remain decryptable
+			// after rotation, until the minimum decryption version is raised
+			if err := p.Rotate(ctx, storage, rand.Reader); err != nil {
+				t.Fatal(err)
+			}
+			decrypted, err := decryptStream(p, nil, ciphertext)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(plaintext, decrypted) {
+				t.Fatal("decrypted plaintext does not match after rotation")
+			}
+			p.MinDecryptionVersion = 2
+			if _, err := decryptStream(p, nil, ciphertext); err == nil {
+				t.Fatal("expected decryption below the minimum decryption version to fail")
+			}
+		})
+	}
+
+	// Derived keys require the same context to decrypt
+	p := NewPolicy(PolicyConfig{
+		Name:    "derived",
+		Type:    KeyType_AES256_GCM96,
+		Derived: true,
+		KDF:     Kdf_hkdf_sha256,
+	})
+	if err := p.Rotate(ctx, storage, rand.Reader); err != nil {
+		t.Fatal(err)
+	}
+	plaintext := []byte("Sphinx of black quartz, judge my vow")
+	ciphertext := encryptStream(t, p, 0, []byte("context"), plaintext, 0)
+	decrypted, err := decryptStream(p, []byte("context"), ciphertext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(plaintext, decrypted) {
+		t.Fatal("decrypted plaintext does not match")
+	}
+	if _, err := decryptStream(p, []byte("other"), ciphertext); err == nil {
+		t.Fatal("expected decryption with a different context to fail")
+	}
+
+	// Chunk sizes are bounded
+	if _, err := p.NewStreamEncrypter(0, []byte("context"), io.Discard, MinStreamChunkSize-1); err == nil {
+		t.Fatal("expected chunk size below the minimum to be rejected")
+	}
+}
+
+func Test_CMAC(t *testing.T) {
+	// Test vectors from NIST SP 800-38B and RFC 4493
+	message, _ := hex.DecodeString("6bc1bee22e409f96e93d7e117393172aae2d8a571e03ac9c9eb76fac45af8e5130c81c46a35ce411e5fbc1191a0a52eff69f2445df4f9b17ad2b417be66c3710")
+	tests := []struct {
+		key      string
+		length   int
+		expected string
+	}{
+		{"2b7e151628aed2a6abf7158809cf4f3c", 0, "bb1d6929e95937287fa37d129b756746"},
+		{"2b7e151628aed2a6abf7158809cf4f3c", 16, "070a16b46b4d4144f79bdd9dd04a287c"},
+		{"2b7e151628aed2a6abf7158809cf4f3c", 40, "dfa66747de9ae63030ca32611497c827"},
+		{"2b7e151628aed2a6abf7158809cf4f3c", 64, "51f0bebf7e3b9d92fc49741779363cfe"},
+		{"603deb1015ca71be2b73aef0857d77811f352c073b6108d72d9810a30914dff4", 0, "028962f61b7bf89efc6b551f4667d983"},
+		{"603deb1015ca71be2b73aef0857d77811f352c073b6108d72d9810a30914dff4", 16, "28a7023f452e8f82bd4bf28d8c37c35c"},
+	}
+
+	for _, test := range tests {
+		key, _ := hex.DecodeString(test.key)
+		mac, err := CMAC(key, message[:test.length])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if hex.EncodeToString(mac) != test.expected {
+			t.Fatalf("key %s length %d: expected %s, got %x", test.key, test.length, test.expected, mac)
+		}
+	}
+
+	// Only non-derived AES keys can be used
+	ctx := context.Background()
+	storage := &logical.InmemStorage{}
+	p := NewPolicy(PolicyConfig{
+		Name: "cmac",
+		Type: KeyType_ChaCha20_Poly1305,
+	})
+	if err := p.Rotate(ctx, storage, rand.Reader); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.CMACKey(1); err == nil {
+		t.Fatal("expected CMAC with a chacha20-poly1305 key to fail")
+	}
+
+	// The CMAC key is the AES key itself
+	p = NewPolicy(PolicyConfig{
+		Name: "cmac",
+		Type: KeyType_AES256_GCM96,
+	})
+	if err := p.Rotate(ctx, storage, rand.Reader); err != nil {
+		t.Fatal(err)
+	}
+	key, err := p.CMACKey(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(key, p.Keys["1"].Key) {
+		t.Fatal("expected the CMAC key to be the encryption key")
+	}
+}
+
+func Test_KMAC(t *testing.T) {
+	// Test vectors from the NIST SP 800-185 KMAC samples
+	key, _ := hex.DecodeString("404142434445464748494a4b4c4d4e4f505152535455565758595a5b5c5d5e5f")
+	input, _ := hex.DecodeString("00010203")
+	tests := []struct {
+		bits          int
+		customization string
+		length        int
+		expected      string
+	}{
+		{128, "", 32, "e5780b0d3ea6f7d3a429c5706aa43a00fadbd7d49628839e3187243f456ee14e"},
+		{128, "My Tagged Application", 32, "3b1fba963cd8b0b59e8c1a6d71888b7143651af8ba0a7070c0979e2811324aa5"},
+		{256, "My Tagged Application", 64, "20c570c31346f703c9ac36c61c03cb64c3970d0cfc787e9b79599d273a68d2f7f69d4cc3de9d104a351689f27cf6f5951f0103f33f4f24871024d9c27773a8dd"},
+	}
+
+	for _, test := range tests {
+		mac, err := KMAC(test.bits, key, input, []byte(test.customization), test.length)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if hex.EncodeToString(mac) != test.expected {
+			t.Fatalf("KMAC%d %q: expected %s, got %x", test.bits, test.customization, test.expected, mac)
+		}
+	}
+
+	if _, err := KMAC(256, key, input, nil, KMACMaxLength+1); err == nil {
+		t.Fatal("expected KMAC length above the maximum to be rejected")
+	}
+}
+
+func Test_FormatPreservingEncryption_Vectors(t *testing.T) {
+	numerals := func(s, alphabet string) []int {
+		var x []int
+		for _, r := range s {
+			x = append(x, strings.IndexRune(alphabet, r))
+		}
+		return x
+	}
+	decimal := "0123456789"
+	base36 := "0123456789abcdefghijklmnopqrstuvwxyz"
+
+	// Test vectors from the NIST SP 800-38G FF1 and FF3 samples. FF3-1 only
+	// differs from FF3 in the derivation of the tweak halves, so the FF3
+	// samples with an all-zero tweak also apply to FF3-1.
+	tests := []struct {
+		name       string
+		ff3        bool
+		key        string
+		tweak      string
+		alphabet   string
+		plaintext  string
+		ciphertext string
+	}{
+		{"ff1 sample 1", false, "2b7e151628aed2a6abf7158809cf4f3c", "", decimal, "0123456789", "2433477484"},
+		{"ff1 sample 2", false, "2b7e151628aed2a6abf7158809cf4f3c", "39383736353433323130", decimal, "0123456789", "6124200773"},
+		{"ff1 sample 3", false, "2b7e151628aed2a6abf7158809cf4f3c", "3737373770717273373737", base36, "0123456789abcdefghi", "a9tv40mll9kdu509eum"},
+		{"ff1 sample 7", false, "2b7e151628aed2a6abf7158809cf4f3cef4359d8d580aa4f7f036d6f04fc6a94", "", decimal, "0123456789", "6657667009"},
+		{"ff1 sample 8", false, "2b7e151628aed2a6abf7158809cf4f3cef4359d8d580aa4f7f036d6f04fc6a94", "39383736353433323130", decimal, "0123456789", "1001623463"},
+		{"ff1 sample 9", false, "2b7e151628aed2a6abf7158809cf4f3cef4359d8d580aa4f7f036d6f04fc6a94", "3737373770717273373737", base36, "0123456789abcdefghi", "xs8a0azh2avyalyzuwd"},
+		{"ff3 sample 4", true, "ef4359d8d580aa4f7f036d6f04fc6a94", "00000000000000", decimal, "89012123456789000000789000000", "34695224821734535122613701434"},
+	}
+
+	for _, test := range tests {
+		key, _ := hex.DecodeString(test.key)
+		tweak, _ := hex.DecodeString(test.tweak)
+		radix := len(test.alphabet)
+
+		transform := func(x []int, decrypt bool) []int {
+			if test.ff3 {
+				reversed := append([]byte(nil), key...)
+				reverseBytes(reversed)
+				block, err := aes.NewCipher(reversed)
+				if err != nil {
+					t.Fatal(err)
+				}
+				return ff3_1(block, tweak, radix, x, decrypt)
+			}
+
+			block, err := aes.NewCipher(key)
+			if err != nil {
+				t.Fatal(err)
+			}
+			return ff1(block, tweak, radix, x, decrypt)
+		}
+
+		plaintext := numerals(test.plaintext, test.alphabet)
+		ciphertext := transform(plaintext, false)
+		if !reflect.DeepEqual(ciphertext, numerals(test.ciphertext, test.alphabet)) {
+			t.Fatalf("%s: expected %v, got %v", test.name, numerals(test.ciphertext, test.alphabet), ciphertext)
+		}
+		if decrypted := transform(ciphertext, true); !reflect.DeepEqual(decrypted, plaintext) {
+			t.Fatalf("%s: expected %v, got %v", test.name, plaintext, decrypted)
+		}
+	}
+}
+
+type testAssociatedData []byte
+
+func (a testAssociatedData) GetAssociatedData() ([]byte, error) {
+	return a, nil
+}
+
+func Test_FormatPreservingEncryption(t *testing.T) {
+	ctx := context.Background()
+	storage := &logical.InmemStorage{}
+
+	for _, keyType := range []KeyType{KeyType_FF1_AES256, KeyType_FF3_1_AES256} {
+		keyType := keyType
+		t.Run(keyType.String(), func(t *testing.T) {
+			p := NewPolicy(PolicyConfig{
+				Name: keyType.String(),
+				Type: keyType,
+			})
+			p.Alphabet = "0123456789"
+			p.Template = `(?P<version>\d{2})(\d{4})-(\d{4})-(\d{4})-(\d{4})`
+			if err := p.Rotate(ctx, storage, rand.Reader); err != nil {
+				t.Fatal(err)
+			}
+
+			value := "4111-1111-1111-1111"
+			plaintext := base64.StdEncoding.EncodeToString([]byte(value))
+
+			// The ciphertext has the format of the plaintext, following the
+			// key version
+			ciphertext, err := p.Encrypt(0, nil, nil, plaintext)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(ciphertext) != len("01")+len(value) || !strings.HasPrefix(ciphertext, "01") || ciphertext[6] != '-' || ciphertext[2:] == value {
+				t.Fatalf("unexpected ciphertext %q", ciphertext)
+			}
+
+			// Encryption is deterministic
+			again, err := p.Encrypt(0, nil, nil, plaintext)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if again != ciphertext {
+				t.Fatalf("expected %q, got %q", ciphertext, again)
+			}
+
+			decrypted, err := p.Decrypt(nil, nil, ciphertext)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if decrypted != plaintext {
+				t.Fatalf("expected %q, got %q", plaintext, decrypted)
+			}
+
+			// The tweak changes the ciphertext
+			tweak := testAssociatedData("tweak12")
+			tweaked, err := p.EncryptWithFactory(0, nil, nil, plaintext, tweak)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tweaked == ciphertext {
+				t.Fatal("expected the tweak to change the ciphertext")
+			}
+			decrypted, err = p.DecryptFormatPreserving(tweaked, tweak)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if decrypted != plaintext {
+				t.Fatalf("expected %q, got %q", plaintext, decrypted)
+			}
+
+			// Each key version encrypts differently, and is held in the
+			// ciphertext
+			if err := p.Rotate(ctx, storage, rand.Reader); err != nil {
+				t.Fatal(err)
+			}
+			rotated, err := p.Encrypt(0, nil, nil, plaintext)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.HasPrefix(rotated, "02") || rotated[2:] == ciphertext[2:] {
+				t.Fatalf("unexpected ciphertext %q", rotated)
+			}
+			for _, c := range []string{ciphertext, rotated} {
+				decrypted, err = p.Decrypt(nil, nil, c)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if decrypted != plaintext {
+					t.Fatalf("expected %q, got %q", plaintext, decrypted)
+				}
+			}
+			for _, invalid := range []string{"00" + ciphertext[2:], "03" + ciphertext[2:], ciphertext[2:]} {
+				if _, err := p.Decrypt(nil, nil, invalid); err == nil {
+					t.Fatalf("expected decryption of %q to fail", invalid)
+				}
+			}
+			p.MinDecryptionVersion = 2
+			if _, err := p.Decrypt(nil, nil, ciphertext); err == nil {
+				t.Fatal("expected decryption with a version which is too old to fail")
+			}
+			p.MinDecryptionVersion = 0
+
+			for _, invalid := range []string{"4111-1111-1111", "4111-1111-1111-111a", "12345"} {
+				if _, err := p.Encrypt(0, nil, nil, base64.StdEncoding.EncodeToString([]byte(invalid))); err == nil {
+					t.Fatalf("expected %q to be rejected", invalid)
+				}
+			}
+		})
+	}
+
+	// Without a template, the whole value is encrypted and the key version
+	// is held by the first characters of the ciphertext
+	p := NewPolicy(PolicyConfig{
+		Name: "no-template",
+		Type: KeyType_FF1_AES256,
+	})
+	p.Alphabet = "0123456789"
+	if err := p.Rotate(ctx, storage, rand.Reader); err != nil {
+		t.Fatal(err)
+	}
+	plaintext := base64.StdEncoding.EncodeToString([]byte("123456"))
+	ciphertext, err := p.Encrypt(0, nil, nil, plaintext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ciphertext) != FPEDefaultVersionLength+6 || !strings.HasPrefix(ciphertext, "01") {
+		t.Fatalf("unexpected ciphertext %q", ciphertext)
+	}
+	decrypted, err := p.Decrypt(nil, nil, ciphertext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decrypted != plaintext {
+		t.Fatalf("expected %q, got %q", plaintext, decrypted)
+	}
+
+	// The template is only compiled once
+	first, err := p.getFPETemplate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := p.getFPETemplate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first != second {
+		t.Fatal("expected the compiled template to be cached")
+	}
+
+	// The key version must fit in the version group
+	for i := 0; i < 99; i++ {
+		if err := p.Rotate(ctx, storage, rand.Reader); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := p.Encrypt(0, nil, nil, plaintext); err == nil {
+		t.Fatal("expected encryption with a key version which doesn't fit in the version group to fail")
+	}
+
+	if _, err := ParseFPEAlphabet("aab"); err == nil {
+		t.Fatal("expected alphabet with duplicate characters to be rejected")
+	}
+
+	// Templates need a fixed length version group and another capture group,
+	// which may only match characters of the alphabet and must not be nested
+	for template, valid := range map[string]bool{
+		`(?P<version>\d)(\d{4})-(\d{4})`:        true,
+		`\w+-([0-3][0-9]{5})(?P<version>\d{2})`: true,
+		`(\d{4})-(\d{4})`:                       false,
+		`(?P<version>\d+)(\d{6})`:               false,
+		`(?P<version>\d{2})`:                    false,
+		`\d+`:                                   false,
+		`(?P<version>\d)((\d{6}))`:              false,
+		`(?P<version>\d)([0-9a-f]{6})`:          false,
+		`(?P<version>\d)([a-z]{6})`:             false,
+		`(?P<version>\d)(\w{6})`:                false,
+		`(?P<version>\d)(.{6})`:                 false,
+		`(?P<version>\d)(?i)(1a{6})`:            false,
+		`(?P<version>[a-z])(\d{6})`:             false,
+	} {
+		err := ValidateFPETemplate(template, "0123456789")
+		if valid && err != nil {
+			t.Fatalf("expected template %q to be accepted: %v", template, err)
+		}
+		if !valid && err == nil {
+			t.Fatalf("expected template %q to be rejected", template)
+		}
+	}
+}
+
+func Test_OperationLimits(t *testing.T) {
+	ctx := context.Background()
+	storage := &logical.InmemStorage{}
+
+	p := NewPolicy(PolicyConfig{
+		Name: "test",
+		Type: KeyType_AES256_GCM96,
+	})
+	if err := p.Rotate(ctx, storage, rand.Reader); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.SetOperationLimit(2, OperationLimitActionRefuse); err != nil {
+		t.Fatal(err)
+	}
+
+	// Operations are only served once they have been reserved
+	plaintext := base64.StdEncoding.EncodeToString([]byte("test"))
+	if p.OperationsReserved(1) {
+		t.Fatal("expected no operations to be reserved")
+	}
+	if _, err := p.Encrypt(0, nil, nil, plaintext); err == nil {
+		t.Fatal("expected encryption without a reservation to be refused")
+	}
+	if err := p.ReserveOperations(ctx, storage, 10); err != nil {
+		t.Fatal(err)
+	}
+	if !p.OperationsReserved(2) || p.OperationCounts["1"].Reserved != 2 {
+		t.Fatalf("expected the reservation to be capped at the limit, got %d", p.OperationCounts["1"].Reserved)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := p.Encrypt(0, nil, nil, plaintext); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := p.Encrypt(0, nil, nil, plaintext); err == nil {
+		t.Fatal("expected encryption past the operation limit to be refused")
+	}
+	if p.RotationRequired() {
+		t.Fatal("rotation should not be required when refusing operations")
+	}
+	if !p.HasPendingOperationCounts() {
+		t.Fatal("expected pending operation counts")
+	}
+
+	// Counts are folded into the persisted counts and loaded back
+	if err := p.Persist(ctx, storage); err != nil {
+		t.Fatal(err)
+	}
+	if p.HasPendingOperationCounts() {
+		t.Fatal("expected no pending operation counts after persisting")
+	}
+	loaded, err := LoadPolicy(ctx, storage, "policy/test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count := loaded.GetOperationCounts()[1]; count.Encryptions != 2 || count.Signatures != 0 {
+		t.Fatalf("unexpected operation count %#v", count)
+	}
+
+	// Switching to rotation requires the key to be rotated, after which the
+	// previous version is still refused
+	if err := p.SetOperationLimit(2, OperationLimitActionRotate); err != nil {
+		t.Fatal(err)
+	}
+	if !p.RotationRequired() {
+		t.Fatal("expected rotation to be required")
+	}
+	if err := p.Rotate(ctx, storage, rand.Reader); err != nil {
+		t.Fatal(err)
+	}
+	if p.RotationRequired() {
+		t.Fatal("rotation should not be required after rotating")
+	}
+	if err := p.ReserveOperations(ctx, storage, 2); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.Encrypt(1, nil, nil, plaintext); err == nil {
+		t.Fatal("expected encryption with a version past the operation limit to be refused")
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := p.Encrypt(0, nil, nil, plaintext); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !p.RotationRequired() {
+		t.Fatal("expected rotation to be required")
+	}
+
+	// Counts of another instance are merged into the policy
+	other, err := LoadPolicy(ctx, storage, "policy/test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	other.MergeOperationCounts(p)
+	if count := other.GetOperationCounts()[2]; count.Encryptions != 2 {
+		t.Fatalf("unexpected operation count %#v", count)
+	}
+	if p.HasPendingOperationCounts() {
+		t.Fatal("expected no pending operation counts after merging")
+	}
+	if !other.RotationRequired() {
+		t.Fatal("expected rotation to be required")
+	}
+
+	if err := p.SetOperationLimit(2, "unknown"); err == nil {
+		t.Fatal("expected unknown operation limit action to be rejected")
+	}
+}
+
+func Test_OperationLimits_Reservations(t *testing.T) {
+	ctx := context.Background()
+	storage := &logical.InmemStorage{}
+
+	p := NewPolicy(PolicyConfig{
+		Name: "test",
+		Type: KeyType_AES256_GCM96,
+	})
+	if err := p.Rotate(ctx, storage, rand.Reader); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.SetOperationLimit(100, OperationLimitActionRefuse); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.ReserveOperations(ctx, storage, 50); err != nil {
+		t.Fatal(err)
+	}
+
+	// Concurrent operations never exceed the reservation
+	plaintext := base64.StdEncoding.EncodeToString([]byte("test"))
+	var wg sync.WaitGroup
+	var served uint64
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				if _, err := p.Encrypt(0, nil, nil, plaintext); err == nil {
+					atomic.AddUint64(&served, 1)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if served != 50 {
+		t.Fatalf("expected 50 operations to be served, got %d", served)
+	}
+
+	// Operations reserved but not persisted count towards the limit once
+	// the policy is loaded again
+	if err := p.ReserveOperations(ctx, storage, 30); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.Encrypt(0, nil, nil, plaintext); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadPolicy(ctx, storage, "policy/test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count := loaded.GetOperationCounts()[1]; count.Encryptions != 50 {
+		t.Fatalf("unexpected operation count %#v", count)
+	}
+	if loaded.OperationsReserved(1) {
+		t.Fatal("expected the reservation of the previous instance to be used up")
+	}
+	if err := loaded.ReserveOperations(ctx, storage, 100); err != nil {
+		t.Fatal(err)
+	}
+	if reserved := loaded.OperationCounts["1"].Reserved; reserved != 100 {
+		t.Fatalf("expected the reservation to be capped at the limit, got %d", reserved)
+	}
+	for i := 0; i < 20; i++ {
+		if _, err := loaded.Encrypt(0, nil, nil, plaintext); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := loaded.Encrypt(0, nil, nil, plaintext); err == nil {
+		t.Fatal("expected encryption past the operation limit to be refused")
+	}
+
+	// Keys without a limit do not need reservations
+	if err := loaded.SetOperationLimit(0, ""); err != nil {
+		t.Fatal(err)
+	}
+	if !loaded.OperationsReserved(1000) {
+		t.Fatal("expected operations to need no reservation without a limit")
+	}
+	if _, err := loaded.Encrypt(0, nil, nil, plaintext); err != nil {
+		t.Fatal(err)
+	}
+}
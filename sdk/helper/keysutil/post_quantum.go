@@ -0,0 +1,202 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package keysutil
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/cloudflare/circl/kem"
+	"github.com/cloudflare/circl/kem/mlkem/mlkem1024"
+	"github.com/cloudflare/circl/kem/mlkem/mlkem768"
+	"github.com/cloudflare/circl/sign"
+	"github.com/cloudflare/circl/sign/mldsa/mldsa44"
+	"github.com/cloudflare/circl/sign/mldsa/mldsa65"
+	"github.com/cloudflare/circl/sign/mldsa/mldsa87"
+	"github.com/hashicorp/go-uuid"
+	"golang.org/x/crypto/ed25519"
+)
+
+// mlDSAScheme returns the ML-DSA (FIPS 204) parameter set used by the key
+// type, or nil if the key type does not use ML-DSA. Hybrid key types return
+// the parameter set of their ML-DSA component.
+func (kt KeyType) mlDSAScheme() sign.Scheme {
+	switch kt {
+	case KeyType_ML_DSA_44:
+		return mldsa44.Scheme()
+	case KeyType_ML_DSA_65, KeyType_HYBRID_ED25519_ML_DSA_65:
+		return mldsa65.Scheme()
+	case KeyType_ML_DSA_87:
+		return mldsa87.Scheme()
+	}
+	return nil
+}
+
+// mlKEMScheme returns the ML-KEM (FIPS 203) parameter set used by the key type,
+// or nil if the key type does not use ML-KEM.
+func (kt KeyType) mlKEMScheme() kem.Scheme {
+	switch kt {
+	case KeyType_ML_KEM_768:
+		return mlkem768.Scheme()
+	case KeyType_ML_KEM_1024:
+		return mlkem1024.Scheme()
+	}
+	return nil
+}
+
+// generatePostQuantumKey generates the key material for a post-quantum or
+// hybrid key type. The private key is stored as the seed it is derived from
+// (prefixed by the Ed25519 private key for hybrid key types), and the public
+// key is returned base64 encoded in the same layout.
+func generatePostQuantumKey(kt KeyType, randReader io.Reader) ([]byte, string, error) {
+	var key, pub []byte
+
+	if kt == KeyType_HYBRID_ED25519_ML_DSA_65 {
+		edPub, edKey, err := ed25519.GenerateKey(randReader)
+		if err != nil {
+			return nil, "", err
+		}
+		key = append(key, edKey...)
+		pub = append(pub, edPub...)
+	}
+
+	switch {
+	case kt.mlDSAScheme() != nil:
+		scheme := kt.mlDSAScheme()
+		seed, err := uuid.GenerateRandomBytesWithReader(scheme.SeedSize(), randReader)
+		if err != nil {
+			return nil, "", err
+		}
+		pk, _ := scheme.DeriveKey(seed)
+		pkBytes, err := pk.MarshalBinary()
+		if err != nil {
+			return nil, "", fmt.Errorf("error marshaling public key: %w", err)
+		}
+		key = append(key, seed...)
+		pub = append(pub, pkBytes...)
+
+	case kt.mlKEMScheme() != nil:
+		scheme := kt.mlKEMScheme()
+		seed, err := uuid.GenerateRandomBytesWithReader(scheme.SeedSize(), randReader)
+		if err != nil {
+			return nil, "", err
+		}
+		pk, _ := scheme.DeriveKeyPair(seed)
+		pkBytes, err := pk.MarshalBinary()
+		if err != nil {
+			return nil, "", fmt.Errorf("error marshaling public key: %w", err)
+		}
+		key = append(key, seed...)
+		pub = append(pub, pkBytes...)
+
+	default:
+		return nil, "", fmt.Errorf("unsupported post-quantum key type %v", kt)
+	}
+
+	return key, base64.StdEncoding.EncodeToString(pub), nil
+}
+
+// signPostQuantum signs the input with an ML-DSA or hybrid key. Hybrid
+// signatures are the Ed25519 signature followed by the ML-DSA signature.
+func signPostQuantum(kt KeyType, key, input []byte) ([]byte, error) {
+	scheme := kt.mlDSAScheme()
+	if scheme == nil {
+		return nil, fmt.Errorf("unsupported post-quantum key type %v", kt)
+	}
+
+	var sig []byte
+	if kt == KeyType_HYBRID_ED25519_ML_DSA_65 {
+		if len(key) != ed25519.PrivateKeySize+scheme.SeedSize() {
+			return nil, errors.New("invalid hybrid private key")
+		}
+		sig = append(sig, ed25519.Sign(ed25519.PrivateKey(key[:ed25519.PrivateKeySize]), input)...)
+		key = key[ed25519.PrivateKeySize:]
+	}
+
+	if len(key) != scheme.SeedSize() {
+		return nil, errors.New("invalid ML-DSA private key")
+	}
+	_, sk := scheme.DeriveKey(key)
+
+	return append(sig, scheme.Sign(sk, input, nil)...), nil
+}
+
+// verifyPostQuantum verifies a signature made by signPostQuantum against the
+// base64 encoded public key. Hybrid signatures are only valid when both the
+// Ed25519 and ML-DSA signatures are valid.
+func verifyPostQuantum(kt KeyType, formattedPublicKey string, input, sig []byte) (bool, error) {
+	scheme := kt.mlDSAScheme()
+	if scheme == nil {
+		return false, fmt.Errorf("unsupported post-quantum key type %v", kt)
+	}
+
+	pub, err := base64.StdEncoding.DecodeString(formattedPublicKey)
+	if err != nil {
+		return false, err
+	}
+
+	if kt == KeyType_HYBRID_ED25519_ML_DSA_65 {
+		if len(pub) != ed25519.PublicKeySize+scheme.PublicKeySize() {
+			return false, errors.New("invalid hybrid public key")
+		}
+		if len(sig) != ed25519.SignatureSize+scheme.SignatureSize() {
+			return false, nil
+		}
+		if !ed25519.Verify(ed25519.PublicKey(pub[:ed25519.PublicKeySize]), input, sig[:ed25519.SignatureSize]) {
+			return false, nil
+		}
+		pub = pub[ed25519.PublicKeySize:]
+		sig = sig[ed25519.SignatureSize:]
+	}
+
+	pk, err := scheme.UnmarshalBinaryPublicKey(pub)
+	if err != nil {
+		return false, fmt.Errorf("invalid ML-DSA public key: %w", err)
+	}
+
+	return scheme.Verify(pk, input, sig, nil), nil
+}
+
+// encapsulate generates a new shared secret and encapsulates it to the
+// base64 encoded ML-KEM public key.
+func encapsulate(kt KeyType, formattedPublicKey string) ([]byte, []byte, error) {
+	scheme := kt.mlKEMScheme()
+	if scheme == nil {
+		return nil, nil, fmt.Errorf("unsupported key encapsulation key type %v", kt)
+	}
+
+	pub, err := base64.StdEncoding.DecodeString(formattedPublicKey)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	pk, err := scheme.UnmarshalBinaryPublicKey(pub)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid ML-KEM public key: %w", err)
+	}
+
+	return scheme.Encapsulate(pk)
+}
+
+// decapsulate recovers the shared secret from a ciphertext made by
+// encapsulate, using the ML-KEM seed stored as the private key.
+func decapsulate(kt KeyType, key, ciphertext []byte) ([]byte, error) {
+	scheme := kt.mlKEMScheme()
+	if scheme == nil {
+		return nil, fmt.Errorf("unsupported key encapsulation key type %v", kt)
+	}
+
+	if len(key) != scheme.SeedSize() {
+		return nil, errors.New("invalid ML-KEM private key")
+	}
+	if len(ciphertext) != scheme.CiphertextSize() {
+		return nil, errors.New("invalid ciphertext length")
+	}
+
+	_, sk := scheme.DeriveKeyPair(key)
+
+	return scheme.Decapsulate(sk, ciphertext)
+}
@@ -0,0 +1,399 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package keysutil
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/hashicorp/vault/sdk/helper/errutil"
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/hkdf"
+)
+
+// Streams are encrypted with the STREAM construction (Hoang, Reyhanitabar,
+// Rogaway and Vizár, "Online Authenticated-Encryption and its Nonce-Reuse
+// Misuse-Resistance"). A stream is a header followed by a sequence of
+// chunks, each of which is sealed separately with the key's AEAD. The nonce of
+// each chunk is made up of a zero prefix, the chunk counter and a flag marking
+// the last chunk, which prevents chunks from being reordered, dropped or the
+// stream from being truncated.
+//
+// The header is laid out as follows, with integers in big-endian order:
+//
+//	magic       [4]byte "VTSE"
+//	format      uint8   format version, currently 1
+//	key version uint32  version of the named key used for the stream
+//	chunk size  uint32  size of the plaintext of each chunk but the last
+//	salt        [32]byte
+//
+// Each stream is sealed with a unique key, derived with HKDF-SHA256 from the
+// key version and the random salt, so nonces never repeat across streams. The
+// header is passed as additional data to every chunk.
+const (
+	// DefaultStreamChunkSize is the size of the plaintext of each chunk
+	// when no chunk size is specified.
+	DefaultStreamChunkSize = 64 * 1024
+
+	// MinStreamChunkSize and MaxStreamChunkSize bound the size of the
+	// plaintext of each chunk.
+	MinStreamChunkSize = 1024
+	MaxStreamChunkSize = 16 * 1024 * 1024
+
+	// StreamHeaderSize is the size of the header preceding the chunks of an
+	// encrypted stream.
+	StreamHeaderSize = 4 + 1 + 4 + 4 + streamSaltSize
+
+	streamFormatVersion = 1
+	streamSaltSize      = 32
+	streamNonceSize     = 12
+	streamKDFInfo       = "vault transit stream"
+)
+
+var streamMagic = []byte("VTSE")
+
+// streamHeader is the decoded header of an encrypted stream.
+type streamHeader struct {
+	keyVersion int
+	chunkSize  int
+	salt       []byte
+}
+
+func (h *streamHeader) marshal() []byte {
+	buf := make([]byte, 0, StreamHeaderSize)
+	buf = append(buf, streamMagic...)
+	buf = append(buf, streamFormatVersion)
+	buf = binary.BigEndian.AppendUint32(buf, uint32(h.keyVersion))
+	buf = binary.BigEndian.AppendUint32(buf, uint32(h.chunkSize))
+	return append(buf, h.salt...)
+}
+
+func parseStreamHeader(buf []byte) (*streamHeader, error) {
+	if len(buf) != StreamHeaderSize || !bytes.Equal(buf[:4], streamMagic) {
+		return nil, errors.New("invalid stream header")
+	}
+	if buf[4] != streamFormatVersion {
+		return nil, fmt.Errorf("unsupported stream format version %d", buf[4])
+	}
+
+	h := &streamHeader{
+		keyVersion: int(binary.BigEndian.Uint32(buf[5:9])),
+		chunkSize:  int(binary.BigEndian.Uint32(buf[9:13])),
+		salt:       buf[13:],
+	}
+	if h.chunkSize < MinStreamChunkSize || h.chunkSize > MaxStreamChunkSize {
+		return nil, fmt.Errorf("invalid stream chunk size %d", h.chunkSize)
+	}
+
+	return h, nil
+}
+
+// streamAEAD returns the AEAD used to seal the chunks of the stream described
+// by the header, with a key unique to the stream.
+func (p *Policy) streamAEAD(context []byte, h *streamHeader) (cipher.AEAD, error) {
+	if p.ConvergentEncryption {
+		return nil, errutil.UserError{Err: "streaming encryption is not supported for keys with convergent encryption enabled"}
+	}
+
+	numBytes := 32
+	switch p.Type {
+	case KeyType_AES128_GCM96:
+		numBytes = 16
+	case KeyType_AES256_GCM96, KeyType_ChaCha20_Poly1305:
+	default:
+		return nil, errutil.UserError{Err: fmt.Sprintf("streaming encryption not supported for key type %v", p.Type)}
+	}
+
+	key, err := p.GetKey(context, h.keyVersion, numBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	streamKey := make([]byte, numBytes)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, key, h.salt, []byte(streamKDFInfo)), streamKey); err != nil {
+		return nil, errutil.InternalError{Err: fmt.Sprintf("failed to derive stream key: %v", err)}
+	}
+
+	if p.Type == KeyType_ChaCha20_Poly1305 {
+		return chacha20poly1305.New(streamKey)
+	}
+
+	block, err := aes.NewCipher(streamKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// streamNonce returns the nonce of the chunk with the given counter.
+func streamNonce(counter uint32, last bool) []byte {
+	nonce := make([]byte, streamNonceSize)
+	binary.BigEndian.PutUint32(nonce[7:11], counter)
+	if last {
+		nonce[11] = 1
+	}
+	return nonce
+}
+
+// StreamEncrypter encrypts the plaintext written to it as a stream of chunks,
+// writing the ciphertext to the underlying writer. Close must be called to
+// seal the last chunk; the ciphertext is not valid until it has been.
+type StreamEncrypter struct {
+	w         io.Writer
+	aead      cipher.AEAD
+	header    []byte
+	chunkSize int
+	buf       []byte
+	counter   uint32
+	closed    bool
+	err       error
+}
+
+// NewStreamEncrypter returns a StreamEncrypter writing a stream encrypted with
+// the given version of the key to w. A version of 0 uses the latest version,
+// and a chunk size of 0 uses DefaultStreamChunkSize. The stream header is
+// written to w immediately.
+func (p *Policy) NewStreamEncrypter(ver int, context []byte, w io.Writer, chunkSize int) (*StreamEncrypter, error) {
+	if !p.Type.EncryptionSupported() {
+		return nil, errutil.UserError{Err: fmt.Sprintf("message encryption not supported for key type %v", p.Type)}
+	}
+
+	switch {
+	case ver == 0:
+		ver = p.LatestVersion
+	case ver < 0:
+		return nil, errutil.UserError{Err: "requested version for encryption is negative"}
+	case ver > p.LatestVersion:
+		return nil, errutil.UserError{Err: "requested version for encryption is higher than the latest key version"}
+	case ver < p.MinEncryptionVersion:
+		return nil, errutil.UserError{Err: "requested version for encryption is less than the minimum encryption key version"}
+	}
+
+	if err := p.countOperation(ver, false); err != nil {
+		return nil, err
+	}
+
+	if chunkSize == 0 {
+		chunkSize = DefaultStreamChunkSize
+	}
+	if chunkSize < MinStreamChunkSize || chunkSize > MaxStreamChunkSize {
+		return nil, errutil.UserError{Err: fmt.Sprintf("chunk size must be between %d and %d bytes", MinStreamChunkSize, MaxStreamChunkSize)}
+	}
+
+	salt := make([]byte, streamSaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, errutil.InternalError{Err: fmt.Sprintf("failed to generate stream salt: %v", err)}
+	}
+
+	h := &streamHeader{
+		keyVersion: ver,
+		chunkSize:  chunkSize,
+		salt:       salt,
+	}
+	aead, err := p.streamAEAD(context, h)
+	if err != nil {
+		return nil, err
+	}
+
+	header := h.marshal()
+	if _, err := w.Write(header); err != nil {
+		return nil, err
+	}
+
+	return &StreamEncrypter{
+		w:         w,
+		aead:      aead,
+		header:    header,
+		chunkSize: chunkSize,
+		buf:       make([]byte, 0, chunkSize),
+	}, nil
+}
+
+// KeyVersion returns the version of the key the stream is encrypted with.
+func (e *StreamEncrypter) KeyVersion() int {
+	return int(binary.BigEndian.Uint32(e.header[5:9]))
+}
+
+// Write encrypts the plaintext. Chunks are only written to the underlying
+// writer once they are complete and more plaintext follows them.
+func (e *StreamEncrypter) Write(p []byte) (int, error) {
+	if e.err != nil {
+		return 0, e.err
+	}
+	if e.closed {
+		return 0, errors.New("write to closed stream")
+	}
+
+	n := len(p)
+	for len(p) > 0 {
+		// Only seal a full chunk once more plaintext is available, as the
+		// last chunk must be flagged as such.
+		if len(e.buf) == e.chunkSize {
+			if e.err = e.seal(false); e.err != nil {
+				return 0, e.err
+			}
+		}
+
+		c := copy(e.buf[len(e.buf):e.chunkSize], p)
+		e.buf = e.buf[:len(e.buf)+c]
+		p = p[c:]
+	}
+
+	return n, nil
+}
+
+// Close seals and writes the last chunk of the stream. It does not close the
+// underlying writer.
+func (e *StreamEncrypter) Close() error {
+	if e.err != nil {
+		return e.err
+	}
+	if e.closed {
+		return nil
+	}
+
+	e.closed = true
+	e.err = e.seal(true)
+	return e.err
+}
+
+func (e *StreamEncrypter) seal(last bool) error {
+	if e.counter == math.MaxUint32 {
+		return errutil.UserError{Err: "stream is too long for the chunk size"}
+	}
+
+	ciphertext := e.aead.Seal(nil, streamNonce(e.counter, last), e.buf, e.header)
+	if _, err := e.w.Write(ciphertext); err != nil {
+		return err
+	}
+
+	e.counter++
+	e.buf = e.buf[:0]
+	return nil
+}
+
+// StreamDecrypter decrypts a stream written by a StreamEncrypter. Plaintext is
+// only returned once the chunk containing it has been authenticated; a stream
+// which has been tampered with or truncated results in an error, though the
+// plaintext of the chunks preceding the error will already have been read.
+type StreamDecrypter struct {
+	r          *bufio.Reader
+	aead       cipher.AEAD
+	header     []byte
+	keyVersion int
+	chunk      []byte
+	plaintext  []byte
+	counter    uint32
+	done       bool
+	err        error
+}
+
+// NewStreamDecrypter reads the stream header from r, and returns a
+// StreamDecrypter for the remainder of the stream.
+func (p *Policy) NewStreamDecrypter(context []byte, r io.Reader) (*StreamDecrypter, error) {
+	if !p.Type.DecryptionSupported() {
+		return nil, errutil.UserError{Err: fmt.Sprintf("message decryption not supported for key type %v", p.Type)}
+	}
+
+	header := make([]byte, StreamHeaderSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, errutil.UserError{Err: "invalid ciphertext: stream header is truncated"}
+		}
+		return nil, err
+	}
+
+	h, err := parseStreamHeader(header)
+	if err != nil {
+		return nil, errutil.UserError{Err: fmt.Sprintf("invalid ciphertext: %v", err)}
+	}
+
+	if h.keyVersion == 0 || h.keyVersion > p.LatestVersion {
+		return nil, errutil.UserError{Err: "invalid ciphertext: version is too new"}
+	}
+	if p.MinDecryptionVersion > 0 && h.keyVersion < p.MinDecryptionVersion {
+		return nil, errutil.UserError{Err: ErrTooOld}
+	}
+
+	aead, err := p.streamAEAD(context, h)
+	if err != nil {
+		return nil, err
+	}
+
+	return &StreamDecrypter{
+		r:          bufio.NewReaderSize(r, h.chunkSize+aead.Overhead()+1),
+		aead:       aead,
+		header:     header,
+		keyVersion: h.keyVersion,
+		chunk:      make([]byte, h.chunkSize+aead.Overhead()),
+	}, nil
+}
+
+// KeyVersion returns the version of the key the stream is encrypted with.
+func (d *StreamDecrypter) KeyVersion() int {
+	return d.keyVersion
+}
+
+// Read reads decrypted plaintext from the stream.
+func (d *StreamDecrypter) Read(p []byte) (int, error) {
+	for len(d.plaintext) == 0 {
+		if d.err != nil {
+			return 0, d.err
+		}
+		if d.done {
+			return 0, io.EOF
+		}
+		d.err = d.open()
+	}
+
+	n := copy(p, d.plaintext)
+	d.plaintext = d.plaintext[n:]
+	return n, nil
+}
+
+func (d *StreamDecrypter) open() error {
+	n, err := io.ReadFull(d.r, d.chunk)
+	switch {
+	case err == io.EOF:
+		// The previous chunk was not the last one, so the stream has been
+		// truncated.
+		return errutil.UserError{Err: "invalid ciphertext: stream is truncated"}
+	case err != nil && err != io.ErrUnexpectedEOF:
+		return err
+	}
+
+	// A chunk is the last one when nothing follows it.
+	last := err == io.ErrUnexpectedEOF
+	if !last {
+		if _, err := d.r.Peek(1); err == io.EOF {
+			last = true
+		} else if err != nil {
+			return err
+		}
+	}
+
+	if d.counter == math.MaxUint32 {
+		return errutil.UserError{Err: "invalid ciphertext: stream is too long"}
+	}
+
+	plaintext, err := d.aead.Open(d.chunk[:0], streamNonce(d.counter, last), d.chunk[:n], d.header)
+	if err != nil {
+		return errutil.UserError{Err: fmt.Sprintf("invalid ciphertext: unable to authenticate chunk %d", d.counter)}
+	}
+
+	d.counter++
+	d.plaintext = plaintext
+	d.done = last
+	return nil
+}
@@ -0,0 +1,328 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: MPL-2.0
+
+package keysutil
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync/atomic"
+
+	"github.com/hashicorp/vault/sdk/helper/errutil"
+	"github.com/hashicorp/vault/sdk/logical"
+)
+
+const (
+	// OperationLimitActionRotate rotates the key once its latest version
+	// reaches its operation limit.
+	OperationLimitActionRotate = "rotate"
+
+	// OperationLimitActionRefuse refuses further operations with the latest
+	// version of the key once it reaches its operation limit, until the key is
+	// rotated.
+	OperationLimitActionRefuse = "refuse"
+
+	// OperationReservationSize is the number of operations to reserve ahead
+	// for a key version with an operation limit when the policy is cached, so
+	// that the policy need not be persisted for every operation.
+	OperationReservationSize = 1000
+)
+
+// OperationCount holds the number of encryption and signing operations
+// performed with a key version.
+type OperationCount struct {
+	Encryptions uint64 `json:"encryptions"`
+	Signatures  uint64 `json:"signatures"`
+
+	// Reserved is the number of operations which may have been performed
+	// with a version with an operation limit. Operations are only served
+	// within the reservation, which is persisted before they are, so that
+	// operations which were served but whose counts were never persisted
+	// still count towards the limit once the policy is loaded again.
+	Reserved uint64 `json:"reserved,omitempty"`
+
+	// Operations performed since the count was last persisted, which are
+	// updated atomically while the policy is only read locked
+	pendingEncryptions uint64
+	pendingSignatures  uint64
+
+	// used is the number of operations counted towards the operation limit,
+	// including those which may have been served by a previous instance of
+	// the policy within its reservation
+	used uint64
+}
+
+func (c *OperationCount) total() uint64 {
+	return atomic.LoadUint64(&c.used)
+}
+
+// loadOperationCounts initializes the operations counted towards the
+// operation limit of the loaded policy, assuming that all operations reserved
+// have been served.
+func (p *Policy) loadOperationCounts() {
+	p.initOperationCounts()
+	for _, count := range p.OperationCounts {
+		count.used = count.Encryptions + count.Signatures
+		if count.Reserved > count.used {
+			count.used = count.Reserved
+		}
+	}
+}
+
+// initOperationCounts ensures there is an operation count for every
+// available key version, and removes those of versions which have been
+// trimmed. The policy must be exclusively locked.
+func (p *Policy) initOperationCounts() {
+	if p.LatestVersion == 0 {
+		return
+	}
+	if p.OperationCounts == nil {
+		p.OperationCounts = make(map[string]*OperationCount, p.LatestVersion)
+	}
+
+	minVersion := p.MinAvailableVersion
+	if minVersion < 1 {
+		minVersion = 1
+	}
+	for ver := minVersion; ver <= p.LatestVersion; ver++ {
+		if p.OperationCounts[strconv.Itoa(ver)] == nil {
+			p.OperationCounts[strconv.Itoa(ver)] = &OperationCount{}
+		}
+	}
+	for k := range p.OperationCounts {
+		if ver, err := strconv.Atoi(k); err != nil || ver < minVersion || ver > p.LatestVersion {
+			delete(p.OperationCounts, k)
+		}
+	}
+}
+
+// countOperation counts an encryption or signing operation with the given
+// key version. It returns an error if the version has reached the operation
+// limit of the key and further operations with it are refused.
+func (p *Policy) countOperation(ver int, signing bool) error {
+	count := p.OperationCounts[strconv.Itoa(ver)]
+	if count == nil {
+		return nil
+	}
+
+	pending := &count.pendingEncryptions
+	if signing {
+		pending = &count.pendingSignatures
+	}
+
+	if p.MaxOperationsPerVersion == 0 {
+		atomic.AddUint64(&count.used, 1)
+		atomic.AddUint64(pending, 1)
+		return nil
+	}
+
+	// Versions other than the latest version can not be rotated away from, so
+	// are always refused once they reach the limit
+	refuse := p.refusesAtLimit(ver)
+
+	// The limit and reservation are checked and the operation counted in a
+	// single step, so that concurrent operations can not exceed them
+	var used uint64
+	for {
+		used = atomic.LoadUint64(&count.used)
+		if refuse && used >= p.MaxOperationsPerVersion {
+			return errutil.UserError{Err: fmt.Sprintf("key version %d has reached its limit of %d operations", ver, p.MaxOperationsPerVersion)}
+		}
+		if used >= count.Reserved {
+			return errutil.UserError{Err: fmt.Sprintf("no operations are reserved for key version %d; retry the request", ver)}
+		}
+		if atomic.CompareAndSwapUint64(&count.used, used, used+1) {
+			break
+		}
+	}
+
+	atomic.AddUint64(pending, 1)
+	if !refuse && used+1 >= p.MaxOperationsPerVersion {
+		atomic.StoreUint32(&p.rotationRequired, 1)
+	}
+
+	return nil
+}
+
+// refusesAtLimit returns whether operations with the given version are
+// refused once it reaches the operation limit, rather than the key rotated.
+func (p *Policy) refusesAtLimit(ver int) bool {
+	return ver != p.LatestVersion || p.OperationLimitAction == OperationLimitActionRefuse
+}
+
+// reservableVersions returns the versions for which operations are reserved,
+// which are those which may be used for new operations and have not reached
+// the operation limit.
+func (p *Policy) reservableVersions() []int {
+	minVersion := p.MinEncryptionVersion
+	if p.MinAvailableVersion > minVersion {
+		minVersion = p.MinAvailableVersion
+	}
+	if minVersion < 1 {
+		minVersion = 1
+	}
+
+	var versions []int
+	for ver := minVersion; ver <= p.LatestVersion; ver++ {
+		count := p.OperationCounts[strconv.Itoa(ver)]
+		if count == nil || (p.refusesAtLimit(ver) && count.total() >= p.MaxOperationsPerVersion) {
+			continue
+		}
+		versions = append(versions, ver)
+	}
+	return versions
+}
+
+// OperationsReserved returns whether n more operations can be served with
+// every version which may be used for new operations without reserving more.
+// It is always true for keys without an operation limit, whose operations are
+// not reserved.
+func (p *Policy) OperationsReserved(n uint64) bool {
+	if p.MaxOperationsPerVersion == 0 {
+		return true
+	}
+
+	for _, ver := range p.reservableVersions() {
+		count := p.OperationCounts[strconv.Itoa(ver)]
+		if count.total()+n > count.Reserved {
+			return false
+		}
+	}
+	return true
+}
+
+// ReserveOperations reserves n more operations for every version which may be
+// used for new operations and does not have as many reserved, and persists the
+// policy so that the reservation survives the loss of this instance.
+// Operations with a version are only served within its reservation. The policy
+// must be exclusively locked.
+func (p *Policy) ReserveOperations(ctx context.Context, storage logical.Storage, n uint64) error {
+	if p.MaxOperationsPerVersion == 0 {
+		return nil
+	}
+
+	p.initOperationCounts()
+	reserved := false
+	for _, ver := range p.reservableVersions() {
+		count := p.OperationCounts[strconv.Itoa(ver)]
+		if count.total()+n <= count.Reserved {
+			continue
+		}
+
+		count.Reserved = count.total() + n
+		if p.refusesAtLimit(ver) && count.Reserved > p.MaxOperationsPerVersion {
+			count.Reserved = p.MaxOperationsPerVersion
+		}
+		reserved = true
+	}
+	if !reserved {
+		return nil
+	}
+
+	return p.Persist(ctx, storage)
+}
+
+// RotationRequired returns whether the latest version of the key has reached
+// its operation limit and the key should be rotated.
+func (p *Policy) RotationRequired() bool {
+	return atomic.LoadUint32(&p.rotationRequired) == 1
+}
+
+// HasPendingOperationCounts returns whether operations have been counted
+// since the policy was last persisted.
+func (p *Policy) HasPendingOperationCounts() bool {
+	for _, count := range p.OperationCounts {
+		if atomic.LoadUint64(&count.pendingEncryptions) != 0 || atomic.LoadUint64(&count.pendingSignatures) != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// GetOperationCounts returns the number of operations performed with each key
+// version, including those not yet persisted.
+func (p *Policy) GetOperationCounts() map[int]OperationCount {
+	counts := make(map[int]OperationCount, len(p.OperationCounts))
+	for k, count := range p.OperationCounts {
+		ver, err := strconv.Atoi(k)
+		if err != nil {
+			continue
+		}
+		counts[ver] = OperationCount{
+			Encryptions: count.Encryptions + atomic.LoadUint64(&count.pendingEncryptions),
+			Signatures:  count.Signatures + atomic.LoadUint64(&count.pendingSignatures),
+		}
+	}
+	return counts
+}
+
+// MergeOperationCounts moves the operations counted by another instance of
+// the policy which have not been persisted, such as one which has been evicted
+// from the cache, into this policy. The policy must be exclusively locked.
+func (p *Policy) MergeOperationCounts(other *Policy) {
+	p.initOperationCounts()
+	if other != p {
+		for k, count := range other.OperationCounts {
+			encryptions := atomic.SwapUint64(&count.pendingEncryptions, 0)
+			signatures := atomic.SwapUint64(&count.pendingSignatures, 0)
+			if target := p.OperationCounts[k]; target != nil {
+				target.pendingEncryptions += encryptions
+				target.pendingSignatures += signatures
+
+				// The operations may already be counted within the
+				// reservation the policy was loaded with
+				used := target.Encryptions + target.Signatures + target.pendingEncryptions + target.pendingSignatures
+				if used > target.used {
+					target.used = used
+				}
+			}
+		}
+	}
+
+	p.checkOperationLimit()
+}
+
+// SetOperationLimit sets the number of operations after which a key version
+// reaches its operation limit, or 0 for no limit, and the action taken once
+// the latest version reaches it; an empty action is treated as rotate. The
+// policy must be exclusively locked.
+func (p *Policy) SetOperationLimit(maxOperations uint64, action string) error {
+	switch action {
+	case "", OperationLimitActionRotate, OperationLimitActionRefuse:
+	default:
+		return fmt.Errorf("unknown operation limit action %q", action)
+	}
+
+	p.MaxOperationsPerVersion = maxOperations
+	p.OperationLimitAction = action
+	p.checkOperationLimit()
+
+	return nil
+}
+
+// checkOperationLimit updates whether the key should be rotated because its
+// latest version has reached its operation limit. The policy must be
+// exclusively locked.
+func (p *Policy) checkOperationLimit() {
+	required := false
+	if p.MaxOperationsPerVersion != 0 && p.OperationLimitAction != OperationLimitActionRefuse {
+		latest := p.OperationCounts[strconv.Itoa(p.LatestVersion)]
+		required = latest != nil && latest.total() >= p.MaxOperationsPerVersion
+	}
+
+	if required {
+		atomic.StoreUint32(&p.rotationRequired, 1)
+	} else {
+		atomic.StoreUint32(&p.rotationRequired, 0)
+	}
+}
+
+// foldOperationCounts adds the pending operation counts to the persisted
+// counts. The policy must be exclusively locked.
+func (p *Policy) foldOperationCounts() {
+	for _, count := range p.OperationCounts {
+		count.Encryptions += atomic.SwapUint64(&count.pendingEncryptions, 0)
+		count.Signatures += atomic.SwapUint64(&count.pendingSignatures, 0)
+	}
+}
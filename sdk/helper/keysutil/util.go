@@ -4,11 +4,13 @@
 package keysutil
 
 import (
+	"crypto"
 	"crypto/x509"
 	"crypto/x509/pkix"
 	"encoding/asn1"
 	"errors"
 	"fmt"
+	"strings"
 
 	"golang.org/x/crypto/ed25519"
 )
@@ -149,3 +151,22 @@ func ParsePKCS8RSAPSSPrivateKey(der []byte) (key interface{}, err error) {
 
 	return key, nil
 }
+
+// ParseOAEPHash returns the hash function with the given name, as used for
+// the RSA-OAEP wrapping of imported and exported keys.
+func ParseOAEPHash(hashFn string) (crypto.Hash, error) {
+	switch strings.ToUpper(hashFn) {
+	case "SHA1":
+		return crypto.SHA1, nil
+	case "SHA224":
+		return crypto.SHA224, nil
+	case "SHA256":
+		return crypto.SHA256, nil
+	case "SHA384":
+		return crypto.SHA384, nil
+	case "SHA512":
+		return crypto.SHA512, nil
+	default:
+		return 0, fmt.Errorf("unknown hash function: %s", hashFn)
+	}
+}
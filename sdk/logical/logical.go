@@ -174,6 +174,14 @@ type Paths struct {
 	//
 	// For more details, consult limits/registry.go.
 	Limited []string
+
+	// Streaming paths are binary paths which read their request body and
+	// write their response body directly, so may be much larger than other
+	// requests. Their request bodies are limited by the listener's
+	// max_stream_request_size rather than max_request_size, and their
+	// parameters are taken from the query string. Streaming is only
+	// supported by builtin plugins.
+	Streaming []string
 }
 
 type Auditor interface {
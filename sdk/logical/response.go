@@ -230,6 +230,12 @@ func (w *HTTPResponseWriter) Written() bool {
 	return atomic.LoadUint32(w.written) == 1
 }
 
+// Unwrap returns the underlying http.ResponseWriter, allowing an
+// http.ResponseController to reach it.
+func (w *HTTPResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
 type WrappingResponseWriter interface {
 	http.ResponseWriter
 	Wrapped() http.ResponseWriter
@@ -262,6 +268,12 @@ func (w *StatusHeaderResponseWriter) Wrapped() http.ResponseWriter {
 	return w.wrapped
 }
 
+// Unwrap returns the wrapped http.ResponseWriter, allowing an
+// http.ResponseController to reach it.
+func (w *StatusHeaderResponseWriter) Unwrap() http.ResponseWriter {
+	return w.wrapped
+}
+
 func (w *StatusHeaderResponseWriter) Header() http.Header {
 	return w.wrapped.Header()
 }
@@ -118,6 +118,15 @@ type WellKnownSystemView interface {
 	DeregisterWellKnownRedirect(ctx context.Context, src string) bool
 }
 
+// TokenPolicySystemView is implemented by the system views of builtin
+// backends, which can resolve the policies of the token of a request.
+type TokenPolicySystemView interface {
+	// TokenPolicies returns the names of the policies which apply in the
+	// namespace of the mount to the token with the given accessor, including
+	// those derived from its identity entity and groups.
+	TokenPolicies(ctx context.Context, accessor string) ([]string, error)
+}
+
 type ExtendedSystemView interface {
 	WellKnownSystemView
 
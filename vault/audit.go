@@ -523,6 +523,17 @@ func (c *Core) removeAuditReloadFunc(entry *MountEntry) {
 
 		delete(c.reloadFuncs, key)
 
+		c.reloadFuncsLock.Unlock()
+	case "http":
+		key := "audit_http|" + entry.Path
+		c.reloadFuncsLock.Lock()
+
+		if c.logger.IsDebug() {
+			c.baseLogger.Named("audit").Debug("removing reload function", "path", entry.Path)
+		}
+
+		delete(c.reloadFuncs, key)
+
 		c.reloadFuncsLock.Unlock()
 	}
 }
@@ -579,6 +590,24 @@ func (c *Core) newAuditBackend(ctx context.Context, entry *MountEntry, view logi
 			return be.Reload(ctx)
 		})
 
+		c.reloadFuncsLock.Unlock()
+	case "http":
+		key := "audit_http|" + entry.Path
+
+		c.reloadFuncsLock.Lock()
+
+		if auditLogger.IsDebug() {
+			auditLogger.Debug("adding reload function", "path", entry.Path)
+			if entry.Options != nil {
+				auditLogger.Debug("http backend options", "path", entry.Path, "address", entry.Options["address"], "spool_dir", entry.Options["spool_dir"])
+			}
+		}
+
+		c.reloadFuncs[key] = append(c.reloadFuncs[key], func() error {
+			auditLogger.Info("flushing http audit backend", "path", entry.Path)
+			return be.Reload(ctx)
+		})
+
 		c.reloadFuncsLock.Unlock()
 	case "socket":
 		if auditLogger.IsDebug() && entry.Options != nil {
@@ -670,3 +699,45 @@ func hasEnterpriseAuditOptions(options map[string]string) bool {
 
 	return false
 }
+
+// redactedAuditOptionValue replaces the values of sensitive audit options when
+// the audit table is read.
+const redactedAuditOptionValue = "redacted"
+
+// redactAuditOptions returns a copy of the options of an audit device, with
+// the values of options which may contain credentials redacted so that they
+// aren't returned to callers able to read the audit table.
+// The HTTP device's 'headers' option usually carries an Authorization header,
+// so the header names are kept but their values are redacted.
+func redactAuditOptions(options map[string]string) map[string]string {
+	const auditOptionHeaders = "headers"
+
+	raw, ok := options[auditOptionHeaders]
+	if !ok {
+		return options
+	}
+
+	redacted := make(map[string]string, len(options))
+	for k, v := range options {
+		redacted[k] = v
+	}
+
+	var headers map[string]string
+	if err := jsonutil.DecodeJSON([]byte(raw), &headers); err != nil {
+		redacted[auditOptionHeaders] = redactedAuditOptionValue
+		return redacted
+	}
+
+	for name := range headers {
+		headers[name] = redactedAuditOptionValue
+	}
+
+	encoded, err := jsonutil.EncodeJSON(headers)
+	if err != nil {
+		redacted[auditOptionHeaders] = redactedAuditOptionValue
+		return redacted
+	}
+	redacted[auditOptionHeaders] = strings.TrimSpace(string(encoded))
+
+	return redacted
+}
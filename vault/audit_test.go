@@ -669,6 +669,56 @@ func TestAudit_hasEnterpriseAuditOptions(t *testing.T) {
 	}
 }
 
+// TestAudit_redactAuditOptions checks that the values of sensitive audit
+// options are redacted, without modifying the options of the audit device.
+func TestAudit_redactAuditOptions(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		input    map[string]string
+		expected map[string]string
+	}{
+		"nil": {},
+		"no-sensitive-opts": {
+			input:    map[string]string{"file_path": "discard"},
+			expected: map[string]string{"file_path": "discard"},
+		},
+		"headers": {
+			input: map[string]string{
+				"address": "https://collector.example.com",
+				"headers": `{"Authorization":"Bearer secret","X-Tenant":"vault"}`,
+			},
+			expected: map[string]string{
+				"address": "https://collector.example.com",
+				"headers": `{"Authorization":"redacted","X-Tenant":"redacted"}`,
+			},
+		},
+		"invalid-headers": {
+			input:    map[string]string{"headers": "Bearer secret"},
+			expected: map[string]string{"headers": "redacted"},
+		},
+	}
+
+	for name, tc := range tests {
+		name := name
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var original map[string]string
+			if tc.input != nil {
+				original = make(map[string]string, len(tc.input))
+				for k, v := range tc.input {
+					original[k] = v
+				}
+			}
+
+			require.Equal(t, tc.expected, redactAuditOptions(tc.input))
+			require.Equal(t, original, tc.input)
+		})
+	}
+}
+
 // TestAudit_hasInvalidAuditOptions tests that depending on whether we are running
 // an Enterprise or non-Enterprise version of Vault, the options supplied to enable
 // an audit device may or may not be valid.
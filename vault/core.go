@@ -3703,6 +3703,39 @@ func (c *Core) ApplyRateLimitQuota(ctx context.Context, req *quotas.Request) (qu
 	return resp, nil
 }
 
+// ApplyConcurrencyQuota checks the request against all the applicable
+// concurrency quota rules, waiting for the rule's queue timeout if the limit
+// has been reached. Paths which are exempt from rate limiting are also exempt
+// from concurrency quotas. The Access of an allowed response must be released
+// with ReleaseConcurrencyQuota once the request completes.
+func (c *Core) ApplyConcurrencyQuota(ctx context.Context, req *quotas.Request) (quotas.Response, error) {
+	req.Type = quotas.TypeConcurrency
+
+	resp := quotas.Response{
+		Allowed: true,
+	}
+
+	if c.quotaManager != nil {
+		if c.quotaManager.RateLimitPathExempt(req.Path, req.NamespacePath) {
+			return resp, nil
+		}
+
+		return c.quotaManager.ApplyQuota(ctx, req)
+	}
+
+	return resp, nil
+}
+
+// ReleaseConcurrencyQuota frees the slot held by a request that was allowed by
+// a concurrency quota. It is a no-op if the request was not subject to one.
+func (c *Core) ReleaseConcurrencyQuota(access quotas.Access) error {
+	if c.quotaManager == nil || access == nil {
+		return nil
+	}
+
+	return c.quotaManager.ReleaseConcurrencyQuota(access)
+}
+
 // RateLimitAuditLoggingEnabled returns if the quota configuration allows audit
 // logging of request rejections due to rate limiting quota rule violations.
 func (c *Core) RateLimitAuditLoggingEnabled() bool {
@@ -4266,6 +4299,69 @@ func (c *Core) ResolveRoleForQuotas(ctx context.Context, req *quotas.Request) (b
 	return c.quotaManager.QueryResolveRoleQuotas(req)
 }
 
+// ctxKeyQuotaTokenEntry is the context key for the client token entry which
+// was looked up by ResolveIdentityForQuotas, so that handling the request
+// doesn't look it up again.
+type ctxKeyQuotaTokenEntry struct{}
+
+func (c ctxKeyQuotaTokenEntry) String() string {
+	return "quota-token-entry"
+}
+
+// quotaTokenEntry is a token entry, along with the token it was looked up by.
+type quotaTokenEntry struct {
+	token string
+	entry *logical.TokenEntry
+}
+
+// ResolveIdentityForQuotas populates the entity ID, accessor and metadata of the
+// client token in the quota request, if the rate limit quota that applies to
+// the request is keyed on the identity of the client. If the token cannot be
+// resolved, the request is left unchanged and is keyed on the client address.
+// The returned context holds the token entry, which is reused when the request
+// is handled.
+func (c *Core) ResolveIdentityForQuotas(ctx context.Context, req *quotas.Request, token string) (context.Context, error) {
+	if c.quotaManager == nil || token == "" {
+		return ctx, nil
+	}
+
+	required, err := c.quotaManager.QueryResolveIdentityQuotas(req)
+	if err != nil || !required {
+		return ctx, err
+	}
+
+	c.stateLock.RLock()
+	defer c.stateLock.RUnlock()
+
+	// Lookup decodes server side consistent tokens, in the same way as when
+	// the token entry is populated while handling the request.
+	te, err := c.LookupToken(ctx, token)
+	if err != nil {
+		c.logger.Debug("failed to look up client token for quotas", "path", req.Path, "error", err)
+		return ctx, nil
+	}
+	if te == nil {
+		return ctx, nil
+	}
+
+	req.EntityID = te.EntityID
+	req.TokenAccessor = te.Accessor
+	req.TokenMetadata = te.Meta
+
+	return context.WithValue(ctx, ctxKeyQuotaTokenEntry{}, &quotaTokenEntry{token: token, entry: te}), nil
+}
+
+// quotaTokenEntryFromContext returns the token entry for the token which was
+// looked up by ResolveIdentityForQuotas, if any.
+func quotaTokenEntryFromContext(ctx context.Context, token string) *logical.TokenEntry {
+	qte, ok := ctx.Value(ctxKeyQuotaTokenEntry{}).(*quotaTokenEntry)
+	if !ok || qte.token != token {
+		return nil
+	}
+
+	return qte.entry
+}
+
 // aliasNameFromLoginRequest will determine the aliasName from the login Request
 func (c *Core) aliasNameFromLoginRequest(ctx context.Context, req *logical.Request) (string, error) {
 	c.authLock.RLock()
@@ -8,6 +8,8 @@ package vault
 import (
 	"context"
 	"fmt"
+	"path"
+	"strings"
 
 	"github.com/hashicorp/go-hclog"
 	"github.com/hashicorp/vault/helper/namespace"
@@ -171,20 +173,89 @@ func (c *Core) initSealsForMigration() {}
 
 func (c *Core) postSealMigration(ctx context.Context) error { return nil }
 
-func (c *Core) applyLeaseCountQuota(_ context.Context, in *quotas.Request) (*quotas.Response, error) {
-	return &quotas.Response{Allowed: true}, nil
+func (c *Core) applyLeaseCountQuota(ctx context.Context, in *quotas.Request) (*quotas.Response, error) {
+	if c.quotaManager == nil {
+		return &quotas.Response{Allowed: true}, nil
+	}
+
+	in.Type = quotas.TypeLeaseCount
+	resp, err := c.quotaManager.ApplyQuota(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+
+	return &resp, nil
 }
 
 func (c *Core) ackLeaseQuota(access quotas.Access, leaseGenerated bool) error {
-	return nil
+	if c.quotaManager == nil {
+		return nil
+	}
+
+	return c.quotaManager.AckLeaseQuota(access, leaseGenerated)
 }
 
+// quotaLeaseWalker calls the callback with a quota request describing each of
+// the leases which count towards lease count quotas. Returning false from the
+// callback terminates the iteration.
 func (c *Core) quotaLeaseWalker(ctx context.Context, callback func(request *quotas.Request) bool) error {
-	return nil
+	if c.expiration == nil {
+		return nil
+	}
+
+	var walkErr error
+	c.expiration.walkQuotaLeases(func(leaseID, loginRole string) bool {
+		req, err := c.leaseQuotaRequest(ctx, leaseID, loginRole)
+		if err != nil {
+			walkErr = err
+			return false
+		}
+
+		return callback(req)
+	})
+
+	return walkErr
 }
 
 func (c *Core) quotasHandleLeases(ctx context.Context, action quotas.LeaseAction, leases []*quotas.QuotaLeaseInformation) error {
-	return nil
+	if c.quotaManager == nil {
+		return nil
+	}
+
+	reqs := make([]*quotas.Request, 0, len(leases))
+	for _, lease := range leases {
+		req, err := c.leaseQuotaRequest(ctx, lease.LeaseId, lease.Role)
+		if err != nil {
+			return err
+		}
+		reqs = append(reqs, req)
+	}
+
+	return c.quotaManager.HandleLeaseAction(action, reqs)
+}
+
+// leaseQuotaRequest builds the quota request that corresponds to the request
+// which generated a lease, so that the lease is counted by the same lease count
+// quota that was applied to the request.
+func (c *Core) leaseQuotaRequest(ctx context.Context, leaseID, loginRole string) (*quotas.Request, error) {
+	ns, err := c.expiration.getNamespaceFromLeaseID(ctx, leaseID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to determine namespace of lease %q: %w", leaseID, err)
+	}
+
+	// The lease ID is the request path followed by a unique identifier, and an
+	// optional namespace ID.
+	id, _ := namespace.SplitIDFromString(leaseID)
+	reqPath := path.Dir(id)
+
+	mountPath := c.router.MatchingMount(namespace.ContextWithNamespace(ctx, ns), reqPath)
+
+	return &quotas.Request{
+		Path:          reqPath,
+		Role:          loginRole,
+		NamespacePath: ns.Path,
+		MountPath:     strings.TrimPrefix(mountPath, ns.Path),
+	}, nil
 }
 
 func (c *Core) namespaceByPath(path string) *namespace.Namespace {
@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/hashicorp/go-secure-stdlib/strutil"
 	"github.com/hashicorp/vault/helper/identity"
 	"github.com/hashicorp/vault/helper/namespace"
 	"github.com/hashicorp/vault/helper/random"
@@ -38,6 +39,8 @@ type extendedSystemView interface {
 	// SudoPrivilege won't work over the plugin system so we keep it here
 	// instead of in sdk/logical to avoid exposing to plugins
 	SudoPrivilege(context.Context, string, string) bool
+	// TokenPolicies is not exposed to plugins for the same reason
+	logical.TokenPolicySystemView
 }
 
 var _ logical.ExtendedSystemView = (*extendedSystemViewImpl)(nil)
@@ -139,6 +142,54 @@ func (e extendedSystemViewImpl) SudoPrivilege(ctx context.Context, path string,
 	return authResults.RootPrivs
 }
 
+// TokenPolicies returns the policies of the token with the given accessor
+// which apply in the namespace of the mount, including its identity policies
+func (e extendedSystemViewImpl) TokenPolicies(ctx context.Context, accessor string) ([]string, error) {
+	if accessor == "" {
+		return nil, fmt.Errorf("no token accessor provided")
+	}
+
+	mountNS := e.mountEntry.Namespace()
+	ctx = namespace.ContextWithNamespace(ctx, mountNS)
+
+	aEntry, err := e.core.tokenStore.lookupByAccessor(ctx, accessor, false, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up token accessor: %w", err)
+	}
+	if aEntry == nil || aEntry.TokenID == "" {
+		return nil, fmt.Errorf("token accessor not found")
+	}
+
+	te, err := e.core.tokenStore.Lookup(ctx, aEntry.TokenID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up token: %w", err)
+	}
+	if te == nil {
+		return nil, fmt.Errorf("token not found")
+	}
+
+	var policies []string
+	if te.NamespaceID == mountNS.ID {
+		policies = append(policies, te.Policies...)
+	}
+
+	tokenNS, err := NamespaceByID(ctx, te.NamespaceID, e.core)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up token namespace: %w", err)
+	}
+	if tokenNS == nil {
+		return nil, namespace.ErrNoNamespace
+	}
+
+	_, identityPolicies, err := e.core.fetchEntityAndDerivedPolicies(ctx, tokenNS, te.EntityID, te.NoIdentityPolicies)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch identity policies: %w", err)
+	}
+	policies = append(policies, identityPolicies[mountNS.ID]...)
+
+	return strutil.RemoveDuplicates(policies, false), nil
+}
+
 func (e extendedSystemViewImpl) APILockShouldBlockRequest() (bool, error) {
 	mountEntry := e.mountEntry
 	if mountEntry == nil {
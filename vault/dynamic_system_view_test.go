@@ -312,3 +312,40 @@ func (b fakeBarrier) Put(context.Context, *logical.StorageEntry) error {
 func (b fakeBarrier) Delete(context.Context, string) error {
 	return fmt.Errorf("not implemented")
 }
+
+func TestDynamicSystemView_TokenPolicies(t *testing.T) {
+	c, _, root := TestCoreUnsealed(t)
+
+	req := logical.TestRequest(t, logical.UpdateOperation, "identity/entity")
+	req.ClientToken = root
+	req.Data["name"] = "approver"
+	req.Data["policies"] = []string{"approver", "dev"}
+	resp, err := c.HandleRequest(namespace.RootContext(nil), req)
+	if err != nil || (resp != nil && resp.IsError()) {
+		t.Fatalf("err: %v\nresp: %#v", err, resp)
+	}
+
+	ent := &logical.TokenEntry{
+		Path:        "test",
+		Policies:    []string{"dev", "ops"},
+		TTL:         time.Hour,
+		NamespaceID: namespace.RootNamespaceID,
+		EntityID:    resp.Data["id"].(string),
+	}
+	testMakeTokenDirectly(t, c.tokenStore, ent)
+
+	dsv := TestDynamicSystemView(c, nil).(logical.TokenPolicySystemView)
+
+	policies, err := dsv.TokenPolicies(namespace.RootContext(nil), ent.Accessor)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(policies)
+	if expected := []string{"approver", "dev", "ops"}; !reflect.DeepEqual(policies, expected) {
+		t.Fatalf("expected policies %v, got %v", expected, policies)
+	}
+
+	if _, err := dsv.TokenPolicies(namespace.RootContext(nil), "unknown"); err == nil {
+		t.Fatal("expected looking up an unknown accessor to fail")
+	}
+}
@@ -839,23 +839,13 @@ func (m *ExpirationManager) processRestore(ctx context.Context, leaseID string)
 		return nil
 	}
 
-	// Load lease and restore expiration timer
-	le, err := m.loadEntryInternal(ctx, leaseID, true, false)
+	// Load lease and restore expiration timer. This also updates the quotas
+	// with the relevant lease information.
+	_, err := m.loadEntryInternal(ctx, leaseID, true, false)
 	if err != nil {
 		return err
 	}
 
-	// Update quotas with relevant lease information
-	if le != nil {
-		leaseInfo := &quotas.QuotaLeaseInformation{LeaseId: le.LeaseID, Role: le.LoginRole}
-		if err := m.core.quotasHandleLeases(context.Background(), quotas.LeaseActionLoaded, []*quotas.QuotaLeaseInformation{leaseInfo}); err != nil {
-			// We don't want to fail the start-up due to leases not being able
-			// to be loaded into the quota manager. When the leases get loaded,
-			// quota manager will be updated individually too.
-			m.logger.Error("failed to load lease into the quota sub-system", "LeaseID:", leaseID, "LoginRole", le.LoginRole, "error", err)
-		}
-	}
-
 	return nil
 }
 
@@ -2089,6 +2079,20 @@ func (m *ExpirationManager) loadEntryInternal(ctx context.Context, leaseID strin
 
 		// Setup revocation timer
 		m.updatePending(le)
+
+		// Update quotas with relevant lease information. This is done here,
+		// rather than by the restore process, so that leases which are loaded
+		// lazily while restoring are also counted. Non-expiring tokens are not
+		// counted, in the same way as when they are created.
+		if !(le.ExpireTime.IsZero() && le.nonexpiringToken()) {
+			leaseInfo := &quotas.QuotaLeaseInformation{LeaseId: le.LeaseID, Role: le.LoginRole}
+			if err := m.core.quotasHandleLeases(ctx, quotas.LeaseActionLoaded, []*quotas.QuotaLeaseInformation{leaseInfo}); err != nil {
+				// We don't want to fail the start-up due to leases not being able
+				// to be loaded into the quota manager. When the leases get loaded,
+				// quota manager will be updated individually too.
+				m.logger.Error("failed to load lease into the quota sub-system", "LeaseID:", leaseID, "LoginRole", le.LoginRole, "error", err)
+			}
+		}
 	}
 	return le, nil
 }
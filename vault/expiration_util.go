@@ -31,3 +31,29 @@ func (m *ExpirationManager) collectLeases() (map[*namespace.Namespace][]string,
 	leaseCount += len(keys)
 	return existing, leaseCount, nil
 }
+
+// walkQuotaLeases calls walkFn for each pending and irrevocable lease, which are
+// the leases that count towards lease count quotas. Returning false from walkFn
+// terminates the iteration.
+func (m *ExpirationManager) walkQuotaLeases(walkFn func(leaseID, loginRole string) bool) {
+	m.pendingLock.RLock()
+	pending, irrevocable := &m.pending, &m.irrevocable
+	m.pendingLock.RUnlock()
+
+	more := true
+	pending.Range(func(key, value interface{}) bool {
+		more = walkFn(key.(string), value.(pendingInfo).loginRole)
+		return more
+	})
+	if !more {
+		return
+	}
+
+	irrevocable.Range(func(key, value interface{}) bool {
+		var loginRole string
+		if le, ok := value.(*leaseEntry); ok && le != nil {
+			loginRole = le.LoginRole
+		}
+		return walkFn(key.(string), loginRole)
+	})
+}
@@ -4,14 +4,21 @@
 package quotas
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
 	"github.com/hashicorp/vault/api"
+	"github.com/hashicorp/vault/audit"
+	auditFile "github.com/hashicorp/vault/builtin/audit/file"
 	"github.com/hashicorp/vault/builtin/credential/userpass"
 	"github.com/hashicorp/vault/builtin/logical/pki"
 	"github.com/hashicorp/vault/helper/testhelpers/teststorage"
+	"github.com/hashicorp/vault/sdk/framework"
 	"github.com/hashicorp/vault/sdk/helper/testhelpers/schema"
 	"github.com/hashicorp/vault/sdk/logical"
 	"github.com/hashicorp/vault/vault"
@@ -541,3 +548,298 @@ func TestQuotas_RateLimitQuota(t *testing.T) {
 		t.Fatalf("unexpected number of failed requests: %d", numFail)
 	}
 }
+
+// TestQuotas_RateLimitQuota_KeyByEntity ensures that a rate limit quota keyed on
+// the entity of the client token limits each entity separately, even though all
+// the requests come from the same address.
+func TestQuotas_RateLimitQuota_KeyByEntity(t *testing.T) {
+	conf, opts := teststorage.ClusterSetup(coreConfig, nil, nil)
+	opts.NoDefaultQuotas = true
+	cluster := vault.NewTestCluster(t, conf, opts)
+	cluster.Start()
+	defer cluster.Cleanup()
+
+	core := cluster.Cores[0].Core
+	client := cluster.Cores[0].Client
+	vault.TestWaitActive(t, core)
+
+	require.NoError(t, client.Sys().EnableAuthWithOptions("userpass", &api.EnableAuthOptions{
+		Type: "userpass",
+	}))
+
+	login := func(username string) *api.Client {
+		t.Helper()
+		_, err := client.Logical().Write("auth/userpass/users/"+username, map[string]interface{}{
+			"password": "bar",
+		})
+		require.NoError(t, err)
+
+		secret, err := client.Logical().Write("auth/userpass/login/"+username, map[string]interface{}{
+			"password": "bar",
+		})
+		require.NoError(t, err)
+		require.NotEmpty(t, secret.Auth.EntityID)
+
+		userClient, err := client.Clone()
+		require.NoError(t, err)
+		userClient.SetToken(secret.Auth.ClientToken)
+		return userClient
+	}
+	noisy := login("noisy")
+	quiet := login("quiet")
+
+	_, err := client.Logical().Write("sys/quotas/rate-limit/rlq", map[string]interface{}{
+		"path":     "auth/token/",
+		"rate":     1,
+		"interval": "1h",
+		"key_by":   "entity_id",
+	})
+	require.NoError(t, err)
+
+	s, err := client.Logical().Read("sys/quotas/rate-limit/rlq")
+	require.NoError(t, err)
+	require.Equal(t, "entity_id", s.Data["key_by"])
+	require.Equal(t, "", s.Data["key_by_metadata"])
+
+	_, err = noisy.Auth().Token().LookupSelf()
+	require.NoError(t, err)
+
+	_, err = noisy.Auth().Token().LookupSelf()
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "rate limit quota exceeded")
+
+	// The other entity is not limited by the requests of the first.
+	_, err = quiet.Auth().Token().LookupSelf()
+	require.NoError(t, err)
+
+	_, err = client.Logical().Write("sys/quotas/rate-limit/rlq", map[string]interface{}{
+		"path":   "auth/token/",
+		"rate":   1,
+		"key_by": "metadata",
+	})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), `key by metadata field is required when keying on "metadata"`)
+}
+
+// TestQuotas_LeaseCountQuota ensures that a lease count quota on a path rejects
+// requests once the limit is reached, that revoking leases makes room for new
+// ones, and that the count is rebuilt from the existing leases after unsealing.
+func TestQuotas_LeaseCountQuota(t *testing.T) {
+	conf, opts := teststorage.ClusterSetup(coreConfig, nil, nil)
+	opts.NoDefaultQuotas = true
+	cluster := vault.NewTestCluster(t, conf, opts)
+	cluster.Start()
+	defer cluster.Cleanup()
+
+	core := cluster.Cores[0].Core
+	client := cluster.Cores[0].Client
+	vault.TestWaitActive(t, core)
+
+	setupMounts(t, client)
+
+	// Create a lease before the quota, to ensure existing leases are counted.
+	secret, err := client.Logical().Write("pki/issue/test", map[string]interface{}{
+		"common_name": "first.testvault.com",
+	})
+	require.NoError(t, err)
+	leaseID := secret.LeaseID
+	require.NotEmpty(t, leaseID)
+
+	_, err = client.Logical().Write("sys/quotas/lease-count/lcq", map[string]interface{}{
+		"path":       "pki/issue/test",
+		"max_leases": 2,
+	})
+	require.NoError(t, err)
+
+	readCounter := func() int64 {
+		t.Helper()
+		s, err := client.Logical().Read("sys/quotas/lease-count/lcq")
+		require.NoError(t, err)
+		require.Equal(t, "pki/issue/test", s.Data["path"])
+		counter, err := s.Data["counter"].(json.Number).Int64()
+		require.NoError(t, err)
+		return counter
+	}
+	require.Equal(t, int64(1), readCounter())
+
+	_, err = client.Logical().Write("pki/issue/test", map[string]interface{}{
+		"common_name": "second.testvault.com",
+	})
+	require.NoError(t, err)
+	require.Equal(t, int64(2), readCounter())
+
+	_, err = client.Logical().Write("pki/issue/test", map[string]interface{}{
+		"common_name": "third.testvault.com",
+	})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "lease count quota exceeded")
+
+	// Paths outside the scope of the quota are not affected.
+	_, err = client.Logical().Write("auth/userpass/login/foo", map[string]interface{}{
+		"password": "bar",
+	})
+	require.NoError(t, err)
+
+	s, err := client.Logical().List("sys/quotas/lease-count")
+	require.NoError(t, err)
+	require.Equal(t, []interface{}{"lcq"}, s.Data["keys"])
+
+	require.NoError(t, client.Sys().Revoke(leaseID))
+	require.Equal(t, int64(1), readCounter())
+
+	_, err = client.Logical().Write("pki/issue/test", map[string]interface{}{
+		"common_name": "third.testvault.com",
+	})
+	require.NoError(t, err)
+
+	// Seal and unseal, the count is rebuilt as the leases are restored.
+	cluster.EnsureCoresSealed(t)
+	cluster.UnsealCores(t)
+	vault.TestWaitActive(t, core)
+
+	require.Eventually(t, func() bool {
+		return readCounter() == 2
+	}, 10*time.Second, 100*time.Millisecond)
+
+	_, err = client.Logical().Write("pki/issue/test", map[string]interface{}{
+		"common_name": "fourth.testvault.com",
+	})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "lease count quota exceeded")
+
+	_, err = client.Logical().Delete("sys/quotas/lease-count/lcq")
+	require.NoError(t, err)
+
+	_, err = client.Logical().Write("pki/issue/test", map[string]interface{}{
+		"common_name": "fourth.testvault.com",
+	})
+	require.NoError(t, err)
+}
+
+// blockingBackendFactory returns a factory for a backend whose "block" path
+// waits until release is closed, so that requests remain in flight.
+func blockingBackendFactory(release <-chan struct{}) logical.Factory {
+	return func(ctx context.Context, conf *logical.BackendConfig) (logical.Backend, error) {
+		b := &framework.Backend{
+			BackendType: logical.TypeLogical,
+			Paths: []*framework.Path{
+				{
+					Pattern: "block",
+					Operations: map[logical.Operation]framework.OperationHandler{
+						logical.ReadOperation: &framework.PathOperation{
+							Callback: func(ctx context.Context, _ *logical.Request, _ *framework.FieldData) (*logical.Response, error) {
+								select {
+								case <-release:
+								case <-ctx.Done():
+								}
+								return &logical.Response{Data: map[string]interface{}{"ok": true}}, nil
+							},
+						},
+					},
+				},
+			},
+		}
+		if err := b.Setup(ctx, conf); err != nil {
+			return nil, err
+		}
+		return b, nil
+	}
+}
+
+func TestQuotas_ConcurrencyQuota(t *testing.T) {
+	release := make(chan struct{})
+	conf, opts := teststorage.ClusterSetup(&vault.CoreConfig{
+		LogicalBackends: map[string]logical.Factory{
+			"blocking": blockingBackendFactory(release),
+		},
+		AuditBackends: map[string]audit.Factory{
+			"file": auditFile.Factory,
+		},
+	}, nil, nil)
+	opts.NoDefaultQuotas = true
+	cluster := vault.NewTestCluster(t, conf, opts)
+	cluster.Start()
+	defer cluster.Cleanup()
+
+	core := cluster.Cores[0].Core
+	client := cluster.Cores[0].Client
+	vault.TestWaitActive(t, core)
+
+	require.NoError(t, client.Sys().Mount("blocking", &api.MountInput{
+		Type: "blocking",
+	}))
+
+	auditLog := filepath.Join(t.TempDir(), "audit.log")
+	require.NoError(t, client.Sys().EnableAuditWithOptions("file", &api.EnableAuditOptions{
+		Type:    "file",
+		Options: map[string]string{"file_path": auditLog},
+	}))
+	_, err := client.Logical().Write("sys/quotas/config", map[string]interface{}{
+		"enable_rate_limit_audit_logging": true,
+	})
+	require.NoError(t, err)
+
+	_, err = client.Logical().Write("sys/quotas/concurrency/cq", map[string]interface{}{
+		"path":           "blocking/",
+		"max_concurrent": 1,
+		"queue_timeout":  "1s",
+	})
+	require.NoError(t, err)
+
+	s, err := client.Logical().Read("sys/quotas/concurrency/cq")
+	require.NoError(t, err)
+	require.Equal(t, "blocking/", s.Data["path"])
+	require.Equal(t, json.Number("1"), s.Data["max_concurrent"])
+	require.Equal(t, json.Number("1"), s.Data["queue_timeout"])
+
+	blocked := make(chan error, 1)
+	go func() {
+		_, err := client.Logical().Read("blocking/block")
+		blocked <- err
+	}()
+
+	require.Eventually(t, func() bool {
+		s, err := client.Logical().Read("sys/quotas/concurrency/cq")
+		return err == nil && s.Data["in_flight"] == json.Number("1")
+	}, 10*time.Second, 50*time.Millisecond)
+
+	// The quota is full, so the request waits for the queue timeout and is
+	// then rejected.
+	start := time.Now()
+	_, err = client.Logical().Read("blocking/block")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "concurrency quota exceeded")
+	require.Contains(t, err.Error(), "Code: 429")
+	require.GreaterOrEqual(t, time.Since(start), time.Second)
+
+	// The rejected request is audit logged, as it would be for a rate limit
+	// quota.
+	logged, err := os.ReadFile(auditLog)
+	require.NoError(t, err)
+	require.Contains(t, string(logged), "concurrency quota exceeded")
+
+	// Requests outside the scope of the quota are not affected.
+	_, err = client.Logical().Read("sys/quotas/concurrency/cq")
+	require.NoError(t, err)
+
+	s, err = client.Logical().List("sys/quotas/concurrency")
+	require.NoError(t, err)
+	require.Equal(t, []interface{}{"cq"}, s.Data["keys"])
+
+	close(release)
+	require.NoError(t, <-blocked)
+
+	_, err = client.Logical().Read("blocking/block")
+	require.NoError(t, err)
+
+	s, err = client.Logical().Read("sys/quotas/concurrency/cq")
+	require.NoError(t, err)
+	require.Equal(t, json.Number("0"), s.Data["in_flight"])
+
+	_, err = client.Logical().Delete("sys/quotas/concurrency/cq")
+	require.NoError(t, err)
+
+	s, err = client.Logical().Read("sys/quotas/concurrency/cq")
+	require.NoError(t, err)
+	require.Nil(t, s)
+}
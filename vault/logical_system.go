@@ -3834,7 +3834,7 @@ func (b *SystemBackend) handleAuditTable(ctx context.Context, req *logical.Reque
 			"path":        entry.Path,
 			"type":        entry.Type,
 			"description": entry.Description,
-			"options":     entry.Options,
+			"options":     redactAuditOptions(entry.Options),
 			"local":       entry.Local,
 		}
 		resp.Data[entry.Path] = info
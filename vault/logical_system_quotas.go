@@ -109,7 +109,7 @@ func (b *SystemBackend) quotasPaths() []*framework.Path {
 
 			Operations: map[logical.Operation]framework.OperationHandler{
 				logical.ListOperation: &framework.PathOperation{
-					Callback: b.handleRateLimitQuotasList(),
+					Callback: b.handleQuotasList(quotas.TypeRateLimit),
 				},
 			},
 			HelpSynopsis:    strings.TrimSpace(quotasHelp["rate-limit-list"][0]),
@@ -160,6 +160,17 @@ The 'rate' must be positive.`,
 					Description: `If set, when a client reaches a rate limit threshold, the client will be prohibited
 from any further requests until after the 'block_interval' has elapsed.`,
 				},
+				"key_by": {
+					Type: framework.TypeString,
+					Description: `What identifies a client, each client is rate limited separately. One of
+'ip', 'entity_id', 'token_accessor' or 'metadata' (default 'ip'). Requests
+without a client token, or whose token lacks the identity, are keyed on the
+client IP address.`,
+				},
+				"key_by_metadata": {
+					Type:        framework.TypeString,
+					Description: "The name of the client token metadata field to key on, when 'key_by' is 'metadata'.",
+				},
 			},
 			Operations: map[logical.Operation]framework.OperationHandler{
 				logical.UpdateOperation: &framework.PathOperation{
@@ -210,6 +221,14 @@ from any further requests until after the 'block_interval' has elapsed.`,
 									Type:     framework.TypeInt,
 									Required: true,
 								},
+								"key_by": {
+									Type:     framework.TypeString,
+									Required: true,
+								},
+								"key_by_metadata": {
+									Type:     framework.TypeString,
+									Required: true,
+								},
 								"inheritable": {
 									Type:     framework.TypeBool,
 									Required: true,
@@ -219,7 +238,7 @@ from any further requests until after the 'block_interval' has elapsed.`,
 					},
 				},
 				logical.DeleteOperation: &framework.PathOperation{
-					Callback: b.handleRateLimitQuotasDelete(),
+					Callback: b.handleQuotasDelete(quotas.TypeRateLimit),
 					DisplayAttrs: &framework.DisplayAttributes{
 						OperationVerb: "delete",
 					},
@@ -233,6 +252,258 @@ from any further requests until after the 'block_interval' has elapsed.`,
 			HelpSynopsis:    strings.TrimSpace(quotasHelp["rate-limit"][0]),
 			HelpDescription: strings.TrimSpace(quotasHelp["rate-limit"][1]),
 		},
+		{
+			Pattern: "quotas/lease-count/?$",
+
+			DisplayAttrs: &framework.DisplayAttributes{
+				OperationPrefix: "lease-count-quotas",
+				OperationVerb:   "list",
+			},
+
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.ListOperation: &framework.PathOperation{
+					Callback: b.handleQuotasList(quotas.TypeLeaseCount),
+				},
+			},
+			HelpSynopsis:    strings.TrimSpace(quotasHelp["lease-count-list"][0]),
+			HelpDescription: strings.TrimSpace(quotasHelp["lease-count-list"][1]),
+		},
+		{
+			Pattern: "quotas/lease-count/" + framework.GenericNameRegex("name"),
+
+			DisplayAttrs: &framework.DisplayAttributes{
+				OperationPrefix: "lease-count-quotas",
+			},
+
+			Fields: map[string]*framework.FieldSchema{
+				"type": {
+					Type:        framework.TypeString,
+					Description: "Type of the quota rule.",
+				},
+				"name": {
+					Type:        framework.TypeString,
+					Description: "Name of the quota rule.",
+				},
+				"path": {
+					Type: framework.TypeString,
+					Description: `Path of the mount or namespace to apply the quota. A blank path configures a
+global quota. For example namespace1/ adds a quota to a full namespace,
+namespace1/auth/userpass adds a quota to userpass in namespace1.`,
+				},
+				"role": {
+					Type: framework.TypeString,
+					Description: `Login role to apply this quota to. Note that when set, path must be configured
+to a valid auth method with a concept of roles.`,
+				},
+				"inheritable": {
+					Type:        framework.TypeBool,
+					Description: `Whether all child namespaces can inherit this namespace quota.`,
+				},
+				"max_leases": {
+					Type: framework.TypeInt,
+					Description: `The maximum number of leases to be allowed by the quota rule.
+The 'max_leases' must be positive.`,
+				},
+			},
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.UpdateOperation: &framework.PathOperation{
+					Callback: b.handleLeaseCountQuotasUpdate(),
+					DisplayAttrs: &framework.DisplayAttributes{
+						OperationVerb: "write",
+					},
+					Responses: map[int][]framework.Response{
+						http.StatusNoContent: {{
+							Description: http.StatusText(http.StatusNoContent),
+						}},
+					},
+				},
+				logical.ReadOperation: &framework.PathOperation{
+					Callback: b.handleLeaseCountQuotasRead(),
+					DisplayAttrs: &framework.DisplayAttributes{
+						OperationVerb: "read",
+					},
+					Responses: map[int][]framework.Response{
+						http.StatusOK: {{
+							Description: "OK",
+							Fields: map[string]*framework.FieldSchema{
+								"type": {
+									Type:     framework.TypeString,
+									Required: true,
+								},
+								"name": {
+									Type:     framework.TypeString,
+									Required: true,
+								},
+								"path": {
+									Type:     framework.TypeString,
+									Required: true,
+								},
+								"role": {
+									Type:     framework.TypeString,
+									Required: true,
+								},
+								"max_leases": {
+									Type:     framework.TypeInt,
+									Required: true,
+								},
+								"counter": {
+									Type:     framework.TypeInt64,
+									Required: true,
+								},
+								"inheritable": {
+									Type:     framework.TypeBool,
+									Required: true,
+								},
+							},
+						}},
+					},
+				},
+				logical.DeleteOperation: &framework.PathOperation{
+					Callback: b.handleQuotasDelete(quotas.TypeLeaseCount),
+					DisplayAttrs: &framework.DisplayAttributes{
+						OperationVerb: "delete",
+					},
+					Responses: map[int][]framework.Response{
+						http.StatusNoContent: {{
+							Description: "OK",
+						}},
+					},
+				},
+			},
+			HelpSynopsis:    strings.TrimSpace(quotasHelp["lease-count"][0]),
+			HelpDescription: strings.TrimSpace(quotasHelp["lease-count"][1]),
+		},
+		{
+			Pattern: "quotas/concurrency/?$",
+
+			DisplayAttrs: &framework.DisplayAttributes{
+				OperationPrefix: "concurrency-quotas",
+				OperationVerb:   "list",
+			},
+
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.ListOperation: &framework.PathOperation{
+					Callback: b.handleQuotasList(quotas.TypeConcurrency),
+				},
+			},
+			HelpSynopsis:    strings.TrimSpace(quotasHelp["concurrency-list"][0]),
+			HelpDescription: strings.TrimSpace(quotasHelp["concurrency-list"][1]),
+		},
+		{
+			Pattern: "quotas/concurrency/" + framework.GenericNameRegex("name"),
+
+			DisplayAttrs: &framework.DisplayAttributes{
+				OperationPrefix: "concurrency-quotas",
+			},
+
+			Fields: map[string]*framework.FieldSchema{
+				"type": {
+					Type:        framework.TypeString,
+					Description: "Type of the quota rule.",
+				},
+				"name": {
+					Type:        framework.TypeString,
+					Description: "Name of the quota rule.",
+				},
+				"path": {
+					Type: framework.TypeString,
+					Description: `Path of the mount or namespace to apply the quota. A blank path configures a
+global quota. For example namespace1/ adds a quota to a full namespace,
+namespace1/auth/userpass adds a quota to userpass in namespace1.`,
+				},
+				"role": {
+					Type: framework.TypeString,
+					Description: `Login role to apply this quota to. Note that when set, path must be configured
+to a valid auth method with a concept of roles.`,
+				},
+				"inheritable": {
+					Type:        framework.TypeBool,
+					Description: `Whether all child namespaces can inherit this namespace quota.`,
+				},
+				"max_concurrent": {
+					Type: framework.TypeInt,
+					Description: `The maximum number of requests to be in flight at the same time allowed by
+the quota rule. The 'max_concurrent' must be positive.`,
+				},
+				"queue_timeout": {
+					Type: framework.TypeDurationSecond,
+					Description: `The duration a request waits for an in flight request to complete, once
+'max_concurrent' is reached, before it is rejected. If unset, requests are
+rejected immediately.`,
+				},
+			},
+			Operations: map[logical.Operation]framework.OperationHandler{
+				logical.UpdateOperation: &framework.PathOperation{
+					Callback: b.handleConcurrencyQuotasUpdate(),
+					DisplayAttrs: &framework.DisplayAttributes{
+						OperationVerb: "write",
+					},
+					Responses: map[int][]framework.Response{
+						http.StatusNoContent: {{
+							Description: http.StatusText(http.StatusNoContent),
+						}},
+					},
+				},
+				logical.ReadOperation: &framework.PathOperation{
+					Callback: b.handleConcurrencyQuotasRead(),
+					DisplayAttrs: &framework.DisplayAttributes{
+						OperationVerb: "read",
+					},
+					Responses: map[int][]framework.Response{
+						http.StatusOK: {{
+							Description: "OK",
+							Fields: map[string]*framework.FieldSchema{
+								"type": {
+									Type:     framework.TypeString,
+									Required: true,
+								},
+								"name": {
+									Type:     framework.TypeString,
+									Required: true,
+								},
+								"path": {
+									Type:     framework.TypeString,
+									Required: true,
+								},
+								"role": {
+									Type:     framework.TypeString,
+									Required: true,
+								},
+								"max_concurrent": {
+									Type:     framework.TypeInt,
+									Required: true,
+								},
+								"queue_timeout": {
+									Type:     framework.TypeInt,
+									Required: true,
+								},
+								"in_flight": {
+									Type:     framework.TypeInt,
+									Required: true,
+								},
+								"inheritable": {
+									Type:     framework.TypeBool,
+									Required: true,
+								},
+							},
+						}},
+					},
+				},
+				logical.DeleteOperation: &framework.PathOperation{
+					Callback: b.handleQuotasDelete(quotas.TypeConcurrency),
+					DisplayAttrs: &framework.DisplayAttributes{
+						OperationVerb: "delete",
+					},
+					Responses: map[int][]framework.Response{
+						http.StatusNoContent: {{
+							Description: "OK",
+						}},
+					},
+				},
+			},
+			HelpSynopsis:    strings.TrimSpace(quotasHelp["concurrency"][0]),
+			HelpDescription: strings.TrimSpace(quotasHelp["concurrency"][1]),
+		},
 	}
 }
 
@@ -329,9 +600,9 @@ func (b *SystemBackend) handleQuotasConfigRead() framework.OperationFunc {
 	}
 }
 
-func (b *SystemBackend) handleRateLimitQuotasList() framework.OperationFunc {
+func (b *SystemBackend) handleQuotasList(qType quotas.Type) framework.OperationFunc {
 	return func(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
-		names, err := b.Core.quotaManager.QuotaNames(quotas.TypeRateLimit)
+		names, err := b.Core.quotaManager.QuotaNames(qType)
 		if err != nil {
 			return nil, err
 		}
@@ -340,6 +611,122 @@ func (b *SystemBackend) handleRateLimitQuotasList() framework.OperationFunc {
 	}
 }
 
+// quotaFactors holds the properties of a quota rule which determine the requests
+// that it applies to.
+type quotaFactors struct {
+	ns          *namespace.Namespace
+	mountPath   string
+	pathSuffix  string
+	role        string
+	inheritable bool
+}
+
+// parseQuotaFactors validates the path, role and inheritable fields of a request
+// to create or update the named quota rule of the given type. If the fields are
+// invalid, an error response is returned.
+func (b *SystemBackend) parseQuotaFactors(ctx context.Context, qType, name string, d *framework.FieldData) (*quotaFactors, *logical.Response, error) {
+	rawPath := sanitizePath(d.Get("path").(string))
+	mountPath := rawPath
+
+	// If the quota creation endpoint is being called from the privileged namespace, we want to prepend the namespace to the path
+	currentNamespace, err := namespace.FromContext(ctx)
+	if err != nil {
+		return nil, logical.ErrorResponse(err.Error()), nil
+	}
+	if currentNamespace.ID != namespace.RootNamespaceID && !strings.HasPrefix(mountPath, currentNamespace.Path) {
+		return nil, logical.ErrorResponse(ErrInvalidQuotaOnParentNs), nil
+	}
+
+	// If there is a quota by the same name that was configured on a parent namespace, prohibit updating this quota
+	if currentNamespace.ID != namespace.RootNamespaceID {
+		quota, err := b.Core.quotaManager.QuotaByName(qType, name)
+		if err != nil {
+			return nil, nil, err
+		}
+		if quota != nil && !strings.HasPrefix(quota.GetNamespacePath(), currentNamespace.Path) {
+			return nil, logical.ErrorResponse(ErrInvalidQuotaUpdate), nil
+		}
+	}
+
+	ns := b.Core.namespaceByPath(mountPath)
+	if ns.ID != namespace.RootNamespaceID {
+		mountPath = strings.TrimPrefix(mountPath, ns.Path)
+	}
+
+	var pathSuffix string
+	if mountPath != "" {
+		me := b.Core.router.MatchingMountEntry(namespace.ContextWithNamespace(ctx, ns), mountPath)
+		if me == nil {
+			return nil, logical.ErrorResponse("invalid mount path %q", mountPath), nil
+		}
+
+		mountAPIPath := me.APIPathNoNamespace()
+		pathSuffix = strings.TrimSuffix(strings.TrimPrefix(mountPath, mountAPIPath), "/")
+		mountPath = mountAPIPath
+	}
+
+	role := d.Get("role").(string)
+	// If this is a quota with a role, ensure the backend supports role resolution
+	if role != "" {
+		if pathSuffix != "" {
+			return nil, logical.ErrorResponse("Quotas cannot contain both a path suffix and a role. If a role is provided, path must be a valid auth mount with a concept of roles"), nil
+		}
+		authBackend := b.Core.router.MatchingBackend(namespace.ContextWithNamespace(ctx, ns), mountPath)
+		if authBackend == nil || authBackend.Type() != logical.TypeCredential {
+			return nil, logical.ErrorResponse("Mount path %q is not a valid auth method and therefore unsuitable for use with role-based quotas", mountPath), nil
+		}
+		// We will always error as we aren't supplying real data, but we're looking for "unsupported operation" in particular
+		_, err := authBackend.HandleRequest(ctx, &logical.Request{
+			Path:      "login",
+			Operation: logical.ResolveRoleOperation,
+		})
+		if err != nil && (err == logical.ErrUnsupportedOperation || err == logical.ErrUnsupportedPath) {
+			return nil, logical.ErrorResponse("Mount path %q does not support use with role-based quotas", mountPath), nil
+		}
+	}
+
+	var inheritable bool
+	// All global quotas should be inherited by default
+	if rawPath == "" {
+		inheritable = true
+	}
+
+	if inheritableRaw, ok := d.GetOk("inheritable"); ok {
+		inheritable = inheritableRaw.(bool)
+		if inheritable {
+			if pathSuffix != "" || role != "" || mountPath != "" {
+				return nil, logical.ErrorResponse("only namespace quotas can be configured as inheritable"), nil
+			}
+		} else if rawPath == "" {
+			// User should not try to configure a global quota that cannot be inherited
+			return nil, logical.ErrorResponse("all global quotas must be inheritable"), nil
+		}
+	}
+
+	// User should not try to configure a global quota to be uninheritable
+	if rawPath == "" && !inheritable {
+		return nil, logical.ErrorResponse("all global quotas must be inheritable"), nil
+	}
+
+	// Disallow creation of new quota that has properties similar to an
+	// existing quota.
+	quotaByFactors, err := b.Core.quotaManager.QuotaByFactors(ctx, qType, ns.Path, mountPath, pathSuffix, role)
+	if err != nil {
+		return nil, nil, err
+	}
+	if quotaByFactors != nil && quotaByFactors.QuotaName() != name {
+		return nil, logical.ErrorResponse("quota rule with similar properties exists under the name %q", quotaByFactors.QuotaName()), nil
+	}
+
+	return &quotaFactors{
+		ns:          ns,
+		mountPath:   mountPath,
+		pathSuffix:  pathSuffix,
+		role:        role,
+		inheritable: inheritable,
+	}, nil, nil
+}
+
 func (b *SystemBackend) handleRateLimitQuotasUpdate() framework.OperationFunc {
 	return func(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
 		name := d.Get("name").(string)
@@ -360,97 +747,193 @@ func (b *SystemBackend) handleRateLimitQuotasUpdate() framework.OperationFunc {
 			return logical.ErrorResponse("'block' is invalid"), nil
 		}
 
-		rawPath := sanitizePath(d.Get("path").(string))
-		mountPath := rawPath
-
-		// If the quota creation endpoint is being called from the privileged namespace, we want to prepend the namespace to the path
-		currentNamespace, err := namespace.FromContext(ctx)
-		if err != nil {
+		keyBy := d.Get("key_by").(string)
+		if keyBy == "" {
+			keyBy = quotas.RateLimitKeyByIP
+		}
+		keyByMetadata := d.Get("key_by_metadata").(string)
+		if err := quotas.ValidateRateLimitKeyBy(keyBy, keyByMetadata); err != nil {
 			return logical.ErrorResponse(err.Error()), nil
 		}
-		if currentNamespace.ID != namespace.RootNamespaceID && !strings.HasPrefix(mountPath, currentNamespace.Path) {
-			return logical.ErrorResponse(ErrInvalidQuotaOnParentNs), nil
+
+		factors, resp, err := b.parseQuotaFactors(ctx, qType, name, d)
+		if resp != nil || err != nil {
+			return resp, err
 		}
 
-		// If there is a quota by the same name that was configured on a parent namespace, prohibit updating this quota
-		if currentNamespace.ID != namespace.RootNamespaceID {
-			quota, err := b.Core.quotaManager.QuotaByName(qType, name)
-			if err != nil {
-				return nil, err
-			}
-			if quota != nil && !strings.HasPrefix(quota.GetNamespacePath(), currentNamespace.Path) {
-				return logical.ErrorResponse(ErrInvalidQuotaUpdate), nil
-			}
+		// If a quota already exists, fetch and update it.
+		quota, err := b.Core.quotaManager.QuotaByName(qType, name)
+		if err != nil {
+			return nil, err
 		}
 
-		ns := b.Core.namespaceByPath(mountPath)
-		if ns.ID != namespace.RootNamespaceID {
-			mountPath = strings.TrimPrefix(mountPath, ns.Path)
+		switch {
+		case quota == nil:
+			rlq := quotas.NewRateLimitQuota(name, factors.ns.Path, factors.mountPath, factors.pathSuffix, factors.role, factors.inheritable, interval, blockInterval, rate)
+			rlq.KeyBy = keyBy
+			rlq.KeyByMetadata = keyByMetadata
+			quota = rlq
+		default:
+			// Re-inserting the already indexed object in memdb might cause problems.
+			// So, clone the object. See https://github.com/hashicorp/go-memdb/issues/76.
+			clonedQuota := quota.Clone()
+			rlq := clonedQuota.(*quotas.RateLimitQuota)
+			rlq.NamespacePath = factors.ns.Path
+			rlq.MountPath = factors.mountPath
+			rlq.PathSuffix = factors.pathSuffix
+			rlq.Rate = rate
+			rlq.Inheritable = factors.inheritable
+			rlq.Interval = interval
+			rlq.BlockInterval = blockInterval
+			rlq.KeyBy = keyBy
+			rlq.KeyByMetadata = keyByMetadata
+			quota = rlq
+		}
+		if err := b.Core.quotaManager.SetQuota(ctx, qType, quota, false); err != nil {
+			return nil, err
 		}
 
-		var pathSuffix string
-		if mountPath != "" {
-			me := b.Core.router.MatchingMountEntry(namespace.ContextWithNamespace(ctx, ns), mountPath)
-			if me == nil {
-				return logical.ErrorResponse("invalid mount path %q", mountPath), nil
-			}
+		return nil, nil
+	}
+}
+
+func (b *SystemBackend) handleRateLimitQuotasRead() framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+		name := d.Get("name").(string)
+		qType := quotas.TypeRateLimit.String()
 
-			mountAPIPath := me.APIPathNoNamespace()
-			pathSuffix = strings.TrimSuffix(strings.TrimPrefix(mountPath, mountAPIPath), "/")
-			mountPath = mountAPIPath
+		quota, err := b.Core.quotaManager.QuotaByName(qType, name)
+		if err != nil {
+			return nil, err
+		}
+		if quota == nil {
+			return nil, nil
 		}
 
-		role := d.Get("role").(string)
-		// If this is a quota with a role, ensure the backend supports role resolution
-		if role != "" {
-			if pathSuffix != "" {
-				return logical.ErrorResponse("Quotas cannot contain both a path suffix and a role. If a role is provided, path must be a valid auth mount with a concept of roles"), nil
-			}
-			authBackend := b.Core.router.MatchingBackend(namespace.ContextWithNamespace(ctx, ns), mountPath)
-			if authBackend == nil || authBackend.Type() != logical.TypeCredential {
-				return logical.ErrorResponse("Mount path %q is not a valid auth method and therefore unsuitable for use with role-based quotas", mountPath), nil
-			}
-			// We will always error as we aren't supplying real data, but we're looking for "unsupported operation" in particular
-			_, err := authBackend.HandleRequest(ctx, &logical.Request{
-				Path:      "login",
-				Operation: logical.ResolveRoleOperation,
-			})
-			if err != nil && (err == logical.ErrUnsupportedOperation || err == logical.ErrUnsupportedPath) {
-				return logical.ErrorResponse("Mount path %q does not support use with role-based quotas", mountPath), nil
-			}
+		rlq := quota.(*quotas.RateLimitQuota)
+
+		nsPath := rlq.NamespacePath
+		if rlq.NamespacePath == "root" {
+			nsPath = ""
 		}
 
-		var inheritable bool
-		// All global quotas should be inherited by default
-		if rawPath == "" {
-			inheritable = true
+		data := map[string]interface{}{
+			"type":            qType,
+			"name":            rlq.Name,
+			"path":            nsPath + rlq.MountPath + rlq.PathSuffix,
+			"role":            rlq.Role,
+			"rate":            rlq.Rate,
+			"inheritable":     rlq.Inheritable,
+			"interval":        int(rlq.Interval.Seconds()),
+			"block_interval":  int(rlq.BlockInterval.Seconds()),
+			"key_by":          rlq.KeyBy,
+			"key_by_metadata": rlq.KeyByMetadata,
 		}
 
-		if inheritableRaw, ok := d.GetOk("inheritable"); ok {
-			inheritable = inheritableRaw.(bool)
-			if inheritable {
-				if pathSuffix != "" || role != "" || mountPath != "" {
-					return logical.ErrorResponse("only namespace quotas can be configured as inheritable"), nil
-				}
-			} else if rawPath == "" {
-				// User should not try to configure a global quota that cannot be inherited
-				return logical.ErrorResponse("all global quotas must be inheritable"), nil
-			}
+		return &logical.Response{
+			Data: data,
+		}, nil
+	}
+}
+
+func (b *SystemBackend) handleLeaseCountQuotasUpdate() framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+		name := d.Get("name").(string)
+
+		qType := quotas.TypeLeaseCount.String()
+		maxLeases := d.Get("max_leases").(int)
+		if maxLeases <= 0 {
+			return logical.ErrorResponse("'max_leases' is invalid"), nil
+		}
+
+		factors, resp, err := b.parseQuotaFactors(ctx, qType, name, d)
+		if resp != nil || err != nil {
+			return resp, err
+		}
+
+		// If a quota already exists, fetch and update it.
+		quota, err := b.Core.quotaManager.QuotaByName(qType, name)
+		if err != nil {
+			return nil, err
 		}
 
-		// User should not try to configure a global quota to be uninheritable
-		if rawPath == "" && !inheritable {
-			return logical.ErrorResponse("all global quotas must be inheritable"), nil
+		switch {
+		case quota == nil:
+			quota = quotas.NewLeaseCountQuota(name, factors.ns.Path, factors.mountPath, factors.pathSuffix, factors.role, factors.inheritable, maxLeases)
+		default:
+			// Re-inserting the already indexed object in memdb might cause problems.
+			// So, clone the object. See https://github.com/hashicorp/go-memdb/issues/76.
+			lcq := quota.Clone().(*quotas.LeaseCountQuota)
+			lcq.NamespacePath = factors.ns.Path
+			lcq.MountPath = factors.mountPath
+			lcq.PathSuffix = factors.pathSuffix
+			lcq.Role = factors.role
+			lcq.Inheritable = factors.inheritable
+			lcq.MaxLeases = maxLeases
+			quota = lcq
+		}
+		if err := b.Core.quotaManager.SetQuota(ctx, qType, quota, false); err != nil {
+			return nil, err
 		}
 
-		// Disallow creation of new quota that has properties similar to an
-		// existing quota.
-		quotaByFactors, err := b.Core.quotaManager.QuotaByFactors(ctx, qType, ns.Path, mountPath, pathSuffix, role)
+		return nil, nil
+	}
+}
+
+func (b *SystemBackend) handleLeaseCountQuotasRead() framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+		name := d.Get("name").(string)
+		qType := quotas.TypeLeaseCount.String()
+
+		quota, err := b.Core.quotaManager.QuotaByName(qType, name)
 		if err != nil {
 			return nil, err
 		}
-		if quotaByFactors != nil && quotaByFactors.QuotaName() != name {
-			return logical.ErrorResponse("quota rule with similar properties exists under the name %q", quotaByFactors.QuotaName()), nil
+		if quota == nil {
+			return nil, nil
+		}
+
+		lcq := quota.(*quotas.LeaseCountQuota)
+
+		nsPath := lcq.NamespacePath
+		if lcq.NamespacePath == "root" {
+			nsPath = ""
+		}
+
+		data := map[string]interface{}{
+			"type":        qType,
+			"name":        lcq.Name,
+			"path":        nsPath + lcq.MountPath + lcq.PathSuffix,
+			"role":        lcq.Role,
+			"max_leases":  lcq.MaxLeases,
+			"counter":     lcq.Counter(),
+			"inheritable": lcq.Inheritable,
+		}
+
+		return &logical.Response{
+			Data: data,
+		}, nil
+	}
+}
+
+func (b *SystemBackend) handleConcurrencyQuotasUpdate() framework.OperationFunc {
+	return func(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
+		name := d.Get("name").(string)
+
+		qType := quotas.TypeConcurrency.String()
+		maxConcurrent := d.Get("max_concurrent").(int)
+		if maxConcurrent <= 0 {
+			return logical.ErrorResponse("'max_concurrent' is invalid"), nil
+		}
+
+		queueTimeout := time.Second * time.Duration(d.Get("queue_timeout").(int))
+		if queueTimeout < 0 {
+			return logical.ErrorResponse("'queue_timeout' is invalid"), nil
+		}
+
+		factors, resp, err := b.parseQuotaFactors(ctx, qType, name, d)
+		if resp != nil || err != nil {
+			return resp, err
 		}
 
 		// If a quota already exists, fetch and update it.
@@ -461,20 +944,19 @@ func (b *SystemBackend) handleRateLimitQuotasUpdate() framework.OperationFunc {
 
 		switch {
 		case quota == nil:
-			quota = quotas.NewRateLimitQuota(name, ns.Path, mountPath, pathSuffix, role, inheritable, interval, blockInterval, rate)
+			quota = quotas.NewConcurrencyQuota(name, factors.ns.Path, factors.mountPath, factors.pathSuffix, factors.role, factors.inheritable, maxConcurrent, queueTimeout)
 		default:
 			// Re-inserting the already indexed object in memdb might cause problems.
 			// So, clone the object. See https://github.com/hashicorp/go-memdb/issues/76.
-			clonedQuota := quota.Clone()
-			rlq := clonedQuota.(*quotas.RateLimitQuota)
-			rlq.NamespacePath = ns.Path
-			rlq.MountPath = mountPath
-			rlq.PathSuffix = pathSuffix
-			rlq.Rate = rate
-			rlq.Inheritable = inheritable
-			rlq.Interval = interval
-			rlq.BlockInterval = blockInterval
-			quota = rlq
+			cq := quota.Clone().(*quotas.ConcurrencyQuota)
+			cq.NamespacePath = factors.ns.Path
+			cq.MountPath = factors.mountPath
+			cq.PathSuffix = factors.pathSuffix
+			cq.Role = factors.role
+			cq.Inheritable = factors.inheritable
+			cq.MaxConcurrent = maxConcurrent
+			cq.QueueTimeout = queueTimeout
+			quota = cq
 		}
 		if err := b.Core.quotaManager.SetQuota(ctx, qType, quota, false); err != nil {
 			return nil, err
@@ -484,10 +966,10 @@ func (b *SystemBackend) handleRateLimitQuotasUpdate() framework.OperationFunc {
 	}
 }
 
-func (b *SystemBackend) handleRateLimitQuotasRead() framework.OperationFunc {
+func (b *SystemBackend) handleConcurrencyQuotasRead() framework.OperationFunc {
 	return func(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
 		name := d.Get("name").(string)
-		qType := quotas.TypeRateLimit.String()
+		qType := quotas.TypeConcurrency.String()
 
 		quota, err := b.Core.quotaManager.QuotaByName(qType, name)
 		if err != nil {
@@ -497,22 +979,22 @@ func (b *SystemBackend) handleRateLimitQuotasRead() framework.OperationFunc {
 			return nil, nil
 		}
 
-		rlq := quota.(*quotas.RateLimitQuota)
+		cq := quota.(*quotas.ConcurrencyQuota)
 
-		nsPath := rlq.NamespacePath
-		if rlq.NamespacePath == "root" {
+		nsPath := cq.NamespacePath
+		if cq.NamespacePath == "root" {
 			nsPath = ""
 		}
 
 		data := map[string]interface{}{
 			"type":           qType,
-			"name":           rlq.Name,
-			"path":           nsPath + rlq.MountPath + rlq.PathSuffix,
-			"role":           rlq.Role,
-			"rate":           rlq.Rate,
-			"inheritable":    rlq.Inheritable,
-			"interval":       int(rlq.Interval.Seconds()),
-			"block_interval": int(rlq.BlockInterval.Seconds()),
+			"name":           cq.Name,
+			"path":           nsPath + cq.MountPath + cq.PathSuffix,
+			"role":           cq.Role,
+			"max_concurrent": cq.MaxConcurrent,
+			"queue_timeout":  int(cq.QueueTimeout.Seconds()),
+			"in_flight":      cq.InFlight(),
+			"inheritable":    cq.Inheritable,
 		}
 
 		return &logical.Response{
@@ -521,17 +1003,16 @@ func (b *SystemBackend) handleRateLimitQuotasRead() framework.OperationFunc {
 	}
 }
 
-func (b *SystemBackend) handleRateLimitQuotasDelete() framework.OperationFunc {
+func (b *SystemBackend) handleQuotasDelete(qType quotas.Type) framework.OperationFunc {
 	return func(ctx context.Context, req *logical.Request, d *framework.FieldData) (*logical.Response, error) {
 		name := d.Get("name").(string)
-		qType := quotas.TypeRateLimit.String()
 
 		ns, err := namespace.FromContext(ctx)
 		if err != nil {
 			return nil, err
 		}
 		if ns.ID != namespace.RootNamespaceID {
-			quota, err := b.Core.quotaManager.QuotaByName(qType, name)
+			quota, err := b.Core.quotaManager.QuotaByName(qType.String(), name)
 			if err != nil {
 				return nil, err
 			}
@@ -540,7 +1021,7 @@ func (b *SystemBackend) handleRateLimitQuotasDelete() framework.OperationFunc {
 			}
 		}
 
-		if err := b.Core.quotaManager.DeleteQuota(ctx, qType, name); err != nil {
+		if err := b.Core.quotaManager.DeleteQuota(ctx, qType.String(), name); err != nil {
 			return nil, err
 		}
 
@@ -559,10 +1040,36 @@ mount.`,
 		`A rate limit quota will enforce API rate limiting in a specified interval. A
 rate limit quota can be created at the root level or defined on a namespace or
 mount by specifying a 'path'. The rate limiter is applied to each unique client
-IP address.`,
+IP address, or when 'key_by' is set, to each unique client identity.`,
 	},
 	"rate-limit-list": {
 		"Lists the names of all the rate limit quotas.",
 		"This list contains quota definitions from all the namespaces.",
 	},
+	"lease-count": {
+		`Get, create or update lease count resource quota for an optional namespace,
+mount or path.`,
+		`A lease count quota limits the number of leases which may exist in a specified
+scope. A lease count quota can be created at the root level or defined on a
+namespace or mount by specifying a 'path'. Once the limit is reached, requests
+which would generate a lease are rejected until existing leases are revoked or
+expire.`,
+	},
+	"lease-count-list": {
+		"Lists the names of all the lease count quotas.",
+		"This list contains quota definitions from all the namespaces.",
+	},
+	"concurrency": {
+		`Get, create or update concurrency resource quota for an optional namespace,
+mount or path.`,
+		`A concurrency quota limits the number of requests which may be in flight at
+the same time in a specified scope. A concurrency quota can be created at the
+root level or defined on a namespace or mount by specifying a 'path'. Once the
+limit is reached, requests wait for up to 'queue_timeout' for an in flight
+request to complete before they are rejected.`,
+	},
+	"concurrency-list": {
+		"Lists the names of all the concurrency quotas.",
+		"This list contains quota definitions from all the namespaces.",
+	},
 }
@@ -28,6 +28,9 @@ const (
 
 	// TypeLeaseCount represents the lease count limiting quota type
 	TypeLeaseCount Type = "lease-count"
+
+	// TypeConcurrency represents the concurrent request limiting quota type
+	TypeConcurrency Type = "concurrency"
 )
 
 // LeaseAction is the action taken by the expiration manager on the lease. The
@@ -79,6 +82,8 @@ func (q Type) String() string {
 		return "lease-count"
 	case TypeRateLimit:
 		return "rate-limit"
+	case TypeConcurrency:
+		return "concurrency"
 	}
 	return "unknown"
 }
@@ -117,6 +122,10 @@ var (
 	// ErrRateLimitQuotaExceeded is returned when a request is rejected due to a
 	// rate limit quota being exceeded.
 	ErrRateLimitQuotaExceeded = errors.New("rate limit quota exceeded")
+
+	// ErrConcurrencyQuotaExceeded is returned when a request is rejected due to
+	// a concurrency quota being exceeded.
+	ErrConcurrencyQuotaExceeded = errors.New("concurrency quota exceeded")
 )
 
 var defaultExemptPaths = []string{
@@ -282,6 +291,13 @@ type Request struct {
 	// ClientAddress is client unique addressable string (e.g. IP address). It can
 	// be empty if the quota type does not need it.
 	ClientAddress string
+
+	// EntityID, TokenAccessor and TokenMetadata describe the client token. They
+	// are only populated when a rate limit quota keyed on the identity of the
+	// client applies to the request.
+	EntityID      string
+	TokenAccessor string
+	TokenMetadata map[string]string
 }
 
 // NewManager creates and initializes a new quota manager to hold all the quota
@@ -682,6 +698,22 @@ func (m *Manager) QueryResolveRoleQuotas(req *Request) (bool, error) {
 	return false, nil
 }
 
+// QueryResolveIdentityQuotas checks if the rate limit quota which applies to
+// the request is keyed on the identity of the client, which requires the client
+// token to be resolved.
+func (m *Manager) QueryResolveIdentityQuotas(req *Request) (bool, error) {
+	rlqReq := *req
+	rlqReq.Type = TypeRateLimit
+
+	quota, err := m.QueryQuota(&rlqReq)
+	if err != nil {
+		return false, err
+	}
+
+	rlq, ok := quota.(*RateLimitQuota)
+	return ok && rlq.keyedOnIdentity(), nil
+}
+
 // DeleteQuota removes a quota rule the QuotaManager's storage view and then
 // updates the associated index in memdb.
 func (m *Manager) DeleteQuota(ctx context.Context, qType string, name string) error {
@@ -1132,6 +1164,8 @@ func Load(ctx context.Context, storage logical.Storage, qType, name string) (Quo
 		quota = &RateLimitQuota{}
 	case TypeLeaseCount.String():
 		quota = &LeaseCountQuota{}
+	case TypeConcurrency.String():
+		quota = &ConcurrencyQuota{}
 	default:
 		return nil, fmt.Errorf("unsupported type: %v", qType)
 	}
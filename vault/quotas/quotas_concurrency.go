@@ -0,0 +1,340 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package quotas
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/armon/go-metrics"
+	log "github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/go-uuid"
+	"github.com/hashicorp/vault/helper/metricsutil"
+	"github.com/hashicorp/vault/sdk/helper/cryptoutil"
+)
+
+// Ensure that ConcurrencyQuota implements the Quota interface
+var _ Quota = (*ConcurrencyQuota)(nil)
+
+// Ensure that concurrencyAccess implements the Access interface
+var _ Access = (*concurrencyAccess)(nil)
+
+// ConcurrencyQuota represents the quota rule properties that is used to limit
+// the number of requests which may be in flight at the same time for a
+// namespace, mount, path or login role.
+type ConcurrencyQuota struct {
+	// ID is the identifier of the quota
+	ID string `json:"id"`
+
+	// Type of quota this represents
+	Type Type `json:"type"`
+
+	// Name of the quota rule
+	Name string `json:"name"`
+
+	// NamespacePath is the path of the namespace to which this quota is
+	// applicable.
+	NamespacePath string `json:"namespace_path"`
+
+	// MountPath is the path of the mount to which this quota is applicable
+	MountPath string `json:"mount_path"`
+
+	// Role is the role on an auth mount to apply the quota to upon /login requests
+	// Not applicable for use with path suffixes
+	Role string `json:"role"`
+
+	// PathSuffix is the path suffix to which this quota is applicable
+	PathSuffix string `json:"path_suffix"`
+
+	// Inheritable indicates whether the quota will be inherited by child namespaces
+	Inheritable bool `json:"inheritable"`
+
+	// MaxConcurrent is the maximum number of requests allowed to be in flight
+	// at the same time by the quota rule.
+	MaxConcurrent int `json:"max_concurrent"`
+
+	// QueueTimeout is the duration a request waits for an in flight request to
+	// complete, once MaxConcurrent is reached, before it is rejected. When zero,
+	// requests are rejected immediately.
+	QueueTimeout time.Duration `json:"queue_timeout"`
+
+	// limiter tracks the requests which are in flight. It is shared with the
+	// clones used to update the quota, so that requests which are in flight
+	// when the quota is updated are still counted.
+	limiter *concurrencyLimiter
+
+	logger     log.Logger
+	metricSink *metricsutil.ClusterMetricSink
+}
+
+// concurrencyAccess is returned by a ConcurrencyQuota when a request is allowed.
+// Releasing the access frees the slot held by the request.
+type concurrencyAccess struct {
+	quota   *ConcurrencyQuota
+	limiter *concurrencyLimiter
+	once    sync.Once
+}
+
+// QuotaID returns the identifier of the quota rule that allowed the request.
+func (a *concurrencyAccess) QuotaID() string {
+	return a.quota.ID
+}
+
+// release frees the slot held by the request. It is safe to call release more
+// than once.
+func (a *concurrencyAccess) release() {
+	a.once.Do(func() {
+		a.limiter.release()
+		a.quota.emitInFlight()
+	})
+}
+
+// NewConcurrencyQuota creates a quota checker for imposing limits on the number
+// of requests in flight at the same time for a namespace, mount, path or login
+// role. A queue timeout of zero rejects requests as soon as the limit is reached.
+func NewConcurrencyQuota(name, nsPath, mountPath, pathSuffix, role string, inheritable bool, maxConcurrent int, queueTimeout time.Duration) *ConcurrencyQuota {
+	id, err := uuid.GenerateUUID()
+	if err != nil {
+		// Fall back to generating with a hash of the name, later in initialize
+		id = ""
+	}
+	return &ConcurrencyQuota{
+		Name:          name,
+		ID:            id,
+		Type:          TypeConcurrency,
+		NamespacePath: nsPath,
+		MountPath:     mountPath,
+		Role:          role,
+		PathSuffix:    pathSuffix,
+		Inheritable:   inheritable,
+		MaxConcurrent: maxConcurrent,
+		QueueTimeout:  queueTimeout,
+	}
+}
+
+func (cq *ConcurrencyQuota) Clone() Quota {
+	return &ConcurrencyQuota{
+		ID:            cq.ID,
+		Name:          cq.Name,
+		MountPath:     cq.MountPath,
+		Role:          cq.Role,
+		Inheritable:   cq.Inheritable,
+		Type:          cq.Type,
+		NamespacePath: cq.NamespacePath,
+		PathSuffix:    cq.PathSuffix,
+		MaxConcurrent: cq.MaxConcurrent,
+		QueueTimeout:  cq.QueueTimeout,
+		limiter:       cq.limiter,
+	}
+}
+
+func (cq *ConcurrencyQuota) GetNamespacePath() string {
+	return cq.NamespacePath
+}
+
+func (cq *ConcurrencyQuota) IsInheritable() bool {
+	return cq.Inheritable
+}
+
+// InFlight returns the number of requests allowed by the quota which have not
+// yet completed.
+func (cq *ConcurrencyQuota) InFlight() int {
+	if cq.limiter == nil {
+		return 0
+	}
+
+	return cq.limiter.inFlightCount()
+}
+
+// initialize ensures the namespace, max concurrent requests and queue timeout
+// are valid, sets the ID if it's currently empty, and sets the limit on in flight
+// requests. Requests which are already in flight continue to be counted when
+// the limit of an existing quota is changed.
+func (cq *ConcurrencyQuota) initialize(logger log.Logger, ms *metricsutil.ClusterMetricSink) error {
+	// Memdb requires a non-empty value for indexing
+	if cq.NamespacePath == "" {
+		cq.NamespacePath = "root"
+	}
+
+	if cq.MaxConcurrent <= 0 {
+		return fmt.Errorf("invalid max concurrent requests: %v", cq.MaxConcurrent)
+	}
+
+	if cq.QueueTimeout < 0 {
+		return fmt.Errorf("invalid queue timeout: %v", cq.QueueTimeout)
+	}
+
+	if logger != nil {
+		cq.logger = logger
+	}
+
+	if cq.metricSink == nil {
+		cq.metricSink = ms
+	}
+
+	if cq.ID == "" {
+		// Generate a deterministic ID, so that performance standby nodes
+		// initializing their copy of the quota agree on its identity.
+		cq.ID = hex.EncodeToString(cryptoutil.Blake2b256Hash(cq.Name))
+	}
+
+	if cq.limiter == nil {
+		cq.limiter = newConcurrencyLimiter()
+	}
+	cq.limiter.resize(cq.MaxConcurrent)
+
+	if cq.metricSink != nil {
+		cq.metricSink.SetGaugeWithLabels([]string{"quota", "concurrency", "max"}, float32(cq.MaxConcurrent), []metrics.Label{{Name: "name", Value: cq.Name}})
+	}
+
+	return nil
+}
+
+// quotaID returns the identifier of the quota rule
+func (cq *ConcurrencyQuota) quotaID() string {
+	return cq.ID
+}
+
+// QuotaName returns the name of the quota rule
+func (cq *ConcurrencyQuota) QuotaName() string {
+	return cq.Name
+}
+
+// allow decides if the request is allowed by the quota. A request is allowed
+// when fewer than the maximum number of requests are in flight. Otherwise, it
+// waits for up to the queue timeout for an in flight request to complete. The
+// request is rejected if the wait times out or the request context is done. An
+// allowed request holds its slot until the returned access is released.
+func (cq *ConcurrencyQuota) allow(ctx context.Context, _ *Request) (Response, error) {
+	var resp Response
+
+	resp.Allowed = cq.limiter.acquire(ctx, cq.QueueTimeout)
+
+	if !resp.Allowed {
+		if cq.metricSink != nil {
+			cq.metricSink.IncrCounterWithLabels([]string{"quota", "concurrency", "violation"}, 1, []metrics.Label{{Name: "name", Value: cq.Name}})
+		}
+		return resp, nil
+	}
+
+	cq.emitInFlight()
+	resp.Access = &concurrencyAccess{quota: cq, limiter: cq.limiter}
+
+	return resp, nil
+}
+
+func (cq *ConcurrencyQuota) emitInFlight() {
+	if cq.metricSink != nil {
+		cq.metricSink.SetGaugeWithLabels([]string{"quota", "concurrency", "in_flight"}, float32(cq.InFlight()), []metrics.Label{{Name: "name", Value: cq.Name}})
+	}
+}
+
+// concurrencyLimiter limits the number of requests in flight. Unlike a buffered
+// channel, the limit can be changed while requests are in flight.
+type concurrencyLimiter struct {
+	lock     sync.Mutex
+	max      int
+	inFlight int
+
+	// released is closed, and replaced, whenever a slot may have become free.
+	released chan struct{}
+}
+
+func newConcurrencyLimiter() *concurrencyLimiter {
+	return &concurrencyLimiter{released: make(chan struct{})}
+}
+
+// acquire takes a slot, waiting for up to the timeout for one to become free.
+// It returns false if no slot became free, or the context is done.
+func (l *concurrencyLimiter) acquire(ctx context.Context, timeout time.Duration) bool {
+	var timer *time.Timer
+	for {
+		l.lock.Lock()
+		if l.inFlight < l.max {
+			l.inFlight++
+			l.lock.Unlock()
+			return true
+		}
+		released := l.released
+		l.lock.Unlock()
+
+		if timeout <= 0 {
+			return false
+		}
+
+		if timer == nil {
+			timer = time.NewTimer(timeout)
+			defer timer.Stop()
+		}
+
+		select {
+		case <-released:
+		case <-timer.C:
+			return false
+		case <-ctx.Done():
+			return false
+		}
+	}
+}
+
+// release frees a slot, waking any requests which are waiting for one.
+func (l *concurrencyLimiter) release() {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.inFlight--
+	l.notifyLocked()
+}
+
+// resize changes the maximum number of slots. When the maximum is reduced below
+// the number of requests in flight, new requests wait until enough of them
+// have completed.
+func (l *concurrencyLimiter) resize(size int) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	if size > l.max {
+		l.notifyLocked()
+	}
+	l.max = size
+}
+
+func (l *concurrencyLimiter) inFlightCount() int {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	return l.inFlight
+}
+
+func (l *concurrencyLimiter) notifyLocked() {
+	close(l.released)
+	l.released = make(chan struct{})
+}
+
+// close is a no-op for concurrency quotas. Requests which are in flight release
+// their slots as they complete.
+func (cq *ConcurrencyQuota) close(_ context.Context) error {
+	return nil
+}
+
+func (cq *ConcurrencyQuota) handleRemount(mountPath, nsPath string) {
+	cq.MountPath = mountPath
+	cq.NamespacePath = nsPath
+}
+
+// ReleaseConcurrencyQuota releases the slot held by a request which was allowed
+// by a concurrency quota, once the request has completed.
+func (m *Manager) ReleaseConcurrencyQuota(access Access) error {
+	ca, ok := access.(*concurrencyAccess)
+	if !ok {
+		return fmt.Errorf("unsupported quota access type: %T", access)
+	}
+
+	ca.release()
+
+	return nil
+}
@@ -0,0 +1,234 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+package quotas
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	log "github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/vault/helper/metricsutil"
+	"github.com/hashicorp/vault/sdk/helper/logging"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewConcurrencyQuota(t *testing.T) {
+	testCases := []struct {
+		name      string
+		cq        *ConcurrencyQuota
+		expectErr string
+	}{
+		{"valid", NewConcurrencyQuota("test", "", "", "", "", true, 10, time.Second), ""},
+		{"no queue timeout", NewConcurrencyQuota("test", "", "", "", "", true, 10, 0), ""},
+		{"invalid max concurrent", NewConcurrencyQuota("test", "", "", "", "", true, 0, time.Second), "invalid max concurrent requests: 0"},
+		{"invalid queue timeout", NewConcurrencyQuota("test", "", "", "", "", true, 1, -time.Second), "invalid queue timeout: -1s"},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.cq.initialize(logging.NewVaultLogger(log.Trace), metricsutil.BlackholeSink())
+			if tc.expectErr != "" {
+				require.EqualError(t, err, tc.expectErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, "root", tc.cq.NamespacePath)
+			require.NotEmpty(t, tc.cq.ID)
+			require.Equal(t, 0, tc.cq.InFlight())
+		})
+	}
+}
+
+// TestConcurrencyQuota_Allow ensures that requests are rejected once the
+// maximum number of requests are in flight, and that releasing a request frees
+// its slot exactly once.
+func TestConcurrencyQuota_Allow(t *testing.T) {
+	cq := NewConcurrencyQuota("test", "", "", "", "", true, 2, 0)
+	require.NoError(t, cq.initialize(logging.NewVaultLogger(log.Trace), metricsutil.BlackholeSink()))
+
+	qm := &Manager{}
+
+	first, err := cq.allow(context.Background(), &Request{})
+	require.NoError(t, err)
+	require.True(t, first.Allowed)
+	require.Equal(t, cq.ID, first.Access.QuotaID())
+
+	second, err := cq.allow(context.Background(), &Request{})
+	require.NoError(t, err)
+	require.True(t, second.Allowed)
+	require.Equal(t, 2, cq.InFlight())
+
+	rejected, err := cq.allow(context.Background(), &Request{})
+	require.NoError(t, err)
+	require.False(t, rejected.Allowed)
+	require.Nil(t, rejected.Access)
+
+	// Releasing the same request more than once only frees one slot.
+	require.NoError(t, qm.ReleaseConcurrencyQuota(first.Access))
+	require.NoError(t, qm.ReleaseConcurrencyQuota(first.Access))
+	require.Equal(t, 1, cq.InFlight())
+
+	resp, err := cq.allow(context.Background(), &Request{})
+	require.NoError(t, err)
+	require.True(t, resp.Allowed)
+
+	require.NoError(t, qm.ReleaseConcurrencyQuota(second.Access))
+	require.NoError(t, qm.ReleaseConcurrencyQuota(resp.Access))
+	require.Equal(t, 0, cq.InFlight())
+
+	require.EqualError(t, qm.ReleaseConcurrencyQuota(&access{}), "unsupported quota access type: *quotas.access")
+}
+
+// TestConcurrencyQuota_QueueTimeout ensures that requests wait for a slot for
+// up to the queue timeout, and stop waiting when their context is done.
+func TestConcurrencyQuota_QueueTimeout(t *testing.T) {
+	cq := NewConcurrencyQuota("test", "", "", "", "", true, 1, 100*time.Millisecond)
+	require.NoError(t, cq.initialize(logging.NewVaultLogger(log.Trace), metricsutil.BlackholeSink()))
+
+	qm := &Manager{}
+
+	held, err := cq.allow(context.Background(), &Request{})
+	require.NoError(t, err)
+	require.True(t, held.Allowed)
+
+	// Nothing is released, so the request times out.
+	start := time.Now()
+	resp, err := cq.allow(context.Background(), &Request{})
+	require.NoError(t, err)
+	require.False(t, resp.Allowed)
+	require.GreaterOrEqual(t, time.Since(start), cq.QueueTimeout)
+
+	// A request whose context is done stops waiting.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	resp, err = cq.allow(ctx, &Request{})
+	require.NoError(t, err)
+	require.False(t, resp.Allowed)
+
+	// A queued request is allowed once the slot is released.
+	cq.QueueTimeout = 5 * time.Second
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		qm.ReleaseConcurrencyQuota(held.Access)
+	}()
+	resp, err = cq.allow(context.Background(), &Request{})
+	require.NoError(t, err)
+	require.True(t, resp.Allowed)
+	require.NoError(t, qm.ReleaseConcurrencyQuota(resp.Access))
+}
+
+// TestQuotas_Concurrency ensures that the quota manager applies the most
+// specific concurrency quota to a request.
+func TestQuotas_Concurrency(t *testing.T) {
+	qm, err := NewManager(logging.NewVaultLogger(log.Trace), nil, metricsutil.BlackholeSink(), true)
+	require.NoError(t, err)
+	require.NoError(t, qm.Setup(context.Background(), &logical.InmemStorage{}, nil))
+
+	global := NewConcurrencyQuota("global", "", "", "", "", true, 10, 0)
+	require.NoError(t, qm.SetQuota(context.Background(), TypeConcurrency.String(), global, false))
+
+	creds := NewConcurrencyQuota("creds", "", "database/", "creds/app", "", false, 1, 0)
+	require.NoError(t, qm.SetQuota(context.Background(), TypeConcurrency.String(), creds, false))
+
+	req := &Request{
+		Type:      TypeConcurrency,
+		Path:      "database/creds/app",
+		MountPath: "database/",
+	}
+
+	resp, err := qm.ApplyQuota(context.Background(), req)
+	require.NoError(t, err)
+	require.True(t, resp.Allowed)
+	require.Equal(t, creds.ID, resp.Access.QuotaID())
+
+	rejected, err := qm.ApplyQuota(context.Background(), req)
+	require.NoError(t, err)
+	require.False(t, rejected.Allowed)
+
+	// Other paths on the mount are only subject to the global quota.
+	other, err := qm.ApplyQuota(context.Background(), &Request{
+		Type:      TypeConcurrency,
+		Path:      "database/creds/other",
+		MountPath: "database/",
+	})
+	require.NoError(t, err)
+	require.True(t, other.Allowed)
+	require.Equal(t, global.ID, other.Access.QuotaID())
+	require.NoError(t, qm.ReleaseConcurrencyQuota(other.Access))
+
+	require.NoError(t, qm.ReleaseConcurrencyQuota(resp.Access))
+	resp, err = qm.ApplyQuota(context.Background(), req)
+	require.NoError(t, err)
+	require.True(t, resp.Allowed)
+	require.NoError(t, qm.ReleaseConcurrencyQuota(resp.Access))
+
+	quota, err := Load(context.Background(), qm.storage, TypeConcurrency.String(), "creds")
+	require.NoError(t, err)
+	require.Equal(t, creds.MaxConcurrent, quota.(*ConcurrencyQuota).MaxConcurrent)
+}
+
+// TestQuotas_Concurrency_Update ensures that requests which are in flight when
+// a concurrency quota is updated are still counted by the updated quota, and
+// that raising the limit wakes requests which are waiting for a slot.
+func TestQuotas_Concurrency_Update(t *testing.T) {
+	qm, err := NewManager(logging.NewVaultLogger(log.Trace), nil, metricsutil.BlackholeSink(), true)
+	require.NoError(t, err)
+	require.NoError(t, qm.Setup(context.Background(), &logical.InmemStorage{}, nil))
+
+	cq := NewConcurrencyQuota("test", "", "", "", "", true, 2, 0)
+	require.NoError(t, qm.SetQuota(context.Background(), TypeConcurrency.String(), cq, false))
+
+	req := &Request{Type: TypeConcurrency, Path: "secret/foo"}
+	var held []Access
+	for i := 0; i < 2; i++ {
+		resp, err := qm.ApplyQuota(context.Background(), req)
+		require.NoError(t, err)
+		require.True(t, resp.Allowed)
+		held = append(held, resp.Access)
+	}
+
+	// Lowering the limit keeps counting the requests which are in flight.
+	updated := cq.Clone().(*ConcurrencyQuota)
+	updated.MaxConcurrent = 1
+	updated.QueueTimeout = 5 * time.Second
+	require.NoError(t, qm.SetQuota(context.Background(), TypeConcurrency.String(), updated, false))
+	require.Equal(t, 2, updated.InFlight())
+
+	// Releasing one request, which was allowed by the previous version of the
+	// quota, still leaves the updated quota full.
+	require.NoError(t, qm.ReleaseConcurrencyQuota(held[0]))
+	require.Equal(t, 1, updated.InFlight())
+
+	done := make(chan Response)
+	go func() {
+		resp, _ := qm.ApplyQuota(context.Background(), req)
+		done <- resp
+	}()
+
+	select {
+	case <-done:
+		t.Fatal("request allowed while the quota is full")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// Raising the limit allows the waiting request.
+	raised := updated.Clone().(*ConcurrencyQuota)
+	raised.MaxConcurrent = 2
+	require.NoError(t, qm.SetQuota(context.Background(), TypeConcurrency.String(), raised, false))
+
+	select {
+	case resp := <-done:
+		require.True(t, resp.Allowed)
+		require.NoError(t, qm.ReleaseConcurrencyQuota(resp.Access))
+	case <-time.After(5 * time.Second):
+		t.Fatal("waiting request was not allowed after the limit was raised")
+	}
+
+	require.NoError(t, qm.ReleaseConcurrencyQuota(held[1]))
+	require.Equal(t, 0, raised.InFlight())
+}
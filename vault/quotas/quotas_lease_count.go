@@ -0,0 +1,298 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+//go:build !enterprise
+
+package quotas
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/armon/go-metrics"
+	log "github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/go-uuid"
+	"github.com/hashicorp/vault/helper/metricsutil"
+	"github.com/hashicorp/vault/sdk/helper/cryptoutil"
+)
+
+// Ensure that LeaseCountQuota implements the Quota interface
+var _ Quota = (*LeaseCountQuota)(nil)
+
+// Ensure that leaseCountAccess implements the Access interface
+var _ Access = (*leaseCountAccess)(nil)
+
+// LeaseCountQuota represents the quota rule properties that is used to limit the
+// number of leases for a namespace, mount, path or login role.
+type LeaseCountQuota struct {
+	// ID is the identifier of the quota
+	ID string `json:"id"`
+
+	// Type of quota this represents
+	Type Type `json:"type"`
+
+	// Name of the quota rule
+	Name string `json:"name"`
+
+	// NamespacePath is the path of the namespace to which this quota is
+	// applicable.
+	NamespacePath string `json:"namespace_path"`
+
+	// MountPath is the path of the mount to which this quota is applicable
+	MountPath string `json:"mount_path"`
+
+	// Role is the role on an auth mount to apply the quota to upon /login requests
+	// Not applicable for use with path suffixes
+	Role string `json:"role"`
+
+	// PathSuffix is the path suffix to which this quota is applicable
+	PathSuffix string `json:"path_suffix"`
+
+	// Inheritable indicates whether the quota will be inherited by child namespaces
+	Inheritable bool `json:"inheritable"`
+
+	// MaxLeases is the maximum number of leases allowed by the quota rule.
+	MaxLeases int `json:"max_leases"`
+
+	// counter is the number of existing leases that are subject to the quota.
+	counter atomic.Int64
+
+	// inFlight is the number of requests which have been allowed by the quota,
+	// and may generate a lease, but which have not yet completed. These are
+	// counted towards MaxLeases so that concurrent requests cannot exceed it.
+	inFlight atomic.Int64
+
+	logger     log.Logger
+	metricSink *metricsutil.ClusterMetricSink
+}
+
+// leaseCountAccess is returned by a LeaseCountQuota when a request is allowed.
+// Acknowledging the access removes the request from the in flight count of the
+// quota which allowed it.
+type leaseCountAccess struct {
+	quota *LeaseCountQuota
+	once  sync.Once
+}
+
+// QuotaID returns the identifier of the quota rule that allowed the request.
+func (a *leaseCountAccess) QuotaID() string {
+	return a.quota.ID
+}
+
+// release removes the request from the in flight count of the quota. It is safe
+// to call release more than once.
+func (a *leaseCountAccess) release() {
+	a.once.Do(func() {
+		a.quota.inFlight.Add(-1)
+	})
+}
+
+// NewLeaseCountQuota creates a quota checker for imposing limits on the number
+// of leases that may exist for a namespace, mount, path or login role.
+func NewLeaseCountQuota(name, nsPath, mountPath, pathSuffix, role string, inheritable bool, maxLeases int) *LeaseCountQuota {
+	id, err := uuid.GenerateUUID()
+	if err != nil {
+		// Fall back to generating with a hash of the name, later in initialize
+		id = ""
+	}
+	return &LeaseCountQuota{
+		Name:          name,
+		ID:            id,
+		Type:          TypeLeaseCount,
+		NamespacePath: nsPath,
+		MountPath:     mountPath,
+		Role:          role,
+		PathSuffix:    pathSuffix,
+		Inheritable:   inheritable,
+		MaxLeases:     maxLeases,
+	}
+}
+
+func (lcq *LeaseCountQuota) Clone() Quota {
+	return &LeaseCountQuota{
+		ID:            lcq.ID,
+		Name:          lcq.Name,
+		MountPath:     lcq.MountPath,
+		Role:          lcq.Role,
+		Inheritable:   lcq.Inheritable,
+		Type:          lcq.Type,
+		NamespacePath: lcq.NamespacePath,
+		PathSuffix:    lcq.PathSuffix,
+		MaxLeases:     lcq.MaxLeases,
+	}
+}
+
+func (lcq *LeaseCountQuota) GetNamespacePath() string {
+	return lcq.NamespacePath
+}
+
+func (lcq *LeaseCountQuota) IsInheritable() bool {
+	return lcq.Inheritable
+}
+
+// Counter returns the number of existing leases that are subject to the quota.
+func (lcq *LeaseCountQuota) Counter() int64 {
+	return lcq.counter.Load()
+}
+
+// initialize ensures the namespace and max leases are valid, and sets the ID
+// if it's currently empty. Lease counts are not modified, they are populated by
+// the quota manager.
+func (lcq *LeaseCountQuota) initialize(logger log.Logger, ms *metricsutil.ClusterMetricSink) error {
+	// Memdb requires a non-empty value for indexing
+	if lcq.NamespacePath == "" {
+		lcq.NamespacePath = "root"
+	}
+
+	if lcq.MaxLeases <= 0 {
+		return fmt.Errorf("invalid max leases: %v", lcq.MaxLeases)
+	}
+
+	if logger != nil {
+		lcq.logger = logger
+	}
+
+	if lcq.metricSink == nil {
+		lcq.metricSink = ms
+	}
+
+	if lcq.ID == "" {
+		// Generate a deterministic ID, so that performance standby nodes
+		// initializing their copy of the quota agree on its identity.
+		lcq.ID = hex.EncodeToString(cryptoutil.Blake2b256Hash(lcq.Name))
+	}
+
+	if lcq.metricSink != nil {
+		lcq.metricSink.SetGaugeWithLabels([]string{"quota", "lease_count", "max"}, float32(lcq.MaxLeases), []metrics.Label{{Name: "name", Value: lcq.Name}})
+	}
+
+	return nil
+}
+
+// quotaID returns the identifier of the quota rule
+func (lcq *LeaseCountQuota) quotaID() string {
+	return lcq.ID
+}
+
+// QuotaName returns the name of the quota rule
+func (lcq *LeaseCountQuota) QuotaName() string {
+	return lcq.Name
+}
+
+// allow decides if the request is allowed by the quota. A request is allowed
+// when the existing leases, along with those which may be generated by requests
+// already in flight, are fewer than the maximum. An allowed request is counted
+// as in flight until the returned access is acknowledged.
+func (lcq *LeaseCountQuota) allow(_ context.Context, _ *Request) (Response, error) {
+	var resp Response
+
+	inFlight := lcq.inFlight.Add(1)
+	if lcq.counter.Load()+inFlight > int64(lcq.MaxLeases) {
+		lcq.inFlight.Add(-1)
+		if lcq.metricSink != nil {
+			lcq.metricSink.IncrCounterWithLabels([]string{"quota", "lease_count", "violation"}, 1, []metrics.Label{{Name: "name", Value: lcq.Name}})
+		}
+		return resp, nil
+	}
+
+	resp.Allowed = true
+	resp.Access = &leaseCountAccess{quota: lcq}
+
+	return resp, nil
+}
+
+// increment records that a lease subject to the quota has been created.
+func (lcq *LeaseCountQuota) increment() {
+	lcq.emitCounter(lcq.counter.Add(1))
+}
+
+// decrement records that a lease subject to the quota has been removed. The
+// counter is never allowed to drop below zero, since a lease that was created
+// before the counter was last computed may be removed.
+func (lcq *LeaseCountQuota) decrement() {
+	for {
+		current := lcq.counter.Load()
+		if current <= 0 {
+			return
+		}
+		if lcq.counter.CompareAndSwap(current, current-1) {
+			lcq.emitCounter(current - 1)
+			return
+		}
+	}
+}
+
+// resetCounter sets the number of existing leases to zero, ahead of the quota
+// manager recomputing it.
+func (lcq *LeaseCountQuota) resetCounter() {
+	lcq.counter.Store(0)
+}
+
+func (lcq *LeaseCountQuota) emitCounter(count int64) {
+	if lcq.metricSink != nil {
+		lcq.metricSink.SetGaugeWithLabels([]string{"quota", "lease_count", "counter"}, float32(count), []metrics.Label{{Name: "name", Value: lcq.Name}})
+	}
+}
+
+// close is a no-op for lease count quotas, there are no resources to clean up.
+func (lcq *LeaseCountQuota) close(_ context.Context) error {
+	return nil
+}
+
+func (lcq *LeaseCountQuota) handleRemount(mountPath, nsPath string) {
+	lcq.MountPath = mountPath
+	lcq.NamespacePath = nsPath
+}
+
+// HandleLeaseAction updates the lease count quotas that apply to each of the
+// requests, which describe leases that were created, loaded or deleted. Leases
+// which are created or loaded also mark their path as one which generates leases.
+func (m *Manager) HandleLeaseAction(action LeaseAction, reqs []*Request) error {
+	m.dbAndCacheLock.RLock()
+	defer m.dbAndCacheLock.RUnlock()
+
+	txn := m.db.Txn(false)
+
+	for _, req := range reqs {
+		req.Type = TypeLeaseCount
+
+		if action == LeaseActionCreated || action == LeaseActionLoaded {
+			m.addLeasePath(req.Path)
+		}
+
+		quota, err := m.queryQuota(txn, req)
+		if err != nil {
+			return err
+		}
+		if quota == nil {
+			continue
+		}
+
+		lcq := quota.(*LeaseCountQuota)
+		switch action {
+		case LeaseActionCreated, LeaseActionLoaded:
+			lcq.increment()
+		case LeaseActionDeleted:
+			lcq.decrement()
+		}
+	}
+
+	return nil
+}
+
+// AckLeaseQuota acknowledges that a request which was allowed by a lease count
+// quota has completed. Any lease it generated has already been counted, so the
+// request is no longer counted as in flight.
+func (m *Manager) AckLeaseQuota(access Access, _ bool) error {
+	lca, ok := access.(*leaseCountAccess)
+	if !ok {
+		return fmt.Errorf("unsupported quota access type: %T", access)
+	}
+
+	lca.release()
+
+	return nil
+}
@@ -0,0 +1,172 @@
+// Copyright (c) HashiCorp, Inc.
+// SPDX-License-Identifier: BUSL-1.1
+
+//go:build !enterprise
+
+package quotas
+
+import (
+	"context"
+	"testing"
+
+	log "github.com/hashicorp/go-hclog"
+	lru "github.com/hashicorp/golang-lru/v2"
+	"github.com/hashicorp/vault/helper/metricsutil"
+	"github.com/hashicorp/vault/sdk/helper/logging"
+	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewLeaseCountQuota(t *testing.T) {
+	lcq := NewLeaseCountQuota("test", "", "", "", "", true, 0)
+	require.EqualError(t, lcq.initialize(logging.NewVaultLogger(log.Trace), metricsutil.BlackholeSink()), "invalid max leases: 0")
+
+	lcq = NewLeaseCountQuota("test", "", "", "", "", true, 10)
+	require.NoError(t, lcq.initialize(logging.NewVaultLogger(log.Trace), metricsutil.BlackholeSink()))
+	require.Equal(t, "root", lcq.NamespacePath)
+	require.NotEmpty(t, lcq.ID)
+}
+
+// TestLeaseCountQuota_Allow ensures that requests are rejected once the existing
+// leases, along with requests which are in flight, reach the maximum.
+func TestLeaseCountQuota_Allow(t *testing.T) {
+	lcq := NewLeaseCountQuota("test", "", "", "", "", true, 2)
+	require.NoError(t, lcq.initialize(logging.NewVaultLogger(log.Trace), metricsutil.BlackholeSink()))
+
+	lcq.increment()
+
+	resp, err := lcq.allow(context.Background(), &Request{})
+	require.NoError(t, err)
+	require.True(t, resp.Allowed)
+	require.Equal(t, lcq.ID, resp.Access.QuotaID())
+
+	// The first request is still in flight, so the next one must be rejected.
+	rejected, err := lcq.allow(context.Background(), &Request{})
+	require.NoError(t, err)
+	require.False(t, rejected.Allowed)
+	require.Nil(t, rejected.Access)
+
+	// Acknowledging the request more than once only releases it once.
+	qm := &Manager{}
+	require.NoError(t, qm.AckLeaseQuota(resp.Access, false))
+	require.NoError(t, qm.AckLeaseQuota(resp.Access, false))
+	require.Equal(t, int64(0), lcq.inFlight.Load())
+
+	resp, err = lcq.allow(context.Background(), &Request{})
+	require.NoError(t, err)
+	require.True(t, resp.Allowed)
+	require.NoError(t, qm.AckLeaseQuota(resp.Access, true))
+
+	// Once the lease from the request has been counted, the quota is full.
+	lcq.increment()
+	resp, err = lcq.allow(context.Background(), &Request{})
+	require.NoError(t, err)
+	require.False(t, resp.Allowed)
+
+	lcq.decrement()
+	lcq.decrement()
+	lcq.decrement()
+	require.Equal(t, int64(0), lcq.Counter())
+}
+
+// TestQuotas_LeaseCount ensures that lease count quotas are populated from the
+// existing leases when they are created, that lease actions update the most
+// specific quota, and that only paths which generate leases are limited.
+func TestQuotas_LeaseCount(t *testing.T) {
+	leases := []*Request{
+		{Path: "database/creds/ci", MountPath: "database/"},
+		{Path: "database/creds/ci", MountPath: "database/"},
+		{Path: "database/creds/app", MountPath: "database/"},
+		{Path: "auth/userpass/login/foo", MountPath: "auth/userpass/"},
+	}
+
+	walkFunc := func(_ context.Context, callback func(*Request) bool) error {
+		for _, lease := range leases {
+			req := *lease
+			if !callback(&req) {
+				break
+			}
+		}
+		return nil
+	}
+
+	qm, err := NewManager(logging.NewVaultLogger(log.Trace), walkFunc, metricsutil.BlackholeSink(), true)
+	require.NoError(t, err)
+	require.NoError(t, qm.Setup(context.Background(), &logical.InmemStorage{}, nil))
+
+	global := NewLeaseCountQuota("global", "", "", "", "", true, 10)
+	require.NoError(t, qm.SetQuota(context.Background(), TypeLeaseCount.String(), global, false))
+	require.Equal(t, int64(4), global.Counter())
+
+	ci := NewLeaseCountQuota("ci", "", "database/", "creds/ci", "", false, 2)
+	require.NoError(t, qm.SetQuota(context.Background(), TypeLeaseCount.String(), ci, false))
+	require.Equal(t, int64(2), ci.Counter())
+	require.Equal(t, int64(2), global.Counter())
+
+	// The quota is full, so requests to create more leases are rejected.
+	resp, err := qm.ApplyQuota(context.Background(), &Request{
+		Type:      TypeLeaseCount,
+		Path:      "database/creds/ci",
+		MountPath: "database/",
+	})
+	require.NoError(t, err)
+	require.False(t, resp.Allowed)
+
+	// Requests to paths which aren't known to generate leases are allowed.
+	resp, err = qm.ApplyQuota(context.Background(), &Request{
+		Type:      TypeLeaseCount,
+		Path:      "database/creds/ci/other",
+		MountPath: "database/",
+	})
+	require.NoError(t, err)
+	require.True(t, resp.Allowed)
+
+	require.NoError(t, qm.HandleLeaseAction(LeaseActionDeleted, []*Request{
+		{Path: "database/creds/ci", MountPath: "database/"},
+	}))
+	require.Equal(t, int64(1), ci.Counter())
+
+	resp, err = qm.ApplyQuota(context.Background(), &Request{
+		Type:      TypeLeaseCount,
+		Path:      "database/creds/ci",
+		MountPath: "database/",
+	})
+	require.NoError(t, err)
+	require.True(t, resp.Allowed)
+	require.NoError(t, qm.AckLeaseQuota(resp.Access, true))
+
+	// Leases for new paths are counted, and their path is then limited.
+	require.False(t, qm.inLeasePathCache("kv/creds/new"))
+	require.NoError(t, qm.HandleLeaseAction(LeaseActionCreated, []*Request{
+		{Path: "kv/creds/new", MountPath: "kv/"},
+	}))
+	require.True(t, qm.inLeasePathCache("kv/creds/new"))
+	require.Equal(t, int64(3), global.Counter())
+
+	// Deleting the more specific quota recomputes the global quota from the
+	// existing leases, which now include those for the deleted quota.
+	require.NoError(t, qm.DeleteQuota(context.Background(), TypeLeaseCount.String(), "ci"))
+	require.Equal(t, int64(len(leases)), global.Counter())
+
+	require.NoError(t, qm.Reset())
+	require.False(t, qm.inLeasePathCache("kv/creds/new"))
+}
+
+// TestManager_leasePathCache ensures that the paths known to generate leases
+// are bounded, evicting the least recently used path first.
+func TestManager_leasePathCache(t *testing.T) {
+	m := &Manager{}
+	m.init(nil)
+	m.leasePaths, _ = lru.New[string, struct{}](2)
+	m.addLeasePath("a")
+	m.addLeasePath("b")
+	require.True(t, m.inLeasePathCache("a"))
+
+	m.addLeasePath("c")
+	require.True(t, m.inLeasePathCache("a"))
+	require.False(t, m.inLeasePathCache("b"))
+	require.True(t, m.inLeasePathCache("c"))
+
+	require.NoError(t, m.entManager.Reset())
+	require.False(t, m.inLeasePathCache("a"))
+}
@@ -35,6 +35,24 @@ const (
 	EnvVaultEnableRateLimitAuditLogging = "VAULT_ENABLE_RATE_LIMIT_AUDIT_LOGGING"
 )
 
+const (
+	// RateLimitKeyByIP keys the rate limiters of a RateLimitQuota on the client
+	// IP address. This is the default.
+	RateLimitKeyByIP = "ip"
+
+	// RateLimitKeyByEntityID keys the rate limiters of a RateLimitQuota on the
+	// entity ID of the client token.
+	RateLimitKeyByEntityID = "entity_id"
+
+	// RateLimitKeyByTokenAccessor keys the rate limiters of a RateLimitQuota on
+	// the accessor of the client token.
+	RateLimitKeyByTokenAccessor = "token_accessor"
+
+	// RateLimitKeyByMetadata keys the rate limiters of a RateLimitQuota on the
+	// value of a metadata field of the client token.
+	RateLimitKeyByMetadata = "metadata"
+)
+
 // Ensure that RateLimitQuota implements the Quota interface
 var _ Quota = (*RateLimitQuota)(nil)
 
@@ -78,6 +96,15 @@ type RateLimitQuota struct {
 	// reaches the rate limit.
 	BlockInterval time.Duration `json:"block_interval"`
 
+	// KeyBy defines what identifies a client, each client has its own rate
+	// limiter. Requests are keyed on the client IP address when empty, or when
+	// the request does not carry the identity KeyBy refers to.
+	KeyBy string `json:"key_by"`
+
+	// KeyByMetadata is the name of the client token metadata field to key on,
+	// when KeyBy is RateLimitKeyByMetadata.
+	KeyByMetadata string `json:"key_by_metadata"`
+
 	lock                *sync.RWMutex
 	store               limiter.Store
 	logger              log.Logger
@@ -134,6 +161,8 @@ func (q *RateLimitQuota) Clone() Quota {
 		BlockInterval: q.BlockInterval,
 		Rate:          q.Rate,
 		Interval:      q.Interval,
+		KeyBy:         q.KeyBy,
+		KeyByMetadata: q.KeyByMetadata,
 	}
 	return rlq
 }
@@ -171,6 +200,14 @@ func (rlq *RateLimitQuota) initialize(logger log.Logger, ms *metricsutil.Cluster
 		return fmt.Errorf("invalid block interval: %v", rlq.BlockInterval)
 	}
 
+	if rlq.KeyBy == "" {
+		rlq.KeyBy = RateLimitKeyByIP
+	}
+
+	if err := ValidateRateLimitKeyBy(rlq.KeyBy, rlq.KeyByMetadata); err != nil {
+		return err
+	}
+
 	if logger != nil {
 		rlq.logger = logger
 	}
@@ -291,16 +328,64 @@ func (rlq *RateLimitQuota) QuotaName() string {
 	return rlq.Name
 }
 
+// ValidateRateLimitKeyBy ensures that keyBy is a supported way of identifying
+// clients, and that a metadata field is given only when keying on metadata.
+func ValidateRateLimitKeyBy(keyBy, metadata string) error {
+	switch keyBy {
+	case RateLimitKeyByIP, RateLimitKeyByEntityID, RateLimitKeyByTokenAccessor:
+		if metadata != "" {
+			return fmt.Errorf("key by metadata field can only be set when keying on %q", RateLimitKeyByMetadata)
+		}
+	case RateLimitKeyByMetadata:
+		if metadata == "" {
+			return fmt.Errorf("key by metadata field is required when keying on %q", RateLimitKeyByMetadata)
+		}
+	default:
+		return fmt.Errorf("invalid key by: %q", keyBy)
+	}
+
+	return nil
+}
+
+// keyedOnIdentity returns true if the client rate limiters are keyed on the
+// identity of the client token, rather than the client address.
+func (rlq *RateLimitQuota) keyedOnIdentity() bool {
+	return rlq.KeyBy != "" && rlq.KeyBy != RateLimitKeyByIP
+}
+
+// clientKey returns the key of the rate limiter for the client making the
+// request. If the request does not carry the identity the quota is keyed on,
+// for example because it is unauthenticated, the client address is used.
+func (rlq *RateLimitQuota) clientKey(req *Request) string {
+	switch rlq.KeyBy {
+	case RateLimitKeyByEntityID:
+		if req.EntityID != "" {
+			return "entity_id:" + req.EntityID
+		}
+	case RateLimitKeyByTokenAccessor:
+		if req.TokenAccessor != "" {
+			return "token_accessor:" + req.TokenAccessor
+		}
+	case RateLimitKeyByMetadata:
+		if v := req.TokenMetadata[rlq.KeyByMetadata]; v != "" {
+			return "metadata:" + v
+		}
+	}
+
+	return req.ClientAddress
+}
+
 // allow decides if the request is allowed by the quota. An error will be
 // returned if the request ID or address is empty. If the path is exempt, the
 // quota will not be evaluated. Otherwise, the client rate limiter is retrieved
-// by address and the rate limit quota is checked against that limiter.
+// by the client key and the rate limit quota is checked against that limiter.
 func (rlq *RateLimitQuota) allow(ctx context.Context, req *Request) (Response, error) {
 	resp := Response{
 		Headers: make(map[string]string),
 	}
 
-	if req.ClientAddress == "" {
+	key := rlq.clientKey(req)
+	if key == "" {
 		return resp, fmt.Errorf("missing request client address in quota request")
 	}
 
@@ -318,11 +403,11 @@ func (rlq *RateLimitQuota) allow(ctx context.Context, req *Request) (Response, e
 	// of purging blocked clients may not yield a false negative. In other words,
 	// a client may no longer be considered blocked whereas the purging interval
 	// has yet to run.
-	if v, ok := rlq.blockedClients.Load(req.ClientAddress); ok {
+	if v, ok := rlq.blockedClients.Load(key); ok {
 		blockedAt := v.(time.Time)
 		if time.Since(blockedAt) >= rlq.BlockInterval {
 			// allow the request and remove the blocked client
-			rlq.blockedClients.Delete(req.ClientAddress)
+			rlq.blockedClients.Delete(key)
 		} else {
 			// deny the request and return early
 			resp.Allowed = false
@@ -331,7 +416,7 @@ func (rlq *RateLimitQuota) allow(ctx context.Context, req *Request) (Response, e
 		}
 	}
 
-	limit, remaining, reset, allow, err := rlq.store.Take(ctx, req.ClientAddress)
+	limit, remaining, reset, allow, err := rlq.store.Take(ctx, key)
 	if err != nil {
 		return resp, err
 	}
@@ -347,7 +432,7 @@ func (rlq *RateLimitQuota) allow(ctx context.Context, req *Request) (Response, e
 	if !resp.Allowed && rlq.purgeBlocked {
 		blockedAt := time.Now()
 		retryAfter = strconv.Itoa(int(time.Until(blockedAt.Add(rlq.BlockInterval)).Seconds()))
-		rlq.blockedClients.Store(req.ClientAddress, blockedAt)
+		rlq.blockedClients.Store(key, blockedAt)
 	}
 
 	return resp, nil
@@ -231,3 +231,129 @@ func TestRateLimitQuota_Update(t *testing.T) {
 
 	require.Nil(t, quota.close(context.Background()))
 }
+
+func TestRateLimitQuota_KeyBy_Initialize(t *testing.T) {
+	testCases := []struct {
+		name          string
+		keyBy         string
+		keyByMetadata string
+		expectErr     string
+	}{
+		{"default", "", "", ""},
+		{"entity id", RateLimitKeyByEntityID, "", ""},
+		{"token accessor", RateLimitKeyByTokenAccessor, "", ""},
+		{"metadata", RateLimitKeyByMetadata, "service", ""},
+		{"metadata without field", RateLimitKeyByMetadata, "", `key by metadata field is required when keying on "metadata"`},
+		{"field without metadata", RateLimitKeyByEntityID, "service", `key by metadata field can only be set when keying on "metadata"`},
+		{"unknown", "role", "", `invalid key by: "role"`},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			rlq := NewRateLimitQuota("test-rate-limiter", "qa", "/foo/bar", "", "", false, time.Second, 0, 10)
+			rlq.KeyBy = tc.keyBy
+			rlq.KeyByMetadata = tc.keyByMetadata
+
+			err := rlq.initialize(logging.NewVaultLogger(log.Trace), metricsutil.BlackholeSink())
+			if tc.expectErr != "" {
+				require.EqualError(t, err, tc.expectErr)
+				return
+			}
+			require.NoError(t, err)
+			require.NotEmpty(t, rlq.KeyBy)
+			require.Nil(t, rlq.close(context.Background()))
+		})
+	}
+}
+
+// TestRateLimitQuota_Allow_KeyBy ensures that clients sharing an address are
+// limited separately when the quota is keyed on their identity, and that
+// requests without the identity are keyed on the client address.
+func TestRateLimitQuota_Allow_KeyBy(t *testing.T) {
+	testCases := []struct {
+		name    string
+		keyBy   string
+		request func(identity string) *Request
+	}{
+		{
+			name:  "entity id",
+			keyBy: RateLimitKeyByEntityID,
+			request: func(identity string) *Request {
+				return &Request{ClientAddress: "127.0.0.1", EntityID: identity}
+			},
+		},
+		{
+			name:  "token accessor",
+			keyBy: RateLimitKeyByTokenAccessor,
+			request: func(identity string) *Request {
+				return &Request{ClientAddress: "127.0.0.1", TokenAccessor: identity}
+			},
+		},
+		{
+			name:  "metadata",
+			keyBy: RateLimitKeyByMetadata,
+			request: func(identity string) *Request {
+				return &Request{ClientAddress: "127.0.0.1", TokenMetadata: map[string]string{"service": identity}}
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+
+		t.Run(tc.name, func(t *testing.T) {
+			rlq := NewRateLimitQuota("test-rate-limiter", "qa", "/foo/bar", "", "", false, time.Hour, 0, 1)
+			rlq.KeyBy = tc.keyBy
+			if tc.keyBy == RateLimitKeyByMetadata {
+				rlq.KeyByMetadata = "service"
+			}
+			require.NoError(t, rlq.initialize(logging.NewVaultLogger(log.Trace), metricsutil.BlackholeSink()))
+			defer rlq.close(context.Background())
+			require.True(t, rlq.keyedOnIdentity())
+
+			allow := func(req *Request) bool {
+				t.Helper()
+				resp, err := rlq.allow(context.Background(), req)
+				require.NoError(t, err)
+				return resp.Allowed
+			}
+
+			require.True(t, allow(tc.request("noisy")))
+			require.False(t, allow(tc.request("noisy")))
+
+			// Another client behind the same address is not affected.
+			require.True(t, allow(tc.request("quiet")))
+
+			// Requests without the identity share the limiter for the address.
+			require.True(t, allow(tc.request("")))
+			require.False(t, allow(&Request{ClientAddress: "127.0.0.1"}))
+		})
+	}
+}
+
+// TestQuotas_QueryResolveIdentityQuotas ensures that the client identity only
+// needs to be resolved when the applicable rate limit quota is keyed on it.
+func TestQuotas_QueryResolveIdentityQuotas(t *testing.T) {
+	qm, err := NewManager(logging.NewVaultLogger(log.Trace), nil, metricsutil.BlackholeSink(), true)
+	require.NoError(t, err)
+	require.NoError(t, qm.Setup(context.Background(), &logical.InmemStorage{}, nil))
+
+	global := NewRateLimitQuota("global", "", "", "", "", true, time.Second, 0, 10)
+	require.NoError(t, qm.SetQuota(context.Background(), TypeRateLimit.String(), global, false))
+
+	kv := NewRateLimitQuota("kv", "", "kv/", "", "", false, time.Second, 0, 10)
+	kv.KeyBy = RateLimitKeyByEntityID
+	require.NoError(t, qm.SetQuota(context.Background(), TypeRateLimit.String(), kv, false))
+
+	required, err := qm.QueryResolveIdentityQuotas(&Request{Path: "kv/foo", MountPath: "kv/"})
+	require.NoError(t, err)
+	require.True(t, required)
+
+	required, err = qm.QueryResolveIdentityQuotas(&Request{Path: "pki/issue/foo", MountPath: "pki/"})
+	require.NoError(t, err)
+	require.False(t, required)
+
+	require.NoError(t, qm.Reset())
+}
@@ -8,27 +8,90 @@ package quotas
 import (
 	"context"
 
-	log "github.com/hashicorp/go-hclog"
 	"github.com/hashicorp/go-memdb"
-	"github.com/hashicorp/vault/helper/metricsutil"
+	lru "github.com/hashicorp/golang-lru/v2"
 )
 
+// leasePathCacheSize is the maximum number of paths which are known to generate
+// leases. The least recently used paths are evicted first, and are added again
+// the next time a lease is created for them.
+const leasePathCacheSize = 100000
+
 func quotaTypes() []string {
 	return []string{
+		TypeLeaseCount.String(),
 		TypeRateLimit.String(),
+		TypeConcurrency.String(),
 	}
 }
 
-func (m *Manager) init(walkFunc leaseWalkFunc) {}
+func (m *Manager) init(walkFunc leaseWalkFunc) {
+	m.leaseWalkFunc = walkFunc
+	m.leasePaths, _ = lru.New[string, struct{}](leasePathCacheSize)
+}
 
+// recomputeLeaseCounts resets the counters of all the lease count quotas in the
+// transaction, then walks the existing leases to count those which are subject
+// to each quota.
 func (m *Manager) recomputeLeaseCounts(ctx context.Context, txn *memdb.Txn) error {
+	iter, err := txn.Get(TypeLeaseCount.String(), indexID)
+	if err != nil {
+		return err
+	}
+
+	var lcqs []*LeaseCountQuota
+	for raw := iter.Next(); raw != nil; raw = iter.Next() {
+		lcq := raw.(*LeaseCountQuota)
+		lcq.resetCounter()
+		lcqs = append(lcqs, lcq)
+	}
+
+	if len(lcqs) == 0 || m.leaseWalkFunc == nil {
+		return nil
+	}
+
+	var queryErr error
+	err = m.leaseWalkFunc(ctx, func(req *Request) bool {
+		req.Type = TypeLeaseCount
+		m.addLeasePath(req.Path)
+
+		quota, err := m.queryQuota(txn, req)
+		if err != nil {
+			queryErr = err
+			return false
+		}
+		if quota != nil {
+			quota.(*LeaseCountQuota).counter.Add(1)
+		}
+
+		return true
+	})
+	if err != nil {
+		return err
+	}
+	if queryErr != nil {
+		return queryErr
+	}
+
+	for _, lcq := range lcqs {
+		lcq.emitCounter(lcq.counter.Load())
+	}
+
 	return nil
 }
 
 func (m *Manager) setIsPerfStandby(quota Quota) {}
 
+// inLeasePathCache returns true if leases have been generated for the path.
 func (m *Manager) inLeasePathCache(path string) bool {
-	return false
+	// Get, rather than Contains, so that paths which are in use aren't evicted.
+	_, ok := m.leasePaths.Get(path)
+	return ok
+}
+
+// addLeasePath records that leases have been generated for the path.
+func (m *Manager) addLeasePath(path string) {
+	m.leasePaths.Add(path, struct{}{})
 }
 
 func (m *Manager) setupDefaultLeaseCountQuotaInStorage(_ctx context.Context) error {
@@ -39,46 +102,18 @@ type entManager struct {
 	isPerfStandby bool
 	isDRSecondary bool
 	isNewInstall  bool
-}
 
-func (*entManager) Reset() error {
-	return nil
-}
-
-type LeaseCountQuota struct{}
-
-func (l LeaseCountQuota) GetNamespacePath() string {
-	panic("implement me")
-}
-
-func (l LeaseCountQuota) IsInheritable() bool {
-	panic("implement me")
-}
-
-func (l LeaseCountQuota) allow(_ context.Context, _ *Request) (Response, error) {
-	panic("implement me")
-}
+	// leaseWalkFunc walks the existing leases, so that lease count quotas can
+	// be populated.
+	leaseWalkFunc leaseWalkFunc
 
-func (l LeaseCountQuota) quotaID() string {
-	panic("implement me")
+	// leasePaths holds the request paths which are known to generate leases.
+	// Lease count quotas are only applied to requests to these paths.
+	leasePaths *lru.Cache[string, struct{}]
 }
 
-func (l LeaseCountQuota) QuotaName() string {
-	panic("implement me")
-}
-
-func (l LeaseCountQuota) initialize(logger log.Logger, sink *metricsutil.ClusterMetricSink) error {
-	panic("implement me")
-}
-
-func (l LeaseCountQuota) close(_ context.Context) error {
-	panic("implement me")
-}
-
-func (l LeaseCountQuota) Clone() Quota {
-	panic("implement me")
-}
+func (e *entManager) Reset() error {
+	e.leasePaths.Purge()
 
-func (l LeaseCountQuota) handleRemount(mountPath, nsPath string) {
-	panic("implement me")
+	return nil
 }
@@ -577,6 +577,9 @@ func (c *Core) switchedLockHandleRequest(httpCtx context.Context, req *logical.R
 	if ok {
 		ctx = context.WithValue(ctx, logical.CtxKeyRequestRole{}, requestRole)
 	}
+	if qte, ok := httpCtx.Value(ctxKeyQuotaTokenEntry{}).(*quotaTokenEntry); ok {
+		ctx = context.WithValue(ctx, ctxKeyQuotaTokenEntry{}, qte)
+	}
 	if disable_repl_status, ok := logical.ContextDisableReplicationStatusEndpointsValue(httpCtx); ok {
 		ctx = logical.CreateContextDisableReplicationStatusEndpoints(ctx, disable_repl_status)
 	}
@@ -1973,6 +1976,9 @@ func (c *Core) handleDelegatedAuth(ctx context.Context, origReq *logical.Request
 	if err != nil {
 		return nil, nil, err
 	}
+	// The body of the HTTP request is lost when cloned, so share it with the
+	// reissued request for backends reading it on binary paths, such as EST
+	secondReq.HTTPRequest = origReq.HTTPRequest
 	secondReq.ClientToken = authResp.Auth.ClientToken
 	secondReq.ClientTokenSource = logical.ClientTokenFromInternalAuth
 	resp, err := c.handleCancelableRequest(ctx, secondReq)
@@ -2547,7 +2553,11 @@ func (c *Core) PopulateTokenEntry(ctx context.Context, req *logical.Request) err
 	req.ClientToken = decodedToken
 	// We ignore the token returned from CheckSSCToken here as Lookup also
 	// decodes the SSCT, and it may need the original SSCT to check state.
-	te, err := c.LookupToken(ctx, token)
+	// If the token was already looked up to apply quotas, reuse the entry.
+	var te *logical.TokenEntry
+	if te = quotaTokenEntryFromContext(ctx, token); te == nil {
+		te, err = c.LookupToken(ctx, token)
+	}
 	if err != nil {
 		// If we're missing required state, return that error
 		// as-is to the client
@@ -4,6 +4,7 @@
 package vault
 
 import (
+	"context"
 	"strings"
 	"testing"
 	"time"
@@ -15,6 +16,8 @@ import (
 	credUserpass "github.com/hashicorp/vault/builtin/credential/userpass"
 	"github.com/hashicorp/vault/helper/namespace"
 	"github.com/hashicorp/vault/sdk/logical"
+	"github.com/hashicorp/vault/vault/quotas"
+	"github.com/stretchr/testify/require"
 )
 
 func TestRequestHandling_Wrapping(t *testing.T) {
@@ -478,3 +481,33 @@ func TestRequestHandling_SecretLeaseMetric(t *testing.T) {
 		},
 	)
 }
+
+// TestRequestHandling_QuotaTokenEntry ensures that the client token entry which
+// is looked up to apply a rate limit quota keyed on the client identity is
+// reused when the request is handled, rather than being looked up again.
+func TestRequestHandling_QuotaTokenEntry(t *testing.T) {
+	c, _, root := TestCoreUnsealed(t)
+
+	rlq := quotas.NewRateLimitQuota("kv", "", "", "", "", true, time.Second, 0, 10)
+	rlq.KeyBy = quotas.RateLimitKeyByEntityID
+	require.NoError(t, c.quotaManager.SetQuota(namespace.RootContext(context.Background()), quotas.TypeRateLimit.String(), rlq, false))
+
+	quotaReq := &quotas.Request{Path: "secret/foo", NamespacePath: "root"}
+	ctx, err := c.ResolveIdentityForQuotas(namespace.RootContext(context.Background()), quotaReq, root)
+	require.NoError(t, err)
+
+	te := quotaTokenEntryFromContext(ctx, root)
+	require.NotNil(t, te)
+	require.Equal(t, te.Accessor, quotaReq.TokenAccessor)
+	require.Nil(t, quotaTokenEntryFromContext(ctx, "other"))
+
+	req := logical.TestRequest(t, logical.ReadOperation, "sys/mounts")
+	req.ClientToken = root
+	require.NoError(t, c.PopulateTokenEntry(ctx, req))
+	require.Same(t, te, req.TokenEntry())
+
+	req = logical.TestRequest(t, logical.ReadOperation, "sys/mounts")
+	req.ClientToken = root
+	_, err = c.HandleRequest(ctx, req)
+	require.NoError(t, err)
+}
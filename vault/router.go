@@ -63,14 +63,15 @@ type routeEntry struct {
 	tainted atomic.Bool
 	// backend is the actual backend instance for this route entry; lock l must
 	// be held to access this field.
-	backend       logical.Backend
-	mountEntry    *MountEntry
-	storageView   logical.Storage
-	storagePrefix string
-	rootPaths     atomic.Value
-	loginPaths    atomic.Value
-	binaryPaths   atomic.Value
-	limitedPaths  atomic.Value
+	backend        logical.Backend
+	mountEntry     *MountEntry
+	storageView    logical.Storage
+	storagePrefix  string
+	rootPaths      atomic.Value
+	loginPaths     atomic.Value
+	binaryPaths    atomic.Value
+	limitedPaths   atomic.Value
+	streamingPaths atomic.Value
 	// l is the lock used to protect access to backend during reloads
 	l sync.RWMutex
 }
@@ -225,6 +226,12 @@ func (r *Router) Mount(backend logical.Backend, prefix string, mountEntry *Mount
 	}
 	re.limitedPaths.Store(limitedPathsEntry)
 
+	streamingPathsEntry, err := parseUnauthenticatedPaths(paths.Streaming)
+	if err != nil {
+		return err
+	}
+	re.streamingPaths.Store(streamingPathsEntry)
+
 	switch {
 	case prefix == "":
 		return fmt.Errorf("missing prefix to be used for router entry; mount_path: %q, mount_type: %q", re.mountEntry.Path, re.mountEntry.Type)
@@ -908,9 +915,17 @@ func (r *Router) LimitedPath(ctx context.Context, path string) bool {
 		})
 }
 
+// StreamingPath checks if the given path streams its request and response
+func (r *Router) StreamingPath(ctx context.Context, path string) bool {
+	return r.specialPath(ctx, path,
+		func(re *routeEntry) *specialPathsEntry {
+			return re.streamingPaths.Load().(*specialPathsEntry)
+		})
+}
+
 // specialPath is a common method for checking if the given path has a matching
-// PathsSpecial entry. This is used for Login, Binary, and Limited PathsSpecial
-// fields.
+// PathsSpecial entry. This is used for Login, Binary, Limited and Streaming
+// PathsSpecial fields.
 // Matching Priority
 //  1. prefix
 //  2. exact
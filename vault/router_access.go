@@ -24,6 +24,10 @@ func (r *RouterAccess) IsBinaryPath(ctx context.Context, path string) bool {
 	return r.c.router.BinaryPath(ctx, path)
 }
 
+func (r *RouterAccess) IsStreamingPath(ctx context.Context, path string) bool {
+	return r.c.router.StreamingPath(ctx, path)
+}
+
 func (r *RouterAccess) IsLimitedPath(ctx context.Context, path string) bool {
 	return r.c.router.LimitedPath(ctx, path)
 }